@@ -33,6 +33,7 @@ const EnvVaultWrapTTL = "VAULT_WRAP_TTL"
 const EnvVaultMaxRetries = "VAULT_MAX_RETRIES"
 const EnvVaultToken = "VAULT_TOKEN"
 const EnvVaultMFA = "VAULT_MFA"
+const EnvVaultNamespace = "VAULT_NAMESPACE"
 
 // WrappingLookupFunc is a function that, given an HTTP verb and a path,
 // returns an optional string duration to be used for response wrapping (e.g.
@@ -244,6 +245,7 @@ type Client struct {
 	addr               *url.URL
 	config             *Config
 	token              string
+	namespace          string
 	headers            http.Header
 	wrappingLookupFunc WrappingLookupFunc
 	mfaCreds           []string
@@ -260,7 +262,8 @@ func (c *Client) SetMFACreds(creds []string) {
 //
 // If the environment variable `VAULT_TOKEN` is present, the token will be
 // automatically added to the client. Otherwise, you must manually call
-// `SetToken()`.
+// `SetToken()`. Likewise, `VAULT_NAMESPACE` sets the namespace of the
+// client.
 func NewClient(c *Config) (*Client, error) {
 	if c == nil {
 		c = DefaultConfig()
@@ -312,6 +315,10 @@ func NewClient(c *Config) (*Client, error) {
 		client.SetToken(token)
 	}
 
+	if namespace := os.Getenv(EnvVaultNamespace); namespace != "" {
+		client.SetNamespace(namespace)
+	}
+
 	return client, nil
 }
 
@@ -365,6 +372,23 @@ func (c *Client) ClearToken() {
 	c.token = ""
 }
 
+// Namespace returns the namespace requests are made in. It will return the
+// empty string for the root namespace.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// SetNamespace sets the namespace future requests are made in. Request
+// paths are then relative to the namespace.
+func (c *Client) SetNamespace(namespace string) {
+	c.namespace = namespace
+}
+
+// ClearNamespace makes future requests in the root namespace.
+func (c *Client) ClearNamespace() {
+	c.namespace = ""
+}
+
 // SetHeaders sets the headers to be used for future requests.
 func (c *Client) SetHeaders(headers http.Header) {
 	c.headers = headers
@@ -375,6 +399,31 @@ func (c *Client) Clone() (*Client, error) {
 	return NewClient(c.config)
 }
 
+// CloneWithState creates a copy of this client that, unlike Clone, also
+// carries over its token, namespace, headers and other settings. The copy
+// shares the configuration and the underlying HTTP client of this client,
+// but its settings can be changed independently.
+func (c *Client) CloneWithState() (*Client, error) {
+	var headers http.Header
+	if c.headers != nil {
+		headers = make(http.Header, len(c.headers))
+		for k, v := range c.headers {
+			headers[k] = v
+		}
+	}
+
+	return &Client{
+		addr:               c.addr,
+		config:             c.config,
+		token:              c.token,
+		namespace:          c.namespace,
+		headers:            headers,
+		wrappingLookupFunc: c.wrappingLookupFunc,
+		mfaCreds:           c.mfaCreds,
+		policyOverride:     c.policyOverride,
+	}, nil
+}
+
 // SetPolicyOverride sets whether requests should be sent with the policy
 // override flag to request overriding soft-mandatory Sentinel policies (both
 // RGPs and EGPs)
@@ -406,6 +455,7 @@ func (c *Client) NewRequest(method, requestPath string) *Request {
 			Path:   path.Join(c.addr.Path, requestPath),
 		},
 		ClientToken: c.token,
+		Namespace:   c.namespace,
 		Params:      make(map[string][]string),
 	}
 
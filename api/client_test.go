@@ -95,6 +95,76 @@ func TestClientToken(t *testing.T) {
 	}
 }
 
+func TestClientNamespace(t *testing.T) {
+	var actual string
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		actual = req.Header.Get("X-Vault-Namespace")
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client.SetNamespace("ns1/child")
+	if _, err := client.RawRequest(client.NewRequest("GET", "/")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != "ns1/child" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	client.ClearNamespace()
+	if _, err := client.RawRequest(client.NewRequest("GET", "/")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != "" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestClientCloneWithState(t *testing.T) {
+	var actual string
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		actual = req.Header.Get("X-Vault-Token")
+	}
+
+	config, ln := testHTTPServer(t, http.HandlerFunc(handler))
+	defer ln.Close()
+
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	client.SetToken("foo")
+
+	// Clones can be made repeatedly and changed independently
+	for i := 0; i < 2; i++ {
+		clone, err := client.CloneWithState()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if clone.Token() != "foo" {
+			t.Fatalf("bad: %s", clone.Token())
+		}
+
+		clone.SetToken("bar")
+		if _, err := clone.RawRequest(clone.NewRequest("GET", "/")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if actual != "bar" {
+			t.Fatalf("bad: %s", actual)
+		}
+	}
+
+	if client.Token() != "foo" {
+		t.Fatalf("bad: %s", client.Token())
+	}
+}
+
 func TestClientRedirect(t *testing.T) {
 	primary := func(w http.ResponseWriter, req *http.Request) {
 		w.Write([]byte("test"))
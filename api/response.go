@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/hashicorp/vault/helper/jsonutil"
@@ -22,8 +23,9 @@ func (r *Response) DecodeJSON(out interface{}) error {
 }
 
 // Error returns an error response if there is one. If there is an error,
-// this will fully consume the response body, but will not close it. The
-// body must still be closed manually.
+// the response body is buffered so that it can still be read by callers
+// that need the raw response, such as proxies. The body must still be
+// closed manually.
 func (r *Response) Error() error {
 	// 200 to 399 are okay status codes. 429 is the code for health status of
 	// standby nodes.
@@ -37,6 +39,8 @@ func (r *Response) Error() error {
 	if _, err := io.Copy(&bodyBuf, r.Body); err != nil {
 		return err
 	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(bodyBuf.Bytes()))
 
 	// Decode the error response if we can. Note that we wrap the bodyBuf
 	// in a bytes.Reader here so that the JSON decoder doesn't move the
@@ -120,19 +120,21 @@ func (c *Sys) MountConfig(path string) (*MountConfigOutput, error) {
 }
 
 type MountInput struct {
-	Type        string           `json:"type" structs:"type"`
-	Description string           `json:"description" structs:"description"`
-	Config      MountConfigInput `json:"config" structs:"config"`
-	Local       bool             `json:"local" structs:"local"`
-	PluginName  string           `json:"plugin_name,omitempty" structs:"plugin_name"`
+	Type        string            `json:"type" structs:"type"`
+	Description string            `json:"description" structs:"description"`
+	Config      MountConfigInput  `json:"config" structs:"config"`
+	Local       bool              `json:"local" structs:"local"`
+	PluginName  string            `json:"plugin_name,omitempty" structs:"plugin_name"`
+	Options     map[string]string `json:"options,omitempty" structs:"options,omitempty"`
 }
 
 type MountConfigInput struct {
-	DefaultLeaseTTL string `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
-	MaxLeaseTTL     string `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
-	ForceNoCache    bool   `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
-	PluginName      string `json:"plugin_name,omitempty" structs:"plugin_name,omitempty" mapstructure:"plugin_name"`
-	SealWrap        bool   `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	DefaultLeaseTTL string            `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
+	MaxLeaseTTL     string            `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
+	ForceNoCache    bool              `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
+	PluginName      string            `json:"plugin_name,omitempty" structs:"plugin_name,omitempty" mapstructure:"plugin_name"`
+	SealWrap        bool              `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	Options         map[string]string `json:"options,omitempty" structs:"options,omitempty" mapstructure:"options"`
 }
 
 type MountOutput struct {
@@ -140,13 +142,15 @@ type MountOutput struct {
 	Description string            `json:"description" structs:"description"`
 	Accessor    string            `json:"accessor" structs:"accessor"`
 	Config      MountConfigOutput `json:"config" structs:"config"`
+	Options     map[string]string `json:"options" structs:"options"`
 	Local       bool              `json:"local" structs:"local"`
 }
 
 type MountConfigOutput struct {
-	DefaultLeaseTTL int    `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
-	MaxLeaseTTL     int    `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
-	ForceNoCache    bool   `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
-	PluginName      string `json:"plugin_name,omitempty" structs:"plugin_name,omitempty" mapstructure:"plugin_name"`
-	SealWrap        bool   `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	DefaultLeaseTTL int               `json:"default_lease_ttl" structs:"default_lease_ttl" mapstructure:"default_lease_ttl"`
+	MaxLeaseTTL     int               `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
+	ForceNoCache    bool              `json:"force_no_cache" structs:"force_no_cache" mapstructure:"force_no_cache"`
+	PluginName      string            `json:"plugin_name,omitempty" structs:"plugin_name,omitempty" mapstructure:"plugin_name"`
+	SealWrap        bool              `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	Options         map[string]string `json:"options,omitempty" structs:"options,omitempty" mapstructure:"options"`
 }
@@ -0,0 +1,117 @@
+package jwtauth
+
+import (
+	"context"
+	"sync"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	configPath string = "config"
+	rolePrefix string = "role/"
+)
+
+type backend struct {
+	*framework.Backend
+
+	// Locks to make changes to role entries. These are indexed based on the
+	// role names.
+	roleLocks []*locksutil.LockEntry
+
+	// l guards the cached configuration and the key set derived from it
+	l            sync.RWMutex
+	cachedConfig *jwtConfig
+	keySet       oidc.KeySet
+
+	// providerCtx is used when fetching keys of JWKS and OIDC discovery
+	// endpoints. It outlives individual requests since the key sets refresh
+	// themselves in the background, and is cancelled when the configuration
+	// changes or the backend is cleaned up.
+	providerCtx       context.Context
+	providerCtxCancel context.CancelFunc
+}
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend()
+	if err := b.Setup(conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func Backend() *backend {
+	b := &backend{
+		roleLocks: locksutil.CreateLocks(),
+	}
+	b.providerCtx, b.providerCtxCancel = context.WithCancel(context.Background())
+
+	b.Backend = &framework.Backend{
+		AuthRenew:   b.pathLoginRenew,
+		BackendType: logical.TypeCredential,
+		Invalidate:  b.invalidate,
+		Help:        backendHelp,
+		Clean:       b.cleanup,
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"login",
+			},
+			SealWrapStorage: []string{
+				configPath,
+			},
+		},
+		Paths: framework.PathAppend(
+			[]*framework.Path{
+				pathLogin(b),
+				pathConfig(b),
+			},
+			pathRoles(b),
+		),
+	}
+
+	return b
+}
+
+func (b *backend) cleanup() {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.providerCtxCancel != nil {
+		b.providerCtxCancel()
+	}
+}
+
+func (b *backend) invalidate(key string) {
+	switch key {
+	case configPath:
+		b.reset()
+	}
+}
+
+// reset drops the cached configuration and key set, so that they are rebuilt
+// from storage the next time they are needed
+func (b *backend) reset() {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.providerCtxCancel != nil {
+		b.providerCtxCancel()
+	}
+	b.providerCtx, b.providerCtxCancel = context.WithCancel(context.Background())
+	b.cachedConfig = nil
+	b.keySet = nil
+}
+
+const backendHelp = `
+The JWT backend plugin allows authentication using JWTs, including OIDC ID
+tokens.
+
+The signatures of the tokens are verified using statically configured public
+keys, the keys published at a JWKS URL, or the keys of an OIDC provider found
+through its discovery URL. Roles bind the claims a token must carry in order
+to log in, and determine the policies and the identity aliases of the tokens
+that are issued.
+`
@@ -0,0 +1,634 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func getBackend(t *testing.T) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+func testGenerateKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}))
+}
+
+func testSignJWT(t *testing.T, key crypto.Signer, keyID string, claims jwt.Claims, privateClaims interface{}) string {
+	signingKey := jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key:       key,
+	}
+	if keyID != "" {
+		signingKey.Key = jose.JSONWebKey{
+			Key:   key,
+			KeyID: keyID,
+		}
+	}
+
+	sig, err := jose.NewSigner(signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := jwt.Signed(sig).Claims(claims).Claims(privateClaims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func testDefaultClaims() jwt.Claims {
+	return jwt.Claims{
+		Issuer:    "https://team-vault.auth0.com/",
+		Subject:   "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+		Audience:  jwt.Audience{"https://vault.plugin.auth.jwt.test"},
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-5 * time.Second)),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(5 * time.Second)),
+	}
+}
+
+func testDefaultPrivateClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"https://vault/user": "jeff",
+		"https://vault/groups": []string{
+			"foo",
+			"bar",
+		},
+		"team": "engineering",
+	}
+}
+
+func testWriteRole(t *testing.T, b *backend, storage logical.Storage, data map[string]interface{}) {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+}
+
+func testDefaultRole() map[string]interface{} {
+	return map[string]interface{}{
+		"bound_audiences": "https://vault.plugin.auth.jwt.test",
+		"bound_subject":   "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+		"user_claim":      "https://vault/user",
+		"groups_claim":    "https://vault/groups",
+		"policies":        "test",
+		"period":          "3s",
+		"token_ttl":       "1s",
+		"token_max_ttl":   "5s",
+	}
+}
+
+func testLogin(b *backend, storage logical.Storage, token string) (*logical.Response, error) {
+	return b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"role": "plugin-test",
+			"jwt":  token,
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	})
+}
+
+func TestConfig_Write(t *testing.T) {
+	b, storage := getBackend(t)
+	_, pubKey := testGenerateKey(t)
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+	}
+
+	// Exactly one method of validating signatures must be set
+	for _, data := range []map[string]interface{}{
+		{},
+		{"jwt_validation_pubkeys": pubKey, "jwks_url": "https://127.0.0.1/jwks"},
+		{"jwks_url": "https://127.0.0.1/jwks", "oidc_discovery_url": "https://127.0.0.1"},
+		{"jwt_validation_pubkeys": "notapublickey"},
+		{"jwt_validation_pubkeys": pubKey, "jwks_ca_pem": "notacert"},
+		{"jwks_url": "https://127.0.0.1/jwks", "jwks_ca_pem": "notacert"},
+	} {
+		req.Data = data
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for config %#v", data)
+		}
+	}
+
+	req.Data = map[string]interface{}{
+		"jwt_validation_pubkeys": pubKey,
+		"bound_issuer":           "an issuer",
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	req.Data = nil
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	expected := map[string]interface{}{
+		"oidc_discovery_url":     "",
+		"oidc_discovery_ca_pem":  "",
+		"jwks_url":               "",
+		"jwks_ca_pem":            "",
+		"jwt_validation_pubkeys": []string{strings.TrimSpace(pubKey)},
+		"bound_issuer":           "an issuer",
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: expected:%#v\nactual:%#v", expected, resp.Data)
+	}
+}
+
+func TestRole_CRUD(t *testing.T) {
+	b, storage := getBackend(t)
+
+	testWriteRole(t, b, storage, testDefaultRole())
+
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	expected := map[string]interface{}{
+		"policies":        []string{"test"},
+		"token_num_uses":  0,
+		"token_ttl":       int64(1),
+		"token_max_ttl":   int64(5),
+		"period":          int64(3),
+		"bound_cidr_list": "",
+		"bound_audiences": []string{"https://vault.plugin.auth.jwt.test"},
+		"bound_subject":   "r3qXcK2bix9eFECzsU3Sbmh0K16fatW6@clients",
+		"bound_claims":    map[string]interface{}(nil),
+		"user_claim":      "https://vault/user",
+		"groups_claim":    "https://vault/groups",
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: expected:%#v\nactual:%#v", expected, resp.Data)
+	}
+
+	req.Operation = logical.UpdateOperation
+	req.Data = map[string]interface{}{
+		"bound_subject": "",
+		"bound_claims":  map[string]interface{}{"team": "engineering"},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	req.Data = nil
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["bound_subject"].(string) != "" ||
+		!reflect.DeepEqual(resp.Data["bound_claims"], map[string]interface{}{"team": "engineering"}) ||
+		!reflect.DeepEqual(resp.Data["policies"], []string{"test"}) {
+		t.Fatalf("bad: role after update: %#v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role/",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "plugin-test" {
+		t.Fatalf("bad: role list: %#v", keys)
+	}
+
+	req.Operation = logical.DeleteOperation
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	resp, err = b.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("expected the role to be deleted; err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestRole_Invalid(t *testing.T) {
+	b, storage := getBackend(t)
+
+	for _, data := range []map[string]interface{}{
+		// No user claim
+		{"bound_subject": "subject"},
+		// No bound constraint
+		{"user_claim": "sub"},
+		{"user_claim": "sub", "bound_subject": "subject", "bound_cidr_list": "notacidr"},
+		{"user_claim": "sub", "bound_subject": "subject", "token_ttl": "10s", "token_max_ttl": "5s"},
+	} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "role/plugin-test",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected error for role %#v", data)
+		}
+	}
+}
+
+func testLoginCommon(t *testing.T, b *backend, storage logical.Storage, key *ecdsa.PrivateKey, keyID string) {
+	token := testSignJWT(t, key, keyID, testDefaultClaims(), testDefaultPrivateClaims())
+
+	resp, err := testLogin(b, storage, token)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	auth := resp.Auth
+	if auth.Alias.Name != "jeff" || auth.DisplayName != "jeff" {
+		t.Fatalf("bad: alias: %#v", auth.Alias)
+	}
+	if len(auth.GroupAliases) != 2 || auth.GroupAliases[0].Name != "bar" || auth.GroupAliases[1].Name != "foo" {
+		t.Fatalf("bad: group aliases: %#v", auth.GroupAliases)
+	}
+	if !reflect.DeepEqual(auth.Policies, []string{"test"}) {
+		t.Fatalf("bad: policies: %#v", auth.Policies)
+	}
+	if auth.Period != 3*time.Second || auth.TTL != 3*time.Second {
+		t.Fatalf("bad: period: %v, ttl: %v", auth.Period, auth.TTL)
+	}
+}
+
+func TestLogin_JWTValidationPubKeys(t *testing.T) {
+	b, storage := getBackend(t)
+	key, pubKey := testGenerateKey(t)
+	_, otherPubKey := testGenerateKey(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwt_validation_pubkeys": []string{otherPubKey, pubKey},
+			"bound_issuer":           "https://team-vault.auth0.com/",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	testWriteRole(t, b, storage, testDefaultRole())
+
+	testLoginCommon(t, b, storage, key, "")
+
+	// Tokens failing any of the constraints are rejected
+	otherKey, _ := testGenerateKey(t)
+	cases := map[string]func(*jwt.Claims, map[string]interface{}) *ecdsa.PrivateKey{
+		"unknown key": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			return otherKey
+		},
+		"wrong issuer": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			c.Issuer = "https://other.auth0.com/"
+			return key
+		},
+		"wrong subject": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			c.Subject = "someone-else"
+			return key
+		},
+		"wrong audience": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			c.Audience = jwt.Audience{"https://other.test"}
+			return key
+		},
+		"expired": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			c.Expiry = jwt.NewNumericDate(time.Now().Add(-5 * time.Minute))
+			return key
+		},
+		"missing user claim": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			delete(p, "https://vault/user")
+			return key
+		},
+		"invalid groups claim": func(c *jwt.Claims, p map[string]interface{}) *ecdsa.PrivateKey {
+			p["https://vault/groups"] = []int{1, 2}
+			return key
+		},
+	}
+	for name, modify := range cases {
+		claims := testDefaultClaims()
+		privateClaims := testDefaultPrivateClaims()
+		signingKey := modify(&claims, privateClaims)
+
+		resp, err := testLogin(b, storage, testSignJWT(t, signingKey, "", claims, privateClaims))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got %#v", name, resp)
+		}
+	}
+}
+
+func TestLogin_BoundClaims(t *testing.T) {
+	b, storage := getBackend(t)
+	key, pubKey := testGenerateKey(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwt_validation_pubkeys": pubKey,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	role := testDefaultRole()
+	delete(role, "groups_claim")
+	role["bound_claims"] = map[string]interface{}{
+		"team":                 "engineering",
+		"https://vault/groups": "bar",
+	}
+	testWriteRole(t, b, storage, role)
+
+	resp, err = testLogin(b, storage, testSignJWT(t, key, "", testDefaultClaims(), testDefaultPrivateClaims()))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	// Group memberships are left alone if the role has no groups claim
+	if resp.Auth.GroupAliases != nil {
+		t.Fatalf("bad: group aliases: %#v", resp.Auth.GroupAliases)
+	}
+
+	for _, privateClaims := range []map[string]interface{}{
+		{"https://vault/user": "jeff", "team": "sales", "https://vault/groups": []string{"bar"}},
+		{"https://vault/user": "jeff", "team": "engineering", "https://vault/groups": []string{"foo"}},
+		{"https://vault/user": "jeff", "https://vault/groups": []string{"bar"}},
+	} {
+		resp, err = testLogin(b, storage, testSignJWT(t, key, "", testDefaultClaims(), privateClaims))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for claims %#v", privateClaims)
+		}
+	}
+}
+
+func TestLogin_BoundCIDRList(t *testing.T) {
+	b, storage := getBackend(t)
+	key, pubKey := testGenerateKey(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwt_validation_pubkeys": pubKey,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	role := testDefaultRole()
+	role["bound_cidr_list"] = "10.0.0.0/8"
+	testWriteRole(t, b, storage, role)
+
+	resp, err = testLogin(b, storage, testSignJWT(t, key, "", testDefaultClaims(), testDefaultPrivateClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error logging in from outside the bound CIDR blocks")
+	}
+}
+
+// testKeyServer returns a TLS server publishing the public key at /certs, and
+// an OIDC discovery document at /.well-known/openid-configuration
+func testKeyServer(t *testing.T, key *ecdsa.PrivateKey, keyID string) (*httptest.Server, string) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{
+				"issuer": "%s",
+				"authorization_endpoint": "%s/auth",
+				"token_endpoint": "%s/token",
+				"jwks_uri": "%s/certs"
+			}`, server.URL, server.URL, server.URL, server.URL)
+		case "/certs":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{
+					{
+						Key:       &key.PublicKey,
+						KeyID:     keyID,
+						Algorithm: string(jose.ES256),
+						Use:       "sig",
+					},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	caPEM := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))
+	return server, caPEM
+}
+
+func TestLogin_JWKS(t *testing.T) {
+	b, storage := getBackend(t)
+	defer b.Cleanup()
+
+	key, _ := testGenerateKey(t)
+	server, caPEM := testKeyServer(t, key, "test-key")
+	defer server.Close()
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwks_url":     server.URL + "/certs",
+			"jwks_ca_pem":  caPEM,
+			"bound_issuer": "https://team-vault.auth0.com/",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	testWriteRole(t, b, storage, testDefaultRole())
+
+	testLoginCommon(t, b, storage, key, "test-key")
+
+	otherKey, _ := testGenerateKey(t)
+	resp, err = testLogin(b, storage, testSignJWT(t, otherKey, "test-key", testDefaultClaims(), testDefaultPrivateClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for a token signed with an unknown key")
+	}
+}
+
+func TestLogin_OIDCDiscovery(t *testing.T) {
+	b, storage := getBackend(t)
+	defer b.Cleanup()
+
+	key, _ := testGenerateKey(t)
+	server, caPEM := testKeyServer(t, key, "test-key")
+	defer server.Close()
+
+	// The server isn't trusted without its CA
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"oidc_discovery_url": server.URL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error checking an untrusted discovery URL")
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"oidc_discovery_url":    server.URL,
+			"oidc_discovery_ca_pem": caPEM,
+			"bound_issuer":          server.URL,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	testWriteRole(t, b, storage, testDefaultRole())
+
+	claims := testDefaultClaims()
+	claims.Issuer = server.URL
+	resp, err = testLogin(b, storage, testSignJWT(t, key, "test-key", claims, testDefaultPrivateClaims()))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.Alias.Name != "jeff" {
+		t.Fatalf("bad: alias: %#v", resp.Auth.Alias)
+	}
+}
+
+func TestLogin_Renew(t *testing.T) {
+	b, storage := getBackend(t)
+	key, pubKey := testGenerateKey(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwt_validation_pubkeys": pubKey,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	testWriteRole(t, b, storage, testDefaultRole())
+
+	resp, err = testLogin(b, storage, testSignJWT(t, key, "", testDefaultClaims(), testDefaultPrivateClaims()))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Path:      "login",
+		Storage:   storage,
+		Auth:      resp.Auth,
+	}
+	req.Auth.IssueTime = time.Now()
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.TTL != 3*time.Second {
+		t.Fatalf("bad: ttl: %v", resp.Auth.TTL)
+	}
+
+	// The token can't be renewed once its role is gone
+	_, err = b.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(req)
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error renewing a token of a deleted role")
+	}
+}
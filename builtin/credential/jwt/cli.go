@@ -0,0 +1,66 @@
+package jwtauth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+type CLIHandler struct{}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, error) {
+	mount, ok := m["mount"]
+	if !ok {
+		mount = "jwt"
+	}
+
+	role, ok := m["role"]
+	if !ok || role == "" {
+		return nil, fmt.Errorf("'role' must be specified")
+	}
+
+	token, ok := m["jwt"]
+	if !ok {
+		if token = os.Getenv("VAULT_AUTH_JWT"); token == "" {
+			return nil, fmt.Errorf("JWT should be provided either as 'value' for 'jwt' key,\nor via an env var VAULT_AUTH_JWT")
+		}
+	}
+
+	path := fmt.Sprintf("auth/%s/login", mount)
+	secret, err := c.Logical().Write(path, map[string]interface{}{
+		"role": role,
+		"jwt":  token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("empty response from credential provider")
+	}
+
+	return secret, nil
+}
+
+func (h *CLIHandler) Help() string {
+	help := `
+The JWT credential provider allows you to authenticate with a JWT, such as an
+OIDC ID token. To use it, specify the "role" and "jwt" parameters. The claims
+of the token must satisfy the constraints bound to the role.
+
+    Example: vault auth -method=jwt role=<role> jwt=<token>
+
+Key/Value Pairs:
+
+    mount=jwt         The mountpoint for the JWT credential provider.
+                      Defaults to "jwt"
+
+    role=<role>       The role to log in against.
+
+    jwt=<token>       The signed JWT. May also be given through the
+                      VAULT_AUTH_JWT env var.
+	`
+
+	return strings.TrimSpace(help)
+}
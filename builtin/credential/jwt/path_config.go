@@ -0,0 +1,320 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"oidc_discovery_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `OIDC Discovery URL, without any .well-known component (base path). Cannot be used with "jwks_url" or "jwt_validation_pubkeys".`,
+			},
+			"oidc_discovery_ca_pem": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The CA certificate or chain of certificates, in PEM format, to use to validate connections to the OIDC Discovery URL. If not set, system certificates are used.",
+			},
+			"jwks_url": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `JWKS URL to use to authenticate signatures. Cannot be used with "oidc_discovery_url" or "jwt_validation_pubkeys".`,
+			},
+			"jwks_ca_pem": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The CA certificate or chain of certificates, in PEM format, to use to validate connections to the JWKS URL. If not set, system certificates are used.",
+			},
+			"jwt_validation_pubkeys": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: `A list of PEM-encoded public keys to use to authenticate signatures locally. Cannot be used with "jwks_url" or "oidc_discovery_url".`,
+			},
+			"bound_issuer": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The value against which to match the 'iss' claim in a JWT. Optional.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    confHelpSyn,
+		HelpDescription: confHelpDesc,
+	}
+}
+
+// config returns the configuration of the backend, caching it until the
+// configuration changes
+func (b *backend) config(s logical.Storage) (*jwtConfig, error) {
+	b.l.RLock()
+	if b.cachedConfig != nil {
+		defer b.l.RUnlock()
+		return b.cachedConfig, nil
+	}
+	b.l.RUnlock()
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.cachedConfig != nil {
+		return b.cachedConfig, nil
+	}
+
+	entry, err := s.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config jwtConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	for _, v := range config.JWTValidationPubKeys {
+		key, err := certutil.ParsePublicKeyPEM([]byte(v))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing public key: %v", err)
+		}
+		config.parsedJWTPubKeys = append(config.parsedJWTPubKeys, key)
+	}
+
+	b.cachedConfig = &config
+	return b.cachedConfig, nil
+}
+
+func (b *backend) pathConfigRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"oidc_discovery_url":     config.OIDCDiscoveryURL,
+			"oidc_discovery_ca_pem":  config.OIDCDiscoveryCAPEM,
+			"jwks_url":               config.JWKSURL,
+			"jwks_ca_pem":            config.JWKSCAPEM,
+			"jwt_validation_pubkeys": config.JWTValidationPubKeys,
+			"bound_issuer":           config.BoundIssuer,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &jwtConfig{
+		OIDCDiscoveryURL:     d.Get("oidc_discovery_url").(string),
+		OIDCDiscoveryCAPEM:   d.Get("oidc_discovery_ca_pem").(string),
+		JWKSURL:              d.Get("jwks_url").(string),
+		JWKSCAPEM:            d.Get("jwks_ca_pem").(string),
+		JWTValidationPubKeys: d.Get("jwt_validation_pubkeys").([]string),
+		BoundIssuer:          d.Get("bound_issuer").(string),
+	}
+
+	// Exactly one of the methods of validating signatures must be configured
+	methods := 0
+	if config.OIDCDiscoveryURL != "" {
+		methods++
+	}
+	if config.JWKSURL != "" {
+		methods++
+	}
+	if len(config.JWTValidationPubKeys) != 0 {
+		methods++
+	}
+	if methods != 1 {
+		return logical.ErrorResponse("exactly one of 'oidc_discovery_url', 'jwks_url' or 'jwt_validation_pubkeys' must be set"), nil
+	}
+	if config.OIDCDiscoveryCAPEM != "" && config.OIDCDiscoveryURL == "" {
+		return logical.ErrorResponse("'oidc_discovery_ca_pem' can only be set along with 'oidc_discovery_url'"), nil
+	}
+	if config.JWKSCAPEM != "" && config.JWKSURL == "" {
+		return logical.ErrorResponse("'jwks_ca_pem' can only be set along with 'jwks_url'"), nil
+	}
+
+	for _, v := range config.JWTValidationPubKeys {
+		if _, err := certutil.ParsePublicKeyPEM([]byte(v)); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing public key: %v", err)), nil
+		}
+	}
+
+	// Check that the remote endpoints can be used before saving them
+	switch {
+	case config.OIDCDiscoveryURL != "":
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := newKeySet(ctx, config); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error checking discovery URL: %v", err)), nil
+		}
+
+	case config.JWKSURL != "":
+		if _, err := createCAContext(context.Background(), config.JWKSCAPEM); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing 'jwks_ca_pem': %v", err)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	b.reset()
+
+	return nil, nil
+}
+
+// getKeySet returns the key set used to verify the signatures of tokens,
+// creating it from the configuration if needed
+func (b *backend) getKeySet(config *jwtConfig) (oidc.KeySet, error) {
+	b.l.RLock()
+	if b.keySet != nil {
+		defer b.l.RUnlock()
+		return b.keySet, nil
+	}
+	b.l.RUnlock()
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.keySet != nil {
+		return b.keySet, nil
+	}
+
+	keySet, err := newKeySet(b.providerCtx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	b.keySet = keySet
+	return b.keySet, nil
+}
+
+// newKeySet creates the key set described by the configuration. The given
+// context is used for all the requests made to fetch the keys.
+func newKeySet(ctx context.Context, config *jwtConfig) (oidc.KeySet, error) {
+	switch {
+	case len(config.parsedJWTPubKeys) != 0:
+		return &staticKeySet{keys: config.parsedJWTPubKeys}, nil
+
+	case config.JWKSURL != "":
+		caCtx, err := createCAContext(ctx, config.JWKSCAPEM)
+		if err != nil {
+			return nil, err
+		}
+		return oidc.NewRemoteKeySet(caCtx, config.JWKSURL), nil
+
+	case config.OIDCDiscoveryURL != "":
+		caCtx, err := createCAContext(ctx, config.OIDCDiscoveryCAPEM)
+		if err != nil {
+			return nil, err
+		}
+		provider, err := oidc.NewProvider(caCtx, config.OIDCDiscoveryURL)
+		if err != nil {
+			return nil, fmt.Errorf("error creating provider with given values: %v", err)
+		}
+
+		var discovery struct {
+			JWKSURL string `json:"jwks_uri"`
+		}
+		if err := provider.Claims(&discovery); err != nil {
+			return nil, fmt.Errorf("error reading the discovery document: %v", err)
+		}
+		if discovery.JWKSURL == "" {
+			return nil, errors.New("discovery document does not contain a JWKS URL")
+		}
+		return oidc.NewRemoteKeySet(caCtx, discovery.JWKSURL), nil
+	}
+
+	return nil, errors.New("no method of verifying signatures is configured")
+}
+
+// createCAContext returns a context carrying the HTTP client to use for
+// requests made by the OIDC library. If a CA PEM is given, the client only
+// trusts the certificates in it.
+func createCAContext(ctx context.Context, caPEM string) (context.Context, error) {
+	if caPEM == "" {
+		return oidc.ClientContext(ctx, cleanhttp.DefaultClient()), nil
+	}
+
+	certPool := x509.NewCertPool()
+	if ok := certPool.AppendCertsFromPEM([]byte(caPEM)); !ok {
+		return nil, errors.New("could not parse the given CA certificates")
+	}
+
+	tr := cleanhttp.DefaultPooledTransport()
+	tr.TLSClientConfig = &tls.Config{
+		RootCAs: certPool,
+	}
+
+	return oidc.ClientContext(ctx, &http.Client{
+		Transport: tr,
+	}), nil
+}
+
+// staticKeySet is a key set that verifies signatures with a fixed list of
+// public keys
+type staticKeySet struct {
+	keys []crypto.PublicKey
+}
+
+func (s *staticKeySet) VerifySignature(ctx context.Context, token string) ([]byte, error) {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token: %v", err)
+	}
+
+	for _, key := range s.keys {
+		if payload, err := jws.Verify(key); err == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, errors.New("no known key successfully validated the token signature")
+}
+
+type jwtConfig struct {
+	OIDCDiscoveryURL     string   `json:"oidc_discovery_url"`
+	OIDCDiscoveryCAPEM   string   `json:"oidc_discovery_ca_pem"`
+	JWKSURL              string   `json:"jwks_url"`
+	JWKSCAPEM            string   `json:"jwks_ca_pem"`
+	JWTValidationPubKeys []string `json:"jwt_validation_pubkeys"`
+	BoundIssuer          string   `json:"bound_issuer"`
+
+	parsedJWTPubKeys []crypto.PublicKey
+}
+
+const (
+	confHelpSyn = `
+Configures the JWT authentication backend.
+`
+	confHelpDesc = `
+The JWT authentication backend validates JWTs (or OIDC ID tokens) using the
+configured credentials. If using OIDC Discovery, the URL must be provided,
+along with (optionally) the CA cert to use for the connection. If performing
+JWKS validation, the JWKS URL must be provided, along with (optionally) the
+CA cert to use for the connection. If performing JWT validation locally, a
+set of public keys must be provided.
+`
+)
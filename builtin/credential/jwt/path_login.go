@@ -0,0 +1,281 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func pathLogin(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "login$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The role to log in against.",
+			},
+			"jwt": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The signed JWT to validate.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLogin,
+		},
+		HelpSynopsis:    pathLoginHelpSyn,
+		HelpDescription: pathLoginHelpDesc,
+	}
+}
+
+// pathLogin returns the Auth object indicating the authentication and
+// authorization information if the JWT is valid for the role
+func (b *backend) pathLogin(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	token := data.Get("jwt").(string)
+	if token == "" {
+		return logical.ErrorResponse("missing jwt"), nil
+	}
+
+	roleName := data.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+
+	role, err := b.roleEntry(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q could not be found", roleName)), nil
+	}
+
+	if role.BoundCIDRList != "" {
+		// If 'bound_cidr_list' was set, verify the CIDR restrictions
+		if req.Connection == nil || req.Connection.RemoteAddr == "" {
+			return nil, fmt.Errorf("failed to get connection information")
+		}
+
+		belongs, err := cidrutil.IPBelongsToCIDRBlocksString(req.Connection.RemoteAddr, role.BoundCIDRList, ",")
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify the CIDR restrictions set on the role: %v", err)
+		}
+		if !belongs {
+			return logical.ErrorResponse(fmt.Sprintf("source address %q unauthorized through CIDR restrictions on the role", req.Connection.RemoteAddr)), nil
+		}
+	}
+
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse("could not load configuration"), nil
+	}
+
+	allClaims, err := b.verifyToken(config, role, token)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	userName, ok := allClaims[role.UserClaim].(string)
+	if !ok || userName == "" {
+		return logical.ErrorResponse(fmt.Sprintf("claim %q not found in token", role.UserClaim)), nil
+	}
+
+	var groupAliases []*logical.Alias
+	if role.GroupsClaim != "" {
+		groups, err := claimStrings(allClaims, role.GroupsClaim)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		// An empty list removes the user from the groups it previously
+		// belonged to through this backend
+		groupAliases = make([]*logical.Alias, 0, len(groups))
+		for _, group := range groups {
+			groupAliases = append(groupAliases, &logical.Alias{
+				Name: group,
+			})
+		}
+	}
+
+	auth := &logical.Auth{
+		NumUses:     role.TokenNumUses,
+		Period:      role.Period,
+		Policies:    role.Policies,
+		DisplayName: userName,
+		InternalData: map[string]interface{}{
+			"role": roleName,
+		},
+		Metadata: map[string]string{
+			"role": roleName,
+		},
+		LeaseOptions: logical.LeaseOptions{
+			Renewable: true,
+		},
+		Alias: &logical.Alias{
+			Name: userName,
+		},
+		GroupAliases: groupAliases,
+	}
+
+	// If 'Period' is set, use the value of 'Period' as the TTL.
+	// Otherwise, set the normal TokenTTL.
+	if role.Period > time.Duration(0) {
+		auth.TTL = role.Period
+	} else {
+		auth.TTL = role.TokenTTL
+	}
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+// verifyToken checks the signature of the token and that its claims satisfy
+// the configuration and the bound constraints of the role. It returns all the
+// claims of the token.
+func (b *backend) verifyToken(config *jwtConfig, role *roleStorageEntry, token string) (map[string]interface{}, error) {
+	keySet, err := b.getKeySet(config)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching the keys to verify the token: %v", err)
+	}
+
+	payload, err := keySet.VerifySignature(context.Background(), token)
+	if err != nil {
+		return nil, fmt.Errorf("error validating signature: %v", err)
+	}
+
+	var claims jwt.Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("error parsing claims: %v", err)
+	}
+	allClaims := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &allClaims); err != nil {
+		return nil, fmt.Errorf("error parsing claims: %v", err)
+	}
+
+	expected := jwt.Expected{
+		Issuer:  config.BoundIssuer,
+		Subject: role.BoundSubject,
+		Time:    time.Now(),
+	}
+	if err := claims.Validate(expected); err != nil {
+		return nil, fmt.Errorf("error validating claims: %v", err)
+	}
+
+	if len(role.BoundAudiences) != 0 {
+		found := false
+		for _, aud := range role.BoundAudiences {
+			if claims.Audience.Contains(aud) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("aud claim does not match any bound audience")
+		}
+	}
+
+	for claim, expectedValue := range role.BoundClaims {
+		value, ok := allClaims[claim]
+		if !ok {
+			return nil, fmt.Errorf("claim %q is missing", claim)
+		}
+		if !claimMatches(value, expectedValue) {
+			return nil, fmt.Errorf("claim %q does not match the bound value", claim)
+		}
+	}
+
+	return allClaims, nil
+}
+
+// claimMatches returns whether the value of a claim equals the expected
+// value or, if the claim is a list, contains it. Values are compared in their
+// string form since claims parsed from JSON don't keep the types given when
+// the role was written.
+func claimMatches(value, expected interface{}) bool {
+	expectedString := fmt.Sprintf("%v", expected)
+
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if fmt.Sprintf("%v", v) == expectedString {
+				return true
+			}
+		}
+		return false
+	}
+
+	return fmt.Sprintf("%v", value) == expectedString
+}
+
+// claimStrings returns the values of a claim holding either a string or a
+// list of strings. A missing claim has no values.
+func claimStrings(allClaims map[string]interface{}, claim string) ([]string, error) {
+	switch v := allClaims[claim].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %q contains a value that is not a string", claim)
+			}
+			values = append(values, s)
+		}
+		return strutil.RemoveDuplicates(values, false), nil
+	default:
+		return nil, fmt.Errorf("claim %q is not a string or a list of strings", claim)
+	}
+}
+
+// pathLoginRenew is invoked when the token issued by this backend is
+// attempting a renewal
+func (b *backend) pathLoginRenew(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName, ok := req.Auth.InternalData["role"].(string)
+	if !ok || roleName == "" {
+		return nil, fmt.Errorf("failed to fetch role during renewal")
+	}
+
+	// Ensure that the Role still exists.
+	role, err := b.roleEntry(req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate role %s during renewal: %v", roleName, err)
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %s does not exist during renewal", roleName)
+	}
+
+	// If 'Period' is set on the Role, the token should never expire.
+	// Replenish the TTL with 'Period's value.
+	if role.Period > time.Duration(0) {
+		// If 'Period' was updated after the token was issued,
+		// token will bear the updated 'Period' value as its TTL.
+		req.Auth.TTL = role.Period
+		return &logical.Response{Auth: req.Auth}, nil
+	}
+
+	return framework.LeaseExtend(role.TokenTTL, role.TokenMaxTTL, b.System())(req, data)
+}
+
+const (
+	pathLoginHelpSyn = `
+Authenticates to Vault using a JWT (or OIDC) token.
+`
+	pathLoginHelpDesc = `
+Authenticates JWTs against the configured public keys, JWKS URL or OIDC
+provider. The 'role' parameter names the role whose bound constraints the
+claims of the token must satisfy, and whose policies and token settings
+apply to the issued token.
+`
+)
@@ -0,0 +1,415 @@
+package jwtauth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/cidrutil"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// roleStorageEntry stores all the options that are set on a role
+type roleStorageEntry struct {
+	// Policies that are to be required by the token to access this role
+	Policies []string `json:"policies" structs:"policies" mapstructure:"policies"`
+
+	// TokenNumUses defines the number of allowed uses of the token issued
+	TokenNumUses int `json:"token_num_uses" structs:"token_num_uses" mapstructure:"token_num_uses"`
+
+	// Duration before which an issued token must be renewed
+	TokenTTL time.Duration `json:"token_ttl" structs:"token_ttl" mapstructure:"token_ttl"`
+
+	// Duration after which an issued token should not be allowed to be renewed
+	TokenMaxTTL time.Duration `json:"token_max_ttl" structs:"token_max_ttl" mapstructure:"token_max_ttl"`
+
+	// Period, if set, indicates that the token generated using this role
+	// should never expire. The token should be renewed within the duration
+	// specified by this value. The renewal duration will be fixed if the
+	// value is not modified on the role. If the `Period` in the role is modified,
+	// a token will pick up the new value during its next renewal.
+	Period time.Duration `json:"period" structs:"period" mapstructure:"period"`
+
+	// A constraint, if set, specifies the CIDR blocks from which logins should be allowed
+	BoundCIDRList string `json:"bound_cidr_list" structs:"bound_cidr_list" mapstructure:"bound_cidr_list"`
+
+	// BoundAudiences, if set, requires the 'aud' claim of the token to
+	// contain at least one of the given values
+	BoundAudiences []string `json:"bound_audiences" structs:"bound_audiences" mapstructure:"bound_audiences"`
+
+	// BoundSubject, if set, requires the 'sub' claim of the token to match
+	BoundSubject string `json:"bound_subject" structs:"bound_subject" mapstructure:"bound_subject"`
+
+	// BoundClaims are claims that the token must carry with the given values
+	BoundClaims map[string]interface{} `json:"bound_claims" structs:"bound_claims" mapstructure:"bound_claims"`
+
+	// UserClaim is the claim used as the name of the identity alias
+	UserClaim string `json:"user_claim" structs:"user_claim" mapstructure:"user_claim"`
+
+	// GroupsClaim, if set, is the claim holding the names of the groups the
+	// user belongs to, used as the names of identity group aliases
+	GroupsClaim string `json:"groups_claim" structs:"groups_claim" mapstructure:"groups_claim"`
+}
+
+// pathRoles creates all the paths that are used to register and manage a role.
+//
+// Paths returned:
+// role/ - For listing all the registered roles
+// role/<role_name> - For registering a role
+func pathRoles(b *backend) []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "role/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.pathRoleList,
+			},
+			HelpSynopsis:    strings.TrimSpace(roleHelp["role-list"][0]),
+			HelpDescription: strings.TrimSpace(roleHelp["role-list"][1]),
+		},
+		&framework.Path{
+			Pattern: "role/" + framework.GenericNameRegex("role_name"),
+			Fields: map[string]*framework.FieldSchema{
+				"role_name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+				"policies": &framework.FieldSchema{
+					Type:        framework.TypeCommaStringSlice,
+					Default:     "default",
+					Description: "Comma separated list of policies on the role.",
+				},
+				"token_num_uses": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: `Number of times issued tokens can be used`,
+				},
+				"token_ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Duration in seconds after which the issued token should expire. Defaults
+to 0, in which case the value will fall back to the system/mount defaults.`,
+				},
+				"token_max_ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Duration in seconds after which the issued token should not be allowed to
+be renewed. Defaults to 0, in which case the value will fall back to the system/mount defaults.`,
+				},
+				"period": &framework.FieldSchema{
+					Type:    framework.TypeDurationSecond,
+					Default: 0,
+					Description: `If set, indicates that the token generated using this role
+should never expire. The token should be renewed within the
+duration specified by this value. At each renewal, the token's
+TTL will be set to the value of this parameter.`,
+				},
+				"bound_cidr_list": &framework.FieldSchema{
+					Type: framework.TypeString,
+					Description: `Comma separated list of CIDR blocks, if set, specifies blocks of IP
+addresses which can perform the login operation`,
+				},
+				"bound_audiences": &framework.FieldSchema{
+					Type:        framework.TypeCommaStringSlice,
+					Description: `Comma-separated list of 'aud' claims that are valid for login; any match is sufficient`,
+				},
+				"bound_subject": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: `The 'sub' claim that is valid for login. Optional.`,
+				},
+				"bound_claims": &framework.FieldSchema{
+					Type:        framework.TypeMap,
+					Description: `Map of claims and values which must be present in the token for login. If the claim in the token is a list, it must contain the value.`,
+				},
+				"user_claim": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: `The claim to use for the Identity entity alias name`,
+				},
+				"groups_claim": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: `The claim to use for the Identity group alias names`,
+				},
+			},
+			ExistenceCheck: b.pathRoleExistenceCheck,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: b.pathRoleCreateUpdate,
+				logical.UpdateOperation: b.pathRoleCreateUpdate,
+				logical.ReadOperation:   b.pathRoleRead,
+				logical.DeleteOperation: b.pathRoleDelete,
+			},
+			HelpSynopsis:    strings.TrimSpace(roleHelp["role"][0]),
+			HelpDescription: strings.TrimSpace(roleHelp["role"][1]),
+		},
+	}
+}
+
+// pathRoleExistenceCheck returns whether the role with the given name exists or not.
+func (b *backend) pathRoleExistenceCheck(req *logical.Request, data *framework.FieldData) (bool, error) {
+	role, err := b.roleEntry(req.Storage, data.Get("role_name").(string))
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+// pathRoleList is used to list all the Roles registered with the backend.
+func (b *backend) pathRoleList(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	lock := b.roleLock("")
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	roles, err := req.Storage.List(rolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(roles), nil
+}
+
+// roleLock returns the lock guarding the role with the given name
+func (b *backend) roleLock(roleName string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.roleLocks, strings.ToLower(roleName))
+}
+
+// roleEntry grabs the read lock and fetches the options of a role from the storage
+func (b *backend) roleEntry(s logical.Storage, roleName string) (*roleStorageEntry, error) {
+	if roleName == "" {
+		return nil, fmt.Errorf("missing role_name")
+	}
+
+	var role roleStorageEntry
+
+	lock := b.roleLock(roleName)
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if entry, err := s.Get(rolePrefix + strings.ToLower(roleName)); err != nil {
+		return nil, err
+	} else if entry == nil {
+		return nil, nil
+	} else if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// setRoleEntry grabs a write lock and stores the options on a role into the
+// storage
+func (b *backend) setRoleEntry(s logical.Storage, roleName string, role *roleStorageEntry) error {
+	if roleName == "" {
+		return fmt.Errorf("missing role name")
+	}
+
+	if role == nil {
+		return fmt.Errorf("nil role")
+	}
+
+	lock := b.roleLock(roleName)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry, err := logical.StorageEntryJSON(rolePrefix+strings.ToLower(roleName), role)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("failed to create storage entry for role %s", roleName)
+	}
+
+	return s.Put(entry)
+}
+
+// pathRoleCreateUpdate registers a new role with the backend or updates the options
+// of an existing role
+func (b *backend) pathRoleCreateUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role_name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role_name"), nil
+	}
+
+	// Check if the role already exists
+	role, err := b.roleEntry(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new entry object if this is a CreateOperation
+	if role == nil && req.Operation == logical.CreateOperation {
+		role = &roleStorageEntry{}
+	} else if role == nil {
+		return nil, fmt.Errorf("role entry not found during update operation")
+	}
+
+	if policiesRaw, ok := data.GetOk("policies"); ok {
+		role.Policies = policyutil.ParsePolicies(policiesRaw)
+	} else if req.Operation == logical.CreateOperation {
+		role.Policies = policyutil.ParsePolicies(data.Get("policies"))
+	}
+
+	periodRaw, ok := data.GetOk("period")
+	if ok {
+		role.Period = time.Second * time.Duration(periodRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.Period = time.Second * time.Duration(data.Get("period").(int))
+	}
+	if role.Period > b.System().MaxLeaseTTL() {
+		return logical.ErrorResponse(fmt.Sprintf("'period' of '%s' is greater than the backend's maximum lease TTL of '%s'", role.Period.String(), b.System().MaxLeaseTTL().String())), nil
+	}
+
+	if tokenNumUsesRaw, ok := data.GetOk("token_num_uses"); ok {
+		role.TokenNumUses = tokenNumUsesRaw.(int)
+	} else if req.Operation == logical.CreateOperation {
+		role.TokenNumUses = data.Get("token_num_uses").(int)
+	}
+	if role.TokenNumUses < 0 {
+		return logical.ErrorResponse("token_num_uses cannot be negative"), nil
+	}
+
+	if tokenTTLRaw, ok := data.GetOk("token_ttl"); ok {
+		role.TokenTTL = time.Second * time.Duration(tokenTTLRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.TokenTTL = time.Second * time.Duration(data.Get("token_ttl").(int))
+	}
+
+	if tokenMaxTTLRaw, ok := data.GetOk("token_max_ttl"); ok {
+		role.TokenMaxTTL = time.Second * time.Duration(tokenMaxTTLRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.TokenMaxTTL = time.Second * time.Duration(data.Get("token_max_ttl").(int))
+	}
+
+	// Check that the TokenTTL value provided is less than the TokenMaxTTL.
+	// Sanitizing the TTL and MaxTTL is not required now and can be performed
+	// at credential issue time.
+	if role.TokenMaxTTL > time.Duration(0) && role.TokenTTL > role.TokenMaxTTL {
+		return logical.ErrorResponse("token_ttl should not be greater than token_max_ttl"), nil
+	}
+
+	if boundCIDRListRaw, ok := data.GetOk("bound_cidr_list"); ok {
+		role.BoundCIDRList = strings.TrimSpace(boundCIDRListRaw.(string))
+	}
+	if role.BoundCIDRList != "" {
+		valid, err := cidrutil.ValidateCIDRListString(role.BoundCIDRList, ",")
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate CIDR blocks: %v", err)
+		}
+		if !valid {
+			return logical.ErrorResponse("invalid CIDR blocks"), nil
+		}
+	}
+
+	if boundAudiencesRaw, ok := data.GetOk("bound_audiences"); ok {
+		role.BoundAudiences = boundAudiencesRaw.([]string)
+	}
+
+	if boundSubjectRaw, ok := data.GetOk("bound_subject"); ok {
+		role.BoundSubject = boundSubjectRaw.(string)
+	}
+
+	if boundClaimsRaw, ok := data.GetOk("bound_claims"); ok {
+		role.BoundClaims = boundClaimsRaw.(map[string]interface{})
+	}
+
+	if userClaimRaw, ok := data.GetOk("user_claim"); ok {
+		role.UserClaim = userClaimRaw.(string)
+	}
+	if role.UserClaim == "" {
+		return logical.ErrorResponse("a user claim must be defined on the role"), nil
+	}
+
+	if groupsClaimRaw, ok := data.GetOk("groups_claim"); ok {
+		role.GroupsClaim = groupsClaimRaw.(string)
+	}
+
+	// A token without any binding could be used by anyone trusted by the
+	// configured issuer; require at least the audience or subject to be bound
+	if len(role.BoundAudiences) == 0 && role.BoundSubject == "" && len(role.BoundClaims) == 0 {
+		return logical.ErrorResponse("must have at least one bound constraint when creating/updating a role"), nil
+	}
+
+	var resp *logical.Response
+	if role.TokenMaxTTL > b.System().MaxLeaseTTL() {
+		resp = &logical.Response{}
+		resp.AddWarning("token_max_ttl is greater than the backend mount's maximum TTL value; issued tokens' max TTL value will be truncated")
+	}
+
+	// Store the entry.
+	return resp, b.setRoleEntry(req.Storage, roleName, role)
+}
+
+// pathRoleRead grabs a read lock and reads the options set on the role from the storage
+func (b *backend) pathRoleRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role_name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role_name"), nil
+	}
+
+	role, err := b.roleEntry(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	// Convert the 'time.Duration' values to second.
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"policies":        role.Policies,
+			"token_num_uses":  role.TokenNumUses,
+			"token_ttl":       int64(role.TokenTTL.Seconds()),
+			"token_max_ttl":   int64(role.TokenMaxTTL.Seconds()),
+			"period":          int64(role.Period.Seconds()),
+			"bound_cidr_list": role.BoundCIDRList,
+			"bound_audiences": role.BoundAudiences,
+			"bound_subject":   role.BoundSubject,
+			"bound_claims":    role.BoundClaims,
+			"user_claim":      role.UserClaim,
+			"groups_claim":    role.GroupsClaim,
+		},
+	}, nil
+}
+
+// pathRoleDelete removes the role from the storage
+func (b *backend) pathRoleDelete(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role_name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role_name"), nil
+	}
+
+	lock := b.roleLock(roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := req.Storage.Delete(rolePrefix + strings.ToLower(roleName)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+var roleHelp = map[string][2]string{
+	"role-list": {
+		"Lists all the roles registered with the backend.",
+		"The list will contain the names of the roles.",
+	},
+	"role": {
+		"Register a role with the backend.",
+		`A role can represent a service, a machine or anything that can be IDed.
+The set of policies on the role defines access to the role, meaning, any
+Vault token with a policy set that is a superset of the policies on the
+role registered here will have access to the role.
+
+The claims of the JWT presented at login must satisfy all the bound
+constraints of the role: the 'aud' claim must contain one of the bound
+audiences, the 'sub' claim must match the bound subject, and each of the
+bound claims must be present with the given value. At least one of these
+constraints must be set on the role.
+
+The 'user_claim' parameter selects the claim whose value names the
+Identity entity alias of the user. If 'groups_claim' is set, each of the
+values of that claim names an Identity group alias; the user is made a
+member of the external groups having these aliases.`,
+	},
+}
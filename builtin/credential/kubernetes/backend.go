@@ -0,0 +1,115 @@
+package kubeauth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	configPath string = "config"
+	rolePrefix string = "role/"
+)
+
+type backend struct {
+	*framework.Backend
+
+	// reviewFactory is used to configure the strategy for doing a token
+	// review. Defaults to calling the TokenReview API of the configured
+	// Kubernetes API server; tests replace it to review tokens locally.
+	reviewFactory tokenReviewFactory
+
+	// l guards the configuration and the role entries
+	l sync.RWMutex
+}
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend()
+	if err := b.Setup(conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func Backend() *backend {
+	b := &backend{
+		reviewFactory: tokenReviewAPIFactory,
+	}
+
+	b.Backend = &framework.Backend{
+		AuthRenew:   b.pathLoginRenew,
+		BackendType: logical.TypeCredential,
+		Help:        backendHelp,
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"login",
+			},
+		},
+		Paths: framework.PathAppend(
+			[]*framework.Path{
+				pathConfig(b),
+				pathLogin(b),
+			},
+			pathsRole(b),
+		),
+	}
+
+	return b
+}
+
+// config reads the configuration of the backend from storage and parses the
+// public keys in it
+func (b *backend) config(s logical.Storage) (*kubeConfig, error) {
+	entry, err := s.Get(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config kubeConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	config.PublicKeys = make([]interface{}, len(config.PEMKeys))
+	for i, pemKey := range config.PEMKeys {
+		config.PublicKeys[i], err = certutil.ParsePublicKeyPEM([]byte(pemKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// role reads the role with the given name from storage
+func (b *backend) role(s logical.Storage, name string) (*roleStorageEntry, error) {
+	entry, err := s.Get(fmt.Sprintf("%s%s", rolePrefix, strings.ToLower(name)))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role roleStorageEntry
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+const backendHelp = `
+The Kubernetes backend allows authentication for Kubernetes service accounts.
+
+Service accounts log in with their JWT, which is verified through the
+TokenReview API of the configured Kubernetes API server. Roles bind service
+account names and namespaces to the policies of the issued tokens.
+`
@@ -0,0 +1,337 @@
+package kubeauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	testName      = "vault-auth"
+	testNamespace = "default"
+	testUID       = "d77f89bc-9055-11e7-a068-0800276d99bf"
+)
+
+func getBackend(t *testing.T) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend()
+	if err := b.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	b.reviewFactory = mockTokenReviewFactory(testName, testNamespace, testUID)
+	return b, config.StorageView
+}
+
+// mockTokenReview is a token reviewer which reports every token as belonging
+// to a fixed service account
+type mockTokenReview struct {
+	saName      string
+	saNamespace string
+	saUID       string
+}
+
+func mockTokenReviewFactory(name, namespace, uid string) tokenReviewFactory {
+	return func(config *kubeConfig) tokenReviewer {
+		return &mockTokenReview{
+			saName:      name,
+			saNamespace: namespace,
+			saUID:       uid,
+		}
+	}
+}
+
+func (t *mockTokenReview) Review(jwt string) (*tokenReviewResult, error) {
+	return &tokenReviewResult{
+		Name:      t.saName,
+		Namespace: t.saNamespace,
+		UID:       t.saUID,
+	}, nil
+}
+
+func testGenerateKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}))
+}
+
+// testServiceAccountJWT returns a JWT for the service account with the given
+// name, namespace and UID, signed with the given key
+func testServiceAccountJWT(t *testing.T, key *ecdsa.PrivateKey, name, namespace, uid string) string {
+	claims := jws.Claims{
+		"iss":                                    expectedJWTIssuer,
+		"sub":                                    "system:serviceaccount:" + namespace + ":" + name,
+		"kubernetes.io/serviceaccount/namespace": namespace,
+		"kubernetes.io/serviceaccount/secret.name":          name + "-token-fkhvx",
+		"kubernetes.io/serviceaccount/service-account.name": name,
+		"kubernetes.io/serviceaccount/service-account.uid":  uid,
+	}
+	token, err := jws.NewJWT(claims, crypto.SigningMethodES256).Serialize(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(token)
+}
+
+func testWriteConfig(t *testing.T, b *backend, storage logical.Storage, data map[string]interface{}) {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      configPath,
+		Storage:   storage,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+}
+
+func testWriteRole(t *testing.T, b *backend, storage logical.Storage, data map[string]interface{}) {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestConfig_ReadWrite(t *testing.T) {
+	b, storage := getBackend(t)
+	_, pubKey := testGenerateKey(t)
+
+	// Reading a missing config returns nothing
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configPath,
+		Storage:   storage,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	testWriteConfig(t, b, storage, map[string]interface{}{
+		"kubernetes_host": "https://192.168.99.100:8443",
+		"pem_keys":        pubKey,
+	})
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      configPath,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	expected := map[string]interface{}{
+		"kubernetes_host":    "https://192.168.99.100:8443",
+		"kubernetes_ca_cert": "",
+		"token_reviewer_jwt": "",
+		"pem_keys":           []string{strings.TrimSpace(pubKey)},
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: expected:%#v actual:%#v", expected, resp.Data)
+	}
+
+	config, err := b.config(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.PublicKeys) != 1 {
+		t.Fatalf("bad: %#v", config.PublicKeys)
+	}
+	if _, ok := config.PublicKeys[0].(*ecdsa.PublicKey); !ok {
+		t.Fatalf("bad: %#v", config.PublicKeys[0])
+	}
+}
+
+func TestConfig_Invalid(t *testing.T) {
+	b, storage := getBackend(t)
+
+	cases := map[string]map[string]interface{}{
+		"missing host": {
+			"kubernetes_ca_cert": "ca",
+		},
+		"missing CA and keys": {
+			"kubernetes_host": "https://192.168.99.100:8443",
+		},
+		"invalid public key": {
+			"kubernetes_host": "https://192.168.99.100:8443",
+			"pem_keys":        "not a key",
+		},
+		"invalid token reviewer JWT": {
+			"kubernetes_host":    "https://192.168.99.100:8443",
+			"kubernetes_ca_cert": "ca",
+			"token_reviewer_jwt": "not a jwt",
+		},
+	}
+
+	for name, data := range cases {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      configPath,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected an error response, got %#v", name, resp)
+		}
+	}
+}
+
+func TestRole_CRUD(t *testing.T) {
+	b, storage := getBackend(t)
+
+	testWriteRole(t, b, storage, map[string]interface{}{
+		"bound_service_account_names":      "vault-auth,other",
+		"bound_service_account_namespaces": "default",
+		"policies":                         "test",
+		"period":                           "3s",
+		"ttl":                              "1s",
+		"num_uses":                         12,
+		"max_ttl":                          "5s",
+	})
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	expected := map[string]interface{}{
+		"bound_service_account_names":      []string{"vault-auth", "other"},
+		"bound_service_account_namespaces": []string{"default"},
+		"policies":                         []string{"test"},
+		"period":                           int64(3),
+		"ttl":                              int64(1),
+		"num_uses":                         12,
+		"max_ttl":                          int64(5),
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("bad: expected:%#v actual:%#v", expected, resp.Data)
+	}
+
+	// Update only some of the fields
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"bound_service_account_namespaces": "*",
+			"ttl":                              "2s",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	role, err := b.role(storage, "plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(role.ServiceAccountNamespaces, []string{"*"}) || role.TTL.Seconds() != 2 || role.NumUses != 12 {
+		t.Fatalf("bad: %#v", role)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role/",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"plugin-test"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "role/plugin-test",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	role, err = b.role(storage, "plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != nil {
+		t.Fatalf("expected role to be deleted, got %#v", role)
+	}
+}
+
+func TestRole_Invalid(t *testing.T) {
+	b, storage := getBackend(t)
+
+	cases := map[string]map[string]interface{}{
+		"missing names": {
+			"bound_service_account_namespaces": "default",
+		},
+		"missing namespaces": {
+			"bound_service_account_names": "vault-auth",
+		},
+		"names mixing *": {
+			"bound_service_account_names":      "*,vault-auth",
+			"bound_service_account_namespaces": "default",
+		},
+		"namespaces mixing *": {
+			"bound_service_account_names":      "vault-auth",
+			"bound_service_account_namespaces": "*,default",
+		},
+		"both *": {
+			"bound_service_account_names":      "*",
+			"bound_service_account_namespaces": "*",
+		},
+		"ttl greater than max_ttl": {
+			"bound_service_account_names":      "vault-auth",
+			"bound_service_account_namespaces": "default",
+			"ttl":                              "10s",
+			"max_ttl":                          "5s",
+		},
+	}
+
+	for name, data := range cases {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "role/plugin-test",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected an error response, got %#v", name, resp)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package kubeauth
+
+import (
+	"fmt"
+
+	"github.com/SermoDigital/jose/jws"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: configPath + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"kubernetes_host": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Host must be a host string, a host:port pair, or a URL to the base of the Kubernetes API server.",
+			},
+			"kubernetes_ca_cert": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "PEM encoded CA cert for use by the TLS client used to talk with the Kubernetes API.",
+			},
+			"token_reviewer_jwt": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `A service account JWT used to access the TokenReview API to
+validate other JWTs during login. If not set the JWT used for
+login will be used to access the API.`,
+			},
+			"pem_keys": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Optional list of PEM-formatted public keys or certificates used
+to verify the signatures of Kubernetes service account JWTs. If a
+certificate is given, its public key will be extracted. Not every
+installation of Kubernetes exposes these keys.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigRead,
+			logical.UpdateOperation: b.pathConfigWrite,
+		},
+
+		HelpSynopsis:    confHelpSyn,
+		HelpDescription: confHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"kubernetes_host":    config.Host,
+			"kubernetes_ca_cert": config.CACert,
+			"token_reviewer_jwt": config.TokenReviewerJWT,
+			"pem_keys":           config.PEMKeys,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigWrite(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &kubeConfig{
+		Host:             d.Get("kubernetes_host").(string),
+		CACert:           d.Get("kubernetes_ca_cert").(string),
+		TokenReviewerJWT: d.Get("token_reviewer_jwt").(string),
+		PEMKeys:          d.Get("pem_keys").([]string),
+	}
+
+	if config.Host == "" {
+		return logical.ErrorResponse("no host provided"), nil
+	}
+	if len(config.PEMKeys) == 0 && config.CACert == "" {
+		return logical.ErrorResponse("one of 'pem_keys' or 'kubernetes_ca_cert' must be set"), nil
+	}
+
+	if config.TokenReviewerJWT != "" {
+		if _, err := jws.ParseJWT([]byte(config.TokenReviewerJWT)); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing 'token_reviewer_jwt': %v", err)), nil
+		}
+	}
+
+	for _, v := range config.PEMKeys {
+		if _, err := certutil.ParsePublicKeyPEM([]byte(v)); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error parsing public key: %v", err)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(configPath, config)
+	if err != nil {
+		return nil, err
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// kubeConfig holds the information needed to verify service account JWTs and
+// to access the TokenReview API
+type kubeConfig struct {
+	// PEMKeys is the list of PEM-encoded public keys used to verify the
+	// signatures of JWTs
+	PEMKeys []string `json:"pem_keys"`
+
+	// Host is the URL of the Kubernetes API server
+	Host string `json:"host"`
+
+	// CACert is the PEM-encoded CA certificate used to connect to the
+	// Kubernetes API server
+	CACert string `json:"ca_cert"`
+
+	// TokenReviewerJWT is the bearer token used for TokenReview API calls
+	TokenReviewerJWT string `json:"token_reviewer_jwt"`
+
+	// PublicKeys holds the parsed PEMKeys
+	PublicKeys []interface{} `json:"-"`
+}
+
+const (
+	confHelpSyn = `
+Configures the JWT public keys and Kubernetes API information.
+`
+	confHelpDesc = `
+The Kubernetes authentication backend validates service account JWTs and
+verifies their existence with the Kubernetes TokenReview API. This endpoint
+configures the public keys used to validate the JWT signatures and the
+information needed to access the Kubernetes API.
+`
+)
@@ -0,0 +1,327 @@
+package kubeauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// expectedJWTIssuer is the issuer of service account JWTs
+	expectedJWTIssuer string = "kubernetes/serviceaccount"
+
+	// uidJWTClaimKey is the claim holding the UID of the service account
+	uidJWTClaimKey string = "kubernetes.io/serviceaccount/service-account.uid"
+)
+
+// errMismatchedSigningMethod is returned when the signing method of a JWT
+// doesn't match the type of a public key
+var errMismatchedSigningMethod = errors.New("invalid signing method")
+
+func pathLogin(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "login$",
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role against which the login is being attempted. This field is required.",
+			},
+			"jwt": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "A signed JWT for authenticating a service account. This field is required.",
+			},
+		},
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation:         b.pathLogin,
+			logical.AliasLookaheadOperation: b.pathLoginAliasLookahead,
+		},
+		HelpSynopsis:    pathLoginHelpSyn,
+		HelpDescription: pathLoginHelpDesc,
+	}
+}
+
+// pathLogin returns the Auth object indicating the authentication and
+// authorization information if the service account JWT is valid for the role
+func (b *backend) pathLogin(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("role").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role"), nil
+	}
+
+	jwtStr := data.Get("jwt").(string)
+	if jwtStr == "" {
+		return logical.ErrorResponse("missing jwt"), nil
+	}
+
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	role, err := b.role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid role name %q", roleName)), nil
+	}
+
+	config, err := b.config(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.New("could not load backend configuration")
+	}
+
+	sa, err := parseAndValidateJWT(jwtStr, role, config)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// Check with the TokenReview API that the token is still valid
+	if err := sa.lookup(jwtStr, b.reviewFactory(config)); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	auth := &logical.Auth{
+		NumUses:     role.NumUses,
+		Period:      role.Period,
+		Policies:    role.Policies,
+		DisplayName: sa.Name,
+		InternalData: map[string]interface{}{
+			"role": roleName,
+		},
+		Metadata: map[string]string{
+			"service_account_uid":         sa.UID,
+			"service_account_name":        sa.Name,
+			"service_account_namespace":   sa.Namespace,
+			"service_account_secret_name": sa.SecretName,
+			"role":                        roleName,
+		},
+		LeaseOptions: logical.LeaseOptions{
+			Renewable: true,
+		},
+		Alias: &logical.Alias{
+			Name: sa.UID,
+		},
+	}
+
+	// If 'Period' is set, use the value of 'Period' as the TTL.
+	// Otherwise, set the normal TTL.
+	if role.Period > time.Duration(0) {
+		auth.TTL = role.Period
+	} else {
+		auth.TTL = role.TTL
+	}
+
+	return &logical.Response{
+		Auth: auth,
+	}, nil
+}
+
+// pathLoginAliasLookahead returns the alias of the service account the JWT
+// was issued for, without validating it
+func (b *backend) pathLoginAliasLookahead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	jwtStr := data.Get("jwt").(string)
+	if jwtStr == "" {
+		return logical.ErrorResponse("missing jwt"), nil
+	}
+
+	parsedJWT, err := jws.ParseJWT([]byte(jwtStr))
+	if err != nil {
+		return nil, err
+	}
+
+	uid, ok := parsedJWT.Claims().Get(uidJWTClaimKey).(string)
+	if !ok || uid == "" {
+		return nil, errors.New("could not parse UID from claims")
+	}
+
+	return &logical.Response{
+		Auth: &logical.Auth{
+			Alias: &logical.Alias{
+				Name: uid,
+			},
+		},
+	}, nil
+}
+
+// parseAndValidateJWT parses the JWT, checks that the service account it was
+// issued for is bound to the role and, if public keys are configured, that
+// one of them validates its signature
+func parseAndValidateJWT(jwtStr string, role *roleStorageEntry, config *kubeConfig) (*serviceAccount, error) {
+	parsedJWT, err := jws.ParseJWT([]byte(jwtStr))
+	if err != nil {
+		return nil, err
+	}
+
+	sa := &serviceAccount{}
+	validator := &jwt.Validator{
+		Expected: jwt.Claims{
+			"iss": expectedJWTIssuer,
+		},
+		Fn: func(c jwt.Claims) error {
+			if err := mapstructure.Decode(c, sa); err != nil {
+				return err
+			}
+
+			if !boundListAllows(role.ServiceAccountNamespaces, sa.Namespace) {
+				return errors.New("namespace not authorized")
+			}
+			if !boundListAllows(role.ServiceAccountNames, sa.Name) {
+				return errors.New("service account name not authorized")
+			}
+
+			return nil
+		},
+	}
+
+	if err := validator.Validate(parsedJWT); err != nil {
+		return nil, err
+	}
+
+	// Without public keys the TokenReview API is the only check of the
+	// token
+	if len(config.PublicKeys) == 0 {
+		return sa, nil
+	}
+
+	var validationErr error
+	for _, key := range config.PublicKeys {
+		err := verifySignature(jwtStr, parsedJWT, key)
+		switch err {
+		case nil:
+			return sa, nil
+		case rsa.ErrVerification, crypto.ErrECDSAVerification, errMismatchedSigningMethod:
+			// Try the next key, keeping the error in case none of them
+			// validates the signature
+			validationErr = multierror.Append(validationErr, err)
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, validationErr
+}
+
+// verifySignature checks that the JWT was signed with the given key and runs
+// the claim validation
+func verifySignature(jwtStr string, parsedJWT jwt.JWT, key interface{}) error {
+	parsedJWS, err := jws.Parse([]byte(jwtStr))
+	if err != nil {
+		return err
+	}
+
+	algStr, ok := parsedJWS.Protected().Get("alg").(string)
+	if !ok {
+		return errors.New("provided JWT must have 'alg' header value")
+	}
+
+	signingMethod := jws.GetSigningMethod(algStr)
+	switch signingMethod.(type) {
+	case *crypto.SigningMethodECDSA:
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return errMismatchedSigningMethod
+		}
+	case *crypto.SigningMethodRSA:
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return errMismatchedSigningMethod
+		}
+	default:
+		return errors.New("unsupported JWT signing method")
+	}
+
+	return parsedJWT.Validate(key, signingMethod)
+}
+
+// boundListAllows returns whether the value is allowed by the bound list of a
+// role, where a single "*" allows any value
+func boundListAllows(bound []string, value string) bool {
+	if len(bound) == 1 && bound[0] == "*" {
+		return true
+	}
+	return strutil.StrListContains(bound, value)
+}
+
+// serviceAccount holds the claims of a service account JWT
+type serviceAccount struct {
+	Name       string `mapstructure:"kubernetes.io/serviceaccount/service-account.name"`
+	UID        string `mapstructure:"kubernetes.io/serviceaccount/service-account.uid"`
+	SecretName string `mapstructure:"kubernetes.io/serviceaccount/secret.name"`
+	Namespace  string `mapstructure:"kubernetes.io/serviceaccount/namespace"`
+}
+
+// lookup reviews the JWT and checks that the service account reported by the
+// reviewer matches its claims
+func (s *serviceAccount) lookup(jwtStr string, tr tokenReviewer) error {
+	r, err := tr.Review(jwtStr)
+	if err != nil {
+		return err
+	}
+
+	if s.Name != r.Name {
+		return errors.New("JWT names did not match")
+	}
+	if s.UID != r.UID {
+		return errors.New("JWT UIDs did not match")
+	}
+	if s.Namespace != r.Namespace {
+		return errors.New("JWT namespaces did not match")
+	}
+
+	return nil
+}
+
+// pathLoginRenew is invoked when the token issued by this backend is
+// attempting a renewal
+func (b *backend) pathLoginRenew(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName, ok := req.Auth.InternalData["role"].(string)
+	if !ok || roleName == "" {
+		return nil, fmt.Errorf("failed to fetch role during renewal")
+	}
+
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	// Ensure that the Role still exists.
+	role, err := b.role(req.Storage, roleName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate role %s during renewal: %v", roleName, err)
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %s does not exist during renewal", roleName)
+	}
+
+	// If 'Period' is set on the Role, the token should never expire.
+	// Replenish the TTL with 'Period's value.
+	if role.Period > time.Duration(0) {
+		// If 'Period' was updated after the token was issued,
+		// token will bear the updated 'Period' value as its TTL.
+		req.Auth.TTL = role.Period
+		return &logical.Response{Auth: req.Auth}, nil
+	}
+
+	return framework.LeaseExtend(role.TTL, role.MaxTTL, b.System())(req, data)
+}
+
+const (
+	pathLoginHelpSyn = `
+Authenticates Kubernetes service accounts with Vault.
+`
+	pathLoginHelpDesc = `
+Authenticates service account JWTs. The JWT must be issued to a service
+account bound to the given role, and is verified with the Kubernetes
+TokenReview API. The alias of the issued token is the UID of the service
+account.
+`
+)
@@ -0,0 +1,212 @@
+package kubeauth
+
+import (
+	"crypto/ecdsa"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testLoginSetup(t *testing.T) (*backend, logical.Storage, *ecdsa.PrivateKey) {
+	b, storage := getBackend(t)
+	key, pubKey := testGenerateKey(t)
+
+	testWriteConfig(t, b, storage, map[string]interface{}{
+		"kubernetes_host": "https://192.168.99.100:8443",
+		"pem_keys":        pubKey,
+	})
+	testWriteRole(t, b, storage, map[string]interface{}{
+		"bound_service_account_names":      testName,
+		"bound_service_account_namespaces": testNamespace,
+		"policies":                         "test",
+		"ttl":                              "1h",
+		"max_ttl":                          "2h",
+	})
+
+	return b, storage, key
+}
+
+func testLogin(b *backend, storage logical.Storage, role, token string) (*logical.Response, error) {
+	return b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"role": role,
+			"jwt":  token,
+		},
+	})
+}
+
+func TestLogin(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+
+	resp, err := testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, testNamespace, testUID))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	auth := resp.Auth
+	if auth.Alias == nil || auth.Alias.Name != testUID {
+		t.Fatalf("bad: %#v", auth.Alias)
+	}
+	if auth.DisplayName != testName {
+		t.Fatalf("bad: %q", auth.DisplayName)
+	}
+	if !reflect.DeepEqual(auth.Policies, []string{"test"}) {
+		t.Fatalf("bad: %#v", auth.Policies)
+	}
+	if auth.TTL != time.Hour {
+		t.Fatalf("bad: %v", auth.TTL)
+	}
+
+	expectedMetadata := map[string]string{
+		"service_account_uid":         testUID,
+		"service_account_name":        testName,
+		"service_account_namespace":   testNamespace,
+		"service_account_secret_name": testName + "-token-fkhvx",
+		"role":                        "plugin-test",
+	}
+	if !reflect.DeepEqual(auth.Metadata, expectedMetadata) {
+		t.Fatalf("bad: expected:%#v actual:%#v", expectedMetadata, auth.Metadata)
+	}
+}
+
+func TestLogin_Invalid(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+	otherKey, _ := testGenerateKey(t)
+
+	cases := map[string]struct {
+		role  string
+		token string
+	}{
+		"missing role": {
+			token: testServiceAccountJWT(t, key, testName, testNamespace, testUID),
+		},
+		"missing jwt": {
+			role: "plugin-test",
+		},
+		"unknown role": {
+			role:  "unknown",
+			token: testServiceAccountJWT(t, key, testName, testNamespace, testUID),
+		},
+		"unbound name": {
+			role:  "plugin-test",
+			token: testServiceAccountJWT(t, key, "other", testNamespace, testUID),
+		},
+		"unbound namespace": {
+			role:  "plugin-test",
+			token: testServiceAccountJWT(t, key, testName, "other", testUID),
+		},
+		"wrong signing key": {
+			role:  "plugin-test",
+			token: testServiceAccountJWT(t, otherKey, testName, testNamespace, testUID),
+		},
+		"not a jwt": {
+			role:  "plugin-test",
+			token: "not a jwt",
+		},
+	}
+
+	for name, tc := range cases {
+		resp, err := testLogin(b, storage, tc.role, tc.token)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected an error, got %#v", name, resp)
+		}
+	}
+}
+
+func TestLogin_ReviewMismatch(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+
+	// The token review reports a different service account than the one in
+	// the claims of the token
+	b.reviewFactory = mockTokenReviewFactory(testName, testNamespace, "other-uid")
+
+	resp, err := testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, testNamespace, testUID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+}
+
+func TestLogin_Wildcards(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+
+	testWriteRole(t, b, storage, map[string]interface{}{
+		"bound_service_account_names":      "*",
+		"bound_service_account_namespaces": testNamespace,
+	})
+	b.reviewFactory = mockTokenReviewFactory("other", testNamespace, testUID)
+
+	resp, err := testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, "other", testNamespace, testUID))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	testWriteRole(t, b, storage, map[string]interface{}{
+		"bound_service_account_names":      testName,
+		"bound_service_account_namespaces": "*",
+	})
+	b.reviewFactory = mockTokenReviewFactory(testName, "other", testUID)
+
+	resp, err = testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, "other", testUID))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestLogin_AliasLookahead(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.AliasLookaheadOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"jwt": testServiceAccountJWT(t, key, testName, testNamespace, testUID),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.Alias.Name != testUID {
+		t.Fatalf("bad: %#v", resp.Auth.Alias)
+	}
+}
+
+func TestLogin_Renew(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+
+	resp, err := testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, testNamespace, testUID))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req := &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Auth:      resp.Auth,
+	}
+	req.Auth.IssueTime = time.Now()
+
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.TTL != time.Hour {
+		t.Fatalf("bad: %v", resp.Auth.TTL)
+	}
+
+	// Renewal fails once the role is gone
+	if err := storage.Delete(rolePrefix + "plugin-test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.HandleRequest(req); err == nil {
+		t.Fatal("expected an error renewing without a role")
+	}
+}
@@ -0,0 +1,308 @@
+package kubeauth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/policyutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// roleStorageEntry stores all the options that are set on a role
+type roleStorageEntry struct {
+	// Policies that are to be required by the token to access this role
+	Policies []string `json:"policies" structs:"policies" mapstructure:"policies"`
+
+	// NumUses defines the number of allowed uses of the token issued
+	NumUses int `json:"num_uses" structs:"num_uses" mapstructure:"num_uses"`
+
+	// Duration before which an issued token must be renewed
+	TTL time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
+
+	// Duration after which an issued token should not be allowed to be renewed
+	MaxTTL time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+
+	// Period, if set, indicates that the token generated using this role
+	// should never expire. The token should be renewed within the duration
+	// specified by this value. The renewal duration will be fixed if the
+	// value is not modified on the role. If the `Period` in the role is modified,
+	// a token will pick up the new value during its next renewal.
+	Period time.Duration `json:"period" structs:"period" mapstructure:"period"`
+
+	// ServiceAccountNames are the names of the service accounts able to log
+	// in with this role, or "*" for any name
+	ServiceAccountNames []string `json:"bound_service_account_names" structs:"bound_service_account_names" mapstructure:"bound_service_account_names"`
+
+	// ServiceAccountNamespaces are the namespaces of the service accounts
+	// able to log in with this role, or "*" for any namespace
+	ServiceAccountNamespaces []string `json:"bound_service_account_namespaces" structs:"bound_service_account_namespaces" mapstructure:"bound_service_account_namespaces"`
+}
+
+// pathsRole creates all the paths that are used to register and manage a role.
+//
+// Paths returned:
+// role/ - For listing all the registered roles
+// role/<name> - For registering a role
+func pathsRole(b *backend) []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "role/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.pathRoleList,
+			},
+			HelpSynopsis:    strings.TrimSpace(roleHelp["role-list"][0]),
+			HelpDescription: strings.TrimSpace(roleHelp["role-list"][1]),
+		},
+		&framework.Path{
+			Pattern: "role/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the role.",
+				},
+				"bound_service_account_names": &framework.FieldSchema{
+					Type: framework.TypeCommaStringSlice,
+					Description: `List of service account names able to access this role. If set to "*"
+all names are allowed, but both this and bound_service_account_namespaces
+can not be "*".`,
+				},
+				"bound_service_account_namespaces": &framework.FieldSchema{
+					Type: framework.TypeCommaStringSlice,
+					Description: `List of namespaces allowed to access this role. If set to "*" all
+namespaces are allowed, but both this and bound_service_account_names
+can not be "*".`,
+				},
+				"policies": &framework.FieldSchema{
+					Type:        framework.TypeCommaStringSlice,
+					Description: "List of policies on the role.",
+				},
+				"num_uses": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "Number of times issued tokens can be used.",
+				},
+				"ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Duration in seconds after which the issued token should expire. Defaults
+to 0, in which case the value will fall back to the system/mount defaults.`,
+				},
+				"max_ttl": &framework.FieldSchema{
+					Type: framework.TypeDurationSecond,
+					Description: `Duration in seconds after which the issued token should not be allowed to
+be renewed. Defaults to 0, in which case the value will fall back to the system/mount defaults.`,
+				},
+				"period": &framework.FieldSchema{
+					Type:    framework.TypeDurationSecond,
+					Default: 0,
+					Description: `If set, indicates that the token generated using this role
+should never expire. The token should be renewed within the
+duration specified by this value. At each renewal, the token's
+TTL will be set to the value of this parameter.`,
+				},
+			},
+			ExistenceCheck: b.pathRoleExistenceCheck,
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: b.pathRoleCreateUpdate,
+				logical.UpdateOperation: b.pathRoleCreateUpdate,
+				logical.ReadOperation:   b.pathRoleRead,
+				logical.DeleteOperation: b.pathRoleDelete,
+			},
+			HelpSynopsis:    strings.TrimSpace(roleHelp["role"][0]),
+			HelpDescription: strings.TrimSpace(roleHelp["role"][1]),
+		},
+	}
+}
+
+// pathRoleExistenceCheck returns whether the role with the given name exists or not.
+func (b *backend) pathRoleExistenceCheck(req *logical.Request, data *framework.FieldData) (bool, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	role, err := b.role(req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+// pathRoleList is used to list all the roles registered with the backend.
+func (b *backend) pathRoleList(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	roles, err := req.Storage.List(rolePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(roles), nil
+}
+
+// pathRoleRead reads the options set on the role from the storage
+func (b *backend) pathRoleRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing name"), nil
+	}
+
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	role, err := b.role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"bound_service_account_names":      role.ServiceAccountNames,
+			"bound_service_account_namespaces": role.ServiceAccountNamespaces,
+			"policies":                         role.Policies,
+			"num_uses":                         role.NumUses,
+			"ttl":                              int64(role.TTL / time.Second),
+			"max_ttl":                          int64(role.MaxTTL / time.Second),
+			"period":                           int64(role.Period / time.Second),
+		},
+	}, nil
+}
+
+// pathRoleDelete removes the role from storage
+func (b *backend) pathRoleDelete(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if err := req.Storage.Delete(rolePrefix + strings.ToLower(roleName)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// pathRoleCreateUpdate registers a new role with the backend or updates the
+// options of an existing role
+func (b *backend) pathRoleCreateUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	roleName := data.Get("name").(string)
+	if roleName == "" {
+		return logical.ErrorResponse("missing role name"), nil
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	role, err := b.role(req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		if req.Operation == logical.UpdateOperation {
+			return nil, fmt.Errorf("role entry not found during update operation")
+		}
+		role = &roleStorageEntry{}
+	}
+
+	if policiesRaw, ok := data.GetOk("policies"); ok {
+		role.Policies = policyutil.ParsePolicies(policiesRaw)
+	}
+
+	if periodRaw, ok := data.GetOk("period"); ok {
+		role.Period = time.Second * time.Duration(periodRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.Period = time.Second * time.Duration(data.Get("period").(int))
+	}
+	if role.Period > b.System().MaxLeaseTTL() {
+		return logical.ErrorResponse(fmt.Sprintf("'period' of %q is greater than the backend's maximum lease TTL of %q", role.Period.String(), b.System().MaxLeaseTTL().String())), nil
+	}
+
+	if numUsesRaw, ok := data.GetOk("num_uses"); ok {
+		role.NumUses = numUsesRaw.(int)
+	} else if req.Operation == logical.CreateOperation {
+		role.NumUses = data.Get("num_uses").(int)
+	}
+	if role.NumUses < 0 {
+		return logical.ErrorResponse("num_uses cannot be negative"), nil
+	}
+
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		role.TTL = time.Second * time.Duration(ttlRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.TTL = time.Second * time.Duration(data.Get("ttl").(int))
+	}
+
+	if maxTTLRaw, ok := data.GetOk("max_ttl"); ok {
+		role.MaxTTL = time.Second * time.Duration(maxTTLRaw.(int))
+	} else if req.Operation == logical.CreateOperation {
+		role.MaxTTL = time.Second * time.Duration(data.Get("max_ttl").(int))
+	}
+
+	// Check that the TTL value provided is less than the MaxTTL.
+	// Sanitizing the TTL and MaxTTL is not required now and can be performed
+	// at credential issue time.
+	if role.MaxTTL > time.Duration(0) && role.TTL > role.MaxTTL {
+		return logical.ErrorResponse("ttl should not be greater than max_ttl"), nil
+	}
+
+	var resp *logical.Response
+	if role.MaxTTL > b.System().MaxLeaseTTL() {
+		resp = &logical.Response{}
+		resp.AddWarning("max_ttl is greater than the system or backend mount's maximum TTL value; issued tokens' max TTL value will be truncated")
+	}
+
+	if namesRaw, ok := data.GetOk("bound_service_account_names"); ok {
+		role.ServiceAccountNames = namesRaw.([]string)
+	}
+	if len(role.ServiceAccountNames) == 0 {
+		return logical.ErrorResponse("'bound_service_account_names' can not be empty"), nil
+	}
+	if len(role.ServiceAccountNames) > 1 && strutil.StrListContains(role.ServiceAccountNames, "*") {
+		return logical.ErrorResponse("can not mix \"*\" with values in 'bound_service_account_names'"), nil
+	}
+
+	if namespacesRaw, ok := data.GetOk("bound_service_account_namespaces"); ok {
+		role.ServiceAccountNamespaces = namespacesRaw.([]string)
+	}
+	if len(role.ServiceAccountNamespaces) == 0 {
+		return logical.ErrorResponse("'bound_service_account_namespaces' can not be empty"), nil
+	}
+	if len(role.ServiceAccountNamespaces) > 1 && strutil.StrListContains(role.ServiceAccountNamespaces, "*") {
+		return logical.ErrorResponse("can not mix \"*\" with values in 'bound_service_account_namespaces'"), nil
+	}
+
+	if strutil.StrListContains(role.ServiceAccountNames, "*") && strutil.StrListContains(role.ServiceAccountNamespaces, "*") {
+		return logical.ErrorResponse("'bound_service_account_names' and 'bound_service_account_namespaces' can not both be \"*\""), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(rolePrefix+strings.ToLower(roleName), role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+var roleHelp = map[string][2]string{
+	"role-list": {
+		"Lists all the roles registered with the backend.",
+		"The list will contain the names of the roles.",
+	},
+	"role": {
+		"Register a role with the backend.",
+		`
+A role is required to authenticate with this backend. The role binds
+Kubernetes service account names and namespaces with token policies and
+settings. The bindings, token polices and token settings can all be
+configured using this endpoint.
+`,
+	},
+}
@@ -11,7 +11,7 @@ import (
 	"net/http"
 	"strings"
 
-	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-cleanhttp"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,21 +19,26 @@ import (
 	authv1 "k8s.io/client-go/pkg/apis/authentication/v1"
 )
 
-// This is the result from the token review
+// tokenReviewResult is the service account a token was issued for, as
+// reported by the TokenReview API
 type tokenReviewResult struct {
 	Name      string
 	Namespace string
 	UID       string
 }
 
-// This exists so we can use a mock TokenReview when running tests
+// tokenReviewer reviews service account tokens. It exists so that tests can
+// replace the Kubernetes API with a mock.
 type tokenReviewer interface {
 	Review(string) (*tokenReviewResult, error)
 }
 
+// tokenReviewFactory creates the token reviewer to use for the given
+// configuration
 type tokenReviewFactory func(*kubeConfig) tokenReviewer
 
-// This is the real implementation that calls the kubernetes API
+// tokenReviewAPI reviews tokens by calling the TokenReview API of the
+// configured Kubernetes API server
 type tokenReviewAPI struct {
 	config *kubeConfig
 }
@@ -45,23 +50,21 @@ func tokenReviewAPIFactory(config *kubeConfig) tokenReviewer {
 }
 
 func (t *tokenReviewAPI) Review(jwt string) (*tokenReviewResult, error) {
-
 	client := cleanhttp.DefaultClient()
 
-	// If we have a CA cert build the TLSConfig
-	if len(t.config.CACert) > 0 {
+	// If a CA cert is configured, only trust the certificates in it
+	if t.config.CACert != "" {
 		certPool := x509.NewCertPool()
-		certPool.AppendCertsFromPEM([]byte(t.config.CACert))
+		if ok := certPool.AppendCertsFromPEM([]byte(t.config.CACert)); !ok {
+			return nil, errors.New("could not parse the configured CA certificate")
+		}
 
-		tlsConfig := &tls.Config{
+		client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
 			MinVersion: tls.VersionTLS12,
 			RootCAs:    certPool,
 		}
-
-		client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
 	}
 
-	// Create the TokenReview Object and marshal it into json
 	trReq := &authv1.TokenReview{
 		Spec: authv1.TokenReviewSpec{
 			Token: jwt,
@@ -72,33 +75,30 @@ func (t *tokenReviewAPI) Review(jwt string) (*tokenReviewResult, error) {
 		return nil, err
 	}
 
-	// Build the request to the token review API
-	url := fmt.Sprintf("%s/apis/authentication.k8s.io/v1/tokenreviews", t.config.Host)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(trJSON))
+	url := fmt.Sprintf("%s/apis/authentication.k8s.io/v1/tokenreviews", strings.TrimSuffix(t.config.Host, "/"))
+	req, err := http.NewRequest("POST", url, bytes.NewReader(trJSON))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	// If we have a configured TokenReviewer JWT use it as the bearer, otherwise
-	// try to use the passed in JWT.
-	bearer := fmt.Sprintf("Bearer %s", jwt)
-	if len(t.config.TokenReviewerJWT) > 0 {
-		bearer = fmt.Sprintf("Bearer %s", t.config.TokenReviewerJWT)
+	// Use the configured token reviewer JWT as the bearer if there is one,
+	// otherwise the token being reviewed has to be allowed to call the API
+	bearer := jwt
+	if t.config.TokenReviewerJWT != "" {
+		bearer = t.config.TokenReviewerJWT
 	}
-
-	// Set the JWT as the Bearer token
-	req.Header.Set("Authorization", bearer)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", bearer))
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the resp into a tokenreview object or a kubernetes error type
 	r, err := parseResponse(resp)
 	switch {
 	case kubeerrors.IsUnauthorized(err):
-		// If the err is unauthorized that means the token has since been deleted
+		// The token has most likely been deleted since it was issued
 		return nil, errors.New("lookup failed: service account unauthorized; this could mean it has been deleted")
 	case err != nil:
 		return nil, err
@@ -107,18 +107,15 @@ func (t *tokenReviewAPI) Review(jwt string) (*tokenReviewResult, error) {
 	if r.Status.Error != "" {
 		return nil, fmt.Errorf("lookup failed: %s", r.Status.Error)
 	}
-
 	if !r.Status.Authenticated {
 		return nil, errors.New("lookup failed: service account jwt not valid")
 	}
 
-	// The username is of format: system:serviceaccount:(NAMESPACE):(SERVICEACCOUNT)
+	// The username has the form system:serviceaccount:<namespace>:<name>
 	parts := strings.Split(r.Status.User.Username, ":")
 	if len(parts) != 4 {
 		return nil, errors.New("lookup failed: unexpected username format")
 	}
-
-	// Validate the user that comes back from token review is a service account
 	if parts[0] != "system" || parts[1] != "serviceaccount" {
 		return nil, errors.New("lookup failed: username returned is not a service account")
 	}
@@ -130,59 +127,31 @@ func (t *tokenReviewAPI) Review(jwt string) (*tokenReviewResult, error) {
 	}, nil
 }
 
-// parseResponse takes the API response and either returns the appropriate error
-// or the TokenReview Object.
+// parseResponse returns the TokenReview object in the response of the API, or
+// the Kubernetes error it describes
 func parseResponse(resp *http.Response) (*authv1.TokenReview, error) {
+	defer resp.Body.Close()
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// If the request was not a success create a kuberenets error
 	if resp.StatusCode < http.StatusOK || resp.StatusCode > http.StatusPartialContent {
 		return nil, kubeerrors.NewGenericServerResponse(resp.StatusCode, "POST", schema.GroupResource{}, "", strings.TrimSpace(string(body)), 0, true)
 	}
 
-	// If we can succesfully Unmarshal into a status object that means there is
-	// an error to return
+	// A body that unmarshals into a status which is not a success describes
+	// an error
 	errStatus := &metav1.Status{}
-	err = json.Unmarshal(body, errStatus)
-	if err == nil && errStatus.Status != metav1.StatusSuccess {
+	if err := json.Unmarshal(body, errStatus); err == nil && errStatus.Status != metav1.StatusSuccess {
 		return nil, kubeerrors.FromObject(runtime.Object(errStatus))
 	}
 
-	// Unmarshal the resp body into a TokenReview Object
 	trResp := &authv1.TokenReview{}
-	err = json.Unmarshal(body, trResp)
-	if err != nil {
+	if err := json.Unmarshal(body, trResp); err != nil {
 		return nil, err
 	}
 
 	return trResp, nil
 }
-
-// mock review is used while testing
-type mockTokenReview struct {
-	saName      string
-	saNamespace string
-	saUID       string
-}
-
-func mockTokenReviewFactory(name, namespace, UID string) tokenReviewFactory {
-	return func(config *kubeConfig) tokenReviewer {
-		return &mockTokenReview{
-			saName:      name,
-			saNamespace: namespace,
-			saUID:       UID,
-		}
-	}
-}
-
-func (t *mockTokenReview) Review(jwt string) (*tokenReviewResult, error) {
-	return &tokenReviewResult{
-		Name:      t.saName,
-		Namespace: t.saNamespace,
-		UID:       t.saUID,
-	}, nil
-}
@@ -0,0 +1,153 @@
+package kubeauth
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authv1 "k8s.io/client-go/pkg/apis/authentication/v1"
+)
+
+// testAPIServer starts a fake Kubernetes API server answering TokenReview
+// requests made with the given reviewer JWT. Tokens are authenticated as the
+// given username, except for the token "unauthorized", which is rejected as if
+// the service account had been deleted. It returns the server and the PEM of
+// its CA certificate.
+func testAPIServer(t *testing.T, reviewerJWT, username string) (*httptest.Server, string) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+reviewerJWT {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var review authv1.TokenReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if review.Spec.Token == "unauthorized" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(&metav1.Status{
+				Status:  metav1.StatusFailure,
+				Reason:  metav1.StatusReasonUnauthorized,
+				Code:    http.StatusUnauthorized,
+				Message: "Unauthorized",
+			})
+			return
+		}
+
+		review.Status = authv1.TokenReviewStatus{
+			Authenticated: true,
+			User: authv1.UserInfo{
+				Username: username,
+				UID:      testUID,
+			},
+		}
+		json.NewEncoder(w).Encode(&review)
+	}))
+
+	caPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ts.Certificate().Raw,
+	})
+	return ts, string(caPEM)
+}
+
+func TestTokenReviewAPI(t *testing.T) {
+	ts, caPEM := testAPIServer(t, "reviewer-jwt", "system:serviceaccount:"+testNamespace+":"+testName)
+	defer ts.Close()
+
+	reviewer := tokenReviewAPIFactory(&kubeConfig{
+		Host:             ts.URL,
+		CACert:           caPEM,
+		TokenReviewerJWT: "reviewer-jwt",
+	})
+
+	result, err := reviewer.Review("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != testName || result.Namespace != testNamespace || result.UID != testUID {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	_, err = reviewer.Review("unauthorized")
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+
+	// Without the reviewer JWT the token being reviewed is used as the
+	// bearer, which the fake server doesn't accept
+	reviewer = tokenReviewAPIFactory(&kubeConfig{
+		Host:   ts.URL,
+		CACert: caPEM,
+	})
+	if _, err := reviewer.Review("token"); err == nil {
+		t.Fatal("expected an error without the reviewer JWT")
+	}
+
+	// Without the CA the certificate of the server isn't trusted
+	reviewer = tokenReviewAPIFactory(&kubeConfig{
+		Host:             ts.URL,
+		TokenReviewerJWT: "reviewer-jwt",
+	})
+	if _, err := reviewer.Review("token"); err == nil {
+		t.Fatal("expected an error without the CA certificate")
+	}
+}
+
+func TestTokenReviewAPI_NotServiceAccount(t *testing.T) {
+	ts, caPEM := testAPIServer(t, "reviewer-jwt", "admin")
+	defer ts.Close()
+
+	reviewer := tokenReviewAPIFactory(&kubeConfig{
+		Host:             ts.URL,
+		CACert:           caPEM,
+		TokenReviewerJWT: "reviewer-jwt",
+	})
+	if _, err := reviewer.Review("token"); err == nil {
+		t.Fatal("expected an error for a user that is not a service account")
+	}
+}
+
+func TestLogin_TokenReviewAPI(t *testing.T) {
+	b, storage, key := testLoginSetup(t)
+	b.reviewFactory = tokenReviewAPIFactory
+
+	reviewerJWT := testServiceAccountJWT(t, key, "reviewer", testNamespace, "reviewer-uid")
+	ts, caPEM := testAPIServer(t, reviewerJWT, "system:serviceaccount:"+testNamespace+":"+testName)
+	defer ts.Close()
+
+	testWriteConfig(t, b, storage, map[string]interface{}{
+		"kubernetes_host":    ts.URL,
+		"kubernetes_ca_cert": caPEM,
+		"token_reviewer_jwt": reviewerJWT,
+	})
+
+	resp, err := testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, testNamespace, testUID))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth.Alias.Name != testUID {
+		t.Fatalf("bad: %#v", resp.Auth.Alias)
+	}
+
+	// The API server reports a different service account than the one in
+	// the claims of the token
+	resp, err = testLogin(b, storage, "plugin-test", testServiceAccountJWT(t, key, testName, testNamespace, "other-uid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+}
@@ -0,0 +1,152 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+
+	// The maximum size of the key authorization served for http-01
+	acmeHTTP01MaxBody = 8 * 1024
+
+	// The maximum number of redirects followed for http-01
+	acmeHTTP01MaxRedirects = 10
+)
+
+// acmeResolver looks up the TXT records of dns-01 challenges. It is an
+// interface so that validation can be tested without a DNS server.
+type acmeResolver interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+// acmeHTTPClient fetches the key authorizations of http-01 challenges
+type acmeHTTPClient interface {
+	Get(url string) (*http.Response, error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+func newACMEHTTPClient() acmeHTTPClient {
+	return &http.Client{
+		Timeout:       10 * time.Second,
+		CheckRedirect: acmeCheckRedirect,
+	}
+}
+
+// acmeCheckRedirect only lets http-01 validation follow redirects to the
+// standard HTTP and HTTPS ports of the domain being validated, so that
+// challenges cannot be used to reach other hosts or services
+func acmeCheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= acmeHTTP01MaxRedirects {
+		return errors.New("too many redirects")
+	}
+	if req.URL.Hostname() != via[0].URL.Hostname() {
+		return errors.New("redirect to another host")
+	}
+	switch req.URL.Scheme + ":" + req.URL.Port() {
+	case "http:", "http:80", "https:", "https:443":
+		return nil
+	default:
+		return errors.New("redirect to a non-standard port")
+	}
+}
+
+// acmeKeyAuthorization returns the key authorization of a challenge token,
+// as defined in RFC 8555 section 8.1
+func acmeKeyAuthorization(token string, key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := acmeThumbprint(key)
+	if err != nil {
+		return "", err
+	}
+	return token + "." + thumbprint, nil
+}
+
+// acmeThumbprint returns the base64url encoded SHA-256 thumbprint of a key
+// (RFC 7638)
+func acmeThumbprint(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// acmeValidateChallenge checks that the client controls the identifier of
+// the authorization, returning a problem describing why not otherwise
+func (b *backend) acmeValidateChallenge(authz *acmeAuthorization, challenge *acmeChallenge, keyAuth string) error {
+	switch challenge.Type {
+	case acmeChallengeHTTP01:
+		return b.acmeValidateHTTP01(authz.Identifier.Value, challenge.Token, keyAuth)
+	case acmeChallengeDNS01:
+		return b.acmeValidateDNS01(authz.Identifier.Value, keyAuth)
+	default:
+		return newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "unsupported challenge type %q", challenge.Type)
+	}
+}
+
+// acmeValidateHTTP01 implements the challenge of RFC 8555 section 8.3. The
+// problems returned do not say what the server responded, so that challenges
+// cannot be used to probe the network the server is in.
+func (b *backend) acmeValidateHTTP01(domain, token, keyAuth string) error {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+	resp, err := b.acmeHTTPClient.Get(url)
+	if err != nil {
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("pki: error fetching ACME http-01 challenge", "url", url, "error", err)
+		}
+		return newACMEProblem(acmeErrConnection, http.StatusBadRequest, "unable to fetch %s", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newACMEProblem(acmeErrIncorrectResponse, http.StatusBadRequest, "%s does not hold the expected key authorization", url)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, acmeHTTP01MaxBody))
+	if err != nil {
+		return newACMEProblem(acmeErrConnection, http.StatusBadRequest, "unable to fetch %s", url)
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(string(body))), []byte(keyAuth)) != 1 {
+		return newACMEProblem(acmeErrIncorrectResponse, http.StatusBadRequest, "%s does not hold the expected key authorization", url)
+	}
+
+	return nil
+}
+
+// acmeValidateDNS01 implements the challenge of RFC 8555 section 8.4
+func (b *backend) acmeValidateDNS01(domain, keyAuth string) error {
+	name := "_acme-challenge." + domain
+	records, err := b.acmeResolver.LookupTXT(name)
+	if err != nil {
+		return newACMEProblem(acmeErrDNS, http.StatusBadRequest, "error looking up TXT records of %s: %v", name, err)
+	}
+
+	digest := sha256.Sum256([]byte(keyAuth))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	for _, record := range records {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(record)), []byte(expected)) == 1 {
+			return nil
+		}
+	}
+
+	return newACMEProblem(acmeErrIncorrectResponse, http.StatusBadRequest, "no TXT record of %s holds the expected key authorization digest", name)
+}
@@ -0,0 +1,500 @@
+package pki
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// ACME (RFC 8555) state is kept per account: the orders and authorizations of
+// an account are stored under its ID, so that they can only be reached with
+// requests signed by its key.
+const (
+	acmeAccountPrefix    = "acme/accounts/"
+	acmeThumbprintPrefix = "acme/thumbprints/"
+	acmeOrderPrefix      = "acme/orders/"
+	acmeAuthzPrefix      = "acme/authorizations/"
+	acmeCertPrefix       = "acme/certs/"
+
+	acmeJSONContentType    = "application/json"
+	acmeProblemContentType = "application/problem+json"
+	acmeCertContentType    = "application/pem-certificate-chain"
+
+	acmeOrderLifetime = 24 * time.Hour
+	acmeNonceLifetime = time.Hour
+
+	// acmeMaxNonces is the number of nonces kept at most; past it, the
+	// oldest ones are dropped
+	acmeMaxNonces = 10000
+
+	// acmeChallengeTimeout is how long a challenge may be processing before
+	// its validation is assumed to have been interrupted
+	acmeChallengeTimeout = time.Minute
+)
+
+// ACME object statuses, as defined in RFC 8555 section 7.1.6
+const (
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusProcessing  = "processing"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusExpired     = "expired"
+	acmeStatusDeactivated = "deactivated"
+)
+
+// ACME error types, as defined in RFC 8555 section 6.7
+const (
+	acmeErrAccountDoesNotExist   = "accountDoesNotExist"
+	acmeErrAlreadyRevoked        = "alreadyRevoked"
+	acmeErrBadCSR                = "badCSR"
+	acmeErrBadNonce              = "badNonce"
+	acmeErrBadSignatureAlgorithm = "badSignatureAlgorithm"
+	acmeErrConnection            = "connection"
+	acmeErrDNS                   = "dns"
+	acmeErrIncorrectResponse     = "incorrectResponse"
+	acmeErrMalformed             = "malformed"
+	acmeErrOrderNotReady         = "orderNotReady"
+	acmeErrRejectedIdentifier    = "rejectedIdentifier"
+	acmeErrServerInternal        = "serverInternal"
+	acmeErrUnauthorized          = "unauthorized"
+	acmeErrUnsupportedContact    = "unsupportedContact"
+	acmeErrUnsupportedIdentifier = "unsupportedIdentifier"
+)
+
+// acmeSignatureAlgorithms are the JWS algorithms accepted from clients
+var acmeSignatureAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// acmeProblem is an ACME error, returned to clients as a problem document
+// (RFC 7807)
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func newACMEProblem(errType string, status int, format string, args ...interface{}) *acmeProblem {
+	return &acmeProblem{
+		Type:   "urn:ietf:params:acme:error:" + errType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func (p *acmeProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+type acmeAccount struct {
+	ID                   string          `json:"id"`
+	Status               string          `json:"status"`
+	Contact              []string        `json:"contact"`
+	TermsOfServiceAgreed bool            `json:"terms_of_service_agreed"`
+	Key                  json.RawMessage `json:"key"`
+	Thumbprint           string          `json:"thumbprint"`
+	CreatedAt            time.Time       `json:"created_at"`
+}
+
+func (a *acmeAccount) jwk() (*jose.JSONWebKey, error) {
+	var key jose.JSONWebKey
+	if err := json.Unmarshal(a.Key, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	ID                string           `json:"id"`
+	AccountID         string           `json:"account_id"`
+	Status            string           `json:"status"`
+	Expires           time.Time        `json:"expires"`
+	Identifiers       []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs  []string         `json:"authorization_ids"`
+	Error             *acmeProblem     `json:"error,omitempty"`
+	CertificateSerial string           `json:"certificate_serial"`
+	Certificate       string           `json:"certificate"`
+}
+
+type acmeChallenge struct {
+	Type      string       `json:"type"`
+	Token     string       `json:"token"`
+	Status    string       `json:"status"`
+	Validated time.Time    `json:"validated"`
+	Error     *acmeProblem `json:"error,omitempty"`
+}
+
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Wildcard   bool             `json:"wildcard"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+// challenge returns the challenge of the given type, or nil
+func (a *acmeAuthorization) challenge(challengeType string) *acmeChallenge {
+	for _, c := range a.Challenges {
+		if c.Type == challengeType {
+			return c
+		}
+	}
+	return nil
+}
+
+// acmeCertEntry records which account a certificate was issued to, so that
+// the account can revoke it
+type acmeCertEntry struct {
+	AccountID string `json:"account_id"`
+	OrderID   string `json:"order_id"`
+}
+
+// getACMEEntry decodes the JSON entry at the given path into out, returning
+// whether it exists
+func getACMEEntry(s logical.Storage, path string, out interface{}) (bool, error) {
+	entry, err := s.Get(path)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func putACMEEntry(s logical.Storage, path string, v interface{}) error {
+	entry, err := logical.StorageEntryJSON(path, v)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+func fetchACMEAccount(s logical.Storage, id string) (*acmeAccount, error) {
+	var account acmeAccount
+	ok, err := getACMEEntry(s, acmeAccountPrefix+id, &account)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func fetchACMEOrder(s logical.Storage, accountID, id string) (*acmeOrder, error) {
+	var order acmeOrder
+	ok, err := getACMEEntry(s, acmeOrderPrefix+accountID+"/"+id, &order)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func writeACMEOrder(s logical.Storage, order *acmeOrder) error {
+	return putACMEEntry(s, acmeOrderPrefix+order.AccountID+"/"+order.ID, order)
+}
+
+func fetchACMEAuthorization(s logical.Storage, accountID, id string) (*acmeAuthorization, error) {
+	var authz acmeAuthorization
+	ok, err := getACMEEntry(s, acmeAuthzPrefix+accountID+"/"+id, &authz)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &authz, nil
+}
+
+func writeACMEAuthorization(s logical.Storage, authz *acmeAuthorization) error {
+	return putACMEEntry(s, acmeAuthzPrefix+authz.AccountID+"/"+authz.ID, authz)
+}
+
+// acmeRandom returns a random base64url encoded string, used for nonces and
+// challenge tokens
+func acmeRandom() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// acmeNonceStore holds the nonces handed out to clients until they are used
+// or expire. Nonces are queued in the order they were issued, which is also
+// the order they expire in, so expired ones are dropped without scanning the
+// others. Used nonces stay queued until they reach its head, and the queue
+// holds at most acmeMaxNonces, dropping the oldest ones first.
+type acmeNonceStore struct {
+	sync.Mutex
+	expiry map[string]time.Time
+	queue  []string
+}
+
+func newACMENonceStore() *acmeNonceStore {
+	return &acmeNonceStore{
+		expiry: make(map[string]time.Time),
+	}
+}
+
+// add stores a nonce valid until the given time
+func (s *acmeNonceStore) add(nonce string, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for len(s.queue) > 0 {
+		oldest := s.queue[0]
+		expiry, ok := s.expiry[oldest]
+		if ok && now.Before(expiry) && len(s.queue) < acmeMaxNonces {
+			break
+		}
+		delete(s.expiry, oldest)
+		s.queue = s.queue[1:]
+	}
+
+	s.expiry[nonce] = now.Add(acmeNonceLifetime)
+	s.queue = append(s.queue, nonce)
+}
+
+// use consumes a nonce, returning whether it was valid
+func (s *acmeNonceStore) use(nonce string, now time.Time) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	expiry, ok := s.expiry[nonce]
+	if !ok {
+		return false
+	}
+	delete(s.expiry, nonce)
+	return now.Before(expiry)
+}
+
+// acmeNewNonce returns a new nonce for clients to use in their next request.
+// Nonces are only kept in memory: requests to a standby are forwarded to the
+// active node, which is the only one issuing and checking them.
+func (b *backend) acmeNewNonce() (string, error) {
+	nonce, err := acmeRandom()
+	if err != nil {
+		return "", err
+	}
+	b.acmeNonces.add(nonce, time.Now())
+	return nonce, nil
+}
+
+// acmeUseNonce consumes a nonce, returning whether it was valid
+func (b *backend) acmeUseNonce(nonce string) bool {
+	return b.acmeNonces.use(nonce, time.Now())
+}
+
+// acmeValidIdentifier reports whether the value of a dns identifier is a
+// syntactically valid hostname, optionally with a wildcard as its first
+// label. IP addresses are not hostnames, nor are names with a numeric last
+// label, which resolvers may take for one.
+func acmeValidIdentifier(name string) bool {
+	name = strings.TrimPrefix(name, "*.")
+	if len(name) > 253 || !hostnameRegex.MatchString(name) || net.ParseIP(name) != nil {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if len(label) > 63 {
+			return false
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+// acmeKeyMode selects how the key of an ACME request must be given
+type acmeKeyMode int
+
+const (
+	// The request is signed by the key of an account, identified by its URL
+	acmeKeyID acmeKeyMode = iota
+	// The request embeds the key it is signed with
+	acmeKeyJWK
+	// Either of the above
+	acmeKeyAny
+)
+
+// acmeRequest is a verified ACME request
+type acmeRequest struct {
+	// Payload is empty for POST-as-GET requests
+	Payload []byte
+	// Account is set for requests signed by the key of an account
+	Account *acmeAccount
+	// JWK is set for requests embedding their key
+	JWK *jose.JSONWebKey
+}
+
+// acmeVerify parses and verifies the JWS of an ACME request, as described in
+// RFC 8555 section 6.2
+func (b *backend) acmeVerify(req *logical.Request, config *acmeConfig, mode acmeKeyMode) (*acmeRequest, error) {
+	body, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "request body must be a JWS with the application/jose+json content type")
+	}
+
+	// Only the flattened JSON serialization, with all headers protected, is
+	// allowed
+	var outer struct {
+		Header     json.RawMessage `json:"header"`
+		Signatures json.RawMessage `json:"signatures"`
+	}
+	if err := json.Unmarshal(body, &outer); err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error parsing JWS: %v", err)
+	}
+	if len(outer.Header) != 0 || len(outer.Signatures) != 0 {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS must use the flattened JSON serialization with a protected header only")
+	}
+
+	jws, err := jose.ParseSigned(string(body))
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error parsing JWS: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Header
+
+	if !acmeSignatureAlgorithms[header.Algorithm] {
+		return nil, newACMEProblem(acmeErrBadSignatureAlgorithm, http.StatusBadRequest, "unsupported signature algorithm %q", header.Algorithm)
+	}
+	if !b.acmeUseNonce(header.Nonce) {
+		return nil, newACMEProblem(acmeErrBadNonce, http.StatusBadRequest, "invalid or expired nonce")
+	}
+	if url, _ := header.ExtraHeaders["url"].(string); url != config.url(req.Path) {
+		return nil, newACMEProblem(acmeErrUnauthorized, http.StatusUnauthorized, "JWS url %q does not match the request URL", url)
+	}
+
+	result := &acmeRequest{}
+	var key *jose.JSONWebKey
+	switch {
+	case header.JSONWebKey != nil && header.KeyID != "":
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS must not have both jwk and kid headers")
+
+	case header.JSONWebKey != nil:
+		if mode == acmeKeyID {
+			return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS must be signed with the key of an account, given by the kid header")
+		}
+		if !header.JSONWebKey.IsPublic() {
+			return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS jwk header must hold a public key")
+		}
+		result.JWK = header.JSONWebKey
+		key = header.JSONWebKey
+
+	default:
+		if mode == acmeKeyJWK {
+			return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS must embed its key with the jwk header")
+		}
+		accountPrefix := config.url("acme/account/")
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return nil, newACMEProblem(acmeErrAccountDoesNotExist, http.StatusBadRequest, "unknown account %q", header.KeyID)
+		}
+		account, err := fetchACMEAccount(req.Storage, strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, newACMEProblem(acmeErrAccountDoesNotExist, http.StatusBadRequest, "unknown account %q", header.KeyID)
+		}
+		if account.Status != acmeStatusValid {
+			return nil, newACMEProblem(acmeErrUnauthorized, http.StatusUnauthorized, "account is %s", account.Status)
+		}
+		key, err = account.jwk()
+		if err != nil {
+			return nil, err
+		}
+		result.Account = account
+	}
+
+	result.Payload, err = jws.Verify(key)
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "JWS signature verification failed")
+	}
+
+	return result, nil
+}
+
+// acmeRawResponse returns a raw response carrying a fresh nonce and a link to
+// the directory, as required for all ACME responses
+func (b *backend) acmeRawResponse(config *acmeConfig, status int, contentType string, body []byte, headers map[string][]string) (*logical.Response, error) {
+	nonce, err := b.acmeNewNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	headers["Replay-Nonce"] = []string{nonce}
+	headers["Cache-Control"] = []string{"no-store"}
+	if config != nil {
+		headers["Link"] = append(headers["Link"], fmt.Sprintf("<%s>;rel=\"index\"", config.url("acme/directory")))
+	}
+	if body == nil {
+		body = []byte{}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  status,
+			logical.HTTPRawHeaders:  headers,
+		},
+	}, nil
+}
+
+// acmeJSONResponse returns the given ACME object, along with the URL of the
+// object in the Location header if location is set
+func (b *backend) acmeJSONResponse(config *acmeConfig, status int, v interface{}, location string) (*logical.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var headers map[string][]string
+	if location != "" {
+		headers = map[string][]string{
+			"Location": []string{location},
+		}
+	}
+
+	return b.acmeRawResponse(config, status, acmeJSONContentType, body, headers)
+}
+
+// acmeErrorResponse turns an error into a problem document. Errors other than
+// ACME problems are internal errors, whose details are only logged.
+func (b *backend) acmeErrorResponse(config *acmeConfig, err error) (*logical.Response, error) {
+	problem, ok := err.(*acmeProblem)
+	if !ok {
+		b.Logger().Error("pki: error handling ACME request", "error", err)
+		problem = newACMEProblem(acmeErrServerInternal, http.StatusInternalServerError, "internal error")
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return nil, err
+	}
+	return b.acmeRawResponse(config, problem.Status, acmeProblemContentType, body, nil)
+}
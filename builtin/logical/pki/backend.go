@@ -1,10 +1,13 @@
 package pki
 
 import (
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
@@ -15,6 +18,9 @@ func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
 	if err := b.Setup(conf); err != nil {
 		return nil, err
 	}
+	if err := b.initialize(conf.StorageView); err != nil {
+		return nil, err
+	}
 	return b, nil
 }
 
@@ -32,12 +38,21 @@ func Backend() *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta",
+				"crl/delta/pem",
+				"ocsp",
+				"ocsp/*",
+				"acme/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"crls/",
+				"delta-crls/",
+				"crl-state/",
 				"certs/",
+				"acme/",
 			},
 
 			Root: []string{
@@ -70,16 +85,46 @@ func Backend() *backend {
 			pathFetchListCerts(&b),
 			pathRevoke(&b),
 			pathTidy(&b),
+			pathTidyStatus(&b),
+			pathConfigAutoTidy(&b),
+			pathOCSP(&b),
+			pathListIssuers(&b),
+			pathIssuers(&b),
+			pathImportIssuers(&b),
+			pathFetchIssuer(&b),
+			pathConfigIssuers(&b),
+			pathListKeys(&b),
+			pathKeys(&b),
+			pathGenerateKey(&b),
+			pathConfigACME(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMEAccountOrders(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEFinalize(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
+			pathACMECertificate(&b),
+			pathACMERevokeCert(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
 
+		PeriodicFunc: b.periodicFunc,
+
 		BackendType: logical.TypeLogical,
 	}
 
 	b.crlLifetime = time.Hour * 72
+	b.ocspLifetime = time.Hour * 12
+	b.acmeNonces = newACMENonceStore()
+	b.acmeResolver = netResolver{}
+	b.acmeHTTPClient = newACMEHTTPClient()
 
 	return &b
 }
@@ -88,7 +133,70 @@ type backend struct {
 	*framework.Backend
 
 	crlLifetime       time.Duration
+	ocspLifetime      time.Duration
 	revokeStorageLock sync.RWMutex
+
+	// crlBuildLock serializes the building of CRLs, which take numbers from
+	// the stored CRL state of their issuer
+	crlBuildLock     sync.Mutex
+	lastDeltaRebuild time.Time
+
+	acmeLock       sync.Mutex
+	acmeNonces     *acmeNonceStore
+	acmeResolver   acmeResolver
+	acmeHTTPClient acmeHTTPClient
+
+	// tidyCASGuard ensures a single tidy operation runs at a time;
+	// tidyStatusLock protects the status of the running or last one and
+	// the time the last automatic one finished, which are read from
+	// storage when first needed
+	tidyCASGuard     uint32
+	tidyStatusLock   sync.Mutex
+	tidyStatus       *tidyStatus
+	lastAutoTidy     time.Time
+	tidyStatusLoaded bool
+
+	// issuersMigrationLock serializes the migration of the legacy CA bundle
+	// and its removal
+	issuersMigrationLock sync.Mutex
+}
+
+// initialize migrates the storage of the mount before it serves requests.
+// Secondaries receive the migrated storage from their primary.
+func (b *backend) initialize(s logical.Storage) error {
+	if s == nil {
+		return nil
+	}
+	repState := b.System().ReplicationState()
+	if repState.HasState(consts.ReplicationPerformanceSecondary) || repState.HasState(consts.ReplicationDRSecondary) {
+		return nil
+	}
+
+	return b.migrateCABundle(s)
+}
+
+// periodicFunc is invoked once a minute by the rollback manager of the active
+// node. It rebuilds the CRLs about to expire and the delta CRLs, and runs the
+// automatic tidy.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	// The storage of DR secondaries is replicated from the primary, and
+	// there is nothing left to clean up once the mount is tainted
+	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary) || b.System().Tainted() {
+		return nil
+	}
+
+	var result error
+	if err := b.rebuildExpiringCRLs(req); err != nil {
+		result = multierror.Append(result, fmt.Errorf("error rebuilding CRLs: %s", err))
+	}
+	if err := b.rebuildDeltaCRLs(req); err != nil {
+		result = multierror.Append(result, fmt.Errorf("error rebuilding delta CRLs: %s", err))
+	}
+	if err := b.autoTidy(req); err != nil {
+		result = multierror.Append(result, fmt.Errorf("error running auto-tidy: %s", err))
+	}
+
+	return result
 }
 
 const backendHelp = `
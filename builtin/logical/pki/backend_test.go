@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -2692,7 +2693,7 @@ func TestBackend_SignSelfIssued(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	signingBundle, err := fetchCAInfo(&logical.Request{Storage: storage})
+	signingBundle, err := fetchCAInfo(&logical.Request{Storage: storage}, defaultRef)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2839,3 +2840,143 @@ O30prQ/sanDzNAeJhftoGtNPJDspwx0fzclHvKIhgl3JVUc=
 -----END CERTIFICATE-----
 `
 )
+
+func TestBackend_Ed25519(t *testing.T) {
+	config := logical.TestBackendConfig()
+	storage := &logical.InmemStorage{}
+	config.StorageView = storage
+
+	b := Backend()
+	err := b.Setup(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if resp != nil && resp.IsError() {
+			t.Fatalf("path %s: bad: %#v", path, *resp)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	parseCert := func(resp *logical.Response) *x509.Certificate {
+		pemBlock, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		if pemBlock == nil {
+			t.Fatal("no certificate in response")
+		}
+		cert, err := x509.ParseCertificate(pemBlock.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	// Generate an Ed25519 root
+	resp := doReq("root/generate/internal", map[string]interface{}{
+		"common_name": "test.com",
+		"key_type":    "ed25519",
+		"ttl":         "172800",
+	})
+	rootCert := parseCert(resp)
+	if rootCert.PublicKeyAlgorithm != x509.Ed25519 || rootCert.SignatureAlgorithm != x509.PureEd25519 {
+		t.Fatalf("bad root algorithms: %v, %v", rootCert.PublicKeyAlgorithm, rootCert.SignatureAlgorithm)
+	}
+
+	// Issue an Ed25519 certificate from an Ed25519 role
+	doReq("roles/ed", map[string]interface{}{
+		"allowed_domains":  "test.com",
+		"allow_subdomains": true,
+		"key_type":         "ed25519",
+		"ttl":              "4h",
+	})
+	resp = doReq("issue/ed", map[string]interface{}{
+		"common_name": "foo.test.com",
+	})
+	if resp.Data["private_key_type"] != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad private key type: %v", resp.Data["private_key_type"])
+	}
+	parsedBundle, err := certutil.ParsePKIMap(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parsedBundle.PrivateKey.(ed25519.PrivateKey); !ok {
+		t.Fatalf("bad private key: %T", parsedBundle.PrivateKey)
+	}
+	if err := parsedBundle.Certificate.CheckSignatureFrom(rootCert); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign an Ed25519 CSR
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: "bar.test.com",
+		},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemCSR := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr,
+	}))
+	resp = doReq("sign/ed", map[string]interface{}{
+		"csr":         pemCSR,
+		"common_name": "bar.test.com",
+	})
+	if cert := parseCert(resp); cert.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Fatalf("bad public key algorithm: %v", cert.PublicKeyAlgorithm)
+	}
+	doReq("sign-verbatim", map[string]interface{}{
+		"csr": pemCSR,
+	})
+
+	// Roles requiring other key types reject Ed25519 CSRs
+	doReq("roles/rsa", map[string]interface{}{
+		"allowed_domains":  "test.com",
+		"allow_subdomains": true,
+	})
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/rsa",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"csr":         pemCSR,
+			"common_name": "bar.test.com",
+		},
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected an error signing an Ed25519 CSR with an RSA role")
+	}
+
+	// Generate an Ed25519 intermediate CSR
+	resp = doReq("intermediate/generate/exported", map[string]interface{}{
+		"common_name": "intermediate.test.com",
+		"key_type":    "ed25519",
+	})
+	if resp.Data["private_key_type"] != certutil.Ed25519PrivateKey {
+		t.Fatalf("bad private key type: %v", resp.Data["private_key_type"])
+	}
+	csrBundle := &certutil.CSRBundle{
+		CSR:        resp.Data["csr"].(string),
+		PrivateKey: resp.Data["private_key"].(string),
+	}
+	parsedCSRBundle, err := csrBundle.ToParsedCSRBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedCSRBundle.CSR.PublicKeyAlgorithm != x509.Ed25519 {
+		t.Fatalf("bad public key algorithm: %v", parsedCSRBundle.CSR.PublicKeyAlgorithm)
+	}
+}
@@ -3,6 +3,7 @@ package pki
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
@@ -12,7 +13,9 @@ import (
 	"encoding/pem"
 	"fmt"
 	"net"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,19 +38,28 @@ const (
 )
 
 type creationBundle struct {
-	CommonName     string
-	OU             []string
-	Organization   []string
-	DNSNames       []string
-	EmailAddresses []string
-	IPAddresses    []net.IP
-	IsCA           bool
-	KeyType        string
-	KeyBits        int
-	SigningBundle  *caInfoBundle
-	NotAfter       time.Time
-	KeyUsage       x509.KeyUsage
-	ExtKeyUsage    certExtKeyUsage
+	CommonName        string
+	OU                []string
+	Organization      []string
+	Country           []string
+	Locality          []string
+	Province          []string
+	StreetAddress     []string
+	PostalCode        []string
+	DNSNames          []string
+	EmailAddresses    []string
+	IPAddresses       []net.IP
+	URIs              []*url.URL
+	OtherSANs         []otherNameUTF8
+	IsCA              bool
+	KeyType           string
+	KeyBits           int
+	SigningBundle     *caInfoBundle
+	NotAfter          time.Time
+	KeyUsage          x509.KeyUsage
+	ExtKeyUsage       certExtKeyUsage
+	ExtKeyUsageOIDs   []asn1.ObjectIdentifier
+	PolicyIdentifiers []asn1.ObjectIdentifier
 
 	// Only used when signing a CA cert
 	UseCSRValues        bool
@@ -62,7 +74,8 @@ type creationBundle struct {
 
 type caInfoBundle struct {
 	certutil.ParsedCertBundle
-	URLs *urlEntries
+	URLs     *urlEntries
+	IssuerID string
 }
 
 func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
@@ -89,8 +102,190 @@ func (b *caInfoBundle) GetCAChain() []*certutil.CertBlock {
 var (
 	hostnameRegex                = regexp.MustCompile(`^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`)
 	oidExtensionBasicConstraints = []int{2, 5, 29, 19}
+	oidExtensionSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
 )
 
+// otherNameUTF8 is an otherName Subject Alternative Name holding a UTF-8
+// string, the only type of otherName value supported. It is written as
+// "<oid>;UTF8:<value>".
+type otherNameUTF8 struct {
+	OID   string
+	Value string
+}
+
+func (o otherNameUTF8) String() string {
+	return o.OID + ";UTF8:" + o.Value
+}
+
+// otherNameRaw is the ASN.1 structure of an otherName (RFC 5280 section
+// 4.2.1.6), whose value is explicitly tagged
+type otherNameRaw struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue
+}
+
+func parseOtherSAN(input string) (otherNameUTF8, error) {
+	splitInput := strings.SplitN(input, ";", 2)
+	if len(splitInput) != 2 {
+		return otherNameUTF8{}, fmt.Errorf("other SAN %q is not of the form <oid>;UTF8:<value>", input)
+	}
+	oid := strings.TrimSpace(splitInput[0])
+	if _, err := parseOID(oid); err != nil {
+		return otherNameUTF8{}, err
+	}
+	splitValue := strings.SplitN(splitInput[1], ":", 2)
+	if len(splitValue) != 2 {
+		return otherNameUTF8{}, fmt.Errorf("other SAN %q is not of the form <oid>;UTF8:<value>", input)
+	}
+	switch strings.ToUpper(splitValue[0]) {
+	case "UTF8", "UTF-8":
+	default:
+		return otherNameUTF8{}, fmt.Errorf("other SAN %q has unsupported type %q; only UTF8 is supported", input, splitValue[0])
+	}
+	return otherNameUTF8{
+		OID:   oid,
+		Value: splitValue[1],
+	}, nil
+}
+
+// parseOtherSANs parses a comma-separated list of other SANs
+func parseOtherSANs(input string) ([]otherNameUTF8, error) {
+	ret := []otherNameUTF8{}
+	for _, v := range strutil.ParseDedupAndSortStrings(input, ",") {
+		otherSAN, err := parseOtherSAN(v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, otherSAN)
+	}
+	return ret, nil
+}
+
+func parseOID(input string) (asn1.ObjectIdentifier, error) {
+	splitOID := strings.Split(input, ".")
+	if len(splitOID) < 2 {
+		return nil, fmt.Errorf("%q is not a valid OID", input)
+	}
+	oid := make(asn1.ObjectIdentifier, 0, len(splitOID))
+	for _, v := range splitOID {
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("%q is not a valid OID", input)
+		}
+		oid = append(oid, i)
+	}
+	return oid, nil
+}
+
+// parseOIDs parses a comma-separated list of OIDs
+func parseOIDs(input string) ([]asn1.ObjectIdentifier, error) {
+	ret := []asn1.ObjectIdentifier{}
+	for _, v := range strutil.ParseDedupAndSortStrings(input, ",") {
+		oid, err := parseOID(v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, oid)
+	}
+	return ret, nil
+}
+
+// getOtherSANsFromCSR returns the otherName SANs of a CSR, which the x509
+// package does not parse
+func getOtherSANsFromCSR(csr *x509.CertificateRequest) ([]otherNameUTF8, error) {
+	ret := []otherNameUTF8{}
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+
+		var seq asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &seq); err != nil || len(rest) != 0 {
+			return nil, fmt.Errorf("could not parse subject alternative names")
+		}
+		rest := seq.Bytes
+		for len(rest) > 0 {
+			var v asn1.RawValue
+			var err error
+			rest, err = asn1.Unmarshal(rest, &v)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse subject alternative names")
+			}
+			if v.Class != asn1.ClassContextSpecific || v.Tag != 0 {
+				continue
+			}
+
+			var other otherNameRaw
+			if _, err := asn1.UnmarshalWithParams(v.FullBytes, &other, "tag:0"); err != nil {
+				return nil, fmt.Errorf("could not parse other subject alternative name: %v", err)
+			}
+			var value string
+			if _, err := asn1.UnmarshalWithParams(other.Value.Bytes, &value, "utf8"); err != nil {
+				return nil, fmt.Errorf("could not parse other subject alternative name %s: only UTF8 values are supported", other.TypeID)
+			}
+			ret = append(ret, otherNameUTF8{
+				OID:   other.TypeID.String(),
+				Value: value,
+			})
+		}
+	}
+	return ret, nil
+}
+
+// marshalSANs builds the Subject Alternative Name extension. It is only
+// needed for otherName SANs, which the x509 package cannot encode.
+func marshalSANs(dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL, otherSANs []otherNameUTF8) (pkix.Extension, error) {
+	var rawValues []asn1.RawValue
+	for _, name := range dnsNames {
+		rawValues = append(rawValues, asn1.RawValue{Tag: 2, Class: asn1.ClassContextSpecific, Bytes: []byte(name)})
+	}
+	for _, email := range emailAddresses {
+		rawValues = append(rawValues, asn1.RawValue{Tag: 1, Class: asn1.ClassContextSpecific, Bytes: []byte(email)})
+	}
+	for _, rawIP := range ipAddresses {
+		ip := rawIP.To4()
+		if ip == nil {
+			ip = rawIP
+		}
+		rawValues = append(rawValues, asn1.RawValue{Tag: 7, Class: asn1.ClassContextSpecific, Bytes: ip})
+	}
+	for _, uri := range uris {
+		rawValues = append(rawValues, asn1.RawValue{Tag: 6, Class: asn1.ClassContextSpecific, Bytes: []byte(uri.String())})
+	}
+	for _, otherSAN := range otherSANs {
+		oid, err := parseOID(otherSAN.OID)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		value, err := asn1.MarshalWithParams(otherSAN.Value, "utf8")
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		otherName, err := asn1.MarshalWithParams(otherNameRaw{
+			TypeID: oid,
+			Value: asn1.RawValue{
+				Tag:        0,
+				Class:      asn1.ClassContextSpecific,
+				IsCompound: true,
+				Bytes:      value,
+			},
+		}, "tag:0")
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		rawValues = append(rawValues, asn1.RawValue{FullBytes: otherName})
+	}
+
+	value, err := asn1.Marshal(rawValues)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{
+		Id:    oidExtensionSubjectAltName,
+		Value: value,
+	}, nil
+}
+
 func oidInExtensions(oid asn1.ObjectIdentifier, extensions []pkix.Extension) bool {
 	for _, e := range extensions {
 		if e.Id.Equal(oid) {
@@ -133,6 +328,8 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 			return logical.ErrorResponse(fmt.Sprintf(
 				"unsupported bit length for EC key: %d", keyBits))
 		}
+	case "ed25519":
+		// Ed25519 keys have a fixed size; the bits are ignored
 	default:
 		return logical.ErrorResponse(fmt.Sprintf(
 			"unknown key type %s", keyType))
@@ -141,49 +338,6 @@ func validateKeyTypeLength(keyType string, keyBits int) *logical.Response {
 	return nil
 }
 
-// Fetches the CA info. Unlike other certificates, the CA info is stored
-// in the backend as a CertBundle, because we are storing its private key
-func fetchCAInfo(req *logical.Request) (*caInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get("config/ca_bundle")
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
-	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
-	}
-
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
-	}
-
-	parsedBundle, err := bundle.ToParsedCertBundle()
-	if err != nil {
-		return nil, errutil.InternalError{Err: err.Error()}
-	}
-
-	if parsedBundle.Certificate == nil {
-		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
-	}
-
-	caInfo := &caInfoBundle{*parsedBundle, nil}
-
-	entries, err := getURLs(req)
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
-	}
-	if entries == nil {
-		entries = &urlEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
-		}
-	}
-	caInfo.URLs = entries
-
-	return caInfo, nil
-}
-
 // Allows fetching certificates from the backend; it handles the slightly
 // separate pathing for CA, CRL, and revoked certificates.
 func fetchCertBySerial(req *logical.Request, prefix, serial string) (*logical.StorageEntry, error) {
@@ -200,10 +354,16 @@ func fetchCertBySerial(req *logical.Request, prefix, serial string) (*logical.St
 	case strings.HasPrefix(prefix, "revoked/"):
 		legacyPath = "revoked/" + colonSerial
 		path = "revoked/" + hyphenSerial
-	case serial == "ca":
-		path = "ca"
-	case serial == "crl":
-		path = "crl"
+	case serial == "delta_crl":
+		return fetchIssuerDeltaCRL(req, defaultRef)
+	case serial == "ca" || serial == "crl":
+		// These belong to the default issuer, or to the single CA of mounts
+		// predating issuers
+		certEntry, err = fetchIssuerBytes(req, defaultRef, serial == "crl")
+		if err != nil || certEntry != nil {
+			return certEntry, err
+		}
+		path = serial
 	default:
 		legacyPath = "certs/" + colonSerial
 		path = "certs/" + hyphenSerial
@@ -416,6 +576,58 @@ func validateNames(req *logical.Request, names []string, role *roleEntry) string
 	return ""
 }
 
+// validateURISANs verifies that the requested URI SANs match the globs
+// allowed by the role, returning the first one that does not
+func validateURISANs(uris []*url.URL, role *roleEntry) string {
+	allowed := strutil.ParseDedupAndSortStrings(role.AllowedURISANs, ",")
+	for _, uri := range uris {
+		valid := false
+		for _, pattern := range allowed {
+			if glob.Glob(pattern, uri.String()) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return uri.String()
+		}
+	}
+
+	return ""
+}
+
+// validateOtherSANs verifies that the requested otherName SANs are allowed by
+// the role, whose entries have the same form with a glob for the value, or
+// are "*" to allow any. The first one that is not allowed is returned.
+func validateOtherSANs(otherSANs []otherNameUTF8, role *roleEntry) (string, error) {
+	var allowed []otherNameUTF8
+	for _, v := range strutil.ParseDedupAndSortStrings(role.AllowedOtherSANs, ",") {
+		if v == "*" {
+			return "", nil
+		}
+		otherSAN, err := parseOtherSAN(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid allowed_other_sans in role: %v", err)
+		}
+		allowed = append(allowed, otherSAN)
+	}
+
+	for _, otherSAN := range otherSANs {
+		valid := false
+		for _, pattern := range allowed {
+			if pattern.OID == otherSAN.OID && glob.Glob(pattern.Value, otherSAN.Value) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return otherSAN.String(), nil
+		}
+	}
+
+	return "", nil
+}
+
 func generateCert(b *backend,
 	role *roleEntry,
 	signingBundle *caInfoBundle,
@@ -558,6 +770,17 @@ func signCert(b *backend,
 				pubKey.Params().BitSize)}
 		}
 
+	case "ed25519":
+		// Verify that the key matches the role type
+		if csr.PublicKeyAlgorithm != x509.Ed25519 {
+			return nil, errutil.UserError{Err: fmt.Sprintf(
+				"role requires keys of type %s",
+				role.KeyType)}
+		}
+		if _, ok := csr.PublicKey.(ed25519.PublicKey); !ok {
+			return nil, errutil.UserError{Err: "could not parse CSR's public key"}
+		}
+
 	case "any":
 		// We only care about running RSA < 2048 bit checks, so if not RSA
 		// break out
@@ -711,6 +934,73 @@ func generateCreationBundle(b *backend,
 		}
 	}
 
+	// Get and verify any URI SANs
+	uris := []*url.URL{}
+	var uriAltInt interface{}
+	{
+		if csr != nil && role.UseCSRSANs {
+			uris = csr.URIs
+		} else {
+			uriAltInt, ok = data.GetOk("uri_sans")
+			if ok {
+				for _, v := range strutil.ParseDedupAndSortStrings(uriAltInt.(string), ",") {
+					parsedURI, err := url.Parse(v)
+					if err != nil || parsedURI.Scheme == "" {
+						return nil, errutil.UserError{Err: fmt.Sprintf(
+							"the value '%s' is not a valid URI", v)}
+					}
+					uris = append(uris, parsedURI)
+				}
+			}
+		}
+
+		if len(uris) > 0 {
+			if role.AllowedURISANs == "" {
+				return nil, errutil.UserError{Err: fmt.Sprintf(
+					"URI Subject Alternative Names are not allowed in this role, but were provided some")}
+			}
+			if badURI := validateURISANs(uris, role); badURI != "" {
+				return nil, errutil.UserError{Err: fmt.Sprintf(
+					"URI Subject Alternative Name %s not allowed by this role", badURI)}
+			}
+		}
+	}
+
+	// Get and verify any otherName SANs
+	otherSANs := []otherNameUTF8{}
+	var otherAltInt interface{}
+	{
+		if csr != nil && role.UseCSRSANs {
+			otherSANs, err = getOtherSANsFromCSR(csr)
+			if err != nil {
+				return nil, errutil.UserError{Err: err.Error()}
+			}
+		} else {
+			otherAltInt, ok = data.GetOk("other_sans")
+			if ok {
+				otherSANs, err = parseOtherSANs(otherAltInt.(string))
+				if err != nil {
+					return nil, errutil.UserError{Err: err.Error()}
+				}
+			}
+		}
+
+		if len(otherSANs) > 0 {
+			if role.AllowedOtherSANs == "" {
+				return nil, errutil.UserError{Err: fmt.Sprintf(
+					"other Subject Alternative Names are not allowed in this role, but were provided some")}
+			}
+			badOtherSAN, err := validateOtherSANs(otherSANs, role)
+			if err != nil {
+				return nil, errutil.InternalError{Err: err.Error()}
+			}
+			if badOtherSAN != "" {
+				return nil, errutil.UserError{Err: fmt.Sprintf(
+					"other Subject Alternative Name %s not allowed by this role", badOtherSAN)}
+			}
+		}
+	}
+
 	// Set OU (organizationalUnit) values if specified in the role
 	ou := []string{}
 	{
@@ -727,6 +1017,19 @@ func generateCreationBundle(b *backend,
 		}
 	}
 
+	// Set the remaining subject values if specified in the role
+	parseSubjectValues := func(input string) []string {
+		if input == "" {
+			return []string{}
+		}
+		return strutil.RemoveDuplicates(strutil.ParseStringSlice(input, ","), false)
+	}
+	country := parseSubjectValues(role.Country)
+	locality := parseSubjectValues(role.Locality)
+	province := parseSubjectValues(role.Province)
+	streetAddress := parseSubjectValues(role.StreetAddress)
+	postalCode := parseSubjectValues(role.PostalCode)
+
 	// Get the TTL and verify it against the max allowed
 	var ttl time.Duration
 	var maxTTL time.Duration
@@ -791,19 +1094,38 @@ func generateCreationBundle(b *backend,
 		}
 	}
 
+	// These were validated when the role was written
+	extUsageOIDs, err := parseOIDs(role.ExtKeyUsageOIDs)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("invalid ext_key_usage_oids in role: %v", err)}
+	}
+	policyIdentifiers, err := parseOIDs(role.PolicyIdentifiers)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("invalid policy_identifiers in role: %v", err)}
+	}
+
 	creationBundle := &creationBundle{
-		CommonName:     cn,
-		OU:             ou,
-		Organization:   organization,
-		DNSNames:       dnsNames,
-		EmailAddresses: emailAddresses,
-		IPAddresses:    ipAddresses,
-		KeyType:        role.KeyType,
-		KeyBits:        role.KeyBits,
-		SigningBundle:  signingBundle,
-		NotAfter:       notAfter,
-		KeyUsage:       x509.KeyUsage(parseKeyUsages(role.KeyUsage)),
-		ExtKeyUsage:    extUsage,
+		CommonName:        cn,
+		OU:                ou,
+		Organization:      organization,
+		Country:           country,
+		Locality:          locality,
+		Province:          province,
+		StreetAddress:     streetAddress,
+		PostalCode:        postalCode,
+		DNSNames:          dnsNames,
+		EmailAddresses:    emailAddresses,
+		IPAddresses:       ipAddresses,
+		URIs:              uris,
+		OtherSANs:         otherSANs,
+		KeyType:           role.KeyType,
+		KeyBits:           role.KeyBits,
+		SigningBundle:     signingBundle,
+		NotAfter:          notAfter,
+		KeyUsage:          x509.KeyUsage(parseKeyUsages(role.KeyUsage)),
+		ExtKeyUsage:       extUsage,
+		ExtKeyUsageOIDs:   extUsageOIDs,
+		PolicyIdentifiers: policyIdentifiers,
 	}
 
 	// Don't deal with URLs or max path length if it's self-signed, as these
@@ -860,6 +1182,37 @@ func addKeyUsages(creationInfo *creationBundle, certTemplate *x509.Certificate)
 	if creationInfo.ExtKeyUsage&emailProtectionExtKeyUsage != 0 {
 		certTemplate.ExtKeyUsage = append(certTemplate.ExtKeyUsage, x509.ExtKeyUsageEmailProtection)
 	}
+	certTemplate.UnknownExtKeyUsage = append(certTemplate.UnknownExtKeyUsage, creationInfo.ExtKeyUsageOIDs...)
+}
+
+// addSubjectAndSANs sets the subject values from the role, the SANs and the
+// policies of the template from the creation information
+func addSubjectAndSANs(creationInfo *creationBundle, certTemplate *x509.Certificate) error {
+	certTemplate.Subject.Country = creationInfo.Country
+	certTemplate.Subject.Locality = creationInfo.Locality
+	certTemplate.Subject.Province = creationInfo.Province
+	certTemplate.Subject.StreetAddress = creationInfo.StreetAddress
+	certTemplate.Subject.PostalCode = creationInfo.PostalCode
+
+	certTemplate.DNSNames = creationInfo.DNSNames
+	certTemplate.EmailAddresses = creationInfo.EmailAddresses
+	certTemplate.IPAddresses = creationInfo.IPAddresses
+	certTemplate.URIs = creationInfo.URIs
+
+	// The x509 package does not encode otherName SANs, so the extension is
+	// built here, in which case the package leaves it alone
+	if len(creationInfo.OtherSANs) > 0 {
+		sanExt, err := marshalSANs(creationInfo.DNSNames, creationInfo.EmailAddresses,
+			creationInfo.IPAddresses, creationInfo.URIs, creationInfo.OtherSANs)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error marshaling subject alternative names: %v", err)}
+		}
+		certTemplate.ExtraExtensions = append(certTemplate.ExtraExtensions, sanExt)
+	}
+
+	certTemplate.PolicyIdentifiers = creationInfo.PolicyIdentifiers
+
+	return nil
 }
 
 // Performs the heavy lifting of creating a certificate. Returns
@@ -891,15 +1244,15 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 	}
 
 	certTemplate := &x509.Certificate{
-		SerialNumber:   serialNumber,
-		Subject:        subject,
-		NotBefore:      time.Now().Add(-30 * time.Second),
-		NotAfter:       creationInfo.NotAfter,
-		IsCA:           false,
-		SubjectKeyId:   subjKeyID,
-		DNSNames:       creationInfo.DNSNames,
-		EmailAddresses: creationInfo.EmailAddresses,
-		IPAddresses:    creationInfo.IPAddresses,
+		SerialNumber: serialNumber,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     creationInfo.NotAfter,
+		IsCA:         false,
+		SubjectKeyId: subjKeyID,
+	}
+	if err := addSubjectAndSANs(creationInfo, certTemplate); err != nil {
+		return nil, err
 	}
 
 	// Add this before calling addKeyUsages
@@ -926,6 +1279,8 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case certutil.ECPrivateKey:
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case certutil.Ed25519PrivateKey:
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		caCert := creationInfo.SigningBundle.Certificate
@@ -951,6 +1306,8 @@ func createCertificate(creationInfo *creationBundle) (*certutil.ParsedCertBundle
 			certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 		case "ec":
 			certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+		case "ed25519":
+			certTemplate.SignatureAlgorithm = x509.PureEd25519
 		}
 
 		certTemplate.AuthorityKeyId = subjKeyID
@@ -1014,6 +1371,8 @@ func createCSR(creationInfo *creationBundle) (*certutil.ParsedCSRBundle, error)
 		csrTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case "ec":
 		csrTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case "ed25519":
+		csrTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, result.PrivateKey)
@@ -1083,6 +1442,8 @@ func signCertificate(creationInfo *creationBundle,
 		certTemplate.SignatureAlgorithm = x509.SHA256WithRSA
 	case certutil.ECPrivateKey:
 		certTemplate.SignatureAlgorithm = x509.ECDSAWithSHA256
+	case certutil.Ed25519PrivateKey:
+		certTemplate.SignatureAlgorithm = x509.PureEd25519
 	}
 
 	if creationInfo.UseCSRValues {
@@ -1091,12 +1452,13 @@ func signCertificate(creationInfo *creationBundle,
 		certTemplate.DNSNames = csr.DNSNames
 		certTemplate.EmailAddresses = csr.EmailAddresses
 		certTemplate.IPAddresses = csr.IPAddresses
+		certTemplate.URIs = csr.URIs
 
 		certTemplate.ExtraExtensions = csr.Extensions
 	} else {
-		certTemplate.DNSNames = creationInfo.DNSNames
-		certTemplate.EmailAddresses = creationInfo.EmailAddresses
-		certTemplate.IPAddresses = creationInfo.IPAddresses
+		if err := addSubjectAndSANs(creationInfo, certTemplate); err != nil {
+			return nil, err
+		}
 	}
 
 	addKeyUsages(creationInfo, certTemplate)
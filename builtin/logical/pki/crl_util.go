@@ -1,10 +1,11 @@
 package pki
 
 import (
-	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/hashicorp/vault/helper/errutil"
@@ -15,6 +16,7 @@ type revocationInfo struct {
 	CertificateBytes  []byte    `json:"certificate_bytes"`
 	RevocationTime    int64     `json:"revocation_time"`
 	RevocationTimeUTC time.Time `json:"revocation_time_utc"`
+	IssuerID          string    `json:"issuer_id"`
 }
 
 // Revokes a cert, and tries to be smart about error recovery
@@ -81,10 +83,16 @@ func revokeCert(b *backend, req *logical.Request, serial string, fromLease bool)
 			return nil, nil
 		}
 
+		issuers, err := fetchAllIssuers(req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
 		currTime := time.Now()
 		revInfo.CertificateBytes = certEntry.Value
 		revInfo.RevocationTime = currTime.Unix()
 		revInfo.RevocationTimeUTC = currTime.UTC()
+		revInfo.IssuerID = issuerOfCert(cert, issuers)
 
 		revEntry, err = logical.StorageEntryJSON("revoked/"+normalizeSerial(serial), revInfo)
 		if err != nil {
@@ -98,12 +106,20 @@ func revokeCert(b *backend, req *logical.Request, serial string, fromLease bool)
 
 	}
 
-	crlErr := buildCRL(b, req)
-	switch crlErr.(type) {
-	case errutil.UserError:
-		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
-	case errutil.InternalError:
-		return nil, fmt.Errorf("Error encountered during CRL building: %s", crlErr)
+	// With delta CRLs, the revocation is listed in the next delta CRLs and
+	// the full CRLs are left until they are rebuilt
+	crlInfo, err := b.CRL(req.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching CRL config information: %s", err)
+	}
+	if crlInfo == nil || !crlInfo.EnableDelta {
+		crlErr := buildCRL(b, req)
+		switch crlErr.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+		case errutil.InternalError:
+			return nil, fmt.Errorf("Error encountered during CRL building: %s", crlErr)
+		}
 	}
 
 	resp := &logical.Response{
@@ -117,39 +133,249 @@ func revokeCert(b *backend, req *logical.Request, serial string, fromLease bool)
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
-func buildCRL(b *backend, req *logical.Request) error {
+var (
+	oidExtensionAuthorityKeyID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidExtensionFreshestCRL       = asn1.ObjectIdentifier{2, 5, 29, 46}
+)
+
+// crlState tracks the numbering of the CRLs of an issuer. Full and delta CRLs
+// share a single sequence of numbers, as required by RFC 5280 section 5.2.3.
+type crlState struct {
+	// NextNumber is the number of the next CRL built, full or delta
+	NextNumber int64 `json:"next_number"`
+
+	// BaseNumber and BaseTime are the number and build time of the last full
+	// CRL. Delta CRLs refer to it and list the certificates revoked since.
+	BaseNumber int64     `json:"base_number"`
+	BaseTime   time.Time `json:"base_time"`
+
+	// DeltaBaseNumber and DeltaTime are the base and build time of the last
+	// delta CRL
+	DeltaBaseNumber int64     `json:"delta_base_number"`
+	DeltaTime       time.Time `json:"delta_time"`
+}
+
+func getCRLState(s logical.Storage, id string) (*crlState, error) {
+	entry, err := s.Get(issuerCRLStatePrefix + id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL state of issuer %s: %s", id, err)}
+	}
+	// CRLs built before numbering was tracked carry no number
+	state := &crlState{
+		NextNumber: 1,
+	}
+	if entry == nil {
+		return state, nil
+	}
+	if err := entry.DecodeJSON(state); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("Error decoding CRL state of issuer %s: %s", id, err)}
+	}
+	return state, nil
+}
+
+func putCRLState(s logical.Storage, id string, state *crlState) error {
+	entry, err := logical.StorageEntryJSON(issuerCRLStatePrefix+id, state)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error creating CRL state of issuer %s: %s", id, err)}
+	}
+	if err := s.Put(entry); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL state of issuer %s: %s", id, err)}
+	}
+	return nil
+}
+
+// The following types are the parts of the ASN.1 structures of RFC 5280 that
+// pkix does not define, or not precisely enough to preserve the encoding of
+// the issuer name.
+
+type certificateList struct {
+	TBSCertList        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertList struct {
+	Version             int `asn1:"optional,default:0"`
+	Signature           pkix.AlgorithmIdentifier
+	Issuer              asn1.RawValue
+	ThisUpdate          time.Time
+	NextUpdate          time.Time                 `asn1:"optional"`
+	RevokedCertificates []pkix.RevokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension          `asn1:"tag:0,optional,explicit"`
+}
+
+type authorityKeyID struct {
+	ID []byte `asn1:"optional,tag:0"`
+}
+
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// crlParams holds what a CRL is made of beyond the revoked certificates
+type crlParams struct {
+	number     int64
+	thisUpdate time.Time
+	nextUpdate time.Time
+
+	// baseNumber is the number of the full CRL a delta CRL refers to, or
+	// zero for a full CRL
+	baseNumber int64
+
+	// freshestCRL lists where the delta CRLs of a full CRL are found
+	freshestCRL []string
+}
+
+// createCRL returns a DER encoded v2 CRL signed by the CA of the bundle,
+// numbered and, for delta CRLs, marked with the Delta CRL Indicator
+func createCRL(bundle *caInfoBundle, revokedCerts []pkix.RevokedCertificate, params crlParams) ([]byte, error) {
+	number, err := asn1.Marshal(big.NewInt(params.number))
+	if err != nil {
+		return nil, err
+	}
+	extensions := []pkix.Extension{
+		{
+			Id:    oidExtensionCRLNumber,
+			Value: number,
+		},
+	}
+
+	if len(bundle.Certificate.SubjectKeyId) > 0 {
+		aki, err := asn1.Marshal(authorityKeyID{ID: bundle.Certificate.SubjectKeyId})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{
+			Id:    oidExtensionAuthorityKeyID,
+			Value: aki,
+		})
+	}
+
+	if params.baseNumber != 0 {
+		baseNumber, err := asn1.Marshal(big.NewInt(params.baseNumber))
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{
+			Id:       oidExtensionDeltaCRLIndicator,
+			Critical: true,
+			Value:    baseNumber,
+		})
+	}
+
+	if len(params.freshestCRL) > 0 {
+		var point distributionPoint
+		for _, url := range params.freshestCRL {
+			point.DistributionPoint.FullName = append(point.DistributionPoint.FullName, asn1.RawValue{
+				Class: asn1.ClassContextSpecific,
+				Tag:   6, // uniformResourceIdentifier
+				Bytes: []byte(url),
+			})
+		}
+		freshestCRL, err := asn1.Marshal([]distributionPoint{point})
+		if err != nil {
+			return nil, err
+		}
+		extensions = append(extensions, pkix.Extension{
+			Id:    oidExtensionFreshestCRL,
+			Value: freshestCRL,
+		})
+	}
+
+	sigAlgorithm, _, err := signatureAlgorithm(bundle.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	// Revocation times must be expressed in UTC
+	revoked := make([]pkix.RevokedCertificate, 0, len(revokedCerts))
+	for _, revokedCert := range revokedCerts {
+		revokedCert.RevocationTime = revokedCert.RevocationTime.UTC()
+		revoked = append(revoked, revokedCert)
+	}
+
+	tbs, err := asn1.Marshal(tbsCertList{
+		Version:             1,
+		Signature:           sigAlgorithm,
+		Issuer:              asn1.RawValue{FullBytes: bundle.Certificate.RawSubject},
+		ThisUpdate:          params.thisUpdate.UTC(),
+		NextUpdate:          params.nextUpdate.UTC(),
+		RevokedCertificates: revoked,
+		Extensions:          extensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, signature, err := signTBS(bundle.PrivateKey, tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(certificateList{
+		TBSCertList:        asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: sigAlgorithm,
+		SignatureValue: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
+}
+
+// fetchRevokedCerts goes through the list of revoked certificates and returns
+// them grouped by the ID of their issuer, with their stored revocation times
+func fetchRevokedCerts(req *logical.Request, issuers []*issuerEntry) (map[string][]pkix.RevokedCertificate, error) {
 	revokedSerials, err := req.Storage.List("revoked/")
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("Error fetching list of revoked certs: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("Error fetching list of revoked certs: %s", err)}
 	}
 
-	revokedCerts := []pkix.RevokedCertificate{}
-	var revInfo revocationInfo
+	revokedCerts := map[string][]pkix.RevokedCertificate{}
 	for _, serial := range revokedSerials {
 		revokedEntry, err := req.Storage.Get("revoked/" + serial)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Unable to fetch revoked cert with serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Unable to fetch revoked cert with serial %s: %s", serial, err)}
 		}
 		if revokedEntry == nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Revoked certificate entry for serial %s is nil", serial)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Revoked certificate entry for serial %s is nil", serial)}
 		}
 		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
 			// TODO: In this case, remove it and continue? How likely is this to
 			// happen? Alternately, could skip it entirely, or could implement a
 			// delete function so that there is a way to remove these
-			return errutil.InternalError{Err: fmt.Sprintf("Found revoked serial but actual certificate is empty")}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Found revoked serial but actual certificate is empty")}
 		}
 
+		var revInfo revocationInfo
 		err = revokedEntry.DecodeJSON(&revInfo)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Error decoding revocation entry for serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Error decoding revocation entry for serial %s: %s", serial, err)}
 		}
 
 		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+			return nil, errutil.InternalError{Err: fmt.Sprintf("Unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+		}
+
+		// Certificates revoked before multiple issuers were supported do not
+		// record their issuer; look it up once and save it
+		if revInfo.IssuerID == "" {
+			revInfo.IssuerID = issuerOfCert(revokedCert, issuers)
+			if revInfo.IssuerID != "" {
+				revokedEntry, err = logical.StorageEntryJSON("revoked/"+serial, revInfo)
+				if err != nil {
+					return nil, errutil.InternalError{Err: fmt.Sprintf("Error creating revocation entry for serial %s: %s", serial, err)}
+				}
+				if err := req.Storage.Put(revokedEntry); err != nil {
+					return nil, errutil.InternalError{Err: fmt.Sprintf("Error saving revocation entry for serial %s: %s", serial, err)}
+				}
+			}
 		}
 
 		// NOTE: We have to change this to UTC time because the CRL standard
@@ -162,42 +388,297 @@ func buildCRL(b *backend, req *logical.Request) error {
 		} else {
 			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
+		revokedCerts[revInfo.IssuerID] = append(revokedCerts[revInfo.IssuerID], newRevCert)
+	}
+
+	return revokedCerts, nil
+}
+
+// revokedSince returns the certificates revoked at or after the given time
+func revokedSince(revokedCerts []pkix.RevokedCertificate, since time.Time) []pkix.RevokedCertificate {
+	var ret []pkix.RevokedCertificate
+	for _, revokedCert := range revokedCerts {
+		if !revokedCert.RevocationTime.Before(since) {
+			ret = append(ret, revokedCert)
+		}
+	}
+	return ret
+}
+
+// crlLifetimeOf returns the configured validity period of CRLs
+func (b *backend) crlLifetimeOf(crlInfo *crlConfig) (time.Duration, error) {
+	if crlInfo == nil {
+		return b.crlLifetime, nil
+	}
+	crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	if err != nil {
+		return 0, errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL duration of %s", crlInfo.Expiry)}
+	}
+	return crlDur, nil
+}
+
+// Builds the CRLs of all issuers with a key by going through the list of
+// revoked certificates and building new CRLs with the stored revocation times
+// and serial numbers. Revoked certificates are listed in the CRL of the
+// issuer that signed them. When delta CRLs are enabled, new delta CRLs,
+// empty as of yet, are built along with the full ones.
+func buildCRL(b *backend, req *logical.Request) error {
+	b.crlBuildLock.Lock()
+	defer b.crlBuildLock.Unlock()
+
+	issuers, err := fetchAllIssuers(req.Storage)
+	if err != nil {
+		return err
 	}
 
-	signingBundle, caErr := fetchCAInfo(req)
-	switch caErr.(type) {
-	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("Could not fetch the CA certificate: %s", caErr)}
-	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CA certificate: %s", caErr)}
+	revokedCerts, err := fetchRevokedCerts(req, issuers)
+	if err != nil {
+		return err
 	}
 
-	crlLifetime := b.crlLifetime
 	crlInfo, err := b.CRL(req.Storage)
 	if err != nil {
 		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
 	}
-	if crlInfo != nil {
-		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+	crlLifetime, err := b.crlLifetimeOf(crlInfo)
+	if err != nil {
+		return err
+	}
+	enableDelta := crlInfo != nil && crlInfo.EnableDelta
+
+	var freshestCRL []string
+	if enableDelta {
+		urls, err := getURLs(req)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL duration of %s", crlInfo.Expiry)}
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching URLs: %s", err)}
+		}
+		if urls != nil {
+			freshestCRL = urls.DeltaCRLDistributionPoints
 		}
-		crlLifetime = crlDur
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	built := false
+	for _, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
+		}
+		built = true
+
+		signingBundle, caErr := fetchCAInfoByID(req, issuer.ID)
+		switch caErr.(type) {
+		case errutil.UserError:
+			return errutil.UserError{Err: fmt.Sprintf("Could not fetch the CA certificate of issuer %s: %s", issuer.ID, caErr)}
+		case errutil.InternalError:
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching CA certificate of issuer %s: %s", issuer.ID, caErr)}
+		}
+
+		state, err := getCRLState(req.Storage, issuer.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		crlBytes, err := createCRL(signingBundle, revokedCerts[issuer.ID], crlParams{
+			number:      state.NextNumber,
+			thisUpdate:  now,
+			nextUpdate:  now.Add(crlLifetime),
+			freshestCRL: freshestCRL,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error creating new CRL: %s", err)}
+		}
+
+		err = req.Storage.Put(&logical.StorageEntry{
+			Key:   issuerCRLPrefix + issuer.ID,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL: %s", err)}
+		}
+
+		state.BaseNumber = state.NextNumber
+		state.BaseTime = now
+		state.NextNumber++
+
+		if enableDelta {
+			if err := writeDeltaCRL(req, signingBundle, state, nil, now, crlLifetime); err != nil {
+				return err
+			}
+		}
+
+		if err := putCRLState(req.Storage, issuer.ID, state); err != nil {
+			return err
+		}
+	}
+	if !built {
+		return errutil.UserError{Err: "Could not fetch the CA certificate: backend must be configured with a CA certificate/key"}
+	}
+
+	return nil
+}
+
+// writeDeltaCRL builds and stores the delta CRL of an issuer, relative to its
+// last full CRL, updating its CRL state. The state is left for the caller to
+// store.
+func writeDeltaCRL(req *logical.Request, bundle *caInfoBundle, state *crlState, revokedCerts []pkix.RevokedCertificate, now time.Time, crlLifetime time.Duration) error {
+	crlBytes, err := createCRL(bundle, revokedCerts, crlParams{
+		number:     state.NextNumber,
+		thisUpdate: now,
+		nextUpdate: now.Add(crlLifetime),
+		baseNumber: state.BaseNumber,
+	})
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("Error creating new CRL: %s", err)}
+		return errutil.InternalError{Err: fmt.Sprintf("Error creating new delta CRL: %s", err)}
 	}
 
 	err = req.Storage.Put(&logical.StorageEntry{
-		Key:   "crl",
+		Key:   issuerDeltaCRLPrefix + bundle.IssuerID,
 		Value: crlBytes,
 	})
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("Error storing CRL: %s", err)}
+		return errutil.InternalError{Err: fmt.Sprintf("Error storing delta CRL: %s", err)}
 	}
 
+	state.DeltaBaseNumber = state.BaseNumber
+	state.DeltaTime = now
+	state.NextNumber++
+
 	return nil
 }
+
+// rebuildDeltaCRLs rebuilds, when delta CRLs are enabled and their rebuild
+// interval has passed, the delta CRLs of the issuers having revoked
+// certificates since their last delta CRL
+func (b *backend) rebuildDeltaCRLs(req *logical.Request) error {
+	crlInfo, err := b.CRL(req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
+	}
+	if crlInfo == nil || !crlInfo.EnableDelta {
+		return nil
+	}
+
+	interval, err := time.ParseDuration(crlInfo.DeltaRebuildInterval)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error parsing delta CRL rebuild interval of %s", crlInfo.DeltaRebuildInterval)}
+	}
+	crlLifetime, err := b.crlLifetimeOf(crlInfo)
+	if err != nil {
+		return err
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+	b.crlBuildLock.Lock()
+	defer b.crlBuildLock.Unlock()
+
+	if !b.lastDeltaRebuild.IsZero() && time.Now().Before(b.lastDeltaRebuild.Add(interval)) {
+		return nil
+	}
+
+	issuers, err := fetchAllIssuers(req.Storage)
+	if err != nil {
+		return err
+	}
+
+	revokedCerts, err := fetchRevokedCerts(req, issuers)
+	if err != nil {
+		return err
+	}
+
+	for _, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
+		}
+
+		state, err := getCRLState(req.Storage, issuer.ID)
+		if err != nil {
+			return err
+		}
+		// Issuers without a numbered full CRL get one on their next rebuild
+		if state.BaseNumber == 0 {
+			continue
+		}
+
+		delta := revokedSince(revokedCerts[issuer.ID], state.BaseTime)
+		if state.DeltaBaseNumber == state.BaseNumber && len(revokedSince(delta, state.DeltaTime)) == 0 {
+			continue
+		}
+
+		signingBundle, caErr := fetchCAInfoByID(req, issuer.ID)
+		switch caErr.(type) {
+		case errutil.UserError:
+			return errutil.UserError{Err: fmt.Sprintf("Could not fetch the CA certificate of issuer %s: %s", issuer.ID, caErr)}
+		case errutil.InternalError:
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching CA certificate of issuer %s: %s", issuer.ID, caErr)}
+		}
+
+		if err := writeDeltaCRL(req, signingBundle, state, delta, time.Now(), crlLifetime); err != nil {
+			return err
+		}
+		if err := putCRLState(req.Storage, issuer.ID, state); err != nil {
+			return err
+		}
+	}
+
+	b.lastDeltaRebuild = time.Now()
+
+	return nil
+}
+
+// rebuildExpiringCRLs rebuilds the CRLs when automatic rebuilding is enabled
+// and the CRL of an issuer with a key is missing or expires within the grace
+// period
+func (b *backend) rebuildExpiringCRLs(req *logical.Request) error {
+	crlInfo, err := b.CRL(req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL config information: %s", err)}
+	}
+	if crlInfo == nil || !crlInfo.AutoRebuild {
+		return nil
+	}
+
+	gracePeriod, err := time.ParseDuration(crlInfo.AutoRebuildGracePeriod)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL rebuild grace period of %s", crlInfo.AutoRebuildGracePeriod)}
+	}
+
+	issuers, err := fetchAllIssuers(req.Storage)
+	if err != nil {
+		return err
+	}
+
+	rebuildBefore := time.Now().Add(gracePeriod)
+	rebuild := false
+	for _, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
+		}
+
+		crlEntry, err := req.Storage.Get(issuerCRLPrefix + issuer.ID)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error fetching CRL of issuer %s: %s", issuer.ID, err)}
+		}
+		if crlEntry == nil || len(crlEntry.Value) == 0 {
+			rebuild = true
+			break
+		}
+
+		crl, err := x509.ParseDERCRL(crlEntry.Value)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("Error parsing CRL of issuer %s: %s", issuer.ID, err)}
+		}
+		if crl.TBSCertList.NextUpdate.Before(rebuildBefore) {
+			rebuild = true
+			break
+		}
+	}
+	if !rebuild {
+		return nil
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	return buildCRL(b, req)
+}
@@ -0,0 +1,207 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_DeltaCRL(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	caBlock, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deltaURL := "http://myvault.com/v1/pki/crl/delta"
+	doReq(logical.UpdateOperation, "config/urls", map[string]interface{}{
+		"delta_crl_distribution_points": deltaURL,
+	})
+
+	// fetchCRL returns the parsed CRL served at a raw path, checking its
+	// signature and its number
+	fetchCRL := func(path string) ([]byte, *x509.RevocationList) {
+		resp := doReq(logical.ReadOperation, path, nil)
+		der := resp.Data[logical.HTTPRawBody].([]byte)
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := crl.CheckSignatureFrom(ca); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if crl.Number == nil {
+			t.Fatalf("%s: CRL has no number", path)
+		}
+		return der, crl
+	}
+	deltaBase := func(crl *x509.RevocationList) *big.Int {
+		for _, ext := range crl.Extensions {
+			if ext.Id.Equal(oidExtensionDeltaCRLIndicator) {
+				if !ext.Critical {
+					t.Fatal("delta CRL indicator is not critical")
+				}
+				base := new(big.Int)
+				if _, err := asn1.Unmarshal(ext.Value, &base); err != nil {
+					t.Fatal(err)
+				}
+				return base
+			}
+		}
+		return nil
+	}
+	periodic := func() {
+		// Skip the rebuild interval
+		b.lastDeltaRebuild = time.Time{}
+		if err := b.periodicFunc(&logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Full CRLs are numbered even without delta CRLs
+	_, full := fetchCRL("crl")
+	if full.Number.Int64() != 1 || deltaBase(full) != nil {
+		t.Fatalf("bad: full CRL number %v", full.Number)
+	}
+	resp = doReq(logical.ReadOperation, "crl/delta", nil)
+	if resp.Data[logical.HTTPStatusCode] != 204 {
+		t.Fatalf("expected no delta CRL, got: %#v", resp.Data)
+	}
+
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/crl",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enable_delta": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error enabling delta CRLs without auto_rebuild, got: err: %v resp: %#v", err, resp)
+	}
+
+	// Enabling delta CRLs rebuilds the full CRLs to point to them
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"auto_rebuild":           true,
+		"enable_delta":           true,
+		"delta_rebuild_interval": "5m",
+	})
+	resp = doReq(logical.ReadOperation, "config/crl", nil)
+	if resp.Data["enable_delta"] != true || resp.Data["delta_rebuild_interval"] != "5m" {
+		t.Fatalf("bad: CRL config: %#v", resp.Data)
+	}
+
+	fullDER, full := fetchCRL("crl")
+	if full.Number.Int64() != 2 {
+		t.Fatalf("bad: full CRL number %v", full.Number)
+	}
+	freshest := false
+	for _, ext := range full.Extensions {
+		if ext.Id.Equal(oidExtensionFreshestCRL) {
+			freshest = bytes.Contains(ext.Value, []byte(deltaURL))
+		}
+	}
+	if !freshest {
+		t.Fatal("full CRL does not point to the delta CRL")
+	}
+
+	deltaDER, delta := fetchCRL("crl/delta")
+	if delta.Number.Int64() != 3 || deltaBase(delta).Int64() != 2 || len(delta.RevokedCertificateEntries) != 0 {
+		t.Fatalf("bad: delta CRL number %v base %v entries %d", delta.Number, deltaBase(delta), len(delta.RevokedCertificateEntries))
+	}
+
+	// Revocations go to the next delta CRL only
+	doReq(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "myvault.com",
+		"allow_subdomains": true,
+	})
+	resp = doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "foo.myvault.com",
+	})
+	serial := resp.Data["serial_number"].(string)
+	certBlock, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+
+	if der, _ := fetchCRL("crl"); !bytes.Equal(der, fullDER) {
+		t.Fatal("full CRL rebuilt on revocation")
+	}
+	if der, _ := fetchCRL("crl/delta"); !bytes.Equal(der, deltaDER) {
+		t.Fatal("delta CRL rebuilt before its interval")
+	}
+
+	periodic()
+	if der, _ := fetchCRL("crl"); !bytes.Equal(der, fullDER) {
+		t.Fatal("full CRL rebuilt outside of its grace period")
+	}
+	deltaDER, delta = fetchCRL("crl/delta")
+	if delta.Number.Int64() != 4 || deltaBase(delta).Int64() != 2 {
+		t.Fatalf("bad: delta CRL number %v base %v", delta.Number, deltaBase(delta))
+	}
+	if len(delta.RevokedCertificateEntries) != 1 || delta.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		t.Fatalf("bad: delta CRL entries: %#v", delta.RevokedCertificateEntries)
+	}
+
+	// Without new revocations, the delta CRL is left alone
+	periodic()
+	if der, _ := fetchCRL("crl/delta"); !bytes.Equal(der, deltaDER) {
+		t.Fatal("delta CRL rebuilt without new revocations")
+	}
+
+	// Rotating moves the revocation to the full CRL
+	doReq(logical.ReadOperation, "crl/rotate", nil)
+	_, full = fetchCRL("crl")
+	if full.Number.Int64() != 5 || len(full.RevokedCertificateEntries) != 1 {
+		t.Fatalf("bad: full CRL number %v entries %d", full.Number, len(full.RevokedCertificateEntries))
+	}
+	_, delta = fetchCRL("crl/delta")
+	if delta.Number.Int64() != 6 || deltaBase(delta).Int64() != 5 || len(delta.RevokedCertificateEntries) != 0 {
+		t.Fatalf("bad: delta CRL number %v base %v entries %d", delta.Number, deltaBase(delta), len(delta.RevokedCertificateEntries))
+	}
+
+	// The delta CRL is served through the other CRL paths as well
+	resp = doReq(logical.ReadOperation, "cert/delta-crl", nil)
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("bad: cert/delta-crl: %#v", resp.Data)
+	}
+	der, _ := fetchCRL("cert/issuer/default/crl/delta/der")
+	if !bytes.Equal(der, block.Bytes) {
+		t.Fatal("issuer delta CRL differs from the default one")
+	}
+	resp = doReq(logical.ReadOperation, "cert/issuer/default/crl/delta", nil)
+	block, _ = pem.Decode([]byte(resp.Data["crl"].(string)))
+	if block == nil || !bytes.Equal(der, block.Bytes) {
+		t.Fatalf("bad: issuer delta CRL: %#v", resp.Data)
+	}
+}
@@ -67,6 +67,18 @@ default TTL is used, in that order. Cannot
 be later than the role max TTL.`,
 	}
 
+	fields["uri_sans"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The requested URI SANs, if any, in a
+comma-delimited list`,
+	}
+
+	fields["other_sans"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `The requested other SANs, if any, in a
+comma-delimited list of <oid>;UTF8:<value>`,
+	}
+
 	return fields
 }
 
@@ -122,14 +134,14 @@ the private key!`,
 		Default: 2048,
 		Description: `The number of bits to use. You will almost
 certainly want to change this if you adjust
-the key_type.`,
+the key_type. Ignored for ed25519 keys.`,
 	}
 
 	fields["key_type"] = &framework.FieldSchema{
 		Type:    framework.TypeString,
 		Default: "rsa",
-		Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+		Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 	}
 
 	return fields
@@ -151,3 +163,32 @@ func addCAIssueFields(fields map[string]*framework.FieldSchema) map[string]*fram
 
 	return fields
 }
+
+// addIssuerRefField adds the field selecting the issuer signing certificates
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: defaultRef,
+		Description: `Reference to the issuer signing the
+certificate: "default", or the ID or name of
+an issuer. Defaults to the default issuer.`,
+	}
+
+	return fields
+}
+
+// addIssuerNameFields adds the fields naming the issuer and the key created
+// when generating a CA
+func addIssuerNameFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_name"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name of the new issuer.`,
+	}
+
+	fields["key_name"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name of the new key.`,
+	}
+
+	return fields
+}
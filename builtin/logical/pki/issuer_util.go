@@ -0,0 +1,742 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// defaultRef refers to the default issuer of the mount
+	defaultRef = "default"
+
+	issuerPrefix         = "issuer/"
+	keyPrefix            = "key/"
+	issuerCRLPrefix      = "crls/"
+	issuerDeltaCRLPrefix = "delta-crls/"
+	issuerCRLStatePrefix = "crl-state/"
+	issuersConfigPath    = "config/issuers"
+	legacyCABundlePath   = "config/ca_bundle"
+
+	// legacyMigrationLogPath holds the legacyMigrationLog
+	legacyMigrationLogPath = "config/legacy-migration-log"
+)
+
+// keyEntry is a private key of the mount, usable by one or more issuers
+type keyEntry struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	PrivateKeyType certutil.PrivateKeyType `json:"private_key_type"`
+	PrivateKey     string                  `json:"private_key"`
+}
+
+// issuerEntry is a CA certificate of the mount. Issuers without a key can be
+// fetched but cannot issue certificates or sign CRLs.
+type issuerEntry struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	KeyID        string   `json:"key_id"`
+	Certificate  string   `json:"certificate"`
+	CAChain      []string `json:"ca_chain"`
+	SerialNumber string   `json:"serial_number"`
+}
+
+// issuersConfig holds the mount-wide issuer configuration
+type issuersConfig struct {
+	DefaultIssuer string `json:"default"`
+}
+
+func (i *issuerEntry) parseCertificate() (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(i.Certificate))
+	if block == nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode certificate of issuer %s", i.ID)}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse certificate of issuer %s: %v", i.ID, err)}
+	}
+	return cert, nil
+}
+
+func (k *keyEntry) publicKey() (crypto.PublicKey, error) {
+	parsed, err := (&certutil.CertBundle{
+		PrivateKeyType: k.PrivateKeyType,
+		PrivateKey:     k.PrivateKey,
+	}).ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	return parsed.PrivateKey.Public(), nil
+}
+
+// legacyMigrationLog records the last migration of the legacy CA bundle. The
+// bundle is kept so that the mount can be downgraded; if it changes, for
+// instance because a downgraded Vault replaced the CA, it is migrated again.
+type legacyMigrationLog struct {
+	Hash     string    `json:"hash"`
+	Time     time.Time `json:"time"`
+	IssuerID string    `json:"issuer_id"`
+	KeyID    string    `json:"key_id"`
+}
+
+func getLegacyMigrationLog(s logical.Storage) (*legacyMigrationLog, error) {
+	entry, err := s.Get(legacyMigrationLogPath)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch legacy CA bundle migration log: %v", err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var log legacyMigrationLog
+	if err := entry.DecodeJSON(&log); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode legacy CA bundle migration log: %v", err)}
+	}
+	return &log, nil
+}
+
+// migrateCABundle copies the single CA bundle of mounts created before
+// multiple issuers were supported into an issuer and a key, which become the
+// default issuer unless there is one already. It runs when the backend is
+// initialized, so that requests never see a half migrated mount. The legacy
+// entries are left in place until they are removed by a tidy operation with
+// 'tidy_legacy_ca_bundle'.
+func (b *backend) migrateCABundle(s logical.Storage) error {
+	b.issuersMigrationLock.Lock()
+	defer b.issuersMigrationLock.Unlock()
+
+	entry, err := s.Get(legacyCABundlePath)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("unable to fetch legacy CA bundle: %v", err)}
+	}
+	if entry == nil {
+		return nil
+	}
+
+	hash := sha256.Sum256(entry.Value)
+	log, err := getLegacyMigrationLog(s)
+	if err != nil {
+		return err
+	}
+	if log != nil && log.Hash == hex.EncodeToString(hash[:]) {
+		return nil
+	}
+
+	var cb certutil.CertBundle
+	if err := entry.DecodeJSON(&cb); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("unable to decode legacy CA bundle: %v", err)}
+	}
+
+	log = &legacyMigrationLog{
+		Hash: hex.EncodeToString(hash[:]),
+		Time: time.Now(),
+	}
+	if cb.PrivateKey != "" {
+		key, _, err := importKey(s, cb.PrivateKey, cb.PrivateKeyType, "")
+		if err != nil {
+			return err
+		}
+		log.KeyID = key.ID
+	}
+	if cb.Certificate != "" {
+		issuer, existing, err := importIssuer(s, cb.Certificate, cb.CAChain, "")
+		if err != nil {
+			return err
+		}
+		log.IssuerID = issuer.ID
+
+		// Keep the last CRL of the CA until the next one is built
+		if !existing {
+			crlEntry, err := s.Get("crl")
+			if err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("unable to fetch legacy CRL: %v", err)}
+			}
+			if crlEntry != nil {
+				crlEntry.Key = issuerCRLPrefix + issuer.ID
+				if err := s.Put(crlEntry); err != nil {
+					return errutil.InternalError{Err: fmt.Sprintf("unable to store CRL: %v", err)}
+				}
+			}
+		}
+	}
+
+	logEntry, err := logical.StorageEntryJSON(legacyMigrationLogPath, log)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(logEntry); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("unable to store legacy CA bundle migration log: %v", err)}
+	}
+
+	if b.Logger().IsInfo() {
+		b.Logger().Info("pki: migrated legacy CA bundle", "issuer_id", log.IssuerID, "key_id", log.KeyID)
+	}
+	return nil
+}
+
+// removeLegacyCABundle deletes the legacy CA entries once they have been
+// migrated. It reports whether there was anything to delete.
+func (b *backend) removeLegacyCABundle(s logical.Storage) (bool, error) {
+	b.issuersMigrationLock.Lock()
+	defer b.issuersMigrationLock.Unlock()
+
+	entry, err := s.Get(legacyCABundlePath)
+	if err != nil {
+		return false, errutil.InternalError{Err: fmt.Sprintf("unable to fetch legacy CA bundle: %v", err)}
+	}
+	if entry == nil {
+		return false, nil
+	}
+
+	hash := sha256.Sum256(entry.Value)
+	log, err := getLegacyMigrationLog(s)
+	if err != nil {
+		return false, err
+	}
+	if log == nil || log.Hash != hex.EncodeToString(hash[:]) {
+		return false, fmt.Errorf("the legacy CA bundle has not been migrated yet")
+	}
+
+	for _, key := range []string{"crl", "ca", legacyCABundlePath} {
+		if err := s.Delete(key); err != nil {
+			return false, errutil.InternalError{Err: fmt.Sprintf("unable to delete legacy CA entry %s: %v", key, err)}
+		}
+	}
+	return true, nil
+}
+
+func getIssuersConfig(s logical.Storage) (*issuersConfig, error) {
+	entry, err := s.Get(issuersConfigPath)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuers configuration: %v", err)}
+	}
+
+	var config issuersConfig
+	if entry != nil {
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode issuers configuration: %v", err)}
+		}
+	}
+	return &config, nil
+}
+
+func setIssuersConfig(s logical.Storage, config *issuersConfig) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigPath, config)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+func listIssuers(s logical.Storage) ([]string, error) {
+	ids, err := s.List(issuerPrefix)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to list issuers: %v", err)}
+	}
+	return ids, nil
+}
+
+func listKeys(s logical.Storage) ([]string, error) {
+	ids, err := s.List(keyPrefix)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to list keys: %v", err)}
+	}
+	return ids, nil
+}
+
+func fetchIssuer(s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(issuerPrefix + id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuer %s: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode issuer %s: %v", id, err)}
+	}
+	return &issuer, nil
+}
+
+func fetchKey(s logical.Storage, id string) (*keyEntry, error) {
+	entry, err := s.Get(keyPrefix + id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch key %s: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key keyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode key %s: %v", id, err)}
+	}
+	return &key, nil
+}
+
+func writeIssuer(s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+func writeKey(s logical.Storage, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON(keyPrefix+key.ID, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(entry)
+}
+
+// fetchAllIssuers returns every issuer of the mount
+func fetchAllIssuers(s logical.Storage) ([]*issuerEntry, error) {
+	ids, err := listIssuers(s)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := make([]*issuerEntry, 0, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuer(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil {
+			issuers = append(issuers, issuer)
+		}
+	}
+	return issuers, nil
+}
+
+// resolveIssuerRef returns the ID of the issuer referred to by ref, which is
+// "default", an issuer ID or an issuer name. It returns an empty ID if there
+// is no such issuer.
+func resolveIssuerRef(s logical.Storage, ref string) (string, error) {
+	if ref == "" || ref == defaultRef {
+		config, err := getIssuersConfig(s)
+		if err != nil {
+			return "", err
+		}
+		return config.DefaultIssuer, nil
+	}
+
+	issuers, err := fetchAllIssuers(s)
+	if err != nil {
+		return "", err
+	}
+	for _, issuer := range issuers {
+		if issuer.ID == ref {
+			return issuer.ID, nil
+		}
+	}
+	for _, issuer := range issuers {
+		if issuer.Name == ref {
+			return issuer.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// resolveKeyRef returns the ID of the key referred to by ref, which is a key
+// ID or a key name. It returns an empty ID if there is no such key.
+func resolveKeyRef(s logical.Storage, ref string) (string, error) {
+	ids, err := listKeys(s)
+	if err != nil {
+		return "", err
+	}
+
+	var byName string
+	for _, id := range ids {
+		if id == ref {
+			return id, nil
+		}
+		key, err := fetchKey(s, id)
+		if err != nil {
+			return "", err
+		}
+		if key != nil && key.Name == ref {
+			byName = id
+		}
+	}
+	return byName, nil
+}
+
+// validateName checks that a new issuer or key name does not clash with the
+// reserved reference or with an existing name
+func validateName(s logical.Storage, name, id string, isIssuer bool) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef {
+		return errutil.UserError{Err: fmt.Sprintf("%q is a reserved name", defaultRef)}
+	}
+
+	var existing string
+	var err error
+	if isIssuer {
+		existing, err = resolveIssuerRef(s, name)
+	} else {
+		existing, err = resolveKeyRef(s, name)
+	}
+	if err != nil {
+		return err
+	}
+	if existing != "" && existing != id {
+		return errutil.UserError{Err: fmt.Sprintf("name %q is already in use", name)}
+	}
+	return nil
+}
+
+// validateIssuerAndKeyNames checks the names of an issuer and a key about to
+// be created, before anything is stored
+func validateIssuerAndKeyNames(s logical.Storage, issuerName, keyName string) error {
+	if err := validateName(s, issuerName, "", true); err != nil {
+		return err
+	}
+	return validateName(s, keyName, "", false)
+}
+
+// findKey returns the key of the mount matching the given public key, if any
+func findKey(s logical.Storage, publicKey crypto.PublicKey) (*keyEntry, error) {
+	ids, err := s.List(keyPrefix)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to list keys: %v", err)}
+	}
+	for _, id := range ids {
+		key, err := fetchKey(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		public, err := key.publicKey()
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse key %s: %v", id, err)}
+		}
+		if equal, _ := certutil.ComparePublicKeys(public, publicKey); equal {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+// importKey stores a PEM encoded private key as a new key, unless it is
+// already known to the mount. It returns the key and whether it existed.
+func importKey(s logical.Storage, privateKey string, keyType certutil.PrivateKeyType, name string) (*keyEntry, bool, error) {
+	newKey := &keyEntry{
+		Name:           name,
+		PrivateKeyType: keyType,
+		PrivateKey:     privateKey,
+	}
+	newPublic, err := newKey.publicKey()
+	if err != nil {
+		return nil, false, errutil.UserError{Err: fmt.Sprintf("unable to parse private key: %v", err)}
+	}
+
+	existing, err := findKey(s, newPublic)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, true, nil
+	}
+
+	if err := validateName(s, name, "", false); err != nil {
+		return nil, false, err
+	}
+
+	newKey.ID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to generate key ID: %v", err)}
+	}
+	if err := writeKey(s, newKey); err != nil {
+		return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to store key: %v", err)}
+	}
+
+	return newKey, false, nil
+}
+
+// importIssuer stores a PEM encoded CA certificate as a new issuer, unless it
+// is already known to the mount, and links it with the key matching its
+// public key, if any. The first issuer of a mount becomes its default issuer.
+// It returns the issuer and whether it existed.
+func importIssuer(s logical.Storage, certificate string, caChain []string, name string) (*issuerEntry, bool, error) {
+	newIssuer := &issuerEntry{
+		Name:        name,
+		Certificate: strings.TrimSpace(certificate),
+		CAChain:     caChain,
+	}
+	cert, err := newIssuer.parseCertificate()
+	if err != nil {
+		return nil, false, errutil.UserError{Err: err.Error()}
+	}
+	if !cert.IsCA {
+		return nil, false, errutil.UserError{Err: "the given certificate is not marked for CA use and cannot be used with this backend"}
+	}
+	newIssuer.SerialNumber = certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	ids, err := s.List(issuerPrefix)
+	if err != nil {
+		return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to list issuers: %v", err)}
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuer(s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if issuer != nil && issuer.Certificate == newIssuer.Certificate {
+			return issuer, true, nil
+		}
+	}
+
+	if err := validateName(s, name, "", true); err != nil {
+		return nil, false, err
+	}
+
+	key, err := findKey(s, cert.PublicKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if key != nil {
+		newIssuer.KeyID = key.ID
+	}
+
+	newIssuer.ID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to generate issuer ID: %v", err)}
+	}
+	if err := writeIssuer(s, newIssuer); err != nil {
+		return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to store issuer: %v", err)}
+	}
+
+	config, err := getIssuersConfig(s)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.DefaultIssuer == "" {
+		config.DefaultIssuer = newIssuer.ID
+		if err := setIssuersConfig(s, config); err != nil {
+			return nil, false, errutil.InternalError{Err: fmt.Sprintf("unable to store issuers configuration: %v", err)}
+		}
+	}
+
+	return newIssuer, false, nil
+}
+
+// importBundle imports the key and the certificates of a parsed PEM bundle.
+// The first certificate becomes an issuer and the others its chain.
+func importBundle(s logical.Storage, bundle *certutil.ParsedCertBundle, issuerName, keyName string) (*issuerEntry, *keyEntry, error) {
+	if bundle.PrivateKey == nil && bundle.Certificate == nil {
+		return nil, nil, errutil.UserError{Err: "no private key or certificate found in the PEM bundle"}
+	}
+
+	cb, err := bundle.ToCertBundle()
+	if err != nil {
+		return nil, nil, errutil.InternalError{Err: fmt.Sprintf("error converting raw values into cert bundle: %v", err)}
+	}
+
+	var key *keyEntry
+	if bundle.PrivateKey != nil {
+		if key, _, err = importKey(s, cb.PrivateKey, cb.PrivateKeyType, keyName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var issuer *issuerEntry
+	if bundle.Certificate != nil {
+		if issuer, _, err = importIssuer(s, cb.Certificate, cb.CAChain, issuerName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return issuer, key, nil
+}
+
+// fetchCAInfo returns the signing bundle of the issuer referred to by
+// issuerRef. Unlike other certificates, issuers are stored with the ID of
+// their private key, so that they can be used for signing.
+func fetchCAInfo(req *logical.Request, issuerRef string) (*caInfoBundle, error) {
+	id, err := resolveIssuerRef(req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, unknownIssuerError(issuerRef)
+	}
+
+	return fetchCAInfoByID(req, id)
+}
+
+func unknownIssuerError(issuerRef string) error {
+	if issuerRef == "" || issuerRef == defaultRef {
+		return errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+	}
+	return errutil.UserError{Err: fmt.Sprintf("unknown issuer %q", issuerRef)}
+}
+
+func fetchCAInfoByID(req *logical.Request, id string) (*caInfoBundle, error) {
+	issuer, err := fetchIssuer(req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unknown issuer %q", id)}
+	}
+	if issuer.KeyID == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %q has no private key", id)}
+	}
+	key, err := fetchKey(req.Storage, issuer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("the private key of issuer %q was deleted", id)}
+	}
+
+	bundle := certutil.CertBundle{
+		Certificate:    issuer.Certificate,
+		CAChain:        issuer.CAChain,
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+	}
+	parsedBundle, err := bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	if parsedBundle.Certificate == nil {
+		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	}
+
+	caInfo := &caInfoBundle{*parsedBundle, nil, issuer.ID}
+
+	entries, err := getURLs(req)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
+	}
+	if entries == nil {
+		entries = &urlEntries{
+			IssuingCertificates:   []string{},
+			CRLDistributionPoints: []string{},
+			OCSPServers:           []string{},
+		}
+	}
+	caInfo.URLs = entries
+
+	return caInfo, nil
+}
+
+// fetchIssuerChain returns the CA chain of the issuer referred to by
+// issuerRef, which does not need to have a key
+func fetchIssuerChain(req *logical.Request, issuerRef string) ([]*certutil.CertBlock, error) {
+	id, err := resolveIssuerRef(req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, unknownIssuerError(issuerRef)
+	}
+	issuer, err := fetchIssuer(req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, unknownIssuerError(issuerRef)
+	}
+
+	parsedBundle, err := (&certutil.CertBundle{
+		Certificate: issuer.Certificate,
+		CAChain:     issuer.CAChain,
+	}).ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+
+	caInfo := &caInfoBundle{*parsedBundle, nil, issuer.ID}
+	return caInfo.GetCAChain(), nil
+}
+
+// fetchIssuerBytes returns a storage entry holding the DER encoded
+// certificate, or CRL if crl is set, of the issuer referred to by issuerRef.
+// It returns nil if there is no such issuer or CRL.
+func fetchIssuerBytes(req *logical.Request, issuerRef string, crl bool) (*logical.StorageEntry, error) {
+	id, err := resolveIssuerRef(req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+
+	if crl {
+		entry, err := req.Storage.Get(issuerCRLPrefix + id)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL of issuer %s: %s", id, err)}
+		}
+		return entry, nil
+	}
+
+	issuer, err := fetchIssuer(req.Storage, id)
+	if err != nil || issuer == nil {
+		return nil, err
+	}
+	cert, err := issuer.parseCertificate()
+	if err != nil {
+		return nil, err
+	}
+	return &logical.StorageEntry{
+		Key:   issuerPrefix + id,
+		Value: cert.Raw,
+	}, nil
+}
+
+// fetchIssuerDeltaCRL returns a storage entry holding the DER encoded delta
+// CRL of the issuer referred to by issuerRef. It returns nil if there is no
+// such issuer or delta CRL.
+func fetchIssuerDeltaCRL(req *logical.Request, issuerRef string) (*logical.StorageEntry, error) {
+	id, err := resolveIssuerRef(req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+
+	entry, err := req.Storage.Get(issuerDeltaCRLPrefix + id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching delta CRL of issuer %s: %s", id, err)}
+	}
+	return entry, nil
+}
+
+// issuerOfCert returns the ID of the issuer, among the given ones, that
+// signed cert, or an empty ID if none of them did
+func issuerOfCert(cert *x509.Certificate, issuers []*issuerEntry) string {
+	for _, issuer := range issuers {
+		issuerCert, err := issuer.parseCertificate()
+		if err != nil {
+			continue
+		}
+		if string(cert.RawIssuer) != string(issuerCert.RawSubject) {
+			continue
+		}
+		if cert.CheckSignatureFrom(issuerCert) == nil {
+			return issuer.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,259 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// OCSP response statuses, as defined in RFC 6960 section 4.2.1
+const (
+	ocspSuccessful       asn1.Enumerated = 0
+	ocspMalformedRequest asn1.Enumerated = 1
+	ocspInternalError    asn1.Enumerated = 2
+	ocspUnauthorized     asn1.Enumerated = 6
+)
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+	// ocspHashes maps the hash algorithms accepted in the CertID of a
+	// request to their implementation
+	ocspHashes = map[string]crypto.Hash{
+		asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}.String():             crypto.SHA1,
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}.String(): crypto.SHA256,
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}.String(): crypto.SHA384,
+		asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}.String(): crypto.SHA512,
+	}
+)
+
+// The following types are the subset of the ASN.1 structures of RFC 6960 the
+// responder needs. Request signatures are not supported and are ignored.
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList       []ocspSingleRequest
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspSingleRequest struct {
+	CertID ocspCertID
+}
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+	NextUpdate time.Time       `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+// ocspCertStatus is the status of a single certificate in a response
+type ocspCertStatus int
+
+const (
+	ocspStatusGood ocspCertStatus = iota
+	ocspStatusRevoked
+	ocspStatusUnknown
+)
+
+// ocspCertResult is the answer to a single certificate of a request
+type ocspCertResult struct {
+	CertID         ocspCertID
+	Status         ocspCertStatus
+	RevocationTime time.Time
+}
+
+// parseOCSPRequest parses a DER encoded OCSP request, returning its
+// certificate requests and its nonce, if any
+func parseOCSPRequest(der []byte) ([]ocspCertID, []byte, error) {
+	var req ocspRequest
+	rest, err := asn1.Unmarshal(der, &req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) > 0 {
+		return nil, nil, fmt.Errorf("trailing data after OCSP request")
+	}
+	if len(req.TBSRequest.RequestList) == 0 {
+		return nil, nil, fmt.Errorf("OCSP request contains no certificates")
+	}
+
+	certIDs := make([]ocspCertID, 0, len(req.TBSRequest.RequestList))
+	for _, single := range req.TBSRequest.RequestList {
+		certIDs = append(certIDs, single.CertID)
+	}
+
+	var nonce []byte
+	for _, ext := range req.TBSRequest.RequestExtensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			nonce = ext.Value
+		}
+	}
+
+	return certIDs, nonce, nil
+}
+
+// issuedBy returns whether the certificate identified by the CertID was
+// issued by the given CA certificate
+func (id ocspCertID) issuedBy(ca *x509.Certificate) bool {
+	hash, ok := ocspHashes[id.HashAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return false
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(ca.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := hash.New()
+	h.Write(ca.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, id.IssuerNameHash) && bytes.Equal(keyHash, id.IssuerKeyHash)
+}
+
+// ocspErrorResponse returns a DER encoded OCSP response carrying only the
+// given unsuccessful status
+func ocspErrorResponse(status asn1.Enumerated) []byte {
+	der, _ := asn1.Marshal(ocspResponse{Status: status})
+	return der
+}
+
+// createOCSPResponse returns a DER encoded successful OCSP response with the
+// given results, signed by the CA and carrying its certificate. If lifetime
+// is not zero, the responses are marked as valid for that long.
+func createOCSPResponse(ca *x509.Certificate, signer crypto.Signer, results []ocspCertResult, nonce []byte, lifetime time.Duration) ([]byte, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	responseData := ocspResponseData{
+		ResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1,
+			IsCompound: true,
+			Bytes:      ca.RawSubject,
+		},
+		ProducedAt: now,
+	}
+	if nonce != nil {
+		responseData.ResponseExtensions = []pkix.Extension{
+			{
+				Id:    oidOCSPNonce,
+				Value: nonce,
+			},
+		}
+	}
+
+	for _, result := range results {
+		single := ocspSingleResponse{
+			CertID:     result.CertID,
+			ThisUpdate: now,
+		}
+		if lifetime != 0 {
+			single.NextUpdate = now.Add(lifetime)
+		}
+		switch result.Status {
+		case ocspStatusGood:
+			single.Good = true
+		case ocspStatusRevoked:
+			single.Revoked = ocspRevokedInfo{
+				RevocationTime: result.RevocationTime.UTC(),
+			}
+		default:
+			single.Unknown = true
+		}
+		responseData.Responses = append(responseData.Responses, single)
+	}
+
+	tbsResponseData, err := asn1.Marshal(responseData)
+	if err != nil {
+		return nil, err
+	}
+
+	sigAlgorithm, signature, err := signTBS(signer, tbsResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	basicResponse, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsResponseData},
+		SignatureAlgorithm: sigAlgorithm,
+		Signature: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+		Certificates: []asn1.RawValue{
+			{FullBytes: ca.Raw},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status: ocspSuccessful,
+		ResponseBytes: ocspResponseBytes{
+			ResponseType: oidOCSPBasicResponse,
+			Response:     basicResponse,
+		},
+	})
+}
@@ -0,0 +1,1120 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// acmeOperation handles an ACME request. Returned errors are turned into
+// problem documents: ACME problems are returned as is, other errors become
+// internal errors.
+type acmeOperation func(req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error)
+
+// acmeWrap checks that ACME is enabled and serializes the requests changing
+// the ACME state, since orders and authorizations are updated as a whole
+func (b *backend) acmeWrap(op acmeOperation) framework.OperationFunc {
+	return b.acmeHandler(op, true)
+}
+
+// acmeWrapUnlocked is like acmeWrap, but leaves taking the ACME lock to the
+// operation, for those that must not hold it throughout
+func (b *backend) acmeWrapUnlocked(op acmeOperation) framework.OperationFunc {
+	return b.acmeHandler(op, false)
+}
+
+func (b *backend) acmeHandler(op acmeOperation, lock bool) framework.OperationFunc {
+	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		config, err := b.ACME(req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil || !config.Enabled {
+			return b.acmeErrorResponse(nil, newACMEProblem(acmeErrMalformed, http.StatusNotFound, "ACME is not enabled on this backend"))
+		}
+
+		if lock && req.Operation == logical.UpdateOperation {
+			b.acmeLock.Lock()
+			defer b.acmeLock.Unlock()
+		}
+
+		resp, err := op(req, data, config)
+		if err != nil {
+			return b.acmeErrorResponse(config, err)
+		}
+		return resp, nil
+	}
+}
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/directory",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.acmeWrap(b.pathACMEDirectory),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-nonce",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.acmeWrap(b.pathACMENewNonce),
+			logical.UpdateOperation: b.acmeWrap(b.pathACMENewNonce),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-account",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMENewAccount),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/account/" + framework.GenericNameRegex("account_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"account_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the account`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMEAccount),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAccountOrders(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/account/" + framework.GenericNameRegex("account_id") + "/orders",
+		Fields: map[string]*framework.FieldSchema{
+			"account_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the account`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMEAccountOrders),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-order",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMENewOrder),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/order/" + framework.GenericNameRegex("order_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the order`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMEOrder),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEFinalize(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/order/" + framework.GenericNameRegex("order_id") + "/finalize",
+		Fields: map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the order`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMEFinalize),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/authorization/" + framework.GenericNameRegex("authz_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"authz_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the authorization`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMEAuthorization),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/challenge/" + framework.GenericNameRegex("authz_id") + "/" + framework.GenericNameRegex("challenge_type"),
+		Fields: map[string]*framework.FieldSchema{
+			"authz_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the authorization`,
+			},
+			"challenge_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Type of the challenge, "http-01" or "dns-01"`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrapUnlocked(b.pathACMEChallenge),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMECertificate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/certificate/" + framework.GenericNameRegex("order_id"),
+		Fields: map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the order`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMECertificate),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMERevokeCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/revoke-cert",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeWrap(b.pathACMERevokeCert),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func acmeNotFound(kind, id string) error {
+	return newACMEProblem(acmeErrMalformed, http.StatusNotFound, "unknown %s %q", kind, id)
+}
+
+// acmeDecodePayload decodes the JSON payload of a request, which may be
+// empty for POST-as-GET requests
+func acmeDecodePayload(payload []byte, out interface{}) error {
+	if len(payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error parsing payload: %v", err)
+	}
+	return nil
+}
+
+func (b *backend) pathACMEDirectory(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	return b.acmeJSONResponse(config, http.StatusOK, map[string]interface{}{
+		"newNonce":   config.url("acme/new-nonce"),
+		"newAccount": config.url("acme/new-account"),
+		"newOrder":   config.url("acme/new-order"),
+		"revokeCert": config.url("acme/revoke-cert"),
+	}, "")
+}
+
+func (b *backend) pathACMENewNonce(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	return b.acmeRawResponse(config, http.StatusOK, "", nil, nil)
+}
+
+func acmeAccountJSON(config *acmeConfig, account *acmeAccount) map[string]interface{} {
+	contact := account.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+	return map[string]interface{}{
+		"status":               account.Status,
+		"contact":              contact,
+		"termsOfServiceAgreed": account.TermsOfServiceAgreed,
+		"orders":               config.url("acme/account/" + account.ID + "/orders"),
+	}
+}
+
+func validateACMEContacts(contacts []string) error {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, "mailto:") || len(contact) == len("mailto:") {
+			return newACMEProblem(acmeErrUnsupportedContact, http.StatusBadRequest, "unsupported contact %q; only mailto: contacts are supported", contact)
+		}
+	}
+	return nil
+}
+
+func (b *backend) pathACMENewAccount(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyJWK)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	thumbprint, err := acmeThumbprint(acmeReq.JWK)
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error computing the key thumbprint: %v", err)
+	}
+
+	// Accounts are identified by their key
+	var accountID string
+	if _, err := getACMEEntry(req.Storage, acmeThumbprintPrefix+thumbprint, &accountID); err != nil {
+		return nil, err
+	}
+	if accountID != "" {
+		account, err := fetchACMEAccount(req.Storage, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			if account.Status != acmeStatusValid {
+				return nil, newACMEProblem(acmeErrUnauthorized, http.StatusUnauthorized, "account is %s", account.Status)
+			}
+			return b.acmeJSONResponse(config, http.StatusOK, acmeAccountJSON(config, account), config.url("acme/account/"+account.ID))
+		}
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, newACMEProblem(acmeErrAccountDoesNotExist, http.StatusBadRequest, "no account exists for this key")
+	}
+	if err := validateACMEContacts(payload.Contact); err != nil {
+		return nil, err
+	}
+
+	key, err := json.Marshal(acmeReq.JWK)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	account := &acmeAccount{
+		ID:                   id,
+		Status:               acmeStatusValid,
+		Contact:              payload.Contact,
+		TermsOfServiceAgreed: payload.TermsOfServiceAgreed,
+		Key:                  key,
+		Thumbprint:           thumbprint,
+		CreatedAt:            time.Now().UTC(),
+	}
+	if err := putACMEEntry(req.Storage, acmeAccountPrefix+account.ID, account); err != nil {
+		return nil, err
+	}
+	if err := putACMEEntry(req.Storage, acmeThumbprintPrefix+thumbprint, account.ID); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(config, http.StatusCreated, acmeAccountJSON(config, account), config.url("acme/account/"+account.ID))
+}
+
+// acmeVerifyAccount verifies a request signed by the key of the account of
+// the given ID
+func (b *backend) acmeVerifyAccount(req *logical.Request, config *acmeConfig, accountID string) (*acmeRequest, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if acmeReq.Account.ID != accountID {
+		return nil, newACMEProblem(acmeErrUnauthorized, http.StatusUnauthorized, "request is not signed by the key of the account")
+	}
+	return acmeReq, nil
+}
+
+func (b *backend) pathACMEAccount(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, err := b.acmeVerifyAccount(req, config, data.Get("account_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	account := acmeReq.Account
+
+	var payload struct {
+		Contact *[]string `json:"contact"`
+		Status  string    `json:"status"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	changed := false
+	if payload.Contact != nil {
+		if err := validateACMEContacts(*payload.Contact); err != nil {
+			return nil, err
+		}
+		account.Contact = *payload.Contact
+		changed = true
+	}
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		account.Status = acmeStatusDeactivated
+		changed = true
+	default:
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "accounts can only be deactivated")
+	}
+
+	if changed {
+		if err := putACMEEntry(req.Storage, acmeAccountPrefix+account.ID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, acmeAccountJSON(config, account), "")
+}
+
+func (b *backend) pathACMEAccountOrders(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, err := b.acmeVerifyAccount(req, config, data.Get("account_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := req.Storage.List(acmeOrderPrefix + acmeReq.Account.ID + "/")
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]string, 0, len(ids))
+	for _, id := range ids {
+		orders = append(orders, config.url("acme/order/"+id))
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, map[string]interface{}{
+		"orders": orders,
+	}, "")
+}
+
+func acmeOrderJSON(config *acmeConfig, order *acmeOrder) map[string]interface{} {
+	authorizations := make([]string, 0, len(order.AuthorizationIDs))
+	for _, id := range order.AuthorizationIDs {
+		authorizations = append(authorizations, config.url("acme/authorization/"+id))
+	}
+
+	ret := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires,
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       config.url("acme/order/" + order.ID + "/finalize"),
+	}
+	if order.Status == acmeStatusValid {
+		ret["certificate"] = config.url("acme/certificate/" + order.ID)
+	}
+	if order.Error != nil {
+		ret["error"] = order.Error
+	}
+	return ret
+}
+
+func (b *backend) pathACMENewOrder(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Identifiers) == 0 {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "orders must have at least one identifier")
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "notBefore and notAfter are not supported; the validity of certificates is set by the role")
+	}
+
+	var identifiers []acmeIdentifier
+	var names []string
+	seen := map[string]bool{}
+	for _, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, newACMEProblem(acmeErrUnsupportedIdentifier, http.StatusBadRequest, "unsupported identifier type %q", identifier.Type)
+		}
+		name := strings.ToLower(identifier.Value)
+		if !acmeValidIdentifier(name) {
+			return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "invalid identifier %q", identifier.Value)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		identifiers = append(identifiers, acmeIdentifier{Type: "dns", Value: name})
+	}
+
+	role, err := b.getRole(req.Storage, config.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("unknown ACME role %q", config.Role)
+	}
+	if badName := validateNames(req, names, role); badName != "" {
+		return nil, newACMEProblem(acmeErrRejectedIdentifier, http.StatusBadRequest, "identifier %q is not allowed by the policy of this server", badName)
+	}
+
+	orderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().UTC().Add(acmeOrderLifetime)
+	order := &acmeOrder{
+		ID:          orderID,
+		AccountID:   acmeReq.Account.ID,
+		Status:      acmeStatusPending,
+		Expires:     expires,
+		Identifiers: identifiers,
+	}
+
+	for _, identifier := range identifiers {
+		authzID, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		authz := &acmeAuthorization{
+			ID:         authzID,
+			AccountID:  acmeReq.Account.ID,
+			Status:     acmeStatusPending,
+			Expires:    expires,
+			Identifier: identifier,
+		}
+
+		// Wildcards are authorized for their base domain, over DNS only
+		challengeTypes := []string{acmeChallengeHTTP01, acmeChallengeDNS01}
+		if strings.HasPrefix(identifier.Value, "*.") {
+			authz.Identifier.Value = identifier.Value[2:]
+			authz.Wildcard = true
+			challengeTypes = []string{acmeChallengeDNS01}
+		}
+		for _, challengeType := range challengeTypes {
+			token, err := acmeRandom()
+			if err != nil {
+				return nil, err
+			}
+			authz.Challenges = append(authz.Challenges, &acmeChallenge{
+				Type:   challengeType,
+				Token:  token,
+				Status: acmeStatusPending,
+			})
+		}
+
+		if err := writeACMEAuthorization(req.Storage, authz); err != nil {
+			return nil, err
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := writeACMEOrder(req.Storage, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(config, http.StatusCreated, acmeOrderJSON(config, order), config.url("acme/order/"+order.ID))
+}
+
+// acmeUpdateAuthorization expires a pending authorization past its
+// expiration date
+func acmeUpdateAuthorization(s logical.Storage, authz *acmeAuthorization) error {
+	if authz.Status != acmeStatusPending || time.Now().Before(authz.Expires) {
+		return nil
+	}
+	authz.Status = acmeStatusExpired
+	return writeACMEAuthorization(s, authz)
+}
+
+// acmeUpdateOrder derives the status of a pending or ready order from its
+// authorizations
+func acmeUpdateOrder(s logical.Storage, order *acmeOrder) error {
+	if order.Status != acmeStatusPending && order.Status != acmeStatusReady {
+		return nil
+	}
+
+	status := acmeStatusReady
+	if time.Now().After(order.Expires) {
+		status = acmeStatusInvalid
+	} else {
+		for _, id := range order.AuthorizationIDs {
+			authz, err := fetchACMEAuthorization(s, order.AccountID, id)
+			if err != nil {
+				return err
+			}
+			if authz == nil {
+				status = acmeStatusInvalid
+				break
+			}
+			if err := acmeUpdateAuthorization(s, authz); err != nil {
+				return err
+			}
+			if authz.Status == acmeStatusPending {
+				status = acmeStatusPending
+				continue
+			}
+			if authz.Status != acmeStatusValid {
+				status = acmeStatusInvalid
+				break
+			}
+		}
+	}
+
+	if status == order.Status {
+		return nil
+	}
+	order.Status = status
+	return writeACMEOrder(s, order)
+}
+
+// acmeFetchOrder returns the order of the given ID belonging to the account
+// of the request
+func (b *backend) acmeFetchOrder(req *logical.Request, config *acmeConfig, id string) (*acmeRequest, *acmeOrder, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := fetchACMEOrder(req.Storage, acmeReq.Account.ID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order == nil {
+		return nil, nil, acmeNotFound("order", id)
+	}
+	if err := acmeUpdateOrder(req.Storage, order); err != nil {
+		return nil, nil, err
+	}
+
+	return acmeReq, order, nil
+}
+
+func (b *backend) pathACMEOrder(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	_, order, err := b.acmeFetchOrder(req, config, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, acmeOrderJSON(config, order), "")
+}
+
+func (b *backend) pathACMEFinalize(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, order, err := b.acmeFetchOrder(req, config, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusReady {
+		return nil, newACMEProblem(acmeErrOrderNotReady, http.StatusForbidden, "order is %s", order.Status)
+	}
+
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+	der, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload.CSR, "="))
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error decoding csr: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "error parsing csr: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "invalid csr signature: %v", err)
+	}
+
+	// The CSR must request exactly the identifiers of the order
+	if len(csr.EmailAddresses) != 0 || len(csr.IPAddresses) != 0 {
+		return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "csr must only request DNS names")
+	}
+	csrNames := map[string]bool{}
+	if csr.Subject.CommonName != "" {
+		csrNames[strings.ToLower(csr.Subject.CommonName)] = true
+	}
+	for _, name := range csr.DNSNames {
+		csrNames[strings.ToLower(name)] = true
+	}
+	var names []string
+	for _, identifier := range order.Identifiers {
+		if !csrNames[identifier.Value] {
+			return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "csr does not request %q", identifier.Value)
+		}
+		names = append(names, identifier.Value)
+	}
+	if len(csrNames) != len(names) {
+		return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "csr requests names other than the identifiers of the order")
+	}
+
+	role, err := b.getRole(req.Storage, config.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("unknown ACME role %q", config.Role)
+	}
+	// ACME certificates are tracked by their order rather than by a lease,
+	// and hold the names of the order, which were checked against the CSR
+	acmeRole := *role
+	acmeRole.GenerateLease = new(bool)
+	acmeRole.UseCSRCommonName = false
+	acmeRole.UseCSRSANs = false
+
+	commonName := strings.ToLower(csr.Subject.CommonName)
+	if commonName == "" {
+		commonName = names[0]
+	}
+	// The common name is added to the SANs when signing
+	var altNames []string
+	for _, name := range names {
+		if name != commonName {
+			altNames = append(altNames, name)
+		}
+	}
+	sort.Strings(altNames)
+	signData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: der,
+			})),
+			"common_name": commonName,
+			"alt_names":   strings.Join(altNames, ","),
+			"format":      "pem",
+		},
+		Schema: pathSign(b).Fields,
+	}
+
+	resp, err := b.pathIssueSignCert(req, signData, &acmeRole, true, false)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "%s", err)
+		default:
+			return nil, err
+		}
+	}
+	if resp.IsError() {
+		return nil, newACMEProblem(acmeErrBadCSR, http.StatusBadRequest, "%s", resp.Data["error"])
+	}
+
+	chain := []string{resp.Data["certificate"].(string)}
+	if caChain, ok := resp.Data["ca_chain"].([]string); ok && len(caChain) > 0 {
+		chain = append(chain, caChain...)
+	} else {
+		chain = append(chain, resp.Data["issuing_ca"].(string))
+	}
+	serial := resp.Data["serial_number"].(string)
+
+	order.Status = acmeStatusValid
+	order.CertificateSerial = serial
+	order.Certificate = strings.Join(chain, "\n") + "\n"
+	if err := writeACMEOrder(req.Storage, order); err != nil {
+		return nil, err
+	}
+	err = putACMEEntry(req.Storage, acmeCertPrefix+normalizeSerial(serial), &acmeCertEntry{
+		AccountID: order.AccountID,
+		OrderID:   order.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, acmeOrderJSON(config, order), config.url("acme/order/"+order.ID))
+}
+
+func acmeChallengeJSON(config *acmeConfig, authz *acmeAuthorization, challenge *acmeChallenge) map[string]interface{} {
+	ret := map[string]interface{}{
+		"type":   challenge.Type,
+		"url":    config.url("acme/challenge/" + authz.ID + "/" + challenge.Type),
+		"token":  challenge.Token,
+		"status": challenge.Status,
+	}
+	if challenge.Status == acmeStatusValid {
+		ret["validated"] = challenge.Validated
+	}
+	if challenge.Error != nil {
+		ret["error"] = challenge.Error
+	}
+	return ret
+}
+
+func acmeAuthorizationJSON(config *acmeConfig, authz *acmeAuthorization) map[string]interface{} {
+	challenges := make([]map[string]interface{}, 0, len(authz.Challenges))
+	for _, challenge := range authz.Challenges {
+		challenges = append(challenges, acmeChallengeJSON(config, authz, challenge))
+	}
+
+	ret := map[string]interface{}{
+		"status":     authz.Status,
+		"expires":    authz.Expires,
+		"identifier": authz.Identifier,
+		"challenges": challenges,
+	}
+	if authz.Wildcard {
+		ret["wildcard"] = true
+	}
+	return ret
+}
+
+// acmeFetchAuthorization returns the authorization of the given ID
+// belonging to the account of the request
+func (b *backend) acmeFetchAuthorization(req *logical.Request, config *acmeConfig, id string) (*acmeRequest, *acmeAuthorization, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authz, err := fetchACMEAuthorization(req.Storage, acmeReq.Account.ID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authz == nil {
+		return nil, nil, acmeNotFound("authorization", id)
+	}
+	if err := acmeUpdateAuthorization(req.Storage, authz); err != nil {
+		return nil, nil, err
+	}
+
+	return acmeReq, authz, nil
+}
+
+func (b *backend) pathACMEAuthorization(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, authz, err := b.acmeFetchAuthorization(req, config, data.Get("authz_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		if authz.Status != acmeStatusPending && authz.Status != acmeStatusValid {
+			return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "authorization is %s", authz.Status)
+		}
+		authz.Status = acmeStatusDeactivated
+		if err := writeACMEAuthorization(req.Storage, authz); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "authorizations can only be deactivated")
+	}
+
+	return b.acmeJSONResponse(config, http.StatusOK, acmeAuthorizationJSON(config, authz), "")
+}
+
+func (b *backend) pathACMEChallenge(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	authzID := data.Get("authz_id").(string)
+	challengeType := data.Get("challenge_type").(string)
+
+	// Validation makes requests to the client's servers, so it runs without
+	// the ACME lock: the challenge is marked as processing under the lock,
+	// so that concurrent requests do not validate it again, and the result
+	// is recorded under the lock once known. Validation is still done before
+	// responding, so that clients find out the result with their first poll.
+	authz, challenge, keyAuth, err := b.acmeStartChallenge(req, config, authzID, challengeType)
+	if err != nil {
+		return nil, err
+	}
+	if keyAuth != "" {
+		result := b.acmeValidateChallenge(authz, challenge, keyAuth)
+		if authz, challenge, err = b.acmeFinishChallenge(req.Storage, authz, challengeType, result); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := b.acmeJSONResponse(config, http.StatusOK, acmeChallengeJSON(config, authz, challenge), "")
+	if err != nil {
+		return nil, err
+	}
+	headers := resp.Data[logical.HTTPRawHeaders].(map[string][]string)
+	headers["Link"] = append(headers["Link"], fmt.Sprintf("<%s>;rel=\"up\"", config.url("acme/authorization/"+authz.ID)))
+	return resp, nil
+}
+
+// acmeStartChallenge fetches a challenge and, if the request asks for it to
+// be validated, marks it as processing. It returns the key authorization to
+// validate the challenge with, or an empty one if there is nothing to do.
+func (b *backend) acmeStartChallenge(req *logical.Request, config *acmeConfig, authzID, challengeType string) (*acmeAuthorization, *acmeChallenge, string, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	acmeReq, authz, err := b.acmeFetchAuthorization(req, config, authzID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	challenge := authz.challenge(challengeType)
+	if challenge == nil {
+		return nil, nil, "", acmeNotFound("challenge", challengeType)
+	}
+
+	// An empty payload only fetches the challenge, while any other asks for
+	// it to be validated. Challenges left processing by a node that went
+	// away are validated again.
+	if len(acmeReq.Payload) == 0 || authz.Status != acmeStatusPending {
+		return authz, challenge, "", nil
+	}
+	switch challenge.Status {
+	case acmeStatusPending:
+	case acmeStatusProcessing:
+		if time.Since(challenge.Validated) < acmeChallengeTimeout {
+			return authz, challenge, "", nil
+		}
+	default:
+		return authz, challenge, "", nil
+	}
+
+	key, err := acmeReq.Account.jwk()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	keyAuth, err := acmeKeyAuthorization(challenge.Token, key)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	challenge.Status = acmeStatusProcessing
+	challenge.Validated = time.Now().UTC()
+	if err := writeACMEAuthorization(req.Storage, authz); err != nil {
+		return nil, nil, "", err
+	}
+	return authz, challenge, keyAuth, nil
+}
+
+// acmeFinishChallenge records the result of the validation of a challenge
+// started by acmeStartChallenge, unless the authorization changed meanwhile
+func (b *backend) acmeFinishChallenge(s logical.Storage, started *acmeAuthorization, challengeType string, result error) (*acmeAuthorization, *acmeChallenge, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err := fetchACMEAuthorization(s, started.AccountID, started.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authz == nil {
+		return nil, nil, acmeNotFound("authorization", started.ID)
+	}
+	challenge := authz.challenge(challengeType)
+	if challenge == nil {
+		return nil, nil, acmeNotFound("challenge", challengeType)
+	}
+	if authz.Status != acmeStatusPending || challenge.Status != acmeStatusProcessing {
+		return authz, challenge, nil
+	}
+
+	if result != nil {
+		problem, ok := result.(*acmeProblem)
+		if !ok {
+			return nil, nil, result
+		}
+		challenge.Status = acmeStatusInvalid
+		challenge.Error = problem
+		authz.Status = acmeStatusInvalid
+	} else {
+		challenge.Status = acmeStatusValid
+		challenge.Validated = time.Now().UTC()
+		authz.Status = acmeStatusValid
+	}
+
+	if err := writeACMEAuthorization(s, authz); err != nil {
+		return nil, nil, err
+	}
+	return authz, challenge, nil
+}
+
+func (b *backend) pathACMECertificate(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	_, order, err := b.acmeFetchOrder(req, config, data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusValid {
+		return nil, acmeNotFound("certificate", order.ID)
+	}
+
+	return b.acmeRawResponse(config, http.StatusOK, acmeCertContentType, []byte(order.Certificate), nil)
+}
+
+func (b *backend) pathACMERevokeCert(
+	req *logical.Request, data *framework.FieldData, config *acmeConfig) (*logical.Response, error) {
+	acmeReq, err := b.acmeVerify(req, config, acmeKeyAny)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Certificate string `json:"certificate"`
+		Reason      int    `json:"reason"`
+	}
+	if err := acmeDecodePayload(acmeReq.Payload, &payload); err != nil {
+		return nil, err
+	}
+	der, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload.Certificate, "="))
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error decoding certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "error parsing certificate: %v", err)
+	}
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	// Only certificates issued by this backend can be revoked
+	certEntry, err := fetchCertBySerial(req, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry == nil || !bytes.Equal(certEntry.Value, der) {
+		return nil, newACMEProblem(acmeErrUnauthorized, http.StatusNotFound, "certificate was not issued by this server")
+	}
+
+	// Revocation is allowed to the account the certificate was issued to,
+	// and to the holders of its private key
+	if acmeReq.Account != nil {
+		var entry acmeCertEntry
+		if _, err := getACMEEntry(req.Storage, acmeCertPrefix+normalizeSerial(serial), &entry); err != nil {
+			return nil, err
+		}
+		if entry.AccountID != acmeReq.Account.ID {
+			return nil, newACMEProblem(acmeErrUnauthorized, http.StatusForbidden, "certificate was not issued to this account")
+		}
+	} else {
+		if !acmePublicKeyMatches(acmeReq.JWK, cert) {
+			return nil, newACMEProblem(acmeErrUnauthorized, http.StatusForbidden, "request is not signed by the key of the certificate")
+		}
+	}
+
+	revEntry, err := fetchCertBySerial(req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revEntry != nil {
+		return nil, newACMEProblem(acmeErrAlreadyRevoked, http.StatusBadRequest, "certificate is already revoked")
+	}
+
+	b.revokeStorageLock.Lock()
+	resp, err := revokeCert(b, req, serial, false)
+	b.revokeStorageLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.IsError() {
+		return nil, newACMEProblem(acmeErrMalformed, http.StatusBadRequest, "%s", resp.Data["error"])
+	}
+
+	return b.acmeRawResponse(config, http.StatusOK, "", nil, nil)
+}
+
+func acmePublicKeyMatches(key *jose.JSONWebKey, cert *x509.Certificate) bool {
+	if key == nil {
+		return false
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Key)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(der, cert.RawSubjectPublicKeyInfo)
+}
+
+const pathACMEHelpSyn = `
+ACME (RFC 8555) server endpoint.
+`
+
+const pathACMEHelpDesc = `
+The paths under "acme/" implement an ACME server, for use by ACME clients
+rather than directly. Clients start from the directory at "acme/directory".
+The server is enabled and configured with the "config/acme" endpoint; the
+paths do not require a Vault token, as requests are authenticated with the
+key of the ACME account instead.
+`
@@ -0,0 +1,494 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const acmeTestBaseURL = "https://vault.example.com/v1/pki"
+
+// acmeTestChallenges stands in for the web servers and DNS zones of the
+// domains being validated
+type acmeTestChallenges struct {
+	sync.Mutex
+	server *httptest.Server
+	http   map[string]string
+	txt    map[string][]string
+}
+
+func newACMETestChallenges() *acmeTestChallenges {
+	c := &acmeTestChallenges{
+		http: map[string]string{},
+		txt:  map[string][]string{},
+	}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Lock()
+		body, ok := c.http[r.Host+r.URL.Path]
+		c.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	return c
+}
+
+// Get sends every request to the test server, as if all the domains were
+// hosted there
+func (c *acmeTestChallenges) Get(rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", c.server.URL+u.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = u.Host
+	return http.DefaultClient.Do(req)
+}
+
+func (c *acmeTestChallenges) LookupTXT(name string) ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	records, ok := c.txt[name]
+	if !ok {
+		return nil, fmt.Errorf("no such host %s", name)
+	}
+	return records, nil
+}
+
+type acmeTestClient struct {
+	t       *testing.T
+	b       *backend
+	storage logical.Storage
+	key     *ecdsa.PrivateKey
+	kid     string
+	nonce   string
+}
+
+type acmeTestResponse struct {
+	status  int
+	headers map[string][]string
+	body    []byte
+}
+
+func (r *acmeTestResponse) decode(t *testing.T) map[string]interface{} {
+	var ret map[string]interface{}
+	if err := json.Unmarshal(r.body, &ret); err != nil {
+		t.Fatalf("error decoding %s: %v", r.body, err)
+	}
+	return ret
+}
+
+func (r *acmeTestResponse) problem(t *testing.T) string {
+	return r.decode(t)["type"].(string)
+}
+
+func (c *acmeTestClient) handle(op logical.Operation, path string, body []byte) *acmeTestResponse {
+	req := &logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   c.storage,
+	}
+	if body != nil {
+		req.Data = map[string]interface{}{
+			logical.HTTPRawBody: body,
+		}
+	}
+	resp, err := c.b.HandleRequest(req)
+	if err != nil || resp == nil {
+		c.t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+	}
+
+	ret := &acmeTestResponse{
+		status:  resp.Data[logical.HTTPStatusCode].(int),
+		headers: resp.Data[logical.HTTPRawHeaders].(map[string][]string),
+		body:    resp.Data[logical.HTTPRawBody].([]byte),
+	}
+	c.nonce = ret.headers["Replay-Nonce"][0]
+	return ret
+}
+
+// post sends a JWS signed by the client key, identified by the account URL
+// once the account exists; a nil payload makes a POST-as-GET request
+func (c *acmeTestClient) post(path string, payload interface{}) *acmeTestResponse {
+	if c.nonce == "" {
+		c.handle(logical.ReadOperation, "acme/new-nonce", nil)
+	}
+
+	body := []byte{}
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	opts := (&jose.SignerOptions{}).
+		WithHeader("url", acmeTestBaseURL+"/"+path).
+		WithHeader("nonce", c.nonce)
+	if c.kid != "" {
+		opts = opts.WithHeader("kid", c.kid)
+	} else {
+		opts.EmbedJWK = true
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: c.key}, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	jws, err := signer.Sign(body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.handle(logical.UpdateOperation, path, []byte(jws.FullSerialize()))
+}
+
+func (c *acmeTestClient) keyAuthorization(token string) string {
+	thumbprint, err := acmeThumbprint(&jose.JSONWebKey{Key: c.key.Public()})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return token + "." + thumbprint
+}
+
+func TestPki_ACME(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	challenges := newACMETestChallenges()
+	defer challenges.server.Close()
+	b.acmeHTTPClient = challenges
+	b.acmeResolver = challenges
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+		"ttl":         "40h",
+	})
+	doReq(logical.UpdateOperation, "roles/acme", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &acmeTestClient{
+		t:       t,
+		b:       b,
+		storage: storage,
+		key:     key,
+	}
+
+	// ACME is disabled until configured
+	if resp := client.handle(logical.ReadOperation, "acme/directory", nil); resp.status != http.StatusNotFound {
+		t.Fatalf("expected disabled ACME to return 404, got %d", resp.status)
+	}
+	doReq(logical.UpdateOperation, "config/acme", map[string]interface{}{
+		"enabled":  true,
+		"base_url": acmeTestBaseURL,
+		"role":     "acme",
+	})
+
+	directory := client.handle(logical.ReadOperation, "acme/directory", nil).decode(t)
+	if directory["newOrder"] != acmeTestBaseURL+"/acme/new-order" {
+		t.Fatalf("bad directory: %#v", directory)
+	}
+
+	// Accounts are found again by their key
+	resp := client.post("acme/new-account", map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	})
+	if resp.status != http.StatusCreated {
+		t.Fatalf("bad account creation: %d %s", resp.status, resp.body)
+	}
+	kid := resp.headers["Location"][0]
+	resp = client.post("acme/new-account", map[string]interface{}{
+		"onlyReturnExisting": true,
+	})
+	if resp.status != http.StatusOK || resp.headers["Location"][0] != kid {
+		t.Fatalf("expected existing account %s: %d %v", kid, resp.status, resp.headers)
+	}
+	client.kid = kid
+
+	// Nonces can only be used once
+	nonce := client.nonce
+	client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "foo.example.com"}},
+	})
+	client.nonce = nonce
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "foo.example.com"}},
+	})
+	if problem := resp.problem(t); problem != "urn:ietf:params:acme:error:badNonce" {
+		t.Fatalf("expected badNonce, got %s", problem)
+	}
+
+	// Identifiers are checked against the role
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "foo.example.org"}},
+	})
+	if problem := resp.problem(t); problem != "urn:ietf:params:acme:error:rejectedIdentifier" {
+		t.Fatalf("expected rejectedIdentifier, got %s", problem)
+	}
+
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{
+			{Type: "dns", Value: "foo.example.com"},
+			{Type: "dns", Value: "*.example.com"},
+		},
+	})
+	if resp.status != http.StatusCreated {
+		t.Fatalf("bad order creation: %d %s", resp.status, resp.body)
+	}
+	orderURL := resp.headers["Location"][0]
+	order := resp.decode(t)
+
+	// Satisfy http-01 for the plain name and dns-01 for the wildcard
+	for _, authzURL := range order["authorizations"].([]interface{}) {
+		authz := client.post(authzURL.(string)[len(acmeTestBaseURL)+1:], nil).decode(t)
+		domain := authz["identifier"].(map[string]interface{})["value"].(string)
+		challengeType := acmeChallengeHTTP01
+		if authz["wildcard"] == true {
+			challengeType = acmeChallengeDNS01
+		}
+
+		var challenge map[string]interface{}
+		for _, c := range authz["challenges"].([]interface{}) {
+			if c.(map[string]interface{})["type"] == challengeType {
+				challenge = c.(map[string]interface{})
+			}
+		}
+		if challenge == nil {
+			t.Fatalf("no %s challenge for %s: %#v", challengeType, domain, authz)
+		}
+		keyAuth := client.keyAuthorization(challenge["token"].(string))
+
+		challenges.Lock()
+		if challengeType == acmeChallengeHTTP01 {
+			challenges.http[domain+"/.well-known/acme-challenge/"+challenge["token"].(string)] = keyAuth
+		} else {
+			digest := sha256.Sum256([]byte(keyAuth))
+			challenges.txt["_acme-challenge."+domain] = []string{base64.RawURLEncoding.EncodeToString(digest[:])}
+		}
+		challenges.Unlock()
+
+		result := client.post(challenge["url"].(string)[len(acmeTestBaseURL)+1:], map[string]interface{}{}).decode(t)
+		if result["status"] != acmeStatusValid {
+			t.Fatalf("expected %s challenge for %s to be valid: %#v", challengeType, domain, result)
+		}
+	}
+
+	orderPath := orderURL[len(acmeTestBaseURL)+1:]
+	order = client.post(orderPath, nil).decode(t)
+	if order["status"] != acmeStatusReady {
+		t.Fatalf("expected order to be ready: %#v", order)
+	}
+
+	// The CSR must request the identifiers of the order
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalize := func(names ...string) *acmeTestResponse {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: names[0]},
+			DNSNames: names,
+		}, certKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.post(orderPath+"/finalize", map[string]interface{}{
+			"csr": base64.RawURLEncoding.EncodeToString(csr),
+		})
+	}
+	resp = finalize("foo.example.com")
+	if problem := resp.problem(t); problem != "urn:ietf:params:acme:error:badCSR" {
+		t.Fatalf("expected badCSR, got %s", problem)
+	}
+	order = finalize("foo.example.com", "*.example.com").decode(t)
+	if order["status"] != acmeStatusValid {
+		t.Fatalf("expected order to be valid: %#v", order)
+	}
+
+	resp = client.post(order["certificate"].(string)[len(acmeTestBaseURL)+1:], nil)
+	if resp.headers["Link"] == nil || resp.status != http.StatusOK {
+		t.Fatalf("bad certificate response: %d %v", resp.status, resp.headers)
+	}
+	block, rest := pem.Decode(resp.body)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Issuer.CommonName != "example.com" || len(cert.DNSNames) != 2 {
+		t.Fatalf("bad certificate: issuer %s, names %v", cert.Issuer.CommonName, cert.DNSNames)
+	}
+	if block, _ := pem.Decode(rest); block == nil {
+		t.Fatal("expected the certificate chain to hold the issuer")
+	}
+
+	// A failed challenge invalidates the order
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "bar.example.com"}},
+	})
+	failedOrderPath := resp.headers["Location"][0][len(acmeTestBaseURL)+1:]
+	authzURL := resp.decode(t)["authorizations"].([]interface{})[0].(string)
+	authzID := authzURL[len(acmeTestBaseURL+"/acme/authorization/"):]
+	result := client.post("acme/challenge/"+authzID+"/"+acmeChallengeHTTP01, map[string]interface{}{}).decode(t)
+	if result["status"] != acmeStatusInvalid || result["error"] == nil {
+		t.Fatalf("expected challenge to be invalid: %#v", result)
+	}
+	if order := client.post(failedOrderPath, nil).decode(t); order["status"] != acmeStatusInvalid {
+		t.Fatalf("expected order to be invalid: %#v", order)
+	}
+
+	// Certificates are revoked by the account they were issued to
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+	revoke := map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(cert.Raw),
+	}
+	if resp := client.post("acme/revoke-cert", revoke); resp.status != http.StatusOK {
+		t.Fatalf("bad revocation: %d %s", resp.status, resp.body)
+	}
+	if problem := client.post("acme/revoke-cert", revoke).problem(t); problem != "urn:ietf:params:acme:error:alreadyRevoked" {
+		t.Fatalf("expected alreadyRevoked, got %s", problem)
+	}
+	fetched := doReq(logical.ReadOperation, "cert/"+serial, nil)
+	if fetched.Data["revocation_time"].(int64) == 0 {
+		t.Fatalf("expected certificate %s to be revoked", serial)
+	}
+}
+
+func TestPki_ACMEValidIdentifier(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"example.com":                  true,
+		"*.example.com":                true,
+		"foo-bar.example.com":          true,
+		"localhost":                    true,
+		"":                             false,
+		"foo@example.com":              false,
+		"example.com:8080":             false,
+		"example.com/path":             false,
+		"example.com#fragment":         false,
+		"127.0.0.1":                    false,
+		"::1":                          false,
+		"[::1]":                        false,
+		"10.0.0.1.":                    false,
+		"example.123":                  false,
+		"*.*.example.com":              false,
+		"foo.*.example.com":            false,
+		"-foo.example.com":             false,
+		fmt.Sprintf("%0*d.com", 64, 0): false,
+	} {
+		if acmeValidIdentifier(name) != valid {
+			t.Errorf("expected validity of %q to be %v", name, valid)
+		}
+	}
+}
+
+func TestPki_ACMECheckRedirect(t *testing.T) {
+	newReq := func(rawurl string) *http.Request {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	via := []*http.Request{newReq("http://example.com/.well-known/acme-challenge/token")}
+
+	for rawurl, allowed := range map[string]bool{
+		"http://example.com/other":      true,
+		"http://example.com:80/other":   true,
+		"https://example.com/other":     true,
+		"https://example.com:443/other": true,
+		"http://example.com:8080/other": false,
+		"https://example.com:80/other":  false,
+		"http://other.example.com/":     false,
+		"http://127.0.0.1/":             false,
+	} {
+		if err := acmeCheckRedirect(newReq(rawurl), via); (err == nil) != allowed {
+			t.Errorf("expected redirect to %s to be allowed: %v, got %v", rawurl, allowed, err)
+		}
+	}
+
+	for len(via) < acmeHTTP01MaxRedirects {
+		via = append(via, via[0])
+	}
+	if err := acmeCheckRedirect(newReq("http://example.com/"), via); err == nil {
+		t.Fatal("expected too many redirects to be refused")
+	}
+}
+
+func TestPki_ACMENonceStore(t *testing.T) {
+	s := newACMENonceStore()
+	now := time.Now()
+
+	s.add("used", now)
+	if !s.use("used", now) {
+		t.Fatal("expected nonce to be valid")
+	}
+	if s.use("used", now) {
+		t.Fatal("expected nonce to be usable only once")
+	}
+
+	s.add("expired", now)
+	if s.use("expired", now.Add(acmeNonceLifetime)) {
+		t.Fatal("expected nonce to have expired")
+	}
+
+	// Nonces are dropped oldest first once the store is full
+	for i := 0; i < acmeMaxNonces+1; i++ {
+		s.add(fmt.Sprintf("nonce-%d", i), now)
+	}
+	if len(s.queue) > acmeMaxNonces || len(s.expiry) > acmeMaxNonces {
+		t.Fatalf("expected at most %d nonces, got %d queued and %d stored", acmeMaxNonces, len(s.queue), len(s.expiry))
+	}
+	if s.use("nonce-0", now) {
+		t.Fatal("expected the oldest nonce to have been dropped")
+	}
+	if !s.use(fmt.Sprintf("nonce-%d", acmeMaxNonces), now) {
+		t.Fatal("expected the newest nonce to be valid")
+	}
+
+	// Expired nonces are dropped as new ones are added
+	s.add("later", now.Add(acmeNonceLifetime))
+	if len(s.expiry) != 1 {
+		t.Fatalf("expected expired nonces to be dropped, got %d stored", len(s.expiry))
+	}
+}
@@ -0,0 +1,147 @@
+package pki
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// acmeConfig holds the configuration of the ACME server
+type acmeConfig struct {
+	Enabled bool   `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	BaseURL string `json:"base_url" mapstructure:"base_url" structs:"base_url"`
+	Role    string `json:"role" mapstructure:"role" structs:"role"`
+}
+
+// url returns the external URL of a path of this backend
+func (c *acmeConfig) url(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + path
+}
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/acme",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set, the ACME server is enabled`,
+			},
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The URL clients reach this backend at, e.g.
+"https://vault.example.com:8200/v1/pki". ACME
+requests are signed over their URL, so it must
+match what clients use.`,
+			},
+			"role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role whose policy applies to the certificates
+issued over ACME`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func (b *backend) ACME(s logical.Storage) (*acmeConfig, error) {
+	entry, err := s.Get("config/acme")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result acmeConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathACMEConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACME(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":  config.Enabled,
+			"base_url": config.BaseURL,
+			"role":     config.Role,
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &acmeConfig{
+		Enabled: d.Get("enabled").(bool),
+		BaseURL: d.Get("base_url").(string),
+		Role:    d.Get("role").(string),
+	}
+
+	if config.Enabled {
+		if config.BaseURL == "" {
+			return logical.ErrorResponse("base_url is required to enable ACME"), nil
+		}
+		if config.Role == "" {
+			return logical.ErrorResponse("role is required to enable ACME"), nil
+		}
+	}
+
+	if config.BaseURL != "" {
+		u, err := url.Parse(config.BaseURL)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return logical.ErrorResponse(fmt.Sprintf("base_url %q must be an absolute URL", config.BaseURL)), nil
+		}
+	}
+
+	if config.Role != "" {
+		role, err := b.getRole(req.Storage, config.Role)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role %q", config.Role)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/acme", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server of this backend.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint enables the ACME (RFC 8555) server found under "acme/", which
+issues certificates to clients proving control of their domain names through
+the http-01 or dns-01 challenges. All ACME certificates are issued following
+the policy of the configured role.
+`
@@ -0,0 +1,177 @@
+package pki
+
+import (
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// autoTidyConfig holds the configuration of the tidy operation run
+// periodically by the active node
+type autoTidyConfig struct {
+	Enabled            bool `json:"enabled" mapstructure:"enabled" structs:"enabled"`
+	Interval           int  `json:"interval_duration" mapstructure:"interval_duration" structs:"interval_duration"`
+	TidyCertStore      bool `json:"tidy_cert_store" mapstructure:"tidy_cert_store" structs:"tidy_cert_store"`
+	TidyRevocationList bool `json:"tidy_revocation_list" mapstructure:"tidy_revocation_list" structs:"tidy_revocation_list"`
+	SafetyBuffer       int  `json:"safety_buffer" mapstructure:"safety_buffer" structs:"safety_buffer"`
+}
+
+func pathConfigAutoTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/auto-tidy",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set, the backend is tidied periodically`,
+			},
+			"interval_duration": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of time between the start of
+two automatic tidy operations. Defaults to
+12 hours.`,
+				Default: 43200, //12h
+			},
+			"tidy_cert_store": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the certificate store`,
+			},
+			"tidy_revocation_list": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the revocation list`,
+			},
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and/or revocation list.
+Defaults to 72 hours.`,
+				Default: 259200, //72h
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathAutoTidyConfigRead,
+			logical.UpdateOperation: b.pathAutoTidyConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigAutoTidyHelpSyn,
+		HelpDescription: pathConfigAutoTidyHelpDesc,
+	}
+}
+
+func (b *backend) AutoTidy(s logical.Storage) (*autoTidyConfig, error) {
+	entry, err := s.Get("config/auto-tidy")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result autoTidyConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathAutoTidyConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.AutoTidy(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":              config.Enabled,
+			"interval_duration":    config.Interval,
+			"tidy_cert_store":      config.TidyCertStore,
+			"tidy_revocation_list": config.TidyRevocationList,
+			"safety_buffer":        config.SafetyBuffer,
+		},
+	}, nil
+}
+
+func (b *backend) pathAutoTidyConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config := &autoTidyConfig{
+		Enabled:            d.Get("enabled").(bool),
+		Interval:           d.Get("interval_duration").(int),
+		TidyCertStore:      d.Get("tidy_cert_store").(bool),
+		TidyRevocationList: d.Get("tidy_revocation_list").(bool),
+		SafetyBuffer:       d.Get("safety_buffer").(int),
+	}
+
+	if config.Interval <= 0 {
+		return logical.ErrorResponse("interval_duration must be greater than zero"), nil
+	}
+	if config.SafetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must not be negative"), nil
+	}
+	if config.Enabled && !config.TidyCertStore && !config.TidyRevocationList {
+		return logical.ErrorResponse("at least one of tidy_cert_store or tidy_revocation_list must be set to enable auto-tidy"), nil
+	}
+
+	entry, err := logical.StorageEntryJSON("config/auto-tidy", config)
+	if err != nil {
+		return nil, err
+	}
+	err = req.Storage.Put(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// autoTidy runs the tidy operation when it is enabled and the configured
+// interval has passed since the last automatic run
+func (b *backend) autoTidy(req *logical.Request) error {
+	config, err := b.AutoTidy(req.Storage)
+	if err != nil {
+		return err
+	}
+	if config == nil || !config.Enabled {
+		return nil
+	}
+
+	interval := time.Duration(config.Interval) * time.Second
+	b.tidyStatusLock.Lock()
+	err = b.loadTidyStatus(req.Storage)
+	lastAutoTidy := b.lastAutoTidy
+	b.tidyStatusLock.Unlock()
+	if err != nil {
+		return err
+	}
+	if !lastAutoTidy.IsZero() && time.Now().Before(lastAutoTidy.Add(interval)) {
+		return nil
+	}
+
+	err = b.tidy(req, true, config.TidyCertStore, config.TidyRevocationList, false, config.SafetyBuffer)
+	if err == errTidyInProgress {
+		// A manual tidy is running; try again on the next tick
+		return nil
+	}
+	return err
+}
+
+const pathConfigAutoTidyHelpSyn = `
+Configure the automatic tidying of the backend.
+`
+
+const pathConfigAutoTidyHelpDesc = `
+This endpoint configures the tidy operation run by the active node every
+'interval_duration', with the same 'tidy_cert_store', 'tidy_revocation_list'
+and 'safety_buffer' parameters as the 'tidy' endpoint. The first automatic run
+happens shortly after it is enabled; the time of the last one is kept in
+storage, so that restarts do not cause additional runs. The progress and
+result of the runs can be read from 'tidy-status'.
+`
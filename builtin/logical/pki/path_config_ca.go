@@ -1,8 +1,6 @@
 package pki
 
 import (
-	"fmt"
-
 	"github.com/hashicorp/vault/helper/certutil"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
@@ -56,32 +54,27 @@ func (b *backend) pathCAWrite(
 		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	cb, err := parsedBundle.ToCertBundle()
+	issuer, key, err := importBundle(req.Storage, parsedBundle, "", "")
 	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
-	}
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
-	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location, plus a fresh CRL
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(entry)
-	if err != nil {
+	// Build a fresh CRL
+	if err := buildCRL(b, req); err != nil {
 		return nil, err
 	}
 
-	err = buildCRL(b, req)
-
-	return nil, err
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+			"key_id":    key.ID,
+		},
+	}, nil
 }
 
 const pathConfigCAHelpSyn = `
@@ -93,6 +86,10 @@ This sets the CA information used for credentials generated by this
 by this mount. This must be a PEM-format, concatenated unencrypted
 secret key and certificate.
 
+The certificate and key are imported as a new issuer and key of the mount,
+unless they already exist; the first issuer of a mount becomes its default
+issuer.
+
 For security reasons, the secret key cannot be retrieved later.
 `
 
@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry" structs:"expiry"`
+	OCSPDisable            bool   `json:"ocsp_disable" mapstructure:"ocsp_disable" structs:"ocsp_disable"`
+	OCSPExpiry             string `json:"ocsp_expiry" mapstructure:"ocsp_expiry" structs:"ocsp_expiry"`
+	AutoRebuild            bool   `json:"auto_rebuild" mapstructure:"auto_rebuild" structs:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period" mapstructure:"auto_rebuild_grace_period" structs:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta" mapstructure:"enable_delta" structs:"enable_delta"`
+	DeltaRebuildInterval   string `json:"delta_rebuild_interval" mapstructure:"delta_rebuild_interval" structs:"delta_rebuild_interval"`
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -23,6 +30,42 @@ func pathConfigCRL(b *backend) *framework.Path {
 valid; defaults to 72 hours`,
 				Default: "72h",
 			},
+			"ocsp_disable": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set, the OCSP responder is disabled`,
+			},
+			"ocsp_expiry": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The amount of time OCSP responses should be
+valid; defaults to 12 hours. If set to 0, responses
+do not carry a next update time.`,
+				Default: "12h",
+			},
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, CRLs are rebuilt in the background
+before they expire`,
+			},
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before their expiry CRLs are
+rebuilt when auto_rebuild is set; defaults to
+12 hours. Must be shorter than expiry.`,
+				Default: "12h",
+			},
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, delta CRLs are built along with the
+full CRLs, which are then only rebuilt on expiry,
+rotation or tidying. Requires auto_rebuild.`,
+			},
+			"delta_rebuild_interval": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How often the delta CRLs are rebuilt to list
+newly revoked certificates when enable_delta is
+set; defaults to 15 minutes`,
+				Default: "15m",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -64,7 +107,13 @@ func (b *backend) pathCRLRead(
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry": config.Expiry,
+			"expiry":                    config.Expiry,
+			"ocsp_disable":              config.OCSPDisable,
+			"ocsp_expiry":               config.OCSPExpiry,
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
+			"delta_rebuild_interval":    config.DeltaRebuildInterval,
 		},
 	}, nil
 }
@@ -73,13 +122,58 @@ func (b *backend) pathCRLWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	expiry := d.Get("expiry").(string)
 
-	_, err := time.ParseDuration(expiry)
+	expiryDur, err := time.ParseDuration(expiry)
 	if err != nil {
 		return logical.ErrorResponse(fmt.Sprintf("Given expiry could not be decoded: %s", err)), nil
 	}
 
+	ocspExpiry := d.Get("ocsp_expiry").(string)
+	_, err = time.ParseDuration(ocspExpiry)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given ocsp_expiry could not be decoded: %s", err)), nil
+	}
+
+	autoRebuild := d.Get("auto_rebuild").(bool)
+	gracePeriod := d.Get("auto_rebuild_grace_period").(string)
+	gracePeriodDur, err := time.ParseDuration(gracePeriod)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+	}
+	// A CRL rebuilt with a grace period at least as long as its lifetime
+	// would be due for a rebuild again right away
+	if autoRebuild && gracePeriodDur >= expiryDur {
+		return logical.ErrorResponse("auto_rebuild_grace_period must be shorter than expiry"), nil
+	}
+
+	enableDelta := d.Get("enable_delta").(bool)
+	deltaInterval := d.Get("delta_rebuild_interval").(string)
+	deltaIntervalDur, err := time.ParseDuration(deltaInterval)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Given delta_rebuild_interval could not be decoded: %s", err)), nil
+	}
+	if enableDelta {
+		// Revocations only make it to the full CRLs when they are rebuilt
+		if !autoRebuild {
+			return logical.ErrorResponse("enable_delta requires auto_rebuild"), nil
+		}
+		if deltaIntervalDur <= 0 || deltaIntervalDur >= expiryDur {
+			return logical.ErrorResponse("delta_rebuild_interval must be positive and shorter than expiry"), nil
+		}
+	}
+
+	oldConfig, err := b.CRL(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
 	config := &crlConfig{
-		Expiry: expiry,
+		Expiry:                 expiry,
+		OCSPDisable:            d.Get("ocsp_disable").(bool),
+		OCSPExpiry:             ocspExpiry,
+		AutoRebuild:            autoRebuild,
+		AutoRebuildGracePeriod: gracePeriod,
+		EnableDelta:            enableDelta,
+		DeltaRebuildInterval:   deltaInterval,
 	}
 
 	entry, err := logical.StorageEntryJSON("config/crl", config)
@@ -91,13 +185,39 @@ func (b *backend) pathCRLWrite(
 		return nil, err
 	}
 
+	// Full CRLs point to their delta CRLs, so they are rebuilt when delta
+	// CRLs are enabled or disabled
+	if (oldConfig != nil && oldConfig.EnableDelta) != enableDelta {
+		b.revokeStorageLock.RLock()
+		defer b.revokeStorageLock.RUnlock()
+
+		crlErr := buildCRL(b, req)
+		switch crlErr.(type) {
+		case errutil.UserError:
+			// Nothing to rebuild without a CA
+		case errutil.InternalError:
+			return nil, fmt.Errorf("Error encountered during CRL building: %s", crlErr)
+		}
+	}
+
 	return nil, nil
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding, and the OCSP responder.
 `
 
 const pathConfigCRLHelpDesc = `
-This endpoint allows configuration of the CRL lifetime.
+This endpoint allows configuration of the CRL lifetime, as well as of the
+OCSP responder and the lifetime of its responses.
+
+When 'auto_rebuild' is set, the CRLs are rebuilt by the active node once they
+are within 'auto_rebuild_grace_period' of their expiry, so that they stay
+valid even when no certificate is revoked for longer than their lifetime.
+
+When 'enable_delta' is also set, revoking a certificate no longer rebuilds the
+full CRLs. Instead, the active node builds delta CRLs (RFC 5280 section 5.2.4)
+every 'delta_rebuild_interval', listing the certificates revoked since the
+last full CRL. Delta CRLs are fetched from "crl/delta", and full CRLs point to
+them through the 'delta_crl_distribution_points' set in 'config/urls'.
 `
@@ -0,0 +1,83 @@
+package pki
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the default issuer`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuersConfigRead,
+			logical.UpdateOperation: b.pathIssuersConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+func (b *backend) pathIssuersConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuer,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuersConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse(`"default" must be the ID or name of an issuer`), nil
+	}
+
+	id, err := resolveIssuerRef(req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", ref)), nil
+	}
+
+	config, err := getIssuersConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	config.DefaultIssuer = id
+	if err := setIssuersConfig(req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuer,
+		},
+	}, nil
+}
+
+const pathConfigIssuersHelpSyn = `
+Configure the default issuer of this backend.
+`
+
+const pathConfigIssuersHelpDesc = `
+This path sets the issuer used when no other issuer is specified, e.g. by
+roles whose "issuer_ref" is "default", and whose certificate and CRL are
+served at the "ca" and "crl" paths.
+`
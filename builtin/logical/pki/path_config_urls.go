@@ -25,6 +25,13 @@ for the issuing certificate attribute`,
 for the CRL distribution points attribute`,
 			},
 
+			"delta_crl_distribution_points": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of URLs to be used
+for the Freshest CRL attribute of CRLs, pointing
+to their delta CRLs`,
+			},
+
 			"ocsp_servers": &framework.FieldSchema{
 				Type: framework.TypeCommaStringSlice,
 				Description: `Comma-separated list of URLs to be used
@@ -111,9 +118,10 @@ func (b *backend) pathWriteURL(
 	}
 	if entries == nil {
 		entries = &urlEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
+			IssuingCertificates:        []string{},
+			CRLDistributionPoints:      []string{},
+			DeltaCRLDistributionPoints: []string{},
+			OCSPServers:                []string{},
 		}
 	}
 
@@ -131,6 +139,13 @@ func (b *backend) pathWriteURL(
 				"invalid URL found in CRL distribution points: %s", badURL)), nil
 		}
 	}
+	if urlsInt, ok := data.GetOk("delta_crl_distribution_points"); ok {
+		entries.DeltaCRLDistributionPoints = urlsInt.([]string)
+		if badURL := validateURLs(entries.DeltaCRLDistributionPoints); badURL != "" {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"invalid URL found in delta CRL distribution points: %s", badURL)), nil
+		}
+	}
 	if urlsInt, ok := data.GetOk("ocsp_servers"); ok {
 		entries.OCSPServers = urlsInt.([]string)
 		if badURL := validateURLs(entries.OCSPServers); badURL != "" {
@@ -143,9 +158,10 @@ func (b *backend) pathWriteURL(
 }
 
 type urlEntries struct {
-	IssuingCertificates   []string `json:"issuing_certificates" structs:"issuing_certificates" mapstructure:"issuing_certificates"`
-	CRLDistributionPoints []string `json:"crl_distribution_points" structs:"crl_distribution_points" mapstructure:"crl_distribution_points"`
-	OCSPServers           []string `json:"ocsp_servers" structs:"ocsp_servers" mapstructure:"ocsp_servers"`
+	IssuingCertificates        []string `json:"issuing_certificates" structs:"issuing_certificates" mapstructure:"issuing_certificates"`
+	CRLDistributionPoints      []string `json:"crl_distribution_points" structs:"crl_distribution_points" mapstructure:"crl_distribution_points"`
+	DeltaCRLDistributionPoints []string `json:"delta_crl_distribution_points" structs:"delta_crl_distribution_points" mapstructure:"delta_crl_distribution_points"`
+	OCSPServers                []string `json:"ocsp_servers" structs:"ocsp_servers" mapstructure:"ocsp_servers"`
 }
 
 const pathConfigURLsHelpSyn = `
@@ -154,7 +170,8 @@ Set the URLs for the issuing CA, CRL distribution points, and OCSP servers.
 
 const pathConfigURLsHelpDesc = `
 This path allows you to set the issuing CA, CRL distribution points, and
-OCSP server URLs that will be encoded into issued certificates. If these
+OCSP server URLs that will be encoded into issued certificates, as well as the
+delta CRL distribution points encoded into full CRLs. If these
 values are not set, no such information will be encoded in the issued
 certificates. To delete URLs, simply re-set the appropriate value with an
 empty string.
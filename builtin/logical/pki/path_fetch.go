@@ -37,10 +37,10 @@ func pathFetchCAChain(b *backend) *framework.Path {
 	}
 }
 
-// Returns the CRL in raw format
+// Returns the CRL or delta CRL in raw format
 func pathFetchCRL(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `crl(/pem)?`,
+		Pattern: `crl(/delta)?(/pem)?`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -73,10 +73,10 @@ hyphen-separated octal`,
 	}
 }
 
-// This returns the CRL in a non-raw format
+// This returns the CRL or delta CRL in a non-raw format
 func pathFetchCRLViaCertPath(b *backend) *framework.Path {
 	return &framework.Path{
-		Pattern: `cert/crl`,
+		Pattern: `cert/(delta-)?crl`,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.ReadOperation: b.pathFetchRead,
@@ -146,6 +146,15 @@ func (b *backend) pathFetchRead(req *logical.Request, data *framework.FieldData)
 	case req.Path == "cert/crl":
 		serial = "crl"
 		pemType = "X509 CRL"
+	case req.Path == "crl/delta" || req.Path == "crl/delta/pem":
+		serial = "delta_crl"
+		contentType = "application/pkix-crl"
+		if req.Path == "crl/delta/pem" {
+			pemType = "X509 CRL"
+		}
+	case req.Path == "cert/delta-crl":
+		serial = "delta_crl"
+		pemType = "X509 CRL"
 	default:
 		serial = data.Get("serial").(string)
 		pemType = "CERTIFICATE"
@@ -156,7 +165,7 @@ func (b *backend) pathFetchRead(req *logical.Request, data *framework.FieldData)
 	}
 
 	if serial == "ca_chain" {
-		caInfo, err := fetchCAInfo(req)
+		caChain, err := fetchIssuerChain(req, defaultRef)
 		switch err.(type) {
 		case errutil.UserError:
 			response = logical.ErrorResponse(err.Error())
@@ -166,7 +175,6 @@ func (b *backend) pathFetchRead(req *logical.Request, data *framework.FieldData)
 			goto reply
 		}
 
-		caChain := caInfo.GetCAChain()
 		for _, ca := range caChain {
 			block := pem.Block{
 				Type:  "CERTIFICATE",
@@ -258,7 +266,7 @@ reply:
 }
 
 const pathFetchHelpSyn = `
-Fetch a CA, CRL, CA Chain, or non-revoked certificate.
+Fetch a CA, CRL, delta CRL, CA Chain, or non-revoked certificate.
 `
 
 const pathFetchHelpDesc = `
@@ -266,5 +274,7 @@ This allows certificates to be fetched. If using the fetch/ prefix any non-revok
 
 Using "ca" or "crl" as the value fetches the appropriate information in DER encoding. Add "/pem" to either to get PEM encoding.
 
+Using "crl/delta" fetches the delta CRL in DER encoding, or in PEM encoding with "crl/delta/pem".
+
 Using "ca_chain" as the value fetches the certificate authority trust chain in PEM encoding.
 `
@@ -24,6 +24,10 @@ func pathGenerateIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+	ret.Fields["key_name"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name of the new key.`,
+	}
 
 	return ret
 }
@@ -40,6 +44,11 @@ certificate with a public key matching the
 previously-generated key from the generation
 endpoint.`,
 			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Optional name of the new issuer.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -62,6 +71,16 @@ func (b *backend) pathGenerateIntermediate(
 		return errorResp, nil
 	}
 
+	keyName := data.Get("key_name").(string)
+	if err := validateName(req.Storage, keyName, "", false); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
 	var resp *logical.Response
 	parsedBundle, err := generateIntermediateCSR(b, role, nil, req, data)
 	if err != nil {
@@ -106,18 +125,17 @@ func (b *backend) pathGenerateIntermediate(
 		}
 	}
 
-	cb := &certutil.CertBundle{}
-	cb.PrivateKey = csrb.PrivateKey
-	cb.PrivateKeyType = csrb.PrivateKeyType
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
+	// Store the key on its own; it becomes an issuer once the signed
+	// certificate is set
+	_, key, err := importBundle(req.Storage, &certutil.ParsedCertBundle{
+		PrivateKeyType:  parsedBundle.PrivateKeyType,
+		PrivateKeyBytes: parsedBundle.PrivateKeyBytes,
+		PrivateKey:      parsedBundle.PrivateKey,
+	}, "", keyName)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["key_id"] = key.ID
 
 	return resp, nil
 }
@@ -144,24 +162,32 @@ func (b *backend) pathSetSignedIntermediate(
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
-	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get("config/ca_bundle")
-	if err != nil {
-		return nil, err
+	if !inputBundle.Certificate.IsCA {
+		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
-	if entry == nil {
-		return logical.ErrorResponse("could not find any existing entry with a private key"), nil
+
+	issuerName := data.Get("issuer_name").(string)
+	if err := validateName(req.Storage, issuerName, "", true); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	err = entry.DecodeJSON(cb)
+	key, err := findKey(req.Storage, inputBundle.Certificate.PublicKey)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(cb.PrivateKey) == 0 || cb.PrivateKeyType == "" {
-		return logical.ErrorResponse("could not find an existing private key"), nil
+	if key == nil {
+		return logical.ErrorResponse("could not find an existing private key matching the certificate"), nil
 	}
 
+	cb := &certutil.CertBundle{
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+	}
 	parsedCB, err := cb.ToParsedCertBundle()
 	if err != nil {
 		return nil, err
@@ -174,48 +200,39 @@ func (b *backend) pathSetSignedIntermediate(
 	inputBundle.PrivateKeyType = parsedCB.PrivateKeyType
 	inputBundle.PrivateKeyBytes = parsedCB.PrivateKeyBytes
 
-	if !inputBundle.Certificate.IsCA {
-		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
-	}
-
 	if err := inputBundle.Verify(); err != nil {
 		return nil, fmt.Errorf("verification of parsed bundle failed: %s", err)
 	}
 
-	cb, err = inputBundle.ToCertBundle()
-	if err != nil {
-		return nil, fmt.Errorf("error converting raw values into cert bundle: %s", err)
-	}
-
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
+	issuer, _, err := importBundle(req.Storage, inputBundle, issuerName, "")
 	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	entry.Key = "certs/" + normalizeSerial(cb.SerialNumber)
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(entry)
+	err = req.Storage.Put(&logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(issuer.SerialNumber),
+		Value: inputBundle.CertificateBytes,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(entry)
-	if err != nil {
+	// Build a fresh CRL
+	if err := buildCRL(b, req); err != nil {
 		return nil, err
 	}
 
-	// Build a fresh CRL
-	err = buildCRL(b, req)
-
-	return nil, err
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id": issuer.ID,
+			"key_id":    key.ID,
+		},
+	}, nil
 }
 
 const pathGenerateIntermediateHelpSyn = `
@@ -72,6 +72,8 @@ taken verbatim from the CSR, except for
 basic constraints.`,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
@@ -133,11 +135,14 @@ func (b *backend) pathSignVerbatim(
 		AllowLocalhost:   true,
 		AllowAnyName:     true,
 		AllowIPSANs:      true,
+		AllowedURISANs:   "*",
+		AllowedOtherSANs: "*",
 		EnforceHostnames: false,
 		KeyType:          "any",
 		UseCSRCommonName: true,
 		UseCSRSANs:       true,
 		GenerateLease:    new(bool),
+		IssuerRef:        data.Get("issuer_ref").(string),
 	}
 
 	if role != nil {
@@ -148,6 +153,9 @@ func (b *backend) pathSignVerbatim(
 			entry.MaxTTL = role.MaxTTL
 		}
 		entry.NoStore = role.NoStore
+		if _, ok := data.GetOk("issuer_ref"); !ok {
+			entry.IssuerRef = role.IssuerRef
+		}
 	}
 
 	*entry.GenerateLease = false
@@ -167,7 +175,7 @@ func (b *backend) pathIssueSignCert(
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(req)
+	signingBundle, caErr := fetchCAInfo(req, role.IssuerRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -0,0 +1,361 @@
+package pki
+
+import (
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathIssuerList,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `"default", or the ID or name of the issuer`,
+			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `New name of the issuer`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuersHelpSyn,
+		HelpDescription: pathIssuersHelpDesc,
+	}
+}
+
+func pathImportIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import/bundle",
+		Fields: map[string]*framework.FieldSchema{
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated certificate and
+its chain, optionally along with its
+unencrypted secret key.`,
+			},
+
+			"issuer_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Optional name of the new issuer.`,
+			},
+
+			"key_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Optional name of the new key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuerImport,
+		},
+
+		HelpSynopsis:    pathImportIssuersHelpSyn,
+		HelpDescription: pathImportIssuersHelpDesc,
+	}
+}
+
+// Returns the certificate, the CRL or the delta CRL of an issuer, either as
+// JSON or raw
+func pathFetchIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "cert/issuer/" + framework.GenericNameRegex("issuer_ref") + `(/crl(/delta)?)?(/der)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `"default", or the ID or name of the issuer`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuer,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+func (b *backend) pathIssuerList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listIssuers(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(ids), nil
+}
+
+// getIssuerByRef returns the issuer referred to by ref, or nil if there is
+// none
+func getIssuerByRef(s logical.Storage, ref string) (*issuerEntry, error) {
+	id, err := resolveIssuerRef(s, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	return fetchIssuer(s, id)
+}
+
+func issuerResponse(issuer *issuerEntry) *logical.Response {
+	caChain := issuer.CAChain
+	if caChain == nil {
+		caChain = []string{}
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":     issuer.ID,
+			"issuer_name":   issuer.Name,
+			"key_id":        issuer.KeyID,
+			"certificate":   issuer.Certificate,
+			"ca_chain":      caChain,
+			"serial_number": issuer.SerialNumber,
+		},
+	}
+}
+
+func (b *backend) pathIssuerRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := getIssuerByRef(req.Storage, data.Get("issuer_ref").(string))
+	if err != nil || issuer == nil {
+		return nil, err
+	}
+
+	return issuerResponse(issuer), nil
+}
+
+func (b *backend) pathIssuerWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerRef := data.Get("issuer_ref").(string)
+	issuer, err := getIssuerByRef(req.Storage, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", issuerRef)), nil
+	}
+
+	name := data.Get("issuer_name").(string)
+	if err := validateName(req.Storage, name, issuer.ID, true); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	issuer.Name = name
+	if err := writeIssuer(req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	return issuerResponse(issuer), nil
+}
+
+func (b *backend) pathIssuerDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, err := getIssuerByRef(req.Storage, data.Get("issuer_ref").(string))
+	if err != nil || issuer == nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuer == issuer.ID {
+		config.DefaultIssuer = ""
+		if err := setIssuersConfig(req.Storage, config); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range []string{issuerPrefix + issuer.ID, issuerCRLPrefix + issuer.ID, issuerDeltaCRLPrefix + issuer.ID, issuerCRLStatePrefix + issuer.ID} {
+		if err := req.Storage.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathIssuerImport(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	parsedBundle, err := certutil.ParsePEMBundle(data.Get("pem_bundle").(string))
+	if err != nil {
+		switch err.(type) {
+		case errutil.InternalError:
+			return nil, err
+		default:
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	issuer, key, err := importBundle(req.Storage, parsedBundle,
+		data.Get("issuer_name").(string), data.Get("key_name").(string))
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{},
+	}
+	if issuer != nil {
+		resp.Data["issuer_id"] = issuer.ID
+	}
+	if key != nil {
+		resp.Data["key_id"] = key.ID
+	}
+
+	// A new issuer able to sign needs a CRL
+	if issuer != nil && issuer.KeyID != "" {
+		if err := buildCRL(b, req); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathFetchIssuer(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuerRef := data.Get("issuer_ref").(string)
+	delta := strings.HasSuffix(req.Path, "/crl/delta") || strings.HasSuffix(req.Path, "/crl/delta/der")
+	crl := delta || strings.HasSuffix(req.Path, "/crl") || strings.HasSuffix(req.Path, "/crl/der")
+	der := strings.HasSuffix(req.Path, "/der")
+
+	var entry *logical.StorageEntry
+	var err error
+	if delta {
+		entry, err = fetchIssuerDeltaCRL(req, issuerRef)
+	} else {
+		entry, err = fetchIssuerBytes(req, issuerRef, crl)
+	}
+	if err != nil && !der {
+		return nil, err
+	}
+
+	contentType := "application/pkix-cert"
+	pemType := "CERTIFICATE"
+	if crl {
+		contentType = "application/pkix-crl"
+		pemType = "X509 CRL"
+	}
+
+	if der {
+		// As for the other raw paths, problems cannot be reported
+		if err != nil {
+			b.Logger().Warn("pki: error fetching issuer", "issuer_ref", issuerRef, "error", err)
+		}
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPContentType: contentType,
+				logical.HTTPRawBody:     []byte{},
+				logical.HTTPStatusCode:  204,
+			},
+		}
+		if entry != nil && len(entry.Value) > 0 {
+			resp.Data[logical.HTTPRawBody] = entry.Value
+			resp.Data[logical.HTTPStatusCode] = 200
+		}
+		return resp, nil
+	}
+
+	if entry == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{},
+	}
+	if crl {
+		resp.Data["crl"] = string(pem.EncodeToMemory(&pem.Block{
+			Type:  pemType,
+			Bytes: entry.Value,
+		}))
+		return resp, nil
+	}
+
+	resp.Data["certificate"] = string(pem.EncodeToMemory(&pem.Block{
+		Type:  pemType,
+		Bytes: entry.Value,
+	}))
+	caChain, err := fetchIssuerChain(req, issuerRef)
+	if err != nil {
+		return nil, err
+	}
+	chain := make([]string, 0, len(caChain))
+	for _, ca := range caChain {
+		chain = append(chain, string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: ca.Bytes,
+		})))
+	}
+	resp.Data["ca_chain"] = chain
+
+	return resp, nil
+}
+
+const pathListIssuersHelpSyn = `List the issuers of this backend`
+
+const pathListIssuersHelpDesc = `Issuers will be listed by their ID.`
+
+const pathIssuersHelpSyn = `Manage an issuer of this backend.`
+
+const pathIssuersHelpDesc = `
+This path reads, renames or deletes an issuer, referred to by "default", its
+ID or its name. Deleting the default issuer leaves the backend without a
+default issuer until one is set with "config/issuers".
+`
+
+const pathImportIssuersHelpSyn = `Import CA certificates and keys as issuers.`
+
+const pathImportIssuersHelpDesc = `
+This path imports a PEM bundle holding a CA certificate and its chain,
+optionally along with its private key. The certificate becomes a new issuer,
+linked with the key matching its public key, if the backend has one.
+Certificates and keys already known to the backend are not imported again.
+`
+
+const pathFetchIssuerHelpSyn = `Fetch the certificate or the CRL of an issuer.`
+
+const pathFetchIssuerHelpDesc = `
+This path returns the certificate and chain of an issuer, or its CRL when
+ending in "/crl", or its delta CRL when ending in "/crl/delta". When ending
+in "/der", the certificate or CRL is returned DER encoded rather than as JSON.
+It does not require authentication.
+`
@@ -0,0 +1,296 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_MultipleIssuers(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-a.com",
+		"ttl":         "40h",
+		"issuer_name": "root-a",
+		"key_name":    "key-a",
+	})
+	issuerA := resp.Data["issuer_id"].(string)
+	resp = doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-b.com",
+		"ttl":         "40h",
+		"issuer_name": "root-b",
+	})
+	issuerB := resp.Data["issuer_id"].(string)
+
+	resp = doReq(logical.ListOperation, "issuers/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two issuers, got %v", keys)
+	}
+	resp = doReq(logical.ListOperation, "keys/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two keys, got %v", keys)
+	}
+
+	// The first issuer is the default one
+	resp = doReq(logical.ReadOperation, "config/issuers", nil)
+	if resp.Data["default"] != issuerA {
+		t.Fatalf("expected default issuer %s, got %v", issuerA, resp.Data["default"])
+	}
+	resp = doReq(logical.ReadOperation, "issuer/default", nil)
+	if resp.Data["issuer_id"] != issuerA || resp.Data["issuer_name"] != "root-a" {
+		t.Fatalf("bad default issuer: %#v", resp.Data)
+	}
+
+	// Names must be unique
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/internal",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "root-c.com",
+			"issuer_name": "root-a",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected duplicate issuer name to be rejected: err: %v resp: %#v", err, resp)
+	}
+
+	// Roles sign with their issuer, which must exist
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/bad",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"allow_any_name": true,
+			"issuer_ref":     "root-c",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected unknown issuer to be rejected: err: %v resp: %#v", err, resp)
+	}
+
+	doReq(logical.UpdateOperation, "roles/a", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	doReq(logical.UpdateOperation, "roles/b", map[string]interface{}{
+		"allow_any_name": true,
+		"issuer_ref":     "root-b",
+	})
+	resp = doReq(logical.ReadOperation, "roles/a", nil)
+	if resp.Data["issuer_ref"] != "default" {
+		t.Fatalf("bad issuer_ref: %v", resp.Data["issuer_ref"])
+	}
+
+	issue := func(role string) (*x509.Certificate, string) {
+		resp := doReq(logical.UpdateOperation, "issue/"+role, map[string]interface{}{
+			"common_name": "foo.com",
+		})
+		block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, resp.Data["serial_number"].(string)
+	}
+	certA, _ := issue("a")
+	if certA.Issuer.CommonName != "root-a.com" {
+		t.Fatalf("expected certificate issued by root-a.com, got %s", certA.Issuer.CommonName)
+	}
+	certB, serialB := issue("b")
+	if certB.Issuer.CommonName != "root-b.com" {
+		t.Fatalf("expected certificate issued by root-b.com, got %s", certB.Issuer.CommonName)
+	}
+
+	// Revoked certificates are listed in the CRL of their issuer only
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serialB,
+	})
+	crlSerials := func(issuerRef string) []string {
+		resp := doReq(logical.ReadOperation, "cert/issuer/"+issuerRef+"/crl/der", nil)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var serials []string
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			serials = append(serials, certutil.GetHexFormatted(revoked.SerialNumber.Bytes(), ":"))
+		}
+		return serials
+	}
+	if serials := crlSerials("root-b"); len(serials) != 1 || serials[0] != serialB {
+		t.Fatalf("expected CRL of root-b to list %s, got %v", serialB, serials)
+	}
+	if serials := crlSerials(issuerA); len(serials) != 0 {
+		t.Fatalf("expected CRL of root-a to be empty, got %v", serials)
+	}
+
+	// The default issuer backs the legacy CA and CRL paths
+	doReq(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "root-b",
+	})
+	resp = doReq(logical.ReadOperation, "ca", nil)
+	caCert, err := x509.ParseCertificate(resp.Data[logical.HTTPRawBody].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caCert.Subject.CommonName != "root-b.com" {
+		t.Fatalf("expected default CA root-b.com, got %s", caCert.Subject.CommonName)
+	}
+	resp = doReq(logical.ReadOperation, "cert/issuer/default", nil)
+	if !strings.Contains(resp.Data["certificate"].(string), "BEGIN CERTIFICATE") {
+		t.Fatalf("bad certificate: %#v", resp.Data)
+	}
+
+	// Keys in use cannot be deleted; issuers can
+	resp, err = b.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "key/key-a",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected deletion of key in use to be rejected: err: %v resp: %#v", err, resp)
+	}
+	doReq(logical.DeleteOperation, "issuer/root-a", nil)
+	doReq(logical.DeleteOperation, "key/key-a", nil)
+	resp = doReq(logical.ListOperation, "issuers/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != issuerB {
+		t.Fatalf("expected only %s left, got %v", issuerB, keys)
+	}
+}
+
+func TestPki_MigrateCABundle(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "root/generate/exported", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	cb := &certutil.CertBundle{
+		Certificate:    resp.Data["certificate"].(string),
+		PrivateKey:     resp.Data["private_key"].(string),
+		PrivateKeyType: resp.Data["private_key_type"].(certutil.PrivateKeyType),
+	}
+	doReq(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "myvault.com",
+		"allow_subdomains": true,
+	})
+	resp = doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "foo.myvault.com",
+	})
+	serial := resp.Data["serial_number"].(string)
+
+	// Rewind the storage to the layout of a mount with a single CA
+	for _, prefix := range []string{issuerPrefix, keyPrefix, issuerCRLPrefix} {
+		ids, err := storage.List(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range ids {
+			if err := storage.Delete(prefix + id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := storage.Delete(issuersConfigPath); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := logical.StorageEntryJSON(legacyCABundlePath, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// The CA bundle is migrated when the mount is loaded, however many times
+	// that happens
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	for i := 0; i < 2; i++ {
+		loaded, err := Factory(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = loaded.(*backend)
+	}
+
+	// Certificates issued before the migration revoke against the migrated
+	// issuer
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+
+	resp = doReq(logical.ListOperation, "issuers/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 {
+		t.Fatalf("expected one migrated issuer, got %v", keys)
+	}
+	resp = doReq(logical.ListOperation, "keys/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 {
+		t.Fatalf("expected one migrated key, got %v", keys)
+	}
+	resp = doReq(logical.ReadOperation, "issuer/default", nil)
+	if resp.Data["key_id"] == "" {
+		t.Fatalf("expected the migrated issuer to have a key: %#v", resp.Data)
+	}
+
+	// The legacy CA bundle is kept until it is tidied
+	if entry, err := storage.Get(legacyCABundlePath); err != nil || entry == nil {
+		t.Fatalf("expected legacy CA bundle to be kept: err: %v", err)
+	}
+	doReq(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_legacy_ca_bundle": true,
+	})
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStateFinished || resp.Data["legacy_ca_bundle_removed"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if entry, err := storage.Get(legacyCABundlePath); err != nil || entry != nil {
+		t.Fatalf("expected legacy CA bundle to be removed: err: %v entry: %#v", err, entry)
+	}
+
+	resp = doReq(logical.ReadOperation, "crl", nil)
+	crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := crl.TBSCertList.RevokedCertificates
+	if len(revoked) != 1 || certutil.GetHexFormatted(revoked[0].SerialNumber.Bytes(), ":") != serial {
+		t.Fatalf("expected CRL to list %s, got %#v", serial, revoked)
+	}
+
+	// The mount keeps issuing certificates
+	doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "bar.myvault.com",
+	})
+}
@@ -0,0 +1,243 @@
+package pki
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathListKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathKeyList,
+		},
+
+		HelpSynopsis:    pathListKeysHelpSyn,
+		HelpDescription: pathListKeysHelpDesc,
+	}
+}
+
+func pathKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("key_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID or name of the key`,
+			},
+
+			"key_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `New name of the key`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.UpdateOperation: b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeysHelpSyn,
+		HelpDescription: pathKeysHelpDesc,
+	}
+}
+
+func pathGenerateKey(b *backend) *framework.Path {
+	ret := &framework.Path{
+		Pattern: "keys/generate/" + framework.GenericNameRegex("exported"),
+
+		Fields: map[string]*framework.FieldSchema{
+			"key_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Optional name of the new key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathKeyGenerate,
+		},
+
+		HelpSynopsis:    pathGenerateKeyHelpSyn,
+		HelpDescription: pathGenerateKeyHelpDesc,
+	}
+
+	ret.Fields = addCAKeyGenerationFields(ret.Fields)
+
+	return ret
+}
+
+func (b *backend) pathKeyList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listKeys(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(ids), nil
+}
+
+// getKeyByRef returns the key referred to by ref, or nil if there is none
+func getKeyByRef(s logical.Storage, ref string) (*keyEntry, error) {
+	id, err := resolveKeyRef(s, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	return fetchKey(s, id)
+}
+
+func keyResponse(key *keyEntry) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": key.PrivateKeyType,
+		},
+	}
+}
+
+func (b *backend) pathKeyRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key, err := getKeyByRef(req.Storage, data.Get("key_ref").(string))
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	return keyResponse(key), nil
+}
+
+func (b *backend) pathKeyWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	keyRef := data.Get("key_ref").(string)
+	key, err := getKeyByRef(req.Storage, keyRef)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key %q", keyRef)), nil
+	}
+
+	name := data.Get("key_name").(string)
+	if err := validateName(req.Storage, name, key.ID, false); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	key.Name = name
+	if err := writeKey(req.Storage, key); err != nil {
+		return nil, err
+	}
+
+	return keyResponse(key), nil
+}
+
+func (b *backend) pathKeyDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key, err := getKeyByRef(req.Storage, data.Get("key_ref").(string))
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	// Issuers cannot sign anything without their key, so they have to be
+	// deleted first
+	issuers, err := fetchAllIssuers(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuer := range issuers {
+		if issuer.KeyID == key.ID {
+			return logical.ErrorResponse(fmt.Sprintf("key is in use by issuer %s", issuer.ID)), nil
+		}
+	}
+
+	if err := req.Storage.Delete(keyPrefix + key.ID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathKeyGenerate(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var exported bool
+	switch data.Get("exported").(string) {
+	case "exported":
+		exported = true
+	case "internal":
+	default:
+		return logical.ErrorResponse(`The "exported" path parameter must be "internal" or "exported"`), nil
+	}
+
+	keyType := data.Get("key_type").(string)
+	keyBits := data.Get("key_bits").(int)
+	if keyType == "rsa" && keyBits < 2048 {
+		return logical.ErrorResponse("RSA keys < 2048 bits are unsafe and not supported"), nil
+	}
+	if errResp := validateKeyTypeLength(keyType, keyBits); errResp != nil {
+		return errResp, nil
+	}
+
+	keyName := data.Get("key_name").(string)
+	if err := validateName(req.Storage, keyName, "", false); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	parsedBundle := &certutil.ParsedCertBundle{}
+	if err := certutil.GeneratePrivateKey(keyType, keyBits, parsedBundle); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	_, key, err := importBundle(req.Storage, parsedBundle, "", keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := keyResponse(key)
+	if exported {
+		resp.Data["private_key"] = key.PrivateKey
+	}
+
+	return resp, nil
+}
+
+const pathListKeysHelpSyn = `List the keys of this backend`
+
+const pathListKeysHelpDesc = `Keys will be listed by their ID.`
+
+const pathKeysHelpSyn = `Manage a key of this backend.`
+
+const pathKeysHelpDesc = `
+This path reads, renames or deletes a key, referred to by its ID or name.
+Private keys cannot be read back; keys used by an issuer cannot be deleted.
+`
+
+const pathGenerateKeyHelpSyn = `Generate a new private key for future issuers.`
+
+const pathGenerateKeyHelpDesc = `
+This path generates a private key that is stored in the backend and matched
+against the certificates imported later on. The path can either end in
+"internal" or "exported"; this controls whether the unencrypted private key is
+returned. This will be your only chance to export the private key.
+`
@@ -0,0 +1,213 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const ocspResponseContentType = "application/ocsp-response"
+
+// Answers OCSP requests, either base64 encoded in the path of GET requests or
+// DER encoded in the body of POST requests
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ocsp(/(?P<req>.+))?`,
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64 encoded OCSP request, for GET requests`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathOCSPRead,
+			logical.UpdateOperation: b.pathOCSPUpdate,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPRead(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, err := base64.StdEncoding.DecodeString(data.Get("req").(string))
+	if err != nil {
+		return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+	}
+	return b.ocspRespond(req, der)
+}
+
+func (b *backend) pathOCSPUpdate(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, ok := req.Data[logical.HTTPRawBody].([]byte)
+	if !ok {
+		return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+	}
+	return b.ocspRespond(req, der)
+}
+
+// ocspRespond answers a DER encoded OCSP request. Problems are reported as
+// OCSP error statuses rather than Vault errors, as OCSP clients cannot
+// understand the latter.
+func (b *backend) ocspRespond(req *logical.Request, der []byte) (*logical.Response, error) {
+	config, err := b.CRL(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	lifetime := b.ocspLifetime
+	if config != nil {
+		if config.OCSPDisable {
+			return ocspRawResponse(ocspErrorResponse(ocspUnauthorized)), nil
+		}
+		if config.OCSPExpiry != "" {
+			lifetime, err = time.ParseDuration(config.OCSPExpiry)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing OCSP response duration of %s", config.OCSPExpiry)
+			}
+		}
+	}
+
+	certIDs, nonce, err := parseOCSPRequest(der)
+	if err != nil {
+		return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+	}
+
+	// The request is answered by the issuer of its first certificate; the
+	// other certificates are only known if issued by the same issuer
+	issuers, err := fetchAllIssuers(req.Storage)
+	if err != nil {
+		b.Logger().Error("pki: error fetching issuers for OCSP response", "error", err)
+		return ocspRawResponse(ocspErrorResponse(ocspInternalError)), nil
+	}
+	var issuerID string
+	for _, issuer := range issuers {
+		if issuer.KeyID == "" {
+			continue
+		}
+		cert, err := issuer.parseCertificate()
+		if err != nil {
+			continue
+		}
+		if certIDs[0].issuedBy(cert) {
+			issuerID = issuer.ID
+			break
+		}
+	}
+	if issuerID == "" {
+		return ocspRawResponse(ocspErrorResponse(ocspUnauthorized)), nil
+	}
+
+	signingBundle, caErr := fetchCAInfoByID(req, issuerID)
+	switch caErr.(type) {
+	case errutil.UserError:
+		return ocspRawResponse(ocspErrorResponse(ocspUnauthorized)), nil
+	case errutil.InternalError:
+		b.Logger().Error("pki: error fetching CA certificate for OCSP response", "error", caErr)
+		return ocspRawResponse(ocspErrorResponse(ocspInternalError)), nil
+	}
+
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	results := make([]ocspCertResult, 0, len(certIDs))
+	for _, certID := range certIDs {
+		result, err := ocspCertStatusOf(req, signingBundle.Certificate, certID)
+		if err != nil {
+			b.Logger().Error("pki: error fetching certificate status for OCSP response", "error", err)
+			return ocspRawResponse(ocspErrorResponse(ocspInternalError)), nil
+		}
+		results = append(results, result)
+	}
+
+	resp, err := createOCSPResponse(signingBundle.Certificate, signingBundle.PrivateKey, results, nonce, lifetime)
+	if err != nil {
+		b.Logger().Error("pki: error creating OCSP response", "error", err)
+		return ocspRawResponse(ocspErrorResponse(ocspInternalError)), nil
+	}
+
+	return ocspRawResponse(resp), nil
+}
+
+// ocspCertStatusOf looks up the status of the certificate identified by the
+// CertID in storage. Certificates not issued by this CA, or not known to it,
+// are reported as unknown.
+func ocspCertStatusOf(req *logical.Request, ca *x509.Certificate, certID ocspCertID) (ocspCertResult, error) {
+	result := ocspCertResult{
+		CertID: certID,
+		Status: ocspStatusUnknown,
+	}
+	if certID.SerialNumber == nil || !certID.issuedBy(ca) {
+		return result, nil
+	}
+
+	serial := certutil.GetHexFormatted(certID.SerialNumber.Bytes(), ":")
+
+	revokedEntry, err := fetchCertBySerial(req, "revoked/", serial)
+	if err != nil {
+		return result, err
+	}
+	if revokedEntry != nil {
+		var revInfo revocationInfo
+		if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+			return result, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+		}
+		result.Status = ocspStatusRevoked
+		if !revInfo.RevocationTimeUTC.IsZero() {
+			result.RevocationTime = revInfo.RevocationTimeUTC
+		} else {
+			result.RevocationTime = time.Unix(revInfo.RevocationTime, 0)
+		}
+		return result, nil
+	}
+
+	certEntry, err := fetchCertBySerial(req, "certs/", serial)
+	if err != nil {
+		return result, err
+	}
+	if certEntry != nil {
+		// Issuers of a mount may share a name and key, so make sure this one
+		// actually signed the certificate
+		cert, err := x509.ParseCertificate(certEntry.Value)
+		if err != nil {
+			return result, fmt.Errorf("error parsing certificate with serial %s: %s", serial, err)
+		}
+		if cert.CheckSignatureFrom(ca) == nil {
+			result.Status = ocspStatusGood
+		}
+	}
+
+	return result, nil
+}
+
+func ocspRawResponse(der []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ocspResponseContentType,
+			logical.HTTPRawBody:     der,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
+
+const pathOCSPHelpSyn = `
+Query the revocation status of certificates with OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint implements an OCSP responder as described in RFC 6960. Requests
+can either be sent DER encoded in the body of a POST request with the
+"application/ocsp-request" content type, or base64 encoded at the end of the
+path of a GET request.
+
+Certificates issued by this backend are reported as good or revoked; any other
+certificate is reported as unknown. Responses are signed by the issuer of the
+requested certificates and are valid for the duration configured with
+"config/crl".
+`
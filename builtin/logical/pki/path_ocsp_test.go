@@ -0,0 +1,189 @@
+package pki
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_OCSP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	caBlock, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "myvault.com",
+		"allow_subdomains": true,
+	})
+	resp = doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "foo.myvault.com",
+	})
+	certBlock, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := resp.Data["serial_number"].(string)
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(ca.RawSubjectPublicKeyInfo, &spki); err != nil {
+		t.Fatal(err)
+	}
+	nameHash := sha1.Sum(ca.RawSubject)
+	keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+
+	nonce, _ := asn1.Marshal([]byte("nonce"))
+	buildRequest := func(serialNumber *big.Int) []byte {
+		der, err := asn1.Marshal(ocspRequest{
+			TBSRequest: ocspTBSRequest{
+				RequestList: []ocspSingleRequest{
+					{
+						CertID: ocspCertID{
+							HashAlgorithm: pkix.AlgorithmIdentifier{
+								Algorithm:  asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26},
+								Parameters: asn1.NullRawValue,
+							},
+							IssuerNameHash: nameHash[:],
+							IssuerKeyHash:  keyHash[:],
+							SerialNumber:   serialNumber,
+						},
+					},
+				},
+				RequestExtensions: []pkix.Extension{
+					{
+						Id:    oidOCSPNonce,
+						Value: nonce,
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	// parseResponse checks the status and the signature of a response and
+	// returns its single response
+	parseResponse := func(resp *logical.Response, status asn1.Enumerated) *ocspSingleResponse {
+		if resp.Data[logical.HTTPContentType] != ocspResponseContentType {
+			t.Fatalf("bad content type: %#v", resp.Data)
+		}
+		var outer ocspResponse
+		if _, err := asn1.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &outer); err != nil {
+			t.Fatal(err)
+		}
+		if outer.Status != status {
+			t.Fatalf("expected status %d, got %d", status, outer.Status)
+		}
+		if status != ocspSuccessful {
+			return nil
+		}
+
+		var basic ocspBasicResponse
+		if _, err := asn1.Unmarshal(outer.ResponseBytes.Response, &basic); err != nil {
+			t.Fatal(err)
+		}
+		if err := ca.CheckSignature(x509.SHA256WithRSA, basic.TBSResponseData.FullBytes, basic.Signature.RightAlign()); err != nil {
+			t.Fatalf("bad signature: %v", err)
+		}
+
+		var data ocspResponseData
+		if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data); err != nil {
+			t.Fatal(err)
+		}
+		if len(data.ResponseExtensions) != 1 || string(data.ResponseExtensions[0].Value) != string(nonce) {
+			t.Fatalf("nonce not echoed: %#v", data.ResponseExtensions)
+		}
+		if len(data.Responses) != 1 {
+			t.Fatalf("expected one response, got %d", len(data.Responses))
+		}
+		return &data.Responses[0]
+	}
+
+	post := func(der []byte) *logical.Response {
+		return doReq(logical.UpdateOperation, "ocsp", map[string]interface{}{
+			logical.HTTPRawBody: der,
+		})
+	}
+
+	// An issued certificate is good, through both POST and GET
+	single := parseResponse(post(buildRequest(cert.SerialNumber)), ocspSuccessful)
+	if !single.Good || single.Unknown {
+		t.Fatalf("expected good status: %#v", single)
+	}
+	if single.NextUpdate.Sub(single.ThisUpdate) != 12*time.Hour {
+		t.Fatalf("bad response lifetime: %v", single.NextUpdate.Sub(single.ThisUpdate))
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(buildRequest(cert.SerialNumber))
+	single = parseResponse(doReq(logical.ReadOperation, "ocsp/"+encoded, nil), ocspSuccessful)
+	if !single.Good {
+		t.Fatalf("expected good status: %#v", single)
+	}
+
+	// An unknown certificate is reported as such
+	single = parseResponse(post(buildRequest(big.NewInt(42))), ocspSuccessful)
+	if !single.Unknown || single.Good {
+		t.Fatalf("expected unknown status: %#v", single)
+	}
+
+	// A revoked certificate is revoked
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	single = parseResponse(post(buildRequest(cert.SerialNumber)), ocspSuccessful)
+	if bool(single.Good) || single.Revoked.RevocationTime.IsZero() {
+		t.Fatalf("expected revoked status: %#v", single)
+	}
+
+	// Garbage is a malformed request
+	parseResponse(post([]byte("garbage")), ocspMalformedRequest)
+
+	// The lifetime of responses is configurable, and the responder can be
+	// disabled
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"ocsp_expiry": "1h",
+	})
+	single = parseResponse(post(buildRequest(cert.SerialNumber)), ocspSuccessful)
+	if single.NextUpdate.Sub(single.ThisUpdate) != time.Hour {
+		t.Fatalf("bad response lifetime: %v", single.NextUpdate.Sub(single.ThisUpdate))
+	}
+
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"ocsp_disable": true,
+	})
+	parseResponse(post(buildRequest(cert.SerialNumber)), ocspUnauthorized)
+}
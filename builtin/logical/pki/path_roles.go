@@ -114,6 +114,24 @@ CN and SANs. Defaults to true.`,
 Any valid IP is accepted.`,
 			},
 
+			"allowed_uri_sans": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, URI Subject Alternative Names are allowed
+if they match one of these comma-separated values,
+which support globbing, e.g.
+"spiffe://example.org/*".`,
+			},
+
+			"allowed_other_sans": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, otherName Subject Alternative Names are
+allowed if they match one of these comma-separated
+values, of the form <oid>;UTF8:<value>, where the
+value supports globbing. "*" allows any.`,
+			},
+
 			"server_flag": &framework.FieldSchema{
 				Type:    framework.TypeBool,
 				Default: true,
@@ -145,8 +163,8 @@ protection use. Defaults to false.`,
 			"key_type": &framework.FieldSchema{
 				Type:    framework.TypeString,
 				Default: "rsa",
-				Description: `The type of key to use; defaults to RSA. "rsa"
-and "ec" are the only valid values.`,
+				Description: `The type of key to use; defaults to RSA. "rsa",
+"ec" and "ed25519" are the only valid values.`,
 			},
 
 			"key_bits": &framework.FieldSchema{
@@ -154,7 +172,7 @@ and "ec" are the only valid values.`,
 				Default: 2048,
 				Description: `The number of bits to use. You will almost
 certainly want to change this if you adjust
-the key_type.`,
+the key_type. Ignored for ed25519 keys.`,
 			},
 
 			"key_usage": &framework.FieldSchema{
@@ -199,6 +217,56 @@ this value in certificates issued by this role.`,
 this value in certificates issued by this role.`,
 			},
 
+			"country": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, the C (Country) will be set to
+this value in certificates issued by this role.`,
+			},
+
+			"locality": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, the L (Locality) will be set to
+this value in certificates issued by this role.`,
+			},
+
+			"province": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, the ST (Province) will be set to
+this value in certificates issued by this role.`,
+			},
+
+			"street_address": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, the Street Address will be set to
+this value in certificates issued by this role.`,
+			},
+
+			"postal_code": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `If set, the Postal Code will be set to
+this value in certificates issued by this role.`,
+			},
+
+			"ext_key_usage_oids": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `A comma-separated set of extended key usage
+OIDs, added to those of the server, client, code
+signing and email protection flags.`,
+			},
+
+			"policy_identifiers": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "",
+				Description: `A comma-separated set of certificate policy
+OIDs, set in certificates issued by this role.`,
+			},
+
 			"generate_lease": &framework.FieldSchema{
 				Type:    framework.TypeBool,
 				Default: false,
@@ -223,6 +291,15 @@ or revoked, so this option is recommended only for certificates that are
 non-sensitive, or extremely short-lived. This option implies a value of "false"
 for "generate_lease".`,
 			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `Reference to the issuer signing the
+certificates of this role: "default", or the ID
+or name of an issuer. Defaults to the default
+issuer of the mount.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -299,6 +376,13 @@ func (b *backend) getRole(s logical.Storage, n string) (*roleEntry, error) {
 		modified = true
 	}
 
+	// Roles created before a mount could hold several issuers use its
+	// default issuer
+	if result.IssuerRef == "" {
+		result.IssuerRef = defaultRef
+		modified = true
+	}
+
 	if modified {
 		jsonEntry, err := logical.StorageEntryJSON("role/"+n, &result)
 		if err != nil {
@@ -392,6 +476,8 @@ func (b *backend) pathRoleCreate(
 		AllowAnyName:        data.Get("allow_any_name").(bool),
 		EnforceHostnames:    data.Get("enforce_hostnames").(bool),
 		AllowIPSANs:         data.Get("allow_ip_sans").(bool),
+		AllowedURISANs:      data.Get("allowed_uri_sans").(string),
+		AllowedOtherSANs:    data.Get("allowed_other_sans").(string),
 		ServerFlag:          data.Get("server_flag").(bool),
 		ClientFlag:          data.Get("client_flag").(bool),
 		CodeSigningFlag:     data.Get("code_signing_flag").(bool),
@@ -403,8 +489,16 @@ func (b *backend) pathRoleCreate(
 		KeyUsage:            data.Get("key_usage").(string),
 		OU:                  data.Get("ou").(string),
 		Organization:        data.Get("organization").(string),
+		Country:             data.Get("country").(string),
+		Locality:            data.Get("locality").(string),
+		Province:            data.Get("province").(string),
+		StreetAddress:       data.Get("street_address").(string),
+		PostalCode:          data.Get("postal_code").(string),
+		ExtKeyUsageOIDs:     data.Get("ext_key_usage_oids").(string),
+		PolicyIdentifiers:   data.Get("policy_identifiers").(string),
 		GenerateLease:       new(bool),
 		NoStore:             data.Get("no_store").(bool),
+		IssuerRef:           data.Get("issuer_ref").(string),
 	}
 
 	// no_store implies generate_lease := false
@@ -461,6 +555,31 @@ func (b *backend) pathRoleCreate(
 		return errResp, nil
 	}
 
+	if _, err := parseOIDs(entry.ExtKeyUsageOIDs); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Invalid ext_key_usage_oids: %s", err)), nil
+	}
+	if _, err := parseOIDs(entry.PolicyIdentifiers); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Invalid policy_identifiers: %s", err)), nil
+	}
+	if _, err := validateOtherSANs(nil, entry); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	// The default issuer may legitimately not exist yet, but named issuers
+	// must
+	if entry.IssuerRef == "" {
+		entry.IssuerRef = defaultRef
+	}
+	if entry.IssuerRef != defaultRef {
+		issuerID, err := resolveIssuerRef(req.Storage, entry.IssuerRef)
+		if err != nil {
+			return nil, err
+		}
+		if issuerID == "" {
+			return logical.ErrorResponse(fmt.Sprintf("unknown issuer %q", entry.IssuerRef)), nil
+		}
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
 	if err != nil {
@@ -518,6 +637,8 @@ type roleEntry struct {
 	AllowAnyName          bool   `json:"allow_any_name" structs:"allow_any_name" mapstructure:"allow_any_name"`
 	EnforceHostnames      bool   `json:"enforce_hostnames" structs:"enforce_hostnames" mapstructure:"enforce_hostnames"`
 	AllowIPSANs           bool   `json:"allow_ip_sans" structs:"allow_ip_sans" mapstructure:"allow_ip_sans"`
+	AllowedURISANs        string `json:"allowed_uri_sans" structs:"allowed_uri_sans" mapstructure:"allowed_uri_sans"`
+	AllowedOtherSANs      string `json:"allowed_other_sans" structs:"allowed_other_sans" mapstructure:"allowed_other_sans"`
 	ServerFlag            bool   `json:"server_flag" structs:"server_flag" mapstructure:"server_flag"`
 	ClientFlag            bool   `json:"client_flag" structs:"client_flag" mapstructure:"client_flag"`
 	CodeSigningFlag       bool   `json:"code_signing_flag" structs:"code_signing_flag" mapstructure:"code_signing_flag"`
@@ -530,8 +651,16 @@ type roleEntry struct {
 	KeyUsage              string `json:"key_usage" structs:"key_usage" mapstructure:"key_usage"`
 	OU                    string `json:"ou" structs:"ou" mapstructure:"ou"`
 	Organization          string `json:"organization" structs:"organization" mapstructure:"organization"`
+	Country               string `json:"country" structs:"country" mapstructure:"country"`
+	Locality              string `json:"locality" structs:"locality" mapstructure:"locality"`
+	Province              string `json:"province" structs:"province" mapstructure:"province"`
+	StreetAddress         string `json:"street_address" structs:"street_address" mapstructure:"street_address"`
+	PostalCode            string `json:"postal_code" structs:"postal_code" mapstructure:"postal_code"`
+	ExtKeyUsageOIDs       string `json:"ext_key_usage_oids" structs:"ext_key_usage_oids" mapstructure:"ext_key_usage_oids"`
+	PolicyIdentifiers     string `json:"policy_identifiers" structs:"policy_identifiers" mapstructure:"policy_identifiers"`
 	GenerateLease         *bool  `json:"generate_lease,omitempty" structs:"generate_lease,omitempty"`
 	NoStore               bool   `json:"no_store" structs:"no_store" mapstructure:"no_store"`
+	IssuerRef             string `json:"issuer_ref" structs:"issuer_ref" mapstructure:"issuer_ref"`
 
 	// Used internally for signing intermediates
 	AllowExpirationPastCA bool
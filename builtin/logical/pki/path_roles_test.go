@@ -1,6 +1,13 @@
 package pki
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/url"
 	"testing"
 
 	"github.com/hashicorp/vault/logical"
@@ -308,3 +315,183 @@ func TestPki_CertsLease(t *testing.T) {
 		t.Fatalf("expected a response that contains a secret")
 	}
 }
+
+func TestPki_RoleSubjectAndSANControls(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+
+	resp, err := doReq("root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	// OIDs are validated when writing the role
+	resp, err = doReq("roles/bad", map[string]interface{}{
+		"allow_any_name":     true,
+		"ext_key_usage_oids": "1.3.6.1.5.5.7.3.foo",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected invalid OID to be rejected: err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = doReq("roles/spiffe", map[string]interface{}{
+		"allowed_domains":    "myvault.com",
+		"allow_subdomains":   true,
+		"allowed_uri_sans":   "spiffe://myvault.com/*",
+		"allowed_other_sans": "1.3.6.1.4.1.311.20.2.3;UTF8:*@myvault.com",
+		"country":            "US",
+		"locality":           "San Francisco",
+		"province":           "CA",
+		"street_address":     "101 Second Street",
+		"postal_code":        "94105",
+		"ext_key_usage_oids": "1.3.6.1.4.1.311.20.2.2",
+		"policy_identifiers": "2.23.140.1.2.1,1.2.3.4",
+		"ttl":                "1h",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	parseCert := func(resp *logical.Response) *x509.Certificate {
+		block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	checkCert := func(cert *x509.Certificate, uri, otherSAN string) {
+		if len(cert.Subject.Country) != 1 || cert.Subject.Country[0] != "US" ||
+			len(cert.Subject.Locality) != 1 || cert.Subject.Locality[0] != "San Francisco" ||
+			len(cert.Subject.Province) != 1 || cert.Subject.Province[0] != "CA" ||
+			len(cert.Subject.StreetAddress) != 1 || cert.Subject.StreetAddress[0] != "101 Second Street" ||
+			len(cert.Subject.PostalCode) != 1 || cert.Subject.PostalCode[0] != "94105" {
+			t.Fatalf("bad subject: %#v", cert.Subject)
+		}
+		if len(cert.URIs) != 1 || cert.URIs[0].String() != uri {
+			t.Fatalf("bad URI SANs: %v", cert.URIs)
+		}
+		otherSANs, err := getOtherSANsFromCSR(&x509.CertificateRequest{Extensions: cert.Extensions})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(otherSANs) != 1 || otherSANs[0].String() != otherSAN {
+			t.Fatalf("bad other SANs: %v", otherSANs)
+		}
+		if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "foo.myvault.com" {
+			t.Fatalf("bad DNS SANs: %v", cert.DNSNames)
+		}
+		if len(cert.UnknownExtKeyUsage) != 1 || cert.UnknownExtKeyUsage[0].String() != "1.3.6.1.4.1.311.20.2.2" {
+			t.Fatalf("bad extended key usages: %v", cert.UnknownExtKeyUsage)
+		}
+		if len(cert.PolicyIdentifiers) != 2 {
+			t.Fatalf("bad policy identifiers: %v", cert.PolicyIdentifiers)
+		}
+	}
+
+	// Issuing
+	resp, err = doReq("issue/spiffe", map[string]interface{}{
+		"common_name": "foo.myvault.com",
+		"uri_sans":    "spiffe://myvault.com/web",
+		"other_sans":  "1.3.6.1.4.1.311.20.2.3;UTF8:admin@myvault.com",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	checkCert(parseCert(resp), "spiffe://myvault.com/web", "1.3.6.1.4.1.311.20.2.3;UTF8:admin@myvault.com")
+
+	for name, data := range map[string]map[string]interface{}{
+		"uri":       {"uri_sans": "spiffe://example.org/web"},
+		"other oid": {"other_sans": "1.2.3.4;UTF8:admin@myvault.com"},
+		"other":     {"other_sans": "1.3.6.1.4.1.311.20.2.3;UTF8:admin@example.org"},
+	} {
+		data["common_name"] = "foo.myvault.com"
+		resp, err = doReq("issue/spiffe", data)
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected disallowed SAN to be rejected: err: %v resp: %#v", name, err, resp)
+		}
+	}
+
+	// Signing, with the SANs taken from the CSR
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPEM := func(uri, otherSAN string) string {
+		parsedURI, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherName, err := parseOtherSAN(otherSAN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sanExt, err := marshalSANs(nil, nil, nil, []*url.URL{parsedURI}, []otherNameUTF8{otherName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:         pkix.Name{CommonName: "foo.myvault.com", Country: []string{"FR"}},
+			ExtraExtensions: []pkix.Extension{sanExt},
+		}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}))
+	}
+
+	resp, err = doReq("roles/spiffe", map[string]interface{}{
+		"allowed_domains":    "myvault.com",
+		"allow_subdomains":   true,
+		"allowed_uri_sans":   "spiffe://myvault.com/*",
+		"allowed_other_sans": "1.3.6.1.4.1.311.20.2.3;UTF8:*@myvault.com",
+		"country":            "US",
+		"locality":           "San Francisco",
+		"province":           "CA",
+		"street_address":     "101 Second Street",
+		"postal_code":        "94105",
+		"ext_key_usage_oids": "1.3.6.1.4.1.311.20.2.2",
+		"policy_identifiers": "2.23.140.1.2.1,1.2.3.4",
+		"key_type":           "ec",
+		"key_bits":           256,
+		"ttl":                "1h",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	resp, err = doReq("sign/spiffe", map[string]interface{}{
+		"csr":         csrPEM("spiffe://myvault.com/db", "1.3.6.1.4.1.311.20.2.3;UTF8:db@myvault.com"),
+		"common_name": "foo.myvault.com",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	checkCert(parseCert(resp), "spiffe://myvault.com/db", "1.3.6.1.4.1.311.20.2.3;UTF8:db@myvault.com")
+
+	resp, err = doReq("sign/spiffe", map[string]interface{}{
+		"csr":         csrPEM("spiffe://example.org/db", "1.3.6.1.4.1.311.20.2.3;UTF8:db@myvault.com"),
+		"common_name": "foo.myvault.com",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected disallowed URI SAN in CSR to be rejected: err: %v resp: %#v", err, resp)
+	}
+	resp, err = doReq("sign/spiffe", map[string]interface{}{
+		"csr":         csrPEM("spiffe://myvault.com/db", "1.3.6.1.4.1.311.20.2.3;UTF8:db@example.org"),
+		"common_name": "foo.myvault.com",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected disallowed other SAN in CSR to be rejected: err: %v resp: %#v", err, resp)
+	}
+}
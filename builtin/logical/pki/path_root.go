@@ -30,6 +30,7 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameFields(ret.Fields)
 
 	return ret
 }
@@ -63,6 +64,7 @@ func pathSignIntermediate(b *backend) *framework.Path {
 
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerRefField(ret.Fields)
 
 	ret.Fields["csr"] = &framework.FieldSchema{
 		Type:        framework.TypeString,
@@ -106,31 +108,56 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 		HelpDescription: pathSignSelfIssuedHelpDesc,
 	}
 
+	ret.Fields = addIssuerRefField(ret.Fields)
+
 	return ret
 }
 
+// pathCADeleteRoot deletes all issuers and keys of the mount, along with
+// their CRLs, to allow a new CA to be generated
 func (b *backend) pathCADeleteRoot(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return nil, req.Storage.Delete("config/ca_bundle")
+	for _, prefix := range []string{issuerPrefix, keyPrefix, issuerCRLPrefix, issuerDeltaCRLPrefix, issuerCRLStatePrefix} {
+		ids, err := req.Storage.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := req.Storage.Delete(prefix + id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, key := range []string{issuersConfigPath, legacyCABundlePath, legacyMigrationLogPath, "ca", "crl"} {
+		if err := req.Storage.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
 }
 
 func (b *backend) pathCAGenerateRoot(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var err error
 
-	entry, err := req.Storage.Get("config/ca_bundle")
-	if err != nil {
-		return nil, err
-	}
-	if entry != nil {
-		return nil, nil
-	}
-
 	exported, format, role, errorResp := b.getGenerationParams(data)
 	if errorResp != nil {
 		return errorResp, nil
 	}
 
+	issuerName := data.Get("issuer_name").(string)
+	keyName := data.Get("key_name").(string)
+	if err := validateIssuerAndKeyNames(req.Storage, issuerName, keyName); err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
 	maxPathLengthIface, ok := data.GetOk("max_path_length")
 	if ok {
 		maxPathLength := maxPathLengthIface.(int)
@@ -188,15 +215,13 @@ func (b *backend) pathCAGenerateRoot(
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(entry)
+	// Store it as a new issuer and key
+	issuer, key, err := importBundle(req.Storage, parsedBundle, issuerName, keyName)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["issuer_id"] = issuer.ID
+	resp.Data["key_id"] = key.ID
 
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
@@ -208,15 +233,6 @@ func (b *backend) pathCAGenerateRoot(
 		return nil, fmt.Errorf("Unable to store certificate locally: %v", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build a fresh CRL
 	err = buildCRL(b, req)
 	if err != nil {
@@ -256,7 +272,7 @@ func (b *backend) pathCASignIntermediate(
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(req)
+	signingBundle, caErr := fetchCAInfo(req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -378,7 +394,7 @@ func (b *backend) pathCASignSelfIssued(
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(req)
+	signingBundle, caErr := fetchCAInfo(req, data.Get("issuer_ref").(string))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -430,7 +446,7 @@ See the API documentation for more information.
 `
 
 const pathDeleteRootHelpSyn = `
-Deletes the root CA key to allow a new one to be generated.
+Deletes all issuers and keys to allow a new root CA to be generated.
 `
 
 const pathDeleteRootHelpDesc = `
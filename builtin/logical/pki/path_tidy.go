@@ -2,7 +2,9 @@ package pki
 
 import (
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/vault/logical"
@@ -27,6 +29,16 @@ the revocation list`,
 				Default: false,
 			},
 
+			"tidy_legacy_ca_bundle": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Set to true to remove the CA bundle
+of mounts created before multiple issuers
+were supported, once it has been migrated.
+Afterwards, the mount can no longer be used
+by older versions of Vault.`,
+				Default: false,
+			},
+
 			"safety_buffer": &framework.FieldSchema{
 				Type: framework.TypeDurationSecond,
 				Description: `The amount of extra time that must have passed
@@ -46,48 +58,200 @@ Defaults to 72 hours.`,
 	}
 }
 
+func pathTidyStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy-status",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathTidyStatusRead,
+		},
+
+		HelpSynopsis:    pathTidyStatusHelpSyn,
+		HelpDescription: pathTidyStatusHelpDesc,
+	}
+}
+
+const (
+	tidyStateRunning  = "Running"
+	tidyStateFinished = "Finished"
+	tidyStateError    = "Error"
+)
+
+// tidyStatusPath holds the status of the last tidy operation and the time
+// the last automatic one finished, so that they survive restarts and
+// leadership changes
+const tidyStatusPath = "config/tidy-status"
+
+// tidyStatus describes the running or last tidy operation of the mount
+type tidyStatus struct {
+	// Parameters of the operation
+	Auto               bool `json:"auto"`
+	SafetyBuffer       int  `json:"safety_buffer"`
+	TidyCertStore      bool `json:"tidy_cert_store"`
+	TidyRevocationList bool `json:"tidy_revocation_list"`
+	TidyLegacyCABundle bool `json:"tidy_legacy_ca_bundle"`
+
+	State                   string    `json:"state"`
+	Error                   string    `json:"error"`
+	TimeStarted             time.Time `json:"time_started"`
+	TimeFinished            time.Time `json:"time_finished"`
+	CertStoreDeletedCount   uint      `json:"cert_store_deleted_count"`
+	RevokedCertDeletedCount uint      `json:"revoked_cert_deleted_count"`
+	LegacyCABundleRemoved   bool      `json:"legacy_ca_bundle_removed"`
+}
+
+// storedTidyStatus is the entry stored at tidyStatusPath
+type storedTidyStatus struct {
+	LastRun      *tidyStatus `json:"last_run"`
+	LastAutoTidy time.Time   `json:"last_auto_tidy"`
+}
+
+// loadTidyStatus reads the stored tidy status the first time it is needed.
+// It must be called with tidyStatusLock held for writing.
+func (b *backend) loadTidyStatus(s logical.Storage) error {
+	if b.tidyStatusLoaded {
+		return nil
+	}
+
+	entry, err := s.Get(tidyStatusPath)
+	if err != nil {
+		return fmt.Errorf("error fetching tidy status: %s", err)
+	}
+	if entry != nil {
+		var stored storedTidyStatus
+		if err := entry.DecodeJSON(&stored); err != nil {
+			return fmt.Errorf("error decoding tidy status: %s", err)
+		}
+		b.tidyStatus = stored.LastRun
+		b.lastAutoTidy = stored.LastAutoTidy
+	}
+
+	b.tidyStatusLoaded = true
+	return nil
+}
+
+// storeTidyStatus persists the tidy status. It must be called with
+// tidyStatusLock held.
+func (b *backend) storeTidyStatus(s logical.Storage) error {
+	entry, err := logical.StorageEntryJSON(tidyStatusPath, &storedTidyStatus{
+		LastRun:      b.tidyStatus,
+		LastAutoTidy: b.lastAutoTidy,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.Put(entry); err != nil {
+		return fmt.Errorf("error storing tidy status: %s", err)
+	}
+	return nil
+}
+
 func (b *backend) pathTidyWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	safetyBuffer := d.Get("safety_buffer").(int)
 	tidyCertStore := d.Get("tidy_cert_store").(bool)
 	tidyRevocationList := d.Get("tidy_revocation_list").(bool)
+	tidyLegacyCABundle := d.Get("tidy_legacy_ca_bundle").(bool)
+
+	err := b.tidy(req, false, tidyCertStore, tidyRevocationList, tidyLegacyCABundle, safetyBuffer)
+	if err == errTidyInProgress {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	return nil, err
+}
+
+var errTidyInProgress = errors.New("a tidy operation is already in progress")
+
+// tidy removes the expired certificates and revocation entries, and the
+// migrated legacy CA bundle, recording the progress and result of the
+// operation in the tidy status. Only one tidy operation runs at a time.
+func (b *backend) tidy(req *logical.Request, auto, tidyCertStore, tidyRevocationList, tidyLegacyCABundle bool, safetyBuffer int) error {
+	if !atomic.CompareAndSwapUint32(&b.tidyCASGuard, 0, 1) {
+		return errTidyInProgress
+	}
+	defer atomic.StoreUint32(&b.tidyCASGuard, 0)
+
+	status := &tidyStatus{
+		Auto:               auto,
+		SafetyBuffer:       safetyBuffer,
+		TidyCertStore:      tidyCertStore,
+		TidyRevocationList: tidyRevocationList,
+		TidyLegacyCABundle: tidyLegacyCABundle,
+		State:              tidyStateRunning,
+		TimeStarted:        time.Now(),
+	}
+	b.tidyStatusLock.Lock()
+	if err := b.loadTidyStatus(req.Storage); err != nil {
+		b.tidyStatusLock.Unlock()
+		return err
+	}
+	b.tidyStatus = status
+	b.tidyStatusLock.Unlock()
+
+	err := b.doTidy(req, status)
 
-	bufferDuration := time.Duration(safetyBuffer) * time.Second
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+	status.TimeFinished = time.Now()
+	if err != nil {
+		status.State = tidyStateError
+		status.Error = err.Error()
+	} else {
+		status.State = tidyStateFinished
+	}
+
+	// Failed automatic runs count as well, so that a persistent error does
+	// not trigger a tidy on every tick
+	if auto {
+		b.lastAutoTidy = status.TimeFinished
+	}
 
-	if tidyCertStore {
+	if storeErr := b.storeTidyStatus(req.Storage); storeErr != nil && err == nil {
+		err = storeErr
+	}
+	return err
+}
+
+func (b *backend) doTidy(req *logical.Request, status *tidyStatus) error {
+	bufferDuration := time.Duration(status.SafetyBuffer) * time.Second
+
+	if status.TidyCertStore {
 		serials, err := req.Storage.List("certs/")
 		if err != nil {
-			return nil, fmt.Errorf("error fetching list of certs: %s", err)
+			return fmt.Errorf("error fetching list of certs: %s", err)
 		}
 
 		for _, serial := range serials {
 			certEntry, err := req.Storage.Get("certs/" + serial)
 			if err != nil {
-				return nil, fmt.Errorf("error fetching certificate %s: %s", serial, err)
+				return fmt.Errorf("error fetching certificate %s: %s", serial, err)
 			}
 
 			if certEntry == nil {
-				return nil, fmt.Errorf("certificate entry for serial %s is nil", serial)
+				return fmt.Errorf("certificate entry for serial %s is nil", serial)
 			}
 
 			if certEntry.Value == nil || len(certEntry.Value) == 0 {
-				return nil, fmt.Errorf("found entry for serial %s but actual certificate is empty", serial)
+				return fmt.Errorf("found entry for serial %s but actual certificate is empty", serial)
 			}
 
 			cert, err := x509.ParseCertificate(certEntry.Value)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse stored certificate with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to parse stored certificate with serial %s: %s", serial, err)
 			}
 
 			if time.Now().After(cert.NotAfter.Add(bufferDuration)) {
 				if err := req.Storage.Delete("certs/" + serial); err != nil {
-					return nil, fmt.Errorf("error deleting serial %s from storage: %s", serial, err)
+					return fmt.Errorf("error deleting serial %s from storage: %s", serial, err)
 				}
+				b.tidyStatusLock.Lock()
+				status.CertStoreDeletedCount++
+				b.tidyStatusLock.Unlock()
 			}
 		}
 	}
 
-	if tidyRevocationList {
+	if status.TidyRevocationList {
 		b.revokeStorageLock.Lock()
 		defer b.revokeStorageLock.Unlock()
 
@@ -95,51 +259,120 @@ func (b *backend) pathTidyWrite(
 
 		revokedSerials, err := req.Storage.List("revoked/")
 		if err != nil {
-			return nil, fmt.Errorf("error fetching list of revoked certs: %s", err)
+			return fmt.Errorf("error fetching list of revoked certs: %s", err)
 		}
 
 		var revInfo revocationInfo
 		for _, serial := range revokedSerials {
 			revokedEntry, err := req.Storage.Get("revoked/" + serial)
 			if err != nil {
-				return nil, fmt.Errorf("unable to fetch revoked cert with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to fetch revoked cert with serial %s: %s", serial, err)
 			}
 			if revokedEntry == nil {
-				return nil, fmt.Errorf("revoked certificate entry for serial %s is nil", serial)
+				return fmt.Errorf("revoked certificate entry for serial %s is nil", serial)
 			}
 			if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
 				// TODO: In this case, remove it and continue? How likely is this to
 				// happen? Alternately, could skip it entirely, or could implement a
 				// delete function so that there is a way to remove these
-				return nil, fmt.Errorf("found revoked serial but actual certificate is empty")
+				return fmt.Errorf("found revoked serial but actual certificate is empty")
 			}
 
 			err = revokedEntry.DecodeJSON(&revInfo)
 			if err != nil {
-				return nil, fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+				return fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
 			}
 
 			revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
 			if err != nil {
-				return nil, fmt.Errorf("unable to parse stored revoked certificate with serial %s: %s", serial, err)
+				return fmt.Errorf("unable to parse stored revoked certificate with serial %s: %s", serial, err)
 			}
 
 			if time.Now().After(revokedCert.NotAfter.Add(bufferDuration)) {
 				if err := req.Storage.Delete("revoked/" + serial); err != nil {
-					return nil, fmt.Errorf("error deleting serial %s from revoked list: %s", serial, err)
+					return fmt.Errorf("error deleting serial %s from revoked list: %s", serial, err)
 				}
 				tidiedRevoked = true
+				b.tidyStatusLock.Lock()
+				status.RevokedCertDeletedCount++
+				b.tidyStatusLock.Unlock()
 			}
 		}
 
 		if tidiedRevoked {
 			if err := buildCRL(b, req); err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 
-	return nil, nil
+	if status.TidyLegacyCABundle {
+		removed, err := b.removeLegacyCABundle(req.Storage)
+		if err != nil {
+			return fmt.Errorf("error removing legacy CA bundle: %s", err)
+		}
+		b.tidyStatusLock.Lock()
+		status.LegacyCABundleRemoved = removed
+		b.tidyStatusLock.Unlock()
+	}
+
+	return nil
+}
+
+func (b *backend) pathTidyStatusRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	b.tidyStatusLock.Lock()
+	defer b.tidyStatusLock.Unlock()
+
+	if err := b.loadTidyStatus(req.Storage); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"state":                      "Inactive",
+			"auto":                       nil,
+			"safety_buffer":              nil,
+			"tidy_cert_store":            nil,
+			"tidy_revocation_list":       nil,
+			"tidy_legacy_ca_bundle":      nil,
+			"error":                      nil,
+			"time_started":               nil,
+			"time_finished":              nil,
+			"cert_store_deleted_count":   nil,
+			"revoked_cert_deleted_count": nil,
+			"legacy_ca_bundle_removed":   nil,
+			"last_auto_tidy_finished":    nil,
+		},
+	}
+
+	if !b.lastAutoTidy.IsZero() {
+		resp.Data["last_auto_tidy_finished"] = b.lastAutoTidy.Format(time.RFC3339Nano)
+	}
+
+	status := b.tidyStatus
+	if status == nil {
+		return resp, nil
+	}
+
+	resp.Data["state"] = status.State
+	resp.Data["auto"] = status.Auto
+	resp.Data["safety_buffer"] = status.SafetyBuffer
+	resp.Data["tidy_cert_store"] = status.TidyCertStore
+	resp.Data["tidy_revocation_list"] = status.TidyRevocationList
+	resp.Data["tidy_legacy_ca_bundle"] = status.TidyLegacyCABundle
+	resp.Data["time_started"] = status.TimeStarted.Format(time.RFC3339Nano)
+	resp.Data["cert_store_deleted_count"] = status.CertStoreDeletedCount
+	resp.Data["revoked_cert_deleted_count"] = status.RevokedCertDeletedCount
+	resp.Data["legacy_ca_bundle_removed"] = status.LegacyCABundleRemoved
+	if !status.TimeFinished.IsZero() {
+		resp.Data["time_finished"] = status.TimeFinished.Format(time.RFC3339Nano)
+	}
+	if status.Error != "" {
+		resp.Data["error"] = status.Error
+	}
+
+	return resp, nil
 }
 
 const pathTidyHelpSyn = `
@@ -155,6 +388,11 @@ For safety, this function is a noop if called without parameters; cleanup from
 normal certificate storage must be enabled with 'tidy_cert_store' and cleanup
 from revocation information must be enabled with 'tidy_revocation_list'.
 
+Mounts created before multiple issuers were supported keep their original CA
+bundle after it has been migrated, so that they can still be used by older
+versions of Vault. Once that is no longer needed, 'tidy_legacy_ca_bundle'
+removes it.
+
 The 'safety_buffer' parameter is useful to ensure that clock skew amongst your
 hosts cannot lead to a certificate being removed from the CRL while it is still
 considered valid by other hosts (for instance, if their clocks are a few
@@ -168,3 +406,15 @@ certificate storage or in revocation infomation will then be checked. If the
 current time, minus the value of 'safety_buffer', is greater than the
 expiration, it will be removed.
 `
+
+const pathTidyStatusHelpSyn = `
+Returns the status of the running or last tidy operation.
+`
+
+const pathTidyStatusHelpDesc = `
+This endpoint returns the parameters, progress and result of the tidy
+operation currently running, or of the last one if none is running, whether
+it was requested through 'tidy' or run automatically as configured with
+'config/auto-tidy'. The state is one of "Inactive" (no tidy ever ran on the
+mount), "Running", "Finished" or "Error".
+`
@@ -0,0 +1,246 @@
+package pki
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestPki_AutoTidy(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != "Inactive" {
+		t.Fatalf("bad: tidy status before any tidy: %#v", resp.Data)
+	}
+
+	doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	doReq(logical.UpdateOperation, "roles/example", map[string]interface{}{
+		"allowed_domains":  "myvault.com",
+		"allow_subdomains": true,
+	})
+	resp = doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "foo.myvault.com",
+		"ttl":         "1s",
+	})
+	serial := resp.Data["serial_number"].(string)
+	doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	doReq(logical.UpdateOperation, "issue/example", map[string]interface{}{
+		"common_name": "bar.myvault.com",
+	})
+
+	// Enabling auto-tidy without anything to tidy is an error
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/auto-tidy",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"enabled": true,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error enabling auto-tidy without tidy flags, got: err: %v resp: %#v", err, resp)
+	}
+
+	doReq(logical.UpdateOperation, "config/auto-tidy", map[string]interface{}{
+		"enabled":              true,
+		"interval_duration":    "1h",
+		"tidy_cert_store":      true,
+		"tidy_revocation_list": true,
+		"safety_buffer":        "0s",
+	})
+	resp = doReq(logical.ReadOperation, "config/auto-tidy", nil)
+	if resp.Data["interval_duration"] != 3600 || resp.Data["safety_buffer"] != 0 || resp.Data["enabled"] != true {
+		t.Fatalf("bad: auto-tidy config: %#v", resp.Data)
+	}
+
+	// Let the first certificate expire
+	time.Sleep(2 * time.Second)
+
+	periodic := func() {
+		if err := b.periodicFunc(&logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	periodic()
+
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStateFinished || resp.Data["auto"] != true {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+	if resp.Data["cert_store_deleted_count"] != uint(1) || resp.Data["revoked_cert_deleted_count"] != uint(1) {
+		t.Fatalf("bad: tidy status counts: %#v", resp.Data)
+	}
+	if resp.Data["time_finished"] == nil || resp.Data["last_auto_tidy_finished"] == nil || resp.Data["error"] != nil {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+	lastAutoTidy := resp.Data["last_auto_tidy_finished"]
+
+	certs, err := storage.List("certs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("expected the CA and unexpired certificates to remain, got %d", len(certs))
+	}
+	revoked, err := storage.List("revoked/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 0 {
+		t.Fatalf("expected the revocation list to be empty, got %d entries", len(revoked))
+	}
+
+	// The next run waits for the interval
+	periodic()
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["last_auto_tidy_finished"] != lastAutoTidy {
+		t.Fatalf("auto-tidy ran again before its interval: %#v", resp.Data)
+	}
+
+	// The status and the time of the last automatic run survive a restart
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	loaded, err := Factory(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = loaded.(*backend)
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStateFinished || resp.Data["auto"] != true || resp.Data["revoked_cert_deleted_count"] != uint(1) {
+		t.Fatalf("bad: tidy status after restart: %#v", resp.Data)
+	}
+	periodic()
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["last_auto_tidy_finished"] != lastAutoTidy {
+		t.Fatalf("auto-tidy ran again after a restart before its interval: %#v", resp.Data)
+	}
+
+	// Manual runs are reported as well
+	doReq(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+	})
+	resp = doReq(logical.ReadOperation, "tidy-status", nil)
+	if resp.Data["state"] != tidyStateFinished || resp.Data["auto"] != false || resp.Data["tidy_revocation_list"] != false {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+	if resp.Data["cert_store_deleted_count"] != uint(0) || resp.Data["safety_buffer"] != 259200 {
+		t.Fatalf("bad: tidy status: %#v", resp.Data)
+	}
+}
+
+func TestPki_CRLAutoRebuild(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := doReq(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "myvault.com",
+		"ttl":         "40h",
+	})
+	issuerID := resp.Data["issuer_id"].(string)
+
+	nextUpdate := func() time.Time {
+		entry, err := storage.Get(issuerCRLPrefix + issuerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil {
+			t.Fatal("missing CRL")
+		}
+		crl, err := x509.ParseDERCRL(entry.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl.TBSCertList.NextUpdate
+	}
+	periodic := func() {
+		if err := b.periodicFunc(&logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/crl",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"expiry":                    "1h",
+			"auto_rebuild":              true,
+			"auto_rebuild_grace_period": "2h",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error with a grace period longer than the expiry, got: err: %v resp: %#v", err, resp)
+	}
+
+	// Build a CRL expiring in a minute
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry": "1m",
+	})
+	doReq(logical.ReadOperation, "crl/rotate", nil)
+	if nextUpdate().After(time.Now().Add(time.Minute)) {
+		t.Fatal("expected a CRL expiring within a minute")
+	}
+
+	// Without auto_rebuild, it is left alone
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry": "72h",
+	})
+	periodic()
+	if nextUpdate().After(time.Now().Add(time.Minute)) {
+		t.Fatal("CRL rebuilt without auto_rebuild")
+	}
+
+	doReq(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry":                    "72h",
+		"auto_rebuild":              true,
+		"auto_rebuild_grace_period": "2m",
+	})
+	resp = doReq(logical.ReadOperation, "config/crl", nil)
+	if resp.Data["auto_rebuild"] != true || resp.Data["auto_rebuild_grace_period"] != "2m" {
+		t.Fatalf("bad: CRL config: %#v", resp.Data)
+	}
+	periodic()
+	rebuilt := nextUpdate()
+	if rebuilt.Before(time.Now().Add(71 * time.Hour)) {
+		t.Fatalf("expected the CRL to be rebuilt with the configured expiry, next update is %s", rebuilt)
+	}
+
+	// A CRL out of its grace period is not rebuilt again
+	periodic()
+	if !nextUpdate().Equal(rebuilt) {
+		t.Fatal("CRL rebuilt outside of its grace period")
+	}
+}
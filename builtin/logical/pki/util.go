@@ -1,7 +1,67 @@
 package pki
 
-import "strings"
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+var (
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
 
 func normalizeSerial(serial string) string {
 	return strings.Replace(strings.ToLower(serial), ":", "-", -1)
 }
+
+// signatureAlgorithm returns the algorithm CAs with the given public key
+// sign OCSP responses and CRLs with, along with its hash function
+func signatureAlgorithm(pub crypto.PublicKey) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  oidSignatureSHA256WithRSA,
+			Parameters: asn1.NullRawValue,
+		}, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm: oidSignatureECDSAWithSHA256,
+		}, crypto.SHA256, nil
+	case ed25519.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm: oidSignatureEd25519,
+		}, 0, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, 0, fmt.Errorf("unsupported CA key type %T", pub)
+	}
+}
+
+// signTBS signs the DER encoded "to be signed" part of an OCSP response or
+// CRL with the key of a CA, returning the signature along with its algorithm
+func signTBS(signer crypto.Signer, tbs []byte) (pkix.AlgorithmIdentifier, []byte, error) {
+	sigAlgorithm, hashFunc, err := signatureAlgorithm(signer.Public())
+	if err != nil {
+		return sigAlgorithm, nil, err
+	}
+
+	signed := tbs
+	if hashFunc != 0 {
+		h := hashFunc.New()
+		h.Write(tbs)
+		signed = h.Sum(nil)
+	}
+	signature, err := signer.Sign(rand.Reader, signed, hashFunc)
+	if err != nil {
+		return sigAlgorithm, nil, err
+	}
+
+	return sigAlgorithm, signature, nil
+}
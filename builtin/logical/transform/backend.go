@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	b := Backend(conf)
+	if err := b.Setup(conf); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func Backend(conf *logical.BackendConfig) *backend {
+	var b backend
+	b.Backend = &framework.Backend{
+		Help: strings.TrimSpace(backendHelp),
+
+		Paths: []*framework.Path{
+			b.pathListRoles(),
+			b.pathRoles(),
+			b.pathListTemplates(),
+			b.pathTemplates(),
+			b.pathListTransformations(),
+			b.pathTransformations(),
+			b.pathEncode(),
+			b.pathDecode(),
+		},
+
+		Secrets:     []*framework.Secret{},
+		Invalidate:  b.invalidate,
+		BackendType: logical.TypeLogical,
+	}
+
+	b.lm = keysutil.NewLockManager(conf.System.CachingDisabled())
+	b.lm.SetMountPoint(conf.MountPoint)
+
+	return &b
+}
+
+type backend struct {
+	*framework.Backend
+
+	// lm manages the keys of the FPE and tokenization transformations,
+	// which are named after their transformation
+	lm *keysutil.LockManager
+}
+
+func (b *backend) invalidate(key string) {
+	if b.Logger().IsTrace() {
+		b.Logger().Trace("transform: invalidating key", "key", key)
+	}
+	switch {
+	case strings.HasPrefix(key, "policy/"):
+		name := strings.TrimPrefix(key, "policy/")
+		b.lm.InvalidatePolicy(name)
+	}
+}
+
+const backendHelp = `
+The transform backend encodes sensitive values while preserving their
+format, using format-preserving encryption, masking or tokenization.
+
+Templates describe the format of the values and which of their characters
+are transformed; transformations apply one of the transformation types using
+a template; roles grant the use of transformations through the encode and
+decode endpoints.
+`
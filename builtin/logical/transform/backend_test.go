@@ -0,0 +1,262 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func createBackendWithStorage(t *testing.T) (*backend, logical.Storage) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend(config)
+	if b == nil {
+		t.Fatalf("failed to create backend")
+	}
+	if err := b.Backend.Setup(config); err != nil {
+		t.Fatal(err)
+	}
+	return b, config.StorageView
+}
+
+func testTransformRequest(t *testing.T, b *backend, storage logical.Storage, path string, data map[string]interface{}) *logical.Response {
+	resp, err := b.HandleRequest(&logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      path,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("path %s: bad: err: %v resp: %#v", path, err, resp)
+	}
+	return resp
+}
+
+func TestTransform_FPE(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	testTransformRequest(t, b, storage, "transformations/ccn", map[string]interface{}{
+		"type":          "fpe",
+		"template":      "builtin/creditcardnumber",
+		"tweak_source":  "internal",
+		"allowed_roles": "payments",
+	})
+	testTransformRequest(t, b, storage, "roles/payments", map[string]interface{}{
+		"transformations": "ccn",
+	})
+
+	value := "4111-1111-1111-1111"
+	resp := testTransformRequest(t, b, storage, "encode/payments", map[string]interface{}{
+		"value": value,
+	})
+	encoded := resp.Data["encoded_value"].(string)
+	if encoded == value {
+		t.Fatal("encoded value matches the value")
+	}
+	if len(encoded) != len(value) || strings.Count(encoded, "-") != 3 || encoded[4] != '-' {
+		t.Fatalf("format of the value was not preserved: %s", encoded)
+	}
+
+	// Encoding is deterministic for a given tweak
+	resp = testTransformRequest(t, b, storage, "encode/payments", map[string]interface{}{
+		"value": value,
+	})
+	if resp.Data["encoded_value"].(string) != encoded {
+		t.Fatalf("expected %s, got %s", encoded, resp.Data["encoded_value"])
+	}
+
+	resp = testTransformRequest(t, b, storage, "decode/payments", map[string]interface{}{
+		"value": encoded,
+	})
+	if resp.Data["decoded_value"].(string) != value {
+		t.Fatalf("expected %s, got %s", value, resp.Data["decoded_value"])
+	}
+
+	// Values not matching the template are rejected
+	resp, err := b.HandleRequest(&logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "encode/payments",
+		Data: map[string]interface{}{
+			"value": "4111-1111-1111",
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
+
+func TestTransform_FPE_CustomTemplate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	testTransformRequest(t, b, storage, "templates/account", map[string]interface{}{
+		"pattern":  `ACC-([a-z0-9]{8})`,
+		"alphabet": "builtin/alphanumericlower",
+	})
+	testTransformRequest(t, b, storage, "transformations/account", map[string]interface{}{
+		"type":          "fpe",
+		"template":      "account",
+		"tweak_source":  "generated",
+		"allowed_roles": "*",
+	})
+	testTransformRequest(t, b, storage, "roles/app", map[string]interface{}{
+		"transformations": "account",
+	})
+
+	resp := testTransformRequest(t, b, storage, "encode/app", map[string]interface{}{
+		"value":          "ACC-abcd1234",
+		"transformation": "account",
+	})
+	encoded := resp.Data["encoded_value"].(string)
+	tweak := resp.Data["tweak"].(string)
+	if !strings.HasPrefix(encoded, "ACC-") || len(encoded) != 12 {
+		t.Fatalf("format of the value was not preserved: %s", encoded)
+	}
+
+	resp = testTransformRequest(t, b, storage, "decode/app", map[string]interface{}{
+		"value":          encoded,
+		"transformation": "account",
+		"tweak":          tweak,
+	})
+	if resp.Data["decoded_value"].(string) != "ACC-abcd1234" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestTransform_Masking(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	testTransformRequest(t, b, storage, "templates/last4", map[string]interface{}{
+		"pattern":  `(\d{3})-(\d{2})-\d{4}`,
+		"alphabet": "builtin/numeric",
+	})
+	testTransformRequest(t, b, storage, "transformations/ssn-mask", map[string]interface{}{
+		"type":              "masking",
+		"template":          "last4",
+		"masking_character": "#",
+		"allowed_roles":     "hr",
+	})
+	testTransformRequest(t, b, storage, "roles/hr", map[string]interface{}{
+		"transformations": "ssn-mask",
+	})
+
+	resp := testTransformRequest(t, b, storage, "encode/hr", map[string]interface{}{
+		"value": "123-45-6789",
+	})
+	if resp.Data["encoded_value"].(string) != "###-##-6789" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "decode/hr",
+		Data: map[string]interface{}{
+			"value": "###-##-6789",
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
+
+func TestTransform_Tokenization(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	testTransformRequest(t, b, storage, "transformations/tokens", map[string]interface{}{
+		"type":          "tokenization",
+		"allowed_roles": "app",
+	})
+	testTransformRequest(t, b, storage, "roles/app", map[string]interface{}{
+		"transformations": "tokens",
+	})
+
+	resp := testTransformRequest(t, b, storage, "encode/app", map[string]interface{}{
+		"value": "4111-1111-1111-1111",
+	})
+	token := resp.Data["encoded_value"].(string)
+	if token == "" || strings.Contains(token, "4111") {
+		t.Fatalf("bad token: %s", token)
+	}
+
+	keys, err := storage.List("token/tokens/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] == token {
+		t.Fatalf("bad token storage: %v", keys)
+	}
+
+	resp = testTransformRequest(t, b, storage, "decode/app", map[string]interface{}{
+		"value": token,
+	})
+	if resp.Data["decoded_value"].(string) != "4111-1111-1111-1111" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Deleting the transformation removes its key and tokens
+	_, err = b.HandleRequest(&logical.Request{
+		Storage:   storage,
+		Operation: logical.DeleteOperation,
+		Path:      "transformations/tokens",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range []string{"token/tokens/", "policy/", "transformation/"} {
+		keys, err := storage.List(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 0 {
+			t.Fatalf("expected no entries under %s, got %v", prefix, keys)
+		}
+	}
+}
+
+func TestTransform_RoleRestrictions(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	testTransformRequest(t, b, storage, "transformations/ccn", map[string]interface{}{
+		"type":          "masking",
+		"template":      "builtin/creditcardnumber",
+		"allowed_roles": "payments",
+	})
+	testTransformRequest(t, b, storage, "roles/payments", map[string]interface{}{
+		"transformations": "ccn",
+	})
+	testTransformRequest(t, b, storage, "roles/other", map[string]interface{}{
+		"transformations": "ccn",
+	})
+	testTransformRequest(t, b, storage, "roles/none", map[string]interface{}{})
+
+	for _, role := range []string{"other", "none", "missing"} {
+		resp, err := b.HandleRequest(&logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "encode/" + role,
+			Data: map[string]interface{}{
+				"value":          "4111111111111111",
+				"transformation": "ccn",
+			},
+		})
+		if err == nil || resp == nil || !resp.IsError() {
+			t.Fatalf("role %s: expected an error, got %#v", role, resp)
+		}
+	}
+
+	// The type of a transformation cannot be changed
+	resp, err := b.HandleRequest(&logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "transformations/ccn",
+		Data: map[string]interface{}{
+			"type": "fpe",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got %#v", resp)
+	}
+}
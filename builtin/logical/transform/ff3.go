@@ -0,0 +1,207 @@
+package transform
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	// ff3TweakSize is the size in bytes of FF3-1 tweaks (56 bits)
+	ff3TweakSize = 7
+
+	// ff3Rounds is the number of Feistel rounds of FF3-1
+	ff3Rounds = 8
+
+	// ff3MinDomainSize is the minimum number of possible values of an input
+	// to encrypt
+	ff3MinDomainSize = 1000000
+
+	// ff3MaxRadix is the largest alphabet supported by FF3-1
+	ff3MaxRadix = 1 << 16
+)
+
+// ff3Cipher implements the FF3-1 format-preserving encryption mode of NIST
+// SP 800-38G Revision 1 over strings of numerals in [0, radix).
+type ff3Cipher struct {
+	block  cipher.Block
+	radix  int
+	minLen int
+	maxLen int
+}
+
+// newFF3Cipher returns an FF3-1 cipher for the given AES key and radix
+func newFF3Cipher(key []byte, radix int) (*ff3Cipher, error) {
+	if radix < 2 || radix > ff3MaxRadix {
+		return nil, fmt.Errorf("radix must be between 2 and %d", ff3MaxRadix)
+	}
+
+	// FF3 uses the AES key with its bytes in reverse order
+	revKey := make([]byte, len(key))
+	for i := range key {
+		revKey[i] = key[len(key)-1-i]
+	}
+	block, err := aes.NewCipher(revKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The minimum length ensures radix^minLen >= 1,000,000; the maximum
+	// length is 2 * floor(log_radix(2^96))
+	minLen := int(math.Ceil(math.Log(ff3MinDomainSize) / math.Log(float64(radix))))
+	if minLen < 2 {
+		minLen = 2
+	}
+	maxLen := 2 * int(math.Floor(96/math.Log2(float64(radix))))
+
+	return &ff3Cipher{
+		block:  block,
+		radix:  radix,
+		minLen: minLen,
+		maxLen: maxLen,
+	}, nil
+}
+
+// Encrypt encrypts the given numerals using the 56-bit tweak
+func (c *ff3Cipher) Encrypt(numerals []uint16, tweak []byte) ([]uint16, error) {
+	return c.cipher(numerals, tweak, true)
+}
+
+// Decrypt decrypts the given numerals using the 56-bit tweak
+func (c *ff3Cipher) Decrypt(numerals []uint16, tweak []byte) ([]uint16, error) {
+	return c.cipher(numerals, tweak, false)
+}
+
+func (c *ff3Cipher) cipher(numerals []uint16, tweak []byte, encrypt bool) ([]uint16, error) {
+	if len(tweak) != ff3TweakSize {
+		return nil, fmt.Errorf("tweak must be %d bytes long", ff3TweakSize)
+	}
+
+	// Split the 56-bit tweak into the two 32-bit halves used by FF3:
+	// TL = T[0..27] || 0^4 and TR = T[32..55] || T[28..31] || 0^4
+	ff3Tweak := []byte{
+		tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0,
+		tweak[4], tweak[5], tweak[6], tweak[3] << 4,
+	}
+	return c.cipherFF3(numerals, ff3Tweak, encrypt)
+}
+
+// cipherFF3 runs the FF3 Feistel network with a 64-bit tweak. FF3-1 only
+// differs from FF3 in how that tweak is built from its 56-bit tweak.
+func (c *ff3Cipher) cipherFF3(numerals []uint16, tweak []byte, encrypt bool) ([]uint16, error) {
+	n := len(numerals)
+	if n < c.minLen || n > c.maxLen {
+		return nil, fmt.Errorf("input length must be between %d and %d for an alphabet of %d characters", c.minLen, c.maxLen, c.radix)
+	}
+	for _, x := range numerals {
+		if int(x) >= c.radix {
+			return nil, fmt.Errorf("numeral %d is out of range for radix %d", x, c.radix)
+		}
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+	a := append([]uint16(nil), numerals[:u]...)
+	b := append([]uint16(nil), numerals[u:]...)
+	tl, tr := tweak[:4], tweak[4:]
+
+	radix := big.NewInt(int64(c.radix))
+	modU := new(big.Int).Exp(radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(radix, big.NewInt(int64(v)), nil)
+
+	for r := 0; r < ff3Rounds; r++ {
+		i := r
+		if !encrypt {
+			i = ff3Rounds - 1 - r
+		}
+
+		m, mod, w := u, modU, tr
+		if i%2 == 1 {
+			m, mod, w = v, modV, tl
+		}
+
+		// In encryption the round function is applied to B and the result
+		// added to A; decryption reverses it from the other half
+		y := c.roundFunction(w, i, b)
+		if !encrypt {
+			y = c.roundFunction(w, i, a)
+		}
+
+		var num *big.Int
+		if encrypt {
+			num = c.num(reverse(a))
+			num.Add(num, y)
+		} else {
+			num = c.num(reverse(b))
+			num.Sub(num, y)
+		}
+		num.Mod(num, mod)
+		result := reverse(c.str(num, m))
+
+		if encrypt {
+			a, b = b, result
+		} else {
+			b, a = a, result
+		}
+	}
+
+	return append(a, b...), nil
+}
+
+// roundFunction computes REVB(CIPH(REVB(P))) for round i, where P is built
+// from the tweak half w and the numerals x
+func (c *ff3Cipher) roundFunction(w []byte, i int, x []uint16) *big.Int {
+	p := make([]byte, aes.BlockSize)
+	copy(p, w)
+	p[3] ^= byte(i)
+
+	numBytes := c.num(reverse(x)).Bytes()
+	copy(p[aes.BlockSize-len(numBytes):], numBytes)
+
+	reverseBytes(p)
+	c.block.Encrypt(p, p)
+	reverseBytes(p)
+
+	return new(big.Int).SetBytes(p)
+}
+
+// num returns the number represented by the numerals, most significant
+// first
+func (c *ff3Cipher) num(x []uint16) *big.Int {
+	radix := big.NewInt(int64(c.radix))
+	result := new(big.Int)
+	for _, d := range x {
+		result.Mul(result, radix)
+		result.Add(result, big.NewInt(int64(d)))
+	}
+	return result
+}
+
+// str returns the m numerals representing x, most significant first
+func (c *ff3Cipher) str(x *big.Int, m int) []uint16 {
+	radix := big.NewInt(int64(c.radix))
+	x = new(big.Int).Set(x)
+	d := new(big.Int)
+	result := make([]uint16, m)
+	for i := m - 1; i >= 0; i-- {
+		x.DivMod(x, radix, d)
+		result[i] = uint16(d.Int64())
+	}
+	return result
+}
+
+func reverse(x []uint16) []uint16 {
+	result := make([]uint16, len(x))
+	for i := range x {
+		result[i] = x[len(x)-1-i]
+	}
+	return result
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
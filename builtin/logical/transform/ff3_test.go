@@ -0,0 +1,199 @@
+package transform
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// The samples of the NIST FF3 examples. FF3-1 shares the Feistel network of
+// FF3, so these exercise everything but the tweak derivation.
+var ff3TestVectors = []struct {
+	key        string
+	radix      int
+	tweak      string
+	plaintext  string
+	ciphertext string
+}{
+	{
+		key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		radix:      10,
+		tweak:      "D8E7920AFA330A73",
+		plaintext:  "890121234567890000",
+		ciphertext: "750918814058654607",
+	},
+	{
+		key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		radix:      10,
+		tweak:      "9A768A92F60E12D8",
+		plaintext:  "890121234567890000",
+		ciphertext: "018989839189395384",
+	},
+	{
+		key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		radix:      10,
+		tweak:      "D8E7920AFA330A73",
+		plaintext:  "89012123456789000000789000000",
+		ciphertext: "48598367162252569629397416226",
+	},
+	{
+		key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		radix:      26,
+		tweak:      "9A768A92F60E12D8",
+		plaintext:  "0123456789abcdefghi",
+		ciphertext: "g2pk40i992fn20cjakb",
+	},
+}
+
+// FF3-1 samples with 56-bit tweaks, which exercise the splitting of the tweak
+// into the two halves used by the Feistel network
+var ff31TestVectors = []struct {
+	key        string
+	tweak      string
+	plaintext  string
+	ciphertext string
+}{
+	{
+		key:        "EF4359D8D580AA4F7F036D6F04FC6A94",
+		tweak:      "D8E7920AFA330A",
+		plaintext:  "890121234567890000",
+		ciphertext: "477064185124354662",
+	},
+	{
+		key:        "2DE79D232DF5585D68CE47882AE256D6",
+		tweak:      "CBD09280979564",
+		plaintext:  "3992520240",
+		ciphertext: "8901801106",
+	},
+	{
+		key:        "01C63017111438F7FC8E24EB16C71AB5",
+		tweak:      "C4E822DCD09F27",
+		plaintext:  "60761757463116869318437658042297305934914824457484538562",
+		ciphertext: "35637144092473838892796702739628394376915177448290847293",
+	},
+}
+
+const testFF3Alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func testFF3Numerals(t *testing.T, s string) []uint16 {
+	numerals := make([]uint16, len(s))
+	for i, r := range s {
+		for j, a := range testFF3Alphabet {
+			if r == a {
+				numerals[i] = uint16(j)
+			}
+		}
+	}
+	return numerals
+}
+
+func testFF3String(numerals []uint16) string {
+	result := make([]byte, len(numerals))
+	for i, n := range numerals {
+		result[i] = testFF3Alphabet[n]
+	}
+	return string(result)
+}
+
+func TestFF3_Vectors(t *testing.T) {
+	for _, tc := range ff3TestVectors {
+		key, _ := hex.DecodeString(tc.key)
+		tweak, _ := hex.DecodeString(tc.tweak)
+
+		c, err := newFF3Cipher(key, tc.radix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct, err := c.cipherFF3(testFF3Numerals(t, tc.plaintext), tweak, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := testFF3String(ct); actual != tc.ciphertext {
+			t.Fatalf("expected %s, got %s", tc.ciphertext, actual)
+		}
+
+		pt, err := c.cipherFF3(ct, tweak, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := testFF3String(pt); actual != tc.plaintext {
+			t.Fatalf("expected %s, got %s", tc.plaintext, actual)
+		}
+	}
+}
+
+func TestFF3_1_Vectors(t *testing.T) {
+	for _, tc := range ff31TestVectors {
+		key, _ := hex.DecodeString(tc.key)
+		tweak, _ := hex.DecodeString(tc.tweak)
+
+		c, err := newFF3Cipher(key, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct, err := c.Encrypt(testFF3Numerals(t, tc.plaintext), tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := testFF3String(ct); actual != tc.ciphertext {
+			t.Fatalf("expected %s, got %s", tc.ciphertext, actual)
+		}
+
+		pt, err := c.Decrypt(ct, tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := testFF3String(pt); actual != tc.plaintext {
+			t.Fatalf("expected %s, got %s", tc.plaintext, actual)
+		}
+	}
+}
+
+func TestFF3_1_RoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	tweak := []byte{1, 2, 3, 4, 5, 6, 7}
+
+	c, err := newFF3Cipher(key, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.minLen != 6 || c.maxLen != 56 {
+		t.Fatalf("bad length bounds: %d, %d", c.minLen, c.maxLen)
+	}
+
+	plaintext := testFF3Numerals(t, "4111111111111111")
+	ct, err := c.Encrypt(plaintext, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testFF3String(ct) == testFF3String(plaintext) {
+		t.Fatal("ciphertext matches plaintext")
+	}
+
+	// The low nibble of the fourth tweak byte is part of the tweak
+	otherTweak := []byte{1, 2, 3, 5, 5, 6, 7}
+	other, err := c.Encrypt(plaintext, otherTweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testFF3String(other) == testFF3String(ct) {
+		t.Fatal("expected different ciphertexts for different tweaks")
+	}
+
+	pt, err := c.Decrypt(ct, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testFF3String(pt) != testFF3String(plaintext) {
+		t.Fatalf("expected %s, got %s", testFF3String(plaintext), testFF3String(pt))
+	}
+
+	// Inputs outside of the length bounds are rejected
+	if _, err := c.Encrypt(testFF3Numerals(t, "12345"), tweak); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := c.Encrypt(plaintext, tweak[:6]); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathDecode() *framework.Path {
+	return &framework.Path{
+		Pattern: "decode/" + framework.GenericNameRegex("role_name"),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"value": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The value to decode, as returned by encode",
+			},
+
+			"transformation": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The transformation the value was encoded
+with. Can be omitted if the role only has one
+transformation.`,
+			},
+
+			"tweak": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64 encoded 7-byte tweak the value was
+encoded with. Required by FPE transformations
+whose tweak source is "supplied" or
+"generated".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDecodeWrite,
+		},
+
+		HelpSynopsis:    pathDecodeHelpSyn,
+		HelpDescription: pathDecodeHelpDesc,
+	}
+}
+
+func (b *backend) pathDecodeWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("role_name").(string)
+	value := d.Get("value").(string)
+	if value == "" {
+		return logical.ErrorResponse("missing value"), logical.ErrInvalidRequest
+	}
+
+	t, name, err := b.getRoleTransformation(req.Storage, roleName, d.Get("transformation").(string))
+	if err != nil {
+		return transformErrorResponse(err)
+	}
+
+	var decoded string
+	switch t.Type {
+	case transformationTypeFPE:
+		tweak, err := transformationTweak(t, d.Get("tweak").(string))
+		if err != nil {
+			return transformErrorResponse(err)
+		}
+		decoded, err = b.fpe(req.Storage, name, t, value, tweak, false)
+		if err != nil {
+			return transformErrorResponse(err)
+		}
+
+	case transformationTypeMasking:
+		return logical.ErrorResponse("masked values cannot be decoded"), logical.ErrInvalidRequest
+
+	case transformationTypeTokenization:
+		decoded, err = b.detokenize(req.Storage, name, value)
+		if err != nil {
+			return transformErrorResponse(err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown transformation type %q", t.Type)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"decoded_value": decoded,
+		},
+	}, nil
+}
+
+const pathDecodeHelpSyn = `Decode a value with a transformation of a role`
+
+const pathDecodeHelpDesc = `
+This path uses one of the transformations of the named role to decode a
+value returned by the encode endpoint. Values encoded by masking
+transformations cannot be decoded.
+`
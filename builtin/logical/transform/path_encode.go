@@ -0,0 +1,139 @@
+package transform
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathEncode() *framework.Path {
+	return &framework.Path{
+		Pattern: "encode/" + framework.GenericNameRegex("role_name"),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"value": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The value to encode",
+			},
+
+			"transformation": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The transformation to apply. Can be omitted
+if the role only has one transformation.`,
+			},
+
+			"tweak": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64 encoded 7-byte tweak. Required by
+FPE transformations whose tweak source is
+"supplied".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathEncodeWrite,
+		},
+
+		HelpSynopsis:    pathEncodeHelpSyn,
+		HelpDescription: pathEncodeHelpDesc,
+	}
+}
+
+func (b *backend) pathEncodeWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("role_name").(string)
+	value := d.Get("value").(string)
+	if value == "" {
+		return logical.ErrorResponse("missing value"), logical.ErrInvalidRequest
+	}
+
+	t, name, err := b.getRoleTransformation(req.Storage, roleName, d.Get("transformation").(string))
+	if err != nil {
+		return transformErrorResponse(err)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{},
+	}
+
+	var encoded string
+	switch t.Type {
+	case transformationTypeFPE:
+		var tweak []byte
+		if t.TweakSource == tweakSourceGenerated {
+			tweak = make([]byte, ff3TweakSize)
+			if _, err := rand.Read(tweak); err != nil {
+				return nil, err
+			}
+			resp.Data["tweak"] = base64.StdEncoding.EncodeToString(tweak)
+		} else {
+			tweak, err = transformationTweak(t, d.Get("tweak").(string))
+			if err != nil {
+				return transformErrorResponse(err)
+			}
+		}
+		encoded, err = b.fpe(req.Storage, name, t, value, tweak, true)
+
+	case transformationTypeMasking:
+		encoded, err = b.mask(req.Storage, t, value)
+
+	case transformationTypeTokenization:
+		encoded, err = b.tokenize(req.Storage, name, value)
+
+	default:
+		return nil, fmt.Errorf("unknown transformation type %q", t.Type)
+	}
+	if err != nil {
+		return transformErrorResponse(err)
+	}
+
+	resp.Data["encoded_value"] = encoded
+	return resp, nil
+}
+
+// transformationTweak returns the FF3-1 tweak to use with an FPE
+// transformation, either internal to it or the supplied one
+func transformationTweak(t *transformationEntry, supplied string) ([]byte, error) {
+	if t.TweakSource == tweakSourceInternal {
+		return t.InternalTweak, nil
+	}
+
+	if supplied == "" {
+		return nil, errutil.UserError{Err: "missing tweak"}
+	}
+	tweak, err := base64.StdEncoding.DecodeString(supplied)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode tweak"}
+	}
+	if len(tweak) != ff3TweakSize {
+		return nil, errutil.UserError{Err: fmt.Sprintf("tweak must be %d bytes long", ff3TweakSize)}
+	}
+	return tweak, nil
+}
+
+// transformErrorResponse turns user errors into error responses
+func transformErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathEncodeHelpSyn = `Encode a value with a transformation of a role`
+
+const pathEncodeHelpDesc = `
+This path uses one of the transformations of the named role to encode the
+given value. FPE and masking transformations preserve the format of the
+value; tokenization transformations return a token that can be decoded
+back into the value.
+`
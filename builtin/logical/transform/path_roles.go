@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// roleEntry grants the use of transformations through the encode and decode
+// endpoints
+type roleEntry struct {
+	Transformations []string `json:"transformations" structs:"transformations" mapstructure:"transformations"`
+}
+
+func (b *backend) pathListRoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathRoleList,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func (b *backend) pathRoles() *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role",
+			},
+
+			"transformations": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "The transformations that can be used with this role",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRoleWrite,
+			logical.ReadOperation:   b.pathRoleRead,
+			logical.DeleteOperation: b.pathRoleDelete,
+		},
+
+		HelpSynopsis:    pathRoleHelpSyn,
+		HelpDescription: pathRoleHelpDesc,
+	}
+}
+
+func (b *backend) getRole(s logical.Storage, name string) (*roleEntry, error) {
+	entry, err := s.Get("role/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathRoleList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("role/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathRoleWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	entry := &roleEntry{
+		Transformations: strutil.RemoveDuplicates(d.Get("transformations").([]string), false),
+	}
+
+	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(jsonEntry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRoleRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := b.getRole(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"transformations": role.Transformations,
+		},
+	}, nil
+}
+
+func (b *backend) pathRoleDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete("role/" + d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathRoleHelpSyn = `Manage the roles that can encode and decode values`
+
+const pathRoleHelpDesc = `
+This path is used to manage the roles of the transform backend. A role
+lists the transformations that can be used through the encode and decode
+endpoints of that role.
+`
@@ -0,0 +1,267 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	templateTypeRegex = "regex"
+
+	builtinPrefix = "builtin/"
+)
+
+// builtinAlphabets are the alphabets that can be referred to by name instead
+// of listing their characters
+var builtinAlphabets = map[string]string{
+	"builtin/numeric":           "0123456789",
+	"builtin/alphalower":        "abcdefghijklmnopqrstuvwxyz",
+	"builtin/alphaupper":        "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"builtin/alphanumeric":      "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"builtin/alphanumericlower": "0123456789abcdefghijklmnopqrstuvwxyz",
+	"builtin/alphanumericupper": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+}
+
+// builtinTemplates are the templates that can be used by transformations
+// without being created first
+var builtinTemplates = map[string]*templateEntry{
+	"builtin/creditcardnumber": &templateEntry{
+		Type:     templateTypeRegex,
+		Pattern:  `(\d{4})[- ]?(\d{4})[- ]?(\d{4})[- ]?(\d{4})`,
+		Alphabet: "builtin/numeric",
+	},
+	"builtin/socialsecuritynumber": &templateEntry{
+		Type:     templateTypeRegex,
+		Pattern:  `(\d{3})[- ]?(\d{2})[- ]?(\d{4})`,
+		Alphabet: "builtin/numeric",
+	},
+}
+
+// templateEntry describes the format of the values a transformation applies
+// to. The characters matched by the capture groups of the pattern are the
+// ones that get transformed; all of them must be part of the alphabet.
+type templateEntry struct {
+	Type     string `json:"type" structs:"type" mapstructure:"type"`
+	Pattern  string `json:"pattern" structs:"pattern" mapstructure:"pattern"`
+	Alphabet string `json:"alphabet" structs:"alphabet" mapstructure:"alphabet"`
+}
+
+// regexp compiles the pattern of the template so that it has to match the
+// whole value
+func (t *templateEntry) regexp() (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + t.Pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("pattern must contain at least one capture group")
+	}
+	return re, nil
+}
+
+// alphabet returns the characters of the alphabet of the template
+func (t *templateEntry) alphabet() ([]rune, error) {
+	alphabet := t.Alphabet
+	if strings.HasPrefix(alphabet, builtinPrefix) {
+		var ok bool
+		alphabet, ok = builtinAlphabets[alphabet]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin alphabet %q", t.Alphabet)
+		}
+	}
+
+	runes := []rune(alphabet)
+	if len(runes) < 2 {
+		return nil, fmt.Errorf("alphabet must contain at least two characters")
+	}
+	if len(runes) > ff3MaxRadix {
+		return nil, fmt.Errorf("alphabet must contain at most %d characters", ff3MaxRadix)
+	}
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		if seen[r] {
+			return nil, fmt.Errorf("alphabet contains %q more than once", r)
+		}
+		seen[r] = true
+	}
+
+	return runes, nil
+}
+
+// validate checks that the pattern and the alphabet of the template can be
+// used
+func (t *templateEntry) validate() error {
+	if t.Type != templateTypeRegex {
+		return fmt.Errorf("unknown template type %q", t.Type)
+	}
+	if _, err := t.regexp(); err != nil {
+		return fmt.Errorf("invalid pattern: %v", err)
+	}
+	if _, err := t.alphabet(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b *backend) pathListTemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "templates/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathTemplateList,
+		},
+
+		HelpSynopsis:    pathTemplateHelpSyn,
+		HelpDescription: pathTemplateHelpDesc,
+	}
+}
+
+func (b *backend) pathTemplates() *framework.Path {
+	return &framework.Path{
+		Pattern: "templates/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the template",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     templateTypeRegex,
+				Description: `The type of the template. Only "regex" is currently supported.`,
+			},
+
+			"pattern": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The regular expression that values must
+match in full. The characters matched by its
+capture groups are the ones transformed.`,
+			},
+
+			"alphabet": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The characters that transformed values
+are made of, or the name of a builtin alphabet:
+"builtin/numeric", "builtin/alphalower",
+"builtin/alphaupper", "builtin/alphanumeric",
+"builtin/alphanumericlower" or
+"builtin/alphanumericupper".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTemplateWrite,
+			logical.ReadOperation:   b.pathTemplateRead,
+			logical.DeleteOperation: b.pathTemplateDelete,
+		},
+
+		HelpSynopsis:    pathTemplateHelpSyn,
+		HelpDescription: pathTemplateHelpDesc,
+	}
+}
+
+// getTemplate returns the named template, which may be one of the builtin
+// templates
+func (b *backend) getTemplate(s logical.Storage, name string) (*templateEntry, error) {
+	if t, ok := builtinTemplates[name]; ok {
+		return t, nil
+	}
+
+	entry, err := s.Get("template/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result templateEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathTemplateList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("template/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathTemplateWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	entry := &templateEntry{
+		Type:     d.Get("type").(string),
+		Pattern:  d.Get("pattern").(string),
+		Alphabet: d.Get("alphabet").(string),
+	}
+	if entry.Pattern == "" {
+		return logical.ErrorResponse("missing pattern"), nil
+	}
+	if entry.Alphabet == "" {
+		return logical.ErrorResponse("missing alphabet"), nil
+	}
+	if err := entry.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	jsonEntry, err := logical.StorageEntryJSON("template/"+name, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(jsonEntry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathTemplateRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	t, err := b.getTemplate(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"type":     t.Type,
+			"pattern":  t.Pattern,
+			"alphabet": t.Alphabet,
+		},
+	}, nil
+}
+
+func (b *backend) pathTemplateDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete("template/" + d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathTemplateHelpSyn = `Manage the templates describing the format of transformed values`
+
+const pathTemplateHelpDesc = `
+This path is used to manage the templates used by transformations. A
+template is a regular expression that values must match, whose capture
+groups select the characters to transform, and the alphabet those
+characters are drawn from.
+
+The builtin templates "builtin/creditcardnumber" and
+"builtin/socialsecuritynumber" can be used without being created.
+`
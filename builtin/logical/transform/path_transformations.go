@@ -0,0 +1,332 @@
+package transform
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	transformationTypeFPE          = "fpe"
+	transformationTypeMasking      = "masking"
+	transformationTypeTokenization = "tokenization"
+
+	tweakSourceSupplied  = "supplied"
+	tweakSourceGenerated = "generated"
+	tweakSourceInternal  = "internal"
+)
+
+// transformationEntry describes how the values matching a template are
+// transformed. FPE and tokenization transformations have a key of the same
+// name in the lock manager.
+type transformationEntry struct {
+	Type         string   `json:"type" structs:"type" mapstructure:"type"`
+	Template     string   `json:"template" structs:"template" mapstructure:"template"`
+	AllowedRoles []string `json:"allowed_roles" structs:"allowed_roles" mapstructure:"allowed_roles"`
+
+	// The source of the FF3-1 tweak of FPE transformations, and the tweak
+	// itself when it is internal
+	TweakSource   string `json:"tweak_source" structs:"tweak_source" mapstructure:"tweak_source"`
+	InternalTweak []byte `json:"internal_tweak" structs:"-" mapstructure:"-"`
+
+	// The character replacing the transformed characters of masking
+	// transformations
+	MaskingCharacter string `json:"masking_character" structs:"masking_character" mapstructure:"masking_character"`
+}
+
+// roleAllowed returns whether the transformation can be used by the role
+func (t *transformationEntry) roleAllowed(role string) bool {
+	return strutil.StrListContains(t.AllowedRoles, "*") || strutil.StrListContains(t.AllowedRoles, role)
+}
+
+func (b *backend) pathListTransformations() *framework.Path {
+	return &framework.Path{
+		Pattern: "transformations/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathTransformationList,
+		},
+
+		HelpSynopsis:    pathTransformationHelpSyn,
+		HelpDescription: pathTransformationHelpDesc,
+	}
+}
+
+func (b *backend) pathTransformations() *framework.Path {
+	return &framework.Path{
+		Pattern: "transformations/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the transformation",
+			},
+
+			"type": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The type of the transformation: "fpe",
+"masking" or "tokenization". Cannot be changed
+once the transformation is created.`,
+			},
+
+			"template": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The template describing the values to
+transform. Required for FPE and masking
+transformations.`,
+			},
+
+			"tweak_source": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: tweakSourceSupplied,
+				Description: `The source of the tweak of FPE
+transformations: "supplied" with each request,
+"generated" on each encode and returned, or
+"internal" to the transformation. Cannot be
+changed once the transformation is created.
+Defaults to "supplied".`,
+			},
+
+			"masking_character": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "*",
+				Description: `The character used by masking transformations. Defaults to "*".`,
+			},
+
+			"allowed_roles": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `The roles allowed to use this
+transformation. "*" allows all roles.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTransformationWrite,
+			logical.ReadOperation:   b.pathTransformationRead,
+			logical.DeleteOperation: b.pathTransformationDelete,
+		},
+
+		HelpSynopsis:    pathTransformationHelpSyn,
+		HelpDescription: pathTransformationHelpDesc,
+	}
+}
+
+func (b *backend) getTransformation(s logical.Storage, name string) (*transformationEntry, error) {
+	entry, err := s.Get("transformation/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result transformationEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathTransformationList(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List("transformation/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathTransformationWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	entry, err := b.getTransformation(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	created := entry == nil
+	if created {
+		entry = &transformationEntry{
+			Type:        d.Get("type").(string),
+			TweakSource: d.Get("tweak_source").(string),
+		}
+	} else {
+		if typeRaw, ok := d.GetOk("type"); ok && typeRaw.(string) != entry.Type {
+			return logical.ErrorResponse("the type of a transformation cannot be changed"), nil
+		}
+		if tweakSourceRaw, ok := d.GetOk("tweak_source"); ok && tweakSourceRaw.(string) != entry.TweakSource {
+			return logical.ErrorResponse("the tweak source of a transformation cannot be changed"), nil
+		}
+	}
+
+	if templateRaw, ok := d.GetOk("template"); ok {
+		entry.Template = templateRaw.(string)
+	}
+	if maskingCharRaw, ok := d.GetOk("masking_character"); ok {
+		entry.MaskingCharacter = maskingCharRaw.(string)
+	} else if created {
+		entry.MaskingCharacter = d.Get("masking_character").(string)
+	}
+	if allowedRolesRaw, ok := d.GetOk("allowed_roles"); ok {
+		entry.AllowedRoles = strutil.RemoveDuplicates(allowedRolesRaw.([]string), false)
+	}
+
+	switch entry.Type {
+	case transformationTypeFPE:
+		switch entry.TweakSource {
+		case tweakSourceSupplied, tweakSourceGenerated:
+		case tweakSourceInternal:
+			if entry.InternalTweak == nil {
+				entry.InternalTweak = make([]byte, ff3TweakSize)
+				if _, err := rand.Read(entry.InternalTweak); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unknown tweak source %q", entry.TweakSource)), nil
+		}
+	case transformationTypeMasking:
+		if len([]rune(entry.MaskingCharacter)) != 1 {
+			return logical.ErrorResponse("masking character must be a single character"), nil
+		}
+	case transformationTypeTokenization:
+	case "":
+		return logical.ErrorResponse("missing type"), nil
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unknown transformation type %q", entry.Type)), nil
+	}
+
+	if entry.Type != transformationTypeTokenization {
+		if entry.Template == "" {
+			return logical.ErrorResponse("missing template"), nil
+		}
+		t, err := b.getTemplate(req.Storage, entry.Template)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			return logical.ErrorResponse(fmt.Sprintf("template %q not found", entry.Template)), nil
+		}
+	}
+
+	// FPE and tokenization transformations get their key on creation
+	if created && entry.Type != transformationTypeMasking {
+		p, lock, _, err := b.lm.GetPolicyUpsert(keysutil.PolicyRequest{
+			Storage: req.Storage,
+			Name:    name,
+			KeyType: keysutil.KeyType_AES256_GCM96,
+		})
+		if lock != nil {
+			defer lock.RUnlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, fmt.Errorf("error generating key: returned policy was nil")
+		}
+	}
+
+	jsonEntry, err := logical.StorageEntryJSON("transformation/"+name, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(jsonEntry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathTransformationRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	t, err := b.getTransformation(req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"type":          t.Type,
+			"template":      t.Template,
+			"allowed_roles": t.AllowedRoles,
+		},
+	}
+	switch t.Type {
+	case transformationTypeFPE:
+		resp.Data["tweak_source"] = t.TweakSource
+	case transformationTypeMasking:
+		resp.Data["masking_character"] = t.MaskingCharacter
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathTransformationDelete(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	t, err := b.getTransformation(req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+
+	if t.Type != transformationTypeMasking {
+		// The key of a transformation is never deleted through any other
+		// means, so it is only marked as deletable now
+		p, lock, err := b.lm.GetPolicyExclusive(req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			p.DeletionAllowed = true
+			err = p.Persist(req.Storage)
+		}
+		if lock != nil {
+			lock.Unlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			if err := b.lm.DeletePolicy(req.Storage, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if t.Type == transformationTypeTokenization {
+		if err := b.deleteTokens(req.Storage, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Storage.Delete("transformation/" + name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathTransformationHelpSyn = `Manage the transformations applied to values`
+
+const pathTransformationHelpDesc = `
+This path is used to manage the transformations of the transform backend.
+
+"fpe" transformations encrypt the characters selected by their template
+with FF3-1 format-preserving encryption, so that the encoded value still
+matches the template. "masking" transformations replace those characters
+with the masking character and cannot be decoded. "tokenization"
+transformations replace the whole value with a random token and store the
+encrypted value so that the token can be decoded later.
+`
@@ -0,0 +1,310 @@
+package transform
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// tokenSize is the number of random bytes in tokens
+const tokenSize = 24
+
+// tokenEntry is the stored mapping of a token to its encrypted value
+type tokenEntry struct {
+	Ciphertext   string    `json:"ciphertext"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// getRoleTransformation returns the named transformation if the role is
+// allowed to use it. The transformation can be omitted when the role only
+// has one.
+func (b *backend) getRoleTransformation(s logical.Storage, roleName, name string) (*transformationEntry, string, error) {
+	role, err := b.getRole(s, roleName)
+	if err != nil {
+		return nil, "", err
+	}
+	if role == nil {
+		return nil, "", errutil.UserError{Err: fmt.Sprintf("role %q not found", roleName)}
+	}
+
+	if name == "" {
+		if len(role.Transformations) != 1 {
+			return nil, "", errutil.UserError{Err: "missing transformation"}
+		}
+		name = role.Transformations[0]
+	}
+	if !strutil.StrListContains(role.Transformations, name) {
+		return nil, "", errutil.UserError{Err: fmt.Sprintf("transformation %q is not allowed for role %q", name, roleName)}
+	}
+
+	t, err := b.getTransformation(s, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if t == nil {
+		return nil, "", errutil.UserError{Err: fmt.Sprintf("transformation %q not found", name)}
+	}
+	if !t.roleAllowed(roleName) {
+		return nil, "", errutil.UserError{Err: fmt.Sprintf("role %q is not allowed to use transformation %q", roleName, name)}
+	}
+
+	return t, name, nil
+}
+
+// applyTemplate replaces the characters of the value selected by the capture
+// groups of the template with the output of fn, which must return as many
+// characters as it is given
+func applyTemplate(tmpl *templateEntry, value string, fn func([]rune) ([]rune, error)) (string, error) {
+	re, err := tmpl.regexp()
+	if err != nil {
+		return "", err
+	}
+
+	matches := re.FindStringSubmatchIndex(value)
+	if matches == nil {
+		return "", errutil.UserError{Err: "value does not match the template"}
+	}
+	selected := make([]bool, len(value))
+	for i := 2; i < len(matches); i += 2 {
+		if matches[i] < 0 {
+			continue
+		}
+		for j := matches[i]; j < matches[i+1]; j++ {
+			selected[j] = true
+		}
+	}
+
+	var result, chars []rune
+	var positions []int
+	for offset, r := range value {
+		if selected[offset] {
+			positions = append(positions, len(result))
+			chars = append(chars, r)
+		}
+		result = append(result, r)
+	}
+
+	transformed, err := fn(chars)
+	if err != nil {
+		return "", err
+	}
+	for i, pos := range positions {
+		result[pos] = transformed[i]
+	}
+
+	return string(result), nil
+}
+
+// getTemplateForTransformation returns the template of the transformation,
+// which must exist
+func (b *backend) getTemplateForTransformation(s logical.Storage, t *transformationEntry) (*templateEntry, error) {
+	tmpl, err := b.getTemplate(s, t.Template)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("template %q not found", t.Template)}
+	}
+	return tmpl, nil
+}
+
+// fpe encrypts or decrypts the characters of the value selected by the
+// template of the transformation using FF3-1. Encoded values carry no key
+// version, so transformation keys are never rotated and the latest version
+// is always used.
+func (b *backend) fpe(s logical.Storage, name string, t *transformationEntry, value string, tweak []byte, encrypt bool) (string, error) {
+	tmpl, err := b.getTemplateForTransformation(s, t)
+	if err != nil {
+		return "", err
+	}
+	alphabet, err := tmpl.alphabet()
+	if err != nil {
+		return "", err
+	}
+	indexes := make(map[rune]uint16, len(alphabet))
+	for i, r := range alphabet {
+		indexes[r] = uint16(i)
+	}
+
+	p, lock, err := b.lm.GetPolicyShared(s, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", fmt.Errorf("key for transformation %q not found", name)
+	}
+
+	c, err := newFF3Cipher(p.Keys[p.LatestVersion].Key, len(alphabet))
+	if err != nil {
+		return "", err
+	}
+
+	return applyTemplate(tmpl, value, func(chars []rune) ([]rune, error) {
+		numerals := make([]uint16, len(chars))
+		for i, r := range chars {
+			n, ok := indexes[r]
+			if !ok {
+				return nil, errutil.UserError{Err: fmt.Sprintf("character %q is not part of the alphabet of the template", r)}
+			}
+			numerals[i] = n
+		}
+
+		var out []uint16
+		var err error
+		if encrypt {
+			out, err = c.Encrypt(numerals, tweak)
+		} else {
+			out, err = c.Decrypt(numerals, tweak)
+		}
+		if err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+
+		result := make([]rune, len(out))
+		for i, n := range out {
+			result[i] = alphabet[n]
+		}
+		return result, nil
+	})
+}
+
+// mask replaces the characters of the value selected by the template of the
+// transformation with its masking character
+func (b *backend) mask(s logical.Storage, t *transformationEntry, value string) (string, error) {
+	tmpl, err := b.getTemplateForTransformation(s, t)
+	if err != nil {
+		return "", err
+	}
+	maskChar := []rune(t.MaskingCharacter)[0]
+
+	return applyTemplate(tmpl, value, func(chars []rune) ([]rune, error) {
+		result := make([]rune, len(chars))
+		for i := range result {
+			result[i] = maskChar
+		}
+		return result, nil
+	})
+}
+
+// tokenize returns a new random token for the value, storing the value
+// encrypted with the key of the transformation
+func (b *backend) tokenize(s logical.Storage, name, value string) (string, error) {
+	p, lock, err := b.lm.GetPolicyShared(s, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", fmt.Errorf("key for transformation %q not found", name)
+	}
+
+	tokenBytes := make([]byte, tokenSize)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	ciphertext, err := p.Encrypt(0, nil, nil, base64.StdEncoding.EncodeToString([]byte(value)))
+	if err != nil {
+		return "", err
+	}
+
+	path, err := tokenPath(p, name, token)
+	if err != nil {
+		return "", err
+	}
+	entry, err := logical.StorageEntryJSON(path, &tokenEntry{
+		Ciphertext:   ciphertext,
+		CreationTime: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.Put(entry); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// detokenize returns the value the token was issued for
+func (b *backend) detokenize(s logical.Storage, name, token string) (string, error) {
+	p, lock, err := b.lm.GetPolicyShared(s, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", fmt.Errorf("key for transformation %q not found", name)
+	}
+
+	path, err := tokenPath(p, name, token)
+	if err != nil {
+		return "", err
+	}
+	raw, err := s.Get(path)
+	if err != nil {
+		return "", err
+	}
+	if raw == nil {
+		return "", errutil.UserError{Err: "token not found"}
+	}
+	var entry tokenEntry
+	if err := raw.DecodeJSON(&entry); err != nil {
+		return "", err
+	}
+
+	plaintext, err := p.Decrypt(nil, nil, entry.Ciphertext)
+	if err != nil {
+		return "", err
+	}
+	value, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+// tokenPath returns the storage path of a token. Tokens are stored under
+// their HMAC so that listing the storage does not reveal them.
+func tokenPath(p *keysutil.Policy, name, token string) (string, error) {
+	key, err := p.HMACKey(p.LatestVersion)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return "token/" + name + "/" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// deleteTokens removes all the tokens of a tokenization transformation
+func (b *backend) deleteTokens(s logical.Storage, name string) error {
+	prefix := "token/" + name + "/"
+	tokens, err := s.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := s.Delete(prefix + token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package transit
 
 import (
+	"crypto/rsa"
 	"strings"
+	"sync"
 
+	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -13,6 +16,11 @@ func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
 	if err := b.Setup(conf); err != nil {
 		return nil, err
 	}
+	if conf.StorageView != nil {
+		if err := b.loadCacheConfig(conf.StorageView); err != nil {
+			return nil, err
+		}
+	}
 	return b, nil
 }
 
@@ -23,7 +31,11 @@ func Backend(conf *logical.BackendConfig) *backend {
 			// Rotate/Config needs to come before Keys
 			// as the handler is greedy
 			b.pathConfig(),
+			b.pathCacheConfig(),
 			b.pathRotate(),
+			b.pathTrim(),
+			b.pathImport(),
+			b.pathImportVersion(),
 			b.pathRewrap(),
 			b.pathKeys(),
 			b.pathListKeys(),
@@ -36,21 +48,32 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathHMAC(),
 			b.pathSign(),
 			b.pathVerify(),
+			b.pathBackup(),
+			b.pathRestore(),
+			b.pathWrappingKey(),
 		},
 
-		Secrets:     []*framework.Secret{},
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Secrets:      []*framework.Secret{},
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
+		BackendType:  logical.TypeLogical,
 	}
 
+	b.view = conf.StorageView
 	b.lm = keysutil.NewLockManager(conf.System.CachingDisabled())
+	b.lm.SetMountPoint(conf.MountPoint)
 
 	return &b
 }
 
 type backend struct {
 	*framework.Backend
-	lm *keysutil.LockManager
+	view logical.Storage
+	lm   *keysutil.LockManager
+
+	// The RSA key used to wrap imported keys, loaded on first use
+	wrappingKey     *rsa.PrivateKey
+	wrappingKeyLock sync.RWMutex
 }
 
 func (b *backend) invalidate(key string) {
@@ -61,5 +84,27 @@ func (b *backend) invalidate(key string) {
 	case strings.HasPrefix(key, "policy/"):
 		name := strings.TrimPrefix(key, "policy/")
 		b.lm.InvalidatePolicy(name)
+	case key == wrappingKeyPath:
+		b.wrappingKeyLock.Lock()
+		b.wrappingKey = nil
+		b.wrappingKeyLock.Unlock()
+	case key == cacheConfigPath:
+		// Another node changed the size of the cache
+		if err := b.loadCacheConfig(b.view); err != nil {
+			b.Logger().Error("transit: failed to reload cache configuration", "error", err)
+		}
+	}
+}
+
+// periodicFunc is invoked once a minute by the rollback manager of the active
+// node. Transit uses it to rotate the keys whose automatic rotation period
+// has elapsed.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	// Secondaries receive their keys, rotated or not, from the primary
+	repState := b.System().ReplicationState()
+	if repState.HasState(consts.ReplicationPerformanceSecondary) || repState.HasState(consts.ReplicationDRSecondary) {
+		return nil
 	}
+
+	return b.autoRotateKeys(req.Storage)
 }
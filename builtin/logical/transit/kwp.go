@@ -0,0 +1,64 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// kwpIV is the alternative initial value of RFC 5649. Transit only unwraps
+// imported keys; wrapping is left to the clients.
+var kwpIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+// kwpUnwrap unwraps the ciphertext wrapped with AES Key Wrap with Padding as
+// specified in RFC 5649
+func kwpUnwrap(kek, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < 16 || len(ciphertext)%8 != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+
+	n := len(ciphertext)/8 - 1
+	a := make([]byte, 8)
+	r := make([]byte, 8*n)
+
+	buf := make([]byte, 16)
+	if n == 1 {
+		block.Decrypt(buf, ciphertext)
+		copy(a, buf[:8])
+		copy(r, buf[8:])
+	} else {
+		copy(a, ciphertext[:8])
+		copy(r, ciphertext[8:])
+		for j := 5; j >= 0; j-- {
+			for i := n - 1; i >= 0; i-- {
+				t := uint64(n*j + i + 1)
+				binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+				copy(buf[8:], r[8*i:8*i+8])
+				block.Decrypt(buf, buf)
+				copy(a, buf[:8])
+				copy(r[8*i:], buf[8:])
+			}
+		}
+	}
+
+	// Check the integrity of the alternative initial value and the padding
+	if subtle.ConstantTimeCompare(a[:4], kwpIV) != 1 {
+		return nil, errors.New("integrity check failed")
+	}
+	mli := int(binary.BigEndian.Uint32(a[4:]))
+	if mli <= 8*(n-1) || mli > 8*n {
+		return nil, errors.New("integrity check failed")
+	}
+	for _, b := range r[mli:] {
+		if b != 0 {
+			return nil, errors.New("integrity check failed")
+		}
+	}
+
+	return r[:mli], nil
+}
@@ -0,0 +1,96 @@
+package transit
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// kwpWrap wraps the plaintext with AES Key Wrap with Padding as specified in
+// RFC 5649
+func kwpWrap(kek, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 || uint64(len(plaintext)) > uint64(^uint32(0)) {
+		return nil, errors.New("invalid plaintext length")
+	}
+
+	// Build the alternative initial value and pad the plaintext to a
+	// multiple of 8 bytes
+	a := make([]byte, 8)
+	copy(a, kwpIV)
+	binary.BigEndian.PutUint32(a[4:], uint32(len(plaintext)))
+	n := (len(plaintext) + 7) / 8
+	r := make([]byte, 8*n)
+	copy(r, plaintext)
+
+	buf := make([]byte, 16)
+	if n == 1 {
+		copy(buf, a)
+		copy(buf[8:], r)
+		block.Encrypt(buf, buf)
+		return buf, nil
+	}
+
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(buf, a)
+			copy(buf[8:], r[8*i:8*i+8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r[8*i:], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+func TestTransit_KWP(t *testing.T) {
+	// Test vectors from RFC 5649
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	cases := []struct {
+		key     string
+		wrapped string
+	}{
+		{
+			key:     "c37b7e6492584340bed12207808941155068f738",
+			wrapped: "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+		},
+		{
+			key:     "466f7250617369",
+			wrapped: "afbeb0f07dfbf5419200f2ccb50bb24f",
+		},
+	}
+
+	for _, tc := range cases {
+		key, _ := hex.DecodeString(tc.key)
+		expected, _ := hex.DecodeString(tc.wrapped)
+
+		wrapped, err := kwpWrap(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Fatalf("expected %x, got %x", expected, wrapped)
+		}
+
+		unwrapped, err := kwpUnwrap(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("expected %x, got %x", key, unwrapped)
+		}
+
+		wrapped[len(wrapped)-1] ^= 1
+		if _, err := kwpUnwrap(kek, wrapped); err == nil {
+			t.Fatal("expected integrity check failure")
+		}
+	}
+}
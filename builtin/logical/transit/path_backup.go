@@ -0,0 +1,53 @@
+package transit
+
+import (
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathBackup() *framework.Path {
+	return &framework.Path{
+		Pattern: "backup/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathBackupRead,
+		},
+
+		HelpSynopsis:    pathBackupHelpSyn,
+		HelpDescription: pathBackupHelpDesc,
+	}
+}
+
+func (b *backend) pathBackupRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	backup, err := b.lm.BackupPolicy(req.Storage, d.Get("name").(string))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"backup": backup,
+		},
+	}, nil
+}
+
+const pathBackupHelpSyn = `Backup the named key`
+
+const pathBackupHelpDesc = `
+This path is used to back up the named key, including all of its
+archived versions. The backup is versioned and holds the key material
+in the clear, so that it can be restored into any mount, including
+one on another cluster, using the restore endpoint. Backups are
+therefore only available for keys that allow plaintext backups.
+`
@@ -0,0 +1,172 @@
+package transit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_BackupRestore(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err:%v resp:%#v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	// Keys cannot be backed up unless they allow it
+	mustRequest(logical.UpdateOperation, "keys/nobackup", nil)
+	if _, err := request(logical.ReadOperation, "backup/nobackup", nil); err == nil {
+		t.Fatal("expected error")
+	}
+
+	mustRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"allow_plaintext_backup": true,
+	})
+
+	// Encrypt with every version, archiving the first ones
+	var ciphertexts []string
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			mustRequest(logical.UpdateOperation, "keys/foo/rotate", nil)
+		}
+		resp := mustRequest(logical.UpdateOperation, "encrypt/foo", map[string]interface{}{
+			"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+		})
+		ciphertexts = append(ciphertexts, resp.Data["ciphertext"].(string))
+	}
+	mustRequest(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{
+		"min_decryption_version": 3,
+		"deletion_allowed":       true,
+	})
+
+	resp := mustRequest(logical.ReadOperation, "backup/foo", nil)
+	backup := resp.Data["backup"].(string)
+	if backup == "" {
+		t.Fatal("empty backup")
+	}
+	// The backup is versioned
+	backupBytes, err := base64.StdEncoding.DecodeString(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(backupBytes, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if envelope["version"] != float64(1) || envelope["policy"] == nil || envelope["archived_keys"] == nil {
+		t.Fatalf("bad: %#v", envelope)
+	}
+
+	// Backups of another format version are refused
+	envelope["version"] = 2
+	unknownVersion, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := request(logical.UpdateOperation, "restore/baz", map[string]interface{}{
+		"backup": base64.StdEncoding.EncodeToString(unknownVersion),
+	}); err == nil && !resp.IsError() {
+		t.Fatal("expected error")
+	}
+
+	// Backups can be restored into a mount with separate storage, such as
+	// one on another cluster
+	b2, s2 := createBackendWithStorage(t)
+	restored := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b2.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s2,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err:%v resp:%#v", op, path, err, resp)
+		}
+		return resp
+	}
+	restored(logical.UpdateOperation, "restore", map[string]interface{}{
+		"backup": backup,
+	})
+	resp = restored(logical.ReadOperation, "keys/foo", nil)
+	if resp.Data["latest_version"] != 3 || resp.Data["restore_info"] == nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = restored(logical.UpdateOperation, "decrypt/foo", map[string]interface{}{
+		"ciphertext": ciphertexts[2],
+	})
+	if resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = mustRequest(logical.ReadOperation, "keys/foo", nil)
+	if resp.Data["backup_info"] == nil {
+		t.Fatalf("expected backup info, got %#v", resp.Data)
+	}
+
+	// The key cannot be overwritten without force
+	if resp, err := request(logical.UpdateOperation, "restore", map[string]interface{}{
+		"backup": backup,
+	}); err == nil && !resp.IsError() {
+		t.Fatal("expected error")
+	}
+
+	mustRequest(logical.DeleteOperation, "keys/foo", nil)
+	mustRequest(logical.UpdateOperation, "restore", map[string]interface{}{
+		"backup": backup,
+	})
+	mustRequest(logical.UpdateOperation, "restore/bar", map[string]interface{}{
+		"backup": backup,
+	})
+	mustRequest(logical.UpdateOperation, "restore/bar", map[string]interface{}{
+		"backup": backup,
+		"force":  true,
+	})
+
+	for _, name := range []string{"foo", "bar"} {
+		resp := mustRequest(logical.ReadOperation, "keys/"+name, nil)
+		if resp.Data["latest_version"] != 3 || resp.Data["restore_info"] == nil {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+
+		// Archived versions come back once the min decryption version is
+		// lowered
+		mustRequest(logical.UpdateOperation, "keys/"+name+"/config", map[string]interface{}{
+			"min_decryption_version": 1,
+		})
+		for _, ciphertext := range ciphertexts {
+			resp := mustRequest(logical.UpdateOperation, "decrypt/"+name, map[string]interface{}{
+				"ciphertext": ciphertext,
+			})
+			if resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+				t.Fatalf("bad: %#v", resp.Data)
+			}
+		}
+	}
+
+	// Plaintext backups cannot be disabled again
+	if resp, err := request(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{
+		"allow_plaintext_backup": false,
+	}); err == nil && !resp.IsError() {
+		t.Fatal("expected error")
+	}
+
+	if resp, err := request(logical.UpdateOperation, "restore/baz", map[string]interface{}{
+		"backup": "foobar",
+	}); err == nil && !resp.IsError() {
+		t.Fatal("expected error")
+	}
+}
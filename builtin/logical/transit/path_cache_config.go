@@ -0,0 +1,121 @@
+package transit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// cacheConfigPath is the storage path of the configuration of the key cache
+const cacheConfigPath = "config/cache"
+
+// cacheConfig is the stored configuration of the key cache
+type cacheConfig struct {
+	Size int `json:"size"`
+}
+
+func (b *backend) pathCacheConfig() *framework.Path {
+	return &framework.Path{
+		Pattern: "config/cache",
+		Fields: map[string]*framework.FieldSchema{
+			"size": &framework.FieldSchema{
+				Type:    framework.TypeInt,
+				Default: 0,
+				Description: fmt.Sprintf(`Maximum number of keys to keep in
+the cache, evicting the least recently used
+ones. If set to 0, the cache is unbounded.
+Otherwise it must be at least %d.`, keysutil.MinCacheSize),
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathCacheConfigRead,
+			logical.UpdateOperation: b.pathCacheConfigWrite,
+		},
+
+		HelpSynopsis:    pathCacheConfigHelpSyn,
+		HelpDescription: pathCacheConfigHelpDesc,
+	}
+}
+
+// getCacheConfig returns the stored cache configuration, or nil if there is
+// none
+func getCacheConfig(s logical.Storage) (*cacheConfig, error) {
+	entry, err := s.Get(cacheConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result cacheConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// loadCacheConfig sizes the cache of the lock manager as configured
+func (b *backend) loadCacheConfig(s logical.Storage) error {
+	config, err := getCacheConfig(s)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return nil
+	}
+	return b.lm.SetCacheSize(config.Size)
+}
+
+func (b *backend) pathCacheConfigRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"size": b.lm.CacheSize(),
+		},
+	}, nil
+}
+
+func (b *backend) pathCacheConfigWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	size := d.Get("size").(int)
+	if size < 0 || (size > 0 && size < keysutil.MinCacheSize) {
+		return logical.ErrorResponse(fmt.Sprintf("size must be 0 or at least %d", keysutil.MinCacheSize)), logical.ErrInvalidRequest
+	}
+
+	entry, err := logical.StorageEntryJSON(cacheConfigPath, &cacheConfig{
+		Size: size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	if err := b.lm.SetCacheSize(size); err != nil {
+		return nil, err
+	}
+
+	if !b.lm.CacheActive() {
+		resp := &logical.Response{}
+		resp.AddWarning("caching is disabled for this Vault server; the size will only apply once it is enabled")
+		return resp, nil
+	}
+
+	return nil, nil
+}
+
+const pathCacheConfigHelpSyn = `Configure the cache of keys`
+
+const pathCacheConfigHelpDesc = `
+This path is used to configure the size of the in-memory cache of keys. By
+default every key is kept in the cache once it has been loaded; with a size,
+the least recently used keys are evicted once the cache is full and loaded
+back from storage when next used.
+
+Changing the size empties the cache, on every node of the cluster.
+`
@@ -0,0 +1,74 @@
+package transit
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_CacheConfig(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	conf := &logical.BackendConfig{
+		StorageView: storage,
+		System:      logical.TestSystemView(),
+	}
+	b := Backend(conf)
+
+	doReq := func(req *logical.Request) *logical.Response {
+		resp, err := b.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("got err:\n%#v\nresp:\n%#v\nreq:\n%#v\n", err, resp, *req)
+		}
+		return resp
+	}
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "config/cache",
+	}
+	resp := doReq(req)
+	if resp.Data["size"].(int) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Sizes below the minimum are rejected
+	req.Operation = logical.UpdateOperation
+	req.Data = map[string]interface{}{
+		"size": 5,
+	}
+	resp, err := b.HandleRequest(req)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+
+	req.Data["size"] = 50
+	doReq(req)
+
+	req.Operation = logical.ReadOperation
+	req.Data = nil
+	resp = doReq(req)
+	if resp.Data["size"].(int) != 50 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// A new backend on the same storage picks up the size
+	f, err := Factory(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := f.(*backend).lm.CacheSize(); size != 50 {
+		t.Fatalf("bad cache size: %d", size)
+	}
+
+	// Other backends on the same storage pick up changes when invalidated
+	req.Operation = logical.UpdateOperation
+	req.Data = map[string]interface{}{
+		"size": 20,
+	}
+	doReq(req)
+	f.(*backend).invalidate(cacheConfigPath)
+	if size := f.(*backend).lm.CacheSize(); size != 20 {
+		t.Fatalf("bad cache size: %d", size)
+	}
+}
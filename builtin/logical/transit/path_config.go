@@ -2,6 +2,7 @@ package transit
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -35,6 +36,28 @@ the latest version of the key is allowed.`,
 				Type:        framework.TypeBool,
 				Description: "Whether to allow deletion of the key",
 			},
+
+			"allow_import": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to allow importing key versions
+into a key generated by Vault`,
+			},
+
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key, which holds the key material in the
+clear. Once set,
+this cannot be disabled, since existing
+backups cannot be revoked.`,
+			},
+
+			"auto_rotate_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Amount of time the key should live before
+being automatically rotated. A value of 0
+disables automatic rotation for the key.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -86,6 +109,10 @@ func (b *backend) pathConfigWrite(
 				return logical.ErrorResponse(
 					fmt.Sprintf("cannot set min decryption version of %d, latest key version is %d", minDecryptionVersion, p.LatestVersion)), nil
 			}
+			if minDecryptionVersion < p.MinAvailableVersion {
+				return logical.ErrorResponse(
+					fmt.Sprintf("cannot set min decryption version of %d, key versions before %d have been trimmed", minDecryptionVersion, p.MinAvailableVersion)), nil
+			}
 			p.MinDecryptionVersion = minDecryptionVersion
 			persistNeeded = true
 		}
@@ -104,6 +131,10 @@ func (b *backend) pathConfigWrite(
 				return logical.ErrorResponse(
 					fmt.Sprintf("cannot set min encryption version of %d, latest key version is %d", minEncryptionVersion, p.LatestVersion)), nil
 			}
+			if minEncryptionVersion > 0 && minEncryptionVersion < p.MinAvailableVersion {
+				return logical.ErrorResponse(
+					fmt.Sprintf("cannot set min encryption version of %d, key versions before %d have been trimmed", minEncryptionVersion, p.MinAvailableVersion)), nil
+			}
 			p.MinEncryptionVersion = minEncryptionVersion
 			persistNeeded = true
 		}
@@ -126,6 +157,44 @@ func (b *backend) pathConfigWrite(
 		}
 	}
 
+	allowImportRaw, ok := d.GetOk("allow_import")
+	if ok {
+		allowImport := allowImportRaw.(bool)
+		if allowImport != p.AllowImport {
+			p.AllowImport = allowImport
+			persistNeeded = true
+		}
+	}
+
+	allowPlaintextBackupRaw, ok := d.GetOk("allow_plaintext_backup")
+	if ok {
+		allowPlaintextBackup := allowPlaintextBackupRaw.(bool)
+		if p.AllowPlaintextBackup && !allowPlaintextBackup {
+			return logical.ErrorResponse("allow_plaintext_backup cannot be disabled once it is enabled"), logical.ErrInvalidRequest
+		}
+		if allowPlaintextBackup != p.AllowPlaintextBackup {
+			p.AllowPlaintextBackup = allowPlaintextBackup
+			persistNeeded = true
+		}
+	}
+
+	autoRotatePeriodRaw, ok := d.GetOk("auto_rotate_period")
+	if ok {
+		autoRotatePeriod := time.Duration(autoRotatePeriodRaw.(int)) * time.Second
+		switch {
+		case autoRotatePeriod < 0:
+			return logical.ErrorResponse("auto rotate period cannot be negative"), logical.ErrInvalidRequest
+		case autoRotatePeriod != 0 && autoRotatePeriod < minAutoRotatePeriod:
+			return logical.ErrorResponse(fmt.Sprintf("auto rotate period must be at least %s", minAutoRotatePeriod)), logical.ErrInvalidRequest
+		case autoRotatePeriod != 0 && p.Imported:
+			return logical.ErrorResponse("imported keys cannot be automatically rotated"), logical.ErrInvalidRequest
+		}
+		if autoRotatePeriod != p.AutoRotatePeriod {
+			p.AutoRotatePeriod = autoRotatePeriod
+			persistNeeded = true
+		}
+	}
+
 	// Add this as a guard here before persisting since we now require the min
 	// decryption version to start at 1; even if it's not explicitly set here,
 	// force the upgrade
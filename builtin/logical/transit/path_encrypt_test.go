@@ -545,3 +545,103 @@ func TestTransit_BatchEncryptionCase12(t *testing.T) {
 		t.Fatalf("expected an error")
 	}
 }
+
+func TestTransit_EncryptDecrypt_KeyTypes(t *testing.T) {
+	for _, keyType := range []string{"chacha20-poly1305", "rsa-2048", "rsa-4096"} {
+		b, s := createBackendWithStorage(t)
+
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "keys/foo",
+			Storage:   s,
+			Data: map[string]interface{}{
+				"type": keyType,
+			},
+		}
+		resp, err := b.HandleRequest(req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+
+		encrypt := func() string {
+			resp, err := b.HandleRequest(&logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt/foo",
+				Storage:   s,
+				Data: map[string]interface{}{
+					"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("%s: err:%v resp:%#v", keyType, err, resp)
+			}
+			return resp.Data["ciphertext"].(string)
+		}
+		decrypt := func(ciphertext string) (*logical.Response, error) {
+			return b.HandleRequest(&logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "decrypt/foo",
+				Storage:   s,
+				Data: map[string]interface{}{
+					"ciphertext": ciphertext,
+				},
+			})
+		}
+
+		v1ciphertext := encrypt()
+		req.Path = "keys/foo/rotate"
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		v2ciphertext := encrypt()
+
+		for _, ciphertext := range []string{v1ciphertext, v2ciphertext} {
+			resp, err := decrypt(ciphertext)
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("%s: err:%v resp:%#v", keyType, err, resp)
+			}
+			if resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+				t.Fatalf("%s: bad plaintext %#v", keyType, resp.Data)
+			}
+		}
+
+		// Tampered ciphertext does not decrypt
+		tampered := v2ciphertext[:len(v2ciphertext)-4] + "AAA="
+		if resp, err := decrypt(tampered); err == nil && !resp.IsError() {
+			t.Fatalf("%s: expected error decrypting tampered ciphertext", keyType)
+		}
+
+		// Archived versions cannot be used below the min decryption version
+		req.Path = "keys/foo/config"
+		req.Data = map[string]interface{}{
+			"min_decryption_version": 2,
+		}
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatal(err)
+		}
+		if resp, err := decrypt(v1ciphertext); err == nil && !resp.IsError() {
+			t.Fatalf("%s: expected error decrypting v1 ciphertext", keyType)
+		}
+	}
+
+	// Only symmetric keys can be created by an encryption upsert
+	b, s := createBackendWithStorage(t)
+	for keyType, ok := range map[string]bool{"chacha20-poly1305": true, "rsa-2048": false} {
+		req := &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "encrypt/" + keyType,
+			Storage:   s,
+			Data: map[string]interface{}{
+				"type":      keyType,
+				"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+			},
+		}
+		resp, err := b.HandleRequest(req)
+		if ok && (err != nil || (resp != nil && resp.IsError())) {
+			t.Fatalf("%s: err:%v resp:%#v", keyType, err, resp)
+		}
+		if !ok && err == nil {
+			t.Fatalf("%s: expected error", keyType)
+		}
+	}
+}
@@ -16,6 +16,11 @@ func TestTransit_Export_KeyVersion_ExportsCorrectVersion(t *testing.T) {
 	verifyExportsCorrectVersion(t, "hmac-key", "aes256-gcm96")
 	verifyExportsCorrectVersion(t, "hmac-key", "ecdsa-p256")
 	verifyExportsCorrectVersion(t, "hmac-key", "ed25519")
+	verifyExportsCorrectVersion(t, "encryption-key", "chacha20-poly1305")
+	verifyExportsCorrectVersion(t, "encryption-key", "rsa-2048")
+	verifyExportsCorrectVersion(t, "signing-key", "rsa-2048")
+	verifyExportsCorrectVersion(t, "signing-key", "ecdsa-p384")
+	verifyExportsCorrectVersion(t, "signing-key", "ecdsa-p521")
 }
 
 func verifyExportsCorrectVersion(t *testing.T, exportType, keyType string) {
@@ -10,8 +10,11 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
 func (b *backend) pathHMAC() *framework.Path {
@@ -63,19 +66,56 @@ to the min_encryption_version configured on the key.`,
 	}
 }
 
+// batchResponseHMACItem represents a response item for batch HMAC
+// generation
+type batchResponseHMACItem struct {
+	// HMAC for the input present in the corresponding batch request item
+	HMAC string `json:"hmac,omitempty" structs:"hmac" mapstructure:"hmac"`
+
+	// Error, if set represents a failure encountered while generating the
+	// HMAC of a corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// hmacHashFuncs maps the supported HMAC algorithms to their hash functions
+var hmacHashFuncs = map[string]func() hash.Hash{
+	"sha2-224": sha256.New224,
+	"sha2-256": sha256.New,
+	"sha2-384": sha512.New384,
+	"sha2-512": sha512.New,
+}
+
 func (b *backend) pathHMACWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	hashFunc, ok := hmacHashFuncs[algorithm]
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	}
+
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = []batchRequestSignItem{
+			{
+				Input: d.Get("input").(string),
+			},
+		}
 	}
 
 	// Get the policy
@@ -109,122 +149,94 @@ func (b *backend) pathHMACWrite(
 		return nil, fmt.Errorf("HMAC key value could not be computed")
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
-	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
-	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
-	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
-	}
-	hf.Write(input)
-	retBytes := hf.Sum(nil)
+	// Process batch request items. If the input of any request item
+	// cannot be decoded, mark the error in the response collection and
+	// continue to process other items.
+	batchResponseItems := make([]batchResponseHMACItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, err := base64.StdEncoding.DecodeString(item.Input)
+		if err != nil {
+			batchResponseItems[i].Error = fmt.Sprintf("unable to decode input as base64: %s", err)
+			continue
+		}
 
-	retStr := base64.StdEncoding.EncodeToString(retBytes)
-	retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
+		hf := hmac.New(hashFunc, key)
+		hf.Write(input)
+		retStr := base64.StdEncoding.EncodeToString(hf.Sum(nil))
+		batchResponseItems[i].HMAC = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
+	}
 
 	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"hmac": retStr,
-		},
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
 	}
-	return resp, nil
-}
-
-func (b *backend) pathHMACVerify(
-	req *logical.Request, d *framework.FieldData, verificationHMAC string) (*logical.Response, error) {
 
-	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
-	algorithm := d.Get("urlalgorithm").(string)
-	if algorithm == "" {
-		algorithm = d.Get("algorithm").(string)
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
 	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"hmac": batchResponseItems[0].HMAC,
+		},
+	}, nil
+}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+// verifyHMAC checks the given HMAC of the input, computed with the named
+// algorithm and the key version embedded in the HMAC
+func verifyHMAC(p *keysutil.Policy, algorithm string, input []byte, verificationHMAC string) (bool, error) {
+	hashFunc, ok := hmacHashFuncs[algorithm]
+	if !ok {
+		return false, errutil.UserError{Err: fmt.Sprintf("unsupported algorithm %s", algorithm)}
 	}
 
 	// Verify the prefix
 	if !strings.HasPrefix(verificationHMAC, "vault:v") {
-		return logical.ErrorResponse("invalid HMAC to verify: no prefix"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC to verify: no prefix"}
 	}
 
 	splitVerificationHMAC := strings.SplitN(strings.TrimPrefix(verificationHMAC, "vault:v"), ":", 2)
 	if len(splitVerificationHMAC) != 2 {
-		return logical.ErrorResponse("invalid HMAC: wrong number of fields"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: wrong number of fields"}
 	}
 
 	ver, err := strconv.Atoi(splitVerificationHMAC[0])
 	if err != nil {
-		return logical.ErrorResponse("invalid HMAC: version number could not be decoded"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: version number could not be decoded"}
 	}
 
 	verBytes, err := base64.StdEncoding.DecodeString(splitVerificationHMAC[1])
 	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)), logical.ErrInvalidRequest
-	}
-
-	// Get the policy
-	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
-	if lock != nil {
-		defer lock.RUnlock()
-	}
-	if err != nil {
-		return nil, err
-	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)}
 	}
 
 	if ver > p.LatestVersion {
-		return logical.ErrorResponse("invalid HMAC: version is too new"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: version is too new"}
 	}
 
 	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
-		return logical.ErrorResponse("cannot verify HMAC: version is too old (disallowed by policy)"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "cannot verify HMAC: version is too old (disallowed by policy)"}
 	}
 
 	key, err := p.HMACKey(ver)
 	if err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: err.Error()}
 	}
 	if key == nil {
-		return nil, fmt.Errorf("HMAC key value could not be computed")
+		return false, fmt.Errorf("HMAC key value could not be computed")
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
-	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
-	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
-	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
-	}
+	hf := hmac.New(hashFunc, key)
 	hf.Write(input)
-	retBytes := hf.Sum(nil)
-
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"valid": hmac.Equal(retBytes, verBytes),
-		},
-	}, nil
+	return hmac.Equal(hf.Sum(nil), verBytes), nil
 }
 
 const pathHMACHelpSyn = `Generate an HMAC for input data using the named key`
 
 const pathHMACHelpDesc = `
-Generates an HMAC sum of the given algorithm and key against the given input data.
+Generates an HMAC sum of the given algorithm and key against the given input
+data, or against each item of the given batch input.
 `
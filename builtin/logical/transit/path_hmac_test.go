@@ -181,3 +181,80 @@ func TestTransit_HMAC(t *testing.T) {
 		t.Fatalf("expected invalid request error, got %v", err)
 	}
 }
+
+func TestTransit_HMAC_Batch(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Storage:   s,
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	req.Path = "hmac/foo/sha2-512"
+	req.Data = map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+			map[string]interface{}{"input": "not base64"},
+		},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	hmacResults := resp.Data["batch_results"].([]batchResponseHMACItem)
+	if len(hmacResults) != 2 {
+		t.Fatalf("bad: %#v", hmacResults)
+	}
+	if hmacResults[0].Error != "" || !strings.HasPrefix(hmacResults[0].HMAC, "vault:v1:") {
+		t.Fatalf("bad: %#v", hmacResults[0])
+	}
+	if hmacResults[1].Error == "" || hmacResults[1].HMAC != "" {
+		t.Fatalf("expected an error, got %#v", hmacResults[1])
+	}
+
+	// The batch result matches the single input result
+	req.Data = map[string]interface{}{
+		"input": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["hmac"] != hmacResults[0].HMAC {
+		t.Fatalf("expected %q, got %#v", hmacResults[0].HMAC, resp.Data)
+	}
+
+	req.Path = "verify/foo/sha2-512"
+	req.Data = map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": hmacResults[0].HMAC},
+			map[string]interface{}{"input": "anVtcGVkIG92ZXIgdGhlIGxhenkgZG9n", "hmac": hmacResults[0].HMAC},
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": "vault:v2:" + strings.TrimPrefix(hmacResults[0].HMAC, "vault:v1:")},
+		},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	verifyResults := resp.Data["batch_results"].([]batchResponseVerifyItem)
+	if len(verifyResults) != 3 {
+		t.Fatalf("bad: %#v", verifyResults)
+	}
+	if !verifyResults[0].Valid || verifyResults[0].Error != "" {
+		t.Fatalf("bad: %#v", verifyResults[0])
+	}
+	if verifyResults[1].Valid || verifyResults[1].Error != "" {
+		t.Fatalf("bad: %#v", verifyResults[1])
+	}
+	if verifyResults[2].Valid || verifyResults[2].Error == "" {
+		t.Fatalf("expected an error, got %#v", verifyResults[2])
+	}
+}
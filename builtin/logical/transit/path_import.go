@@ -0,0 +1,262 @@
+package transit
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathImport() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "aes256-gcm96",
+				Description: `The type of key being imported. Currently,
+"aes256-gcm96" (symmetric), "chacha20-poly1305" (symmetric),
+"ecdsa-p256", "ecdsa-p384", "ecdsa-p521" (asymmetric), "rsa-2048"
+(asymmetric) and "rsa-4096" (asymmetric) are supported. Defaults
+to "aes256-gcm96".`,
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: importCiphertextDescription,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "SHA256",
+				Description: importHashFunctionDescription,
+			},
+
+			"derived": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables key derivation mode. This
+allows for per-transaction unique
+keys for encryption operations.`,
+			},
+
+			"exportable": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables keys to be exportable.
+This allows for all the valid keys
+in the key ring to be exported.`,
+			},
+
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: importCiphertextDescription,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "SHA256",
+				Description: importHashFunctionDescription,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathImportWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	keyType := d.Get("type").(string)
+
+	polReq := keysutil.PolicyRequest{
+		Storage:              req.Storage,
+		Name:                 name,
+		Derived:              d.Get("derived").(bool),
+		Exportable:           d.Get("exportable").(bool),
+		AllowPlaintextBackup: d.Get("allow_plaintext_backup").(bool),
+	}
+	kt, ok := keysutil.KeyTypeFromString(keyType)
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+	polReq.KeyType = kt
+
+	key, err := b.decryptImportedKey(req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := b.lm.ImportPolicy(polReq, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyExclusive(req.Storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+
+	// Keys generated by Vault only take imported versions if allowed to
+	if !p.Imported && !p.AllowImport {
+		return logical.ErrorResponse(fmt.Sprintf("key %q was generated by Vault and does not allow importing versions", name)), logical.ErrInvalidRequest
+	}
+
+	key, err := b.decryptImportedKey(req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := p.Import(req.Storage, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+// decryptImportedKey unwraps the key material of an import request. The
+// ciphertext is made of an ephemeral AES-256 key encrypted with RSA-OAEP
+// under the wrapping key, followed by the key material wrapped with the
+// ephemeral key using AES Key Wrap with Padding (RFC 5649).
+func (b *backend) decryptImportedKey(storage logical.Storage, d *framework.FieldData) ([]byte, error) {
+	ciphertextB64 := d.Get("ciphertext").(string)
+	if ciphertextB64 == "" {
+		return nil, errutil.UserError{Err: "missing ciphertext"}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode ciphertext"}
+	}
+
+	var hashFunc hash.Hash
+	switch d.Get("hash_function").(string) {
+	case "SHA1":
+		hashFunc = sha1.New()
+	case "SHA224":
+		hashFunc = sha256.New224()
+	case "SHA256":
+		hashFunc = sha256.New()
+	case "SHA384":
+		hashFunc = sha512.New384()
+	case "SHA512":
+		hashFunc = sha512.New()
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", d.Get("hash_function").(string))}
+	}
+
+	wrappingKey, err := b.getWrappingKey(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralKeyLen := wrappingKey.Size()
+	if len(ciphertext) <= ephemeralKeyLen {
+		return nil, errutil.UserError{Err: "ciphertext is too short"}
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFunc, rand.Reader, wrappingKey, ciphertext[:ephemeralKeyLen], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to decrypt the ephemeral key"}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: "ephemeral key must be an AES-256 key"}
+	}
+
+	key, err := kwpUnwrap(ephemeralKey, ciphertext[ephemeralKeyLen:])
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to unwrap the key material: %v", err)}
+	}
+
+	return key, nil
+}
+
+func importErrorResponse(err error) (*logical.Response, error) {
+	if _, ok := err.(errutil.UserError); ok {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, err
+}
+
+const importCiphertextDescription = `The base64-encoded ciphertext of the key
+material. It is made of an ephemeral AES-256 key
+encrypted with RSA-OAEP under the wrapping key,
+followed by the key material wrapped with the
+ephemeral key using AES Key Wrap with Padding
+(RFC 5649). Symmetric keys are given as raw bytes,
+asymmetric keys as PKCS#8 DER-encoded private keys.`
+
+const importHashFunctionDescription = `The hash function used for the RSA-OAEP
+encryption of the ephemeral key. One of "SHA1",
+"SHA224", "SHA256", "SHA384" or "SHA512".
+Defaults to "SHA256".`
+
+const pathImportHelpSyn = `Imports an externally-generated key into a new transit key`
+
+const pathImportHelpDesc = `
+This path is used to create a new key from externally-generated key
+material. The key material must be wrapped for the wrapping key
+returned by the wrapping_key endpoint.
+`
+
+const pathImportVersionHelpSyn = `Imports an externally-generated key into an existing transit key`
+
+const pathImportVersionHelpDesc = `
+This path is used to add a new version to an existing key from
+externally-generated key material. Keys generated by Vault only
+accept imported versions when their allow_import setting is enabled.
+`
@@ -0,0 +1,188 @@
+package transit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// testWrapKey wraps the key material for import the way clients do
+func testWrapKey(t *testing.T, b *backend, s logical.Storage, key []byte) string {
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+		Storage:   s,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatal("failed to decode wrapping key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ephemeralKey := make([]byte, 32)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		t.Fatal(err)
+	}
+	wrappedEphemeralKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub.(*rsa.PublicKey), ephemeralKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappedKey, err := kwpWrap(ephemeralKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(wrappedEphemeralKey, wrappedKey...))
+}
+
+func TestTransit_Import(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: err:%v resp:%#v", op, path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(op logical.Operation, path string, data map[string]interface{}) {
+		resp, err := request(op, path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s %s: expected error, got %#v", op, path, resp)
+		}
+	}
+
+	// Import a symmetric key and check that it is the one being used
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+		"exportable": true,
+	})
+	resp := mustRequest(logical.ReadOperation, "export/encryption-key/aes/1", nil)
+	if resp.Data["keys"].(map[string]string)["1"] != base64.StdEncoding.EncodeToString(aesKey) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = mustRequest(logical.ReadOperation, "keys/aes", nil)
+	if resp.Data["imported"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Existing keys cannot be imported over
+	expectError(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	})
+
+	// Imported keys take new imported versions
+	resp = mustRequest(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{
+		"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+	})
+	v1ciphertext := resp.Data["ciphertext"].(string)
+	aesKey2 := make([]byte, 32)
+	if _, err := rand.Read(aesKey2); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/aes/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey2),
+	})
+	resp = mustRequest(logical.ReadOperation, "export/encryption-key/aes/latest", nil)
+	if resp.Data["keys"].(map[string]string)["2"] != base64.StdEncoding.EncodeToString(aesKey2) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	resp = mustRequest(logical.UpdateOperation, "decrypt/aes", map[string]interface{}{
+		"ciphertext": v1ciphertext,
+	})
+	if resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Symmetric key material must be of the right size
+	expectError(logical.UpdateOperation, "keys/aes/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey[:16]),
+	})
+
+	// Keys generated by Vault only take imported versions once allowed
+	mustRequest(logical.UpdateOperation, "keys/generated", nil)
+	expectError(logical.UpdateOperation, "keys/generated/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	})
+	mustRequest(logical.UpdateOperation, "keys/generated/config", map[string]interface{}{
+		"allow_import": true,
+	})
+	mustRequest(logical.UpdateOperation, "keys/generated/import_version", map[string]interface{}{
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	})
+
+	// Import an ECDSA key and verify its signatures locally
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectError(logical.UpdateOperation, "keys/ec/import", map[string]interface{}{
+		"type":       "ecdsa-p256",
+		"ciphertext": testWrapKey(t, b, s, der),
+	})
+	mustRequest(logical.UpdateOperation, "keys/ec/import", map[string]interface{}{
+		"type":       "ecdsa-p384",
+		"ciphertext": testWrapKey(t, b, s, der),
+	})
+	resp = mustRequest(logical.UpdateOperation, "sign/ec/sha2-256", map[string]interface{}{
+		"input": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+	})
+	sigBytes, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(sigBytes, &sig); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("the quick brown fox"))
+	if !ecdsa.Verify(&ecKey.PublicKey, digest[:], sig.R, sig.S) {
+		t.Fatal("signature of the imported key did not verify")
+	}
+
+	// Tampered ciphertexts are rejected
+	ciphertext, _ := base64.StdEncoding.DecodeString(testWrapKey(t, b, s, aesKey))
+	ciphertext[len(ciphertext)-1] ^= 1
+	expectError(logical.UpdateOperation, "keys/tampered/import", map[string]interface{}{
+		"ciphertext": base64.StdEncoding.EncodeToString(ciphertext),
+	})
+	expectError(logical.UpdateOperation, "keys/tampered/import", map[string]interface{}{
+		"type":       "ed25519",
+		"ciphertext": testWrapKey(t, b, s, aesKey),
+	})
+}
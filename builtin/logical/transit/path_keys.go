@@ -1,7 +1,6 @@
 package transit
 
 import (
-	"crypto/elliptic"
 	"encoding/base64"
 	"fmt"
 	"strconv"
@@ -41,8 +40,10 @@ func (b *backend) pathKeys() *framework.Path {
 				Type:    framework.TypeString,
 				Default: "aes256-gcm96",
 				Description: `The type of key to create. Currently,
-"aes256-gcm96" (symmetric) and "ecdsa-p256" (asymmetric), and
-'ed25519' (asymmetric) are supported. Defaults to "aes256-gcm96".`,
+"aes256-gcm96" (symmetric), "chacha20-poly1305" (symmetric),
+"ecdsa-p256", "ecdsa-p384", "ecdsa-p521" (asymmetric),
+"ed25519" (asymmetric), "rsa-2048" (asymmetric) and "rsa-4096"
+(asymmetric) are supported. Defaults to "aes256-gcm96".`,
 			},
 
 			"derived": &framework.FieldSchema{
@@ -75,6 +76,13 @@ This allows for all the valid keys
 in the key ring to be exported.`,
 			},
 
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+			},
+
 			"context": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `Base64 encoded context for key derivation.
@@ -112,28 +120,25 @@ func (b *backend) pathPolicyWrite(
 	convergent := d.Get("convergent_encryption").(bool)
 	keyType := d.Get("type").(string)
 	exportable := d.Get("exportable").(bool)
+	allowPlaintextBackup := d.Get("allow_plaintext_backup").(bool)
 
 	if !derived && convergent {
 		return logical.ErrorResponse("convergent encryption requires derivation to be enabled"), nil
 	}
 
 	polReq := keysutil.PolicyRequest{
-		Storage:    req.Storage,
-		Name:       name,
-		Derived:    derived,
-		Convergent: convergent,
-		Exportable: exportable,
+		Storage:              req.Storage,
+		Name:                 name,
+		Derived:              derived,
+		Convergent:           convergent,
+		Exportable:           exportable,
+		AllowPlaintextBackup: allowPlaintextBackup,
 	}
-	switch keyType {
-	case "aes256-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES256_GCM96
-	case "ecdsa-p256":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P256
-	case "ed25519":
-		polReq.KeyType = keysutil.KeyType_ED25519
-	default:
+	kt, ok := keysutil.KeyTypeFromString(keyType)
+	if !ok {
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 	}
+	polReq.KeyType = kt
 
 	p, lock, upserted, err := b.lm.GetPolicyUpsert(polReq)
 	if lock != nil {
@@ -185,15 +190,34 @@ func (b *backend) pathPolicyRead(
 			"deletion_allowed":       p.DeletionAllowed,
 			"min_decryption_version": p.MinDecryptionVersion,
 			"min_encryption_version": p.MinEncryptionVersion,
+			"min_available_version":  p.MinAvailableVersion,
 			"latest_version":         p.LatestVersion,
 			"exportable":             p.Exportable,
 			"supports_encryption":    p.Type.EncryptionSupported(),
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"allow_plaintext_backup": p.AllowPlaintextBackup,
+			"imported":               p.Imported,
+			"allow_import":           p.AllowImport,
+			"auto_rotate_period":     int64(p.AutoRotatePeriod.Seconds()),
+			"last_rotation_time":     p.LastRotationTime(),
 		},
 	}
 
+	if p.BackupInfo != nil {
+		resp.Data["backup_info"] = map[string]interface{}{
+			"time":    p.BackupInfo.Time,
+			"version": p.BackupInfo.Version,
+		}
+	}
+	if p.RestoreInfo != nil {
+		resp.Data["restore_info"] = map[string]interface{}{
+			"time":    p.RestoreInfo.Time,
+			"version": p.RestoreInfo.Version,
+		}
+	}
+
 	if p.Derived {
 		switch p.KDF {
 		case keysutil.Kdf_hmac_sha256_counter:
@@ -218,14 +242,15 @@ func (b *backend) pathPolicyRead(
 	}
 
 	switch p.Type {
-	case keysutil.KeyType_AES256_GCM96:
+	case keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[strconv.Itoa(k)] = v.DeprecatedCreationTime
 		}
 		resp.Data["keys"] = retKeys
 
-	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ED25519:
+	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521,
+		keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
 		retKeys := map[string]map[string]interface{}{}
 		for k, v := range p.Keys {
 			key := asymKey{
@@ -237,8 +262,10 @@ func (b *backend) pathPolicyRead(
 			}
 
 			switch p.Type {
-			case keysutil.KeyType_ECDSA_P256:
-				key.Name = elliptic.P256().Params().Name
+			case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521:
+				key.Name = p.Type.Curve().Params().Name
+			case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+				key.Name = p.Type.String()
 			case keysutil.KeyType_ED25519:
 				if p.Derived {
 					if len(context) == 0 {
@@ -0,0 +1,62 @@
+package transit
+
+import (
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathRestore() *framework.Path {
+	return &framework.Path{
+		Pattern: "restore" + framework.OptionalParamRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"backup": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Backup of the key, as returned by the backup endpoint",
+			},
+
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "If set, the name of the restored key. Defaults to the name in the backup.",
+			},
+
+			"force": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, an existing key with the same name is overwritten",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRestoreUpdate,
+		},
+
+		HelpSynopsis:    pathRestoreHelpSyn,
+		HelpDescription: pathRestoreHelpDesc,
+	}
+}
+
+func (b *backend) pathRestoreUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	backup := d.Get("backup").(string)
+	if backup == "" {
+		return logical.ErrorResponse("missing backup"), logical.ErrInvalidRequest
+	}
+
+	err := b.lm.RestorePolicy(req.Storage, d.Get("name").(string), backup, d.Get("force").(bool))
+	if err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathRestoreHelpSyn = `Restore the named key`
+
+const pathRestoreHelpDesc = `
+This path is used to restore a key from a backup taken by the
+backup endpoint of this or any other mount, under the name given in the path
+or under its original name. An existing key is only overwritten if "force" is set.
+`
@@ -1,10 +1,18 @@
 package transit
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
+// minAutoRotatePeriod is the shortest period allowed for automatic key
+// rotation
+const minAutoRotatePeriod = time.Hour
+
 func (b *backend) pathRotate() *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/" + framework.GenericNameRegex("name") + "/rotate",
@@ -46,6 +54,53 @@ func (b *backend) pathRotateWrite(
 	return nil, err
 }
 
+// autoRotateKeys rotates every key whose automatic rotation period has
+// elapsed. A failure to rotate one key does not prevent the others from
+// being rotated.
+func (b *backend) autoRotateKeys(storage logical.Storage) error {
+	names, err := storage.List("policy/")
+	if err != nil {
+		return err
+	}
+
+	var mErr *multierror.Error
+	for _, name := range names {
+		if err := b.autoRotateKey(storage, name); err != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("failed to rotate key %s: %v", name, err))
+		}
+	}
+	return mErr.ErrorOrNil()
+}
+
+// autoRotateKey rotates the named key if its automatic rotation period has
+// elapsed
+func (b *backend) autoRotateKey(storage logical.Storage, name string) error {
+	// Check without loading the key into the cache first so that keys which
+	// are not due neither block other requests nor evict cached keys
+	required, err := b.lm.PolicyNeedsAutoRotation(storage, name, time.Now())
+	if err != nil || !required {
+		return err
+	}
+
+	p, lock, err := b.lm.GetPolicyExclusive(storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	// The key may have been rotated or deleted while it was unlocked
+	if p == nil || !p.NeedsAutoRotation(time.Now()) {
+		return nil
+	}
+
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("transit: automatically rotating key", "name", name)
+	}
+	return p.Rotate(storage)
+}
+
 const pathRotateHelpSyn = `Rotate named encryption key`
 
 const pathRotateHelpDesc = `
@@ -0,0 +1,114 @@
+package transit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_AutoRotate(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Storage:   s,
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(op logical.Operation, path string, data map[string]interface{}) {
+		resp, err := request(op, path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected an error, got resp:%#v", path, resp)
+		}
+	}
+	latestVersion := func(name string) int {
+		resp := mustRequest(logical.ReadOperation, "keys/"+name, nil)
+		return resp.Data["latest_version"].(int)
+	}
+	// backdate moves the creation time of the latest version of the key
+	// into the past
+	backdate := func(name string, d time.Duration) {
+		p, lock, err := b.lm.GetPolicyExclusive(s, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lock.Unlock()
+		entry := p.Keys[p.LatestVersion]
+		entry.CreationTime = entry.CreationTime.Add(-d)
+		entry.DeprecatedCreationTime = entry.CreationTime.Unix()
+		p.Keys[p.LatestVersion] = entry
+		if err := p.Persist(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustRequest(logical.UpdateOperation, "keys/foo", nil)
+	mustRequest(logical.UpdateOperation, "keys/bar", nil)
+
+	// Periods must be positive and at least an hour
+	expectError(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{"auto_rotate_period": "-1h"})
+	expectError(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{"auto_rotate_period": "10m"})
+	mustRequest(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{"auto_rotate_period": "24h"})
+
+	resp := mustRequest(logical.ReadOperation, "keys/foo", nil)
+	if resp.Data["auto_rotate_period"] != int64(86400) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	lastRotation := resp.Data["last_rotation_time"].(time.Time)
+	if lastRotation.IsZero() || time.Since(lastRotation) > time.Minute {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Nothing is due yet
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if v := latestVersion("foo"); v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+
+	// Only the key with an elapsed period is rotated
+	backdate("foo", 25*time.Hour)
+	backdate("bar", 25*time.Hour)
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if v := latestVersion("foo"); v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+	if v := latestVersion("bar"); v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+	resp = mustRequest(logical.ReadOperation, "keys/foo", nil)
+	if !resp.Data["last_rotation_time"].(time.Time).After(lastRotation) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The period counts from the new version
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if v := latestVersion("foo"); v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+
+	// A zero period disables rotation
+	mustRequest(logical.UpdateOperation, "keys/foo/config", map[string]interface{}{"auto_rotate_period": 0})
+	backdate("foo", 25*time.Hour)
+	if err := b.periodicFunc(&logical.Request{Storage: s}); err != nil {
+		t.Fatal(err)
+	}
+	if v := latestVersion("foo"); v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+}
@@ -1,17 +1,57 @@
 package transit
 
 import (
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
-	"hash"
 
 	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// batchRequestSignItem represents a request item for batch signing,
+// verification or HMAC processing
+type batchRequestSignItem struct {
+	// Input is the base64-encoded data to sign, verify or HMAC
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+
+	// Context for key derivation. This is required for derived keys.
+	Context string `json:"context" structs:"context" mapstructure:"context"`
+
+	// Signature to verify against the input
+	Signature string `json:"signature" structs:"signature" mapstructure:"signature"`
+
+	// HMAC to verify against the input
+	HMAC string `json:"hmac" structs:"hmac" mapstructure:"hmac"`
+}
+
+// batchResponseSignItem represents a response item for batch signing
+type batchResponseSignItem struct {
+	// Signature for the input present in the corresponding batch request
+	// item
+	Signature string `json:"signature,omitempty" structs:"signature" mapstructure:"signature"`
+
+	// PublicKey is set for derived ed25519 keys
+	PublicKey []byte `json:"public_key,omitempty" structs:"public_key" mapstructure:"public_key"`
+
+	// Error, if set represents a failure encountered while signing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// batchResponseVerifyItem represents a response item for batch verification
+type batchResponseVerifyItem struct {
+	// Valid indicates whether the signature or HMAC of the corresponding
+	// batch request item matches its input
+	Valid bool `json:"valid" structs:"valid" mapstructure:"valid"`
+
+	// Error, if set represents a failure encountered while verifying a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
 func (b *backend) pathSign() *framework.Path {
 	return &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -58,6 +98,12 @@ including ed25519.`,
 Must be 0 (for latest) or a value greater than or equal
 to the min_encryption_version configured on the key.`,
 			},
+
+			"signature_algorithm": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The signature algorithm to use for RSA keys. Valid
+values are "pss" and "pkcs1v15". Defaults to "pss".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -117,6 +163,12 @@ derivation is enabled; currently only available with ed25519 keys.`,
 
 Defaults to "sha2-256". Not valid for all key types.`,
 			},
+
+			"signature_algorithm": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The signature algorithm to use for RSA keys. Valid
+values are "pss" and "pkcs1v15". Defaults to "pss".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -132,15 +184,30 @@ func (b *backend) pathSignWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
+	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = []batchRequestSignItem{
+			{
+				Input:   d.Get("input").(string),
+				Context: d.Get("context").(string),
+			},
+		}
 	}
 
 	// Get the policy
@@ -159,50 +226,63 @@ func (b *backend) pathSignWrite(
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
 	}
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
+	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
+	if p.Type.HashSignatureInput() && !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	}
+
+	// Process batch request items. If signing of any request item fails,
+	// respectively mark the error in the response collection and continue
+	// to process other items.
+	batchResponseItems := make([]batchResponseSignItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, context, err := decodeSignBatchItem(p, hashAlgorithm, item)
 		if err != nil {
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+			batchResponseItems[i].Error = err.Error()
+			continue
 		}
-	}
 
-	if p.Type.HashSignatureInput() && algorithm != "none" {
-		var hf hash.Hash
-		switch algorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
-			return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+		sig, err := p.Sign(ver, context, input, hashAlgorithm, sigAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
+		}
+		if sig == nil {
+			return nil, fmt.Errorf("signature could not be computed")
+		}
+
+		batchResponseItems[i].Signature = sig.Signature
+		if len(sig.PublicKey) > 0 {
+			batchResponseItems[i].PublicKey = sig.PublicKey
 		}
-		hf.Write(input)
-		input = hf.Sum(nil)
 	}
 
-	sig, err := p.Sign(ver, context, input)
-	if err != nil {
-		return nil, err
+	// Generate the response
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
 	}
-	if sig == nil {
-		return nil, fmt.Errorf("signature could not be computed")
+
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
 	}
 
-	// Generate the response
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"signature": sig.Signature,
+			"signature": batchResponseItems[0].Signature,
 		},
 	}
 
-	if len(sig.PublicKey) > 0 {
-		resp.Data["public_key"] = sig.PublicKey
+	if len(batchResponseItems[0].PublicKey) > 0 {
+		resp.Data["public_key"] = batchResponseItems[0].PublicKey
 	}
 
 	return resp, nil
@@ -210,30 +290,48 @@ func (b *backend) pathSignWrite(
 
 func (b *backend) pathVerifyWrite(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-
-	sig := d.Get("signature").(string)
-	hmac := d.Get("hmac").(string)
-	switch {
-	case sig != "" && hmac != "":
-		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
-
-	case sig == "" && hmac == "":
-		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
-
-	case hmac != "":
-		return b.pathHMACVerify(req, d, hmac)
-	}
-
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
+	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []batchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch input: %v", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = []batchRequestSignItem{
+			{
+				Input:     d.Get("input").(string),
+				Context:   d.Get("context").(string),
+				Signature: d.Get("signature").(string),
+				HMAC:      d.Get("hmac").(string),
+			},
+		}
+	}
+
+	// Before getting the policy, make sure each request item carries
+	// exactly one of a signature or an HMAC to verify
+	batchResponseItems := make([]batchResponseVerifyItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		switch {
+		case item.Signature != "" && item.HMAC != "":
+			batchResponseItems[i].Error = "provide one of 'signature' or 'hmac'"
+		case item.Signature == "" && item.HMAC == "":
+			batchResponseItems[i].Error = "neither a 'signature' nor an 'hmac' were given to verify"
+		}
+	}
+	if batchInputRaw == nil && batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
 	}
 
 	// Get the policy
@@ -248,65 +346,107 @@ func (b *backend) pathVerifyWrite(
 		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
 	}
 
-	if !p.Type.SigningSupported() {
-		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
-	}
+	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
-		if err != nil {
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+	// Process batch request items. If verification of any request item
+	// fails, respectively mark the error in the response collection and
+	// continue to process other items.
+	for i, item := range batchInputItems {
+		if batchResponseItems[i].Error != "" {
+			continue
 		}
-	}
 
-	if p.Type.HashSignatureInput() && algorithm != "none" {
-		var hf hash.Hash
-		switch algorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
-			return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
-		}
-		hf.Write(input)
-		input = hf.Sum(nil)
-	}
+		var valid bool
+		switch {
+		case item.HMAC != "":
+			var input []byte
+			input, err = base64.StdEncoding.DecodeString(item.Input)
+			if err != nil {
+				err = errutil.UserError{Err: fmt.Sprintf("unable to decode input as base64: %s", err)}
+				break
+			}
+			valid, err = verifyHMAC(p, algorithm, input, item.HMAC)
+
+		case !p.Type.SigningSupported():
+			err = errutil.UserError{Err: fmt.Sprintf("key type %v does not support verification", p.Type)}
+
+		case p.Type.HashSignatureInput() && !ok:
+			err = errutil.UserError{Err: fmt.Sprintf("unsupported algorithm %s", algorithm)}
 
-	valid, err := p.VerifySignature(context, input, sig)
-	if err != nil {
-		switch err.(type) {
-		case errutil.UserError:
-			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		case errutil.InternalError:
-			return nil, err
 		default:
-			return nil, err
+			var input, context []byte
+			input, context, err = decodeSignBatchItem(p, hashAlgorithm, item)
+			if err != nil {
+				break
+			}
+			valid, err = p.VerifySignature(context, input, item.Signature, hashAlgorithm, sigAlgorithm)
+		}
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				return nil, err
+			}
 		}
+
+		batchResponseItems[i].Valid = valid
 	}
 
 	// Generate the response
-	resp := &logical.Response{
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
+	}
+
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
 		Data: map[string]interface{}{
-			"valid": valid,
+			"valid": batchResponseItems[0].Valid,
 		},
+	}, nil
+}
+
+// decodeSignBatchItem decodes the input and context of a sign or verify
+// request item, hashing the input if the key type requires it
+func decodeSignBatchItem(p *keysutil.Policy, hashAlgorithm keysutil.HashType, item batchRequestSignItem) ([]byte, []byte, error) {
+	input, err := base64.StdEncoding.DecodeString(item.Input)
+	if err != nil {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("unable to decode input as base64: %s", err)}
 	}
-	return resp, nil
+
+	var context []byte
+	if len(item.Context) != 0 {
+		context, err = base64.StdEncoding.DecodeString(item.Context)
+		if err != nil {
+			return nil, nil, errutil.UserError{Err: "failed to base64-decode context"}
+		}
+	}
+
+	if p.Type.HashSignatureInput() && hashAlgorithm != keysutil.HashTypeNone {
+		hf := keysutil.HashFuncMap[hashAlgorithm]()
+		hf.Write(input)
+		input = hf.Sum(nil)
+	}
+
+	return input, context, nil
 }
 
 const pathSignHelpSyn = `Generate a signature for input data using the named key`
 
 const pathSignHelpDesc = `
-Generates a signature of the input data using the named key and the given hash algorithm.
+Generates a signature of the input data, or of each item of the given batch
+input, using the named key and the given hash algorithm.
 `
 const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
-Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+Verifies a signature or HMAC of the input data, or of each item of the given
+batch input, using the named key and the given hash algorithm.
 `
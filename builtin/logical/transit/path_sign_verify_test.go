@@ -415,3 +415,214 @@ func TestTransit_SignVerify_ED25519(t *testing.T) {
 	verifyRequest(req, false, "bar", sig)
 	verifyRequest(req, true, "bar", v1sig)
 }
+
+func TestTransit_SignVerify_KeyTypes(t *testing.T) {
+	testSignVerifyKeyType(t, "ecdsa-p384", "")
+	testSignVerifyKeyType(t, "ecdsa-p521", "")
+	testSignVerifyKeyType(t, "rsa-2048", "")
+	testSignVerifyKeyType(t, "rsa-2048", "pss")
+	testSignVerifyKeyType(t, "rsa-2048", "pkcs1v15")
+	testSignVerifyKeyType(t, "rsa-4096", "pkcs1v15")
+}
+
+func testSignVerifyKeyType(t *testing.T, keyType, sigAlgorithm string) {
+	b, storage := createBackendWithStorage(t)
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Data: map[string]interface{}{
+			"type": keyType,
+		},
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	sign := func(algorithm string) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "sign/foo",
+			Data: map[string]interface{}{
+				"input":               "dGhlIHF1aWNrIGJyb3duIGZveA==",
+				"algorithm":           algorithm,
+				"signature_algorithm": sigAlgorithm,
+			},
+		})
+	}
+	verify := func(algorithm, sig string) bool {
+		resp, err := b.HandleRequest(&logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      "verify/foo",
+			Data: map[string]interface{}{
+				"input":               "dGhlIHF1aWNrIGJyb3duIGZveA==",
+				"algorithm":           algorithm,
+				"signature_algorithm": sigAlgorithm,
+				"signature":           sig,
+			},
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			return false
+		}
+		return resp.Data["valid"].(bool)
+	}
+
+	var v1sig string
+	for _, algorithm := range []string{"sha2-224", "sha2-256", "sha2-384", "sha2-512", "none"} {
+		resp, err := sign(algorithm)
+		if algorithm == "none" && strings.HasPrefix(keyType, "rsa") && sigAlgorithm != "pkcs1v15" {
+			// PSS signatures need a hash of the input
+			if err == nil || resp == nil || !resp.IsError() {
+				t.Fatalf("%s: expected error, got %#v", keyType, resp)
+			}
+			continue
+		}
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("%s/%s: err: %v, resp: %#v", keyType, algorithm, err, resp)
+		}
+		sig := resp.Data["signature"].(string)
+		if !verify(algorithm, sig) {
+			t.Fatalf("%s/%s: signature did not verify", keyType, algorithm)
+		}
+		if algorithm == "sha2-256" {
+			v1sig = sig
+			if verify("sha2-512", sig) {
+				t.Fatalf("%s: signature verified with the wrong algorithm", keyType)
+			}
+		}
+	}
+
+	// Signatures of archived versions stop verifying once the min
+	// decryption version passes them
+	for i := 0; i < 2; i++ {
+		req.Path = "keys/foo/rotate"
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !verify("sha2-256", v1sig) {
+		t.Fatalf("%s: v1 signature did not verify", keyType)
+	}
+	req.Path = "keys/foo/config"
+	req.Data = map[string]interface{}{
+		"min_decryption_version": 2,
+	}
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if verify("sha2-256", v1sig) {
+		t.Fatalf("%s: v1 signature verified below the min decryption version", keyType)
+	}
+
+	resp, err = sign("sha2-256")
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	sig := resp.Data["signature"].(string)
+	if !strings.HasPrefix(sig, "vault:v3:") || !verify("sha2-256", sig) {
+		t.Fatalf("%s: bad signature %q", keyType, sig)
+	}
+
+	// The public keys of all versions are returned
+	req.Operation = logical.ReadOperation
+	req.Path = "keys/foo"
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := resp.Data["keys"].(map[string]map[string]interface{})
+	if len(keys) != 2 || keys["3"]["public_key"] == "" {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
+
+func TestTransit_SignVerify_Batch(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"type": "ecdsa-p256",
+		},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// The item with invalid input fails on its own
+	req.Path = "sign/foo"
+	req.Data = map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+			map[string]interface{}{"input": "not base64"},
+			map[string]interface{}{"input": "anVtcGVkIG92ZXIgdGhlIGxhenkgZG9n"},
+		},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	signResults := resp.Data["batch_results"].([]batchResponseSignItem)
+	if len(signResults) != 3 {
+		t.Fatalf("bad: %#v", signResults)
+	}
+	if signResults[0].Error != "" || signResults[0].Signature == "" {
+		t.Fatalf("bad: %#v", signResults[0])
+	}
+	if signResults[1].Error == "" || signResults[1].Signature != "" {
+		t.Fatalf("expected an error, got %#v", signResults[1])
+	}
+	if signResults[2].Error != "" || signResults[2].Signature == "" {
+		t.Fatalf("bad: %#v", signResults[2])
+	}
+
+	// Verify the signatures, swapping them between inputs for the last item
+	req.Path = "verify/foo"
+	req.Data = map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "signature": signResults[0].Signature},
+			map[string]interface{}{"input": "anVtcGVkIG92ZXIgdGhlIGxhenkgZG9n", "signature": signResults[2].Signature},
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+			map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "signature": signResults[2].Signature},
+		},
+	}
+	resp, err = b.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	verifyResults := resp.Data["batch_results"].([]batchResponseVerifyItem)
+	if len(verifyResults) != 4 {
+		t.Fatalf("bad: %#v", verifyResults)
+	}
+	if !verifyResults[0].Valid || verifyResults[0].Error != "" {
+		t.Fatalf("bad: %#v", verifyResults[0])
+	}
+	if !verifyResults[1].Valid || verifyResults[1].Error != "" {
+		t.Fatalf("bad: %#v", verifyResults[1])
+	}
+	if verifyResults[2].Valid || verifyResults[2].Error == "" {
+		t.Fatalf("expected an error, got %#v", verifyResults[2])
+	}
+	if verifyResults[3].Valid || verifyResults[3].Error != "" {
+		t.Fatalf("bad: %#v", verifyResults[3])
+	}
+
+	// An empty batch is rejected
+	req.Data = map[string]interface{}{
+		"batch_input": []interface{}{},
+	}
+	resp, err = b.HandleRequest(req)
+	if err == nil {
+		t.Fatalf("expected an error, got resp:%#v", resp)
+	}
+}
@@ -0,0 +1,72 @@
+package transit
+
+import (
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathTrim() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/trim",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"min_available_version": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The minimum available version for the key ring. All
+versions before this version will be permanently deleted.
+This value can at most be equal to the lesser of
+'min_decryption_version' and 'min_encryption_version'.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTrimUpdate,
+		},
+
+		HelpSynopsis:    pathTrimHelpSyn,
+		HelpDescription: pathTrimHelpDesc,
+	}
+}
+
+func (b *backend) pathTrimUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyExclusive(req.Storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+
+	minAvailableVersionRaw, ok := d.GetOk("min_available_version")
+	if !ok {
+		return logical.ErrorResponse("missing min_available_version"), logical.ErrInvalidRequest
+	}
+
+	if err := p.Trim(req.Storage, minAvailableVersionRaw.(int)); err != nil {
+		if _, ok := err.(errutil.UserError); ok {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathTrimHelpSyn = `Trim key versions of a named key`
+
+const pathTrimHelpDesc = `
+This path is used to permanently delete the versions of the named
+key that are older than the given minimum available version, both
+from the key ring and from its archive.
+`
@@ -0,0 +1,101 @@
+package transit
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestTransit_Trim(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err:%v resp:%#v", path, err, resp)
+		}
+		return resp
+	}
+	expectError := func(path string, data map[string]interface{}) {
+		resp, err := request(path, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected error, got %#v", path, resp)
+		}
+	}
+
+	mustRequest("keys/foo", nil)
+	var ciphertexts []string
+	for i := 1; i <= 5; i++ {
+		if i > 1 {
+			mustRequest("keys/foo/rotate", nil)
+		}
+		resp := mustRequest("encrypt/foo", map[string]interface{}{
+			"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+		})
+		ciphertexts = append(ciphertexts, resp.Data["ciphertext"].(string))
+	}
+
+	// The trim is bounded by the min decryption version
+	expectError("keys/foo/trim", map[string]interface{}{
+		"min_available_version": 2,
+	})
+	expectError("keys/foo/trim", nil)
+	expectError("keys/bar/trim", map[string]interface{}{
+		"min_available_version": 1,
+	})
+
+	mustRequest("keys/foo/config", map[string]interface{}{
+		"min_decryption_version": 4,
+		"min_encryption_version": 4,
+	})
+	expectError("keys/foo/trim", map[string]interface{}{
+		"min_available_version": 5,
+	})
+	mustRequest("keys/foo/trim", map[string]interface{}{
+		"min_available_version": 3,
+	})
+
+	resp, err := b.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "keys/foo",
+		Storage:   s,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["min_available_version"] != 3 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Trimmed versions cannot be brought back; the remaining archived
+	// version can
+	expectError("keys/foo/config", map[string]interface{}{
+		"min_decryption_version": 2,
+	})
+	mustRequest("keys/foo/config", map[string]interface{}{
+		"min_decryption_version": 3,
+		"min_encryption_version": 3,
+	})
+	for i, ciphertext := range ciphertexts {
+		resp, err := request("decrypt/foo", map[string]interface{}{
+			"ciphertext": ciphertext,
+		})
+		if i < 2 {
+			if err == nil && !resp.IsError() {
+				t.Fatalf("expected error decrypting trimmed version %d", i+1)
+			}
+			continue
+		}
+		if err != nil || resp.IsError() || resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+			t.Fatalf("version %d: err:%v resp:%#v", i+1, err, resp)
+		}
+	}
+}
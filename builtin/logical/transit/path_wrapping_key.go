@@ -0,0 +1,116 @@
+package transit
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// wrappingKeyPath is the storage path of the RSA key used to wrap key
+	// material being imported
+	wrappingKeyPath = "import/wrapping-key"
+
+	wrappingKeyBits = 4096
+)
+
+// wrappingKeyEntry is the stored form of the wrapping key
+type wrappingKeyEntry struct {
+	Key *rsa.PrivateKey `json:"key"`
+}
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathWrappingKeyRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling wrapping key: %v", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: derBytes,
+			})),
+		},
+	}, nil
+}
+
+// getWrappingKey returns the wrapping key, generating it on first use
+func (b *backend) getWrappingKey(storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.RLock()
+	key := b.wrappingKey
+	b.wrappingKeyLock.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	// Check if it was loaded in the meantime
+	if b.wrappingKey != nil {
+		return b.wrappingKey, nil
+	}
+
+	raw, err := storage.Get(wrappingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		var entry wrappingKeyEntry
+		if err := jsonutil.DecodeJSON(raw.Value, &entry); err != nil {
+			return nil, fmt.Errorf("error decoding wrapping key: %v", err)
+		}
+		b.wrappingKey = entry.Key
+		return b.wrappingKey, nil
+	}
+
+	key, err = rsa.GenerateKey(rand.Reader, wrappingKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating wrapping key: %v", err)
+	}
+	entry, err := logical.StorageEntryJSON(wrappingKeyPath, &wrappingKeyEntry{
+		Key: key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.Put(entry); err != nil {
+		return nil, err
+	}
+
+	b.wrappingKey = key
+	return key, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to use for wrapping imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path is used to retrieve the RSA-4096 wrapping key for wrapping
+keys that are being imported into transit.
+`
@@ -0,0 +1,358 @@
+package command
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	colorable "github.com/mattn/go-colorable"
+	log "github.com/mgutz/logxi/v1"
+	"github.com/posener/complete"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/command/agent/auth/approle"
+	"github.com/hashicorp/vault/command/agent/auth/cert"
+	"github.com/hashicorp/vault/command/agent/auth/jwt"
+	"github.com/hashicorp/vault/command/agent/cache"
+	agentConfig "github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/command/agent/sink/file"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/helper/gated-writer"
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/meta"
+)
+
+// AgentCommand is a Command that starts the Vault agent, which
+// authenticates on behalf of applications and caches their secrets.
+type AgentCommand struct {
+	meta.Meta
+
+	ShutdownCh chan struct{}
+
+	logGate *gatedwriter.Writer
+	logger  log.Logger
+}
+
+func (c *AgentCommand) Run(args []string) int {
+	var configPath, logLevel string
+	flags := c.Meta.FlagSet("agent", meta.FlagSetDefault)
+	flags.StringVar(&configPath, "config", "", "")
+	flags.StringVar(&logLevel, "log-level", "info", "")
+	flags.Usage = func() { c.Ui.Output(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if configPath == "" {
+		c.Ui.Error("At least one config path must be specified with -config")
+		flags.Usage()
+		return 1
+	}
+
+	config, err := agentConfig.LoadConfig(configPath)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration from %s: %s", configPath, err))
+		return 1
+	}
+
+	// Create a logger. We wrap it in a gated writer so that it doesn't
+	// start logging too early.
+	c.logGate = &gatedwriter.Writer{Writer: colorable.NewColorable(os.Stderr)}
+	var level int
+	switch strings.ToLower(strings.TrimSpace(logLevel)) {
+	case "trace":
+		level = log.LevelTrace
+	case "debug":
+		level = log.LevelDebug
+	case "info":
+		level = log.LevelInfo
+	case "notice":
+		level = log.LevelNotice
+	case "warn":
+		level = log.LevelWarn
+	case "err":
+		level = log.LevelError
+	default:
+		c.Ui.Error(fmt.Sprintf("Unknown log level %s", logLevel))
+		return 1
+	}
+	c.logger = logformat.NewVaultLoggerWithWriter(c.logGate, level)
+
+	client, err := c.Client()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error initializing client: %s", err))
+		return 1
+	}
+
+	info := map[string]string{
+		"log level":     logLevel,
+		"vault address": client.Address(),
+	}
+
+	var method auth.AuthMethod
+	var sinks []*sink.SinkConfig
+	if config.AutoAuth != nil {
+		method, err = c.newAuthMethod(config.AutoAuth.Method)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating %s auth method: %s", config.AutoAuth.Method.Type, err))
+			return 1
+		}
+		info["auth method"] = fmt.Sprintf("%s (%s)", config.AutoAuth.Method.Type, config.AutoAuth.Method.MountPath)
+
+		for _, sc := range config.AutoAuth.Sinks {
+			s, err := c.newSink(sc, client)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error creating %s sink: %s", sc.Type, err))
+				return 1
+			}
+			sinks = append(sinks, s)
+		}
+		info["sinks"] = fmt.Sprintf("%d", len(sinks))
+	}
+
+	var leaseCache *cache.LeaseCache
+	var listeners []net.Listener
+	if config.Cache != nil {
+		leaseCache, err = cache.NewLeaseCache(&cache.LeaseCacheConfig{
+			Client:           client,
+			Logger:           c.logger,
+			UseAutoAuthToken: config.Cache.UseAutoAuthToken,
+		})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error creating lease cache: %s", err))
+			return 1
+		}
+		defer leaseCache.Shutdown()
+
+		for i, lnConfig := range config.Listeners {
+			ln, props, _, err := server.NewListener(lnConfig.Type, lnConfig.Config, c.logGate)
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error initializing listener of type %s: %s", lnConfig.Type, err))
+				return 1
+			}
+			defer ln.Close()
+			listeners = append(listeners, ln)
+
+			var propsList []string
+			for k, v := range props {
+				propsList = append(propsList, fmt.Sprintf("%s: %q", k, v))
+			}
+			sort.Strings(propsList)
+			info[fmt.Sprintf("listener %d", i+1)] = fmt.Sprintf(
+				"%s (%s)", lnConfig.Type, strings.Join(propsList, ", "))
+		}
+	}
+
+	if err := c.storePidFile(config.PidFile); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error storing PID: %s", err))
+		return 1
+	}
+	defer func() {
+		if err := c.removePidFile(config.PidFile); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error deleting the PID file: %s", err))
+		}
+	}()
+
+	// Output the header and the configuration
+	c.Ui.Output("==> Vault agent configuration:\n")
+	infoKeys := make([]string, 0, len(info))
+	padding := 0
+	for k := range info {
+		infoKeys = append(infoKeys, k)
+		if len(k) > padding {
+			padding = len(k)
+		}
+	}
+	sort.Strings(infoKeys)
+	for _, k := range infoKeys {
+		c.Ui.Output(fmt.Sprintf(
+			"%s%s: %s",
+			strings.Repeat(" ", padding-len(k)),
+			strings.Title(k),
+			info[k]))
+	}
+	c.Ui.Output("")
+	c.Ui.Output("==> Vault agent started! Log data will stream in below:\n")
+	c.logGate.Flush()
+
+	var wg sync.WaitGroup
+	doneCh := make(chan struct{})
+
+	if method != nil {
+		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+			Logger: c.logger,
+			Client: client,
+		})
+		ss := sink.NewSinkServer(&sink.SinkServerConfig{
+			Logger: c.logger,
+		})
+
+		// Tokens go to the sinks, and to the cache if it uses them
+		tokenCh := ah.OutputCh
+		if leaseCache != nil && config.Cache.UseAutoAuthToken {
+			sinkCh := make(chan string, 1)
+			tokenCh = sinkCh
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-doneCh:
+						return
+					case token := <-ah.OutputCh:
+						leaseCache.SetAutoAuthToken(token)
+						select {
+						case <-doneCh:
+							return
+						case sinkCh <- token:
+						}
+					}
+				}
+			}()
+		}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ah.Run(doneCh, method)
+		}()
+		go func() {
+			defer wg.Done()
+			ss.Run(doneCh, tokenCh, sinks)
+		}()
+	}
+
+	for _, ln := range listeners {
+		go http.Serve(ln, leaseCache)
+	}
+
+	<-c.ShutdownCh
+	c.Ui.Output("==> Vault agent shutdown triggered")
+	close(doneCh)
+	wg.Wait()
+
+	return 0
+}
+
+// newAuthMethod returns the configured auto-auth method
+func (c *AgentCommand) newAuthMethod(m *agentConfig.Method) (auth.AuthMethod, error) {
+	authConfig := &auth.AuthConfig{
+		Logger:    c.logger,
+		MountPath: m.MountPath,
+		Config:    m.Config,
+	}
+
+	switch m.Type {
+	case "approle":
+		return approle.NewApproleAuthMethod(authConfig)
+	case "cert":
+		return cert.NewCertAuthMethod(authConfig)
+	case "jwt":
+		return jwt.NewJWTAuthMethod(authConfig)
+	default:
+		return nil, fmt.Errorf("unknown auth method type %q", m.Type)
+	}
+}
+
+// newSink returns the configured sink
+func (c *AgentCommand) newSink(s *agentConfig.Sink, client *api.Client) (*sink.SinkConfig, error) {
+	sc := &sink.SinkConfig{
+		Logger:  c.logger,
+		Config:  s.Config,
+		Client:  client,
+		WrapTTL: s.WrapTTL,
+	}
+
+	switch s.Type {
+	case "file":
+		fs, err := file.NewFileSink(sc)
+		if err != nil {
+			return nil, err
+		}
+		sc.Sink = fs
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", s.Type)
+	}
+
+	return sc, nil
+}
+
+// storePidFile is used to write out our PID to a file if necessary
+func (c *AgentCommand) storePidFile(pidPath string) error {
+	if pidPath == "" {
+		return nil
+	}
+
+	pidFile, err := os.OpenFile(pidPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open pid file: %v", err)
+	}
+	defer pidFile.Close()
+
+	if _, err := pidFile.WriteString(fmt.Sprintf("%d", os.Getpid())); err != nil {
+		return fmt.Errorf("could not write to pid file: %v", err)
+	}
+	return nil
+}
+
+// removePidFile is used to cleanup the PID file if necessary
+func (c *AgentCommand) removePidFile(pidPath string) error {
+	if pidPath == "" {
+		return nil
+	}
+	return os.Remove(pidPath)
+}
+
+func (c *AgentCommand) Synopsis() string {
+	return "Start a Vault agent"
+}
+
+func (c *AgentCommand) Help() string {
+	helpText := `
+Usage: vault agent [options]
+
+  Start a Vault agent.
+
+  The agent authenticates with Vault on behalf of applications using the
+  auto-auth method in its configuration, keeps the resulting token renewed,
+  and writes it to the configured sinks, such as files. A token written to a
+  sink can be response-wrapped.
+
+  If a cache is configured, the agent also listens for requests and proxies
+  them to Vault. Leased secrets and tokens returned by Vault are cached and
+  renewed by the agent, and evicted when they can no longer be renewed or
+  their lease expires.
+
+  Start an agent with a configuration file:
+
+      $ vault agent -config=/etc/vault/agent.hcl
+
+General Options:
+` + meta.GeneralOptionsUsage() + `
+Agent Options:
+
+  -config=<path>          Path to the configuration file.
+
+  -log-level=info         Log verbosity. Defaults to "info", will be output to
+                          stderr. Supported values: "trace", "debug", "info",
+                          "warn", "err"
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AgentCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *AgentCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":    complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json")),
+		"-log-level": complete.PredictSet("trace", "debug", "info", "warn", "err"),
+	}
+}
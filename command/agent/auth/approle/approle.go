@@ -0,0 +1,84 @@
+package approle
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+)
+
+type approleMethod struct {
+	mountPath        string
+	roleIDFilePath   string
+	secretIDFilePath string
+}
+
+// NewApproleAuthMethod returns a method that logs in with the role ID and
+// secret ID read from the configured files
+func NewApproleAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
+	if conf == nil {
+		return nil, errors.New("empty config")
+	}
+	if conf.Config == nil {
+		return nil, errors.New("empty config data")
+	}
+
+	a := &approleMethod{
+		mountPath: conf.MountPath,
+	}
+
+	roleIDFilePathRaw, ok := conf.Config["role_id_file_path"]
+	if !ok {
+		return nil, errors.New("missing 'role_id_file_path' value")
+	}
+	a.roleIDFilePath, ok = roleIDFilePathRaw.(string)
+	if !ok || a.roleIDFilePath == "" {
+		return nil, errors.New("could not convert 'role_id_file_path' config value to string")
+	}
+
+	if secretIDFilePathRaw, ok := conf.Config["secret_id_file_path"]; ok {
+		a.secretIDFilePath, ok = secretIDFilePathRaw.(string)
+		if !ok {
+			return nil, errors.New("could not convert 'secret_id_file_path' config value to string")
+		}
+	}
+
+	return a, nil
+}
+
+func (a *approleMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	// The files are read on every login so that they can be replaced, for
+	// instance with a new secret ID, without restarting the agent
+	roleID, err := readValue(a.roleIDFilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading role ID file: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"role_id": roleID,
+	}
+	if a.secretIDFilePath != "" {
+		secretID, err := readValue(a.secretIDFilePath)
+		if err != nil {
+			return "", nil, fmt.Errorf("error reading secret ID file: %v", err)
+		}
+		data["secret_id"] = secretID
+	}
+
+	return fmt.Sprintf("%s/login", a.mountPath), data, nil
+}
+
+func readValue(path string) (string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(raw))
+	if value == "" {
+		return "", fmt.Errorf("file %q is empty", path)
+	}
+	return value, nil
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	initialBackoff = 1 * time.Second
+	maxBackoff     = 5 * time.Minute
+)
+
+// AuthMethod is implemented by the methods the agent can authenticate with.
+// Authenticate returns the login path and the data to write to it.
+type AuthMethod interface {
+	Authenticate(*api.Client) (string, map[string]interface{}, error)
+}
+
+// AuthConfig is the configuration passed to the factory of an AuthMethod
+type AuthConfig struct {
+	Logger    log.Logger
+	MountPath string
+	Config    map[string]interface{}
+}
+
+// AuthHandler authenticates with an AuthMethod, keeps the resulting token
+// renewed, and authenticates again when the token can no longer be renewed.
+// Every new token is sent on OutputCh.
+type AuthHandler struct {
+	OutputCh chan string
+
+	logger log.Logger
+	client *api.Client
+	random *rand.Rand
+}
+
+// AuthHandlerConfig is the configuration of an AuthHandler
+type AuthHandlerConfig struct {
+	Logger log.Logger
+	Client *api.Client
+}
+
+// NewAuthHandler returns a new AuthHandler
+func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
+	return &AuthHandler{
+		// This is buffered so that a token can be handed off while the
+		// consumer is still busy with the previous one
+		OutputCh: make(chan string, 1),
+		logger:   conf.Logger,
+		client:   conf.Client,
+		random:   rand.New(rand.NewSource(int64(time.Now().Nanosecond()))),
+	}
+}
+
+// Run authenticates with the given method until the shutdown channel is
+// closed
+func (ah *AuthHandler) Run(shutdownCh <-chan struct{}, am AuthMethod) {
+	if am == nil {
+		panic("nil auth method")
+	}
+
+	ah.logger.Info("auth.handler: starting")
+	defer ah.logger.Info("auth.handler: shutting down")
+
+	backoff := initialBackoff
+
+	// sleep waits before the next attempt, doubling the backoff every time,
+	// and reports whether the handler should keep running
+	sleep := func() bool {
+		wait := backoff + time.Duration(ah.random.Int63n(int64(backoff)))
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		select {
+		case <-shutdownCh:
+			return false
+		case <-time.After(wait):
+			return true
+		}
+	}
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+		default:
+		}
+
+		// Logins are not made with the token of the agent itself
+		client, err := ah.client.CloneWithState()
+		if err != nil {
+			ah.logger.Error("auth.handler: error creating client", "error", err)
+			if !sleep() {
+				return
+			}
+			continue
+		}
+		client.ClearToken()
+
+		path, data, err := am.Authenticate(client)
+		if err != nil {
+			ah.logger.Error("auth.handler: error getting path or data from method", "error", err)
+			if !sleep() {
+				return
+			}
+			continue
+		}
+
+		secret, err := client.Logical().Write(path, data)
+		if err == nil && (secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "") {
+			err = fmt.Errorf("no token returned")
+		}
+		if err != nil {
+			ah.logger.Error("auth.handler: error authenticating", "path", path, "error", err)
+			if !sleep() {
+				return
+			}
+			continue
+		}
+		backoff = initialBackoff
+
+		ah.logger.Info("auth.handler: authentication successful, sending token to sinks")
+		select {
+		case <-shutdownCh:
+			return
+		case ah.OutputCh <- secret.Auth.ClientToken:
+		}
+
+		if !secret.Auth.Renewable {
+			ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+			if ttl == 0 {
+				ah.logger.Info("auth.handler: token does not expire")
+				<-shutdownCh
+				return
+			}
+
+			// Authenticate again shortly before the token expires
+			ah.logger.Info("auth.handler: token is not renewable, re-authenticating before it expires", "ttl", ttl)
+			select {
+			case <-shutdownCh:
+				return
+			case <-time.After(ttl * 2 / 3):
+			}
+			continue
+		}
+
+		renewer, err := client.NewRenewer(&api.RenewerInput{
+			Secret: secret,
+		})
+		if err != nil {
+			ah.logger.Error("auth.handler: error creating renewer", "error", err)
+			if !sleep() {
+				return
+			}
+			continue
+		}
+		go renewer.Renew()
+
+	RenewerLoop:
+		for {
+			select {
+			case <-shutdownCh:
+				renewer.Stop()
+				return
+
+			case err := <-renewer.DoneCh():
+				if err != nil {
+					ah.logger.Error("auth.handler: error renewing token", "error", err)
+				}
+				ah.logger.Info("auth.handler: token can no longer be renewed, re-authenticating")
+				break RenewerLoop
+
+			case <-renewer.RenewCh():
+				ah.logger.Info("auth.handler: renewed auth token")
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cert
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+)
+
+type certMethod struct {
+	mountPath string
+	name      string
+}
+
+// NewCertAuthMethod returns a method that logs in with the TLS client
+// certificate the Vault client is configured with, for instance through
+// VAULT_CLIENT_CERT and VAULT_CLIENT_KEY
+func NewCertAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
+	if conf == nil {
+		return nil, errors.New("empty config")
+	}
+
+	c := &certMethod{
+		mountPath: conf.MountPath,
+	}
+
+	if conf.Config != nil {
+		if nameRaw, ok := conf.Config["name"]; ok {
+			c.name, ok = nameRaw.(string)
+			if !ok {
+				return nil, errors.New("could not convert 'name' config value to string")
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *certMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if c.name != "" {
+		data["name"] = c.name
+	}
+
+	return fmt.Sprintf("%s/login", c.mountPath), data, nil
+}
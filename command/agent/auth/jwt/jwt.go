@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+)
+
+type jwtMethod struct {
+	mountPath string
+	path      string
+	role      string
+}
+
+// NewJWTAuthMethod returns a method that logs in with the JWT read from the
+// configured file. The file is read on every login, so whatever issues the
+// JWT can keep replacing it with a fresh one.
+func NewJWTAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
+	if conf == nil {
+		return nil, errors.New("empty config")
+	}
+	if conf.Config == nil {
+		return nil, errors.New("empty config data")
+	}
+
+	j := &jwtMethod{
+		mountPath: conf.MountPath,
+	}
+
+	pathRaw, ok := conf.Config["path"]
+	if !ok {
+		return nil, errors.New("missing 'path' value")
+	}
+	j.path, ok = pathRaw.(string)
+	if !ok || j.path == "" {
+		return nil, errors.New("could not convert 'path' config value to string")
+	}
+
+	roleRaw, ok := conf.Config["role"]
+	if !ok {
+		return nil, errors.New("missing 'role' value")
+	}
+	j.role, ok = roleRaw.(string)
+	if !ok || j.role == "" {
+		return nil, errors.New("could not convert 'role' config value to string")
+	}
+
+	return j, nil
+}
+
+func (j *jwtMethod) Authenticate(client *api.Client) (string, map[string]interface{}, error) {
+	raw, err := ioutil.ReadFile(j.path)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading JWT file: %v", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", nil, fmt.Errorf("JWT file %q is empty", j.path)
+	}
+
+	return fmt.Sprintf("%s/login", j.mountPath), map[string]interface{}{
+		"role": j.role,
+		"jwt":  token,
+	}, nil
+}
@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	tokenHeaderName     = "X-Vault-Token"
+	namespaceHeaderName = "X-Vault-Namespace"
+	wrapTTLHeaderName   = "X-Vault-Wrap-TTL"
+)
+
+// nonCacheablePrefixes are the paths whose responses are never cached even
+// if they carry a lease, such as the renewal of leases and tokens
+var nonCacheablePrefixes = []string{
+	"/v1/sys/",
+	"/v1/auth/token/renew",
+}
+
+// LeaseCache is an http.Handler that proxies requests to Vault. Responses
+// holding a leased secret or a token are cached, so that repeated requests
+// share the same secret. Cached secrets are renewed in the background and
+// evicted once they can no longer be renewed or their lease expires.
+type LeaseCache struct {
+	client           *api.Client
+	logger           log.Logger
+	useAutoAuthToken bool
+
+	tokenLock     sync.RWMutex
+	autoAuthToken string
+
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// LeaseCacheConfig is the configuration of a LeaseCache
+type LeaseCacheConfig struct {
+	Client *api.Client
+	Logger log.Logger
+
+	// UseAutoAuthToken makes the cache use the token of the agent for
+	// requests that do not carry a token
+	UseAutoAuthToken bool
+}
+
+// cacheEntry is a cached response from Vault
+type cacheEntry struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+
+	// stopCh stops the renewal of the secret
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func (e *cacheEntry) stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+	})
+}
+
+// NewLeaseCache returns a new LeaseCache
+func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
+	if conf.Client == nil {
+		return nil, errors.New("nil API client")
+	}
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger")
+	}
+
+	return &LeaseCache{
+		client:           conf.Client,
+		logger:           conf.Logger,
+		useAutoAuthToken: conf.UseAutoAuthToken,
+		entries:          make(map[string]*cacheEntry),
+	}, nil
+}
+
+// SetAutoAuthToken sets the token used for requests without a token when
+// the cache is configured to use the auto-auth token
+func (c *LeaseCache) SetAutoAuthToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.autoAuthToken = token
+}
+
+// Shutdown stops the renewal of all of the cached secrets and empties the
+// cache
+func (c *LeaseCache) Shutdown() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key, entry := range c.entries {
+		entry.stop()
+		delete(c.entries, key)
+	}
+}
+
+func (c *LeaseCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Errorf("error reading request body: %v", err))
+		return
+	}
+
+	token := r.Header.Get(tokenHeaderName)
+	if token == "" && c.useAutoAuthToken {
+		c.tokenLock.RLock()
+		token = c.autoAuthToken
+		c.tokenLock.RUnlock()
+	}
+
+	// Wrapped responses are single use, so they are never cached
+	cacheable := r.Header.Get(wrapTTLHeaderName) == ""
+	for _, prefix := range nonCacheablePrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			cacheable = false
+		}
+	}
+
+	key := cacheKey(r, token, body)
+	if cacheable {
+		c.lock.Lock()
+		entry, ok := c.entries[key]
+		c.lock.Unlock()
+		if ok {
+			c.logger.Trace("cache: returning cached response", "method", r.Method, "path", r.URL.Path)
+			writeResponse(w, entry.statusCode, entry.header, entry.body)
+			return
+		}
+	}
+
+	resp, err := c.forward(r, token, body)
+	if err != nil {
+		c.logger.Error("cache: error forwarding request", "method", r.Method, "path", r.URL.Path, "error", err)
+		respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		respondError(w, http.StatusBadGateway, fmt.Errorf("error reading response body: %v", err))
+		return
+	}
+
+	if cacheable && resp.StatusCode == http.StatusOK {
+		c.cache(key, token, resp, respBody)
+	}
+
+	writeResponse(w, resp.StatusCode, resp.Header, respBody)
+}
+
+// forward sends the request on to Vault with the given token
+func (c *LeaseCache) forward(r *http.Request, token string, body []byte) (*api.Response, error) {
+	client, err := c.client.CloneWithState()
+	if err != nil {
+		return nil, err
+	}
+
+	req := client.NewRequest(r.Method, r.URL.Path)
+	req.Params = r.URL.Query()
+	req.Headers = make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		req.Headers[name] = values
+	}
+	req.Headers.Del(tokenHeaderName)
+	req.ClientToken = token
+
+	// The namespace and the wrapping TTL are passed through in the headers
+	// of the request
+	req.Namespace = r.Header.Get(namespaceHeaderName)
+	req.WrapTTL = r.Header.Get(wrapTTLHeaderName)
+
+	if len(body) > 0 {
+		req.Body = bytes.NewReader(body)
+		req.BodySize = int64(len(body))
+	}
+
+	// Error responses from Vault are passed back to the client as is, so
+	// only a missing response is an error here
+	resp, err := client.RawRequest(req)
+	if resp == nil {
+		if err == nil {
+			err = errors.New("no response from Vault")
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cache caches the response if it holds a leased secret or a token, and
+// starts renewing it
+func (c *LeaseCache) cache(key, token string, resp *api.Response, body []byte) {
+	secret, err := api.ParseSecret(bytes.NewReader(body))
+	if err != nil || secret == nil || secret.WrapInfo != nil {
+		return
+	}
+
+	var renewable bool
+	var ttl time.Duration
+	switch {
+	case secret.Auth != nil && secret.Auth.ClientToken != "":
+		renewable = secret.Auth.Renewable
+		ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+	case secret.LeaseID != "" && secret.LeaseDuration > 0:
+		renewable = secret.Renewable
+		ttl = time.Duration(secret.LeaseDuration) * time.Second
+	default:
+		return
+	}
+
+	entry := &cacheEntry{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       body,
+		stopCh:     make(chan struct{}),
+	}
+
+	c.lock.Lock()
+	if existing, ok := c.entries[key]; ok {
+		existing.stop()
+	}
+	c.entries[key] = entry
+	c.lock.Unlock()
+
+	c.logger.Debug("cache: caching response", "path", resp.Request.URL.Path, "ttl", ttl, "renewable", renewable)
+
+	if !renewable {
+		if ttl > 0 {
+			go c.evictAfter(key, entry, ttl)
+		}
+		return
+	}
+
+	client, err := c.client.CloneWithState()
+	if err != nil {
+		c.logger.Error("cache: error creating client for renewal", "error", err)
+		c.evict(key, entry)
+		return
+	}
+	client.SetToken(token)
+
+	renewer, err := client.NewRenewer(&api.RenewerInput{
+		Secret: secret,
+	})
+	if err != nil {
+		c.logger.Error("cache: error creating renewer", "error", err)
+		c.evict(key, entry)
+		return
+	}
+	go renewer.Renew()
+	go c.watchRenewer(key, entry, renewer)
+}
+
+// watchRenewer evicts the entry once its secret can no longer be renewed
+func (c *LeaseCache) watchRenewer(key string, entry *cacheEntry, renewer *api.Renewer) {
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-entry.stopCh:
+			return
+
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				c.logger.Error("cache: error renewing cached secret", "error", err)
+			}
+			c.evict(key, entry)
+			return
+
+		case <-renewer.RenewCh():
+			c.logger.Trace("cache: renewed cached secret")
+		}
+	}
+}
+
+// evictAfter evicts the entry once its lease expires
+func (c *LeaseCache) evictAfter(key string, entry *cacheEntry, ttl time.Duration) {
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+
+	select {
+	case <-entry.stopCh:
+	case <-timer.C:
+		c.evict(key, entry)
+	}
+}
+
+// evict removes the entry from the cache, unless it has been replaced
+func (c *LeaseCache) evict(key string, entry *cacheEntry) {
+	entry.stop()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries[key] == entry {
+		c.logger.Debug("cache: evicting cached response")
+		delete(c.entries, key)
+	}
+}
+
+// cacheKey identifies a request by everything that can change its response
+func cacheKey(r *http.Request, token string, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{
+		r.Method,
+		r.URL.Path,
+		r.URL.Query().Encode(),
+		r.Header.Get(namespaceHeaderName),
+		token,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, header http.Header, body []byte) {
+	for name, values := range header {
+		// The length is set by the response writer
+		if name == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
+
+func respondError(w http.ResponseWriter, statusCode int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []string{err.Error()},
+	})
+}
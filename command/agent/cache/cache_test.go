@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// testLeaseCache returns a client talking to Vault through a lease cache,
+// along with the cache and a client talking to Vault directly
+func testLeaseCache(t *testing.T) (*api.Client, *LeaseCache, *api.Client, func()) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	vaultClient, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultClient.SetToken(token)
+
+	c, err := NewLeaseCache(&LeaseCacheConfig{
+		Client: vaultClient,
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(c)
+
+	config = api.DefaultConfig()
+	config.Address = proxy.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(token)
+
+	return client, c, vaultClient, func() {
+		c.Shutdown()
+		proxy.Close()
+		ln.Close()
+	}
+}
+
+func TestLeaseCache_leasedSecret(t *testing.T) {
+	client, c, _, cleanup := testLeaseCache(t)
+	defer cleanup()
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+		"ttl":   "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.LeaseID == "" || first.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", first)
+	}
+
+	// The second read is served from the cache
+	second, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.LeaseID != first.LeaseID {
+		t.Fatalf("expected cached lease %q, got %q", first.LeaseID, second.LeaseID)
+	}
+
+	// Requests made with a different token are cached separately
+	secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.CloneWithState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetToken(secret.Auth.ClientToken)
+	third, err := other.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.LeaseID == first.LeaseID {
+		t.Fatal("expected a different lease for a different token")
+	}
+
+	// Errors are passed through as is
+	other.SetToken("invalid")
+	_, err = other.Logical().Read("secret/foo")
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+
+	c.Shutdown()
+	if len(c.entries) != 0 {
+		t.Fatalf("expected an empty cache, got %d entries", len(c.entries))
+	}
+}
+
+func TestLeaseCache_token(t *testing.T) {
+	client, _, vaultClient, cleanup := testLeaseCache(t)
+	defer cleanup()
+
+	create := func() string {
+		secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{
+			Policies: []string{"default"},
+			TTL:      "1h",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret.Auth.ClientToken
+	}
+
+	token := create()
+	if create() != token {
+		t.Fatal("expected the cached token")
+	}
+
+	// The token is a real token
+	if _, err := vaultClient.Auth().Token().Lookup(token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renewals are never cached
+	other, err := client.CloneWithState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetToken(token)
+	for i := 0; i < 2; i++ {
+		if _, err := other.Auth().Token().RenewSelf(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vaultClient.Auth().Token().RevokeTree(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Auth().Token().RenewSelf(0); err == nil {
+		t.Fatal("expected error renewing a revoked token")
+	}
+}
+
+func TestLeaseCache_evict(t *testing.T) {
+	client, c, _, cleanup := testLeaseCache(t)
+	defer cleanup()
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+		"ttl":   "1s",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lease is too short to be renewed, so it gets evicted
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		c.lock.Lock()
+		count := len(c.entries)
+		c.lock.Unlock()
+		if count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cached secret was not evicted")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	second, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.LeaseID == first.LeaseID {
+		t.Fatal("expected a new lease after eviction")
+	}
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// Config is the configuration for the vault agent.
+type Config struct {
+	AutoAuth  *AutoAuth   `hcl:"-"`
+	Cache     *Cache      `hcl:"-"`
+	Listeners []*Listener `hcl:"-"`
+
+	PidFile string `hcl:"pid_file"`
+}
+
+// AutoAuth is the configured authentication method and sinks
+type AutoAuth struct {
+	Method *Method `hcl:"-"`
+	Sinks  []*Sink `hcl:"-"`
+}
+
+// Method represents the configuration for the authentication backend
+type Method struct {
+	Type      string                 `hcl:"-"`
+	MountPath string                 `hcl:"mount_path"`
+	Config    map[string]interface{} `hcl:"config"`
+}
+
+// Sink defines a location to write the authenticated token
+type Sink struct {
+	Type       string                 `hcl:"-"`
+	WrapTTL    time.Duration          `hcl:"-"`
+	WrapTTLRaw interface{}            `hcl:"wrap_ttl"`
+	Config     map[string]interface{} `hcl:"config"`
+}
+
+// Cache configures the caching proxy
+type Cache struct {
+	UseAutoAuthToken    bool        `hcl:"-"`
+	UseAutoAuthTokenRaw interface{} `hcl:"use_auto_auth_token"`
+}
+
+// Listener is the listener configuration for the caching proxy.
+type Listener struct {
+	Type   string
+	Config map[string]interface{}
+}
+
+// LoadConfig loads the configuration at the given path.
+func LoadConfig(path string) (*Config, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(string(d))
+}
+
+// ParseConfig parses the given agent configuration.
+func ParseConfig(d string) (*Config, error) {
+	obj, err := hcl.Parse(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Config
+	if err := hcl.DecodeObject(&result, obj); err != nil {
+		return nil, err
+	}
+
+	list, ok := obj.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: file doesn't contain a root object")
+	}
+
+	valid := []string{
+		"auto_auth",
+		"cache",
+		"listener",
+		"pid_file",
+	}
+	if err := checkHCLKeys(list, valid); err != nil {
+		return nil, err
+	}
+
+	if o := list.Filter("auto_auth"); len(o.Items) > 0 {
+		if err := parseAutoAuth(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'auto_auth': %s", err)
+		}
+	}
+
+	if o := list.Filter("cache"); len(o.Items) > 0 {
+		if err := parseCache(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'cache': %s", err)
+		}
+	}
+
+	if o := list.Filter("listener"); len(o.Items) > 0 {
+		if err := parseListeners(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'listener': %s", err)
+		}
+	}
+
+	if result.AutoAuth == nil && result.Cache == nil {
+		return nil, fmt.Errorf("at least one of 'auto_auth' and 'cache' must be configured")
+	}
+	if result.Cache != nil {
+		if len(result.Listeners) == 0 {
+			return nil, fmt.Errorf("'cache' requires at least one 'listener'")
+		}
+		if result.Cache.UseAutoAuthToken && result.AutoAuth == nil {
+			return nil, fmt.Errorf("'use_auto_auth_token' requires 'auto_auth' to be configured")
+		}
+	}
+
+	return &result, nil
+}
+
+func parseAutoAuth(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'auto_auth' block is permitted")
+	}
+
+	item := list.Items[0]
+	if err := checkHCLKeys(item.Val, []string{"method", "sink"}); err != nil {
+		return multierror.Prefix(err, "auto_auth:")
+	}
+
+	var subList *ast.ObjectList
+	if ot, ok := item.Val.(*ast.ObjectType); ok {
+		subList = ot.List
+	} else {
+		return fmt.Errorf("could not parse 'auto_auth' as an object")
+	}
+
+	result.AutoAuth = new(AutoAuth)
+
+	methods := subList.Filter("method")
+	if len(methods.Items) != 1 {
+		return fmt.Errorf("exactly one 'method' block is required")
+	}
+	if err := parseMethod(result, methods); err != nil {
+		return multierror.Prefix(err, "auto_auth:")
+	}
+
+	if o := subList.Filter("sink"); len(o.Items) > 0 {
+		if err := parseSinks(result, o); err != nil {
+			return multierror.Prefix(err, "auto_auth:")
+		}
+	}
+
+	return nil
+}
+
+func parseMethod(result *Config, list *ast.ObjectList) error {
+	item := list.Items[0]
+
+	key := "method"
+	if len(item.Keys) > 0 {
+		key = item.Keys[0].Token.Value().(string)
+	}
+
+	if err := checkHCLKeys(item.Val, []string{"mount_path", "config"}); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("method.%s:", key))
+	}
+
+	var m Method
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("method.%s:", key))
+	}
+	m.Type = strings.ToLower(key)
+
+	// Default to the path the method is usually mounted at
+	if m.MountPath == "" {
+		m.MountPath = "auth/" + m.Type
+	}
+	m.MountPath = strings.TrimSuffix(m.MountPath, "/")
+
+	result.AutoAuth.Method = &m
+	return nil
+}
+
+func parseSinks(result *Config, list *ast.ObjectList) error {
+	sinks := make([]*Sink, 0, len(list.Items))
+	for _, item := range list.Items {
+		key := "sink"
+		if len(item.Keys) > 0 {
+			key = item.Keys[0].Token.Value().(string)
+		}
+
+		if err := checkHCLKeys(item.Val, []string{"wrap_ttl", "config"}); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+		}
+
+		var s Sink
+		if err := hcl.DecodeObject(&s, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+		}
+		s.Type = strings.ToLower(key)
+
+		if s.WrapTTLRaw != nil {
+			var err error
+			if s.WrapTTL, err = parseutil.ParseDurationSecond(s.WrapTTLRaw); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("sink.%s:", key))
+			}
+			s.WrapTTLRaw = nil
+		}
+
+		sinks = append(sinks, &s)
+	}
+
+	result.AutoAuth.Sinks = sinks
+	return nil
+}
+
+func parseCache(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'cache' block is permitted")
+	}
+
+	item := list.Items[0]
+	if err := checkHCLKeys(item.Val, []string{"use_auto_auth_token"}); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	var c Cache
+	if err := hcl.DecodeObject(&c, item.Val); err != nil {
+		return multierror.Prefix(err, "cache:")
+	}
+
+	if c.UseAutoAuthTokenRaw != nil {
+		var err error
+		if c.UseAutoAuthToken, err = parseutil.ParseBool(c.UseAutoAuthTokenRaw); err != nil {
+			return multierror.Prefix(err, "cache:")
+		}
+		c.UseAutoAuthTokenRaw = nil
+	}
+
+	result.Cache = &c
+	return nil
+}
+
+func parseListeners(result *Config, list *ast.ObjectList) error {
+	listeners := make([]*Listener, 0, len(list.Items))
+	for _, item := range list.Items {
+		key := "listener"
+		if len(item.Keys) > 0 {
+			key = item.Keys[0].Token.Value().(string)
+		}
+
+		valid := []string{
+			"address",
+			"tls_disable",
+			"tls_cert_file",
+			"tls_key_file",
+			"tls_min_version",
+			"tls_cipher_suites",
+			"tls_prefer_server_cipher_suites",
+			"tls_require_and_verify_client_cert",
+			"tls_client_ca_file",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("listeners.%s:", key))
+		}
+
+		listeners = append(listeners, &Listener{
+			Type:   strings.ToLower(key),
+			Config: m,
+		})
+	}
+
+	result.Listeners = listeners
+	return nil
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key '%s' on line %d", key, item.Assign.Line))
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "approle",
+				MountPath: "auth/approle-custom",
+				Config: map[string]interface{}{
+					"role_id_file_path":   "/tmp/role-id",
+					"secret_id_file_path": "/tmp/secret-id",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+				&Sink{
+					Type:    "file",
+					WrapTTL: 5 * time.Minute,
+					Config: map[string]interface{}{
+						"path": "/tmp/file-bar",
+					},
+				},
+			},
+		},
+		Cache: &Cache{
+			UseAutoAuthToken: true,
+		},
+		Listeners: []*Listener{
+			&Listener{
+				Type: "tcp",
+				Config: map[string]interface{}{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": true,
+				},
+			},
+		},
+		PidFile: "./pidfile",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config, expected)
+	}
+}
+
+func TestParseConfig_defaultMountPath(t *testing.T) {
+	config, err := ParseConfig(`
+auto_auth {
+  method "cert" {
+    config = {
+      name = "web"
+    }
+  }
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if config.AutoAuth.Method.MountPath != "auth/cert" {
+		t.Fatalf("bad: %#v", config.AutoAuth.Method)
+	}
+	if config.Cache != nil || len(config.AutoAuth.Sinks) != 0 {
+		t.Fatalf("bad: %#v", config)
+	}
+}
+
+func TestParseConfig_invalid(t *testing.T) {
+	cases := map[string]string{
+		"empty": ``,
+		"unknown key": `
+auto_auth {
+  method "approle" {
+    foo = "bar"
+  }
+}
+`,
+		"no method": `
+auto_auth {
+  sink "file" {
+    config = {
+      path = "/tmp/file-foo"
+    }
+  }
+}
+`,
+		"bad wrap ttl": `
+auto_auth {
+  method "approle" {}
+
+  sink "file" {
+    wrap_ttl = "soon"
+  }
+}
+`,
+		"auto auth token without auto auth": `
+cache {
+  use_auto_auth_token = true
+}
+
+listener "tcp" {
+  address = "127.0.0.1:8300"
+}
+`,
+	}
+
+	for name, d := range cases {
+		if _, err := ParseConfig(d); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	_, err := LoadConfig("./test-fixtures/config-cache-no-listener.hcl")
+	if err == nil || !strings.Contains(err.Error(), "listener") {
+		t.Fatalf("expected listener error, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method "approle" {
+    mount_path = "auth/approle-custom/"
+
+    config = {
+      role_id_file_path   = "/tmp/role-id"
+      secret_id_file_path = "/tmp/secret-id"
+    }
+  }
+
+  sink "file" {
+    config = {
+      path = "/tmp/file-foo"
+    }
+  }
+
+  sink "file" {
+    wrap_ttl = "5m"
+
+    config = {
+      path = "/tmp/file-bar"
+    }
+  }
+}
+
+cache {
+  use_auto_auth_token = true
+}
+
+listener "tcp" {
+  address     = "127.0.0.1:8300"
+  tls_disable = true
+}
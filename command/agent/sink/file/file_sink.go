@@ -0,0 +1,86 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/vault/command/agent/sink"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// fileSink writes the token to a file
+type fileSink struct {
+	path   string
+	logger log.Logger
+}
+
+// NewFileSink returns a sink that writes the token to the file at the
+// configured path
+func NewFileSink(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	pathRaw, ok := conf.Config["path"]
+	if !ok {
+		return nil, errors.New("'path' not specified for file sink")
+	}
+	path, ok := pathRaw.(string)
+	if !ok || path == "" {
+		return nil, errors.New("could not parse 'path' as string")
+	}
+
+	f := &fileSink{
+		path:   path,
+		logger: conf.Logger,
+	}
+
+	// Make sure the file can be written before the first token arrives
+	if err := f.write(""); err != nil {
+		return nil, fmt.Errorf("error during write check: %v", err)
+	}
+
+	return f, nil
+}
+
+func (f *fileSink) WriteToken(token string) error {
+	f.logger.Trace("sink.file: writing token", "path", f.path)
+
+	if err := f.write(token); err != nil {
+		return err
+	}
+
+	f.logger.Info("sink.file: token written", "path", f.path)
+	return nil
+}
+
+// write writes the token to a temporary file next to the sink file and
+// renames it, so that readers never see a partially written token
+func (f *fileSink) write(token string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp.")
+	if err != nil {
+		return fmt.Errorf("error opening temp file in %q: %v", filepath.Dir(f.path), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0640); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error setting permissions on temp file: %v", err)
+	}
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing token to temp file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temp file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("error moving temp file to %q: %v", f.path, err)
+	}
+
+	return nil
+}
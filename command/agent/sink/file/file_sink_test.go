@@ -0,0 +1,72 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/helper/logformat"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-agent-file-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	fs, err := NewFileSink(&sink.SinkConfig{
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Config: map[string]interface{}{
+			"path": path,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.WriteToken("foobar"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != "foobar" {
+		t.Fatalf("bad: %q", raw)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("bad mode: %v", fi.Mode())
+	}
+
+	// No temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected a single file, got %d", len(files))
+	}
+}
+
+func TestFileSink_badPath(t *testing.T) {
+	_, err := NewFileSink(&sink.SinkConfig{
+		Logger: logformat.NewVaultLogger(log.LevelTrace),
+		Config: map[string]interface{}{
+			"path": "/nonexistent/dir/token",
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// Sink is a location the agent writes its token to
+type Sink interface {
+	WriteToken(string) error
+}
+
+// SinkConfig is a Sink along with the settings that apply to any sink
+type SinkConfig struct {
+	Sink
+	Logger  log.Logger
+	Config  map[string]interface{}
+	Client  *api.Client
+	WrapTTL time.Duration
+}
+
+// SinkServer writes the tokens it receives to all of its sinks
+type SinkServer struct {
+	logger log.Logger
+}
+
+// SinkServerConfig is the configuration of a SinkServer
+type SinkServerConfig struct {
+	Logger log.Logger
+}
+
+// NewSinkServer returns a new SinkServer
+func NewSinkServer(conf *SinkServerConfig) *SinkServer {
+	return &SinkServer{
+		logger: conf.Logger,
+	}
+}
+
+// Run writes every token received on the incoming channel to the sinks until
+// the shutdown channel is closed. A sink that fails to be written is retried
+// until it succeeds or a new token is received.
+func (ss *SinkServer) Run(shutdownCh <-chan struct{}, incoming <-chan string, sinks []*SinkConfig) {
+	if incoming == nil {
+		panic("incoming channel is nil")
+	}
+
+	ss.logger.Info("sink.server: starting")
+	defer ss.logger.Info("sink.server: shutting down")
+
+	var token string
+	var pending []*SinkConfig
+	var retryCh <-chan time.Time
+
+	for {
+		select {
+		case <-shutdownCh:
+			return
+
+		case token = <-incoming:
+			pending = sinks
+
+		case <-retryCh:
+		}
+
+		var failed []*SinkConfig
+		for _, sc := range pending {
+			if err := sc.writeToken(token); err != nil {
+				ss.logger.Error("sink.server: error writing token to sink", "error", err)
+				failed = append(failed, sc)
+			}
+		}
+		pending = failed
+
+		retryCh = nil
+		if len(pending) > 0 {
+			retryCh = time.After(time.Second)
+		}
+	}
+}
+
+// writeToken writes the token to the sink, wrapping it first if the sink is
+// configured to do so
+func (sc *SinkConfig) writeToken(token string) error {
+	if sc.WrapTTL == 0 {
+		return sc.WriteToken(token)
+	}
+
+	wrapped, err := sc.wrapToken(token)
+	if err != nil {
+		return fmt.Errorf("error wrapping token: %v", err)
+	}
+	return sc.WriteToken(wrapped)
+}
+
+// wrapToken response-wraps the token using the token itself, and returns
+// the wrapping information as JSON
+func (sc *SinkConfig) wrapToken(token string) (string, error) {
+	if sc.Client == nil {
+		return "", errors.New("no client to wrap the token with")
+	}
+
+	client, err := sc.Client.CloneWithState()
+	if err != nil {
+		return "", err
+	}
+	client.SetToken(token)
+	client.SetWrappingLookupFunc(func(string, string) string {
+		return sc.WrapTTL.String()
+	})
+
+	secret, err := client.Logical().Write("sys/wrapping/wrap", map[string]interface{}{
+		"token": token,
+	})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		return "", errors.New("no wrapping information returned")
+	}
+
+	raw, err := json.Marshal(secret.WrapInfo)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
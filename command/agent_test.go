@@ -0,0 +1,220 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	credAppRole "github.com/hashicorp/vault/builtin/credential/approle"
+	"github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/meta"
+	"github.com/hashicorp/vault/vault"
+	"github.com/mitchellh/cli"
+)
+
+// testAgentWaitForFile waits until the file at the given path is not empty
+// and returns its contents
+func testAgentWaitForFile(t *testing.T, path string) string {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		raw, err := ioutil.ReadFile(path)
+		if err == nil && len(raw) > 0 {
+			return string(raw)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", path)
+	return ""
+}
+
+// testAgentFreePort returns a port that is free to listen on
+func testAgentFreePort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestAgent(t *testing.T) {
+	if err := vault.AddTestCredentialBackend("approle", credAppRole.Factory); err != nil {
+		t.Fatal(err)
+	}
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := http.TestServer(t, core)
+	defer ln.Close()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(token)
+
+	if err := client.Sys().EnableAuth("approle", "approle", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("auth/approle/role/agent", map[string]interface{}{
+		"policies": "default",
+		"period":   "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.Logical().Read("auth/approle/role/agent/role-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleID := secret.Data["role_id"].(string)
+	secret, err = client.Logical().Write("auth/approle/role/agent/secret-id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretID := secret.Data["secret_id"].(string)
+
+	dir, err := ioutil.TempDir("", "vault-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	roleIDPath := filepath.Join(dir, "role-id")
+	secretIDPath := filepath.Join(dir, "secret-id")
+	tokenPath := filepath.Join(dir, "token")
+	wrappedPath := filepath.Join(dir, "wrapped")
+	configPath := filepath.Join(dir, "agent.hcl")
+	if err := ioutil.WriteFile(roleIDPath, []byte(roleID), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(secretIDPath, []byte(secretID), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listenAddr := "127.0.0.1:" + testAgentFreePort(t)
+	if err := ioutil.WriteFile(configPath, []byte(fmt.Sprintf(`
+auto_auth {
+  method "approle" {
+    config = {
+      role_id_file_path   = %q
+      secret_id_file_path = %q
+    }
+  }
+
+  sink "file" {
+    config = {
+      path = %q
+    }
+  }
+
+  sink "file" {
+    wrap_ttl = "5m"
+
+    config = {
+      path = %q
+    }
+  }
+}
+
+cache {
+  use_auto_auth_token = true
+}
+
+listener "tcp" {
+  address     = %q
+  tls_disable = true
+}
+`, roleIDPath, secretIDPath, tokenPath, wrappedPath, listenAddr)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ui := new(cli.MockUi)
+	shutdownCh := make(chan struct{})
+	c := &AgentCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+		ShutdownCh: shutdownCh,
+	}
+
+	exitCh := make(chan int)
+	go func() {
+		exitCh <- c.Run([]string{"-address", addr, "-config", configPath})
+	}()
+
+	// The token is written to the sink and belongs to the role
+	agentToken := testAgentWaitForFile(t, tokenPath)
+	secret, err = client.Auth().Token().Lookup(agentToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["meta"].(map[string]interface{})["role_name"] != "agent" {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	// The wrapped sink gets a wrapping token for the token
+	var wrapInfo api.SecretWrapInfo
+	if err := json.Unmarshal([]byte(testAgentWaitForFile(t, wrappedPath)), &wrapInfo); err != nil {
+		t.Fatal(err)
+	}
+	secret, err = client.Logical().Unwrap(wrapInfo.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["token"] != agentToken {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	// Requests without a token made through the agent use its token
+	config = api.DefaultConfig()
+	config.Address = "http://" + listenAddr
+	agentClient, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentClient.ClearToken()
+	secret, err = agentClient.Auth().Token().LookupSelf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["id"] != agentToken {
+		t.Fatalf("bad: %#v", secret.Data)
+	}
+
+	close(shutdownCh)
+	select {
+	case code := <-exitCh:
+		if code != 0 {
+			t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the agent to shut down")
+	}
+}
+
+func TestAgent_badConfig(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &AgentCommand{
+		Meta: meta.Meta{
+			Ui: ui,
+		},
+		ShutdownCh: make(chan struct{}),
+	}
+
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if code := c.Run([]string{"-config", "/nonexistent/agent.hcl"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
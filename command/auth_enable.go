@@ -122,6 +122,8 @@ func (c *AuthEnableCommand) AutocompleteArgs() complete.Predictor {
 		"app-id",
 		"gcp",
 		"github",
+		"jwt",
+		"kubernetes",
 		"userpass",
 		"ldap",
 		"okta",
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/flag-kv"
 	"github.com/hashicorp/vault/meta"
 	"github.com/posener/complete"
 )
@@ -17,6 +18,7 @@ type MountCommand struct {
 func (c *MountCommand) Run(args []string) int {
 	var description, path, defaultLeaseTTL, maxLeaseTTL, pluginName string
 	var local, forceNoCache bool
+	var options map[string]string
 	flags := c.Meta.FlagSet("mount", meta.FlagSetDefault)
 	flags.StringVar(&description, "description", "", "")
 	flags.StringVar(&path, "path", "", "")
@@ -25,6 +27,7 @@ func (c *MountCommand) Run(args []string) int {
 	flags.StringVar(&pluginName, "plugin-name", "", "")
 	flags.BoolVar(&forceNoCache, "force-no-cache", false, "")
 	flags.BoolVar(&local, "local", false, "")
+	flags.Var((*kvFlag.Flag)(&options), "options", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -66,7 +69,8 @@ func (c *MountCommand) Run(args []string) int {
 			ForceNoCache:    forceNoCache,
 			PluginName:      pluginName,
 		},
-		Local: local,
+		Local:   local,
+		Options: options,
 	}
 
 	if err := client.Sys().Mount(path, mountInfo); err != nil {
@@ -131,6 +135,11 @@ Mount Options:
   -local                         Mark the mount as a local mount. Local mounts
                                  are not replicated nor (if a secondary)
                                  removed by replication.
+
+  -options="key=value"           Options to pass to the backend. This can be
+                                 specified multiple times. For example,
+                                 -options="version=2" creates a versioned kv
+                                 mount.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -142,6 +151,7 @@ func (c *MountCommand) AutocompleteArgs() complete.Predictor {
 		"consul",
 		"pki",
 		"transit",
+		"transform",
 		"ssh",
 		"rabbitmq",
 		"database",
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/flag-kv"
 	"github.com/hashicorp/vault/meta"
 )
 
@@ -16,9 +17,11 @@ type MountTuneCommand struct {
 
 func (c *MountTuneCommand) Run(args []string) int {
 	var defaultLeaseTTL, maxLeaseTTL string
+	var options map[string]string
 	flags := c.Meta.FlagSet("mount-tune", meta.FlagSetDefault)
 	flags.StringVar(&defaultLeaseTTL, "default-lease-ttl", "", "")
 	flags.StringVar(&maxLeaseTTL, "max-lease-ttl", "", "")
+	flags.Var((*kvFlag.Flag)(&options), "options", "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -37,6 +40,7 @@ func (c *MountTuneCommand) Run(args []string) int {
 	mountConfig := api.MountConfigInput{
 		DefaultLeaseTTL: defaultLeaseTTL,
 		MaxLeaseTTL:     maxLeaseTTL,
+		Options:         options,
 	}
 
 	client, err := c.Client()
@@ -84,6 +88,12 @@ Mount Options:
                                  the previously set value. Set to 'system' to
                                  explicitly set it to use the system default.
 
+  -options="key=value"           Options to pass to the backend. This can be
+                                 specified multiple times. Setting
+                                 -options="version=2" on a kv mount upgrades it
+                                 to a versioned mount, keeping existing secrets
+                                 as their first version.
+
 `
 	return strings.TrimSpace(helpText)
 }
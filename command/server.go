@@ -229,7 +229,11 @@ func (c *ServerCommand) Run(args []string) int {
 	infoKeys := make([]string, 0, 10)
 	info := make(map[string]string)
 
-	var seal vault.Seal = &vault.DefaultSeal{}
+	seal, migrationSeal, err := configureSeal(config, &infoKeys, info, c.logger)
+	if err != nil {
+		c.Ui.Output(err.Error())
+		return 1
+	}
 
 	// Ensure that the seal finalizer is called, even if using verify-only
 	defer func() {
@@ -239,6 +243,12 @@ func (c *ServerCommand) Run(args []string) int {
 				c.Ui.Error(fmt.Sprintf("Error finalizing seals: %v", err))
 			}
 		}
+		if migrationSeal != nil {
+			err = migrationSeal.Finalize()
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error finalizing seals: %v", err))
+			}
+		}
 	}()
 
 	if seal == nil {
@@ -251,6 +261,7 @@ func (c *ServerCommand) Run(args []string) int {
 		RedirectAddr:       config.Storage.RedirectAddr,
 		HAPhysical:         nil,
 		Seal:               seal,
+		MigrationSeal:      migrationSeal,
 		AuditBackends:      c.AuditBackends,
 		CredentialBackends: c.CredentialBackends,
 		LogicalBackends:    c.LogicalBackends,
@@ -435,6 +446,11 @@ CLUSTER_SYNTHESIS_COMPLETE:
 		mlock.Supported(), !config.DisableMlock && mlock.Supported())
 	infoKeys = append(infoKeys, "log level", "mlock", "storage")
 
+	if core.IsInSealMigration() {
+		info["seal migration"] = fmt.Sprintf("%s -> %s", migrationSeal.BarrierType(), seal.BarrierType())
+		infoKeys = append(infoKeys, "seal migration")
+	}
+
 	if coreConfig.ClusterAddr != "" {
 		info["cluster address"] = coreConfig.ClusterAddr
 		infoKeys = append(infoKeys, "cluster address")
@@ -607,6 +623,12 @@ CLUSTER_SYNTHESIS_COMPLETE:
 		}
 	}
 
+	// If the seal can unseal Vault on its own, do so in the background so
+	// that an unavailable key management system does not block startup
+	if !dev && core.SealAccess().StoredKeysSupported() {
+		go c.autoUnseal(core)
+	}
+
 	// If we're in Dev mode, then initialize the core
 	if dev && !devSkipInit {
 		init, err := c.enableDev(core, coreConfig)
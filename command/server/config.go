@@ -24,7 +24,8 @@ type Config struct {
 	Storage   *Storage    `hcl:"-"`
 	HAStorage *Storage    `hcl:"-"`
 
-	HSM *HSM `hcl:"-"`
+	HSM  *HSM  `hcl:"-"`
+	Seal *Seal `hcl:"-"`
 
 	CacheSize       int         `hcl:"cache_size"`
 	DisableCache    bool        `hcl:"-"`
@@ -125,6 +126,20 @@ func (h *HSM) GoString() string {
 	return fmt.Sprintf("*%#v", *h)
 }
 
+// Seal contains the auto-unseal configuration for the server
+type Seal struct {
+	Type string
+
+	// Disabled marks a seal that is only used to migrate away from
+	Disabled bool
+
+	Config map[string]string
+}
+
+func (s *Seal) GoString() string {
+	return fmt.Sprintf("*%#v", *s)
+}
+
 // Telemetry is the telemetry configuration for the server
 type Telemetry struct {
 	StatsiteAddr string `hcl:"statsite_address"`
@@ -246,6 +261,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.HSM = c2.HSM
 	}
 
+	result.Seal = c.Seal
+	if c2.Seal != nil {
+		result.Seal = c2.Seal
+	}
+
 	result.Telemetry = c.Telemetry
 	if c2.Telemetry != nil {
 		result.Telemetry = c2.Telemetry
@@ -394,6 +414,7 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		"backend",
 		"ha_backend",
 		"hsm",
+		"seal",
 		"listener",
 		"cache_size",
 		"disable_cache",
@@ -443,6 +464,12 @@ func ParseConfig(d string, logger log.Logger) (*Config, error) {
 		}
 	}
 
+	if o := list.Filter("seal"); len(o.Items) > 0 {
+		if err := parseSeal(&result, o); err != nil {
+			return nil, fmt.Errorf("error parsing 'seal': %s", err)
+		}
+	}
+
 	if o := list.Filter("listener"); len(o.Items) > 0 {
 		if err := parseListeners(&result, o); err != nil {
 			return nil, fmt.Errorf("error parsing 'listener': %s", err)
@@ -684,6 +711,70 @@ func parseHSMs(result *Config, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseSeal(result *Config, list *ast.ObjectList) error {
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'seal' block is permitted")
+	}
+
+	// Get our item
+	item := list.Items[0]
+
+	key := "seal"
+	if len(item.Keys) > 0 {
+		key = item.Keys[0].Token.Value().(string)
+	}
+
+	valid := []string{"disabled"}
+	switch strings.ToLower(key) {
+	case "transit":
+		valid = append(valid,
+			"address",
+			"token",
+			"mount_path",
+			"key_name",
+			"tls_ca_cert",
+			"tls_client_cert",
+			"tls_client_key",
+			"tls_server_name",
+			"tls_skip_verify",
+		)
+	case "file":
+		valid = append(valid,
+			"path",
+			"key_label",
+			"generate_key",
+		)
+	default:
+		return fmt.Errorf("invalid seal type %q", key)
+	}
+	if err := checkHCLKeys(item.Val, valid); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+	}
+
+	var m map[string]string
+	if err := hcl.DecodeObject(&m, item.Val); err != nil {
+		return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+	}
+
+	var disabled bool
+	if v, ok := m["disabled"]; ok {
+		var err error
+		disabled, err = strconv.ParseBool(v)
+		if err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("seal.%s:", key))
+		}
+		delete(m, "disabled")
+	}
+
+	result.Seal = &Seal{
+		Type:     strings.ToLower(key),
+		Disabled: disabled,
+		Config:   m,
+	}
+
+	return nil
+}
+
 func parseListeners(result *Config, list *ast.ObjectList) error {
 	listeners := make([]*Listener, 0, len(list.Items))
 	for _, item := range list.Items {
@@ -47,6 +47,15 @@ func TestLoadConfigFile(t *testing.T) {
 			DisableClustering: true,
 		},
 
+		Seal: &Seal{
+			Type: "transit",
+			Config: map[string]string{
+				"address":    "https://vault.example.com:8200",
+				"key_name":   "autounseal",
+				"mount_path": "transit/",
+			},
+		},
+
 		Telemetry: &Telemetry{
 			StatsdAddr:      "bar",
 			StatsiteAddr:    "foo",
@@ -380,3 +389,71 @@ telemetry {
 		t.Errorf("bad error: %q", err)
 	}
 }
+
+func TestParseConfig_badSeal(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	_, err := ParseConfig(strings.TrimSpace(`
+seal "file" {
+	path = "/tmp/seal"
+	bad  = "one"
+}
+`), logger)
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "seal.file: invalid key 'bad' on line 3") {
+		t.Errorf("bad error: %q", err)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+seal "unknown" {
+	path = "/tmp/seal"
+}
+`), logger)
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !strings.Contains(err.Error(), `invalid seal type "unknown"`) {
+		t.Errorf("bad error: %q", err)
+	}
+}
+
+func TestParseConfig_sealDisabled(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	config, err := ParseConfig(strings.TrimSpace(`
+seal "file" {
+	path     = "/tmp/seal"
+	disabled = "true"
+}
+`), logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Seal{
+		Type:     "file",
+		Disabled: true,
+		Config: map[string]string{
+			"path": "/tmp/seal",
+		},
+	}
+	if !reflect.DeepEqual(config.Seal, expected) {
+		t.Fatalf("expected \n\n%#v\n\n to be \n\n%#v\n\n", config.Seal, expected)
+	}
+
+	_, err = ParseConfig(strings.TrimSpace(`
+seal "file" {
+	path     = "/tmp/seal"
+	disabled = "maybe"
+}
+`), logger)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -18,6 +18,12 @@ ha_backend "consul" {
     disable_clustering = "true"
 }
 
+seal "transit" {
+    address = "https://vault.example.com:8200"
+    key_name = "autounseal"
+    mount_path = "transit/"
+}
+
 telemetry {
     statsd_address = "bar"
     statsite_address = "foo"
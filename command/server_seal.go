@@ -0,0 +1,96 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal"
+	"github.com/hashicorp/vault/vault/seal/file"
+	"github.com/hashicorp/vault/vault/seal/transit"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// autoUnsealRetryInterval is how long the server waits between attempts to
+// unseal itself with the stored keys
+var autoUnsealRetryInterval = 5 * time.Second
+
+// configureSeal returns the seal described by the server configuration,
+// adding information about it to the server info. If no seal is configured,
+// the default Shamir seal is returned.
+//
+// The second seal returned is the seal to migrate from, if the stored data
+// turns out to still be protected by it: the default seal if an auto-unseal
+// seal is configured, and the configured seal if it is disabled.
+func configureSeal(config *server.Config, infoKeys *[]string, info map[string]string, logger log.Logger) (vault.Seal, vault.Seal, error) {
+	if config.Seal == nil {
+		return &vault.DefaultSeal{}, nil, nil
+	}
+
+	var access interface {
+		seal.Access
+		SetConfig(map[string]string) (map[string]string, error)
+	}
+	switch config.Seal.Type {
+	case seal.Transit:
+		access = transit.NewSeal(logger)
+	case seal.File:
+		access = file.NewSeal(logger)
+	default:
+		return nil, nil, fmt.Errorf("unknown seal type %q", config.Seal.Type)
+	}
+
+	sealInfo, err := access.SetConfig(config.Seal.Config)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf(fmt.Sprintf("error configuring %s seal: {{err}}", config.Seal.Type), err)
+	}
+
+	if err := access.Init(); err != nil {
+		return nil, nil, errwrap.Wrapf(fmt.Sprintf("error initializing %s seal: {{err}}", config.Seal.Type), err)
+	}
+
+	props := make([]string, 0, len(sealInfo)+1)
+	for k, v := range sealInfo {
+		props = append(props, fmt.Sprintf("%s: %q", k, v))
+	}
+	if config.Seal.Disabled {
+		props = append(props, "disabled: true")
+	}
+	sort.Strings(props)
+	*infoKeys = append(*infoKeys, "seal")
+	info["seal"] = fmt.Sprintf("%s (%s)", config.Seal.Type, strings.Join(props, ", "))
+
+	if config.Seal.Disabled {
+		return &vault.DefaultSeal{}, vault.NewAutoSeal(access), nil
+	}
+	return vault.NewAutoSeal(access), &vault.DefaultSeal{}, nil
+}
+
+// autoUnseal unseals the core using the keys stored by the seal. Failures
+// to reach the key management system are retried until the core is unsealed
+// or the server shuts down. While a seal migration is in progress, possibly
+// performed by another server, no attempt is made.
+func (c *ServerCommand) autoUnseal(core *vault.Core) {
+	for {
+		if !core.IsInSealMigration() {
+			err := core.UnsealWithStoredKeys()
+			if err == nil {
+				return
+			}
+			if !errwrap.ContainsType(err, new(vault.NonFatalError)) {
+				c.logger.Error("core: auto-unseal failed", "error", err)
+				return
+			}
+		}
+
+		select {
+		case <-c.ShutdownCh:
+			return
+		case <-time.After(autoUnsealRetryInterval):
+		}
+	}
+}
@@ -36,12 +36,14 @@ func (c *StatusCommand) Run(args []string) int {
 	}
 
 	outStr := fmt.Sprintf(
-		"Sealed: %v\n"+
+		"Seal Type: %s\n"+
+			"Sealed: %v\n"+
 			"Key Shares: %d\n"+
 			"Key Threshold: %d\n"+
 			"Unseal Progress: %d\n"+
 			"Unseal Nonce: %v\n"+
 			"Version: %s",
+		sealStatus.Type,
 		sealStatus.Sealed,
 		sealStatus.N,
 		sealStatus.T,
@@ -53,6 +55,14 @@ func (c *StatusCommand) Run(args []string) int {
 		outStr = fmt.Sprintf("%s\nCluster Name: %s\nCluster ID: %s", outStr, sealStatus.ClusterName, sealStatus.ClusterID)
 	}
 
+	if sealStatus.Migration {
+		outStr = fmt.Sprintf("%s\nSeal Migration in Progress: %v", outStr, sealStatus.Migration)
+	}
+
+	if sealStatus.RecoverySeal {
+		outStr = fmt.Sprintf("%s\nRecovery Seal: %v", outStr, sealStatus.RecoverySeal)
+	}
+
 	c.Ui.Output(outStr)
 
 	// Mask the 'Vault is sealed' error, since this means HA is enabled,
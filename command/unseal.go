@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/password"
 	"github.com/hashicorp/vault/meta"
 )
@@ -19,9 +20,10 @@ type UnsealCommand struct {
 }
 
 func (c *UnsealCommand) Run(args []string) int {
-	var reset bool
+	var reset, migrate bool
 	flags := c.Meta.FlagSet("unseal", meta.FlagSetDefault)
 	flags.BoolVar(&reset, "reset", false, "")
+	flags.BoolVar(&migrate, "migrate", false, "")
 	flags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := flags.Parse(args); err != nil {
 		return 1
@@ -71,7 +73,10 @@ func (c *UnsealCommand) Run(args []string) int {
 				return 1
 			}
 		}
-		sealStatus, err = client.Sys().Unseal(strings.TrimSpace(value))
+		sealStatus, err = client.Sys().UnsealWithOptions(&api.UnsealOpts{
+			Key:     strings.TrimSpace(value),
+			Migrate: migrate,
+		})
 	}
 
 	if err != nil {
@@ -80,7 +85,7 @@ func (c *UnsealCommand) Run(args []string) int {
 		return 1
 	}
 
-	c.Ui.Output(fmt.Sprintf(
+	outStr := fmt.Sprintf(
 		"Sealed: %v\n"+
 			"Key Shares: %d\n"+
 			"Key Threshold: %d\n"+
@@ -91,7 +96,11 @@ func (c *UnsealCommand) Run(args []string) int {
 		sealStatus.T,
 		sealStatus.Progress,
 		sealStatus.Nonce,
-	))
+	)
+	if sealStatus.Migration {
+		outStr = fmt.Sprintf("%s\nSeal Migration in Progress: %v", outStr, sealStatus.Migration)
+	}
+	c.Ui.Output(outStr)
 
 	return 0
 }
@@ -123,6 +132,13 @@ Unseal Options:
   -reset                  Reset the unsealing process by throwing away
                           prior keys in process to unseal the vault.
 
+  -migrate                Provide the key as part of a seal migration. While
+                          migrating, the unseal keys of the old seal are
+                          entered when moving to an auto-unseal seal, and the
+                          recovery keys when moving back to Shamir. Once
+                          enough keys are entered, the stored keys are moved
+                          to the new seal and the vault is unsealed.
+
 `
 	return strings.TrimSpace(helpText)
 }
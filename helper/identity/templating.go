@@ -0,0 +1,265 @@
+package identity
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnbalancedTemplatingCharacter is returned when a string has an
+	// opening "{{" without a matching "}}", or the other way around
+	ErrUnbalancedTemplatingCharacter = errors.New("unbalanced templating characters")
+
+	// ErrNoEntityAttachedToToken is returned when a string is templated but
+	// there is no entity to resolve it with
+	ErrNoEntityAttachedToToken = errors.New("string contains entity template directives but no entity was provided")
+
+	// ErrNoGroupsAttachedToToken is returned when a string uses group
+	// templates but there are no groups to resolve them with
+	ErrNoGroupsAttachedToToken = errors.New("string contains groups template directives but no groups were provided")
+
+	// ErrTemplateValueNotFound is returned when a template refers to a value
+	// that the entity or its groups don't have
+	ErrTemplateValueNotFound = errors.New("no value could be found for one of the template directives")
+)
+
+// PopulateStringInput holds the string to template and the identity
+// information used to resolve its templates
+type PopulateStringInput struct {
+	// ValidityCheckOnly only checks that the templates in the string are
+	// well formed, without resolving them
+	ValidityCheckOnly bool
+
+	String string
+	Entity *Entity
+	Groups []*Group
+}
+
+// PopulateString replaces the templates of the form {{identity.<...>}} in the
+// input string with values taken from the entity and groups. It returns
+// whether the string contained any templates, along with the resulting
+// string.
+//
+// The supported templates are:
+//
+//	identity.entity.id
+//	identity.entity.name
+//	identity.entity.metadata.<key>
+//	identity.entity.aliases.<mount accessor>.id
+//	identity.entity.aliases.<mount accessor>.name
+//	identity.entity.aliases.<mount accessor>.metadata.<key>
+//	identity.groups.ids.<group id>.name
+//	identity.groups.ids.<group id>.metadata.<key>
+//	identity.groups.names.<group name>.id
+//	identity.groups.names.<group name>.metadata.<key>
+func PopulateString(p *PopulateStringInput) (bool, string, error) {
+	if p == nil {
+		return false, "", errors.New("nil input")
+	}
+
+	if p.String == "" {
+		return false, "", nil
+	}
+
+	var subst bool
+	splitStr := strings.Split(p.String, "{{")
+
+	if len(splitStr) == 1 {
+		if strings.Contains(p.String, "}}") {
+			return false, "", ErrUnbalancedTemplatingCharacter
+		}
+		return false, p.String, nil
+	}
+
+	var b bytes.Buffer
+
+	for i, str := range splitStr {
+		if i == 0 {
+			if strings.Contains(str, "}}") {
+				return false, "", ErrUnbalancedTemplatingCharacter
+			}
+			if !p.ValidityCheckOnly {
+				b.WriteString(str)
+			}
+			continue
+		}
+
+		splitPiece := strings.Split(str, "}}")
+		if len(splitPiece) != 2 {
+			return false, "", ErrUnbalancedTemplatingCharacter
+		}
+
+		subst = true
+		if p.ValidityCheckOnly {
+			if err := validateTemplate(splitPiece[0]); err != nil {
+				return false, "", err
+			}
+			continue
+		}
+
+		tmplStr, err := performTemplating(strings.TrimSpace(splitPiece[0]), p.Entity, p.Groups)
+		if err != nil {
+			return false, "", err
+		}
+		b.WriteString(tmplStr)
+		b.WriteString(splitPiece[1])
+	}
+
+	if p.ValidityCheckOnly {
+		return subst, p.String, nil
+	}
+	return subst, b.String(), nil
+}
+
+// validateTemplate checks that the template refers to a known kind of value
+func validateTemplate(input string) error {
+	input = strings.TrimSpace(input)
+	switch {
+	case input == "identity.entity.id",
+		input == "identity.entity.name",
+		strings.HasPrefix(input, "identity.entity.metadata."),
+		strings.HasPrefix(input, "identity.entity.aliases."),
+		strings.HasPrefix(input, "identity.groups.ids."),
+		strings.HasPrefix(input, "identity.groups.names."):
+		return nil
+	}
+	return fmt.Errorf("invalid template directive %q", input)
+}
+
+func performTemplating(input string, entity *Entity, groups []*Group) (string, error) {
+	if err := validateTemplate(input); err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(input, "identity.entity."):
+		if entity == nil {
+			return "", ErrNoEntityAttachedToToken
+		}
+		return performEntityTemplating(strings.TrimPrefix(input, "identity.entity."), entity)
+
+	default:
+		if len(groups) == 0 {
+			return "", ErrNoGroupsAttachedToToken
+		}
+		return performGroupsTemplating(strings.TrimPrefix(input, "identity.groups."), groups)
+	}
+}
+
+func performEntityTemplating(trimmed string, entity *Entity) (string, error) {
+	switch {
+	case trimmed == "id":
+		return entity.ID, nil
+
+	case trimmed == "name":
+		if entity.Name == "" {
+			return "", ErrTemplateValueNotFound
+		}
+		return entity.Name, nil
+
+	case strings.HasPrefix(trimmed, "metadata."):
+		val, ok := entity.Metadata[strings.TrimPrefix(trimmed, "metadata.")]
+		if !ok {
+			return "", ErrTemplateValueNotFound
+		}
+		return val, nil
+
+	case strings.HasPrefix(trimmed, "aliases."):
+		// The mount accessor is followed by the name of the value
+		split := strings.SplitN(strings.TrimPrefix(trimmed, "aliases."), ".", 2)
+		if len(split) != 2 {
+			return "", errors.New("invalid alias selector")
+		}
+
+		var alias *Alias
+		for _, a := range entity.Aliases {
+			if a.MountAccessor == split[0] {
+				alias = a
+				break
+			}
+		}
+		if alias == nil {
+			return "", ErrTemplateValueNotFound
+		}
+
+		switch {
+		case split[1] == "id":
+			return alias.ID, nil
+		case split[1] == "name":
+			if alias.Name == "" {
+				return "", ErrTemplateValueNotFound
+			}
+			return alias.Name, nil
+		case strings.HasPrefix(split[1], "metadata."):
+			val, ok := alias.Metadata[strings.TrimPrefix(split[1], "metadata.")]
+			if !ok {
+				return "", ErrTemplateValueNotFound
+			}
+			return val, nil
+		}
+
+		return "", errors.New("invalid alias selector")
+	}
+
+	return "", ErrTemplateValueNotFound
+}
+
+func performGroupsTemplating(trimmed string, groups []*Group) (string, error) {
+	var ids bool
+
+	selectorSplit := strings.SplitN(trimmed, ".", 2)
+	switch {
+	case len(selectorSplit) != 2:
+		return "", errors.New("invalid groups selector")
+	case selectorSplit[0] == "ids":
+		ids = true
+	case selectorSplit[0] == "names":
+	default:
+		return "", errors.New("invalid groups selector")
+	}
+	trimmed = selectorSplit[1]
+
+	// The group ID or name is followed by the name of the value
+	accessorSplit := strings.SplitN(trimmed, ".", 2)
+	if len(accessorSplit) != 2 {
+		return "", errors.New("invalid groups accessor")
+	}
+
+	var found *Group
+	for _, group := range groups {
+		compare := group.Name
+		if ids {
+			compare = group.ID
+		}
+		if compare == accessorSplit[0] {
+			found = group
+			break
+		}
+	}
+	if found == nil {
+		return "", ErrTemplateValueNotFound
+	}
+
+	trimmed = accessorSplit[1]
+	switch {
+	case ids && trimmed == "name":
+		if found.Name == "" {
+			return "", ErrTemplateValueNotFound
+		}
+		return found.Name, nil
+
+	case !ids && trimmed == "id":
+		return found.ID, nil
+
+	case strings.HasPrefix(trimmed, "metadata."):
+		val, ok := found.Metadata[strings.TrimPrefix(trimmed, "metadata.")]
+		if !ok {
+			return "", ErrTemplateValueNotFound
+		}
+		return val, nil
+	}
+
+	return "", ErrTemplateValueNotFound
+}
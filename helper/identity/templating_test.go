@@ -0,0 +1,186 @@
+package identity
+
+import (
+	"testing"
+)
+
+func TestPopulateString(t *testing.T) {
+	entity := &Entity{
+		ID:   "entityID",
+		Name: "entityName",
+		Metadata: map[string]string{
+			"foo": "bar",
+		},
+		Aliases: []*Alias{
+			&Alias{
+				ID:            "aliasID",
+				MountAccessor: "aliasAccessor",
+				Name:          "aliasName",
+				Metadata: map[string]string{
+					"zip": "zap",
+				},
+			},
+		},
+	}
+	groups := []*Group{
+		&Group{
+			ID:   "groupID",
+			Name: "groupName",
+			Metadata: map[string]string{
+				"group": "meta",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		noEntity  bool
+		noGroups  bool
+		output    string
+		templated bool
+		err       error
+	}{
+		{
+			name:   "no templating",
+			input:  "path/to/secret",
+			output: "path/to/secret",
+		},
+		{
+			name:  "unbalanced opening",
+			input: "path/{{identity.entity.id",
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:  "unbalanced closing",
+			input: "path/identity.entity.id}}",
+			err:   ErrUnbalancedTemplatingCharacter,
+		},
+		{
+			name:      "entity id",
+			input:     "path/{{identity.entity.id}}/foo",
+			output:    "path/entityID/foo",
+			templated: true,
+		},
+		{
+			name:      "entity name with spaces",
+			input:     "path/{{ identity.entity.name }}",
+			output:    "path/entityName",
+			templated: true,
+		},
+		{
+			name:      "entity metadata",
+			input:     "{{identity.entity.metadata.foo}}",
+			output:    "bar",
+			templated: true,
+		},
+		{
+			name:  "missing entity metadata",
+			input: "{{identity.entity.metadata.missing}}",
+			err:   ErrTemplateValueNotFound,
+		},
+		{
+			name:      "alias name",
+			input:     "path/{{identity.entity.aliases.aliasAccessor.name}}",
+			output:    "path/aliasName",
+			templated: true,
+		},
+		{
+			name:      "alias id and metadata",
+			input:     "{{identity.entity.aliases.aliasAccessor.id}}/{{identity.entity.aliases.aliasAccessor.metadata.zip}}",
+			output:    "aliasID/zap",
+			templated: true,
+		},
+		{
+			name:  "unknown alias accessor",
+			input: "{{identity.entity.aliases.other.name}}",
+			err:   ErrTemplateValueNotFound,
+		},
+		{
+			name:      "group id by name",
+			input:     "{{identity.groups.names.groupName.id}}",
+			output:    "groupID",
+			templated: true,
+		},
+		{
+			name:      "group name by id",
+			input:     "{{identity.groups.ids.groupID.name}}",
+			output:    "groupName",
+			templated: true,
+		},
+		{
+			name:      "group metadata",
+			input:     "{{identity.groups.names.groupName.metadata.group}}",
+			output:    "meta",
+			templated: true,
+		},
+		{
+			name:  "unknown group",
+			input: "{{identity.groups.names.other.id}}",
+			err:   ErrTemplateValueNotFound,
+		},
+		{
+			name:     "no entity",
+			input:    "{{identity.entity.id}}",
+			noEntity: true,
+			err:      ErrNoEntityAttachedToToken,
+		},
+		{
+			name:     "no groups",
+			input:    "{{identity.groups.names.groupName.id}}",
+			noGroups: true,
+			err:      ErrNoGroupsAttachedToToken,
+		},
+	}
+
+	for _, test := range tests {
+		input := &PopulateStringInput{
+			String: test.input,
+			Entity: entity,
+			Groups: groups,
+		}
+		if test.noEntity {
+			input.Entity = nil
+		}
+		if test.noGroups {
+			input.Groups = nil
+		}
+
+		templated, output, err := PopulateString(input)
+		if test.err != nil {
+			if err != test.err {
+				t.Fatalf("%s: expected error %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if templated != test.templated {
+			t.Fatalf("%s: expected templated to be %t", test.name, test.templated)
+		}
+		if output != test.output {
+			t.Fatalf("%s: expected %q, got %q", test.name, test.output, output)
+		}
+	}
+}
+
+func TestPopulateString_ValidityCheckOnly(t *testing.T) {
+	templated, output, err := PopulateString(&PopulateStringInput{
+		ValidityCheckOnly: true,
+		String:            "path/{{identity.entity.name}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !templated || output != "path/{{identity.entity.name}}" {
+		t.Fatalf("bad: %t %q", templated, output)
+	}
+
+	if _, _, err := PopulateString(&PopulateStringInput{
+		ValidityCheckOnly: true,
+		String:            "path/{{identity.other}}",
+	}); err == nil {
+		t.Fatal("expected an error for an invalid template")
+	}
+}
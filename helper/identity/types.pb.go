@@ -59,6 +59,14 @@ type Group struct {
 	// the groups belonging to a particular bucket during invalidation of the
 	// storage key.
 	BucketKeyHash string `protobuf:"bytes,10,opt,name=bucket_key_hash,json=bucketKeyHash" json:"bucket_key_hash,omitempty"`
+	// Alias is used to mark this group as an external group and to map it
+	// to a group in an external identity source
+	Alias *Alias `protobuf:"bytes,11,opt,name=alias" json:"alias,omitempty"`
+	// Type indicates if this group is an internal group or an external
+	// group. Memberships of internal groups are managed over the API, while
+	// the memberships of external groups are managed by the authentication
+	// backend that the alias of the group belongs to.
+	Type string `protobuf:"bytes,12,opt,name=type" json:"type,omitempty"`
 }
 
 func (m *Group) Reset()                    { *m = Group{} }
@@ -136,6 +144,20 @@ func (m *Group) GetBucketKeyHash() string {
 	return ""
 }
 
+func (m *Group) GetAlias() *Alias {
+	if m != nil {
+		return m.Alias
+	}
+	return nil
+}
+
+func (m *Group) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
 // Entity represents an entity that gets persisted and indexed.
 // Entity is fundamentally composed of zero or many aliases.
 type Entity struct {
@@ -253,8 +275,9 @@ func (m *Entity) GetBucketKeyHash() string {
 type Alias struct {
 	// ID is the unique identifier that represents this alias
 	ID string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
-	// EntityID is the entity identifier to which this alias belongs to
-	EntityID string `protobuf:"bytes,2,opt,name=entity_id,json=entityId" json:"entity_id,omitempty"`
+	// CanonicalID is the identifier of the entity to which this alias
+	// belongs to, or of the group in the case of a group alias
+	CanonicalID string `protobuf:"bytes,2,opt,name=canonical_id,json=canonicalId" json:"canonical_id,omitempty"`
 	// MountType is the backend mount's type to which this alias belongs to.
 	// This enables categorically querying aliases of specific backend types.
 	MountType string `protobuf:"bytes,3,opt,name=mount_type,json=mountType" json:"mount_type,omitempty"`
@@ -299,9 +322,9 @@ func (m *Alias) GetID() string {
 	return ""
 }
 
-func (m *Alias) GetEntityID() string {
+func (m *Alias) GetCanonicalID() string {
 	if m != nil {
-		return m.EntityID
+		return m.CanonicalID
 	}
 	return ""
 }
@@ -371,41 +394,43 @@ func init() {
 func init() { proto.RegisterFile("types.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 570 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x94, 0xcd, 0x6e, 0xd3, 0x40,
-	0x10, 0xc7, 0xe5, 0x38, 0x1f, 0xf6, 0xa4, 0x4d, 0xcb, 0x82, 0x90, 0x15, 0x54, 0x08, 0x95, 0x40,
-	0x86, 0x83, 0x2b, 0xb5, 0x17, 0x28, 0x07, 0x54, 0x89, 0x02, 0x15, 0x42, 0x42, 0x56, 0x39, 0x5b,
-	0x9b, 0x78, 0x9a, 0xac, 0x1a, 0x7b, 0x2d, 0xef, 0x1a, 0xe1, 0x27, 0xe4, 0x39, 0x38, 0xf1, 0x1a,
-	0xc8, 0xb3, 0x76, 0x62, 0x08, 0x5f, 0x15, 0xb9, 0xd9, 0xff, 0x99, 0x1d, 0xcf, 0xce, 0xff, 0x37,
-	0x86, 0xa1, 0x2e, 0x33, 0x54, 0x41, 0x96, 0x4b, 0x2d, 0x99, 0x23, 0x62, 0x4c, 0xb5, 0xd0, 0xe5,
-	0xf8, 0xc1, 0x5c, 0xca, 0xf9, 0x12, 0x8f, 0x48, 0x9f, 0x16, 0x57, 0x47, 0x5a, 0x24, 0xa8, 0x34,
-	0x4f, 0x32, 0x93, 0x7a, 0xf8, 0xcd, 0x86, 0xde, 0x9b, 0x5c, 0x16, 0x19, 0x1b, 0x41, 0x47, 0xc4,
-	0x9e, 0x35, 0xb1, 0x7c, 0x37, 0xec, 0x88, 0x98, 0x31, 0xe8, 0xa6, 0x3c, 0x41, 0xaf, 0x43, 0x0a,
-	0x3d, 0xb3, 0x31, 0x38, 0x99, 0x5c, 0x8a, 0x99, 0x40, 0xe5, 0xd9, 0x13, 0xdb, 0x77, 0xc3, 0xd5,
-	0x3b, 0xf3, 0x61, 0x3f, 0xe3, 0x39, 0xa6, 0x3a, 0x9a, 0x57, 0xf5, 0x22, 0x11, 0x2b, 0xaf, 0x4b,
-	0x39, 0x23, 0xa3, 0xd3, 0x67, 0x2e, 0x62, 0xc5, 0x9e, 0xc2, 0xad, 0x04, 0x93, 0x29, 0xe6, 0x91,
-	0xe9, 0x92, 0x52, 0x7b, 0x94, 0xba, 0x67, 0x02, 0xe7, 0xa4, 0x57, 0xb9, 0xcf, 0xc1, 0x49, 0x50,
-	0xf3, 0x98, 0x6b, 0xee, 0xf5, 0x27, 0xb6, 0x3f, 0x3c, 0x3e, 0x08, 0x9a, 0xdb, 0x05, 0x54, 0x31,
-	0x78, 0x5f, 0xc7, 0xcf, 0x53, 0x9d, 0x97, 0xe1, 0x2a, 0x9d, 0xbd, 0x84, 0xdd, 0x59, 0x8e, 0x5c,
-	0x0b, 0x99, 0x46, 0xd5, 0xb5, 0xbd, 0xc1, 0xc4, 0xf2, 0x87, 0xc7, 0xe3, 0xc0, 0xcc, 0x24, 0x68,
-	0x66, 0x12, 0x5c, 0x36, 0x33, 0x09, 0x77, 0x9a, 0x03, 0x95, 0xc4, 0x5e, 0xc1, 0xfe, 0x92, 0x2b,
-	0x1d, 0x15, 0x59, 0xcc, 0x35, 0x9a, 0x1a, 0xce, 0x5f, 0x6b, 0x8c, 0xaa, 0x33, 0x1f, 0xe9, 0x08,
-	0x55, 0x79, 0x08, 0x3b, 0x89, 0x8c, 0xc5, 0x55, 0x19, 0x89, 0x34, 0xc6, 0xcf, 0x9e, 0x3b, 0xb1,
-	0xfc, 0x6e, 0x38, 0x34, 0xda, 0x45, 0x25, 0xb1, 0xc7, 0xb0, 0x37, 0x2d, 0x66, 0xd7, 0xa8, 0xa3,
-	0x6b, 0x2c, 0xa3, 0x05, 0x57, 0x0b, 0x0f, 0x68, 0xea, 0xbb, 0x46, 0x7e, 0x87, 0xe5, 0x5b, 0xae,
-	0x16, 0xe3, 0x17, 0xb0, 0xfb, 0xc3, 0x65, 0xd9, 0x3e, 0xd8, 0xd7, 0x58, 0xd6, 0xa6, 0x55, 0x8f,
-	0xec, 0x0e, 0xf4, 0x3e, 0xf1, 0x65, 0xd1, 0xd8, 0x66, 0x5e, 0x4e, 0x3b, 0xcf, 0xac, 0xc3, 0x2f,
-	0x36, 0xf4, 0xcd, 0x5c, 0xd9, 0x13, 0x18, 0xf0, 0xa5, 0xe0, 0x0a, 0x95, 0x67, 0xd1, 0x4c, 0xf7,
-	0xd6, 0x33, 0x3d, 0xab, 0x02, 0x61, 0x13, 0xaf, 0xa9, 0xe8, 0x6c, 0x50, 0x61, 0xb7, 0xa8, 0x38,
-	0x6d, 0x79, 0xd4, 0xa5, 0x7a, 0xf7, 0xd7, 0xf5, 0xcc, 0x27, 0xff, 0xdd, 0xa4, 0xde, 0x16, 0x4c,
-	0xea, 0xdf, 0xd8, 0x24, 0x42, 0x32, 0x9f, 0x63, 0xdc, 0x46, 0x72, 0xd0, 0x20, 0x59, 0x05, 0xd6,
-	0x48, 0xb6, 0x97, 0xc0, 0xf9, 0x69, 0x09, 0x7e, 0xe1, 0xa4, 0xbb, 0x75, 0x27, 0xbf, 0xda, 0xd0,
-	0x23, 0x9b, 0x36, 0x76, 0xf6, 0x1e, 0xb8, 0xab, 0xfe, 0xeb, 0x73, 0x0e, 0xd6, 0x8d, 0xb3, 0x03,
-	0x80, 0x44, 0x16, 0xa9, 0x8e, 0xaa, 0x5f, 0x45, 0x6d, 0xa0, 0x4b, 0xca, 0x65, 0x99, 0x21, 0x7b,
-	0x04, 0x23, 0x13, 0xe6, 0xb3, 0x19, 0x2a, 0x25, 0x73, 0xaf, 0x6b, 0x3a, 0x27, 0xf5, 0xac, 0x16,
-	0xd7, 0x55, 0x32, 0xae, 0x17, 0xe4, 0x56, 0x53, 0xe5, 0x03, 0xd7, 0x8b, 0x3f, 0xef, 0x2b, 0x35,
-	0xfd, 0x5b, 0x14, 0x1a, 0xb4, 0x06, 0x2d, 0xb4, 0x36, 0xf0, 0x70, 0xb6, 0x80, 0x87, 0x7b, 0x63,
-	0x3c, 0x4e, 0xe0, 0x6e, 0x8d, 0xc7, 0x55, 0x2e, 0x93, 0x36, 0x23, 0x40, 0x00, 0xdc, 0x36, 0xd1,
-	0xd7, 0xb9, 0x4c, 0x56, 0x9c, 0xfc, 0x97, 0xc7, 0xd3, 0x3e, 0x75, 0x75, 0xf2, 0x3d, 0x00, 0x00,
-	0xff, 0xff, 0x17, 0x1c, 0xfc, 0x89, 0xd8, 0x05, 0x00, 0x00,
+	// 598 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x93, 0xdf, 0x6e, 0xd3, 0x3e,
+	0x14, 0xc7, 0xd5, 0x26, 0x69, 0x93, 0x93, 0xae, 0xdb, 0xcf, 0x3f, 0x84, 0xac, 0x4a, 0x83, 0x6c,
+	0xd2, 0x50, 0xe0, 0x22, 0x93, 0xb6, 0x1b, 0x18, 0x17, 0x68, 0x12, 0x03, 0x26, 0x84, 0x84, 0xa2,
+	0x71, 0x1d, 0xb9, 0x89, 0xd7, 0x5a, 0x4b, 0xe2, 0x28, 0x76, 0x10, 0x79, 0x1d, 0x5e, 0x86, 0x47,
+	0xe1, 0x35, 0x90, 0xed, 0xa6, 0x0d, 0x6c, 0xfc, 0x99, 0xb6, 0x3b, 0xe7, 0x7b, 0x8e, 0x8f, 0x4f,
+	0xce, 0xf7, 0x73, 0xc0, 0x97, 0x6d, 0x45, 0x45, 0x54, 0xd5, 0x5c, 0x72, 0xe4, 0xb2, 0x8c, 0x96,
+	0x92, 0xc9, 0x76, 0xf6, 0x78, 0xc1, 0xf9, 0x22, 0xa7, 0x87, 0x5a, 0x9f, 0x37, 0x97, 0x87, 0x92,
+	0x15, 0x54, 0x48, 0x52, 0x54, 0x26, 0x75, 0xff, 0xab, 0x0d, 0xce, 0xdb, 0x9a, 0x37, 0x15, 0x9a,
+	0xc2, 0x90, 0x65, 0x78, 0x10, 0x0c, 0x42, 0x2f, 0x1e, 0xb2, 0x0c, 0x21, 0xb0, 0x4b, 0x52, 0x50,
+	0x3c, 0xd4, 0x8a, 0x3e, 0xa3, 0x19, 0xb8, 0x15, 0xcf, 0x59, 0xca, 0xa8, 0xc0, 0x56, 0x60, 0x85,
+	0x5e, 0xbc, 0xfe, 0x46, 0x21, 0xec, 0x54, 0xa4, 0xa6, 0xa5, 0x4c, 0x16, 0xaa, 0x5e, 0xc2, 0x32,
+	0x81, 0x6d, 0x9d, 0x33, 0x35, 0xba, 0x7e, 0xe6, 0x3c, 0x13, 0xe8, 0x19, 0xfc, 0x57, 0xd0, 0x62,
+	0x4e, 0xeb, 0xc4, 0x74, 0xa9, 0x53, 0x1d, 0x9d, 0xba, 0x6d, 0x02, 0x67, 0x5a, 0x57, 0xb9, 0x2f,
+	0xc0, 0x2d, 0xa8, 0x24, 0x19, 0x91, 0x04, 0x8f, 0x02, 0x2b, 0xf4, 0x8f, 0x76, 0xa3, 0xee, 0xef,
+	0x22, 0x5d, 0x31, 0xfa, 0xb0, 0x8a, 0x9f, 0x95, 0xb2, 0x6e, 0xe3, 0x75, 0x3a, 0x7a, 0x05, 0x5b,
+	0x69, 0x4d, 0x89, 0x64, 0xbc, 0x4c, 0xd4, 0x6f, 0xe3, 0x71, 0x30, 0x08, 0xfd, 0xa3, 0x59, 0x64,
+	0x66, 0x12, 0x75, 0x33, 0x89, 0x2e, 0xba, 0x99, 0xc4, 0x93, 0xee, 0x82, 0x92, 0xd0, 0x6b, 0xd8,
+	0xc9, 0x89, 0x90, 0x49, 0x53, 0x65, 0x44, 0x52, 0x53, 0xc3, 0xfd, 0x6b, 0x8d, 0xa9, 0xba, 0xf3,
+	0x49, 0x5f, 0xd1, 0x55, 0xf6, 0x60, 0x52, 0xf0, 0x8c, 0x5d, 0xb6, 0x09, 0x2b, 0x33, 0xfa, 0x05,
+	0x7b, 0xc1, 0x20, 0xb4, 0x63, 0xdf, 0x68, 0xe7, 0x4a, 0x42, 0x4f, 0x60, 0x7b, 0xde, 0xa4, 0x57,
+	0x54, 0x26, 0x57, 0xb4, 0x4d, 0x96, 0x44, 0x2c, 0x31, 0xe8, 0xa9, 0x6f, 0x19, 0xf9, 0x3d, 0x6d,
+	0xdf, 0x11, 0xb1, 0x44, 0x07, 0xe0, 0x90, 0x9c, 0x11, 0x81, 0x7d, 0xdd, 0xc5, 0xf6, 0x66, 0x12,
+	0xa7, 0x4a, 0x8e, 0x4d, 0x54, 0x39, 0xa7, 0x68, 0xc0, 0x13, 0xe3, 0x9c, 0x3a, 0xcf, 0x5e, 0xc2,
+	0xd6, 0x4f, 0x73, 0x42, 0x3b, 0x60, 0x5d, 0xd1, 0x76, 0xe5, 0xb7, 0x3a, 0xa2, 0x07, 0xe0, 0x7c,
+	0x26, 0x79, 0xd3, 0x39, 0x6e, 0x3e, 0x4e, 0x86, 0xcf, 0x07, 0xfb, 0xdf, 0x2c, 0x18, 0x19, 0x4b,
+	0xd0, 0x53, 0x18, 0xeb, 0x47, 0xa8, 0xc0, 0x83, 0xc0, 0xba, 0xa9, 0x89, 0x2e, 0xbe, 0x02, 0x6a,
+	0x78, 0x0d, 0x28, 0xab, 0x07, 0xd4, 0x49, 0xcf, 0x5e, 0x5b, 0xd7, 0x7b, 0xb4, 0xa9, 0x67, 0x9e,
+	0xfc, 0x77, 0x7f, 0x9d, 0x7b, 0xf0, 0x77, 0x74, 0x6b, 0x7f, 0x35, 0xcd, 0xf5, 0x82, 0x66, 0x7d,
+	0x9a, 0xc7, 0x1d, 0xcd, 0x2a, 0xb0, 0xa1, 0xb9, 0xbf, 0x3f, 0xee, 0x2f, 0xfb, 0x73, 0x03, 0x04,
+	0xde, 0x0d, 0x10, 0xdc, 0xcd, 0xc9, 0xef, 0x16, 0x38, 0xda, 0xa6, 0x6b, 0xeb, 0xbe, 0x07, 0x93,
+	0x94, 0x94, 0xbc, 0x64, 0x29, 0xc9, 0x93, 0xb5, 0x6f, 0xfe, 0x5a, 0x3b, 0xcf, 0xd0, 0x2e, 0x40,
+	0xc1, 0x9b, 0x52, 0x26, 0x9a, 0x2e, 0x63, 0xa3, 0xa7, 0x95, 0x8b, 0xb6, 0xa2, 0xe8, 0x00, 0xa6,
+	0x26, 0x4c, 0xd2, 0x94, 0x0a, 0xc1, 0x6b, 0x6c, 0x9b, 0xfe, 0xb5, 0x7a, 0xba, 0x12, 0x37, 0x55,
+	0x2a, 0x22, 0x97, 0xd8, 0xe9, 0x55, 0xf9, 0x48, 0xe4, 0xf2, 0xcf, 0x0b, 0xaf, 0x5b, 0xff, 0x2d,
+	0x10, 0x1d, 0x60, 0xe3, 0x1e, 0x60, 0xd7, 0x20, 0x71, 0xef, 0x01, 0x12, 0xef, 0xd6, 0x90, 0x1c,
+	0xc3, 0xc3, 0x15, 0x24, 0x97, 0x35, 0x2f, 0xfa, 0xa4, 0x80, 0xc6, 0xe0, 0x7f, 0x13, 0x7d, 0x53,
+	0xf3, 0x62, 0x4d, 0xcb, 0x9d, 0x9c, 0x9e, 0x8f, 0x74, 0x57, 0xc7, 0x3f, 0x06, 0x00, 0x9b, 0xc0,
+	0xb2, 0x32, 0x19, 0x06, 0x00, 0x00,
 }
@@ -0,0 +1,91 @@
+package keysutil
+
+import (
+	"fmt"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	// MinCacheSize is the smallest size of a bounded policy cache. A size of
+	// zero means the cache is unbounded.
+	MinCacheSize = 10
+)
+
+// policyCache is the in-memory cache of the policies of a LockManager. It is
+// guarded by the cacheMutex of its LockManager.
+type policyCache interface {
+	Get(name string) *Policy
+	Set(name string, p *Policy)
+	Delete(name string)
+	Size() int
+}
+
+// newPolicyCache returns an unbounded cache if size is zero, and an LRU cache
+// holding up to size policies otherwise. Evictions are counted in a metric
+// with the given labels.
+func newPolicyCache(size int, labels []metrics.Label) (policyCache, error) {
+	switch {
+	case size == 0:
+		return mapCache{}, nil
+	case size < MinCacheSize:
+		return nil, fmt.Errorf("cache size must be 0 or at least %d", MinCacheSize)
+	}
+
+	c, err := lru.NewWithEvict(size, func(key interface{}, value interface{}) {
+		metrics.IncrCounterWithLabels([]string{"keysutil", "cache", "evict"}, 1, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lruCache{lru: c, size: size}, nil
+}
+
+// mapCache caches every policy it is given until it is deleted
+type mapCache map[string]*Policy
+
+func (c mapCache) Get(name string) *Policy {
+	return c[name]
+}
+
+func (c mapCache) Set(name string, p *Policy) {
+	c[name] = p
+}
+
+func (c mapCache) Delete(name string) {
+	delete(c, name)
+}
+
+func (c mapCache) Size() int {
+	return 0
+}
+
+// lruCache caches a bounded number of policies, evicting the least recently
+// used ones. Evicting a policy is safe while its lock is held by someone
+// else: all changes to a policy are persisted before its lock is released,
+// and the next holder of the lock loads it back from storage.
+type lruCache struct {
+	lru  *lru.Cache
+	size int
+}
+
+func (c *lruCache) Get(name string) *Policy {
+	p, ok := c.lru.Get(name)
+	if !ok {
+		return nil
+	}
+	return p.(*Policy)
+}
+
+func (c *lruCache) Set(name string, p *Policy) {
+	c.lru.Add(name, p)
+}
+
+func (c *lruCache) Delete(name string) {
+	c.lru.Remove(name)
+}
+
+func (c *lruCache) Size() int {
+	return c.size
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -39,6 +42,9 @@ type PolicyRequest struct {
 	// Whether to allow export
 	Exportable bool
 
+	// Whether to allow plaintext backup
+	AllowPlaintextBackup bool
+
 	// Whether to upsert
 	Upsert bool
 }
@@ -50,11 +56,17 @@ type LockManager struct {
 	// A mutex for the map itself
 	locksMutex sync.RWMutex
 
-	// If caching is enabled, the map of name to in-memory policy cache
-	cache map[string]*Policy
+	// Whether caching is enabled
+	useCache bool
+
+	// If caching is enabled, the in-memory cache of policies by name
+	cache policyCache
 
 	// Used for global locking, and as the cache map mutex
 	cacheMutex sync.RWMutex
+
+	// The labels of the cache metrics, identifying the mount
+	metricsLabels []metrics.Label
 }
 
 func NewLockManager(cacheDisabled bool) *LockManager {
@@ -62,13 +74,54 @@ func NewLockManager(cacheDisabled bool) *LockManager {
 		locks: map[string]*sync.RWMutex{},
 	}
 	if !cacheDisabled {
-		lm.cache = map[string]*Policy{}
+		lm.useCache = true
+		lm.cache = mapCache{}
 	}
 	return lm
 }
 
+// SetMountPoint labels the metrics of the cache with the mount the lock
+// manager serves. It must be called before the lock manager is used.
+func (lm *LockManager) SetMountPoint(mountPoint string) {
+	lm.metricsLabels = []metrics.Label{
+		{Name: "mount", Value: mountPoint},
+	}
+}
+
 func (lm *LockManager) CacheActive() bool {
-	return lm.cache != nil
+	return lm.useCache
+}
+
+// CacheSize returns the maximum number of policies cached, zero meaning that
+// the cache is unbounded
+func (lm *LockManager) CacheSize() int {
+	if !lm.CacheActive() {
+		return 0
+	}
+	lm.cacheMutex.RLock()
+	defer lm.cacheMutex.RUnlock()
+	return lm.cache.Size()
+}
+
+// SetCacheSize replaces the cache with an empty one holding up to size
+// policies, or an unbounded one if size is zero. It does nothing if caching
+// is disabled.
+func (lm *LockManager) SetCacheSize(size int) error {
+	if !lm.CacheActive() {
+		return nil
+	}
+
+	cache, err := newPolicyCache(size, lm.metricsLabels)
+	if err != nil {
+		return err
+	}
+
+	lm.cacheMutex.Lock()
+	defer lm.cacheMutex.Unlock()
+	if lm.cache.Size() != size {
+		lm.cache = cache
+	}
+	return nil
 }
 
 func (lm *LockManager) InvalidatePolicy(name string) {
@@ -76,7 +129,7 @@ func (lm *LockManager) InvalidatePolicy(name string) {
 	if lm.CacheActive() {
 		lm.cacheMutex.Lock()
 		defer lm.cacheMutex.Unlock()
-		delete(lm.cache, name)
+		lm.cache.Delete(name)
 	}
 }
 
@@ -175,6 +228,20 @@ func (lm *LockManager) GetPolicyExclusive(storage logical.Storage, name string)
 	return p, lock, err
 }
 
+// PolicyNeedsAutoRotation reports whether the named policy is due for
+// automatic rotation. The policy is read from storage without being cached,
+// so that checking every policy does not evict the ones in use.
+func (lm *LockManager) PolicyNeedsAutoRotation(storage logical.Storage, name string, now time.Time) (bool, error) {
+	lock := lm.policyLock(name, shared)
+	defer lock.RUnlock()
+
+	p, err := lm.getStoredPolicy(storage, name)
+	if err != nil || p == nil {
+		return false, err
+	}
+	return p.NeedsAutoRotation(now), nil
+}
+
 // Get the policy with a read lock; if it returns that an exclusive lock is
 // needed, retry. If successful, call one more time to get a read lock and
 // return the value.
@@ -212,12 +279,14 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 	// Check if it's in our cache. If so, return right away.
 	if lm.CacheActive() {
 		lm.cacheMutex.RLock()
-		p = lm.cache[req.Name]
+		p = lm.cache.Get(req.Name)
 		if p != nil {
 			lm.cacheMutex.RUnlock()
+			metrics.IncrCounterWithLabels([]string{"keysutil", "cache", "hit"}, 1, lm.metricsLabels)
 			return p, lock, false, nil
 		}
 		lm.cacheMutex.RUnlock()
+		metrics.IncrCounterWithLabels([]string{"keysutil", "cache", "miss"}, 1, lm.metricsLabels)
 	}
 
 	// Load it from storage
@@ -240,40 +309,10 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 			return nil, nil, false, errNeedExclusiveLock
 		}
 
-		switch req.KeyType {
-		case KeyType_AES256_GCM96:
-			if req.Convergent && !req.Derived {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
-			}
-
-		case KeyType_ECDSA_P256:
-			if req.Derived || req.Convergent {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_ED25519:
-			if req.Convergent {
-				lm.UnlockPolicy(lock, lockType)
-				return nil, nil, false, fmt.Errorf("convergent encryption not not supported for keys of type %v", req.KeyType)
-			}
-
-		default:
+		p, err = newPolicy(req)
+		if err != nil {
 			lm.UnlockPolicy(lock, lockType)
-			return nil, nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
-		}
-
-		p = &Policy{
-			Name:       req.Name,
-			Type:       req.KeyType,
-			Derived:    req.Derived,
-			Exportable: req.Exportable,
-		}
-		if req.Derived {
-			p.KDF = Kdf_hkdf_sha256
-			p.ConvergentEncryption = req.Convergent
-			p.ConvergentVersion = 2
+			return nil, nil, false, err
 		}
 
 		err = p.Rotate(req.Storage)
@@ -289,12 +328,12 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 			defer lm.cacheMutex.Unlock()
 			// Make sure a policy didn't appear. If so, it will only be set if
 			// there was no error, so assume it's good and return that
-			exp := lm.cache[req.Name]
+			exp := lm.cache.Get(req.Name)
 			if exp != nil {
 				return exp, lock, false, nil
 			}
 			if err == nil {
-				lm.cache[req.Name] = p
+				lm.cache.Set(req.Name, p)
 			}
 		}
 
@@ -322,18 +361,173 @@ func (lm *LockManager) getPolicyCommon(req PolicyRequest, lockType bool) (*Polic
 		defer lm.cacheMutex.Unlock()
 		// Make sure a policy didn't appear. If so, it will only be set if
 		// there was no error, so assume it's good and return that
-		exp := lm.cache[req.Name]
+		exp := lm.cache.Get(req.Name)
 		if exp != nil {
 			return exp, lock, false, nil
 		}
 		if err == nil {
-			lm.cache[req.Name] = p
+			lm.cache.Set(req.Name, p)
 		}
 	}
 
 	return p, lock, false, nil
 }
 
+// BackupPolicy returns a backup of the named policy, see Policy.Backup
+func (lm *LockManager) BackupPolicy(storage logical.Storage, name string) (string, error) {
+	p, lock, err := lm.GetPolicyExclusive(storage, name)
+	if lock != nil {
+		defer lock.Unlock()
+	}
+	if err != nil {
+		return "", err
+	}
+	if p == nil {
+		return "", errutil.UserError{Err: fmt.Sprintf("key %q not found", name)}
+	}
+
+	return p.Backup(storage)
+}
+
+// RestorePolicy restores a policy from a backup taken by BackupPolicy, in
+// this or any other mount. If name is empty, the name of the backed up policy
+// is used. An existing policy is only overwritten if force is set.
+func (lm *LockManager) RestorePolicy(storage logical.Storage, name, backup string, force bool) error {
+	keyData, err := decodeBackup(backup)
+	if err != nil {
+		return err
+	}
+	if keyData.Policy.Name == "" || keyData.Policy.LatestVersion < 1 {
+		return errutil.UserError{Err: "backup does not contain a valid key"}
+	}
+
+	if name == "" {
+		name = keyData.Policy.Name
+	}
+	keyData.Policy.Name = name
+
+	lm.cacheMutex.Lock()
+	lock := lm.policyLock(name, exclusive)
+	defer lock.Unlock()
+	defer lm.cacheMutex.Unlock()
+
+	if !force {
+		var p *Policy
+		if lm.CacheActive() {
+			p = lm.cache.Get(name)
+		}
+		if p == nil {
+			p, err = lm.getStoredPolicy(storage, name)
+			if err != nil {
+				return err
+			}
+		}
+		if p != nil {
+			return errutil.UserError{Err: fmt.Sprintf("key %q already exists", name)}
+		}
+	}
+
+	// Restore the archived keys first so that persisting the policy finds
+	// all of its versions
+	if keyData.ArchivedKeys != nil {
+		if err := keyData.Policy.storeArchive(keyData.ArchivedKeys, storage); err != nil {
+			return fmt.Errorf("failed to restore archived keys of %s: %v", name, err)
+		}
+	}
+
+	keyData.Policy.RestoreInfo = &RestoreInfo{
+		Time:    time.Now(),
+		Version: keyData.Policy.LatestVersion,
+	}
+	if err := keyData.Policy.Persist(storage); err != nil {
+		return fmt.Errorf("failed to restore policy %s: %v", name, err)
+	}
+
+	if lm.CacheActive() {
+		lm.cache.Set(name, keyData.Policy)
+	}
+
+	return nil
+}
+
+// newPolicy returns a new policy without any key versions as requested
+func newPolicy(req PolicyRequest) (*Policy, error) {
+	switch req.KeyType {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if req.Convergent && !req.Derived {
+			return nil, fmt.Errorf("convergent encryption requires derivation to be enabled")
+		}
+
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_RSA2048, KeyType_RSA4096:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ED25519:
+		if req.Convergent {
+			return nil, fmt.Errorf("convergent encryption not not supported for keys of type %v", req.KeyType)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", req.KeyType)
+	}
+
+	p := &Policy{
+		Name:                 req.Name,
+		Type:                 req.KeyType,
+		Derived:              req.Derived,
+		Exportable:           req.Exportable,
+		AllowPlaintextBackup: req.AllowPlaintextBackup,
+	}
+	if req.Derived {
+		p.KDF = Kdf_hkdf_sha256
+		p.ConvergentEncryption = req.Convergent
+		p.ConvergentVersion = 2
+	}
+
+	return p, nil
+}
+
+// ImportPolicy creates a new policy whose first key version is the given
+// key material, see Policy.Import
+func (lm *LockManager) ImportPolicy(req PolicyRequest, key []byte) error {
+	lm.cacheMutex.Lock()
+	lock := lm.policyLock(req.Name, exclusive)
+	defer lock.Unlock()
+	defer lm.cacheMutex.Unlock()
+
+	var p *Policy
+	var err error
+	if lm.CacheActive() {
+		p = lm.cache.Get(req.Name)
+	}
+	if p == nil {
+		p, err = lm.getStoredPolicy(req.Storage, req.Name)
+		if err != nil {
+			return err
+		}
+	}
+	if p != nil {
+		return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+	}
+
+	p, err = newPolicy(req)
+	if err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+	p.Imported = true
+
+	if err := p.Import(req.Storage, key); err != nil {
+		return err
+	}
+
+	if lm.CacheActive() {
+		lm.cache.Set(req.Name, p)
+	}
+
+	return nil
+}
+
 func (lm *LockManager) DeletePolicy(storage logical.Storage, name string) error {
 	lm.cacheMutex.Lock()
 	lock := lm.policyLock(name, exclusive)
@@ -344,7 +538,7 @@ func (lm *LockManager) DeletePolicy(storage logical.Storage, name string) error
 	var err error
 
 	if lm.CacheActive() {
-		p = lm.cache[name]
+		p = lm.cache.Get(name)
 	}
 	if p == nil {
 		p, err = lm.getStoredPolicy(storage, name)
@@ -371,7 +565,7 @@ func (lm *LockManager) DeletePolicy(storage logical.Storage, name string) error
 	}
 
 	if lm.CacheActive() {
-		delete(lm.cache, name)
+		lm.cache.Delete(name)
 	}
 
 	return nil
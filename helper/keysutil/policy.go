@@ -9,19 +9,23 @@ import (
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"hash"
 	"io"
 	"math/big"
 	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/hkdf"
 
@@ -44,6 +48,51 @@ const (
 	KeyType_AES256_GCM96 = iota
 	KeyType_ECDSA_P256
 	KeyType_ED25519
+	KeyType_RSA2048
+	KeyType_RSA4096
+	KeyType_ChaCha20_Poly1305
+	KeyType_ECDSA_P384
+	KeyType_ECDSA_P521
+)
+
+// The hash algorithms that signing input can be hashed with
+const (
+	HashTypeNone HashType = iota
+	HashTypeSHA2224
+	HashTypeSHA2256
+	HashTypeSHA2384
+	HashTypeSHA2512
+)
+
+// The signature schemes of RSA keys
+const (
+	SignatureAlgorithmPSS      = "pss"
+	SignatureAlgorithmPKCS1v15 = "pkcs1v15"
+)
+
+var (
+	HashTypeMap = map[string]HashType{
+		"none":     HashTypeNone,
+		"sha2-224": HashTypeSHA2224,
+		"sha2-256": HashTypeSHA2256,
+		"sha2-384": HashTypeSHA2384,
+		"sha2-512": HashTypeSHA2512,
+	}
+
+	HashFuncMap = map[HashType]func() hash.Hash{
+		HashTypeSHA2224: sha256.New224,
+		HashTypeSHA2256: sha256.New,
+		HashTypeSHA2384: sha512.New384,
+		HashTypeSHA2512: sha512.New,
+	}
+
+	cryptoHashMap = map[HashType]crypto.Hash{
+		HashTypeNone:    crypto.Hash(0),
+		HashTypeSHA2224: crypto.SHA224,
+		HashTypeSHA2256: crypto.SHA256,
+		HashTypeSHA2384: crypto.SHA384,
+		HashTypeSHA2512: crypto.SHA512,
+	}
 )
 
 const ErrTooOld = "ciphertext or signature version is disallowed by policy (too old)"
@@ -57,11 +106,13 @@ type ecdsaSignature struct {
 	R, S *big.Int
 }
 
+type HashType uint32
+
 type KeyType int
 
 func (kt KeyType) EncryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -69,7 +120,7 @@ func (kt KeyType) EncryptionSupported() bool {
 
 func (kt KeyType) DecryptionSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -77,7 +128,7 @@ func (kt KeyType) DecryptionSupported() bool {
 
 func (kt KeyType) SigningSupported() bool {
 	switch kt {
-	case KeyType_ECDSA_P256, KeyType_ED25519:
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -85,7 +136,7 @@ func (kt KeyType) SigningSupported() bool {
 
 func (kt KeyType) HashSignatureInput() bool {
 	switch kt {
-	case KeyType_ECDSA_P256:
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_RSA2048, KeyType_RSA4096:
 		return true
 	}
 	return false
@@ -93,25 +144,77 @@ func (kt KeyType) HashSignatureInput() bool {
 
 func (kt KeyType) DerivationSupported() bool {
 	switch kt {
-	case KeyType_AES256_GCM96, KeyType_ED25519:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_ED25519:
+		return true
+	}
+	return false
+}
+
+// ConvergentEncryptionSupported reports whether keys of this type can be
+// used for convergent encryption
+func (kt KeyType) ConvergentEncryptionSupported() bool {
+	switch kt {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		return true
 	}
 	return false
 }
 
+// Curve returns the elliptic curve of ECDSA key types, or nil
+func (kt KeyType) Curve() elliptic.Curve {
+	switch kt {
+	case KeyType_ECDSA_P256:
+		return elliptic.P256()
+	case KeyType_ECDSA_P384:
+		return elliptic.P384()
+	case KeyType_ECDSA_P521:
+		return elliptic.P521()
+	}
+	return nil
+}
+
 func (kt KeyType) String() string {
 	switch kt {
 	case KeyType_AES256_GCM96:
 		return "aes256-gcm96"
+	case KeyType_ChaCha20_Poly1305:
+		return "chacha20-poly1305"
 	case KeyType_ECDSA_P256:
 		return "ecdsa-p256"
+	case KeyType_ECDSA_P384:
+		return "ecdsa-p384"
+	case KeyType_ECDSA_P521:
+		return "ecdsa-p521"
 	case KeyType_ED25519:
 		return "ed25519"
+	case KeyType_RSA2048:
+		return "rsa-2048"
+	case KeyType_RSA4096:
+		return "rsa-4096"
 	}
 
 	return "[unknown]"
 }
 
+// KeyTypeFromString returns the key type with the given name
+func KeyTypeFromString(name string) (KeyType, bool) {
+	for _, kt := range []KeyType{
+		KeyType_AES256_GCM96,
+		KeyType_ChaCha20_Poly1305,
+		KeyType_ECDSA_P256,
+		KeyType_ECDSA_P384,
+		KeyType_ECDSA_P521,
+		KeyType_ED25519,
+		KeyType_RSA2048,
+		KeyType_RSA4096,
+	} {
+		if kt.String() == name {
+			return kt, true
+		}
+	}
+	return 0, false
+}
+
 // KeyEntry stores the key and metadata
 type KeyEntry struct {
 	// AES or some other kind that is a pure byte slice like ED25519
@@ -127,6 +230,8 @@ type KeyEntry struct {
 	EC_Y *big.Int `json:"ec_y"`
 	EC_D *big.Int `json:"ec_d"`
 
+	RSAKey *rsa.PrivateKey `json:"rsa_key"`
+
 	// The public key in an appropriate format for the type of key
 	FormattedPublicKey string `json:"public_key"`
 
@@ -193,6 +298,14 @@ type Policy struct {
 	// a max.
 	ArchiveVersion int `json:"archive_version"`
 
+	// The minimum version of the key that is available. Versions below it
+	// have been trimmed and are gone for good.
+	MinAvailableVersion int `json:"min_available_version"`
+
+	// The version of the first key in the archive. The archive is indexed by
+	// key version, offset by this value once versions have been trimmed.
+	ArchiveMinVersion int `json:"archive_min_version"`
+
 	// Whether the key is allowed to be deleted
 	DeletionAllowed bool `json:"deletion_allowed"`
 
@@ -201,6 +314,96 @@ type Policy struct {
 
 	// The type of key
 	Type KeyType `json:"type"`
+
+	// Whether the key can be backed up in plaintext. Once set, this cannot
+	// be unset.
+	AllowPlaintextBackup bool `json:"allow_plaintext_backup"`
+
+	// Information about the last backup of the key
+	BackupInfo *BackupInfo `json:"backup_info"`
+
+	// Information about the restore of the key, if it was restored from a
+	// backup
+	RestoreInfo *RestoreInfo `json:"restore_info"`
+
+	// Whether the key was created from imported key material
+	Imported bool `json:"imported"`
+
+	// Whether key versions can be imported into a key generated by Vault
+	AllowImport bool `json:"allow_import"`
+
+	// The period after which the key is automatically rotated. Zero
+	// disables automatic rotation.
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+}
+
+// BackupInfo records when a key was last backed up and its latest version at
+// the time
+type BackupInfo struct {
+	Time    time.Time `json:"time"`
+	Version int       `json:"version"`
+}
+
+// RestoreInfo records when a key was restored from a backup and its latest
+// version at the time
+type RestoreInfo struct {
+	Time    time.Time `json:"time"`
+	Version int       `json:"version"`
+}
+
+// KeyData is the content of a key backup: the policy along with all of its
+// archived key versions
+type KeyData struct {
+	Policy       *Policy       `json:"policy"`
+	ArchivedKeys *archivedKeys `json:"archived_keys"`
+}
+
+// backupFormatVersion is the version of the backup format produced by
+// Backup. Backups of any other version are refused on restore.
+const backupFormatVersion = 1
+
+// backupEnvelope is the encoded form of a backup: the KeyData along with the
+// version of the format it was taken in
+type backupEnvelope struct {
+	Version int `json:"version"`
+	KeyData
+}
+
+// encodeBackup returns the base64-encoded backup of the given KeyData
+func encodeBackup(keyData *KeyData) (string, error) {
+	encoded, err := json.Marshal(&backupEnvelope{
+		Version: backupFormatVersion,
+		KeyData: *keyData,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// decodeBackup checks the format version of a backup and returns its KeyData
+func decodeBackup(backup string) (*KeyData, error) {
+	backupBytes, err := base64.StdEncoding.DecodeString(backup)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to base64-decode the backup"}
+	}
+
+	envelope := &backupEnvelope{
+		KeyData: KeyData{
+			Policy: &Policy{
+				Keys: keyEntryMap{},
+			},
+		},
+	}
+	if err := jsonutil.DecodeJSON(backupBytes, envelope); err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to decode the backup: %v", err)}
+	}
+	if envelope.Version != backupFormatVersion {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported backup format version %d", envelope.Version)}
+	}
+
+	return &envelope.KeyData, nil
 }
 
 // ArchivedKeys stores old keys. This is used to keep the key loading time sane
@@ -277,6 +480,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	case p.MinDecryptionVersion > p.LatestVersion:
 		return fmt.Errorf("minimum decryption version of %d is greater than the latest version %d",
 			p.MinDecryptionVersion, p.LatestVersion)
+	case p.MinAvailableVersion > p.MinDecryptionVersion:
+		return fmt.Errorf("minimum available version of %d is greater than the minimum decryption version %d",
+			p.MinAvailableVersion, p.MinDecryptionVersion)
 	}
 
 	archive, err := p.LoadArchive(storage)
@@ -288,7 +494,7 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 		// Need to move keys *from* archive
 
 		for i := p.MinDecryptionVersion; i <= p.LatestVersion; i++ {
-			p.Keys[i] = archive.Keys[i]
+			p.Keys[i] = archive.Keys[i-p.ArchiveMinVersion]
 		}
 
 		return nil
@@ -299,9 +505,9 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	// We need a size that is equivalent to the latest version (number of keys)
 	// but adding one since slice numbering starts at 0 and we're indexing by
 	// key version
-	if len(archive.Keys) < p.LatestVersion+1 {
+	if len(archive.Keys)+p.ArchiveMinVersion < p.LatestVersion+1 {
 		// Increase the size of the archive slice
-		newKeys := make([]KeyEntry, p.LatestVersion+1)
+		newKeys := make([]KeyEntry, p.LatestVersion-p.ArchiveMinVersion+1)
 		copy(newKeys, archive.Keys)
 		archive.Keys = newKeys
 	}
@@ -309,10 +515,16 @@ func (p *Policy) handleArchiving(storage logical.Storage) error {
 	// We are storing all keys in the archive, so we ensure that it is up to
 	// date up to p.LatestVersion
 	for i := p.ArchiveVersion + 1; i <= p.LatestVersion; i++ {
-		archive.Keys[i] = p.Keys[i]
+		archive.Keys[i-p.ArchiveMinVersion] = p.Keys[i]
 		p.ArchiveVersion = i
 	}
 
+	// Drop the trimmed versions from the archive
+	if p.ArchiveMinVersion < p.MinAvailableVersion {
+		archive.Keys = archive.Keys[p.MinAvailableVersion-p.ArchiveMinVersion:]
+		p.ArchiveMinVersion = p.MinAvailableVersion
+	}
+
 	err = p.storeArchive(archive, storage)
 	if err != nil {
 		return err
@@ -355,6 +567,87 @@ func (p *Policy) Serialize() ([]byte, error) {
 	return json.Marshal(p)
 }
 
+// Trim permanently removes the key versions below the given version, both
+// from the key map and from the archive. The version can be at most the
+// minimum decryption and encryption versions. If persisting fails, the policy
+// is left unchanged.
+func (p *Policy) Trim(storage logical.Storage, minAvailableVersion int) error {
+	switch {
+	case minAvailableVersion <= 0:
+		return errutil.UserError{Err: "minimum available version must be positive"}
+	case minAvailableVersion < p.MinAvailableVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version cannot be lowered from %d", p.MinAvailableVersion)}
+	case minAvailableVersion > p.MinDecryptionVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version of %d cannot be greater than the minimum decryption version %d", minAvailableVersion, p.MinDecryptionVersion)}
+	case p.MinEncryptionVersion > 0 && minAvailableVersion > p.MinEncryptionVersion:
+		return errutil.UserError{Err: fmt.Sprintf("minimum available version of %d cannot be greater than the minimum encryption version %d", minAvailableVersion, p.MinEncryptionVersion)}
+	}
+
+	if minAvailableVersion == p.MinAvailableVersion {
+		return nil
+	}
+
+	// Keep the current state around so that a failure to persist does not
+	// leave the policy out of sync with its archive
+	prevArchive, err := p.LoadArchive(storage)
+	if err != nil {
+		return err
+	}
+	prevMinAvailableVersion := p.MinAvailableVersion
+	prevArchiveMinVersion := p.ArchiveMinVersion
+	prevKeys := keyEntryMap{}
+	for k, v := range p.Keys {
+		prevKeys[k] = v
+	}
+
+	p.MinAvailableVersion = minAvailableVersion
+	if err := p.Persist(storage); err != nil {
+		p.MinAvailableVersion = prevMinAvailableVersion
+		p.ArchiveMinVersion = prevArchiveMinVersion
+		p.Keys = prevKeys
+
+		// The archive is written before the policy, so put it back in case
+		// only the policy failed to be written
+		if archiveErr := p.storeArchive(prevArchive, storage); archiveErr != nil {
+			return fmt.Errorf("failed to trim key versions: %v; failed to restore archive: %v", err, archiveErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Backup returns a versioned, base64-encoded backup of the policy along with
+// its archived key versions. The backup holds the key material in the clear,
+// so that it can be restored into any mount, which requires the policy to
+// allow plaintext backups. The policy must be locked exclusively since the
+// backup is recorded in it.
+func (p *Policy) Backup(storage logical.Storage) (string, error) {
+	if !p.AllowPlaintextBackup {
+		return "", errutil.UserError{Err: "plaintext backup is disallowed on the policy"}
+	}
+
+	// Record the backup in the policy before taking it so that the backup
+	// carries it too
+	p.BackupInfo = &BackupInfo{
+		Time:    time.Now(),
+		Version: p.LatestVersion,
+	}
+	if err := p.Persist(storage); err != nil {
+		return "", fmt.Errorf("failed to persist policy with backup info: %v", err)
+	}
+
+	archive, err := p.LoadArchive(storage)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeBackup(&KeyData{
+		Policy:       p,
+		ArchivedKeys: archive,
+	})
+}
+
 func (p *Policy) NeedsUpgrade() bool {
 	// Ensure we've moved from Key -> Keys
 	if p.Key != nil && len(p.Key) > 0 {
@@ -486,7 +779,7 @@ func (p *Policy) DeriveKey(context []byte, ver int) ([]byte, error) {
 		}
 
 		switch p.Type {
-		case KeyType_AES256_GCM96:
+		case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 			n, err := derBytes.ReadFrom(limReader)
 			if err != nil {
 				return nil, errutil.InternalError{Err: fmt.Sprintf("error reading returned derived bytes: %v", err)}
@@ -519,13 +812,6 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 		return "", errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
 	}
 
-	// Guard against a potentially invalid key type
-	switch p.Type {
-	case KeyType_AES256_GCM96:
-	default:
-		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
-	}
-
 	// Decode the plaintext value
 	plaintext, err := base64.StdEncoding.DecodeString(value)
 	if err != nil {
@@ -543,67 +829,107 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 		return "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
 	}
 
-	// Derive the key that should be used
-	key, err := p.DeriveKey(context, ver)
-	if err != nil {
-		return "", err
-	}
-
-	// Guard against a potentially invalid key type
+	var full []byte
 	switch p.Type {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		full, err = p.symmetricEncrypt(ver, context, nonce, plaintext)
+		if err != nil {
+			return "", err
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+		full, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, &key.PublicKey, plaintext, nil)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("failed to RSA encrypt the plaintext: %v", err)}
+		}
+
 	default:
 		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
 
-	// Setup the cipher
-	aesCipher, err := aes.NewCipher(key)
+	// Convert to base64
+	encoded := base64.StdEncoding.EncodeToString(full)
+
+	// Prepend some information
+	encoded = "vault:v" + strconv.Itoa(ver) + ":" + encoded
+
+	return encoded, nil
+}
+
+// symmetricEncrypt encrypts the plaintext with the AEAD of the given key
+// version, returning the nonce followed by the ciphertext
+func (p *Policy) symmetricEncrypt(ver int, context, nonce, plaintext []byte) ([]byte, error) {
+	// Derive the key that should be used
+	key, err := p.DeriveKey(context, ver)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, err
 	}
 
-	// Setup the GCM AEAD
-	gcm, err := cipher.NewGCM(aesCipher)
+	aead, err := p.newAEAD(key)
 	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
+		return nil, err
 	}
 
 	if p.ConvergentEncryption {
 		switch p.ConvergentVersion {
 		case 1:
-			if len(nonce) != gcm.NonceSize() {
-				return "", errutil.UserError{Err: fmt.Sprintf("base64-decoded nonce must be %d bytes long when using convergent encryption with this key", gcm.NonceSize())}
+			if len(nonce) != aead.NonceSize() {
+				return nil, errutil.UserError{Err: fmt.Sprintf("base64-decoded nonce must be %d bytes long when using convergent encryption with this key", aead.NonceSize())}
 			}
 		default:
 			nonceHmac := hmac.New(sha256.New, context)
 			nonceHmac.Write(plaintext)
 			nonceSum := nonceHmac.Sum(nil)
-			nonce = nonceSum[:gcm.NonceSize()]
+			nonce = nonceSum[:aead.NonceSize()]
 		}
 	} else {
 		// Compute random nonce
-		nonce, err = uuid.GenerateRandomBytes(gcm.NonceSize())
+		nonce, err = uuid.GenerateRandomBytes(aead.NonceSize())
 		if err != nil {
-			return "", errutil.InternalError{Err: err.Error()}
+			return nil, errutil.InternalError{Err: err.Error()}
 		}
 	}
 
-	// Encrypt and tag with GCM
-	out := gcm.Seal(nil, nonce, plaintext, nil)
+	// Encrypt and tag
+	out := aead.Seal(nil, nonce, plaintext, nil)
 
 	// Place the encrypted data after the nonce
-	full := out
 	if !p.ConvergentEncryption || p.ConvergentVersion > 1 {
-		full = append(nonce, out...)
+		out = append(nonce, out...)
 	}
 
-	// Convert to base64
-	encoded := base64.StdEncoding.EncodeToString(full)
+	return out, nil
+}
 
-	// Prepend some information
-	encoded = "vault:v" + strconv.Itoa(ver) + ":" + encoded
+// newAEAD returns the AEAD of the key type of the policy keyed with the given
+// key
+func (p *Policy) newAEAD(key []byte) (cipher.AEAD, error) {
+	switch p.Type {
+	case KeyType_AES256_GCM96:
+		// Setup the cipher
+		aesCipher, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
 
-	return encoded, nil
+		// Setup the GCM AEAD
+		gcm, err := cipher.NewGCM(aesCipher)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
+		return gcm, nil
+
+	case KeyType_ChaCha20_Poly1305:
+		aead, err := chacha20poly1305.New(key)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
+		return aead, nil
+
+	default:
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
+	}
 }
 
 func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
@@ -644,50 +970,53 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 		return "", errutil.UserError{Err: ErrTooOld}
 	}
 
-	// Derive the key that should be used
-	key, err := p.DeriveKey(context, ver)
-	if err != nil {
-		return "", err
-	}
-
-	// Guard against a potentially invalid key type
-	switch p.Type {
-	case KeyType_AES256_GCM96:
-	default:
-		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
-	}
-
 	// Decode the base64
 	decoded, err := base64.StdEncoding.DecodeString(splitVerCiphertext[1])
 	if err != nil {
 		return "", errutil.UserError{Err: "invalid ciphertext: could not decode base64"}
 	}
 
-	// Setup the cipher
-	aesCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
-	}
+	var plain []byte
+	switch p.Type {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		// Derive the key that should be used
+		key, err := p.DeriveKey(context, ver)
+		if err != nil {
+			return "", err
+		}
 
-	// Setup the GCM AEAD
-	gcm, err := cipher.NewGCM(aesCipher)
-	if err != nil {
-		return "", errutil.InternalError{Err: err.Error()}
-	}
+		aead, err := p.newAEAD(key)
+		if err != nil {
+			return "", err
+		}
 
-	// Extract the nonce and ciphertext
-	var ciphertext []byte
-	if p.ConvergentEncryption && p.ConvergentVersion < 2 {
-		ciphertext = decoded
-	} else {
-		nonce = decoded[:gcm.NonceSize()]
-		ciphertext = decoded[gcm.NonceSize():]
-	}
+		// Extract the nonce and ciphertext
+		var ciphertext []byte
+		if p.ConvergentEncryption && p.ConvergentVersion < 2 {
+			ciphertext = decoded
+		} else {
+			if len(decoded) < aead.NonceSize() {
+				return "", errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+			}
+			nonce = decoded[:aead.NonceSize()]
+			ciphertext = decoded[aead.NonceSize():]
+		}
 
-	// Verify and Decrypt
-	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+		// Verify and Decrypt
+		plain, err = aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			return "", errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+		plain, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, key, decoded, nil)
+		if err != nil {
+			return "", errutil.UserError{Err: "invalid ciphertext: unable to decrypt"}
+		}
+
+	default:
+		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
 
 	return base64.StdEncoding.EncodeToString(plain), nil
@@ -708,7 +1037,11 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return p.Keys[version].HMACKey, nil
 }
 
-func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
+// Sign signs the input with the given key version. The input of key types
+// that hash their signature input must already be hashed with the given hash
+// algorithm. The signature algorithm only applies to RSA keys, and defaults
+// to PSS.
+func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, sigAlgorithm string) (*SigningResult, error) {
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
 	}
@@ -728,11 +1061,11 @@ func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
 	var pubKey []byte
 	var err error
 	switch p.Type {
-	case KeyType_ECDSA_P256:
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
 		keyParams := p.Keys[ver]
 		key := &ecdsa.PrivateKey{
 			PublicKey: ecdsa.PublicKey{
-				Curve: elliptic.P256(),
+				Curve: p.Type.Curve(),
 				X:     keyParams.EC_X,
 				Y:     keyParams.EC_Y,
 			},
@@ -773,6 +1106,29 @@ func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
 			return nil, err
 		}
 
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+
+		algo, ok := cryptoHashMap[hashAlgorithm]
+		if !ok {
+			return nil, errutil.InternalError{Err: "unsupported hash algorithm"}
+		}
+
+		switch sigAlgorithm {
+		case SignatureAlgorithmPSS, "":
+			if algo == crypto.Hash(0) {
+				return nil, errutil.UserError{Err: "hash algorithm \"none\" cannot be used with PSS signatures"}
+			}
+			sig, err = rsa.SignPSS(rand.Reader, key, algo, input, nil)
+		case SignatureAlgorithmPKCS1v15:
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key, algo, input)
+		default:
+			return nil, errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %q", sigAlgorithm)}
+		}
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("failed to sign the input: %v", err)}
+		}
+
 	default:
 		return nil, fmt.Errorf("unsupported key type %v", p.Type)
 	}
@@ -788,7 +1144,9 @@ func (p *Policy) Sign(ver int, context, input []byte) (*SigningResult, error) {
 	return res, nil
 }
 
-func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error) {
+// VerifySignature verifies the signature of the input, which must be hashed
+// and signed the same way as for Sign
+func (p *Policy) VerifySignature(context, input []byte, sig string, hashAlgorithm HashType, sigAlgorithm string) (bool, error) {
 	if !p.Type.SigningSupported() {
 		return false, errutil.UserError{Err: fmt.Sprintf("message verification not supported for key type %v", p.Type)}
 	}
@@ -822,7 +1180,7 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 	}
 
 	switch p.Type {
-	case KeyType_ECDSA_P256:
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
 		var ecdsaSig ecdsaSignature
 		rest, err := asn1.Unmarshal(sigBytes, &ecdsaSig)
 		if err != nil {
@@ -834,7 +1192,7 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 
 		keyParams := p.Keys[ver]
 		key := &ecdsa.PublicKey{
-			Curve: elliptic.P256(),
+			Curve: p.Type.Curve(),
 			X:     keyParams.EC_X,
 			Y:     keyParams.EC_Y,
 		}
@@ -857,6 +1215,28 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 
 		return ed25519.Verify(key.Public().(ed25519.PublicKey), input, sigBytes), nil
 
+	case KeyType_RSA2048, KeyType_RSA4096:
+		key := p.Keys[ver].RSAKey
+
+		algo, ok := cryptoHashMap[hashAlgorithm]
+		if !ok {
+			return false, errutil.InternalError{Err: "unsupported hash algorithm"}
+		}
+
+		switch sigAlgorithm {
+		case SignatureAlgorithmPSS, "":
+			if algo == crypto.Hash(0) {
+				return false, errutil.UserError{Err: "hash algorithm \"none\" cannot be used with PSS signatures"}
+			}
+			err = rsa.VerifyPSS(&key.PublicKey, algo, input, sigBytes, nil)
+		case SignatureAlgorithmPKCS1v15:
+			err = rsa.VerifyPKCS1v15(&key.PublicKey, algo, input, sigBytes)
+		default:
+			return false, errutil.UserError{Err: fmt.Sprintf("unsupported signature algorithm %q", sigAlgorithm)}
+		}
+
+		return err == nil, nil
+
 	default:
 		return false, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -864,6 +1244,28 @@ func (p *Policy) VerifySignature(context, input []byte, sig string) (bool, error
 	return false, errutil.InternalError{Err: "no valid key type found"}
 }
 
+// LastRotationTime returns the creation time of the latest version of the
+// key
+func (p *Policy) LastRotationTime() time.Time {
+	entry, ok := p.Keys[p.LatestVersion]
+	if !ok {
+		return time.Time{}
+	}
+	if entry.CreationTime.IsZero() {
+		return time.Unix(entry.DeprecatedCreationTime, 0)
+	}
+	return entry.CreationTime
+}
+
+// NeedsAutoRotation returns whether the automatic rotation period of the key
+// has elapsed since its last rotation
+func (p *Policy) NeedsAutoRotation(now time.Time) bool {
+	if p.AutoRotatePeriod <= 0 {
+		return false
+	}
+	return !now.Before(p.LastRotationTime().Add(p.AutoRotatePeriod))
+}
+
 func (p *Policy) Rotate(storage logical.Storage) error {
 	if p.Keys == nil {
 		// This is an initial key rotation when generating a new policy. We
@@ -886,7 +1288,7 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 	entry.HMACKey = hmacKey
 
 	switch p.Type {
-	case KeyType_AES256_GCM96:
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		// Generate a 256bit key
 		newKey, err := uuid.GenerateRandomBytes(32)
 		if err != nil {
@@ -894,8 +1296,8 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 		}
 		entry.Key = newKey
 
-	case KeyType_ECDSA_P256:
-		privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		privKey, err := ecdsa.GenerateKey(p.Type.Curve(), rand.Reader)
 		if err != nil {
 			return err
 		}
@@ -923,6 +1325,21 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 		}
 		entry.Key = pri
 		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(pub)
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		bits := 2048
+		if p.Type == KeyType_RSA4096 {
+			bits = 4096
+		}
+		privKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return err
+		}
+		entry.RSAKey = privKey
+		entry.FormattedPublicKey, err = formatPublicKey(privKey.Public())
+		if err != nil {
+			return err
+		}
 	}
 
 	p.Keys[p.LatestVersion] = entry
@@ -937,6 +1354,109 @@ func (p *Policy) Rotate(storage logical.Storage) error {
 	return p.Persist(storage)
 }
 
+// Import adds a new key version made of the given key material. Symmetric
+// keys are given as raw bytes, asymmetric keys as PKCS#8 DER-encoded
+// private keys.
+func (p *Policy) Import(storage logical.Storage, key []byte) error {
+	if p.Keys == nil {
+		p.Keys = keyEntryMap{}
+	}
+
+	now := time.Now()
+	entry := KeyEntry{
+		CreationTime:           now,
+		DeprecatedCreationTime: now.Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	switch p.Type {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if len(key) != 32 {
+			return errutil.UserError{Err: fmt.Sprintf("key material for key type %v must be 32 bytes, got %d", p.Type, len(key))}
+		}
+		entry.Key = key
+
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		parsed, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("failed to parse PKCS#8 private key: %v", err)}
+		}
+		privKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve != p.Type.Curve() {
+			return errutil.UserError{Err: fmt.Sprintf("key material is not a private key of type %v", p.Type)}
+		}
+		entry.EC_D = privKey.D
+		entry.EC_X = privKey.X
+		entry.EC_Y = privKey.Y
+		entry.FormattedPublicKey, err = formatPublicKey(privKey.Public())
+		if err != nil {
+			return err
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		parsed, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("failed to parse PKCS#8 private key: %v", err)}
+		}
+		privKey, ok := parsed.(*rsa.PrivateKey)
+		bits := 2048
+		if p.Type == KeyType_RSA4096 {
+			bits = 4096
+		}
+		if !ok || privKey.N.BitLen() != bits {
+			return errutil.UserError{Err: fmt.Sprintf("key material is not a private key of type %v", p.Type)}
+		}
+		if err := privKey.Validate(); err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("invalid RSA private key: %v", err)}
+		}
+		entry.RSAKey = privKey
+		entry.FormattedPublicKey, err = formatPublicKey(privKey.Public())
+		if err != nil {
+			return err
+		}
+
+	default:
+		return errutil.UserError{Err: fmt.Sprintf("import is not supported for key type %v", p.Type)}
+	}
+
+	p.LatestVersion += 1
+	p.Keys[p.LatestVersion] = entry
+
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	if err := p.Persist(storage); err != nil {
+		delete(p.Keys, p.LatestVersion)
+		p.LatestVersion -= 1
+		return err
+	}
+
+	return nil
+}
+
+// formatPublicKey returns the PEM encoding of the PKIX form of the public
+// key
+func formatPublicKey(pub crypto.PublicKey) (string, error) {
+	derBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling public key: %s", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return "", fmt.Errorf("error PEM-encoding public key")
+	}
+	return string(pemBytes), nil
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
@@ -1,8 +1,10 @@
 package keysutil
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 )
@@ -123,7 +125,7 @@ func testArchivingUpgradeCommon(t *testing.T, lm *LockManager) {
 	// If we're caching, expire from the cache since we modified it
 	// under-the-hood
 	if lm.CacheActive() {
-		delete(lm.cache, "test")
+		lm.cache.Delete("test")
 	}
 
 	// Now get the policy again; the upgrade should happen automatically
@@ -141,7 +143,7 @@ func testArchivingUpgradeCommon(t *testing.T, lm *LockManager) {
 	// Let's check some deletion logic while we're at it
 
 	// The policy should be in there
-	if lm.CacheActive() && lm.cache["test"] == nil {
+	if lm.CacheActive() && lm.cache.Get("test") == nil {
 		t.Fatal("nil policy in cache")
 	}
 
@@ -152,7 +154,7 @@ func testArchivingUpgradeCommon(t *testing.T, lm *LockManager) {
 	}
 
 	// The policy should still be in there
-	if lm.CacheActive() && lm.cache["test"] == nil {
+	if lm.CacheActive() && lm.cache.Get("test") == nil {
 		t.Fatal("nil policy in cache")
 	}
 
@@ -177,7 +179,7 @@ func testArchivingUpgradeCommon(t *testing.T, lm *LockManager) {
 	}
 
 	// The policy should *not* be in there
-	if lm.CacheActive() && lm.cache["test"] != nil {
+	if lm.CacheActive() && lm.cache.Get("test") != nil {
 		t.Fatal("non-nil policy in cache")
 	}
 
@@ -349,3 +351,288 @@ func checkKeys(t *testing.T,
 		}
 	}
 }
+
+func Test_KeyTypes_Persistence(t *testing.T) {
+	for _, keyType := range []KeyType{KeyType_ChaCha20_Poly1305, KeyType_RSA2048} {
+		testKeyTypePersistence(t, keyType)
+	}
+}
+
+func testKeyTypePersistence(t *testing.T, keyType KeyType) {
+	// Without the cache, every lookup loads the policy from storage
+	lm := NewLockManager(false)
+	storage := &logical.InmemStorage{}
+	p, lock, _, err := lm.GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: keyType,
+		Name:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.RUnlock()
+
+	ciphertext, err := p.Encrypt(0, nil, nil, "dGhlIHF1aWNrIGJyb3duIGZveA==")
+	if err != nil {
+		t.Fatalf("%v: %v", keyType, err)
+	}
+	if err := p.Rotate(storage); err != nil {
+		t.Fatal(err)
+	}
+
+	p, lock, err = lm.GetPolicyShared(storage, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.RUnlock()
+	if p.Type != keyType || p.LatestVersion != 2 {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	plaintext, err := p.Decrypt(nil, nil, ciphertext)
+	if err != nil {
+		t.Fatalf("%v: %v", keyType, err)
+	}
+	if plaintext != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+		t.Fatalf("%v: bad plaintext %q", keyType, plaintext)
+	}
+}
+
+func Test_RSA_Signatures(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	p, lock, _, err := NewLockManager(true).GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: KeyType_RSA2048,
+		Name:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.RUnlock()
+
+	hf := HashFuncMap[HashTypeSHA2256]()
+	hf.Write([]byte("the quick brown fox"))
+	input := hf.Sum(nil)
+
+	for _, sigAlgorithm := range []string{"", SignatureAlgorithmPSS, SignatureAlgorithmPKCS1v15} {
+		sig, err := p.Sign(0, nil, input, HashTypeSHA2256, sigAlgorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid, err := p.VerifySignature(nil, input, sig.Signature, HashTypeSHA2256, sigAlgorithm)
+		if err != nil || !valid {
+			t.Fatalf("%q: valid: %v, err: %v", sigAlgorithm, valid, err)
+		}
+
+		// The signature does not verify with a different hash algorithm
+		valid, err = p.VerifySignature(nil, input, sig.Signature, HashTypeSHA2512, sigAlgorithm)
+		if err != nil || valid {
+			t.Fatalf("%q: valid: %v, err: %v", sigAlgorithm, valid, err)
+		}
+	}
+
+	// PSS requires the input to be hashed
+	if _, err := p.Sign(0, nil, input, HashTypeNone, SignatureAlgorithmPSS); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := p.Sign(0, nil, input, HashTypeSHA2256, "foobar"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Trim(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	p, lock, _, err := NewLockManager(true).GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: KeyType_AES256_GCM96,
+		Name:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.RUnlock()
+
+	for i := 2; i <= 10; i++ {
+		if err := p.Rotate(storage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := map[int]KeyEntry{}
+	for k, v := range p.Keys {
+		keys[k] = v
+	}
+
+	// Trimming is bounded by the min decryption and encryption versions
+	if err := p.Trim(storage, 5); err == nil {
+		t.Fatal("expected error")
+	}
+	p.MinDecryptionVersion = 7
+	p.MinEncryptionVersion = 8
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Trim(storage, 8); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := p.Trim(storage, 0); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := p.Trim(storage, 3); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := p.LoadArchive(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Keys) != 8 || p.ArchiveMinVersion != 3 || p.MinAvailableVersion != 3 {
+		t.Fatalf("bad: archive size %d, policy %#v", len(archive.Keys), p)
+	}
+
+	// Versions cannot come back once trimmed
+	if err := p.Trim(storage, 2); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Trimmed archives are still used to restore versions
+	p.MinDecryptionVersion = 3
+	p.MinEncryptionVersion = 0
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Keys) != 8 {
+		t.Fatalf("expected 8 keys, got %d", len(p.Keys))
+	}
+	for i := 3; i <= 10; i++ {
+		if !reflect.DeepEqual(p.Keys[i].Key, keys[i].Key) {
+			t.Fatalf("key %d mismatch", i)
+		}
+	}
+
+	// Rotations keep appending to the trimmed archive
+	if err := p.Rotate(storage); err != nil {
+		t.Fatal(err)
+	}
+	archive, err = p.LoadArchive(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Keys) != 9 || !reflect.DeepEqual(archive.Keys[8].Key, p.Keys[11].Key) {
+		t.Fatalf("bad archive: %d keys", len(archive.Keys))
+	}
+
+	// Setting the min decryption version to the min available version
+	// must still work
+	p.MinDecryptionVersion = 11
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	p.MinDecryptionVersion = 3
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Keys[3].Key, keys[3].Key) {
+		t.Fatal("key 3 mismatch")
+	}
+}
+
+func Test_LockManager_CacheSize(t *testing.T) {
+	lm := NewLockManager(false)
+	storage := &logical.InmemStorage{}
+
+	if err := lm.SetCacheSize(MinCacheSize - 1); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := lm.SetCacheSize(MinCacheSize); err != nil {
+		t.Fatal(err)
+	}
+	if lm.CacheSize() != MinCacheSize {
+		t.Fatalf("bad cache size: %d", lm.CacheSize())
+	}
+
+	// Create more policies than the cache holds
+	for i := 0; i <= MinCacheSize; i++ {
+		_, lock, _, err := lm.GetPolicyUpsert(PolicyRequest{
+			Storage: storage,
+			KeyType: KeyType_AES256_GCM96,
+			Name:    fmt.Sprintf("test%d", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lock.RUnlock()
+	}
+
+	// The least recently used policy was evicted but is still loaded from
+	// storage
+	if lm.cache.Get("test0") != nil {
+		t.Fatal("expected test0 to be evicted")
+	}
+	if lm.cache.Get(fmt.Sprintf("test%d", MinCacheSize)) == nil {
+		t.Fatal("expected the latest policy to be cached")
+	}
+	p, lock, err := lm.GetPolicyShared(storage, "test0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.RUnlock()
+	if p == nil || p.LatestVersion != 1 {
+		t.Fatalf("bad: %#v", p)
+	}
+	if lm.cache.Get("test0") == nil {
+		t.Fatal("expected test0 to be cached again")
+	}
+
+	// Caching can be made unbounded again
+	if err := lm.SetCacheSize(0); err != nil {
+		t.Fatal(err)
+	}
+	if lm.CacheSize() != 0 {
+		t.Fatalf("bad cache size: %d", lm.CacheSize())
+	}
+}
+
+func Test_LockManager_PolicyNeedsAutoRotation(t *testing.T) {
+	lm := NewLockManager(false)
+	storage := &logical.InmemStorage{}
+
+	p, lock, _, err := lm.GetPolicyUpsert(PolicyRequest{
+		Storage: storage,
+		KeyType: KeyType_AES256_GCM96,
+		Name:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.RUnlock()
+	p.AutoRotatePeriod = time.Hour
+	if err := p.Persist(storage); err != nil {
+		t.Fatal(err)
+	}
+	lm.InvalidatePolicy("test")
+
+	for _, tc := range []struct {
+		now      time.Time
+		expected bool
+	}{
+		{time.Now(), false},
+		{time.Now().Add(2 * time.Hour), true},
+	} {
+		required, err := lm.PolicyNeedsAutoRotation(storage, "test", tc.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if required != tc.expected {
+			t.Fatalf("expected %t at %s", tc.expected, tc.now)
+		}
+	}
+
+	// Checking does not load the policy into the cache
+	if lm.cache.Get("test") != nil {
+		t.Fatal("expected the policy not to be cached")
+	}
+
+	if required, err := lm.PolicyNeedsAutoRotation(storage, "missing", time.Now()); err != nil || required {
+		t.Fatalf("bad: %t, %v", required, err)
+	}
+}
@@ -0,0 +1,55 @@
+package namespace
+
+import (
+	"strings"
+)
+
+const (
+	// RootNamespaceID is the ID of the root namespace, which always exists
+	// and holds everything that isn't in a child namespace
+	RootNamespaceID = "root"
+)
+
+var (
+	// RootNamespace is the namespace that is used when no other namespace
+	// is selected
+	RootNamespace = &Namespace{
+		ID:   RootNamespaceID,
+		Path: "",
+	}
+)
+
+// Namespace is an isolated tenant of Vault, with its own mounts, policies,
+// tokens and identities. Namespaces are nested; the path of a namespace
+// includes the paths of all of its parents, and always ends with a slash
+// unless it is the root namespace.
+type Namespace struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+// HasParent returns whether the given namespace is an ancestor of this one.
+// A namespace is not its own parent.
+func (n *Namespace) HasParent(possibleParent *Namespace) bool {
+	if n == nil || possibleParent == nil || n.Path == possibleParent.Path {
+		return false
+	}
+	return strings.HasPrefix(n.Path, possibleParent.Path)
+}
+
+// TrimmedPath returns the given path with the path of the namespace
+// removed, making it relative to the namespace
+func (n *Namespace) TrimmedPath(path string) string {
+	return strings.TrimPrefix(path, n.Path)
+}
+
+// Canonicalize returns the canonical form of a namespace path, which has no
+// leading slash and a single trailing slash. The root namespace is
+// represented by the empty string.
+func Canonicalize(nsPath string) string {
+	nsPath = strings.Trim(nsPath, "/")
+	if nsPath == "" {
+		return ""
+	}
+	return nsPath + "/"
+}
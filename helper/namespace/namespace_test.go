@@ -0,0 +1,61 @@
+package namespace
+
+import (
+	"testing"
+)
+
+func TestNamespace_HasParent(t *testing.T) {
+	ns1 := &Namespace{ID: "ns1", Path: "ns1/"}
+	child := &Namespace{ID: "child", Path: "ns1/child/"}
+	ns2 := &Namespace{ID: "ns2", Path: "ns2/"}
+
+	cases := []struct {
+		ns       *Namespace
+		parent   *Namespace
+		expected bool
+	}{
+		{ns1, RootNamespace, true},
+		{child, RootNamespace, true},
+		{child, ns1, true},
+		{ns1, child, false},
+		{ns2, ns1, false},
+		{ns1, ns1, false},
+		{RootNamespace, RootNamespace, false},
+		{RootNamespace, ns1, false},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.ns.HasParent(tc.parent); actual != tc.expected {
+			t.Fatalf("%q has parent %q: expected %t, got %t", tc.ns.Path, tc.parent.Path, tc.expected, actual)
+		}
+	}
+}
+
+func TestNamespace_TrimmedPath(t *testing.T) {
+	ns := &Namespace{ID: "child", Path: "ns1/child/"}
+
+	if actual := ns.TrimmedPath("ns1/child/secret/foo"); actual != "secret/foo" {
+		t.Fatalf("bad: %q", actual)
+	}
+	if actual := RootNamespace.TrimmedPath("secret/foo"); actual != "secret/foo" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	cases := map[string]string{
+		"":               "",
+		"/":              "",
+		"ns1":            "ns1/",
+		"ns1/":           "ns1/",
+		"/ns1/child":     "ns1/child/",
+		"/ns1/child//":   "ns1/child/",
+		"ns1/child/leaf": "ns1/child/leaf/",
+	}
+
+	for input, expected := range cases {
+		if actual := Canonicalize(input); actual != expected {
+			t.Fatalf("%q: expected %q, got %q", input, expected, actual)
+		}
+	}
+}
@@ -116,7 +116,7 @@ func TestStoragePacker_SerializeDeserializeComplexItem(t *testing.T) {
 
 	alias1 := &identity.Alias{
 		ID:            "alias_id",
-		EntityID:      "entity_id",
+		CanonicalID:   "entity_id",
 		MountType:     "mount_type",
 		MountAccessor: "mount_accessor",
 		Metadata: map[string]string{
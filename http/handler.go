@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/errwrap"
@@ -32,6 +34,11 @@ const (
 	// not to use request forwarding
 	NoRequestForwardingHeaderName = "X-Vault-No-Request-Forwarding"
 
+	// NamespaceHeaderName is the name of the header containing the path of
+	// the namespace the request is made in. The path of the request is then
+	// relative to that namespace.
+	NamespaceHeaderName = "X-Vault-Namespace"
+
 	// MaxRequestSize is the maximum accepted request size. This is to prevent
 	// a denial of service attack where no Content-Length is provided and the server
 	// is fed ever more data until it exhausts memory.
@@ -333,6 +340,14 @@ func respondErrorCommon(w http.ResponseWriter, req *logical.Request, resp *logic
 		return false
 	}
 
+	// Tell clients rejected by a quota when they may try again
+	if errwrap.ContainsType(err, new(logical.QuotaExceededError)) {
+		quotaErr := errwrap.GetType(err, new(logical.QuotaExceededError)).(*logical.QuotaExceededError)
+		if quotaErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+		}
+	}
+
 	respondError(w, statusCode, newErr)
 	return true
 }
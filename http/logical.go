@@ -2,6 +2,7 @@ package http
 
 import (
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
@@ -10,12 +11,37 @@ import (
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/vault"
 )
 
 type PrepareRequestFunc func(*vault.Core, *logical.Request) error
 
+// rawRequestContentTypes are the content types of request bodies that are
+// passed to backends as is, in the logical.HTTPRawBody field of the request
+// data, rather than parsed as JSON. Each is only passed through to the
+// endpoints of the protocol it belongs to; elsewhere the body is parsed as
+// usual.
+var rawRequestContentTypes = map[string]func(path string) bool{
+	// OCSP requests are posted to the ocsp endpoint of a PKI mount
+	"application/ocsp-request": func(path string) bool {
+		return strings.HasSuffix(path, "/ocsp")
+	},
+
+	// ACME requests are posted to the acme endpoints of a PKI mount
+	"application/jose+json": func(path string) bool {
+		return strings.Contains(path, "/acme/")
+	},
+}
+
+// isRawRequest reports whether the body of a request with the given content
+// type to the given path is passed to the backend as is
+func isRawRequest(contentType, path string) bool {
+	matches, ok := rawRequestContentTypes[contentType]
+	return ok && matches(path)
+}
+
 func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) (*logical.Request, int, error) {
 	// Determine the path...
 	if !strings.HasPrefix(r.URL.Path, "/v1/") {
@@ -26,12 +52,20 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		return nil, http.StatusNotFound, nil
 	}
 
+	// Requests made within a namespace are relative to it
+	if ns := r.Header.Get(NamespaceHeaderName); ns != "" {
+		path = namespace.Canonicalize(ns) + path
+	}
+
 	// Determine the operation
 	var op logical.Operation
+	var data map[string]interface{}
 	switch r.Method {
 	case "DELETE":
 		op = logical.DeleteOperation
-	case "GET":
+	case "GET", "HEAD":
+		// HEAD requests are reads whose body is discarded by the server, as
+		// used by protocols such as ACME to fetch headers only
 		op = logical.ReadOperation
 		// Need to call ParseForm to get query params loaded
 		queryVals := r.URL.Query()
@@ -45,6 +79,24 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 				op = logical.ListOperation
 			}
 		}
+
+		// Pass the remaining query parameters through to reads, e.g. to
+		// select a particular version of a secret
+		if op == logical.ReadOperation {
+			for k, v := range queryVals {
+				if k == "list" || len(v) == 0 {
+					continue
+				}
+				if data == nil {
+					data = make(map[string]interface{})
+				}
+				if len(v) == 1 {
+					data[k] = v[0]
+				} else {
+					data[k] = v
+				}
+			}
+		}
 	case "POST", "PUT":
 		op = logical.UpdateOperation
 	case "LIST":
@@ -61,15 +113,26 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	}
 
 	// Parse the request if we can
-	var data map[string]interface{}
 	if op == logical.UpdateOperation {
-		err := parseRequest(r, w, &data)
-		if err == io.EOF {
-			data = nil
-			err = nil
-		}
-		if err != nil {
-			return nil, http.StatusBadRequest, err
+		if isRawRequest(r.Header.Get("Content-Type"), path) {
+			// Requests of some protocols, such as OCSP, are not JSON; hand
+			// their body to the backend untouched
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			data = map[string]interface{}{
+				logical.HTTPRawBody: body,
+			}
+		} else {
+			err := parseRequest(r, w, &data)
+			if err == io.EOF {
+				data = nil
+				err = nil
+			}
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
 		}
 	}
 
@@ -239,6 +302,18 @@ func respondRaw(w http.ResponseWriter, r *http.Request, resp *logical.Response)
 	}
 
 	// Write the response
+	if headersRaw, ok := resp.Data[logical.HTTPRawHeaders]; ok {
+		headers, ok := headersRaw.(map[string][]string)
+		if !ok {
+			retErr(w, "cannot decode headers")
+			return
+		}
+		for k, values := range headers {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
 	if contentType != "" {
 		w.Header().Set("Content-Type", contentType)
 	}
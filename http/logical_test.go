@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strconv"
 	"strings"
@@ -13,7 +14,9 @@ import (
 
 	log "github.com/mgutz/logxi/v1"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/physical/inmem"
 	"github.com/hashicorp/vault/vault"
@@ -65,6 +68,44 @@ func TestLogical(t *testing.T) {
 	testResponseStatus(t, resp, 404)
 }
 
+func TestLogical_versionedRead(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPost(t, token, addr+"/v1/sys/mounts/versioned", map[string]interface{}{
+		"type": "kv",
+		"options": map[string]interface{}{
+			"version": "2",
+		},
+	})
+	testResponseStatus(t, resp, 204)
+
+	for _, value := range []string{"one", "two"} {
+		resp = testHttpPut(t, token, addr+"/v1/versioned/data/foo", map[string]interface{}{
+			"data": map[string]interface{}{
+				"value": value,
+			},
+		})
+		testResponseStatus(t, resp, 200)
+	}
+
+	// The version query parameter selects an older version
+	resp = testHttpGet(t, token, addr+"/v1/versioned/data/foo?version=1")
+	testResponseStatus(t, resp, 200)
+
+	var actual map[string]interface{}
+	testResponseBody(t, resp, &actual)
+	secret := actual["data"].(map[string]interface{})
+	if secret["data"].(map[string]interface{})["value"] != "one" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if secret["metadata"].(map[string]interface{})["version"] != json.Number("1") {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestLogical_noExist(t *testing.T) {
 	core, _, token := vault.TestCoreUnsealed(t)
 	ln, addr := TestServer(t, core)
@@ -300,3 +341,103 @@ func TestLogical_ListSuffix(t *testing.T) {
 		t.Fatal("trailing slash not found on path")
 	}
 }
+
+func TestLogical_RawRequestBody(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	body := []byte{0x30, 0x03, 0x02, 0x01, 0x01}
+
+	// OCSP requests to the ocsp endpoint are passed through as is
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:8200/v1/pki/ocsp", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	lreq, status, err := buildLogicalRequest(core, httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("got status %d", status)
+	}
+	if !reflect.DeepEqual(lreq.Data[logical.HTTPRawBody], body) {
+		t.Fatalf("bad: %#v", lreq.Data)
+	}
+
+	// Elsewhere the body is parsed as JSON
+	req, _ = http.NewRequest("POST", "http://127.0.0.1:8200/v1/secret/foo", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	_, status, err = buildLogicalRequest(core, httptest.NewRecorder(), req)
+	if err == nil || status != http.StatusBadRequest {
+		t.Fatalf("expected a bad request, got %d: %v", status, err)
+	}
+}
+
+func TestLogical_namespace(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/namespaces/ns1", nil)
+	testResponseStatus(t, resp, 200)
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken(token)
+	client.SetNamespace("ns1")
+
+	// Paths are relative to the namespace given in the header
+	if err := client.Sys().Mount("secret", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{"data": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same secret is reachable through the path prefix of the namespace
+	client.ClearNamespace()
+	secret, err := client.Logical().Read("ns1/secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["data"] != "bar" {
+		t.Fatalf("bad: %#v", secret)
+	}
+
+	// The secret of the root namespace is untouched
+	secret, err = client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != nil {
+		t.Fatalf("bad: %#v", secret)
+	}
+}
+
+func TestLogical_rateLimitQuota(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+	TestServerAuth(t, addr, token)
+
+	resp := testHttpPut(t, token, addr+"/v1/sys/quotas/rate-limit/secret", map[string]interface{}{
+		"path":     "secret/",
+		"rate":     1,
+		"interval": "1h",
+	})
+	testResponseStatus(t, resp, 204)
+
+	resp = testHttpGet(t, token, addr+"/v1/secret/foo")
+	testResponseStatus(t, resp, 404)
+
+	resp = testHttpGet(t, token, addr+"/v1/secret/foo")
+	testResponseStatus(t, resp, 429)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter <= 0 || retryAfter > 3600 {
+		t.Fatalf("bad: %d", retryAfter)
+	}
+}
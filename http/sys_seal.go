@@ -121,9 +121,15 @@ func handleSysUnseal(core *vault.Core) http.Handler {
 			}
 
 			// Attempt the unseal
-			if _, err := core.Unseal(key); err != nil {
+			unseal := core.Unseal
+			if req.Migrate {
+				unseal = core.UnsealMigrate
+			}
+			if _, err := unseal(key); err != nil {
 				switch {
 				case errwrap.ContainsType(err, new(vault.ErrInvalidKey)):
+				case errwrap.Contains(err, vault.ErrSealMigrationInProgress.Error()):
+				case errwrap.Contains(err, vault.ErrNoSealMigration.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierInvalidKey.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierNotInit.Error()):
 				case errwrap.Contains(err, vault.ErrBarrierSealed.Error()):
@@ -160,7 +166,15 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	sealConfig, err := core.SealAccess().BarrierConfig()
+	// While migrating between seals, report the keys that have to be
+	// provided to complete the migration
+	migration := core.IsInSealMigration()
+	var sealConfig *vault.SealConfig
+	if migration {
+		sealConfig, err = core.SealMigrationConfig()
+	} else {
+		sealConfig, err = core.SealAccess().BarrierConfig()
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err)
 		return
@@ -190,18 +204,22 @@ func handleSysSealStatusRaw(core *vault.Core, w http.ResponseWriter, r *http.Req
 	progress, nonce := core.SecretProgress()
 
 	respondOk(w, &SealStatusResponse{
-		Sealed:      sealed,
-		T:           sealConfig.SecretThreshold,
-		N:           sealConfig.SecretShares,
-		Progress:    progress,
-		Nonce:       nonce,
-		Version:     version.GetVersion().VersionNumber(),
-		ClusterName: clusterName,
-		ClusterID:   clusterID,
+		Type:         sealConfig.Type,
+		Sealed:       sealed,
+		T:            sealConfig.SecretThreshold,
+		N:            sealConfig.SecretShares,
+		Progress:     progress,
+		Nonce:        nonce,
+		Version:      version.GetVersion().VersionNumber(),
+		ClusterName:  clusterName,
+		ClusterID:    clusterID,
+		Migration:    migration,
+		RecoverySeal: core.SealAccess().RecoveryKeySupported(),
 	})
 }
 
 type SealStatusResponse struct {
+	Type        string `json:"type"`
 	Sealed      bool   `json:"sealed"`
 	T           int    `json:"t"`
 	N           int    `json:"n"`
@@ -210,9 +228,13 @@ type SealStatusResponse struct {
 	Version     string `json:"version"`
 	ClusterName string `json:"cluster_name,omitempty"`
 	ClusterID   string `json:"cluster_id,omitempty"`
+
+	Migration    bool `json:"migration"`
+	RecoverySeal bool `json:"recovery_seal"`
 }
 
 type UnsealRequest struct {
-	Key   string
-	Reset bool
+	Key     string
+	Reset   bool
+	Migrate bool
 }
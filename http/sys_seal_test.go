@@ -26,11 +26,14 @@ func TestSysSealStatus(t *testing.T) {
 
 	var actual map[string]interface{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        json.Number("3"),
-		"n":        json.Number("3"),
-		"progress": json.Number("0"),
-		"nonce":    "",
+		"sealed":        true,
+		"type":          "shamir",
+		"recovery_seal": false,
+		"migration":     false,
+		"t":             json.Number("3"),
+		"n":             json.Number("3"),
+		"progress":      json.Number("0"),
+		"nonce":         "",
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
@@ -114,11 +117,14 @@ func TestSysUnseal(t *testing.T) {
 
 		var actual map[string]interface{}
 		expected := map[string]interface{}{
-			"sealed":   true,
-			"t":        json.Number("3"),
-			"n":        json.Number("3"),
-			"progress": json.Number(fmt.Sprintf("%d", i+1)),
-			"nonce":    "",
+			"sealed":        true,
+			"type":          "shamir",
+			"recovery_seal": false,
+			"migration":     false,
+			"t":             json.Number("3"),
+			"n":             json.Number("3"),
+			"progress":      json.Number(fmt.Sprintf("%d", i+1)),
+			"nonce":         "",
 		}
 		if i == len(keys)-1 {
 			expected["sealed"] = false
@@ -163,6 +169,19 @@ func TestSysUnseal_badKey(t *testing.T) {
 	testResponseStatus(t, resp, 400)
 }
 
+func TestSysUnseal_migrateNoMigration(t *testing.T) {
+	core := vault.TestCore(t)
+	keys, _ := vault.TestCoreInit(t, core)
+	ln, addr := TestServer(t, core)
+	defer ln.Close()
+
+	resp := testHttpPut(t, "", addr+"/v1/sys/unseal", map[string]interface{}{
+		"key":     hex.EncodeToString(keys[0]),
+		"migrate": true,
+	})
+	testResponseStatus(t, resp, 400)
+}
+
 func TestSysUnseal_Reset(t *testing.T) {
 	core := vault.TestCore(t)
 	ln, addr := TestServer(t, core)
@@ -192,10 +211,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 		var actual map[string]interface{}
 		expected := map[string]interface{}{
-			"sealed":   true,
-			"t":        json.Number("3"),
-			"n":        json.Number("5"),
-			"progress": json.Number(strconv.Itoa(i + 1)),
+			"sealed":        true,
+			"type":          "shamir",
+			"recovery_seal": false,
+			"migration":     false,
+			"t":             json.Number("3"),
+			"n":             json.Number("5"),
+			"progress":      json.Number(strconv.Itoa(i + 1)),
 		}
 		testResponseStatus(t, resp, 200)
 		testResponseBody(t, resp, &actual)
@@ -228,10 +250,13 @@ func TestSysUnseal_Reset(t *testing.T) {
 
 	actual = map[string]interface{}{}
 	expected := map[string]interface{}{
-		"sealed":   true,
-		"t":        json.Number("3"),
-		"n":        json.Number("5"),
-		"progress": json.Number("0"),
+		"sealed":        true,
+		"type":          "shamir",
+		"recovery_seal": false,
+		"migration":     false,
+		"t":             json.Number("3"),
+		"n":             json.Number("5"),
+		"progress":      json.Number("0"),
 	}
 	testResponseStatus(t, resp, 200)
 	testResponseBody(t, resp, &actual)
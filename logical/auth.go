@@ -59,6 +59,13 @@ type Auth struct {
 	// Alias is the information about the authenticated client returned by
 	// the auth backend
 	Alias *Alias `json:"alias" structs:"alias" mapstructure:"alias"`
+
+	// GroupAliases are the identifiers of the groups in the authentication
+	// source to which the authenticated client belongs to. These are used
+	// to manage the client's memberships of external identity groups. A nil
+	// value leaves the memberships untouched, while an empty value removes
+	// the client from all the external groups of the backend.
+	GroupAliases []*Alias `json:"group_aliases" structs:"group_aliases" mapstructure:"group_aliases"`
 }
 
 func (a *Auth) GoString() string {
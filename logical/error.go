@@ -1,5 +1,7 @@
 package logical
 
+import "time"
+
 type HTTPCodedError interface {
 	Error() string
 	Code() int
@@ -48,3 +50,20 @@ type ReplicationCodedError struct {
 func (r *ReplicationCodedError) Error() string {
 	return r.Msg
 }
+
+// QuotaExceededError is returned when a request is rejected by a quota.
+// RetryAfter is how long the client should wait before retrying, if known.
+type QuotaExceededError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return e.Err.Error()
+}
+
+// WrappedErrors returns the quota error, so that it can be found within
+// wrapped errors
+func (e *QuotaExceededError) WrappedErrors() []error {
+	return []error{e.Err}
+}
@@ -101,6 +101,10 @@ type BackendConfig struct {
 
 	// Config is the opaque user configuration provided when mounting
 	Config map[string]string
+
+	// MountPoint is the path the backend is mounted at, which backends may
+	// use to label the metrics they emit
+	MountPoint string
 }
 
 // Factory is the factory function to create a logical backend.
@@ -260,4 +260,12 @@ var (
 
 	// ErrPermissionDenied is returned if the client is not authorized
 	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrRateLimitQuotaExceeded is returned if the client is over the rate
+	// limit quota that applies to the request
+	ErrRateLimitQuotaExceeded = errors.New("rate limit quota exceeded")
+
+	// ErrLeaseCountQuotaExceeded is returned if the request would create a
+	// lease beyond the lease count quota that applies to it
+	ErrLeaseCountQuotaExceeded = errors.New("lease count quota exceeded")
 )
@@ -17,12 +17,21 @@ const (
 	// HTTPRawBody is the raw content of the HTTP body that goes with the HTTPContentType.
 	// This can only be specified for non-secrets, and should should be similarly
 	// avoided like the HTTPContentType. The value must be a byte slice.
+	//
+	// It is also set in the Data field of requests whose body is not JSON,
+	// such as OCSP requests, to the raw content of that body.
 	HTTPRawBody = "http_raw_body"
 
 	// HTTPStatusCode is the response code of the HTTP body that goes with the HTTPContentType.
 	// This can only be specified for non-secrets, and should should be similarly
 	// avoided like the HTTPContentType. The value must be an integer.
 	HTTPStatusCode = "http_status_code"
+
+	// HTTPRawHeaders are additional HTTP headers that go with the HTTPRawBody,
+	// such as those required by protocols like ACME. This can only be
+	// specified for non-secrets, and should be similarly avoided like the
+	// HTTPContentType. The value must be a map[string][]string.
+	HTTPRawHeaders = "http_raw_headers"
 )
 
 // Response is a struct that stores the response of a request.
@@ -72,6 +72,8 @@ func RespondErrorCommon(req *Request, resp *Response, err error) (int, error) {
 		switch {
 		case errwrap.ContainsType(err, new(StatusBadRequest)):
 			statusCode = http.StatusBadRequest
+		case errwrap.ContainsType(err, new(QuotaExceededError)):
+			statusCode = http.StatusTooManyRequests
 		case errwrap.Contains(err, ErrPermissionDenied.Error()):
 			statusCode = http.StatusForbidden
 		case errwrap.Contains(err, ErrUnsupportedOperation.Error()):
@@ -62,6 +62,7 @@ type Meta struct {
 	flagInsecure       bool
 	flagMFA            []string
 	flagPolicyOverride bool
+	flagNamespace      string
 
 	// Queried if no token can be found
 	TokenHelper TokenHelperFunc
@@ -128,6 +129,11 @@ func (m *Meta) Client() (*api.Client, error) {
 
 	client.SetPolicyOverride(m.flagPolicyOverride)
 
+	// The namespace flag overrides the VAULT_NAMESPACE environment variable
+	if m.flagNamespace != "" {
+		client.SetNamespace(m.flagNamespace)
+	}
+
 	// If we have a token directly, then set that
 	token := m.ClientToken
 
@@ -179,6 +185,7 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 		f.BoolVar(&m.flagInsecure, "tls-skip-verify", false, "")
 		f.BoolVar(&m.flagPolicyOverride, "policy-override", false, "")
 		f.Var((*sliceflag.StringFlag)(&m.flagMFA), "mfa", "")
+		f.StringVar(&m.flagNamespace, "namespace", "", "")
 	}
 
 	// Create an io.Writer that writes to our Ui properly for errors.
@@ -226,6 +233,10 @@ func GeneralOptionsUsage() string {
   -tls-skip-verify        Do not verify TLS certificate. This is highly
                           not recommended. Verification will also be skipped
                           if VAULT_SKIP_VERIFY is set.
+
+  -namespace=path         The namespace the request is made in. Paths are
+                          relative to the namespace. Overrides the
+                          VAULT_NAMESPACE environment variable if set.
 `
 
 	general += additionalOptionsUsage()
@@ -0,0 +1,166 @@
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/armon/go-radix"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	log "github.com/mgutz/logxi/v1"
+)
+
+// FSM is the finite state machine that raft log entries are applied to. It
+// keeps the current state of the storage in memory; durability is provided
+// by the raft log and the snapshots stored on disk.
+type FSM struct {
+	l      sync.RWMutex
+	root   *radix.Tree
+	logger log.Logger
+}
+
+// NewFSM returns an empty FSM
+func NewFSM(logger log.Logger) *FSM {
+	return &FSM{
+		root:   radix.New(),
+		logger: logger,
+	}
+}
+
+// Get returns the entry stored at key, or nil if it does not exist
+func (f *FSM) Get(key string) (*physical.Entry, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	if raw, ok := f.root.Get(key); ok {
+		entry := raw.(*physical.Entry)
+		return &physical.Entry{
+			Key:      entry.Key,
+			Value:    entry.Value,
+			SealWrap: entry.SealWrap,
+		}, nil
+	}
+	return nil, nil
+}
+
+// List returns the keys under the given prefix, up to the next prefix
+func (f *FSM) List(prefix string) ([]string, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	var out []string
+	seen := make(map[string]interface{})
+	walkFn := func(s string, v interface{}) bool {
+		trimmed := strings.TrimPrefix(s, prefix)
+		sep := strings.Index(trimmed, "/")
+		if sep == -1 {
+			out = append(out, trimmed)
+		} else {
+			trimmed = trimmed[:sep+1]
+			if _, ok := seen[trimmed]; !ok {
+				out = append(out, trimmed)
+				seen[trimmed] = struct{}{}
+			}
+		}
+		return false
+	}
+	f.root.WalkPrefix(prefix, walkFn)
+
+	return out, nil
+}
+
+// Apply is called by raft once a log entry has been committed. The log
+// data is a list of operations which are applied atomically. The returned
+// value is an error if the operations could not be applied.
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	var txns []*physical.TxnEntry
+	if err := jsonutil.DecodeJSON(l.Data, &txns); err != nil {
+		f.logger.Error("raft: failed to decode log entry", "index", l.Index, "error", err)
+		return fmt.Errorf("failed to decode log entry: %v", err)
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	for _, txn := range txns {
+		if txn.Entry == nil {
+			continue
+		}
+		switch txn.Operation {
+		case physical.PutOperation:
+			f.root.Insert(txn.Entry.Key, txn.Entry)
+		case physical.DeleteOperation:
+			f.root.Delete(txn.Entry.Key)
+		default:
+			return fmt.Errorf("unsupported operation %q", txn.Operation)
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns a point in time copy of the current state. Entries are
+// never mutated once inserted so it is enough to copy the references.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	entries := make([]*physical.Entry, 0, f.root.Len())
+	f.root.Walk(func(s string, v interface{}) bool {
+		entries = append(entries, v.(*physical.Entry))
+		return false
+	})
+
+	return &fsmSnapshot{entries: entries}, nil
+}
+
+// Restore replaces the current state with the contents of a snapshot
+func (f *FSM) Restore(r io.ReadCloser) error {
+	defer r.Close()
+
+	root := radix.New()
+	dec := json.NewDecoder(r)
+	for {
+		var entry physical.Entry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode snapshot: %v", err)
+		}
+		root.Insert(entry.Key, &entry)
+	}
+
+	f.l.Lock()
+	f.root = root
+	f.l.Unlock()
+
+	return nil
+}
+
+// fsmSnapshot is a snapshot of the FSM, written out as a stream of JSON
+// encoded entries.
+type fsmSnapshot struct {
+	entries []*physical.Entry
+}
+
+// Persist writes the snapshot to the given sink
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	enc := json.NewEncoder(sink)
+	for _, entry := range s.entries {
+		if err := enc.Encode(entry); err != nil {
+			sink.Cancel()
+			return err
+		}
+	}
+
+	return sink.Close()
+}
+
+// Release is a no-op as the snapshot holds no resources
+func (s *fsmSnapshot) Release() {}
@@ -0,0 +1,585 @@
+package raft
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/raft-boltdb"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// defaultAddress is the address raft listens on for traffic from the
+	// other nodes in the cluster if none is configured
+	defaultAddress = "127.0.0.1:8202"
+
+	// applyTimeout is how long to wait for a log entry to be committed
+	applyTimeout = 10 * time.Second
+
+	// snapshotsRetained is the number of snapshots kept on disk
+	snapshotsRetained = 2
+
+	// lockRetryInterval is how often a lock waiting for leadership checks
+	// the raft state, and how often a held lock checks it is still leader
+	lockRetryInterval = 250 * time.Millisecond
+
+	// nodeIDFileName is the file in the data directory the node ID is
+	// persisted in when it is not given in the configuration
+	nodeIDFileName = "node-id"
+)
+
+// ErrNotLeader is returned when an operation that has to be performed by
+// the raft leader is attempted on a follower
+var ErrNotLeader = errors.New("node is not the raft leader")
+
+// Verify RaftBackend satisfies the correct interfaces
+var _ physical.Backend = (*RaftBackend)(nil)
+var _ physical.HABackend = (*RaftBackend)(nil)
+var _ physical.Transactional = (*RaftBackend)(nil)
+
+// RaftBackend is a physical backend that replicates its data between a
+// cluster of Vault nodes using the raft consensus protocol. The log is kept
+// on local disk in BoltDB and compacted into snapshots, so no external
+// storage system is needed for a highly-available deployment.
+type RaftBackend struct {
+	logger     log.Logger
+	permitPool *physical.PermitPool
+
+	nodeID    string
+	fsm       *FSM
+	raft      *raft.Raft
+	transport *raft.NetworkTransport
+	store     *raftboltdb.BoltStore
+
+	// heldLocks tracks the HA lock keys held on this node so that two
+	// locks for the same key are exclusive on the leader
+	heldLocks map[string]struct{}
+	locksLock sync.Mutex
+}
+
+// NewRaftBackend constructs a RaftBackend storing its data under the
+// configured path
+func NewRaftBackend(conf map[string]string, logger log.Logger) (physical.Backend, error) {
+	path, ok := conf["path"]
+	if !ok {
+		return nil, fmt.Errorf("'path' must be set")
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, errwrap.Wrapf("failed to create raft data directory: {{err}}", err)
+	}
+
+	nodeID, err := loadNodeID(path, conf["node_id"])
+	if err != nil {
+		return nil, err
+	}
+
+	address, ok := conf["address"]
+	if !ok {
+		address = defaultAddress
+	}
+
+	raftConfig := raft.DefaultConfig()
+	raftConfig.LocalID = raft.ServerID(nodeID)
+	raftConfig.Logger = newStdLogger(logger)
+
+	if raw, ok := conf["snapshot_threshold"]; ok {
+		threshold, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing snapshot_threshold parameter: {{err}}", err)
+		}
+		raftConfig.SnapshotThreshold = threshold
+	}
+	if raw, ok := conf["trailing_logs"]; ok {
+		trailing, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing trailing_logs parameter: {{err}}", err)
+		}
+		raftConfig.TrailingLogs = trailing
+	}
+
+	var bootstrap bool
+	if raw, ok := conf["bootstrap"]; ok {
+		bootstrap, err = strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing bootstrap parameter: {{err}}", err)
+		}
+	}
+
+	disableTLS, err := tlsDisabled(conf)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if !disableTLS {
+		tlsConfig, err = setupTLSConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		logger.Warn("raft: TLS is disabled, cluster traffic is neither encrypted nor authenticated")
+	}
+
+	store, err := raftboltdb.NewBoltStore(filepath.Join(path, "raft.db"))
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to open raft log store: {{err}}", err)
+	}
+
+	snaps, err := raft.NewFileSnapshotStoreWithLogger(path, snapshotsRetained, raftConfig.Logger)
+	if err != nil {
+		store.Close()
+		return nil, errwrap.Wrapf("failed to create raft snapshot store: {{err}}", err)
+	}
+
+	var transport *raft.NetworkTransport
+	if tlsConfig != nil {
+		var stream *tlsStreamLayer
+		stream, err = newTLSStreamLayer(address, tlsConfig)
+		if err == nil {
+			transport = raft.NewNetworkTransportWithLogger(stream, 3, 10*time.Second, raftConfig.Logger)
+		}
+	} else {
+		transport, err = raft.NewTCPTransportWithLogger(address, nil, 3, 10*time.Second, raftConfig.Logger)
+	}
+	if err != nil {
+		store.Close()
+		return nil, errwrap.Wrapf("failed to create raft transport: {{err}}", err)
+	}
+
+	if bootstrap {
+		hasState, err := raft.HasExistingState(store, store, snaps)
+		if err != nil {
+			transport.Close()
+			store.Close()
+			return nil, errwrap.Wrapf("failed to check for existing raft state: {{err}}", err)
+		}
+		if !hasState {
+			configuration := raft.Configuration{
+				Servers: []raft.Server{
+					{
+						Suffrage: raft.Voter,
+						ID:       raftConfig.LocalID,
+						Address:  transport.LocalAddr(),
+					},
+				},
+			}
+			if err := raft.BootstrapCluster(raftConfig, store, store, snaps, transport, configuration); err != nil {
+				transport.Close()
+				store.Close()
+				return nil, errwrap.Wrapf("failed to bootstrap raft cluster: {{err}}", err)
+			}
+		}
+	}
+
+	fsm := NewFSM(logger)
+	r, err := raft.NewRaft(raftConfig, fsm, store, store, snaps, transport)
+	if err != nil {
+		transport.Close()
+		store.Close()
+		return nil, errwrap.Wrapf("failed to start raft: {{err}}", err)
+	}
+
+	return &RaftBackend{
+		logger:     logger,
+		permitPool: physical.NewPermitPool(physical.DefaultParallelOperations),
+		nodeID:     nodeID,
+		fsm:        fsm,
+		raft:       r,
+		transport:  transport,
+		store:      store,
+		heldLocks:  make(map[string]struct{}),
+	}, nil
+}
+
+// loadNodeID returns the configured node ID, or the one persisted in the
+// data directory. A new ID is generated and persisted if neither exists.
+func loadNodeID(path, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	idPath := filepath.Join(path, nodeIDFileName)
+	raw, err := ioutil.ReadFile(idPath)
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(raw)), nil
+	case !os.IsNotExist(err):
+		return "", errwrap.Wrapf("failed to read raft node ID: {{err}}", err)
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(idPath, []byte(id), 0600); err != nil {
+		return "", errwrap.Wrapf("failed to persist raft node ID: {{err}}", err)
+	}
+	return id, nil
+}
+
+// NodeID returns the ID this node is known by in the raft cluster
+func (b *RaftBackend) NodeID() string {
+	return b.nodeID
+}
+
+// Address returns the address other nodes use to reach this node
+func (b *RaftBackend) Address() string {
+	return string(b.transport.LocalAddr())
+}
+
+// IsLeader returns whether this node is currently the raft leader
+func (b *RaftBackend) IsLeader() bool {
+	return b.raft.State() == raft.Leader
+}
+
+// Close shuts down raft and releases the log store and transport
+func (b *RaftBackend) Close() error {
+	if err := b.raft.Shutdown().Error(); err != nil {
+		return err
+	}
+	if err := b.transport.Close(); err != nil {
+		return err
+	}
+	return b.store.Close()
+}
+
+// Snapshot forces raft to take a snapshot and compact the log
+func (b *RaftBackend) Snapshot() error {
+	return b.raft.Snapshot().Error()
+}
+
+// Put is used to insert or update an entry
+func (b *RaftBackend) Put(entry *physical.Entry) error {
+	defer metrics.MeasureSince([]string{"raft", "put"}, time.Now())
+
+	return b.apply([]*physical.TxnEntry{
+		{
+			Operation: physical.PutOperation,
+			Entry:     entry,
+		},
+	})
+}
+
+// Get is used to fetch an entry
+func (b *RaftBackend) Get(key string) (*physical.Entry, error) {
+	defer metrics.MeasureSince([]string{"raft", "get"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.Get(key)
+}
+
+// Delete is used to permanently delete an entry
+func (b *RaftBackend) Delete(key string) error {
+	defer metrics.MeasureSince([]string{"raft", "delete"}, time.Now())
+
+	return b.apply([]*physical.TxnEntry{
+		{
+			Operation: physical.DeleteOperation,
+			Entry: &physical.Entry{
+				Key: key,
+			},
+		},
+	})
+}
+
+// List is used to list all the keys under a given
+// prefix, up to the next prefix.
+func (b *RaftBackend) List(prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"raft", "list"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.List(prefix)
+}
+
+// Transaction applies all the given operations atomically as a single log
+// entry
+func (b *RaftBackend) Transaction(txns []*physical.TxnEntry) error {
+	defer metrics.MeasureSince([]string{"raft", "transaction"}, time.Now())
+
+	for _, txn := range txns {
+		if txn.Operation != physical.PutOperation && txn.Operation != physical.DeleteOperation {
+			return fmt.Errorf("unsupported transaction operation %q", txn.Operation)
+		}
+	}
+
+	return b.apply(txns)
+}
+
+// apply commits the operations to the raft log and waits for them to be
+// applied to the local FSM. Only the leader can accept writes.
+func (b *RaftBackend) apply(txns []*physical.TxnEntry) error {
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	cmd, err := jsonutil.EncodeJSON(txns)
+	if err != nil {
+		return err
+	}
+
+	future := b.raft.Apply(cmd, applyTimeout)
+	if err := future.Error(); err != nil {
+		if err == raft.ErrNotLeader {
+			return ErrNotLeader
+		}
+		return err
+	}
+
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Peer describes a member of the raft cluster
+type Peer struct {
+	NodeID  string `json:"node_id" structs:"node_id" mapstructure:"node_id"`
+	Address string `json:"address" structs:"address" mapstructure:"address"`
+	Leader  bool   `json:"leader" structs:"leader" mapstructure:"leader"`
+	Voter   bool   `json:"voter" structs:"voter" mapstructure:"voter"`
+}
+
+// Peers returns the current members of the raft cluster
+func (b *RaftBackend) Peers() ([]*Peer, error) {
+	future := b.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	leader := b.raft.Leader()
+	var peers []*Peer
+	for _, server := range future.Configuration().Servers {
+		peers = append(peers, &Peer{
+			NodeID:  string(server.ID),
+			Address: string(server.Address),
+			Leader:  server.Address == leader,
+			Voter:   server.Suffrage == raft.Voter,
+		})
+	}
+
+	return peers, nil
+}
+
+// AddPeer joins the node with the given ID and address to the cluster as a
+// voter. It must be called on the leader.
+func (b *RaftBackend) AddPeer(nodeID, address string) error {
+	if !b.IsLeader() {
+		return ErrNotLeader
+	}
+
+	if b.logger.IsInfo() {
+		b.logger.Info("raft: adding peer", "node_id", nodeID, "address", address)
+	}
+
+	return b.raft.AddVoter(raft.ServerID(nodeID), raft.ServerAddress(address), 0, applyTimeout).Error()
+}
+
+// RemovePeer removes the node with the given ID from the cluster. It must
+// be called on the leader.
+func (b *RaftBackend) RemovePeer(nodeID string) error {
+	if !b.IsLeader() {
+		return ErrNotLeader
+	}
+
+	if b.logger.IsInfo() {
+		b.logger.Info("raft: removing peer", "node_id", nodeID)
+	}
+
+	return b.raft.RemoveServer(raft.ServerID(nodeID), 0, applyTimeout).Error()
+}
+
+// LockWith is used for mutual exclusion based on the given key. The lock
+// is held by whichever node is the raft leader.
+func (b *RaftBackend) LockWith(key, value string) (physical.Lock, error) {
+	return &RaftLock{
+		b:     b,
+		key:   key,
+		value: value,
+	}, nil
+}
+
+// tryHoldLock marks the lock key as held on this node, returning false if
+// it already is
+func (b *RaftBackend) tryHoldLock(key string) bool {
+	b.locksLock.Lock()
+	defer b.locksLock.Unlock()
+
+	if _, ok := b.heldLocks[key]; ok {
+		return false
+	}
+	b.heldLocks[key] = struct{}{}
+	return true
+}
+
+// releaseLock marks the lock key as no longer held on this node
+func (b *RaftBackend) releaseLock(key string) {
+	b.locksLock.Lock()
+	delete(b.heldLocks, key)
+	b.locksLock.Unlock()
+}
+
+// HAEnabled indicates whether the HA functionality should be exposed.
+// Raft always provides leader election.
+func (b *RaftBackend) HAEnabled() bool {
+	return true
+}
+
+// RaftLock implements a physical.Lock on top of raft leadership. Acquiring
+// the lock waits for the node to become leader and then records the value
+// in the replicated state so the other nodes can find the holder.
+type RaftLock struct {
+	b     *RaftBackend
+	key   string
+	value string
+
+	held     bool
+	leaderCh chan struct{}
+	stopCh   chan struct{}
+	l        sync.Mutex
+}
+
+// Lock blocks until this node is the raft leader and no other lock for the
+// same key is held on it, or stopCh is closed
+func (l *RaftLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	if l.held {
+		return nil, fmt.Errorf("lock already held")
+	}
+
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	for !l.b.IsLeader() || !l.b.tryHoldLock(l.key) {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return nil, nil
+		}
+	}
+
+	// Make sure every entry from previous terms has been applied before
+	// the caller starts reading state
+	if err := l.b.raft.Barrier(applyTimeout).Error(); err != nil {
+		l.b.releaseLock(l.key)
+		return nil, errwrap.Wrapf("failed to wait for raft log to be applied: {{err}}", err)
+	}
+
+	err := l.b.Put(&physical.Entry{
+		Key:   l.key,
+		Value: []byte(l.value),
+	})
+	if err != nil {
+		l.b.releaseLock(l.key)
+		return nil, errwrap.Wrapf("failed to write lock value: {{err}}", err)
+	}
+
+	l.held = true
+	l.leaderCh = make(chan struct{})
+	l.stopCh = make(chan struct{})
+	go l.monitorLeadership(l.leaderCh, l.stopCh)
+
+	return l.leaderCh, nil
+}
+
+// monitorLeadership closes leaderCh once this node is no longer leader
+func (l *RaftLock) monitorLeadership(leaderCh, stopCh chan struct{}) {
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !l.b.IsLeader() {
+				close(leaderCh)
+				return
+			}
+		case <-stopCh:
+			close(leaderCh)
+			return
+		}
+	}
+}
+
+// Unlock releases the lock. Raft leadership itself is not given up, so
+// the same node will acquire the lock again if it asks for it.
+func (l *RaftLock) Unlock() error {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if !l.held {
+		return nil
+	}
+
+	close(l.stopCh)
+	l.stopCh = nil
+	l.leaderCh = nil
+	l.held = false
+	defer l.b.releaseLock(l.key)
+
+	if !l.b.IsLeader() {
+		return nil
+	}
+
+	return l.b.Delete(l.key)
+}
+
+// Value returns whether the lock is held by any node and the value it
+// was acquired with
+func (l *RaftLock) Value() (bool, string, error) {
+	entry, err := l.b.Get(l.key)
+	if err != nil {
+		return false, "", err
+	}
+	if entry == nil {
+		return false, "", nil
+	}
+
+	return true, string(entry.Value), nil
+}
+
+// logWriter adapts the standard library logger used by raft to the Vault
+// logger, mapping the level prefix raft writes on each line
+type logWriter struct {
+	logger log.Logger
+}
+
+func newStdLogger(logger log.Logger) *stdlog.Logger {
+	return stdlog.New(&logWriter{logger: logger}, "", 0)
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	line := string(bytes.TrimSpace(p))
+	switch {
+	case strings.HasPrefix(line, "[ERR] "):
+		w.logger.Error(strings.TrimPrefix(line, "[ERR] "))
+	case strings.HasPrefix(line, "[WARN] "):
+		w.logger.Warn(strings.TrimPrefix(line, "[WARN] "))
+	case strings.HasPrefix(line, "[INFO] "):
+		w.logger.Info(strings.TrimPrefix(line, "[INFO] "))
+	case strings.HasPrefix(line, "[DEBUG] "):
+		w.logger.Debug(strings.TrimPrefix(line, "[DEBUG] "))
+	default:
+		w.logger.Trace(line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,445 @@
+package raft
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/physical"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func testRaftBackend(t *testing.T, path string, conf map[string]string) *RaftBackend {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+
+	config := map[string]string{
+		"path":    path,
+		"address": "127.0.0.1:0",
+	}
+	for k, v := range testTLSFiles(t, testCA(t), path) {
+		config[k] = v
+	}
+	for k, v := range conf {
+		config[k] = v
+	}
+
+	b, err := NewRaftBackend(config, logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return b.(*RaftBackend)
+}
+
+// testCertAuthority is a CA issuing the certificates of test nodes
+type testCertAuthority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var (
+	testCALock   sync.Mutex
+	testCAShared *testCertAuthority
+)
+
+// testCA returns the CA shared by the nodes of every test cluster
+func testCA(t *testing.T) *testCertAuthority {
+	testCALock.Lock()
+	defer testCALock.Unlock()
+
+	if testCAShared == nil {
+		testCAShared = newTestCA(t)
+	}
+	return testCAShared
+}
+
+func newTestCA(t *testing.T) *testCertAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "raft-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &testCertAuthority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// testTLSFiles writes a node certificate issued by ca to dir and returns the
+// configuration pointing to it
+func testTLSFiles(t *testing.T, ca *testCertAuthority, dir string) map[string]string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "raft-node"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := map[string][]byte{
+		"tls_cert_file": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"tls_key_file":  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"tls_ca_file":   ca.pem,
+	}
+	conf := make(map[string]string, len(files))
+	for name, data := range files {
+		path := filepath.Join(dir, name+".pem")
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		conf[name] = path
+	}
+	return conf
+}
+
+func testRaftCluster(t *testing.T, nodes int) ([]*RaftBackend, func()) {
+	var dirs []string
+	var backends []*RaftBackend
+	cleanup := func() {
+		for _, b := range backends {
+			b.Close()
+		}
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}
+
+	for i := 0; i < nodes; i++ {
+		dir, err := ioutil.TempDir("", "vault-raft")
+		if err != nil {
+			cleanup()
+			t.Fatalf("err: %s", err)
+		}
+		dirs = append(dirs, dir)
+
+		conf := map[string]string{
+			"node_id": fmt.Sprintf("node%d", i+1),
+		}
+		if i == 0 {
+			conf["bootstrap"] = "true"
+		}
+		backends = append(backends, testRaftBackend(t, dir, conf))
+	}
+
+	waitForLeader(t, backends[0])
+	for _, b := range backends[1:] {
+		if err := backends[0].AddPeer(b.NodeID(), b.Address()); err != nil {
+			cleanup()
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return backends, cleanup
+}
+
+func waitForLeader(t *testing.T, backends ...*RaftBackend) *RaftBackend {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, b := range backends {
+			if b.IsLeader() {
+				return b
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("no leader elected")
+	return nil
+}
+
+func waitForValue(t *testing.T, b *RaftBackend, key, value string) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		entry, err := b.Get(key)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if entry != nil && string(entry.Value) == value {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("node %s never saw %q=%q", b.NodeID(), key, value)
+}
+
+func TestRaftBackend(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 1)
+	defer cleanup()
+
+	physical.ExerciseBackend(t, backends[0])
+	physical.ExerciseBackend_ListPrefix(t, backends[0])
+}
+
+func TestRaftBackend_Transactional(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 1)
+	defer cleanup()
+
+	physical.ExerciseTransactionalBackend(t, backends[0])
+}
+
+func TestRaftBackend_HA(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 1)
+	defer cleanup()
+
+	physical.ExerciseHABackend(t, backends[0], backends[0])
+}
+
+func TestRaftBackend_Cluster(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 3)
+	defer cleanup()
+
+	leader := backends[0]
+	err := leader.Put(&physical.Entry{Key: "foo", Value: []byte("bar")})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, b := range backends[1:] {
+		waitForValue(t, b, "foo", "bar")
+	}
+
+	// Followers cannot accept writes
+	err = backends[1].Put(&physical.Entry{Key: "foo", Value: []byte("baz")})
+	if err != ErrNotLeader {
+		t.Fatalf("expected not leader error, got: %v", err)
+	}
+	if err := backends[1].AddPeer("node4", "127.0.0.1:1"); err != ErrNotLeader {
+		t.Fatalf("expected not leader error, got: %v", err)
+	}
+
+	peers, err := leader.Peers()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(peers) != 3 {
+		t.Fatalf("bad: %#v", peers)
+	}
+	for _, peer := range peers {
+		if !peer.Voter {
+			t.Fatalf("bad: %#v", peer)
+		}
+		if peer.Leader != (peer.NodeID == leader.NodeID()) {
+			t.Fatalf("bad: %#v", peer)
+		}
+	}
+
+	if err := leader.RemovePeer("node3"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	peers, err = leader.Peers()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("bad: %#v", peers)
+	}
+	for _, peer := range peers {
+		if peer.NodeID == "node3" {
+			t.Fatalf("bad: %#v", peers)
+		}
+	}
+}
+
+func TestRaftBackend_TLS(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 1)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "vault-raft")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A node with a certificate from another CA cannot connect
+	conf := testTLSFiles(t, newTestCA(t), dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, testCA(t).pem, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conf["tls_ca_file"] = caFile
+	tlsConfig, err := setupTLSConfig(conf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	conn, err := tls.Dial("tcp", backends[0].Address(), tlsConfig)
+	if err == nil {
+		// The server reports a bad client certificate on the first read
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if netErr, ok := err.(net.Error); err == nil || (ok && netErr.Timeout()) {
+		t.Fatalf("expected the connection to be refused, got: %v", err)
+	}
+
+	// TLS can only be left out explicitly
+	if _, err := NewRaftBackend(map[string]string{"path": dir}, nil); err == nil {
+		t.Fatal("expected an error without TLS files")
+	}
+}
+
+func TestRaftBackend_LeaderFailover(t *testing.T) {
+	backends, cleanup := testRaftCluster(t, 3)
+	defer cleanup()
+
+	lock, err := backends[0].LockWith("core/lock", "node1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if leaderCh == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	for _, b := range backends[1:] {
+		waitForValue(t, b, "core/lock", "node1")
+	}
+
+	// Take down the leader and make sure the rest of the cluster picks up
+	if err := backends[0].Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	select {
+	case <-leaderCh:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("leader channel was not closed")
+	}
+
+	newLeader := waitForLeader(t, backends[1:]...)
+	lock2, err := newLeader.LockWith("core/lock", newLeader.NodeID())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	leaderCh2, err := lock2.Lock(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if leaderCh2 == nil {
+		t.Fatalf("failed to get leader ch")
+	}
+
+	for _, b := range backends[1:] {
+		waitForValue(t, b, "core/lock", newLeader.NodeID())
+	}
+	lock2.Unlock()
+}
+
+func TestRaftBackend_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := testRaftBackend(t, dir, map[string]string{
+		"bootstrap":     "true",
+		"trailing_logs": "0",
+	})
+	waitForLeader(t, b)
+
+	for i := 0; i < 10; i++ {
+		err := b.Put(&physical.Entry{Key: fmt.Sprintf("foo/%d", i), Value: []byte("bar")})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := b.Delete("foo/0"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.Snapshot(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A node joining after the log was compacted gets the snapshot
+	dir2, err := ioutil.TempDir("", "vault-raft")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir2)
+	b2 := testRaftBackend(t, dir2, nil)
+	if err := b.AddPeer(b2.NodeID(), b2.Address()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	waitForValue(t, b2, "foo/9", "bar")
+	keys, err := b2.List("foo/")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(keys) != 9 {
+		t.Fatalf("bad: %v", keys)
+	}
+	if err := b.RemovePeer(b2.NodeID()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b2.Close()
+
+	// Restarting recovers the state from disk
+	address := b.Address()
+	nodeID := b.NodeID()
+	if err := b.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b = testRaftBackend(t, dir, map[string]string{
+		"bootstrap": "true",
+		"address":   address,
+	})
+	defer b.Close()
+
+	if b.NodeID() != nodeID {
+		t.Fatalf("node ID was not persisted: %q != %q", b.NodeID(), nodeID)
+	}
+	waitForValue(t, b, "foo/5", "bar")
+	entry, err := b.Get("foo/0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if entry != nil {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
@@ -0,0 +1,148 @@
+package raft
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/tlsutil"
+)
+
+// tlsStreamLayer carries the raft traffic between nodes over mutually
+// authenticated TLS: both ends present a certificate issued by the cluster
+// CA, so only nodes holding one can replicate the log or join the cluster.
+type tlsStreamLayer struct {
+	net.Listener
+	config *tls.Config
+}
+
+// Verify tlsStreamLayer satisfies the correct interfaces
+var _ raft.StreamLayer = (*tlsStreamLayer)(nil)
+
+func newTLSStreamLayer(address string, config *tls.Config) (*tlsStreamLayer, error) {
+	listener, err := tls.Listen("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// The listening address is the one the node is known by in the cluster
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok || addr.IP.IsUnspecified() {
+		listener.Close()
+		return nil, fmt.Errorf("raft address %q is not advertisable", address)
+	}
+
+	return &tlsStreamLayer{
+		Listener: listener,
+		config:   config,
+	}, nil
+}
+
+// Dial opens a TLS connection to another node
+func (l *tlsStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+	return tls.DialWithDialer(dialer, "tcp", string(address), l.config)
+}
+
+// setupTLSConfig builds the TLS configuration used both to accept
+// connections from and to dial the other nodes of the cluster
+func setupTLSConfig(conf map[string]string) (*tls.Config, error) {
+	certFile, okCert := conf["tls_cert_file"]
+	keyFile, okKey := conf["tls_key_file"]
+	caFile, okCA := conf["tls_ca_file"]
+	if !okCert || !okKey || !okCA {
+		return nil, errors.New("'tls_cert_file', 'tls_key_file' and 'tls_ca_file' must be set unless 'tls_disable' is set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to load raft TLS certificate: {{err}}", err)
+	}
+
+	data, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read raft TLS CA file: {{err}}", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to parse raft TLS CA file")
+	}
+
+	tlsMinVersionStr, ok := conf["tls_min_version"]
+	if !ok {
+		tlsMinVersionStr = "tls12"
+	}
+	tlsMinVersion, ok := tlsutil.TLSLookup[tlsMinVersionStr]
+	if !ok {
+		return nil, fmt.Errorf("invalid 'tls_min_version'")
+	}
+
+	config := &tls.Config{
+		MinVersion:   tlsMinVersion,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    caPool,
+		RootCAs:      caPool,
+	}
+
+	if serverName, ok := conf["tls_server_name"]; ok {
+		// Every node certificate carries this name, so the usual hostname
+		// verification applies
+		config.ServerName = serverName
+		return config, nil
+	}
+
+	// Nodes are addressed by whatever address they were joined with, which
+	// their certificates need not contain. The chain is still verified
+	// against the cluster CA, which is what authenticates a node.
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate presented by raft peer")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         caPool,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		return err
+	}
+
+	return config, nil
+}
+
+// tlsDisabled reports whether the configuration opts out of TLS between the
+// nodes, which is only meant for testing
+func tlsDisabled(conf map[string]string) (bool, error) {
+	raw, ok := conf["tls_disable"]
+	if !ok {
+		return false, nil
+	}
+	disabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errwrap.Wrapf("failed parsing tls_disable parameter: {{err}}", err)
+	}
+	return disabled, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/armon/go-radix"
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -43,7 +44,16 @@ type ACLResults struct {
 }
 
 // New is used to construct a policy based ACL from a set of policies.
+// Templated paths of the policies are left out, since there is no identity to
+// resolve them with.
 func NewACL(policies []*Policy) (*ACL, error) {
+	return NewACLWithIdentity(policies, nil, nil)
+}
+
+// NewACLWithIdentity is used to construct a policy based ACL from a set of
+// policies, resolving the templated paths of the policies using the given
+// entity and the groups it belongs to.
+func NewACLWithIdentity(policies []*Policy, entity *identity.Entity, groups []*identity.Group) (*ACL, error) {
 	// Initialize
 	a := &ACL{
 		exactRules: radix.New(),
@@ -68,7 +78,29 @@ func NewACL(policies []*Policy) (*ACL, error) {
 		if policy.Name == "root" {
 			a.root = true
 		}
+		// The paths of policies of child namespaces are relative to the
+		// namespace
+		var nsPath string
+		if policy.namespace != nil {
+			nsPath = policy.namespace.Path
+		}
+
 		for _, pc := range policy.Paths {
+			// Resolve the identity templates of the path; the paths that
+			// can't be resolved don't apply to this identity
+			prefix := pc.Prefix
+			if pc.Templated {
+				_, templated, err := identity.PopulateString(&identity.PopulateStringInput{
+					String: pc.Prefix,
+					Entity: entity,
+					Groups: groups,
+				})
+				if err != nil {
+					continue
+				}
+				prefix = templated
+			}
+
 			// Check which tree to use
 			tree := a.exactRules
 			if pc.Glob {
@@ -76,13 +108,13 @@ func NewACL(policies []*Policy) (*ACL, error) {
 			}
 
 			// Check for an existing policy
-			raw, ok := tree.Get(pc.Prefix)
+			raw, ok := tree.Get(nsPath + prefix)
 			if !ok {
 				clonedPerms, err := pc.Permissions.Clone()
 				if err != nil {
 					return nil, errwrap.Wrapf("error cloning ACL permissions: {{err}}", err)
 				}
-				tree.Insert(pc.Prefix, clonedPerms)
+				tree.Insert(nsPath+prefix, clonedPerms)
 				continue
 			}
 
@@ -167,7 +199,7 @@ func NewACL(policies []*Policy) (*ACL, error) {
 			}
 
 		INSERT:
-			tree.Insert(pc.Prefix, existingPerms)
+			tree.Insert(nsPath+prefix, existingPerms)
 		}
 	}
 	return a, nil
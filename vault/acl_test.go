@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -445,6 +446,83 @@ func TestACL_CreationRace(t *testing.T) {
 	wg.Wait()
 }
 
+func TestACL_Templated(t *testing.T) {
+	policy, err := ParseACLPolicy(templatedPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	policy.Name = "templated"
+
+	entity := &identity.Entity{
+		ID:   "entity-id",
+		Name: "alice",
+		Metadata: map[string]string{
+			"team": "eng",
+		},
+		Aliases: []*identity.Alias{
+			&identity.Alias{
+				ID:            "alias-id",
+				MountAccessor: "auth_userpass_1234",
+				Name:          "alice-userpass",
+			},
+		},
+	}
+	groups := []*identity.Group{
+		&identity.Group{
+			ID:   "group-id",
+			Name: "admins",
+		},
+	}
+
+	acl, err := NewACLWithIdentity([]*Policy{policy}, entity, groups)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		path     string
+		expected []string
+	}
+	tcases := []tcase{
+		{"secret/users/alice/foo", []string{"read", "list", "update", "delete", "create"}},
+		{"secret/users/bob/foo", []string{"deny"}},
+		{"secret/users/{{identity.entity.name}}/foo", []string{"deny"}},
+		{"secret/teams/eng", []string{"read"}},
+		{"secret/aliases/alice-userpass", []string{"read"}},
+		{"secret/groups/group-id", []string{"read"}},
+		{"secret/missing/", []string{"deny"}},
+	}
+	for _, tc := range tcases {
+		actual := acl.Capabilities(tc.path)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Fatalf("bad: path:%s\ngot\n%#v\nexpected\n%#v\n", tc.path, actual, tc.expected)
+		}
+	}
+
+	// Without an identity none of the templated paths apply, while the other
+	// paths still do
+	acl, err = NewACL([]*Policy{policy})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := acl.Capabilities("secret/users/alice/foo"); !reflect.DeepEqual(actual, []string{"deny"}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if actual := acl.Capabilities("secret/static"); !reflect.DeepEqual(actual, []string{"read"}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// A value ending with the glob character doesn't make the path a glob
+	entity.Metadata["team"] = "eng*"
+	acl, err = NewACLWithIdentity([]*Policy{policy}, entity, groups)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if actual := acl.Capabilities("secret/teams/engineering"); !reflect.DeepEqual(actual, []string{"deny"}) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 var tokenCreationPolicy = `
 name = "tokenCreation"
 path "auth/token/create*" {
@@ -763,3 +841,25 @@ path "test/star" {
 	}
 }
 `
+
+var templatedPolicy = `
+name = "templated"
+path "secret/users/{{identity.entity.name}}/*" {
+	capabilities = ["create", "read", "update", "delete", "list"]
+}
+path "secret/teams/{{identity.entity.metadata.team}}" {
+	capabilities = ["read"]
+}
+path "secret/aliases/{{identity.entity.aliases.auth_userpass_1234.name}}" {
+	capabilities = ["read"]
+}
+path "secret/groups/{{identity.groups.names.admins.id}}" {
+	capabilities = ["read"]
+}
+path "secret/missing/{{identity.entity.metadata.missing}}" {
+	capabilities = ["read"]
+}
+path "secret/static" {
+	capabilities = ["read"]
+}
+`
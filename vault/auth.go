@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -44,6 +45,14 @@ var (
 
 // enableCredential is used to enable a new credential backend
 func (c *Core) enableCredential(entry *MountEntry) error {
+	// Ensure the token backend is a singleton
+	if entry.Type == "token" {
+		return fmt.Errorf("token credential backend cannot be instantiated")
+	}
+	return c.enableCredentialInternal(entry)
+}
+
+func (c *Core) enableCredentialInternal(entry *MountEntry) error {
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(entry.Path, "/") {
 		entry.Path += "/"
@@ -54,11 +63,18 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		return fmt.Errorf("backend path must be specified")
 	}
 
+	if err := c.setMountEntryNamespace(entry); err != nil {
+		return err
+	}
+
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 
 	// Look for matching name
 	for _, ent := range c.auth.Entries {
+		if ent.Namespace().ID != entry.NamespaceID {
+			continue
+		}
 		switch {
 		// Existing is oauth/github/ new is oauth/ or
 		// existing is oauth/ and new is oauth/github/
@@ -69,12 +85,8 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		}
 	}
 
-	// Ensure the token backend is a singleton
-	if entry.Type == "token" {
-		return fmt.Errorf("token credential backend cannot be instantiated")
-	}
-
-	if match := c.router.MatchingMount(credentialRoutePrefix + entry.Path); match != "" {
+	path := entry.APIPath()
+	if match := c.router.MatchingMount(path); match != "" {
 		return logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
 	}
 
@@ -103,10 +115,14 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		conf["plugin_name"] = entry.Config.PluginName
 	}
 
-	// Create the new backend
-	backend, err = c.newCredentialBackend(entry.Type, sysView, view, conf)
-	if err != nil {
-		return err
+	// Create the new backend. The token store is shared by all namespaces.
+	if entry.Type == "token" {
+		backend = c.tokenStore
+	} else {
+		backend, err = c.newCredentialBackend(entry.Type, sysView, view, conf)
+		if err != nil {
+			return err
+		}
 	}
 	if backend == nil {
 		return fmt.Errorf("nil backend returned from %q factory", entry.Type)
@@ -118,8 +134,10 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 		return fmt.Errorf("cannot mount '%s' of type '%s' as an auth backend", entry.Config.PluginName, backendType)
 	}
 
-	if err := backend.Initialize(); err != nil {
-		return err
+	if entry.Type != "token" {
+		if err := backend.Initialize(); err != nil {
+			return err
+		}
 	}
 
 	// Update the auth table
@@ -131,20 +149,19 @@ func (c *Core) enableCredential(entry *MountEntry) error {
 
 	c.auth = newTable
 
-	path := credentialRoutePrefix + entry.Path
 	if err := c.router.Mount(backend, path, entry, view); err != nil {
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("core: enabled credential backend", "path", entry.Path, "type", entry.Type)
+		c.logger.Info("core: enabled credential backend", "path", path, "type", entry.Type)
 	}
 	return nil
 }
 
-// disableCredential is used to disable an existing credential backend; the
-// boolean indicates if it existed
-func (c *Core) disableCredential(path string) error {
+// disableCredential is used to disable an existing credential backend in the
+// given namespace; the boolean indicates if it existed
+func (c *Core) disableCredential(ns *namespace.Namespace, path string) error {
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(path, "/") {
 		path += "/"
@@ -155,15 +172,19 @@ func (c *Core) disableCredential(path string) error {
 		return fmt.Errorf("token credential backend cannot be disabled")
 	}
 
+	return c.disableCredentialInternal(ns, path)
+}
+
+func (c *Core) disableCredentialInternal(ns *namespace.Namespace, path string) error {
 	// Store the view for this backend
-	fullPath := credentialRoutePrefix + path
+	fullPath := ns.Path + credentialRoutePrefix + path
 	view := c.router.MatchingStorageByAPIPath(fullPath)
 	if view == nil {
 		return fmt.Errorf("no matching backend %s", fullPath)
 	}
 
 	// Mark the entry as tainted
-	if err := c.taintCredEntry(path); err != nil {
+	if err := c.taintCredEntry(ns, path); err != nil {
 		return err
 	}
 
@@ -178,8 +199,11 @@ func (c *Core) disableCredential(path string) error {
 			return err
 		}
 
-		// Call cleanup function if it exists
-		backend.Cleanup()
+		// Call cleanup function if it exists, except for the token store
+		// which is shared by all namespaces
+		if entry.Type != "token" {
+			backend.Cleanup()
+		}
 	}
 
 	// Unmount the backend
@@ -188,35 +212,37 @@ func (c *Core) disableCredential(path string) error {
 	}
 
 	switch {
+	case entry.Type == "token":
+		// The storage of the token store is shared by all namespaces
 	case entry.Local, !c.replicationState.HasState(consts.ReplicationPerformanceSecondary):
 		// Have writable storage, remove the whole thing
 		if err := logical.ClearView(view); err != nil {
-			c.logger.Error("core: failed to clear view for path being unmounted", "error", err, "path", path)
+			c.logger.Error("core: failed to clear view for path being unmounted", "error", err, "path", fullPath)
 			return err
 		}
 
 	}
 
 	// Remove the mount table entry
-	if err := c.removeCredEntry(path); err != nil {
+	if err := c.removeCredEntry(ns, path); err != nil {
 		return err
 	}
 	if c.logger.IsInfo() {
-		c.logger.Info("core: disabled credential backend", "path", path)
+		c.logger.Info("core: disabled credential backend", "path", fullPath)
 	}
 	return nil
 }
 
 // removeCredEntry is used to remove an entry in the auth table
-func (c *Core) removeCredEntry(path string) error {
+func (c *Core) removeCredEntry(ns *namespace.Namespace, path string) error {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 
 	// Taint the entry from the auth table
 	newTable := c.auth.shallowClone()
-	entry := newTable.remove(path)
+	entry := newTable.remove(ns, path)
 	if entry == nil {
-		c.logger.Error("core: nil entry found removing entry in auth table", "path", path)
+		c.logger.Error("core: nil entry found removing entry in auth table", "path", ns.Path+path)
 		return logical.CodedError(500, "failed to remove entry in auth table")
 	}
 
@@ -233,8 +259,8 @@ func (c *Core) removeCredEntry(path string) error {
 // remountCredEntryForce takes a copy of the mount entry for the path and fully
 // unmounts and remounts the backend to pick up any changes, such as filtered
 // paths
-func (c *Core) remountCredEntryForce(path string) error {
-	fullPath := credentialRoutePrefix + path
+func (c *Core) remountCredEntryForce(ns *namespace.Namespace, path string) error {
+	fullPath := ns.Path + credentialRoutePrefix + path
 	me := c.router.MatchingMountEntry(fullPath)
 	if me == nil {
 		return fmt.Errorf("cannot find mount for path '%s'", path)
@@ -245,21 +271,21 @@ func (c *Core) remountCredEntryForce(path string) error {
 		return err
 	}
 
-	if err := c.disableCredential(path); err != nil {
+	if err := c.disableCredential(ns, path); err != nil {
 		return err
 	}
 	return c.enableCredential(me)
 }
 
 // taintCredEntry is used to mark an entry in the auth table as tainted
-func (c *Core) taintCredEntry(path string) error {
+func (c *Core) taintCredEntry(ns *namespace.Namespace, path string) error {
 	c.authLock.Lock()
 	defer c.authLock.Unlock()
 
 	// Taint the entry from the auth table
 	// We do this on the original since setting the taint operates
 	// on the entries which a shallow clone shares anyways
-	entry := c.auth.setTaint(path, true)
+	entry := c.auth.setTaint(ns, path, true)
 
 	// Ensure there was a match
 	if entry == nil {
@@ -333,6 +359,14 @@ func (c *Core) loadCredentials() error {
 				entry.Accessor = accessor
 				needPersist = true
 			}
+			if entry.NamespaceID == "" {
+				entry.NamespaceID = namespace.RootNamespaceID
+				needPersist = true
+			}
+			if err := c.setMountEntryNamespace(entry); err != nil {
+				c.logger.Error("core: failed to resolve namespace of auth entry", "path", entry.Path, "error", err)
+				return errLoadAuthFailed
+			}
 		}
 
 		if !needPersist {
@@ -438,6 +472,11 @@ func (c *Core) setupCredentials() error {
 			persistNeeded = true
 		}
 
+		// Resolve the namespace of the entry
+		if err := c.setMountEntryNamespace(entry); err != nil {
+			return err
+		}
+
 		// Create a barrier view using the UUID
 		viewPath := credentialBarrierPrefix + entry.UUID + "/"
 		view = NewBarrierView(c.barrier, viewPath)
@@ -448,6 +487,16 @@ func (c *Core) setupCredentials() error {
 			conf["plugin_name"] = entry.Config.PluginName
 		}
 
+		// The token store of the root namespace is shared by all
+		// namespaces, so it only needs to be routed
+		if entry.Type == "token" && entry.Namespace().ID != namespace.RootNamespaceID {
+			if c.tokenStore == nil {
+				return fmt.Errorf("token store of the root namespace is not setup")
+			}
+			backend = c.tokenStore
+			goto ROUTER_MOUNT
+		}
+
 		backend, err = c.newCredentialBackend(entry.Type, sysView, view, conf)
 		if err != nil {
 			c.logger.Error("core: failed to create credential entry", "path", entry.Path, "error", err)
@@ -474,7 +523,7 @@ func (c *Core) setupCredentials() error {
 		}
 	ROUTER_MOUNT:
 		// Mount the backend
-		path := credentialRoutePrefix + entry.Path
+		path := entry.APIPath()
 		err = c.router.Mount(backend, path, entry, view)
 		if err != nil {
 			c.logger.Error("core: failed to mount auth entry", "path", entry.Path, "error", err)
@@ -487,7 +536,7 @@ func (c *Core) setupCredentials() error {
 		}
 
 		// Check if this is the token store
-		if entry.Type == "token" {
+		if entry.Type == "token" && entry.Namespace().ID == namespace.RootNamespaceID {
 			c.tokenStore = backend.(*TokenStore)
 
 			// this is loaded *after* the normal mounts, including cubbyhole
@@ -512,7 +561,7 @@ func (c *Core) teardownCredentials() error {
 	if c.auth != nil {
 		authTable := c.auth.shallowClone()
 		for _, e := range authTable.Entries {
-			backend := c.router.MatchingBackend(credentialRoutePrefix + e.Path)
+			backend := c.router.MatchingBackend(e.APIPath())
 			if backend != nil {
 				backend.Cleanup()
 			}
@@ -570,6 +619,8 @@ func (c *Core) defaultAuthTable() *MountTable {
 		Description: "token based credentials",
 		UUID:        tokenUUID,
 		Accessor:    tokenAccessor,
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
 	}
 	table.Entries = append(table.Entries, tokenAuth)
 	return table
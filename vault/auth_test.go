@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -217,7 +218,7 @@ func TestCore_DisableCredential(t *testing.T) {
 		return &NoopBackend{}, nil
 	}
 
-	err := c.disableCredential("foo")
+	err := c.disableCredential(namespace.RootNamespace, "foo")
 	if err != nil && !strings.HasPrefix(err.Error(), "no matching backend") {
 		t.Fatalf("err: %v", err)
 	}
@@ -232,7 +233,7 @@ func TestCore_DisableCredential(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 
-	err = c.disableCredential("foo")
+	err = c.disableCredential(namespace.RootNamespace, "foo")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -268,7 +269,7 @@ func TestCore_DisableCredential(t *testing.T) {
 
 func TestCore_DisableCredential_Protected(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
-	err := c.disableCredential("token")
+	err := c.disableCredential(namespace.RootNamespace, "token")
 	if err.Error() != "token credential backend cannot be disabled" {
 		t.Fatalf("err: %v", err)
 	}
@@ -324,7 +325,7 @@ func TestCore_DisableCredential_Cleanup(t *testing.T) {
 	}
 
 	// Disable should cleanup
-	err = c.disableCredential("foo")
+	err = c.disableCredential(namespace.RootNamespace, "foo")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
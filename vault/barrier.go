@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
 )
 
 var (
@@ -124,6 +125,11 @@ type SecurityBarrier interface {
 	// Rekey is used to change the master key used to protect the keyring
 	Rekey([]byte) error
 
+	// RekeyTxn is used to change the master key, persisting the keyring in
+	// the same transaction as the given operations. The physical backend
+	// must be transactional.
+	RekeyTxn([]byte, []*physical.TxnEntry) error
+
 	// For replication we must send over the keyring, so this must be available
 	Keyring() (*Keyring, error)
 
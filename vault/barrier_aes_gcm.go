@@ -134,30 +134,44 @@ func (b *AESGCMBarrier) Initialize(key []byte) error {
 // persistKeyring is used to write out the keyring using the
 // master key to encrypt it.
 func (b *AESGCMBarrier) persistKeyring(keyring *Keyring) error {
+	entries, err := b.keyringEntries(keyring)
+	if err != nil {
+		return err
+	}
+
+	if err := b.backend.Put(entries[0]); err != nil {
+		return fmt.Errorf("failed to persist keyring: %v", err)
+	}
+	if err := b.backend.Put(entries[1]); err != nil {
+		return fmt.Errorf("failed to persist master key: %v", err)
+	}
+	return nil
+}
+
+// keyringEntries returns the physical entries for the keyring, encrypted
+// with the master key, and for the master key, encrypted with the active key
+func (b *AESGCMBarrier) keyringEntries(keyring *Keyring) ([]*physical.Entry, error) {
 	// Create the keyring entry
 	keyringBuf, err := keyring.Serialize()
 	defer memzero(keyringBuf)
 	if err != nil {
-		return fmt.Errorf("failed to serialize keyring: %v", err)
+		return nil, fmt.Errorf("failed to serialize keyring: %v", err)
 	}
 
 	// Create the AES-GCM
 	gcm, err := b.aeadFromKey(keyring.MasterKey())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Encrypt the barrier init value
 	value := b.encrypt(keyringPath, initialKeyTerm, gcm, keyringBuf)
 
 	// Create the keyring physical entry
-	pe := &physical.Entry{
+	keyringEntry := &physical.Entry{
 		Key:   keyringPath,
 		Value: value,
 	}
-	if err := b.backend.Put(pe); err != nil {
-		return fmt.Errorf("failed to persist keyring: %v", err)
-	}
 
 	// Serialize the master key value
 	key := &Key{
@@ -168,26 +182,24 @@ func (b *AESGCMBarrier) persistKeyring(keyring *Keyring) error {
 	keyBuf, err := key.Serialize()
 	defer memzero(keyBuf)
 	if err != nil {
-		return fmt.Errorf("failed to serialize master key: %v", err)
+		return nil, fmt.Errorf("failed to serialize master key: %v", err)
 	}
 
 	// Encrypt the master key
 	activeKey := keyring.ActiveKey()
 	aead, err := b.aeadFromKey(activeKey.Value)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	value = b.encrypt(masterKeyPath, activeKey.Term, aead, keyBuf)
 
 	// Update the masterKeyPath for standby instances
-	pe = &physical.Entry{
+	masterKeyEntry := &physical.Entry{
 		Key:   masterKeyPath,
 		Value: value,
 	}
-	if err := b.backend.Put(pe); err != nil {
-		return fmt.Errorf("failed to persist master key: %v", err)
-	}
-	return nil
+
+	return []*physical.Entry{keyringEntry, masterKeyEntry}, nil
 }
 
 // GenerateKey is used to generate a new key
@@ -593,6 +605,44 @@ func (b *AESGCMBarrier) Rekey(key []byte) error {
 	return nil
 }
 
+// RekeyTxn is used to change the master key like Rekey, but persists the new
+// keyring in a single transaction together with the given operations
+func (b *AESGCMBarrier) RekeyTxn(key []byte, txns []*physical.TxnEntry) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	txnBackend, ok := b.backend.(physical.Transactional)
+	if !ok {
+		return fmt.Errorf("physical backend does not support transactions")
+	}
+
+	newKeyring, err := b.updateMasterKeyCommon(key)
+	if err != nil {
+		return err
+	}
+
+	entries, err := b.keyringEntries(newKeyring)
+	if err != nil {
+		return err
+	}
+	for _, pe := range entries {
+		txns = append(txns, &physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry:     pe,
+		})
+	}
+
+	if err := txnBackend.Transaction(txns); err != nil {
+		return fmt.Errorf("failed to persist keyring: %v", err)
+	}
+
+	// Swap the keyrings
+	oldKeyring := b.keyring
+	b.keyring = newKeyring
+	oldKeyring.Zeroize(false)
+	return nil
+}
+
 // SetMasterKey updates the keyring's in-memory master key but does not persist
 // anything to storage
 func (b *AESGCMBarrier) SetMasterKey(key []byte) error {
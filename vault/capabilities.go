@@ -28,9 +28,14 @@ func (c *Core) Capabilities(token, path string) ([]string, error) {
 		return []string{DenyCapability}, nil
 	}
 
+	ns := c.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		return []string{DenyCapability}, nil
+	}
+
 	var policies []*Policy
 	for _, tePolicy := range te.Policies {
-		policy, err := c.policyStore.GetPolicy(tePolicy, PolicyTypeToken)
+		policy, err := c.policyStore.GetPolicy(ns, tePolicy, PolicyTypeToken)
 		if err != nil {
 			return nil, err
 		}
@@ -41,7 +46,12 @@ func (c *Core) Capabilities(token, path string) ([]string, error) {
 		return []string{DenyCapability}, nil
 	}
 
-	acl, err := NewACL(policies)
+	entity, groups, err := c.fetchEntityAndGroups(te)
+	if err != nil {
+		return nil, err
+	}
+
+	acl, err := NewACLWithIdentity(policies, entity, groups)
 	if err != nil {
 		return nil, err
 	}
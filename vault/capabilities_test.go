@@ -3,6 +3,8 @@ package vault
 import (
 	"reflect"
 	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
 )
 
 func TestCapabilities(t *testing.T) {
@@ -19,7 +21,7 @@ func TestCapabilities(t *testing.T) {
 
 	// Create a policy
 	policy, _ := ParseACLPolicy(aclPolicy)
-	err = c.policyStore.SetPolicy(policy)
+	err = c.policyStore.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
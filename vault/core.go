@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/armon/go-radix"
 	log "github.com/mgutz/logxi/v1"
 
 	"golang.org/x/net/context"
@@ -31,6 +32,7 @@ import (
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logformat"
 	"github.com/hashicorp/vault/helper/mlock"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/reload"
 	"github.com/hashicorp/vault/helper/tlsutil"
 	"github.com/hashicorp/vault/logical"
@@ -172,9 +174,17 @@ type Core struct {
 	// physical backend is the un-trusted backend with durable data
 	physical physical.Backend
 
+	// underlyingPhysical is the physical backend as configured, without the
+	// cache layer, used to reach backend specific functionality
+	underlyingPhysical physical.Backend
+
 	// Our Seal, for seal configuration information
 	seal Seal
 
+	// migrationSeal is the seal being migrated away from, if any. It is
+	// only used until the stored data has been moved to seal.
+	migrationSeal Seal
+
 	// barrier is the security barrier wrapping the physical backend
 	barrier SecurityBarrier
 
@@ -233,6 +243,15 @@ type Core struct {
 	// change underneath a calling function
 	authLock sync.RWMutex
 
+	// namespacesByPath and namespacesByID index the namespaces, which are
+	// loaded after unseal. The root namespace is always present.
+	namespacesByPath *radix.Tree
+	namespacesByID   map[string]*namespace.Namespace
+
+	// namespacesLock is used to ensure that the namespaces do not change
+	// underneath a calling function
+	namespacesLock sync.RWMutex
+
 	// audit is loaded after unseal since it is a protected
 	// configuration
 	audit *MountTable
@@ -249,6 +268,9 @@ type Core struct {
 	// can be output in the audit logs
 	auditedHeaders *AuditedHeadersConfig
 
+	// quotas holds the rate limit and lease count quotas applied to requests
+	quotas *QuotaManager
+
 	// systemBackend is the backend which is used to manage internal operations
 	systemBackend *SystemBackend
 
@@ -398,6 +420,10 @@ type CoreConfig struct {
 
 	Seal Seal `json:"seal" structs:"seal" mapstructure:"seal"`
 
+	// MigrationSeal is the seal the stored data is currently protected with
+	// when migrating to Seal. May be nil, which disables seal migration.
+	MigrationSeal Seal `json:"migration_seal" structs:"migration_seal" mapstructure:"migration_seal"`
+
 	Logger log.Logger `json:"logger" structs:"logger" mapstructure:"logger"`
 
 	// Disables the LRU cache on the physical backend
@@ -474,6 +500,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	c := &Core{
 		devToken:                         conf.DevToken,
 		physical:                         conf.Physical,
+		underlyingPhysical:               conf.Physical,
 		redirectAddr:                     conf.RedirectAddr,
 		clusterAddr:                      conf.ClusterAddr,
 		seal:                             conf.Seal,
@@ -512,6 +539,11 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	}
 	c.seal.SetCore(c)
 
+	if conf.MigrationSeal != nil {
+		c.migrationSeal = conf.MigrationSeal
+		c.migrationSeal.SetCore(c)
+	}
+
 	var ok bool
 
 	// Wrap the physical backend in a cache layer if enabled
@@ -686,57 +718,91 @@ func (c *Core) fetchACLTokenEntryAndEntity(clientToken string) (*ACL, *TokenEntr
 		return nil, nil, nil, logical.ErrPermissionDenied
 	}
 
-	tokenPolicies := te.Policies
+	// The policies and the identity of the token come from its namespace
+	ns := c.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
 
-	var entity *identity.Entity
+	tokenPolicies := te.Policies
 
 	// Append the policies of the entity to those on the tokens and create ACL
 	// off of the combined list.
-	if te.EntityID != "" {
-		//c.logger.Debug("core: entity set on the token", "entity_id", te.EntityID)
-		// Fetch entity for the entity ID in the token entry
-		entity, err = c.identityStore.memDBEntityByID(te.EntityID, false)
+	entity, groups, err := c.fetchEntityAndGroups(te)
+	if err != nil {
+		c.logger.Error("core: failed to lookup the entity of the token", "error", err)
+		return nil, nil, nil, ErrInternalError
+	}
+	if entity != nil {
+		//c.logger.Debug("core: entity successfully fetched; adding entity policies to token's policies to create ACL")
+		// Attach the policies on the entity to the policies tied to the token
+		tokenPolicies = append(tokenPolicies, entity.Policies...)
+
+		groupPolicies, err := c.namespaceIdentityStore(ns).groupPoliciesByEntityID(entity.ID)
 		if err != nil {
-			c.logger.Error("core: failed to lookup entity using its ID", "error", err)
+			c.logger.Error("core: failed to fetch group policies", "error", err)
 			return nil, nil, nil, ErrInternalError
 		}
 
-		if entity == nil {
-			// If there was no corresponding entity object found, it is
-			// possible that the entity got merged into another entity. Try
-			// finding entity based on the merged entity index.
-			entity, err = c.identityStore.memDBEntityByMergedEntityID(te.EntityID, false)
-			if err != nil {
-				c.logger.Error("core: failed to lookup entity in merged entity ID index", "error", err)
-				return nil, nil, nil, ErrInternalError
-			}
-		}
+		// Attach the policies from all the groups to which this entity ID
+		// belongs to
+		tokenPolicies = append(tokenPolicies, groupPolicies...)
+	}
 
-		if entity != nil {
-			//c.logger.Debug("core: entity successfully fetched; adding entity policies to token's policies to create ACL")
-			// Attach the policies on the entity to the policies tied to the token
-			tokenPolicies = append(tokenPolicies, entity.Policies...)
+	// Construct the corresponding ACL object, resolving the templated
+	// policies using the identity of the token
+	acl, err := c.policyStore.ACL(ns, entity, groups, tokenPolicies...)
+	if err != nil {
+		c.logger.Error("core: failed to construct ACL", "error", err)
+		return nil, nil, nil, ErrInternalError
+	}
 
-			groupPolicies, err := c.identityStore.groupPoliciesByEntityID(entity.ID)
-			if err != nil {
-				c.logger.Error("core: failed to fetch group policies", "error", err)
-				return nil, nil, nil, ErrInternalError
-			}
+	return acl, te, entity, nil
+}
+
+// fetchEntityAndGroups returns the entity tied to the token entry, if any,
+// along with all the groups the entity belongs to, directly or through their
+// subgroups. Entities are looked up in the identity store of the namespace of
+// the token.
+func (c *Core) fetchEntityAndGroups(te *TokenEntry) (*identity.Entity, []*identity.Group, error) {
+	if te == nil || te.EntityID == "" {
+		return nil, nil, nil
+	}
+	ns := c.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		return nil, nil, nil
+	}
+	identityStore := c.namespaceIdentityStore(ns)
+	if identityStore == nil {
+		return nil, nil, nil
+	}
 
-			// Attach the policies from all the groups to which this entity ID
-			// belongs to
-			tokenPolicies = append(tokenPolicies, groupPolicies...)
+	// Fetch entity for the entity ID in the token entry
+	entity, err := identityStore.memDBEntityByID(te.EntityID, false)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("failed to lookup entity using its ID: {{err}}", err)
+	}
+
+	if entity == nil {
+		// If there was no corresponding entity object found, it is
+		// possible that the entity got merged into another entity. Try
+		// finding entity based on the merged entity index.
+		entity, err = identityStore.memDBEntityByMergedEntityID(te.EntityID, false)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf("failed to lookup entity in merged entity ID index: {{err}}", err)
 		}
 	}
 
-	// Construct the corresponding ACL object
-	acl, err := c.policyStore.ACL(tokenPolicies...)
+	if entity == nil {
+		return nil, nil, nil
+	}
+
+	groups, err := identityStore.transitiveGroupsByEntityID(entity.ID)
 	if err != nil {
-		c.logger.Error("core: failed to construct ACL", "error", err)
-		return nil, nil, nil, ErrInternalError
+		return nil, nil, errwrap.Wrapf("failed to fetch the groups of the entity: {{err}}", err)
 	}
 
-	return acl, te, entity, nil
+	return entity, groups, nil
 }
 
 func (c *Core) checkToken(req *logical.Request, unauth bool) (*logical.Auth, *TokenEntry, error) {
@@ -981,6 +1047,17 @@ func (c *Core) ResetUnsealProcess() {
 // this method is done with it. If you want to keep the key around, a copy
 // should be made.
 func (c *Core) Unseal(key []byte) (bool, error) {
+	return c.unseal(key, false)
+}
+
+// UnsealMigrate is used to provide a key part while migrating between seals.
+// Once enough parts are provided the stored data is moved to the new seal
+// and Vault is unsealed.
+func (c *Core) UnsealMigrate(key []byte) (bool, error) {
+	return c.unseal(key, true)
+}
+
+func (c *Core) unseal(key []byte, migrate bool) (bool, error) {
 	defer metrics.MeasureSince([]string{"core", "unseal"}, time.Now())
 
 	// Verify the key length
@@ -993,8 +1070,24 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 		return false, &ErrInvalidKey{fmt.Sprintf("key is longer than maximum %d bytes", max)}
 	}
 
+	migrating, err := c.sealMigrationPending()
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case migrating && !migrate:
+		return false, ErrSealMigrationInProgress
+	case !migrating && migrate:
+		return false, ErrNoSealMigration
+	}
+
 	// Get the seal configuration
-	config, err := c.seal.BarrierConfig()
+	var config *SealConfig
+	if migrating {
+		config, err = c.sealMigrationKeyConfig()
+	} else {
+		config, err = c.seal.BarrierConfig()
+	}
 	if err != nil {
 		return false, err
 	}
@@ -1016,11 +1109,18 @@ func (c *Core) Unseal(key []byte) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if masterKey != nil {
-		return c.unsealInternal(masterKey)
+	if masterKey == nil {
+		return false, nil
 	}
 
-	return false, nil
+	if migrating {
+		masterKey, err = c.migrateSeal(masterKey)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return c.unsealInternal(masterKey)
 }
 
 func (c *Core) unsealPart(config *SealConfig, key []byte) ([]byte, error) {
@@ -1467,6 +1567,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupPluginCatalog(); err != nil {
 		return err
 	}
+	if err := c.loadNamespaces(); err != nil {
+		return err
+	}
 	if err := c.loadMounts(); err != nil {
 		return err
 	}
@@ -1491,6 +1594,9 @@ func (c *Core) postUnseal() (retErr error) {
 	if err := c.setupExpiration(); err != nil {
 		return err
 	}
+	if err := c.setupQuotas(); err != nil {
+		return err
+	}
 	if err := c.loadAudits(); err != nil {
 		return err
 	}
@@ -1538,6 +1644,7 @@ func (c *Core) preSeal() error {
 	if err := c.teardownAudits(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error tearing down audits: {{err}}", err))
 	}
+	c.teardownQuotas()
 	if err := c.stopExpiration(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error stopping expiration: {{err}}", err))
 	}
@@ -1553,6 +1660,7 @@ func (c *Core) preSeal() error {
 	if err := c.unloadMounts(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error unloading mounts: {{err}}", err))
 	}
+	c.unloadNamespaces()
 	if err := enterprisePreSeal(c); err != nil {
 		result = multierror.Append(result, err)
 	}
@@ -1990,6 +2098,9 @@ func (c *Core) emitMetrics(stopCh chan struct{}) {
 			if c.expiration != nil {
 				c.expiration.emitMetrics()
 			}
+			if c.quotas != nil {
+				c.quotas.emitMetrics()
+			}
 			c.metricsMutex.Unlock()
 		case <-stopCh:
 			return
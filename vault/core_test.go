@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/physical/inmem"
@@ -1931,7 +1932,7 @@ path "secret/*" {
 
 	ps := c.policyStore
 	policy, _ := ParseACLPolicy(secretWritingPolicy)
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
@@ -41,8 +41,20 @@ func (d dynamicSystemView) SudoPrivilege(path string, token string) bool {
 		return false
 	}
 
+	ns := d.core.namespaceByID(te.NamespaceID)
+	if ns == nil {
+		d.core.logger.Error("namespace not found for given token")
+		return false
+	}
+
+	entity, groups, err := d.core.fetchEntityAndGroups(te)
+	if err != nil {
+		d.core.logger.Error("failed to lookup the entity of the token", "error", err)
+		return false
+	}
+
 	// Construct the corresponding ACL object
-	acl, err := d.core.policyStore.ACL(te.Policies...)
+	acl, err := d.core.policyStore.ACL(ns, entity, groups, te.Policies...)
 	if err != nil {
 		d.core.logger.Error("failed to retrieve ACL for token's policies", "token_policies", te.Policies, "error", err)
 		return false
@@ -60,6 +60,13 @@ type ExpirationManager struct {
 	pending     map[string]*time.Timer
 	pendingLock sync.RWMutex
 
+	// leaseCounts holds the number of leases under the path of each lease
+	// count quota, including the leases reserved while being registered.
+	// Along with reserved and quotas, it is guarded by pendingLock.
+	leaseCounts map[string]int
+	reserved    map[string]struct{}
+	quotas      *QuotaManager
+
 	tidyLock int32
 
 	restoreMode        int32
@@ -85,6 +92,9 @@ func NewExpirationManager(router *Router, view *BarrierView, ts *TokenStore, log
 		logger:     logger,
 		pending:    make(map[string]*time.Timer),
 
+		leaseCounts: make(map[string]int),
+		reserved:    make(map[string]struct{}),
+
 		// new instances of the expiration manager will go immediately into
 		// restore mode
 		restoreMode:  1,
@@ -435,6 +445,10 @@ func (m *ExpirationManager) Stop() error {
 		timer.Stop()
 	}
 	m.pending = make(map[string]*time.Timer)
+	for prefix := range m.leaseCounts {
+		m.leaseCounts[prefix] = 0
+	}
+	m.reserved = make(map[string]struct{})
 	m.pendingLock.Unlock()
 
 	close(m.quitCh)
@@ -503,6 +517,7 @@ func (m *ExpirationManager) revokeCommon(leaseID string, force, skipToken bool)
 	if timer, ok := m.pending[leaseID]; ok {
 		timer.Stop()
 		delete(m.pending, leaseID)
+		m.countLease(leaseID, -1)
 	}
 	m.pendingLock.Unlock()
 	return nil
@@ -786,6 +801,10 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 
 	leaseID := path.Join(req.Path, leaseUUID)
 
+	if err := m.reserveLease(leaseID); err != nil {
+		return "", err
+	}
+
 	defer func() {
 		// If there is an error we want to rollback as much as possible (note
 		// that errors here are ignored to do as much cleanup as we can). We
@@ -806,6 +825,8 @@ func (m *ExpirationManager) Register(req *logical.Request, resp *logical.Respons
 			if err := m.removeIndexByToken(req.ClientToken, leaseID); err != nil {
 				retErr = multierror.Append(retErr, errwrap.Wrapf("an additional error was encountered removing lease indexes associated with the newly-generated secret: {{err}}", err))
 			}
+
+			m.releaseLease(leaseID)
 		}
 	}()
 
@@ -865,8 +886,13 @@ func (m *ExpirationManager) RegisterAuth(source string, auth *logical.Auth) erro
 		ExpireTime:  auth.ExpirationTime(),
 	}
 
+	if err := m.reserveLease(le.LeaseID); err != nil {
+		return err
+	}
+
 	// Encode the entry
 	if err := m.persistEntry(&le); err != nil {
+		m.releaseLease(le.LeaseID)
 		return err
 	}
 
@@ -938,6 +964,10 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 		if ok {
 			timer.Stop()
 			delete(m.pending, le.LeaseID)
+			m.countLease(le.LeaseID, -1)
+		}
+		if m.unreserveLease(le.LeaseID) {
+			m.countLease(le.LeaseID, -1)
 		}
 		return
 	}
@@ -948,6 +978,11 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 			m.expireID(le.LeaseID)
 		})
 		m.pending[le.LeaseID] = timer
+
+		// A reserved lease has been counted already
+		if !m.unreserveLease(le.LeaseID) {
+			m.countLease(le.LeaseID, 1)
+		}
 		return
 	}
 
@@ -959,7 +994,10 @@ func (m *ExpirationManager) updatePending(le *leaseEntry, leaseTotal time.Durati
 func (m *ExpirationManager) expireID(leaseID string) {
 	// Clear from the pending expiration
 	m.pendingLock.Lock()
-	delete(m.pending, leaseID)
+	if _, ok := m.pending[leaseID]; ok {
+		delete(m.pending, leaseID)
+		m.countLease(leaseID, -1)
+	}
 	m.pendingLock.Unlock()
 
 	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
@@ -1024,7 +1062,7 @@ func (m *ExpirationManager) renewAuthEntry(req *logical.Request, le *leaseEntry,
 	auth := *le.Auth
 	auth.IssueTime = le.IssueTime
 	auth.Increment = increment
-	if strings.HasPrefix(le.Path, "auth/token/") {
+	if me := m.router.MatchingMountEntry(le.Path); me != nil && me.Type == "token" {
 		auth.ClientToken = le.ClientToken
 	} else {
 		auth.ClientToken = ""
@@ -1208,6 +1246,116 @@ func (m *ExpirationManager) lookupByToken(token string) ([]string, error) {
 	return leaseIDs, nil
 }
 
+// setQuotas sets the quota manager whose lease count quotas are enforced
+// when registering leases
+func (m *ExpirationManager) setQuotas(quotas *QuotaManager) {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+	m.quotas = quotas
+}
+
+// trackLeaseCount starts counting the leases under the given prefix. The
+// existing leases are only scanned once, after which the count is kept up to
+// date as leases are registered and revoked.
+func (m *ExpirationManager) trackLeaseCount(prefix string) {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	if _, ok := m.leaseCounts[prefix]; ok {
+		return
+	}
+	count := 0
+	for leaseID := range m.pending {
+		if strings.HasPrefix(leaseID, prefix) {
+			count++
+		}
+	}
+	for leaseID := range m.reserved {
+		if strings.HasPrefix(leaseID, prefix) {
+			count++
+		}
+	}
+	m.leaseCounts[prefix] = count
+}
+
+// untrackLeaseCount stops counting the leases under the given prefix
+func (m *ExpirationManager) untrackLeaseCount(prefix string) {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+	delete(m.leaseCounts, prefix)
+}
+
+// countLeases returns the number of leases under the given prefix, which
+// must be tracked
+func (m *ExpirationManager) countLeases(prefix string) int {
+	m.pendingLock.RLock()
+	defer m.pendingLock.RUnlock()
+	return m.leaseCounts[prefix]
+}
+
+// countLease adds delta to the count of every tracked prefix of the lease.
+// The caller must hold pendingLock.
+func (m *ExpirationManager) countLease(leaseID string, delta int) {
+	for prefix := range m.leaseCounts {
+		if strings.HasPrefix(leaseID, prefix) {
+			m.leaseCounts[prefix] += delta
+		}
+	}
+}
+
+// reserveLease counts a lease before it is registered, failing if this
+// would exceed the lease count quota that applies to it. Checking and
+// counting happen under the same lock, so concurrent registrations cannot
+// exceed the quota.
+func (m *ExpirationManager) reserveLease(leaseID string) error {
+	m.pendingLock.RLock()
+	quotas := m.quotas
+	m.pendingLock.RUnlock()
+
+	// The quota is matched without holding pendingLock, since the quota
+	// manager calls into the expiration manager while holding its own lock
+	var q *Quota
+	if quotas != nil {
+		q = quotas.match(quotaTypeLeaseCount, leaseID)
+	}
+
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	if q != nil {
+		if count, ok := m.leaseCounts[q.Path]; ok && count >= q.MaxLeases {
+			metrics.IncrCounter([]string{"quota", "lease_count", q.Name, "violation"}, 1)
+			return &logical.QuotaExceededError{
+				Err: logical.ErrLeaseCountQuotaExceeded,
+			}
+		}
+	}
+
+	m.reserved[leaseID] = struct{}{}
+	m.countLease(leaseID, 1)
+	return nil
+}
+
+// releaseLease gives back the reservation of a lease that failed to register
+func (m *ExpirationManager) releaseLease(leaseID string) {
+	m.pendingLock.Lock()
+	defer m.pendingLock.Unlock()
+
+	if m.unreserveLease(leaseID) {
+		m.countLease(leaseID, -1)
+	}
+}
+
+// unreserveLease removes the reservation of a lease, reporting whether it
+// had one. The caller must hold pendingLock.
+func (m *ExpirationManager) unreserveLease(leaseID string) bool {
+	if _, ok := m.reserved[leaseID]; !ok {
+		return false
+	}
+	delete(m.reserved, leaseID)
+	return true
+}
+
 // emitMetrics is invoked periodically to emit statistics
 func (m *ExpirationManager) emitMetrics() {
 	m.pendingLock.RLock()
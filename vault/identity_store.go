@@ -51,6 +51,7 @@ func NewIdentityStore(core *Core, config *logical.BackendConfig) (*IdentityStore
 			entityPaths(iStore),
 			aliasPaths(iStore),
 			groupPaths(iStore),
+			groupAliasPaths(iStore),
 			lookupPaths(iStore),
 			upgradePaths(iStore),
 		),
@@ -296,7 +297,7 @@ func (i *IdentityStore) CreateEntity(alias *logical.Alias) (*identity.Entity, er
 
 	// Create a new alias
 	newAlias := &identity.Alias{
-		EntityID:      entity.ID,
+		CanonicalID:   entity.ID,
 		Name:          alias.Name,
 		MountAccessor: alias.MountAccessor,
 		MountPath:     mountValidationResp.MountPath,
@@ -255,7 +255,7 @@ func (i *IdentityStore) handleAliasUpdateCommon(req *logical.Request, d *framewo
 
 	// Set the entity ID in the alias index. This should be done after
 	// sanitizing entity.
-	alias.EntityID = entity.ID
+	alias.CanonicalID = entity.ID
 
 	// ID creation and other validations
 	err = i.sanitizeAlias(alias)
@@ -300,7 +300,7 @@ func (i *IdentityStore) pathAliasIDRead(req *logical.Request, d *framework.Field
 
 	respData := map[string]interface{}{}
 	respData["id"] = alias.ID
-	respData["entity_id"] = alias.EntityID
+	respData["entity_id"] = alias.CanonicalID
 	respData["mount_type"] = alias.MountType
 	respData["mount_accessor"] = alias.MountAccessor
 	respData["mount_path"] = alias.MountPath
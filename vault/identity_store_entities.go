@@ -210,7 +210,7 @@ func (i *IdentityStore) pathEntityMergeID(req *logical.Request, d *framework.Fie
 
 		for _, alias := range fromEntity.Aliases {
 			// Set the desired entity id
-			alias.EntityID = toEntity.ID
+			alias.CanonicalID = toEntity.ID
 
 			// Set the entity id of which this alias is now an alias to
 			alias.MergedFromEntityIDs = append(alias.MergedFromEntityIDs, fromEntity.ID)
@@ -427,7 +427,7 @@ func (i *IdentityStore) pathEntityIDRead(req *logical.Request, d *framework.Fiel
 	for aliasIdx, alias := range entity.Aliases {
 		aliasMap := map[string]interface{}{}
 		aliasMap["id"] = alias.ID
-		aliasMap["entity_id"] = alias.EntityID
+		aliasMap["entity_id"] = alias.CanonicalID
 		aliasMap["mount_type"] = alias.MountType
 		aliasMap["mount_accessor"] = alias.MountAccessor
 		aliasMap["mount_path"] = alias.MountPath
@@ -0,0 +1,323 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	memdb "github.com/hashicorp/go-memdb"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// groupAliasPaths returns the API endpoints to operate on group aliases.
+// Following are the paths supported:
+// group-alias - To register/modify a group alias
+// group-alias/id - To lookup, delete and list group aliases based on ID
+func groupAliasPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "group-alias$",
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the group alias.",
+				},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Alias of the group.",
+				},
+				"mount_accessor": {
+					Type:        framework.TypeString,
+					Description: "Mount accessor to which this alias belongs to.",
+				},
+				"canonical_id": {
+					Type:        framework.TypeString,
+					Description: "ID of the external group to which this is an alias.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupAliasRegister,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupAliasHelp["group-alias"][0]),
+			HelpDescription: strings.TrimSpace(groupAliasHelp["group-alias"][1]),
+		},
+		{
+			Pattern: "group-alias/id/" + framework.GenericNameRegex("id"),
+			Fields: map[string]*framework.FieldSchema{
+				"id": {
+					Type:        framework.TypeString,
+					Description: "ID of the group alias.",
+				},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Alias of the group.",
+				},
+				"mount_accessor": {
+					Type:        framework.TypeString,
+					Description: "Mount accessor to which this alias belongs to.",
+				},
+				"canonical_id": {
+					Type:        framework.TypeString,
+					Description: "ID of the external group to which this is an alias.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathGroupAliasIDUpdate,
+				logical.ReadOperation:   i.pathGroupAliasIDRead,
+				logical.DeleteOperation: i.pathGroupAliasIDDelete,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupAliasHelp["group-alias-by-id"][0]),
+			HelpDescription: strings.TrimSpace(groupAliasHelp["group-alias-by-id"][1]),
+		},
+		{
+			Pattern: "group-alias/id/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathGroupAliasIDList,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(groupAliasHelp["group-alias-id-list"][0]),
+			HelpDescription: strings.TrimSpace(groupAliasHelp["group-alias-id-list"][1]),
+		},
+	}
+}
+
+// pathGroupAliasRegister is used to register a new group alias
+func (i *IdentityStore) pathGroupAliasRegister(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	_, ok := d.GetOk("id")
+	if ok {
+		return i.pathGroupAliasIDUpdate(req, d)
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	return i.handleGroupAliasUpdateCommon(req, d, nil)
+}
+
+// pathGroupAliasIDUpdate is used to update a group alias based on the given
+// alias ID
+func (i *IdentityStore) pathGroupAliasIDUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	groupAliasID := d.Get("id").(string)
+	if groupAliasID == "" {
+		return logical.ErrorResponse("empty group alias ID"), nil
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	groupAlias, err := i.memDBGroupAliasByID(groupAliasID, true)
+	if err != nil {
+		return nil, err
+	}
+	if groupAlias == nil {
+		return logical.ErrorResponse("invalid group alias ID"), nil
+	}
+
+	return i.handleGroupAliasUpdateCommon(req, d, groupAlias)
+}
+
+// handleGroupAliasUpdateCommon is used to register or update a group alias.
+// The alias is stored along with the external group it belongs to. This must
+// be called with the group lock held.
+func (i *IdentityStore) handleGroupAliasUpdateCommon(req *logical.Request, d *framework.FieldData, groupAlias *identity.Alias) (*logical.Response, error) {
+	var err error
+	var newGroupAlias bool
+	var previousGroup *identity.Group
+
+	if groupAlias == nil {
+		groupAlias = &identity.Alias{}
+		newGroupAlias = true
+	}
+
+	groupAliasName := d.Get("name").(string)
+	if groupAliasName == "" {
+		return logical.ErrorResponse("missing alias name"), nil
+	}
+
+	mountAccessor := d.Get("mount_accessor").(string)
+	if mountAccessor == "" {
+		return logical.ErrorResponse("missing mount_accessor"), nil
+	}
+
+	mountValidationResp := i.validateMountAccessorFunc(mountAccessor)
+	if mountValidationResp == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid mount accessor %q", mountAccessor)), nil
+	}
+
+	groupAliasByFactors, err := i.memDBGroupAliasByFactors(mountValidationResp.MountAccessor, groupAliasName, false)
+	if err != nil {
+		return nil, err
+	}
+	if groupAliasByFactors != nil && (newGroupAlias || groupAliasByFactors.ID != groupAlias.ID) {
+		return logical.ErrorResponse("combination of mount and group alias name is already in use"), nil
+	}
+
+	canonicalID := d.Get("canonical_id").(string)
+	if canonicalID == "" {
+		if newGroupAlias {
+			return logical.ErrorResponse("missing canonical_id"), nil
+		}
+		canonicalID = groupAlias.CanonicalID
+	}
+
+	group, err := i.memDBGroupByID(canonicalID, true)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return logical.ErrorResponse("invalid canonical_id"), nil
+	}
+	if group.Type != groupTypeExternal {
+		return logical.ErrorResponse("alias can't be set on an internal group"), nil
+	}
+	if group.Alias != nil && group.Alias.ID != groupAlias.ID {
+		return logical.ErrorResponse("group already has an alias"), nil
+	}
+
+	// If the alias is being moved to a different group, it needs to be
+	// removed from the group it currently belongs to
+	if !newGroupAlias && groupAlias.CanonicalID != group.ID {
+		previousGroup, err = i.memDBGroupByID(groupAlias.CanonicalID, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groupAlias.Name = groupAliasName
+	groupAlias.MountType = mountValidationResp.MountType
+	groupAlias.MountAccessor = mountValidationResp.MountAccessor
+	groupAlias.MountPath = mountValidationResp.MountPath
+	groupAlias.CanonicalID = group.ID
+
+	// ID creation and other validations
+	err = i.sanitizeAlias(groupAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	if previousGroup != nil {
+		previousGroup.Alias = nil
+		err = i.sanitizeAndUpsertGroup(previousGroup, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	group.Alias = groupAlias
+	err = i.sanitizeAndUpsertGroup(group, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":           groupAlias.ID,
+			"canonical_id": group.ID,
+		},
+	}, nil
+}
+
+// pathGroupAliasIDRead returns the properties of a group alias for a given
+// alias ID
+func (i *IdentityStore) pathGroupAliasIDRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	groupAliasID := d.Get("id").(string)
+	if groupAliasID == "" {
+		return logical.ErrorResponse("empty group alias id"), nil
+	}
+
+	groupAlias, err := i.memDBGroupAliasByID(groupAliasID, false)
+	if err != nil {
+		return nil, err
+	}
+	if groupAlias == nil {
+		return nil, nil
+	}
+
+	respData := map[string]interface{}{}
+	respData["id"] = groupAlias.ID
+	respData["canonical_id"] = groupAlias.CanonicalID
+	respData["mount_type"] = groupAlias.MountType
+	respData["mount_accessor"] = groupAlias.MountAccessor
+	respData["mount_path"] = groupAlias.MountPath
+	respData["name"] = groupAlias.Name
+
+	// Convert protobuf timestamp into RFC3339 format
+	respData["creation_time"] = ptypes.TimestampString(groupAlias.CreationTime)
+	respData["last_update_time"] = ptypes.TimestampString(groupAlias.LastUpdateTime)
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+// pathGroupAliasIDDelete deletes the group's alias for a given group alias ID
+func (i *IdentityStore) pathGroupAliasIDDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	groupAliasID := d.Get("id").(string)
+	if groupAliasID == "" {
+		return logical.ErrorResponse("missing group alias ID"), nil
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	groupAlias, err := i.memDBGroupAliasByID(groupAliasID, false)
+	if err != nil {
+		return nil, err
+	}
+	if groupAlias == nil {
+		return nil, nil
+	}
+
+	group, err := i.memDBGroupByID(groupAlias.CanonicalID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The group alias is stored within the group; remove it from there
+	if group == nil || group.Alias == nil || group.Alias.ID != groupAlias.ID {
+		return nil, fmt.Errorf("group alias is not associated with its group")
+	}
+
+	group.Alias = nil
+	return nil, i.sanitizeAndUpsertGroup(group, nil)
+}
+
+// pathGroupAliasIDList lists the IDs of all the valid group aliases in the
+// identity store
+func (i *IdentityStore) pathGroupAliasIDList(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ws := memdb.NewWatchSet()
+	iter, err := i.memDBGroupAliases(ws)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch iterator for group aliases in memdb: %v", err)
+	}
+
+	var groupAliasIDs []string
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		groupAliasIDs = append(groupAliasIDs, raw.(*identity.Alias).ID)
+	}
+
+	return logical.ListResponse(groupAliasIDs), nil
+}
+
+var groupAliasHelp = map[string][2]string{
+	"group-alias": {
+		"Creates a new group alias, or updates an existing one.",
+		"",
+	},
+	"group-alias-by-id": {
+		"Update, read or delete a group alias using its ID.",
+		"",
+	},
+	"group-alias-id-list": {
+		"List all the group alias IDs.",
+		"",
+	},
+}
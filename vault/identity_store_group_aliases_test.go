@@ -0,0 +1,325 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func testIdentityStoreExternalGroup(t *testing.T, is *IdentityStore, name string) string {
+	resp, err := is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group",
+		Data: map[string]interface{}{
+			"name":     name,
+			"type":     "external",
+			"policies": name + "-policy",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	return resp.Data["id"].(string)
+}
+
+func TestIdentityStore_GroupAliases_CRUD(t *testing.T) {
+	var resp *logical.Response
+	var err error
+	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(t)
+
+	groupID := testIdentityStoreExternalGroup(t, is, "testgroupname")
+
+	groupAliasReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group-alias",
+		Data: map[string]interface{}{
+			"name":           "testgroupaliasname",
+			"mount_accessor": githubAccessor,
+			"canonical_id":   groupID,
+		},
+	}
+	resp, err = is.HandleRequest(groupAliasReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	groupAliasID := resp.Data["id"].(string)
+
+	// The same alias can't be registered twice
+	resp, err = is.HandleRequest(groupAliasReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error registering a duplicate group alias")
+	}
+
+	groupAliasReq.Operation = logical.ReadOperation
+	groupAliasReq.Path = "group-alias/id/" + groupAliasID
+	resp, err = is.HandleRequest(groupAliasReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["id"].(string) != groupAliasID ||
+		resp.Data["canonical_id"].(string) != groupID ||
+		resp.Data["name"].(string) != "testgroupaliasname" ||
+		resp.Data["mount_accessor"].(string) != githubAccessor ||
+		resp.Data["mount_type"].(string) != "github" {
+		t.Fatalf("bad: group alias: %#v", resp.Data)
+	}
+
+	// The alias is returned along with the group
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "group/id/" + groupID,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["type"].(string) != "external" {
+		t.Fatalf("bad: group type: %#v", resp.Data["type"])
+	}
+	if resp.Data["alias"].(map[string]interface{})["id"].(string) != groupAliasID {
+		t.Fatalf("bad: group alias: %#v", resp.Data["alias"])
+	}
+
+	groupAliasReq.Operation = logical.UpdateOperation
+	groupAliasReq.Data["name"] = "updatedgroupaliasname"
+	resp, err = is.HandleRequest(groupAliasReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	groupAlias, err := is.memDBGroupAliasByFactors(githubAccessor, "updatedgroupaliasname", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias == nil || groupAlias.ID != groupAliasID {
+		t.Fatalf("bad: group alias after update: %#v", groupAlias)
+	}
+	groupAlias, err = is.memDBGroupAliasByFactors(githubAccessor, "testgroupaliasname", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias != nil {
+		t.Fatalf("expected the previous name of the group alias to be removed")
+	}
+
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "group-alias/id",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != groupAliasID {
+		t.Fatalf("bad: group alias IDs: %#v", keys)
+	}
+
+	groupAliasReq.Operation = logical.DeleteOperation
+	resp, err = is.HandleRequest(groupAliasReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	groupAlias, err = is.memDBGroupAliasByID(groupAliasID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias != nil {
+		t.Fatalf("expected the group alias to be deleted")
+	}
+	group, err := is.memDBGroupByID(groupID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Alias != nil {
+		t.Fatalf("expected the group alias to be removed from the group")
+	}
+}
+
+func TestIdentityStore_GroupAliases_DeleteGroup(t *testing.T) {
+	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(t)
+
+	groupID := testIdentityStoreExternalGroup(t, is, "testgroupname")
+	resp, err := is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group-alias",
+		Data: map[string]interface{}{
+			"name":           "testgroupaliasname",
+			"mount_accessor": githubAccessor,
+			"canonical_id":   groupID,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	groupAliasID := resp.Data["id"].(string)
+
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "group/id/" + groupID,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	groupAlias, err := is.memDBGroupAliasByID(groupAliasID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias != nil {
+		t.Fatalf("expected the group alias to be deleted along with the group")
+	}
+}
+
+func TestIdentityStore_GroupAliases_InternalGroup(t *testing.T) {
+	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(t)
+
+	resp, err := is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group",
+		Data: map[string]interface{}{
+			"name": "testgroupname",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	groupID := resp.Data["id"].(string)
+
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group-alias",
+		Data: map[string]interface{}{
+			"name":           "testgroupaliasname",
+			"mount_accessor": githubAccessor,
+			"canonical_id":   groupID,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error setting an alias on an internal group")
+	}
+
+	// The type of a group can't be changed
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group/id/" + groupID,
+		Data: map[string]interface{}{
+			"type": "external",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error changing the group type")
+	}
+
+	// Memberships of external groups can't be set manually
+	externalGroupID := testIdentityStoreExternalGroup(t, is, "externalgroupname")
+	resp, err = is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "group/id/" + externalGroupID,
+		Data: map[string]interface{}{
+			"member_entity_ids": "someentityid",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error setting members of an external group")
+	}
+}
+
+func TestIdentityStore_RefreshExternalGroupMemberships(t *testing.T) {
+	is, githubAccessor, _ := testIdentityStoreWithGithubAuth(t)
+
+	resp, err := is.HandleRequest(&logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "entity",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	entityID := resp.Data["id"].(string)
+
+	groupIDs := make(map[string]string)
+	for _, name := range []string{"eng", "ops"} {
+		groupIDs[name] = testIdentityStoreExternalGroup(t, is, name)
+		resp, err = is.HandleRequest(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "group-alias",
+			Data: map[string]interface{}{
+				"name":           name,
+				"mount_accessor": githubAccessor,
+				"canonical_id":   groupIDs[name],
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+	}
+
+	checkMemberships := func(expected ...string) {
+		t.Helper()
+		groups, err := is.memDBGroupsByMemberEntityID(entityID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := make(map[string]bool)
+		for _, group := range groups {
+			actual[group.Name] = true
+		}
+		if len(actual) != len(expected) {
+			t.Fatalf("bad: memberships; expected: %v, actual: %v", expected, actual)
+		}
+		for _, name := range expected {
+			if !actual[name] {
+				t.Fatalf("bad: memberships; expected: %v, actual: %v", expected, actual)
+			}
+		}
+	}
+
+	// Groups of the authentication source without an alias are ignored
+	err = is.refreshExternalGroupMembershipsByEntityID(entityID, githubAccessor, []*logical.Alias{
+		{Name: "eng"},
+		{Name: "unmapped"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMemberships("eng")
+
+	policies, err := is.groupPoliciesByEntityID(entityID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 1 || policies[0] != "eng-policy" {
+		t.Fatalf("bad: policies: %#v", policies)
+	}
+
+	err = is.refreshExternalGroupMembershipsByEntityID(entityID, githubAccessor, []*logical.Alias{
+		{Name: "ops"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMemberships("ops")
+
+	// Memberships of groups of other mounts are left alone
+	err = is.refreshExternalGroupMembershipsByEntityID(entityID, "someotheraccessor", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMemberships("ops")
+
+	err = is.refreshExternalGroupMembershipsByEntityID(entityID, githubAccessor, []*logical.Alias{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMemberships()
+}
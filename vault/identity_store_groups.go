@@ -11,6 +11,11 @@ import (
 	"github.com/hashicorp/vault/logical/framework"
 )
 
+const (
+	groupTypeInternal = "internal"
+	groupTypeExternal = "external"
+)
+
 func groupPaths(i *IdentityStore) []*framework.Path {
 	return []*framework.Path{
 		{
@@ -24,6 +29,10 @@ func groupPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Name of the group.",
 				},
+				"type": {
+					Type:        framework.TypeString,
+					Description: "Type of the group, 'internal' or 'external'. Defaults to 'internal'",
+				},
 				"metadata": {
 					Type:        framework.TypeStringSlice,
 					Description: "Metadata to be associated with the group. Format should be a list of `key=value` pairs.",
@@ -59,6 +68,10 @@ func groupPaths(i *IdentityStore) []*framework.Path {
 					Type:        framework.TypeString,
 					Description: "Name of the group.",
 				},
+				"type": {
+					Type:        framework.TypeString,
+					Description: "Type of the group, 'internal' or 'external'. Defaults to 'internal'",
+				},
 				"metadata": {
 					Type:        framework.TypeStringSlice,
 					Description: "Metadata to be associated with the group. Format should be a list of `key=value` pairs.",
@@ -137,6 +150,23 @@ func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framewo
 		newGroup = true
 	}
 
+	// The type of a group is set when it is created, as the memberships of
+	// external groups are managed by the authentication backends
+	groupTypeRaw, ok := d.GetOk("type")
+	switch {
+	case ok && group.Type != "" && groupTypeRaw.(string) != group.Type:
+		return logical.ErrorResponse("group type cannot be changed"), nil
+	case ok:
+		group.Type = groupTypeRaw.(string)
+	case group.Type == "":
+		group.Type = groupTypeInternal
+	}
+	switch group.Type {
+	case groupTypeInternal, groupTypeExternal:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid group type %q", group.Type)), nil
+	}
+
 	// Update the policies if supplied
 	policiesRaw, ok := d.GetOk("policies")
 	if ok {
@@ -173,6 +203,9 @@ func (i *IdentityStore) handleGroupUpdateCommon(req *logical.Request, d *framewo
 
 	memberEntityIDsRaw, ok := d.GetOk("member_entity_ids")
 	if ok {
+		if group.Type == groupTypeExternal {
+			return logical.ErrorResponse("member entities can't be set manually for external groups"), nil
+		}
 		group.MemberEntityIDs = memberEntityIDsRaw.([]string)
 		if len(group.MemberEntityIDs) > 512 {
 			return logical.ErrorResponse("member entity IDs exceeding the limit of 512"), nil
@@ -224,6 +257,7 @@ func (i *IdentityStore) handleGroupReadCommon(group *identity.Group) (*logical.R
 	respData := map[string]interface{}{}
 	respData["id"] = group.ID
 	respData["name"] = group.Name
+	respData["type"] = group.Type
 	respData["policies"] = group.Policies
 	respData["member_entity_ids"] = group.MemberEntityIDs
 	respData["metadata"] = group.Metadata
@@ -231,6 +265,19 @@ func (i *IdentityStore) handleGroupReadCommon(group *identity.Group) (*logical.R
 	respData["last_update_time"] = ptypes.TimestampString(group.LastUpdateTime)
 	respData["modify_index"] = group.ModifyIndex
 
+	aliasMap := map[string]interface{}{}
+	if group.Alias != nil {
+		aliasMap["id"] = group.Alias.ID
+		aliasMap["canonical_id"] = group.Alias.CanonicalID
+		aliasMap["mount_type"] = group.Alias.MountType
+		aliasMap["mount_accessor"] = group.Alias.MountAccessor
+		aliasMap["mount_path"] = group.Alias.MountPath
+		aliasMap["name"] = group.Alias.Name
+		aliasMap["creation_time"] = ptypes.TimestampString(group.Alias.CreationTime)
+		aliasMap["last_update_time"] = ptypes.TimestampString(group.Alias.LastUpdateTime)
+	}
+	respData["alias"] = aliasMap
+
 	memberGroupIDs, err := i.memberGroupIDsByID(group.ID)
 	if err != nil {
 		return nil, err
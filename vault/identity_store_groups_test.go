@@ -201,6 +201,8 @@ func TestIdentityStore_GroupsCreateUpdate(t *testing.T) {
 			"testkey1": "testvalue1",
 			"testkey2": "testvalue2",
 		},
+		"type":  "internal",
+		"alias": map[string]interface{}{},
 	}
 	expectedData["id"] = resp.Data["id"]
 	expectedData["name"] = resp.Data["name"]
@@ -319,6 +321,8 @@ func TestIdentityStore_GroupsCRUD_ByID(t *testing.T) {
 			"testkey1": "testvalue1",
 			"testkey2": "testvalue2",
 		},
+		"type":  "internal",
+		"alias": map[string]interface{}{},
 	}
 	expectedData["id"] = resp.Data["id"]
 	expectedData["name"] = resp.Data["name"]
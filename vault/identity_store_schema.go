@@ -15,6 +15,7 @@ func identityStoreSchema() *memdb.DBSchema {
 		entityTableSchema,
 		aliasesTableSchema,
 		groupTableSchema,
+		groupAliasesTableSchema,
 	}
 
 	for _, schemaFunc := range schemas {
@@ -43,7 +44,7 @@ func aliasesTableSchema() *memdb.TableSchema {
 				Name:   "entity_id",
 				Unique: false,
 				Indexer: &memdb.StringFieldIndex{
-					Field: "EntityID",
+					Field: "CanonicalID",
 				},
 			},
 			"mount_type": &memdb.IndexSchema{
@@ -178,3 +179,39 @@ func groupTableSchema() *memdb.TableSchema {
 		},
 	}
 }
+
+func groupAliasesTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: "group_aliases",
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:   "id",
+				Unique: true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "ID",
+				},
+			},
+			"canonical_id": &memdb.IndexSchema{
+				Name:   "canonical_id",
+				Unique: true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "CanonicalID",
+				},
+			},
+			"factors": &memdb.IndexSchema{
+				Name:   "factors",
+				Unique: true,
+				Indexer: &memdb.CompoundIndex{
+					Indexes: []memdb.Indexer{
+						&memdb.StringFieldIndex{
+							Field: "MountAccessor",
+						},
+						&memdb.StringFieldIndex{
+							Field: "Name",
+						},
+					},
+				},
+			},
+		},
+	}
+}
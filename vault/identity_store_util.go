@@ -11,8 +11,10 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/storagepacker"
 	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
 )
 
 // parseMetadata takes in a slice of string and parses each item as a key value pair separated by an '=' sign.
@@ -39,14 +41,29 @@ func (c *Core) loadIdentityStoreArtifacts() error {
 		return fmt.Errorf("identity store is not setup")
 	}
 
-	err = c.identityStore.loadEntities()
-	if err != nil {
-		return err
+	// Every namespace has its own identity store
+	identityStores := []*IdentityStore{c.identityStore}
+	c.namespacesLock.RLock()
+	for _, ns := range c.namespacesByID {
+		if ns.ID == namespace.RootNamespaceID {
+			continue
+		}
+		if is := c.namespaceIdentityStore(ns); is != nil {
+			identityStores = append(identityStores, is)
+		}
 	}
+	c.namespacesLock.RUnlock()
 
-	err = c.identityStore.loadGroups()
-	if err != nil {
-		return err
+	for _, is := range identityStores {
+		err = is.loadEntities()
+		if err != nil {
+			return err
+		}
+
+		err = is.loadGroups()
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -258,8 +275,8 @@ func (i *IdentityStore) upsertEntityInTxn(txn *memdb.Txn, entity *identity.Entit
 			return err
 		}
 
-		if aliasByFactors != nil && aliasByFactors.EntityID != entity.ID {
-			return fmt.Errorf("alias %q in already tied to a different entity %q", alias.ID, aliasByFactors.EntityID)
+		if aliasByFactors != nil && aliasByFactors.CanonicalID != entity.ID {
+			return fmt.Errorf("alias %q in already tied to a different entity %q", alias.ID, aliasByFactors.CanonicalID)
 		}
 
 		// Insert or update alias in MemDB using the transaction created above
@@ -1033,7 +1050,7 @@ func (i *IdentityStore) memDBEntityByAliasIDInTxn(txn *memdb.Txn, aliasID string
 		return nil, nil
 	}
 
-	return i.memDBEntityByIDInTxn(txn, alias.EntityID, clone)
+	return i.memDBEntityByIDInTxn(txn, alias.CanonicalID, clone)
 }
 
 func (i *IdentityStore) memDBEntityByAliasID(aliasID string, clone bool) (*identity.Entity, error) {
@@ -1111,7 +1128,7 @@ func (i *IdentityStore) sanitizeAlias(alias *identity.Alias) error {
 	}
 
 	// Alias must always be tied to an entity
-	if alias.EntityID == "" {
+	if alias.CanonicalID == "" {
 		return fmt.Errorf("missing entity ID")
 	}
 
@@ -1584,6 +1601,15 @@ func (i *IdentityStore) memDBUpsertGroupInTxn(txn *memdb.Txn, group *identity.Gr
 	}
 
 	if groupRaw != nil {
+		// The alias of the group may have been removed or replaced; drop the
+		// index of the previous one
+		if prevGroup, ok := groupRaw.(*identity.Group); ok && prevGroup.Alias != nil {
+			err = i.memDBDeleteGroupAliasByIDInTxn(txn, prevGroup.Alias.ID)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = txn.Delete("groups", groupRaw)
 		if err != nil {
 			return fmt.Errorf("failed to delete group from memdb: %v", err)
@@ -1594,6 +1620,13 @@ func (i *IdentityStore) memDBUpsertGroupInTxn(txn *memdb.Txn, group *identity.Gr
 		return fmt.Errorf("failed to update group into memdb: %v", err)
 	}
 
+	if group.Alias != nil {
+		err = i.memDBUpsertGroupAliasInTxn(txn, group.Alias)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1659,6 +1692,13 @@ func (i *IdentityStore) memDBDeleteGroupByIDInTxn(txn *memdb.Txn, groupID string
 		return nil
 	}
 
+	if group.Alias != nil {
+		err = i.memDBDeleteGroupAliasByIDInTxn(txn, group.Alias.ID)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = txn.Delete("groups", group)
 	if err != nil {
 		return fmt.Errorf("failed to delete group from memdb: %v", err)
@@ -1730,6 +1770,13 @@ func (i *IdentityStore) memDBDeleteGroupByNameInTxn(txn *memdb.Txn, groupName st
 		return nil
 	}
 
+	if group.Alias != nil {
+		err = i.memDBDeleteGroupAliasByIDInTxn(txn, group.Alias.ID)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = txn.Delete("groups", group)
 	if err != nil {
 		return fmt.Errorf("failed to delete group from memdb: %v", err)
@@ -2120,3 +2167,238 @@ func (i *IdentityStore) memDBGroupsByBucketEntryKeyHashInTxn(txn *memdb.Txn, has
 
 	return groups, nil
 }
+
+func (i *IdentityStore) memDBUpsertGroupAliasInTxn(txn *memdb.Txn, alias *identity.Alias) error {
+	if txn == nil {
+		return fmt.Errorf("nil txn")
+	}
+
+	if alias == nil {
+		return fmt.Errorf("group alias is nil")
+	}
+
+	aliasRaw, err := txn.First("group_aliases", "id", alias.ID)
+	if err != nil {
+		return fmt.Errorf("failed to lookup group alias from memdb using alias ID: %v", err)
+	}
+
+	if aliasRaw != nil {
+		err = txn.Delete("group_aliases", aliasRaw)
+		if err != nil {
+			return fmt.Errorf("failed to delete group alias from memdb: %v", err)
+		}
+	}
+
+	if err := txn.Insert("group_aliases", alias); err != nil {
+		return fmt.Errorf("failed to update group alias into memdb: %v", err)
+	}
+
+	return nil
+}
+
+func (i *IdentityStore) memDBDeleteGroupAliasByIDInTxn(txn *memdb.Txn, aliasID string) error {
+	if aliasID == "" {
+		return nil
+	}
+
+	if txn == nil {
+		return fmt.Errorf("txn is nil")
+	}
+
+	alias, err := i.memDBGroupAliasByIDInTxn(txn, aliasID, false)
+	if err != nil {
+		return err
+	}
+
+	if alias == nil {
+		return nil
+	}
+
+	err = txn.Delete("group_aliases", alias)
+	if err != nil {
+		return fmt.Errorf("failed to delete group alias from memdb: %v", err)
+	}
+
+	return nil
+}
+
+func (i *IdentityStore) memDBGroupAliasByIDInTxn(txn *memdb.Txn, aliasID string, clone bool) (*identity.Alias, error) {
+	if aliasID == "" {
+		return nil, fmt.Errorf("missing group alias ID")
+	}
+
+	if txn == nil {
+		return nil, fmt.Errorf("txn is nil")
+	}
+
+	aliasRaw, err := txn.First("group_aliases", "id", aliasID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group alias from memdb using alias ID: %v", err)
+	}
+
+	if aliasRaw == nil {
+		return nil, nil
+	}
+
+	alias, ok := aliasRaw.(*identity.Alias)
+	if !ok {
+		return nil, fmt.Errorf("failed to declare the type of fetched group alias")
+	}
+
+	if clone {
+		return alias.Clone()
+	}
+
+	return alias, nil
+}
+
+func (i *IdentityStore) memDBGroupAliasByID(aliasID string, clone bool) (*identity.Alias, error) {
+	if aliasID == "" {
+		return nil, fmt.Errorf("missing group alias ID")
+	}
+
+	txn := i.db.Txn(false)
+
+	return i.memDBGroupAliasByIDInTxn(txn, aliasID, clone)
+}
+
+func (i *IdentityStore) memDBGroupAliasByFactorsInTxn(txn *memdb.Txn, mountAccessor, aliasName string, clone bool) (*identity.Alias, error) {
+	if aliasName == "" {
+		return nil, fmt.Errorf("missing group alias name")
+	}
+
+	if mountAccessor == "" {
+		return nil, fmt.Errorf("missing mount accessor")
+	}
+
+	if txn == nil {
+		return nil, fmt.Errorf("txn is nil")
+	}
+
+	aliasRaw, err := txn.First("group_aliases", "factors", mountAccessor, aliasName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group alias from memdb using factors: %v", err)
+	}
+
+	if aliasRaw == nil {
+		return nil, nil
+	}
+
+	alias, ok := aliasRaw.(*identity.Alias)
+	if !ok {
+		return nil, fmt.Errorf("failed to declare the type of fetched group alias")
+	}
+
+	if clone {
+		return alias.Clone()
+	}
+
+	return alias, nil
+}
+
+func (i *IdentityStore) memDBGroupAliasByFactors(mountAccessor, aliasName string, clone bool) (*identity.Alias, error) {
+	txn := i.db.Txn(false)
+
+	return i.memDBGroupAliasByFactorsInTxn(txn, mountAccessor, aliasName, clone)
+}
+
+func (i *IdentityStore) memDBGroupAliases(ws memdb.WatchSet) (memdb.ResultIterator, error) {
+	txn := i.db.Txn(false)
+
+	iter, err := txn.Get("group_aliases", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws.Add(iter.WatchCh())
+
+	return iter, nil
+}
+
+// refreshExternalGroupMembershipsByEntityID updates the memberships of the
+// entity in the external groups whose aliases belong to the given mount. The
+// entity is added to the groups whose aliases are given and removed from the
+// other external groups of the mount.
+func (i *IdentityStore) refreshExternalGroupMembershipsByEntityID(entityID, mountAccessor string, groupAliases []*logical.Alias) error {
+	if entityID == "" {
+		return fmt.Errorf("empty entity ID")
+	}
+
+	if mountAccessor == "" {
+		return fmt.Errorf("missing mount accessor")
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	txn := i.db.Txn(true)
+	defer txn.Abort()
+
+	memberOf := make(map[string]bool)
+	for _, groupAlias := range groupAliases {
+		if groupAlias == nil || groupAlias.Name == "" {
+			continue
+		}
+
+		alias, err := i.memDBGroupAliasByFactorsInTxn(txn, mountAccessor, groupAlias.Name, false)
+		if err != nil {
+			return err
+		}
+
+		// Groups of the authentication source that are not mapped to an
+		// external group are of no interest
+		if alias == nil {
+			continue
+		}
+
+		group, err := i.memDBGroupByIDInTxn(txn, alias.CanonicalID, true)
+		if err != nil {
+			return err
+		}
+		if group == nil {
+			continue
+		}
+
+		memberOf[group.ID] = true
+		if strutil.StrListContains(group.MemberEntityIDs, entityID) {
+			continue
+		}
+
+		group.MemberEntityIDs = append(group.MemberEntityIDs, entityID)
+		err = i.upsertGroupInTxn(txn, group, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	groupsIter, err := txn.Get("groups", "member_entity_ids", entityID)
+	if err != nil {
+		return fmt.Errorf("failed to lookup groups using entity ID: %v", err)
+	}
+
+	var staleGroups []*identity.Group
+	for raw := groupsIter.Next(); raw != nil; raw = groupsIter.Next() {
+		group := raw.(*identity.Group)
+		if group.Type != groupTypeExternal || group.Alias == nil || group.Alias.MountAccessor != mountAccessor || memberOf[group.ID] {
+			continue
+		}
+		staleGroups = append(staleGroups, group)
+	}
+
+	for _, group := range staleGroups {
+		group, err = group.Clone()
+		if err != nil {
+			return err
+		}
+
+		group.MemberEntityIDs = strutil.StrListDelete(group.MemberEntityIDs, entityID)
+		err = i.upsertGroupInTxn(txn, group, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+
+	return nil
+}
@@ -38,8 +38,17 @@ func (c *Core) Initialized() (bool, error) {
 		return false, nil
 	}
 
-	// Verify the seal configuration
-	sealConf, err := c.seal.BarrierConfig()
+	// Verify the seal configuration, which belongs to the seal being
+	// migrated from until a seal migration is complete
+	seal := c.seal
+	migrating, err := c.sealMigrationPending()
+	if err != nil {
+		return false, err
+	}
+	if migrating {
+		seal = c.migrationSeal
+	}
+	sealConf, err := seal.BarrierConfig()
 	if err != nil {
 		return false, err
 	}
@@ -257,6 +266,12 @@ func (c *Core) UnsealWithStoredKeys() error {
 		return nil
 	}
 
+	// The stored keys are only available once a seal migration is complete
+	if c.IsInSealMigration() {
+		c.logger.Info("core: seal migration in progress, not attempting auto-unseal")
+		return nil
+	}
+
 	sealed, err := c.Sealed()
 	if err != nil {
 		c.logger.Error("core: error checking sealed status in auto-unseal", "error", err)
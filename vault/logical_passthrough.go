@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -26,6 +27,10 @@ func LeasedPassthroughBackendFactory(conf *logical.BackendConfig) (logical.Backe
 // LeaseSwitchedPassthroughBackend returns a PassthroughBackend
 // with leases switched on or off
 func LeaseSwitchedPassthroughBackend(conf *logical.BackendConfig, leases bool) (logical.Backend, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("Configuation passed into backend is nil")
+	}
+
 	var b PassthroughBackend
 	b.generateLeases = leases
 	b.Backend = &framework.Backend{
@@ -36,8 +41,11 @@ func LeaseSwitchedPassthroughBackend(conf *logical.BackendConfig, leases bool) (
 				"/",
 			},
 		},
+	}
 
-		Paths: []*framework.Path{
+	switch conf.Config["version"] {
+	case "", "1":
+		b.Backend.Paths = []*framework.Path{
 			&framework.Path{
 				Pattern: ".*",
 
@@ -54,21 +62,34 @@ func LeaseSwitchedPassthroughBackend(conf *logical.BackendConfig, leases bool) (
 				HelpSynopsis:    strings.TrimSpace(passthroughHelpSynopsis),
 				HelpDescription: strings.TrimSpace(passthroughHelpDescription),
 			},
-		},
+		}
+
+	case "2":
+		b.versioned = true
+		b.storage = conf.StorageView
+		b.locks = locksutil.CreateLocks()
+		b.Backend.Help = strings.TrimSpace(passthroughHelp + versionedHelp)
+		b.Backend.Paths = versionedPaths(&b)
+		b.Backend.Init = b.upgradeToVersioned
+
+	default:
+		return nil, fmt.Errorf("unsupported kv version %q", conf.Config["version"])
+	}
+
+	renew := b.handleRead
+	if b.versioned {
+		renew = b.handleVersionedRenew
 	}
 
 	b.Backend.Secrets = []*framework.Secret{
 		&framework.Secret{
 			Type: "kv",
 
-			Renew:  b.handleRead,
+			Renew:  renew,
 			Revoke: b.handleRevoke,
 		},
 	}
 
-	if conf == nil {
-		return nil, fmt.Errorf("Configuation passed into backend is nil")
-	}
 	b.Backend.Setup(conf)
 
 	return &b, nil
@@ -81,6 +102,12 @@ func LeaseSwitchedPassthroughBackend(conf *logical.BackendConfig, leases bool) (
 type PassthroughBackend struct {
 	*framework.Backend
 	generateLeases bool
+
+	// versioned is set when the mount was created or tuned with version=2,
+	// in which case every write creates a new version of the secret
+	versioned bool
+	storage   logical.Storage
+	locks     []*locksutil.LockEntry
 }
 
 func (b *PassthroughBackend) handleRevoke(
@@ -119,6 +146,12 @@ func (b *PassthroughBackend) handleRead(
 		return nil, fmt.Errorf("json decoding failed: %v", err)
 	}
 
+	return b.secretResponse(rawData), nil
+}
+
+// secretResponse builds the response for a read of the given secret data,
+// honoring any TTL hint stored alongside it
+func (b *PassthroughBackend) secretResponse(rawData map[string]interface{}) *logical.Response {
 	var resp *logical.Response
 	if b.generateLeases {
 		// Generate the response
@@ -150,7 +183,7 @@ func (b *PassthroughBackend) handleRead(
 
 	resp.Secret.TTL = ttlDuration
 
-	return resp, nil
+	return resp
 }
 
 func (b *PassthroughBackend) GeneratesLeases() bool {
@@ -3,11 +3,14 @@ package vault
 import (
 	"encoding/json"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/vault/helper/logformat"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/logical"
+	log "github.com/mgutz/logxi/v1"
 )
 
 func TestPassthroughBackend_RootPaths(t *testing.T) {
@@ -235,3 +238,285 @@ func testPassthroughLeasedBackend() logical.Backend {
 	})
 	return b
 }
+
+func TestPassthroughBackend_Versioned(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	b := testPassthroughVersionedBackend(t, storage)
+
+	write := func(data map[string]interface{}, options map[string]interface{}) *logical.Response {
+		req := logical.TestRequest(t, logical.UpdateOperation, "data/foo")
+		req.Storage = storage
+		req.Data["data"] = data
+		if options != nil {
+			req.Data["options"] = options
+		}
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+	read := func(version string) *logical.Response {
+		req := logical.TestRequest(t, logical.ReadOperation, "data/foo")
+		req.Storage = storage
+		if version != "" {
+			req.Data["version"] = version
+		}
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp
+	}
+
+	resp := write(map[string]interface{}{"raw": "v1"}, nil)
+	if resp.Data["version"] != uint64(1) {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = write(map[string]interface{}{"raw": "v2"}, nil)
+	if resp.Data["version"] != uint64(2) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp = read("")
+	if resp.Data["data"].(map[string]interface{})["raw"] != "v2" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = read("1")
+	if resp.Data["data"].(map[string]interface{})["raw"] != "v1" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// A mismatched check-and-set is refused
+	resp = write(map[string]interface{}{"raw": "v3"}, map[string]interface{}{"cas": 1})
+	if !resp.IsError() {
+		t.Fatalf("expected cas error: %#v", resp)
+	}
+	resp = write(map[string]interface{}{"raw": "v3"}, map[string]interface{}{"cas": 2})
+	if resp.IsError() || resp.Data["version"] != uint64(3) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Soft-delete and restore version 1
+	for _, op := range []string{"delete", "undelete"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, op+"/foo")
+		req.Storage = storage
+		req.Data["versions"] = "1"
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		resp = read("1")
+		deleted := resp.Data["data"] == nil
+		if deleted != (op == "delete") {
+			t.Fatalf("bad after %s: %#v", op, resp)
+		}
+	}
+
+	// Destroyed versions can't be restored
+	for _, op := range []string{"destroy", "undelete"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, op+"/foo")
+		req.Storage = storage
+		req.Data["versions"] = "2"
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	resp = read("2")
+	if resp.Data["data"] != nil || !resp.Data["metadata"].(map[string]interface{})["destroyed"].(bool) {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if entry, _ := storage.Get(versionedDataKey("foo", 2)); entry != nil {
+		t.Fatalf("destroyed version still in storage")
+	}
+
+	// Deleting the data path soft-deletes the current version
+	req := logical.TestRequest(t, logical.DeleteOperation, "data/foo")
+	req.Storage = storage
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp = read("")
+	if resp.Data["data"] != nil || resp.Data["metadata"].(map[string]interface{})["deletion_time"] == "" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	req = logical.TestRequest(t, logical.ListOperation, "metadata/")
+	req.Storage = storage
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"foo"}) {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Removing the metadata removes every version
+	req = logical.TestRequest(t, logical.DeleteOperation, "metadata/foo")
+	req.Storage = storage
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp = read(""); resp != nil {
+		t.Fatalf("bad: %#v", resp)
+	}
+	for _, version := range []uint64{1, 3} {
+		if entry, _ := storage.Get(versionedDataKey("foo", version)); entry != nil {
+			t.Fatalf("version %d still in storage", version)
+		}
+	}
+}
+
+func TestPassthroughBackend_VersionedMaxVersions(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	b := testPassthroughVersionedBackend(t, storage)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "metadata/foo")
+	req.Storage = storage
+	req.Data["max_versions"] = 2
+	req.Data["cas_required"] = true
+	if _, err := b.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "data/foo")
+	req.Storage = storage
+	req.Data["data"] = map[string]interface{}{"raw": "test"}
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !resp.IsError() {
+		t.Fatalf("expected cas to be required: %#v", resp)
+	}
+
+	for i := 0; i < 3; i++ {
+		req.Data["options"] = map[string]interface{}{"cas": i}
+		resp, err := b.HandleRequest(req)
+		if err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "metadata/foo")
+	req.Storage = storage
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["current_version"] != uint64(3) || resp.Data["oldest_version"] != uint64(2) {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if len(resp.Data["versions"].(map[string]interface{})) != 2 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if entry, _ := storage.Get(versionedDataKey("foo", 1)); entry != nil {
+		t.Fatalf("trimmed version still in storage")
+	}
+}
+
+func TestPassthroughBackend_VersionedUpgrade(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	// Write some secrets with an unversioned backend
+	b := testPassthroughBackend()
+	for _, path := range []string{"foo", "bar/baz"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.Storage = storage
+		req.Data["raw"] = path
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	b = testPassthroughVersionedBackend(t, storage)
+	for _, path := range []string{"foo", "bar/baz"} {
+		req := logical.TestRequest(t, logical.ReadOperation, "data/"+path)
+		req.Storage = storage
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp.Data["data"].(map[string]interface{})["raw"] != path {
+			t.Fatalf("bad: %#v", resp)
+		}
+		if resp.Data["metadata"].(map[string]interface{})["version"] != uint64(1) {
+			t.Fatalf("bad: %#v", resp)
+		}
+
+		if entry, _ := storage.Get(path); entry != nil {
+			t.Fatalf("legacy entry %q still in storage", path)
+		}
+	}
+
+	// Running the upgrade again must not create new versions
+	if err := b.Initialize(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	meta, err := b.(*PassthroughBackend).keyMetadata(storage, "foo")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if meta.CurrentVersion != 1 {
+		t.Fatalf("bad: %#v", meta)
+	}
+}
+
+func TestPassthroughBackend_VersionedUpgradeReservedPrefix(t *testing.T) {
+	storage := &logical.InmemStorage{}
+
+	b := testPassthroughBackend()
+	for _, path := range []string{"foo", versionedStoragePrefix + "bar"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.Storage = storage
+		req.Data["raw"] = path
+		if _, err := b.HandleRequest(req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	vb, err := PassthroughBackendFactory(&logical.BackendConfig{
+		Logger:      logformat.NewVaultLogger(log.LevelTrace),
+		StorageView: storage,
+		Config: map[string]string{
+			"version": "2",
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := vb.Initialize(); err == nil {
+		t.Fatal("expected the upgrade to be refused")
+	}
+
+	// Nothing was touched, so the mount can still be used unversioned
+	keys, err := logical.CollectKeys(storage)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"foo", versionedStoragePrefix + "bar"}) {
+		t.Fatalf("bad: %v", keys)
+	}
+}
+
+func testPassthroughVersionedBackend(t *testing.T, storage logical.Storage) logical.Backend {
+	b, err := PassthroughBackendFactory(&logical.BackendConfig{
+		Logger:      logformat.NewVaultLogger(log.LevelTrace),
+		StorageView: storage,
+		Config: map[string]string{
+			"version": "2",
+		},
+		System: logical.StaticSystemView{
+			DefaultLeaseTTLVal: time.Hour * 24,
+			MaxLeaseTTLVal:     time.Hour * 24 * 32,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := b.Initialize(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return b
+}
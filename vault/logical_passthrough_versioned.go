@@ -0,0 +1,977 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// All of the storage used by a versioned kv mount lives under this
+	// prefix, so that the upgrade from an unversioned mount can tell it apart
+	// from the legacy entries that still need to be converted. The upgrade
+	// refuses to run while legacy entries exist under it.
+	versionedStoragePrefix  = "versioned/"
+	versionedMetadataPrefix = versionedStoragePrefix + "metadata/"
+	versionedDataPrefix     = versionedStoragePrefix + "data/"
+	versionedConfigPath     = versionedStoragePrefix + "config"
+	versionedUpgradePath    = versionedStoragePrefix + "upgraded"
+	versionedUpgradingPath  = versionedStoragePrefix + "upgrading"
+
+	// defaultMaxVersions is the number of versions kept for a key when
+	// neither the key nor the mount configures max_versions
+	defaultMaxVersions = 10
+)
+
+// versionedConfig holds the mount-wide settings of a versioned kv mount
+type versionedConfig struct {
+	MaxVersions uint32 `json:"max_versions"`
+	CASRequired bool   `json:"cas_required"`
+}
+
+// versionedKeyMetadata tracks every version that has been written to a key
+type versionedKeyMetadata struct {
+	Key            string                               `json:"key"`
+	Versions       map[uint64]*versionedVersionMetadata `json:"versions"`
+	CurrentVersion uint64                               `json:"current_version"`
+	OldestVersion  uint64                               `json:"oldest_version"`
+	MaxVersions    uint32                               `json:"max_versions"`
+	CASRequired    bool                                 `json:"cas_required"`
+	CreatedTime    time.Time                            `json:"created_time"`
+	UpdatedTime    time.Time                            `json:"updated_time"`
+}
+
+// versionedVersionMetadata describes a single version of a key
+type versionedVersionMetadata struct {
+	CreatedTime  time.Time `json:"created_time"`
+	DeletionTime time.Time `json:"deletion_time"`
+	Destroyed    bool      `json:"destroyed"`
+}
+
+func (v *versionedVersionMetadata) responseData(version uint64) map[string]interface{} {
+	var deletionTime string
+	if !v.DeletionTime.IsZero() {
+		deletionTime = v.DeletionTime.Format(time.RFC3339Nano)
+	}
+
+	return map[string]interface{}{
+		"version":       version,
+		"created_time":  v.CreatedTime.Format(time.RFC3339Nano),
+		"deletion_time": deletionTime,
+		"destroyed":     v.Destroyed,
+	}
+}
+
+func versionedPaths(b *PassthroughBackend) []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "config$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"max_versions": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "The number of versions to keep for each key. Defaults to 10.",
+				},
+				"cas_required": &framework.FieldSchema{
+					Type:        framework.TypeBool,
+					Description: "If true, all keys will require the cas parameter to be set on all write requests.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleVersionedConfigRead,
+				logical.UpdateOperation: b.handleVersionedConfigWrite,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(versionedConfigHelpSynopsis),
+			HelpDescription: strings.TrimSpace(versionedConfigHelpDescription),
+		},
+
+		&framework.Path{
+			Pattern: "data/(?P<path>.+)",
+
+			Fields: map[string]*framework.FieldSchema{
+				"path": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Location of the secret.",
+				},
+				"version": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "If provided during a read, the value at the version number will be returned.",
+				},
+				"data": &framework.FieldSchema{
+					Type:        framework.TypeMap,
+					Description: "The contents of the data map will be stored and returned on read.",
+				},
+				"options": &framework.FieldSchema{
+					Type: framework.TypeMap,
+					Description: `Options for writing a secret. "cas" is the check-and-set version;
+if set to 0 the write is only allowed if the key doesn't exist, otherwise
+the write is only allowed if the key's current version matches.`,
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleVersionedRead,
+				logical.CreateOperation: b.handleVersionedWrite,
+				logical.UpdateOperation: b.handleVersionedWrite,
+				logical.DeleteOperation: b.handleVersionedDeleteLatest,
+			},
+
+			ExistenceCheck: b.handleVersionedExistenceCheck,
+
+			HelpSynopsis:    strings.TrimSpace(versionedDataHelpSynopsis),
+			HelpDescription: strings.TrimSpace(versionedDataHelpDescription),
+		},
+
+		&framework.Path{
+			Pattern: "metadata/(?P<path>.*)",
+
+			Fields: map[string]*framework.FieldSchema{
+				"path": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Location of the secret.",
+				},
+				"max_versions": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "The number of versions to keep. If not set, the mount's configured value is used.",
+				},
+				"cas_required": &framework.FieldSchema{
+					Type:        framework.TypeBool,
+					Description: "If true, the key will require the cas parameter to be set on all write requests.",
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleVersionedMetadataRead,
+				logical.UpdateOperation: b.handleVersionedMetadataWrite,
+				logical.DeleteOperation: b.handleVersionedMetadataDelete,
+				logical.ListOperation:   b.handleVersionedMetadataList,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(versionedMetadataHelpSynopsis),
+			HelpDescription: strings.TrimSpace(versionedMetadataHelpDescription),
+		},
+
+		versionedVersionsPath("delete", b.handleVersionedDelete, versionedDeleteHelpSynopsis, versionedDeleteHelpDescription),
+		versionedVersionsPath("undelete", b.handleVersionedUndelete, versionedUndeleteHelpSynopsis, versionedUndeleteHelpDescription),
+		versionedVersionsPath("destroy", b.handleVersionedDestroy, versionedDestroyHelpSynopsis, versionedDestroyHelpDescription),
+	}
+}
+
+// versionedVersionsPath returns a path that operates on a list of versions
+// of a key, e.g. delete/<path>
+func versionedVersionsPath(prefix string, callback framework.OperationFunc, synopsis, description string) *framework.Path {
+	return &framework.Path{
+		Pattern: prefix + "/(?P<path>.+)",
+
+		Fields: map[string]*framework.FieldSchema{
+			"path": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Location of the secret.",
+			},
+			"versions": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "The versions to operate on.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: callback,
+		},
+
+		HelpSynopsis:    strings.TrimSpace(synopsis),
+		HelpDescription: strings.TrimSpace(description),
+	}
+}
+
+// versionedDataKey returns the storage key holding the given version of a
+// key. The key is hashed so that versions of nested keys don't end up
+// interleaved with each other in storage.
+func versionedDataKey(key string, version uint64) string {
+	sum := sha256.Sum256([]byte(key))
+	return versionedDataPrefix + hex.EncodeToString(sum[:]) + "/" + strconv.FormatUint(version, 10)
+}
+
+func (b *PassthroughBackend) lockForKey(key string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.locks, key)
+}
+
+func (b *PassthroughBackend) versionedConfig(s logical.Storage) (*versionedConfig, error) {
+	config := &versionedConfig{}
+	entry, err := s.Get(versionedConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, fmt.Errorf("json decoding failed: %v", err)
+	}
+
+	return config, nil
+}
+
+func (b *PassthroughBackend) keyMetadata(s logical.Storage, key string) (*versionedKeyMetadata, error) {
+	entry, err := s.Get(versionedMetadataPrefix + key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var meta versionedKeyMetadata
+	if err := entry.DecodeJSON(&meta); err != nil {
+		return nil, fmt.Errorf("json decoding failed: %v", err)
+	}
+	if meta.Versions == nil {
+		meta.Versions = make(map[uint64]*versionedVersionMetadata)
+	}
+
+	return &meta, nil
+}
+
+func (b *PassthroughBackend) putKeyMetadata(s logical.Storage, meta *versionedKeyMetadata) error {
+	entry, err := logical.StorageEntryJSON(versionedMetadataPrefix+meta.Key, meta)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(entry)
+}
+
+// maxVersions returns the number of versions to retain for the key, with
+// the key's own setting taking precedence over the mount's
+func (b *PassthroughBackend) maxVersions(config *versionedConfig, meta *versionedKeyMetadata) uint32 {
+	switch {
+	case meta.MaxVersions > 0:
+		return meta.MaxVersions
+	case config.MaxVersions > 0:
+		return config.MaxVersions
+	default:
+		return defaultMaxVersions
+	}
+}
+
+// trimVersions drops versions that exceed the retention limit from the
+// metadata, returning the storage keys of the data that should be removed
+// once the metadata has been persisted
+func (b *PassthroughBackend) trimVersions(config *versionedConfig, meta *versionedKeyMetadata) []string {
+	var toDelete []string
+	max := uint64(b.maxVersions(config, meta))
+	for meta.OldestVersion > 0 && meta.CurrentVersion-meta.OldestVersion >= max {
+		if _, ok := meta.Versions[meta.OldestVersion]; ok {
+			delete(meta.Versions, meta.OldestVersion)
+			toDelete = append(toDelete, versionedDataKey(meta.Key, meta.OldestVersion))
+		}
+		meta.OldestVersion++
+	}
+
+	return toDelete
+}
+
+// parseVersions converts the versions field into version numbers
+func parseVersions(data *framework.FieldData) ([]uint64, error) {
+	raw := data.Get("versions").([]string)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no versions provided")
+	}
+
+	versions := make([]uint64, 0, len(raw))
+	for _, v := range raw {
+		version, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+func (b *PassthroughBackend) handleVersionedConfigRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.versionedConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"max_versions": config.MaxVersions,
+			"cas_required": config.CASRequired,
+		},
+	}, nil
+}
+
+func (b *PassthroughBackend) handleVersionedConfigWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.versionedConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxRaw, ok := data.GetOk("max_versions"); ok {
+		if maxRaw.(int) < 0 {
+			return logical.ErrorResponse("max_versions cannot be negative"), nil
+		}
+		config.MaxVersions = uint32(maxRaw.(int))
+	}
+	if casRaw, ok := data.GetOk("cas_required"); ok {
+		config.CASRequired = casRaw.(bool)
+	}
+
+	entry, err := logical.StorageEntryJSON(versionedConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(entry); err != nil {
+		return nil, fmt.Errorf("failed to write: %v", err)
+	}
+
+	return nil, nil
+}
+
+func (b *PassthroughBackend) handleVersionedExistenceCheck(
+	req *logical.Request, data *framework.FieldData) (bool, error) {
+	meta, err := b.keyMetadata(req.Storage, data.Get("path").(string))
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %v", err)
+	}
+
+	return meta != nil, nil
+}
+
+func (b *PassthroughBackend) handleVersionedRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.readVersion(req.Storage, data.Get("path").(string), uint64(data.Get("version").(int)))
+}
+
+// handleVersionedRenew re-reads the current version of the secret a lease
+// was issued for
+func (b *PassthroughBackend) handleVersionedRenew(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.readVersion(req.Storage, strings.TrimPrefix(req.Path, "data/"), 0)
+}
+
+// readVersion returns the given version of the key, or the current version
+// if version is zero
+func (b *PassthroughBackend) readVersion(s logical.Storage, key string, version uint64) (*logical.Response, error) {
+	lock := b.lockForKey(key)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	meta, err := b.keyMetadata(s, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	if version == 0 {
+		version = meta.CurrentVersion
+	}
+	vm, ok := meta.Versions[version]
+	if !ok {
+		return nil, nil
+	}
+
+	// Deleted and destroyed versions only return their metadata
+	if vm.Destroyed || !vm.DeletionTime.IsZero() {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"data":     nil,
+				"metadata": vm.responseData(version),
+			},
+		}, nil
+	}
+
+	out, err := s.Get(versionedDataKey(key, version))
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	if out == nil {
+		return nil, fmt.Errorf("no data found for version %d of %q", version, key)
+	}
+
+	var rawData map[string]interface{}
+	if err := jsonutil.DecodeJSON(out.Value, &rawData); err != nil {
+		return nil, fmt.Errorf("json decoding failed: %v", err)
+	}
+
+	resp := b.secretResponse(rawData)
+	resp.Data = map[string]interface{}{
+		"data":     rawData,
+		"metadata": vm.responseData(version),
+	}
+
+	return resp, nil
+}
+
+func (b *PassthroughBackend) handleVersionedWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key := data.Get("path").(string)
+
+	secretData := data.Get("data").(map[string]interface{})
+	if len(secretData) == 0 {
+		return logical.ErrorResponse("missing data fields"), nil
+	}
+
+	var cas int
+	casRaw, casOK := data.Get("options").(map[string]interface{})["cas"]
+	if casOK {
+		if err := mapstructure.WeakDecode(casRaw, &cas); err != nil || cas < 0 {
+			return logical.ErrorResponse("invalid check-and-set parameter"), nil
+		}
+	}
+
+	config, err := b.versionedConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := b.lockForKey(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if meta == nil {
+		meta = &versionedKeyMetadata{
+			Key:         key,
+			Versions:    make(map[uint64]*versionedVersionMetadata),
+			CreatedTime: now,
+		}
+	}
+
+	switch {
+	case !casOK && (config.CASRequired || meta.CASRequired):
+		return logical.ErrorResponse("check-and-set parameter required for this call"), nil
+	case casOK && uint64(cas) != meta.CurrentVersion:
+		return logical.ErrorResponse("check-and-set parameter did not match the current version"), nil
+	}
+
+	buf, err := json.Marshal(secretData)
+	if err != nil {
+		return nil, fmt.Errorf("json encoding failed: %v", err)
+	}
+
+	version := meta.CurrentVersion + 1
+	if err := req.Storage.Put(&logical.StorageEntry{
+		Key:   versionedDataKey(key, version),
+		Value: buf,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write: %v", err)
+	}
+
+	vm := &versionedVersionMetadata{
+		CreatedTime: now,
+	}
+	meta.Versions[version] = vm
+	meta.CurrentVersion = version
+	meta.UpdatedTime = now
+	if meta.OldestVersion == 0 {
+		meta.OldestVersion = version
+	}
+	toDelete := b.trimVersions(config, meta)
+
+	if err := b.putKeyMetadata(req.Storage, meta); err != nil {
+		return nil, fmt.Errorf("failed to write: %v", err)
+	}
+
+	for _, dataKey := range toDelete {
+		if err := req.Storage.Delete(dataKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: vm.responseData(version),
+	}, nil
+}
+
+// handleVersionedDeleteLatest soft-deletes the current version of the key
+func (b *PassthroughBackend) handleVersionedDeleteLatest(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key := data.Get("path").(string)
+
+	lock := b.lockForKey(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	vm, ok := meta.Versions[meta.CurrentVersion]
+	if !ok || vm.Destroyed || !vm.DeletionTime.IsZero() {
+		return nil, nil
+	}
+	vm.DeletionTime = time.Now().UTC()
+
+	return nil, b.putKeyMetadata(req.Storage, meta)
+}
+
+// updateVersions applies update to each of the requested versions of the key
+// and persists the metadata. When update returns true the data of that
+// version is removed from storage.
+func (b *PassthroughBackend) updateVersions(
+	req *logical.Request, data *framework.FieldData,
+	update func(*versionedVersionMetadata) bool) (*logical.Response, error) {
+	key := data.Get("path").(string)
+	versions, err := parseVersions(data)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	lock := b.lockForKey(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	var toDelete []string
+	for _, version := range versions {
+		vm, ok := meta.Versions[version]
+		if !ok {
+			continue
+		}
+		if update(vm) {
+			toDelete = append(toDelete, versionedDataKey(key, version))
+		}
+	}
+
+	if err := b.putKeyMetadata(req.Storage, meta); err != nil {
+		return nil, fmt.Errorf("failed to write: %v", err)
+	}
+
+	for _, dataKey := range toDelete {
+		if err := req.Storage.Delete(dataKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *PassthroughBackend) handleVersionedDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	now := time.Now().UTC()
+	return b.updateVersions(req, data, func(vm *versionedVersionMetadata) bool {
+		if !vm.Destroyed && vm.DeletionTime.IsZero() {
+			vm.DeletionTime = now
+		}
+		return false
+	})
+}
+
+func (b *PassthroughBackend) handleVersionedUndelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.updateVersions(req, data, func(vm *versionedVersionMetadata) bool {
+		if !vm.Destroyed {
+			vm.DeletionTime = time.Time{}
+		}
+		return false
+	})
+}
+
+func (b *PassthroughBackend) handleVersionedDestroy(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.updateVersions(req, data, func(vm *versionedVersionMetadata) bool {
+		if vm.Destroyed {
+			return false
+		}
+		vm.Destroyed = true
+		return true
+	})
+}
+
+func (b *PassthroughBackend) handleVersionedMetadataRead(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key := data.Get("path").(string)
+
+	lock := b.lockForKey(key)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	versions := make(map[string]interface{}, len(meta.Versions))
+	for version, vm := range meta.Versions {
+		vData := vm.responseData(version)
+		delete(vData, "version")
+		versions[strconv.FormatUint(version, 10)] = vData
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"versions":        versions,
+			"current_version": meta.CurrentVersion,
+			"oldest_version":  meta.OldestVersion,
+			"max_versions":    meta.MaxVersions,
+			"cas_required":    meta.CASRequired,
+			"created_time":    meta.CreatedTime.Format(time.RFC3339Nano),
+			"updated_time":    meta.UpdatedTime.Format(time.RFC3339Nano),
+		},
+	}, nil
+}
+
+func (b *PassthroughBackend) handleVersionedMetadataWrite(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key := data.Get("path").(string)
+	if key == "" {
+		return logical.ErrorResponse("missing path"), nil
+	}
+
+	config, err := b.versionedConfig(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := b.lockForKey(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if meta == nil {
+		meta = &versionedKeyMetadata{
+			Key:         key,
+			Versions:    make(map[uint64]*versionedVersionMetadata),
+			CreatedTime: now,
+		}
+	}
+
+	if maxRaw, ok := data.GetOk("max_versions"); ok {
+		if maxRaw.(int) < 0 {
+			return logical.ErrorResponse("max_versions cannot be negative"), nil
+		}
+		meta.MaxVersions = uint32(maxRaw.(int))
+	}
+	if casRaw, ok := data.GetOk("cas_required"); ok {
+		meta.CASRequired = casRaw.(bool)
+	}
+	meta.UpdatedTime = now
+
+	// Lowering max_versions takes effect immediately
+	toDelete := b.trimVersions(config, meta)
+
+	if err := b.putKeyMetadata(req.Storage, meta); err != nil {
+		return nil, fmt.Errorf("failed to write: %v", err)
+	}
+
+	for _, dataKey := range toDelete {
+		if err := req.Storage.Delete(dataKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// handleVersionedMetadataDelete permanently removes every version of the key
+// along with its metadata
+func (b *PassthroughBackend) handleVersionedMetadataDelete(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	key := data.Get("path").(string)
+
+	lock := b.lockForKey(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := b.keyMetadata(req.Storage, key)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	for version := range meta.Versions {
+		if err := req.Storage.Delete(versionedDataKey(key, version)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Storage.Delete(versionedMetadataPrefix + key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *PassthroughBackend) handleVersionedMetadataList(
+	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	path := data.Get("path").(string)
+	if path != "" && !strings.HasSuffix(path, "/") {
+		path = path + "/"
+	}
+
+	keys, err := req.Storage.List(versionedMetadataPrefix + path)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(keys), nil
+}
+
+// upgradeToVersioned converts the entries of a mount that was previously
+// unversioned so that each existing secret becomes version 1 at the same
+// path. The legacy entries are only removed once every secret has been
+// converted, so a failed upgrade leaves the unversioned data intact and can
+// be run again.
+func (b *PassthroughBackend) upgradeToVersioned() error {
+	done, err := b.storage.Get(versionedUpgradePath)
+	if err != nil {
+		return err
+	}
+	if done != nil {
+		var status versionedUpgradeStatus
+		if err := done.DecodeJSON(&status); err != nil {
+			return err
+		}
+		if !status.LegacyRemoved {
+			// The upgrade is committed, so failing to clean up must not keep
+			// the mount from loading; it is retried on the next load
+			if err := b.removeLegacyEntries(&status); err != nil {
+				b.Logger().Warn("kv: failed to remove unversioned secrets", "error", err)
+			}
+		}
+		return nil
+	}
+
+	keys, err := logical.CollectKeys(b.storage)
+	if err != nil {
+		return err
+	}
+
+	upgrading, err := b.storage.Get(versionedUpgradingPath)
+	if err != nil {
+		return err
+	}
+	if upgrading == nil {
+		// Before the upgrade starts, everything under the versioned prefix is
+		// a secret written through the unversioned mount, which would clash
+		// with the storage of the versioned mount
+		for _, key := range keys {
+			if strings.HasPrefix(key, versionedStoragePrefix) {
+				return fmt.Errorf("cannot upgrade while secrets exist under %q; move them to another path first", versionedStoragePrefix)
+			}
+		}
+		if err := b.storage.Put(&logical.StorageEntry{
+			Key: versionedUpgradingPath,
+		}); err != nil {
+			return err
+		}
+	}
+
+	var legacy []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, versionedStoragePrefix) {
+			continue
+		}
+
+		if err := b.upgradeKey(key); err != nil {
+			return fmt.Errorf("failed to upgrade %q: %v", key, err)
+		}
+		legacy = append(legacy, key)
+	}
+
+	status := &versionedUpgradeStatus{
+		UpgradeTime: time.Now().UTC(),
+		Upgraded:    len(legacy),
+	}
+	if err := b.putUpgradeStatus(status); err != nil {
+		return err
+	}
+	if err := b.storage.Delete(versionedUpgradingPath); err != nil {
+		return err
+	}
+
+	if len(legacy) > 0 {
+		b.Logger().Info("kv: upgraded unversioned secrets", "count", len(legacy))
+	}
+
+	if err := b.removeLegacyEntries(status); err != nil {
+		b.Logger().Warn("kv: failed to remove unversioned secrets", "error", err)
+	}
+
+	return nil
+}
+
+// versionedUpgradeStatus is stored once the upgrade of an unversioned mount
+// is committed
+type versionedUpgradeStatus struct {
+	UpgradeTime   time.Time `json:"upgrade_time"`
+	Upgraded      int       `json:"upgraded"`
+	LegacyRemoved bool      `json:"legacy_removed"`
+}
+
+func (b *PassthroughBackend) putUpgradeStatus(status *versionedUpgradeStatus) error {
+	entry, err := logical.StorageEntryJSON(versionedUpgradePath, status)
+	if err != nil {
+		return err
+	}
+	return b.storage.Put(entry)
+}
+
+// removeLegacyEntries deletes the unversioned entries left behind by a
+// committed upgrade. A versioned mount only writes under the versioned
+// prefix, so any other entry is a legacy one.
+func (b *PassthroughBackend) removeLegacyEntries(status *versionedUpgradeStatus) error {
+	keys, err := logical.CollectKeys(b.storage)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, versionedStoragePrefix) {
+			continue
+		}
+		if err := b.storage.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	status.LegacyRemoved = true
+	return b.putUpgradeStatus(status)
+}
+
+// upgradeKey stores the legacy entry at key as version 1 of the secret. Until
+// the upgrade is committed the legacy entry is still the source of truth, so
+// anything written by a previous attempt is overwritten.
+func (b *PassthroughBackend) upgradeKey(key string) error {
+	legacy, err := b.storage.Get(key)
+	if err != nil {
+		return err
+	}
+	if legacy == nil {
+		return nil
+	}
+
+	if err := b.storage.Put(&logical.StorageEntry{
+		Key:   versionedDataKey(key, 1),
+		Value: legacy.Value,
+	}); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	return b.putKeyMetadata(b.storage, &versionedKeyMetadata{
+		Key: key,
+		Versions: map[uint64]*versionedVersionMetadata{
+			1: &versionedVersionMetadata{
+				CreatedTime: now,
+			},
+		},
+		CurrentVersion: 1,
+		OldestVersion:  1,
+		CreatedTime:    now,
+		UpdatedTime:    now,
+	})
+}
+
+const versionedHelp = `
+
+This mount is versioned: every write creates a new version of the secret,
+which is read and written under data/. The history of a secret, and the
+number of versions to keep, is managed under metadata/. Versions can be
+soft-deleted and restored with delete/ and undelete/, or permanently removed
+with destroy/.
+`
+
+const versionedConfigHelpSynopsis = `
+Configures settings for the versioned kv mount.
+`
+
+const versionedConfigHelpDescription = `
+The mount-wide settings are "max_versions", the number of versions kept for a
+key unless the key sets its own value, and "cas_required", which requires the
+check-and-set option on every write.
+`
+
+const versionedDataHelpSynopsis = `
+Write, read, and delete versioned secrets.
+`
+
+const versionedDataHelpDescription = `
+Writing to this path creates a new version of the secret from the contents of
+"data". If the "cas" option is given, the write only succeeds when it matches
+the current version of the secret; a value of 0 only allows the write if the
+secret doesn't exist yet.
+
+Reads return the current version unless "version" is given. Deleting this path
+soft-deletes the current version, which can be restored with undelete/.
+`
+
+const versionedMetadataHelpSynopsis = `
+Configures settings for, and lists the versions of, a versioned secret.
+`
+
+const versionedMetadataHelpDescription = `
+Reading this path returns the metadata of every version of the secret. Writes
+set the "max_versions" and "cas_required" options for the secret. Deleting
+this path permanently removes all versions of the secret and its metadata.
+`
+
+const versionedDeleteHelpSynopsis = `
+Marks one or more versions as deleted.
+`
+
+const versionedDeleteHelpDescription = `
+Soft-deletes the given versions of the secret. Their data is kept in storage
+and can be restored with undelete/.
+`
+
+const versionedUndeleteHelpSynopsis = `
+Restores one or more deleted versions.
+`
+
+const versionedUndeleteHelpDescription = `
+Clears the deletion time of the given versions of the secret, making them
+readable again. Destroyed versions cannot be restored.
+`
+
+const versionedDestroyHelpSynopsis = `
+Permanently removes one or more versions.
+`
+
+const versionedDestroyHelpDescription = `
+Permanently removes the data of the given versions of the secret. Their
+metadata is kept and marked as destroyed.
+`
@@ -16,10 +16,12 @@ import (
 	"github.com/fatih/structs"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/wrapping"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/physical/raft"
 	log "github.com/mgutz/logxi/v1"
 	"github.com/mitchellh/mapstructure"
 )
@@ -80,6 +82,7 @@ func NewSystemBackend(core *Core) *SystemBackend {
 				"leases/revoke-prefix/*",
 				"leases/revoke-force/*",
 				"leases/lookup/*",
+				"storage/raft/*",
 			},
 
 			Unauthenticated: []string{
@@ -272,6 +275,10 @@ func NewSystemBackend(core *Core) *SystemBackend {
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["auth_desc"][0]),
 					},
+					"options": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["tune_mount_options"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -312,6 +319,10 @@ func NewSystemBackend(core *Core) *SystemBackend {
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["mount_plugin_name"][0]),
 					},
+					"options": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["mount_options"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -953,6 +964,12 @@ func NewSystemBackend(core *Core) *SystemBackend {
 	}
 
 	b.Backend.Paths = append(b.Backend.Paths, replicationPaths(b)...)
+	b.Backend.Paths = append(b.Backend.Paths, b.namespacePaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.quotaPaths()...)
+
+	if _, ok := core.underlyingPhysical.(*raft.RaftBackend); ok {
+		b.Backend.Paths = append(b.Backend.Paths, b.raftStoragePaths()...)
+	}
 
 	if core.rawEnabled {
 		b.Backend.Paths = append(b.Backend.Paths, &framework.Path{
@@ -988,6 +1005,18 @@ type SystemBackend struct {
 	*framework.Backend
 	Core   *Core
 	logger log.Logger
+
+	// ns is the namespace the backend is mounted in, whose system storage
+	// the backend is given
+	ns *namespace.Namespace
+}
+
+// namespace returns the namespace the backend is mounted in
+func (b *SystemBackend) namespace() *namespace.Namespace {
+	if b.ns == nil {
+		return namespace.RootNamespace
+	}
+	return b.ns
 }
 
 // handleCORSRead returns the current CORS configuration
@@ -1044,10 +1073,12 @@ func (b *SystemBackend) invalidate(key string) {
 	*/
 	switch {
 	case strings.HasPrefix(key, policyACLSubPath):
+		// Keys are relative to the system storage of the namespace of the
+		// backend, which holds the policies of that namespace
 		b.Core.stateLock.RLock()
 		defer b.Core.stateLock.RUnlock()
 		if b.Core.policyStore != nil {
-			b.Core.policyStore.invalidate(strings.TrimPrefix(key, policyACLSubPath), PolicyTypeACL)
+			b.Core.policyStore.invalidate(b.namespace(), strings.TrimPrefix(key, policyACLSubPath), PolicyTypeACL)
 		}
 	case strings.HasPrefix(key, tokenSubPath):
 		b.Core.stateLock.RLock()
@@ -1229,7 +1260,8 @@ func (b *SystemBackend) handleCapabilities(req *logical.Request, d *framework.Fi
 	if token == "" {
 		token = req.ClientToken
 	}
-	capabilities, err := b.Core.Capabilities(token, d.Get("path").(string))
+	path := b.requestNamespace(req).Path + d.Get("path").(string)
+	capabilities, err := b.Core.Capabilities(token, path)
 	if err != nil {
 		return nil, err
 	}
@@ -1254,7 +1286,8 @@ func (b *SystemBackend) handleCapabilitiesAccessor(req *logical.Request, d *fram
 		return nil, err
 	}
 
-	capabilities, err := b.Core.Capabilities(aEntry.TokenID, d.Get("path").(string))
+	path := b.requestNamespace(req).Path + d.Get("path").(string)
+	capabilities, err := b.Core.Capabilities(aEntry.TokenID, path)
 	if err != nil {
 		return nil, err
 	}
@@ -1345,6 +1378,8 @@ func (b *SystemBackend) handleRekeyDeleteRecovery(
 // handleMountTable handles the "mounts" endpoint to provide the mount table
 func (b *SystemBackend) handleMountTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns := b.requestNamespace(req)
+
 	b.Core.mountsLock.RLock()
 	defer b.Core.mountsLock.RUnlock()
 
@@ -1353,6 +1388,10 @@ func (b *SystemBackend) handleMountTable(
 	}
 
 	for _, entry := range b.Core.mounts.Entries {
+		if entry.Namespace().ID != ns.ID {
+			continue
+		}
+
 		// Populate mount info
 		info := map[string]interface{}{
 			"type":        entry.Type,
@@ -1365,7 +1404,8 @@ func (b *SystemBackend) handleMountTable(
 				"plugin_name":       entry.Config.PluginName,
 				"seal_wrap":         entry.Config.SealWrap,
 			},
-			"local": entry.Local,
+			"options": entry.Options,
+			"local":   entry.Local,
 		}
 		resp.Data[entry.Path] = info
 	}
@@ -1388,9 +1428,15 @@ func (b *SystemBackend) handleMount(
 	logicalType := data.Get("type").(string)
 	description := data.Get("description").(string)
 	pluginName := data.Get("plugin_name").(string)
+	options := data.Get("options").(map[string]interface{})
 
 	path = sanitizeMountPath(path)
 
+	optionMap, err := parseMountOptions(options)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	var config MountConfig
 	var apiConfig APIMountConfig
 
@@ -1475,10 +1521,12 @@ func (b *SystemBackend) handleMount(
 	// Create the mount entry
 	me := &MountEntry{
 		Table:       mountTableType,
+		NamespaceID: b.requestNamespace(req).ID,
 		Path:        path,
 		Type:        logicalType,
 		Description: description,
 		Config:      config,
+		Options:     optionMap,
 		Local:       local,
 	}
 
@@ -1508,27 +1556,30 @@ func (b *SystemBackend) handleUnmount(
 	path := data.Get("path").(string)
 	path = sanitizeMountPath(path)
 
+	ns := b.requestNamespace(req)
+	fullPath := ns.Path + path
+
 	repState := b.Core.replicationState
-	entry := b.Core.router.MatchingMountEntry(path)
+	entry := b.Core.router.MatchingMountEntry(fullPath)
 	if entry != nil && !entry.Local && repState.HasState(consts.ReplicationPerformanceSecondary) {
 		return logical.ErrorResponse("cannot unmount a non-local mount on a replication secondary"), nil
 	}
 
 	// We return success when the mount does not exists to not expose if the
 	// mount existed or not
-	match := b.Core.router.MatchingMount(path)
-	if match == "" || path != match {
+	match := b.Core.router.MatchingMount(fullPath)
+	if match == "" || fullPath != match {
 		return nil, nil
 	}
 
-	_, prefix, found := b.Core.router.MatchingStoragePrefixByAPIPath(path)
+	_, prefix, found := b.Core.router.MatchingStoragePrefixByAPIPath(fullPath)
 	if !found {
-		b.Backend.Logger().Error("sys: unable to find storage for path", "path", path)
-		return handleError(fmt.Errorf("unable to find storage for path: %s", path))
+		b.Backend.Logger().Error("sys: unable to find storage for path", "path", fullPath)
+		return handleError(fmt.Errorf("unable to find storage for path: %s", fullPath))
 	}
 
 	// Attempt unmount
-	if err := b.Core.unmount(path); err != nil {
+	if err := b.Core.unmount(ns, path); err != nil {
 		b.Backend.Logger().Error("sys: unmount failed", "path", path, "error", err)
 		return handleError(err)
 	}
@@ -1553,13 +1604,14 @@ func (b *SystemBackend) handleRemount(
 	fromPath = sanitizeMountPath(fromPath)
 	toPath = sanitizeMountPath(toPath)
 
-	entry := b.Core.router.MatchingMountEntry(fromPath)
+	ns := b.requestNamespace(req)
+	entry := b.Core.router.MatchingMountEntry(ns.Path + fromPath)
 	if entry != nil && !entry.Local && repState.HasState(consts.ReplicationPerformanceSecondary) {
 		return logical.ErrorResponse("cannot remount a non-local mount on a replication secondary"), nil
 	}
 
 	// Attempt remount
-	if err := b.Core.remount(fromPath, toPath); err != nil {
+	if err := b.Core.remount(ns, fromPath, toPath); err != nil {
 		b.Backend.Logger().Error("sys: remount failed", "from_path", fromPath, "to_path", toPath, "error", err)
 		return handleError(err)
 	}
@@ -1576,7 +1628,7 @@ func (b *SystemBackend) handleAuthTuneRead(
 				"path must be specified as a string"),
 			logical.ErrInvalidRequest
 	}
-	return b.handleTuneReadCommon("auth/" + path)
+	return b.handleTuneReadCommon(b.requestNamespace(req), "auth/"+path)
 }
 
 // handleMountTuneRead is used to get config settings on a backend
@@ -1592,12 +1644,13 @@ func (b *SystemBackend) handleMountTuneRead(
 	// This call will read both logical backend's configuration as well as auth backends'.
 	// Retaining this behavior for backward compatibility. If this behavior is not desired,
 	// an error can be returned if path has a prefix of "auth/".
-	return b.handleTuneReadCommon(path)
+	return b.handleTuneReadCommon(b.requestNamespace(req), path)
 }
 
-// handleTuneReadCommon returns the config settings of a path
-func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, error) {
-	path = sanitizeMountPath(path)
+// handleTuneReadCommon returns the config settings of a path of the given
+// namespace
+func (b *SystemBackend) handleTuneReadCommon(ns *namespace.Namespace, path string) (*logical.Response, error) {
+	path = ns.Path + sanitizeMountPath(path)
 
 	sysView := b.Core.router.MatchingSystemView(path)
 	if sysView == nil {
@@ -1619,6 +1672,10 @@ func (b *SystemBackend) handleTuneReadCommon(path string) (*logical.Response, er
 		},
 	}
 
+	if len(mountEntry.Options) > 0 {
+		resp.Data["options"] = mountEntry.Options
+	}
+
 	return resp, nil
 }
 
@@ -1630,7 +1687,7 @@ func (b *SystemBackend) handleAuthTuneWrite(
 		return logical.ErrorResponse("path must be specified as a string"),
 			logical.ErrInvalidRequest
 	}
-	return b.handleTuneWriteCommon("auth/"+path, data)
+	return b.handleTuneWriteCommon(b.requestNamespace(req), "auth/"+path, data)
 }
 
 // handleMountTuneWrite is used to set config settings on a backend
@@ -1644,12 +1701,13 @@ func (b *SystemBackend) handleMountTuneWrite(
 	// This call will write both logical backend's configuration as well as auth backends'.
 	// Retaining this behavior for backward compatibility. If this behavior is not desired,
 	// an error can be returned if path has a prefix of "auth/".
-	return b.handleTuneWriteCommon(path, data)
+	return b.handleTuneWriteCommon(b.requestNamespace(req), path, data)
 }
 
-// handleTuneWriteCommon is used to set config settings on a path
+// handleTuneWriteCommon is used to set config settings on a path of the
+// given namespace
 func (b *SystemBackend) handleTuneWriteCommon(
-	path string, data *framework.FieldData) (*logical.Response, error) {
+	ns *namespace.Namespace, path string, data *framework.FieldData) (*logical.Response, error) {
 	repState := b.Core.replicationState
 
 	path = sanitizeMountPath(path)
@@ -1662,6 +1720,11 @@ func (b *SystemBackend) handleTuneWriteCommon(
 		}
 	}
 
+	// The auth prefix check below is relative to the namespace, everything
+	// else needs the full path
+	isAuth := strings.HasPrefix(path, credentialRoutePrefix)
+	path = ns.Path + path
+
 	mountEntry := b.Core.router.MatchingMountEntry(path)
 	if mountEntry == nil {
 		b.Backend.Logger().Error("sys: tune failed: no mount entry found", "path", path)
@@ -1673,7 +1736,7 @@ func (b *SystemBackend) handleTuneWriteCommon(
 
 	var lock *sync.RWMutex
 	switch {
-	case strings.HasPrefix(path, credentialRoutePrefix):
+	case isAuth:
 		lock = &b.Core.authLock
 	default:
 		lock = &b.Core.mountsLock
@@ -1741,7 +1804,7 @@ func (b *SystemBackend) handleTuneWriteCommon(
 		// Update the mount table
 		var err error
 		switch {
-		case strings.HasPrefix(path, "auth/"):
+		case isAuth:
 			err = b.Core.persistAuth(b.Core.auth, mountEntry.Local)
 		default:
 			err = b.Core.persistMounts(b.Core.mounts, mountEntry.Local)
@@ -1755,6 +1818,17 @@ func (b *SystemBackend) handleTuneWriteCommon(
 		}
 	}
 
+	if rawOptions, ok := data.GetOk("options"); ok {
+		optionMap, err := parseMountOptions(rawOptions.(map[string]interface{}))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		if err := b.tuneMountOptions(path, mountEntry, optionMap); err != nil {
+			b.Backend.Logger().Error("sys: tuning of options failed", "path", path, "error", err)
+			return handleError(err)
+		}
+	}
+
 	return nil, nil
 }
 
@@ -1767,6 +1841,10 @@ func (b *SystemBackend) handleLeaseLookup(
 			logical.ErrInvalidRequest
 	}
 
+	if !strings.HasPrefix(leaseID, b.requestNamespace(req).Path) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
 	leaseTimes, err := b.Core.expiration.FetchLeaseTimes(leaseID)
 	if err != nil {
 		b.Backend.Logger().Error("sys: error retrieving lease", "lease_id", leaseID, "error", err)
@@ -1804,6 +1882,7 @@ func (b *SystemBackend) handleLeaseLookupList(
 	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix = prefix + "/"
 	}
+	prefix = b.requestNamespace(req).Path + prefix
 
 	keys, err := b.Core.expiration.idView.List(prefix)
 	if err != nil {
@@ -1830,6 +1909,10 @@ func (b *SystemBackend) handleRenew(
 	// Convert the increment
 	increment := time.Duration(incrementRaw) * time.Second
 
+	if !strings.HasPrefix(leaseID, b.requestNamespace(req).Path) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
 	// Invoke the expiration manager directly
 	resp, err := b.Core.expiration.Renew(leaseID, increment)
 	if err != nil {
@@ -1852,6 +1935,10 @@ func (b *SystemBackend) handleRevoke(
 			logical.ErrInvalidRequest
 	}
 
+	if !strings.HasPrefix(leaseID, b.requestNamespace(req).Path) {
+		return logical.ErrorResponse("invalid lease"), logical.ErrInvalidRequest
+	}
+
 	// Invoke the expiration manager directly
 	if err := b.Core.expiration.Revoke(leaseID); err != nil {
 		b.Backend.Logger().Error("sys: lease revocation failed", "lease_id", leaseID, "error", err)
@@ -1876,7 +1963,7 @@ func (b *SystemBackend) handleRevokeForce(
 func (b *SystemBackend) handleRevokePrefixCommon(
 	req *logical.Request, data *framework.FieldData, force bool) (*logical.Response, error) {
 	// Get all the options
-	prefix := data.Get("prefix").(string)
+	prefix := b.requestNamespace(req).Path + data.Get("prefix").(string)
 
 	// Invoke the expiration manager directly
 	var err error
@@ -1895,6 +1982,8 @@ func (b *SystemBackend) handleRevokePrefixCommon(
 // handleAuthTable handles the "auth" endpoint to provide the auth table
 func (b *SystemBackend) handleAuthTable(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns := b.requestNamespace(req)
+
 	b.Core.authLock.RLock()
 	defer b.Core.authLock.RUnlock()
 
@@ -1902,6 +1991,10 @@ func (b *SystemBackend) handleAuthTable(
 		Data: make(map[string]interface{}),
 	}
 	for _, entry := range b.Core.auth.Entries {
+		if entry.Namespace().ID != ns.ID {
+			continue
+		}
+
 		info := map[string]interface{}{
 			"type":        entry.Type,
 			"description": entry.Description,
@@ -1971,6 +2064,7 @@ func (b *SystemBackend) handleEnableAuth(
 	// Create the mount entry
 	me := &MountEntry{
 		Table:       credentialTableType,
+		NamespaceID: b.requestNamespace(req).ID,
 		Path:        path,
 		Type:        logicalType,
 		Description: description,
@@ -1992,7 +2086,8 @@ func (b *SystemBackend) handleDisableAuth(
 	path := data.Get("path").(string)
 	path = sanitizeMountPath(path)
 
-	fullPath := credentialRoutePrefix + path
+	ns := b.requestNamespace(req)
+	fullPath := ns.Path + credentialRoutePrefix + path
 
 	repState := b.Core.replicationState
 	entry := b.Core.router.MatchingMountEntry(fullPath)
@@ -2008,7 +2103,7 @@ func (b *SystemBackend) handleDisableAuth(
 	}
 
 	// Attempt disable
-	if err := b.Core.disableCredential(path); err != nil {
+	if err := b.Core.disableCredential(ns, path); err != nil {
 		b.Backend.Logger().Error("sys: disable auth mount failed", "path", path, "error", err)
 		return handleError(err)
 	}
@@ -2019,10 +2114,13 @@ func (b *SystemBackend) handleDisableAuth(
 func (b *SystemBackend) handlePolicyList(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	// Get all the configured policies
-	policies, err := b.Core.policyStore.ListPolicies(PolicyTypeACL)
+	ns := b.requestNamespace(req)
+	policies, err := b.Core.policyStore.ListPolicies(ns, PolicyTypeACL)
 
-	// Add the special "root" policy
-	policies = append(policies, "root")
+	// Add the special "root" policy, which only exists in the root namespace
+	if ns.ID == namespace.RootNamespaceID {
+		policies = append(policies, "root")
+	}
 	resp := logical.ListResponse(policies)
 
 	// Backwords compatibility
@@ -2033,7 +2131,8 @@ func (b *SystemBackend) handlePolicyList(
 
 func (b *SystemBackend) handlePoliciesList(policyType PolicyType) func(*logical.Request, *framework.FieldData) (*logical.Response, error) {
 	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		policies, err := b.Core.policyStore.ListPolicies(policyType)
+		ns := b.requestNamespace(req)
+		policies, err := b.Core.policyStore.ListPolicies(ns, policyType)
 		if err != nil {
 			return nil, err
 		}
@@ -2041,7 +2140,9 @@ func (b *SystemBackend) handlePoliciesList(policyType PolicyType) func(*logical.
 		switch policyType {
 		case PolicyTypeACL:
 			// Add the special "root" policy if not egp
-			policies = append(policies, "root")
+			if ns.ID == namespace.RootNamespaceID {
+				policies = append(policies, "root")
+			}
 			return logical.ListResponse(policies), nil
 
 		}
@@ -2054,7 +2155,7 @@ func (b *SystemBackend) handlePoliciesRead(policyType PolicyType) func(*logical.
 	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 		name := data.Get("name").(string)
 
-		policy, err := b.Core.policyStore.GetPolicy(name, policyType)
+		policy, err := b.Core.policyStore.GetPolicy(b.requestNamespace(req), name, policyType)
 		if err != nil {
 			return handleError(err)
 		}
@@ -2079,7 +2180,7 @@ func (b *SystemBackend) handlePolicyRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
-	policy, err := b.Core.policyStore.GetPolicy(name, PolicyTypeACL)
+	policy, err := b.Core.policyStore.GetPolicy(b.requestNamespace(req), name, PolicyTypeACL)
 	if err != nil {
 		return handleError(err)
 	}
@@ -2132,7 +2233,7 @@ func (b *SystemBackend) handlePoliciesSet(policyType PolicyType) func(*logical.R
 		}
 
 		// Update the policy
-		if err := b.Core.policyStore.SetPolicy(policy); err != nil {
+		if err := b.Core.policyStore.SetPolicy(b.requestNamespace(req), policy); err != nil {
 			return handleError(err)
 		}
 		return nil, nil
@@ -2172,7 +2273,7 @@ func (b *SystemBackend) handlePolicySet(
 	policy.Paths = p.Paths
 
 	// Update the policy
-	if err := b.Core.policyStore.SetPolicy(policy); err != nil {
+	if err := b.Core.policyStore.SetPolicy(b.requestNamespace(req), policy); err != nil {
 		return handleError(err)
 	}
 	return resp, nil
@@ -2182,7 +2283,7 @@ func (b *SystemBackend) handlePoliciesDelete(policyType PolicyType) func(*logica
 	return func(req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 		name := data.Get("name").(string)
 
-		if err := b.Core.policyStore.DeletePolicy(name, policyType); err != nil {
+		if err := b.Core.policyStore.DeletePolicy(b.requestNamespace(req), name, policyType); err != nil {
 			return handleError(err)
 		}
 		return nil, nil
@@ -2194,7 +2295,7 @@ func (b *SystemBackend) handlePolicyDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
-	if err := b.Core.policyStore.DeletePolicy(name, PolicyTypeACL); err != nil {
+	if err := b.Core.policyStore.DeletePolicy(b.requestNamespace(req), name, PolicyTypeACL); err != nil {
 		return handleError(err)
 	}
 	return nil, nil
@@ -2262,14 +2363,9 @@ func (b *SystemBackend) handleEnableAudit(
 	description := data.Get("description").(string)
 	options := data.Get("options").(map[string]interface{})
 
-	optionMap := make(map[string]string)
-	for k, v := range options {
-		vStr, ok := v.(string)
-		if !ok {
-			return logical.ErrorResponse("options must be string valued"),
-				logical.ErrInvalidRequest
-		}
-		optionMap[k] = vStr
+	optionMap, err := parseMountOptions(options)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	// Create the mount entry
@@ -2980,6 +3076,14 @@ in the plugin catalog.`,
 		`The max lease TTL for this mount.`,
 	},
 
+	"tune_mount_options": {
+		`The options to pass into the backend. Should be a json object with string keys and values.`,
+	},
+
+	"mount_options": {
+		`The options to pass into the backend. Should be a json object with string keys and values.`,
+	},
+
 	"remount": {
 		"Move the mount point of an already-mounted backend.",
 		`
@@ -3360,6 +3464,93 @@ This path responds to the following HTTP methods.
 		`The mount paths of the plugin backends to reload.`,
 		"",
 	},
+	"raft-join": {
+		"Add a node to the raft storage cluster.",
+		`Adds the node with the given ID and raft address to the raft storage
+		cluster as a voter. The node must have been started with raft storage and
+		without bootstrapping its own cluster.`,
+	},
+	"raft-remove-peer": {
+		"Remove a node from the raft storage cluster.",
+		`Removes the node with the given ID from the raft storage cluster. The
+		node will no longer take part in replication or leader election.`,
+	},
+	"raft-list-peers": {
+		"List the nodes in the raft storage cluster.",
+		`Returns the ID and raft address of every node in the raft storage cluster,
+		along with whether it is the current leader and whether it is a voter.`,
+	},
+	"raft-node-id": {
+		`The ID of the raft node.`,
+		"",
+	},
+	"raft-address": {
+		`The address the raft node listens on for cluster traffic.`,
+		"",
+	},
+	"namespaces": {
+		"Create, read or delete a namespace.",
+		`Namespaces are isolated tenants of Vault with their own mounts, policies
+		and tokens. The path is relative to the namespace of the request; a new
+		namespace is created as a child of the namespace at its parent path.`,
+	},
+	"namespaces-list": {
+		"List the child namespaces of the current namespace.",
+		"",
+	},
+	"namespace-path": {
+		`The path of the namespace, relative to the current namespace.`,
+		"",
+	},
+	"quotas-list": {
+		"List the rate limit or lease count quotas.",
+		"",
+	},
+	"quota-type": {
+		`The type of the quotas, either "rate-limit" or "lease-count".`,
+		"",
+	},
+	"quota-name": {
+		`The name of the quota.`,
+		"",
+	},
+	"quota-path": {
+		`The path the quota applies to. This can be a mount, such as "secret/",
+		or a path prefix within a mount, such as "secret/team-a/". If empty,
+		the quota applies globally.`,
+		"",
+	},
+	"quota-rate": {
+		`The number of requests each client may make per interval.`,
+		"",
+	},
+	"quota-interval": {
+		`The interval the rate applies to. Defaults to one second.`,
+		"",
+	},
+	"quota-burst": {
+		`The maximum number of requests each client may make at once.
+		Defaults to the rate.`,
+		"",
+	},
+	"quota-max-leases": {
+		`The maximum number of leases under the path of the quota.`,
+		"",
+	},
+	"rate-limit-quota": {
+		"Create, read or delete a rate limit quota.",
+		`Rate limit quotas limit the rate of requests each client, identified by
+		its address, may make under a path. Requests over the limit are rejected
+		with a 429 status code and a Retry-After header. When several quotas
+		match a request, the one with the most specific path applies.`,
+	},
+	"lease-count-quota": {
+		"Create, read or delete a lease count quota.",
+		`Lease count quotas limit the number of leases, including the leases of
+		tokens, that may exist under a path. Requests that would create a lease
+		over the limit are rejected with a 429 status code. When several quotas
+		match a request, the one with the most specific path applies.`,
+	},
 	"hash": {
 		"Generate a hash sum for input data",
 		"Generates a hash sum of the given algorithm against the given input data.",
@@ -2,7 +2,6 @@ package vault
 
 import (
 	"fmt"
-	"strings"
 	"time"
 )
 
@@ -36,7 +35,7 @@ func (b *SystemBackend) tuneMountTTLs(path string, me *MountEntry, newDefault, n
 	// Update the mount table
 	var err error
 	switch {
-	case strings.HasPrefix(path, credentialRoutePrefix):
+	case me.Table == credentialTableType:
 		err = b.Core.persistAuth(b.Core.auth, me.Local)
 	default:
 		err = b.Core.persistMounts(b.Core.mounts, me.Local)
@@ -52,3 +51,74 @@ func (b *SystemBackend) tuneMountTTLs(path string, me *MountEntry, newDefault, n
 
 	return nil
 }
+
+// parseMountOptions converts the raw options given over the API into the
+// string map stored on the mount entry
+func parseMountOptions(options map[string]interface{}) (map[string]string, error) {
+	optionMap := make(map[string]string)
+	for k, v := range options {
+		vStr, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("options must be string valued")
+		}
+		optionMap[k] = vStr
+	}
+	return optionMap, nil
+}
+
+// tuneMountOptions is used to merge new backend options into a mount point;
+// the backend is reloaded so that it picks up the new options
+func (b *SystemBackend) tuneMountOptions(path string, me *MountEntry, options map[string]string) error {
+	if me.Table == credentialTableType {
+		return fmt.Errorf("options cannot be tuned on auth mounts")
+	}
+
+	newOptions := make(map[string]string, len(me.Options)+len(options))
+	for k, v := range me.Options {
+		newOptions[k] = v
+	}
+	var changed bool
+	for k, v := range options {
+		if existing, ok := newOptions[k]; !ok || existing != v {
+			changed = true
+		}
+		newOptions[k] = v
+	}
+	if !changed {
+		return nil
+	}
+
+	// A versioned kv mount has had its storage upgraded, so it cannot go back
+	if me.Options["version"] == "2" && newOptions["version"] != "2" {
+		return fmt.Errorf("cannot downgrade a versioned kv mount")
+	}
+
+	origOptions := me.Options
+	me.Options = newOptions
+
+	// The mount table is updated before the backend is reloaded, since
+	// reloading may run a storage upgrade that the old options cannot read
+	if err := b.Core.persistMounts(b.Core.mounts, me.Local); err != nil {
+		me.Options = origOptions
+		return fmt.Errorf("failed to update mount table, rolling back option changes")
+	}
+
+	if err := b.Core.reloadBackendCommon(me, false); err != nil {
+		// Upgrades only remove data once they are committed, at which point
+		// they no longer fail, so the old options are still usable here
+		me.Options = origOptions
+		if err := b.Core.persistMounts(b.Core.mounts, me.Local); err != nil {
+			b.Core.logger.Error("core: failed to roll back options in mount table", "path", path, "error", err)
+		}
+		if err := b.Core.reloadBackendCommon(me, false); err != nil {
+			b.Core.logger.Error("core: failed to reload backend after rolling back options", "path", path, "error", err)
+		}
+		return err
+	}
+
+	if b.Core.logger.IsInfo() {
+		b.Core.logger.Info("core: mount tuning of options successful", "path", path)
+	}
+
+	return nil
+}
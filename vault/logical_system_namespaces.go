@@ -0,0 +1,182 @@
+package vault
+
+import (
+	"path"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// namespaceSystemPaths are the prefixes of the paths of the system backend
+// that are available in child namespaces. Everything else, such as sealing,
+// auditing or raw storage access, concerns the whole of Vault and is only
+// available in the root namespace.
+var namespaceSystemPaths = []string{
+	"auth",
+	"capabilities",
+	"internal/ui/mounts",
+	"leases/",
+	"mounts",
+	"namespaces",
+	"policies/acl",
+	"policy",
+	"remount",
+	"renew",
+	"revoke",
+	"tools/",
+	"wrapping/",
+}
+
+// namespacePaths returns the paths used to manage the child namespaces of
+// the namespace of the system backend
+func (b *SystemBackend) namespacePaths() []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "namespaces/?$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.handleNamespacesList,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["namespaces-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["namespaces-list"][1]),
+		},
+		&framework.Path{
+			Pattern: "namespaces/(?P<path>.+)",
+
+			Fields: map[string]*framework.FieldSchema{
+				"path": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["namespace-path"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleNamespacesRead,
+				logical.UpdateOperation: b.handleNamespacesCreate,
+				logical.DeleteOperation: b.handleNamespacesDelete,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["namespaces"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["namespaces"][1]),
+		},
+	}
+}
+
+// HandleRequest restricts the system backends of child namespaces to the
+// paths that are scoped to a namespace, before handing the request to the
+// framework. Rollbacks aren't tied to a path and always go through.
+func (b *SystemBackend) HandleRequest(req *logical.Request) (*logical.Response, error) {
+	ns := b.Core.namespaceByPath(req.MountPoint)
+	if ns.ID != namespace.RootNamespaceID && req.Operation != logical.RollbackOperation {
+		allowed := false
+		for _, prefix := range namespaceSystemPaths {
+			if strings.HasPrefix(req.Path, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return logical.ErrorResponse("path is only available in the root namespace"), logical.ErrUnsupportedPath
+		}
+	}
+
+	return b.Backend.HandleRequest(req)
+}
+
+// requestNamespace returns the namespace the request was made in, which is
+// the namespace of the system backend serving it
+func (b *SystemBackend) requestNamespace(req *logical.Request) *namespace.Namespace {
+	return b.Core.namespaceByPath(req.MountPoint)
+}
+
+// childNamespace returns the descendant of the given namespace at the given
+// relative path, or nil if there is no such namespace
+func (b *SystemBackend) childNamespace(parent *namespace.Namespace, relPath string) *namespace.Namespace {
+	fullPath := parent.Path + namespace.Canonicalize(relPath)
+	ns := b.Core.namespaceByPath(fullPath)
+	if ns.Path != fullPath || ns.ID == parent.ID {
+		return nil
+	}
+	return ns
+}
+
+// handleNamespacesList lists the direct children of the namespace of the
+// request
+func (b *SystemBackend) handleNamespacesList(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	parent := b.requestNamespace(req)
+
+	var keys []string
+	for _, ns := range b.Core.listNamespaces(parent) {
+		keys = append(keys, parent.TrimmedPath(ns.Path))
+	}
+	return logical.ListResponse(keys), nil
+}
+
+// handleNamespacesRead returns the namespace at the given path, relative to
+// the namespace of the request
+func (b *SystemBackend) handleNamespacesRead(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ns := b.childNamespace(b.requestNamespace(req), d.Get("path").(string))
+	if ns == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		},
+	}, nil
+}
+
+// handleNamespacesCreate creates a namespace at the given path, relative to
+// the namespace of the request. The parent of the new namespace must exist.
+func (b *SystemBackend) handleNamespacesCreate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	relPath := strings.Trim(d.Get("path").(string), "/")
+	if relPath == "" {
+		return logical.ErrorResponse("missing namespace path"), logical.ErrInvalidRequest
+	}
+
+	parent := b.requestNamespace(req)
+	if dir, _ := path.Split(relPath); dir != "" {
+		parent = b.childNamespace(parent, dir)
+		if parent == nil {
+			return logical.ErrorResponse("parent namespace does not exist"), logical.ErrInvalidRequest
+		}
+	}
+
+	ns, err := b.Core.createNamespace(parent, path.Base(relPath))
+	if err != nil {
+		b.Backend.Logger().Error("sys: namespace creation failed", "path", relPath, "error", err)
+		return handleError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"id":   ns.ID,
+			"path": ns.Path,
+		},
+	}, nil
+}
+
+// handleNamespacesDelete deletes the namespace at the given path, relative
+// to the namespace of the request, along with all of its mounts, policies
+// and tokens
+func (b *SystemBackend) handleNamespacesDelete(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	relPath := d.Get("path").(string)
+
+	// We return success when the namespace does not exist, as with mounts
+	ns := b.childNamespace(b.requestNamespace(req), relPath)
+	if ns == nil {
+		return nil, nil
+	}
+
+	if err := b.Core.deleteNamespace(ns); err != nil {
+		b.Backend.Logger().Error("sys: namespace deletion failed", "path", relPath, "error", err)
+		return handleError(err)
+	}
+
+	return nil, nil
+}
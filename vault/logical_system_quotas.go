@@ -0,0 +1,227 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// quotaPaths returns the paths used to manage the rate limit and lease count
+// quotas
+func (b *SystemBackend) quotaPaths() []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "quotas/(?P<type>rate-limit|lease-count)/?$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"type": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quota-type"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: b.handleQuotasList,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-list"][1]),
+		},
+		&framework.Path{
+			Pattern: "quotas/rate-limit/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quota-name"][0]),
+				},
+				"path": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quota-path"][0]),
+				},
+				"rate": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: strings.TrimSpace(sysHelp["quota-rate"][0]),
+				},
+				"interval": &framework.FieldSchema{
+					Type:        framework.TypeDurationSecond,
+					Description: strings.TrimSpace(sysHelp["quota-interval"][0]),
+				},
+				"burst": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: strings.TrimSpace(sysHelp["quota-burst"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleQuotasRead(quotaTypeRateLimit),
+				logical.UpdateOperation: b.handleRateLimitQuotaUpdate,
+				logical.DeleteOperation: b.handleQuotasDelete(quotaTypeRateLimit),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["rate-limit-quota"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["rate-limit-quota"][1]),
+		},
+		&framework.Path{
+			Pattern: "quotas/lease-count/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quota-name"][0]),
+				},
+				"path": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["quota-path"][0]),
+				},
+				"max_leases": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: strings.TrimSpace(sysHelp["quota-max-leases"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   b.handleQuotasRead(quotaTypeLeaseCount),
+				logical.UpdateOperation: b.handleLeaseCountQuotaUpdate,
+				logical.DeleteOperation: b.handleQuotasDelete(quotaTypeLeaseCount),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["lease-count-quota"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["lease-count-quota"][1]),
+		},
+	}
+}
+
+// handleQuotasList lists the names of the quotas of the given type
+func (b *SystemBackend) handleQuotasList(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names := b.Core.quotas.list(d.Get("type").(string))
+	sort.Strings(names)
+	return logical.ListResponse(names), nil
+}
+
+// handleQuotasRead returns the quota of the given type
+func (b *SystemBackend) handleQuotasRead(quotaType string) framework.OperationFunc {
+	return func(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		q := b.Core.quotas.get(quotaType, d.Get("name").(string))
+		if q == nil {
+			return nil, nil
+		}
+
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				"name": q.Name,
+				"type": q.Type,
+				"path": q.Path,
+			},
+		}
+		switch quotaType {
+		case quotaTypeRateLimit:
+			resp.Data["rate"] = q.Rate
+			resp.Data["interval"] = int64(q.Interval.Seconds())
+			resp.Data["burst"] = q.Burst
+		case quotaTypeLeaseCount:
+			resp.Data["max_leases"] = q.MaxLeases
+		}
+		return resp, nil
+	}
+}
+
+// handleQuotasDelete deletes the quota of the given type
+func (b *SystemBackend) handleQuotasDelete(quotaType string) framework.OperationFunc {
+	return func(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if err := b.Core.quotas.delete(quotaType, d.Get("name").(string)); err != nil {
+			return handleError(err)
+		}
+		return nil, nil
+	}
+}
+
+// newQuota returns the quota of the given type being updated, starting from
+// the existing quota if there is one. The path of the quota must be empty or
+// be within a mount.
+func (b *SystemBackend) newQuota(quotaType string, d *framework.FieldData) (*Quota, error) {
+	name := d.Get("name").(string)
+	q := &Quota{
+		Name: name,
+		Type: quotaType,
+	}
+	if existing := b.Core.quotas.get(quotaType, name); existing != nil {
+		q.Path = existing.Path
+		q.Rate = existing.Rate
+		q.Interval = existing.Interval
+		q.Burst = existing.Burst
+		q.MaxLeases = existing.MaxLeases
+	}
+
+	if pathRaw, ok := d.GetOk("path"); ok {
+		q.Path = strings.TrimPrefix(pathRaw.(string), "/")
+	}
+	if q.Path != "" && b.Core.router.MatchingMount(q.Path) == "" {
+		return nil, fmt.Errorf("no mount at path %q", q.Path)
+	}
+	return q, nil
+}
+
+// handleRateLimitQuotaUpdate creates or updates a rate limit quota
+func (b *SystemBackend) handleRateLimitQuotaUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	q, err := b.newQuota(quotaTypeRateLimit, d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if rateRaw, ok := d.GetOk("rate"); ok {
+		q.Rate = rateRaw.(int)
+	}
+	if q.Rate <= 0 {
+		return logical.ErrorResponse("rate must be positive"), logical.ErrInvalidRequest
+	}
+
+	if intervalRaw, ok := d.GetOk("interval"); ok {
+		q.Interval = time.Duration(intervalRaw.(int)) * time.Second
+	}
+	if q.Interval == 0 {
+		q.Interval = time.Second
+	}
+	if q.Interval < 0 {
+		return logical.ErrorResponse("interval must be positive"), logical.ErrInvalidRequest
+	}
+
+	if burstRaw, ok := d.GetOk("burst"); ok {
+		q.Burst = burstRaw.(int)
+	}
+	if q.Burst == 0 {
+		q.Burst = q.Rate
+	}
+	if q.Burst < 0 {
+		return logical.ErrorResponse("burst must be positive"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.quotas.set(q); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
+
+// handleLeaseCountQuotaUpdate creates or updates a lease count quota
+func (b *SystemBackend) handleLeaseCountQuotaUpdate(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	q, err := b.newQuota(quotaTypeLeaseCount, d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if maxLeasesRaw, ok := d.GetOk("max_leases"); ok {
+		q.MaxLeases = maxLeasesRaw.(int)
+	}
+	if q.MaxLeases <= 0 {
+		return logical.ErrorResponse("max_leases must be positive"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.quotas.set(q); err != nil {
+		return handleError(err)
+	}
+	return nil, nil
+}
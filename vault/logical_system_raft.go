@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/physical/raft"
+)
+
+// raftStoragePaths returns the paths used to manage the cluster membership
+// of the raft storage backend. They are only mounted when raft is in use.
+func (b *SystemBackend) raftStoragePaths() []*framework.Path {
+	return []*framework.Path{
+		&framework.Path{
+			Pattern: "storage/raft/join$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"node_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["raft-node-id"][0]),
+				},
+				"address": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["raft-address"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleRaftJoin,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-join"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-join"][1]),
+		},
+		&framework.Path{
+			Pattern: "storage/raft/remove-peer$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"node_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["raft-node-id"][0]),
+				},
+			},
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.handleRaftRemovePeer,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-remove-peer"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-remove-peer"][1]),
+		},
+		&framework.Path{
+			Pattern: "storage/raft/list-peers$",
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: b.handleRaftListPeers,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-list-peers"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-list-peers"][1]),
+		},
+	}
+}
+
+// raftBackend returns the raft storage backend, if it is the one in use
+func (b *SystemBackend) raftBackend() (*raft.RaftBackend, bool) {
+	raftBackend, ok := b.Core.underlyingPhysical.(*raft.RaftBackend)
+	return raftBackend, ok
+}
+
+// handleRaftJoin adds a node to the raft cluster
+func (b *SystemBackend) handleRaftJoin(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftBackend, ok := b.raftBackend()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), nil
+	}
+
+	nodeID := d.Get("node_id").(string)
+	if nodeID == "" {
+		return logical.ErrorResponse("node_id is required"), nil
+	}
+	address := d.Get("address").(string)
+	if address == "" {
+		return logical.ErrorResponse("address is required"), nil
+	}
+
+	if err := raftBackend.AddPeer(nodeID, address); err != nil {
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRaftRemovePeer removes a node from the raft cluster
+func (b *SystemBackend) handleRaftRemovePeer(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftBackend, ok := b.raftBackend()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), nil
+	}
+
+	nodeID := d.Get("node_id").(string)
+	if nodeID == "" {
+		return logical.ErrorResponse("node_id is required"), nil
+	}
+
+	if err := raftBackend.RemovePeer(nodeID); err != nil {
+		return handleError(err)
+	}
+
+	return nil, nil
+}
+
+// handleRaftListPeers lists the members of the raft cluster
+func (b *SystemBackend) handleRaftListPeers(req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftBackend, ok := b.raftBackend()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), nil
+	}
+
+	peers, err := raftBackend.Peers()
+	if err != nil {
+		return handleError(err)
+	}
+
+	servers := make([]map[string]interface{}, 0, len(peers))
+	for _, peer := range peers {
+		servers = append(servers, map[string]interface{}{
+			"node_id": peer.NodeID,
+			"address": peer.Address,
+			"leader":  peer.Leader,
+			"voter":   peer.Voter,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"servers": servers,
+		},
+	}, nil
+}
@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical/raft"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func testRaftBackend(t *testing.T, nodeID string, bootstrap bool) (*raft.RaftBackend, func()) {
+	dir, err := ioutil.TempDir("", "vault-raft")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := map[string]string{
+		"path":        dir,
+		"node_id":     nodeID,
+		"address":     "127.0.0.1:0",
+		"tls_disable": "true",
+	}
+	if bootstrap {
+		conf["bootstrap"] = "true"
+	}
+
+	b, err := raft.NewRaftBackend(conf, logformat.NewVaultLogger(log.LevelTrace))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	raftBackend := b.(*raft.RaftBackend)
+
+	return raftBackend, func() {
+		raftBackend.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSystemBackend_raftStorage(t *testing.T) {
+	raft1, cleanup1 := testRaftBackend(t, "node1", true)
+	defer cleanup1()
+	raft2, cleanup2 := testRaftBackend(t, "node2", false)
+	defer cleanup2()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !raft1.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("raft node never became leader")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	c, _, root := TestCoreUnsealedBackend(t, raft1)
+	b := testSystemBackendInternal(t, c)
+
+	readServers := func() []map[string]interface{} {
+		req := logical.TestRequest(t, logical.ReadOperation, "storage/raft/list-peers")
+		req.ClientToken = root
+		resp, err := b.HandleRequest(req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return resp.Data["servers"].([]map[string]interface{})
+	}
+
+	servers := readServers()
+	if len(servers) != 1 || servers[0]["node_id"] != "node1" || servers[0]["leader"] != true {
+		t.Fatalf("bad: %#v", servers)
+	}
+
+	// Joining needs the address of the new node
+	req := logical.TestRequest(t, logical.UpdateOperation, "storage/raft/join")
+	req.ClientToken = root
+	req.Data["node_id"] = "node2"
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got: %#v", resp)
+	}
+
+	req.Data["address"] = raft2.Address()
+	resp, err = b.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("bad: resp: %#v err: %v", resp, err)
+	}
+
+	servers = readServers()
+	if len(servers) != 2 || servers[1]["node_id"] != "node2" || servers[1]["address"] != raft2.Address() {
+		t.Fatalf("bad: %#v", servers)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "storage/raft/remove-peer")
+	req.ClientToken = root
+	req.Data["node_id"] = "node2"
+	resp, err = b.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("bad: resp: %#v err: %v", resp, err)
+	}
+
+	servers = readServers()
+	if len(servers) != 1 || servers[0]["node_id"] != "node1" {
+		t.Fatalf("bad: %#v", servers)
+	}
+}
+
+func TestSystemBackend_raftStorageNotMounted(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "storage/raft/list-peers")
+	_, err := b.HandleRequest(req)
+	if err != logical.ErrUnsupportedPath {
+		t.Fatalf("expected unsupported path, got: %v", err)
+	}
+}
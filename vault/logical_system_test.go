@@ -16,6 +16,7 @@ import (
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/builtinplugins"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/pluginutil"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
@@ -41,6 +42,7 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"leases/revoke-prefix/*",
 		"leases/revoke-force/*",
 		"leases/lookup/*",
+		"storage/raft/*",
 	}
 
 	b := testSystemBackend(t)
@@ -128,7 +130,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"force_no_cache":    false,
 				"seal_wrap":         false,
 			},
-			"local": false,
+			"options": map[string]string(nil),
+			"local":   false,
 		},
 		"sys/": map[string]interface{}{
 			"type":        "system",
@@ -141,7 +144,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"force_no_cache":    false,
 				"seal_wrap":         false,
 			},
-			"local": false,
+			"options": map[string]string(nil),
+			"local":   false,
 		},
 		"cubbyhole/": map[string]interface{}{
 			"description": "per-token private secret storage",
@@ -154,7 +158,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"force_no_cache":    false,
 				"seal_wrap":         false,
 			},
-			"local": true,
+			"options": map[string]string(nil),
+			"local":   true,
 		},
 		"identity/": map[string]interface{}{
 			"description": "identity store",
@@ -167,7 +172,8 @@ func TestSystemBackend_mounts(t *testing.T) {
 				"force_no_cache":    false,
 				"seal_wrap":         false,
 			},
-			"local": false,
+			"options": map[string]string(nil),
+			"local":   false,
 		},
 	}
 	if !reflect.DeepEqual(resp.Data, exp) {
@@ -230,6 +236,57 @@ func TestSystemBackend_mount_invalid(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_tuneOptions(t *testing.T) {
+	c, b, root := testCoreSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+	req.ClientToken = root
+	req.Data["raw"] = "test"
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Upgrade the mount to a versioned kv mount
+	req = logical.TestRequest(t, logical.UpdateOperation, "mounts/secret/tune")
+	req.Data["options"] = map[string]interface{}{
+		"version": "2",
+	}
+	resp, err := b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "mounts/secret/tune")
+	resp, err = b.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["options"].(map[string]string)["version"] != "2" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The existing secret is now the first version
+	req = logical.TestRequest(t, logical.ReadOperation, "secret/data/foo")
+	req.ClientToken = root
+	resp, err = c.HandleRequest(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp == nil || resp.Data["data"].(map[string]interface{})["raw"] != "test" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// Downgrading is refused
+	req = logical.TestRequest(t, logical.UpdateOperation, "mounts/secret/tune")
+	req.Data["options"] = map[string]interface{}{
+		"version": "1",
+	}
+	resp, err = b.HandleRequest(req)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected error, got: %v, resp: %#v", err, resp)
+	}
+}
+
 func TestSystemBackend_unmount(t *testing.T) {
 	b := testSystemBackend(t)
 
@@ -279,7 +336,7 @@ func testCapabilities(t *testing.T, endpoint string) {
 	}
 
 	policy, _ := ParseACLPolicy(capabilitiesPolicy)
-	err = core.policyStore.SetPolicy(policy)
+	err = core.policyStore.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -331,7 +388,7 @@ func TestSystemBackend_CapabilitiesAccessor(t *testing.T) {
 	}
 
 	policy, _ := ParseACLPolicy(capabilitiesPolicy)
-	err = core.policyStore.SetPolicy(policy)
+	err = core.policyStore.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -1532,7 +1589,7 @@ func TestSystemBackend_rawDelete(t *testing.T) {
 		Name: "test",
 		Type: PolicyTypeACL,
 	}
-	err := c.policyStore.SetPolicy(p)
+	err := c.policyStore.SetPolicy(namespace.RootNamespace, p)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -1549,7 +1606,7 @@ func TestSystemBackend_rawDelete(t *testing.T) {
 
 	// Policy should be gone
 	c.policyStore.tokenPoliciesLRU.Purge()
-	out, err := c.policyStore.GetPolicy("test", PolicyTypeToken)
+	out, err := c.policyStore.GetPolicy(namespace.RootNamespace, "test", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
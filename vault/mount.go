@@ -13,6 +13,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -135,10 +136,10 @@ func (t *MountTable) Hash() ([]byte, error) {
 }
 
 // setTaint is used to set the taint on given entry
-func (t *MountTable) setTaint(path string, value bool) *MountEntry {
+func (t *MountTable) setTaint(ns *namespace.Namespace, path string, value bool) *MountEntry {
 	n := len(t.Entries)
 	for i := 0; i < n; i++ {
-		if t.Entries[i].Path == path {
+		if t.Entries[i].Path == path && t.Entries[i].Namespace().ID == ns.ID {
 			t.Entries[i].Tainted = value
 			return t.Entries[i]
 		}
@@ -148,10 +149,10 @@ func (t *MountTable) setTaint(path string, value bool) *MountEntry {
 
 // remove is used to remove a given path entry; returns the entry that was
 // removed
-func (t *MountTable) remove(path string) *MountEntry {
+func (t *MountTable) remove(ns *namespace.Namespace, path string) *MountEntry {
 	n := len(t.Entries)
 	for i := 0; i < n; i++ {
-		if entry := t.Entries[i]; entry.Path == path {
+		if entry := t.Entries[i]; entry.Path == path && entry.Namespace().ID == ns.ID {
 			t.Entries[i], t.Entries[n-1] = t.Entries[n-1], nil
 			t.Entries = t.Entries[:n-1]
 			return entry
@@ -181,6 +182,11 @@ type MountEntry struct {
 	Options     map[string]string `json:"options"`           // Backend options
 	Local       bool              `json:"local"`             // Local mounts are not replicated or affected by replication
 	Tainted     bool              `json:"tainted,omitempty"` // Set as a Write-Ahead flag for unmount/remount
+	NamespaceID string            `json:"namespace_id"`      // ID of the namespace the mount belongs to
+
+	// namespace is the namespace the mount belongs to, resolved from
+	// NamespaceID when the entry is loaded or mounted
+	namespace *namespace.Namespace
 }
 
 // MountConfig is used to hold settable options
@@ -207,9 +213,42 @@ func (e *MountEntry) Clone() (*MountEntry, error) {
 	if err != nil {
 		return nil, err
 	}
+	cp.(*MountEntry).namespace = e.namespace
 	return cp.(*MountEntry), nil
 }
 
+// Namespace returns the namespace the mount belongs to
+func (e *MountEntry) Namespace() *namespace.Namespace {
+	if e.namespace == nil {
+		return namespace.RootNamespace
+	}
+	return e.namespace
+}
+
+// APIPath returns the full path the mount is routed at, which includes the
+// path of its namespace and, for credential backends, the auth prefix
+func (e *MountEntry) APIPath() string {
+	path := e.Path
+	if e.Table == credentialTableType {
+		path = credentialRoutePrefix + path
+	}
+	return e.Namespace().Path + path
+}
+
+// setMountEntryNamespace resolves the namespace of the entry from its namespace ID.
+// Entries without a namespace ID belong to the root namespace.
+func (c *Core) setMountEntryNamespace(entry *MountEntry) error {
+	if entry.NamespaceID == "" {
+		entry.NamespaceID = namespace.RootNamespaceID
+	}
+	ns := c.namespaceByID(entry.NamespaceID)
+	if ns == nil {
+		return fmt.Errorf("namespace %q of mount %q not found", entry.NamespaceID, entry.Path)
+	}
+	entry.namespace = ns
+	return nil
+}
+
 // Mount is used to mount a new backend to the mount table.
 func (c *Core) mount(entry *MountEntry) error {
 	// Ensure we end the path in a slash
@@ -234,13 +273,21 @@ func (c *Core) mount(entry *MountEntry) error {
 }
 
 func (c *Core) mountInternal(entry *MountEntry) error {
+	if err := c.setMountEntryNamespace(entry); err != nil {
+		return err
+	}
+
 	c.mountsLock.Lock()
 	defer c.mountsLock.Unlock()
 
-	// Verify there is no conflicting mount
-	if match := c.router.MatchingMount(entry.Path); match != "" {
+	// Verify there is no conflicting mount or namespace
+	path := entry.APIPath()
+	if match := c.router.MatchingMount(path); match != "" {
 		return logical.CodedError(409, fmt.Sprintf("existing mount at %s", match))
 	}
+	if ns := c.namespaceByPath(path); ns.ID != entry.NamespaceID {
+		return logical.CodedError(409, fmt.Sprintf("existing namespace at %s", ns.Path))
+	}
 
 	// Generate a new UUID and view
 	if entry.UUID == "" {
@@ -257,8 +304,7 @@ func (c *Core) mountInternal(entry *MountEntry) error {
 		}
 		entry.Accessor = accessor
 	}
-	viewPath := backendBarrierPrefix + entry.UUID + "/"
-	view := NewBarrierView(c.barrier, viewPath)
+	view := c.mountEntryView(entry)
 	var backend logical.Backend
 	var err error
 	sysView := c.mountEntrySysView(entry)
@@ -266,9 +312,12 @@ func (c *Core) mountInternal(entry *MountEntry) error {
 	if entry.Config.PluginName != "" {
 		conf["plugin_name"] = entry.Config.PluginName
 	}
+	for k, v := range entry.Options {
+		conf[k] = v
+	}
 
 	// Consider having plugin name under entry.Options
-	backend, err = c.newLogicalBackend(entry.Type, sysView, view, conf)
+	backend, err = c.newLogicalBackend(entry.Type, entry.Path, sysView, view, conf)
 	if err != nil {
 		return err
 	}
@@ -298,19 +347,19 @@ func (c *Core) mountInternal(entry *MountEntry) error {
 	}
 	c.mounts = newTable
 
-	if err := c.router.Mount(backend, entry.Path, entry, view); err != nil {
+	if err := c.router.Mount(backend, path, entry, view); err != nil {
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("core: successful mount", "path", entry.Path, "type", entry.Type)
+		c.logger.Info("core: successful mount", "path", path, "type", entry.Type)
 	}
 	return nil
 }
 
-// Unmount is used to unmount a path. The boolean indicates whether the mount
-// was found.
-func (c *Core) unmount(path string) error {
+// Unmount is used to unmount a path in the given namespace. The boolean
+// indicates whether the mount was found.
+func (c *Core) unmount(ns *namespace.Namespace, path string) error {
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(path, "/") {
 		path += "/"
@@ -322,44 +371,45 @@ func (c *Core) unmount(path string) error {
 			return fmt.Errorf("cannot unmount '%s'", path)
 		}
 	}
-	return c.unmountInternal(path)
+	return c.unmountInternal(ns, path)
 }
 
-func (c *Core) unmountInternal(path string) error {
+func (c *Core) unmountInternal(ns *namespace.Namespace, path string) error {
 	// Verify exact match of the route
-	match := c.router.MatchingMount(path)
-	if match == "" || path != match {
+	fullPath := ns.Path + path
+	match := c.router.MatchingMount(fullPath)
+	if match == "" || fullPath != match {
 		return fmt.Errorf("no matching mount")
 	}
 
 	// Get the view for this backend
-	view := c.router.MatchingStorageByAPIPath(path)
+	view := c.router.MatchingStorageByAPIPath(fullPath)
 
 	// Get the backend/mount entry for this path, used to remove ignored
 	// replication prefixes
-	backend := c.router.MatchingBackend(path)
-	entry := c.router.MatchingMountEntry(path)
+	backend := c.router.MatchingBackend(fullPath)
+	entry := c.router.MatchingMountEntry(fullPath)
 
 	// Mark the entry as tainted
-	if err := c.taintMountEntry(path); err != nil {
-		c.logger.Error("core: failed to taint mount entry for path being unmounted", "error", err, "path", path)
+	if err := c.taintMountEntry(ns, path); err != nil {
+		c.logger.Error("core: failed to taint mount entry for path being unmounted", "error", err, "path", fullPath)
 		return err
 	}
 
 	// Taint the router path to prevent routing. Note that in-flight requests
 	// are uncertain, right now.
-	if err := c.router.Taint(path); err != nil {
+	if err := c.router.Taint(fullPath); err != nil {
 		return err
 	}
 
 	if backend != nil {
 		// Invoke the rollback manager a final time
-		if err := c.rollback.Rollback(path); err != nil {
+		if err := c.rollback.Rollback(fullPath); err != nil {
 			return err
 		}
 
 		// Revoke all the dynamic keys
-		if err := c.expiration.RevokePrefix(path); err != nil {
+		if err := c.expiration.RevokePrefix(fullPath); err != nil {
 			return err
 		}
 
@@ -368,7 +418,7 @@ func (c *Core) unmountInternal(path string) error {
 	}
 
 	// Unmount the backend entirely
-	if err := c.router.Unmount(path); err != nil {
+	if err := c.router.Unmount(fullPath); err != nil {
 		return err
 	}
 
@@ -376,33 +426,33 @@ func (c *Core) unmountInternal(path string) error {
 	case entry.Local, !c.replicationState.HasState(consts.ReplicationPerformanceSecondary):
 		// Have writable storage, remove the whole thing
 		if err := logical.ClearView(view); err != nil {
-			c.logger.Error("core: failed to clear view for path being unmounted", "error", err, "path", path)
+			c.logger.Error("core: failed to clear view for path being unmounted", "error", err, "path", fullPath)
 			return err
 		}
 	}
 
 	// Remove the mount table entry
-	if err := c.removeMountEntry(path); err != nil {
-		c.logger.Error("core: failed to remove mount entry for path being unmounted", "error", err, "path", path)
+	if err := c.removeMountEntry(ns, path); err != nil {
+		c.logger.Error("core: failed to remove mount entry for path being unmounted", "error", err, "path", fullPath)
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("core: successfully unmounted", "path", path)
+		c.logger.Info("core: successfully unmounted", "path", fullPath)
 	}
 	return nil
 }
 
 // removeMountEntry is used to remove an entry from the mount table
-func (c *Core) removeMountEntry(path string) error {
+func (c *Core) removeMountEntry(ns *namespace.Namespace, path string) error {
 	c.mountsLock.Lock()
 	defer c.mountsLock.Unlock()
 
 	// Remove the entry from the mount table
 	newTable := c.mounts.shallowClone()
-	entry := newTable.remove(path)
+	entry := newTable.remove(ns, path)
 	if entry == nil {
-		c.logger.Error("core: nil entry found removing entry in mounts table", "path", path)
+		c.logger.Error("core: nil entry found removing entry in mounts table", "path", ns.Path+path)
 		return logical.CodedError(500, "failed to remove entry in mounts table")
 	}
 
@@ -423,15 +473,15 @@ func (c *Core) removeMountEntry(path string) error {
 }
 
 // taintMountEntry is used to mark an entry in the mount table as tainted
-func (c *Core) taintMountEntry(path string) error {
+func (c *Core) taintMountEntry(ns *namespace.Namespace, path string) error {
 	c.mountsLock.Lock()
 	defer c.mountsLock.Unlock()
 
 	// As modifying the taint of an entry affects shallow clones,
 	// we simply use the original
-	entry := c.mounts.setTaint(path, true)
+	entry := c.mounts.setTaint(ns, path, true)
 	if entry == nil {
-		c.logger.Error("core: nil entry found tainting entry in mounts table", "path", path)
+		c.logger.Error("core: nil entry found tainting entry in mounts table", "path", ns.Path+path)
 		return logical.CodedError(500, "failed to taint entry in mounts table")
 	}
 
@@ -446,8 +496,8 @@ func (c *Core) taintMountEntry(path string) error {
 
 // remountForce takes a copy of the mount entry for the path and fully unmounts
 // and remounts the backend to pick up any changes, such as filtered paths
-func (c *Core) remountForce(path string) error {
-	me := c.router.MatchingMountEntry(path)
+func (c *Core) remountForce(ns *namespace.Namespace, path string) error {
+	me := c.router.MatchingMountEntry(ns.Path + path)
 	if me == nil {
 		return fmt.Errorf("cannot find mount for path '%s'", path)
 	}
@@ -457,14 +507,15 @@ func (c *Core) remountForce(path string) error {
 		return err
 	}
 
-	if err := c.unmount(path); err != nil {
+	if err := c.unmount(ns, path); err != nil {
 		return err
 	}
 	return c.mount(me)
 }
 
-// Remount is used to remount a path at a new mount point.
-func (c *Core) remount(src, dst string) error {
+// Remount is used to remount a path at a new mount point within the given
+// namespace.
+func (c *Core) remount(ns *namespace.Namespace, src, dst string) error {
 	// Ensure we end the path in a slash
 	if !strings.HasSuffix(src, "/") {
 		src += "/"
@@ -481,39 +532,43 @@ func (c *Core) remount(src, dst string) error {
 	}
 
 	// Verify exact match of the route
-	match := c.router.MatchingMount(src)
-	if match == "" || src != match {
+	srcPath, dstPath := ns.Path+src, ns.Path+dst
+	match := c.router.MatchingMount(srcPath)
+	if match == "" || srcPath != match {
 		return fmt.Errorf("no matching mount at '%s'", src)
 	}
 
-	if match := c.router.MatchingMount(dst); match != "" {
+	if match := c.router.MatchingMount(dstPath); match != "" {
 		return fmt.Errorf("existing mount at '%s'", match)
 	}
+	if dstNS := c.namespaceByPath(dstPath); dstNS.ID != ns.ID {
+		return fmt.Errorf("existing namespace at '%s'", dstNS.Path)
+	}
 
 	// Mark the entry as tainted
-	if err := c.taintMountEntry(src); err != nil {
+	if err := c.taintMountEntry(ns, src); err != nil {
 		return err
 	}
 
 	// Taint the router path to prevent routing
-	if err := c.router.Taint(src); err != nil {
+	if err := c.router.Taint(srcPath); err != nil {
 		return err
 	}
 
 	// Invoke the rollback manager a final time
-	if err := c.rollback.Rollback(src); err != nil {
+	if err := c.rollback.Rollback(srcPath); err != nil {
 		return err
 	}
 
 	// Revoke all the dynamic keys
-	if err := c.expiration.RevokePrefix(src); err != nil {
+	if err := c.expiration.RevokePrefix(srcPath); err != nil {
 		return err
 	}
 
 	c.mountsLock.Lock()
 	var entry *MountEntry
 	for _, entry = range c.mounts.Entries {
-		if entry.Path == src {
+		if entry.Path == src && entry.Namespace().ID == ns.ID {
 			entry.Path = dst
 			entry.Tainted = false
 			break
@@ -537,17 +592,17 @@ func (c *Core) remount(src, dst string) error {
 	c.mountsLock.Unlock()
 
 	// Remount the backend
-	if err := c.router.Remount(src, dst); err != nil {
+	if err := c.router.Remount(srcPath, dstPath); err != nil {
 		return err
 	}
 
 	// Un-taint the path
-	if err := c.router.Untaint(dst); err != nil {
+	if err := c.router.Untaint(dstPath); err != nil {
 		return err
 	}
 
 	if c.logger.IsInfo() {
-		c.logger.Info("core: successful remount", "old_path", src, "new_path", dst)
+		c.logger.Info("core: successful remount", "old_path", srcPath, "new_path", dstPath)
 	}
 	return nil
 }
@@ -605,7 +660,10 @@ func (c *Core) loadMounts() error {
 		for _, requiredMount := range c.requiredMountTable().Entries {
 			foundRequired := false
 			for _, coreMount := range c.mounts.Entries {
-				if coreMount.Type == requiredMount.Type {
+				// Only the root namespace is checked here; child namespaces
+				// get their required mounts when they are created
+				isRoot := coreMount.NamespaceID == "" || coreMount.NamespaceID == namespace.RootNamespaceID
+				if coreMount.Type == requiredMount.Type && isRoot {
 					foundRequired = true
 					break
 				}
@@ -642,6 +700,14 @@ func (c *Core) loadMounts() error {
 				entry.Accessor = accessor
 				needPersist = true
 			}
+			if entry.NamespaceID == "" {
+				entry.NamespaceID = namespace.RootNamespaceID
+				needPersist = true
+			}
+			if err := c.setMountEntryNamespace(entry); err != nil {
+				c.logger.Error("core: failed to resolve namespace of mount", "path", entry.Path, "error", err)
+				return errLoadMountsFailed
+			}
 		}
 
 		// Done if we have restored the mount table and we don't need
@@ -742,15 +808,13 @@ func (c *Core) setupMounts() error {
 	var err error
 
 	for _, entry := range c.mounts.Entries {
-
-		// Initialize the backend, special casing for system
-		barrierPath := backendBarrierPrefix + entry.UUID + "/"
-		if entry.Type == "system" {
-			barrierPath = systemBarrierPrefix
+		// Resolve the namespace of the entry
+		if err := c.setMountEntryNamespace(entry); err != nil {
+			return err
 		}
 
-		// Create a barrier view using the UUID
-		view = NewBarrierView(c.barrier, barrierPath)
+		// Create a barrier view using the UUID, special casing for system
+		view = c.mountEntryView(entry)
 
 		var backend logical.Backend
 		var err error
@@ -760,8 +824,11 @@ func (c *Core) setupMounts() error {
 		if entry.Config.PluginName != "" {
 			conf["plugin_name"] = entry.Config.PluginName
 		}
+		for k, v := range entry.Options {
+			conf[k] = v
+		}
 		// Create the new backend
-		backend, err = c.newLogicalBackend(entry.Type, sysView, view, conf)
+		backend, err = c.newLogicalBackend(entry.Type, entry.Path, sysView, view, conf)
 		if err != nil {
 			c.logger.Error("core: failed to create mount entry", "path", entry.Path, "error", err)
 			if errwrap.Contains(err, ErrPluginNotFound.Error()) && entry.Type == "plugin" {
@@ -790,19 +857,19 @@ func (c *Core) setupMounts() error {
 
 	ROUTER_MOUNT:
 		// Mount the backend
-		err = c.router.Mount(backend, entry.Path, entry, view)
+		err = c.router.Mount(backend, entry.APIPath(), entry, view)
 		if err != nil {
-			c.logger.Error("core: failed to mount entry", "path", entry.Path, "error", err)
+			c.logger.Error("core: failed to mount entry", "path", entry.APIPath(), "error", err)
 			return errLoadMountsFailed
 		}
 
 		if c.logger.IsInfo() {
-			c.logger.Info("core: successfully mounted backend", "type", entry.Type, "path", entry.Path)
+			c.logger.Info("core: successfully mounted backend", "type", entry.Type, "path", entry.APIPath())
 		}
 
 		// Ensure the path is tainted if set in the mount table
 		if entry.Tainted {
-			c.router.Taint(entry.Path)
+			c.router.Taint(entry.APIPath())
 		}
 	}
 	return nil
@@ -817,7 +884,7 @@ func (c *Core) unloadMounts() error {
 	if c.mounts != nil {
 		mountTable := c.mounts.shallowClone()
 		for _, e := range mountTable.Entries {
-			backend := c.router.MatchingBackend(e.Path)
+			backend := c.router.MatchingBackend(e.APIPath())
 			if backend != nil {
 				backend.Cleanup()
 			}
@@ -831,7 +898,7 @@ func (c *Core) unloadMounts() error {
 }
 
 // newLogicalBackend is used to create and configure a new logical backend by name
-func (c *Core) newLogicalBackend(t string, sysView logical.SystemView, view logical.Storage, conf map[string]string) (logical.Backend, error) {
+func (c *Core) newLogicalBackend(t, mountPoint string, sysView logical.SystemView, view logical.Storage, conf map[string]string) (logical.Backend, error) {
 	if alias, ok := mountAliases[t]; ok {
 		t = alias
 	}
@@ -845,6 +912,7 @@ func (c *Core) newLogicalBackend(t string, sysView logical.SystemView, view logi
 		Logger:      c.logger,
 		Config:      conf,
 		System:      sysView,
+		MountPoint:  mountPoint,
 	}
 
 	b, err := f(config)
@@ -857,6 +925,16 @@ func (c *Core) newLogicalBackend(t string, sysView logical.SystemView, view logi
 	return b, nil
 }
 
+// mountEntryView returns the barrier view holding the storage of the mount.
+// The system backend of each namespace uses the system view of the namespace,
+// every other backend uses a view based on the UUID of the mount.
+func (c *Core) mountEntryView(entry *MountEntry) *BarrierView {
+	if entry.Type == "system" {
+		return NewBarrierView(c.barrier, namespaceSystemBarrierPrefix(entry.Namespace()))
+	}
+	return NewBarrierView(c.barrier, backendBarrierPrefix+entry.UUID+"/")
+}
+
 // mountEntrySysView creates a logical.SystemView from global and
 // mount-specific entries; because this should be called when setting
 // up a mountEntry, it doesn't check to ensure that me is not nil
@@ -887,6 +965,8 @@ func (c *Core) defaultMountTable() *MountTable {
 		Description: "key/value secret storage",
 		UUID:        mountUUID,
 		Accessor:    mountAccessor,
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
 	}
 	table.Entries = append(table.Entries, kvMount)
 	table.Entries = append(table.Entries, c.requiredMountTable().Entries...)
@@ -915,6 +995,8 @@ func (c *Core) requiredMountTable() *MountTable {
 		UUID:        cubbyholeUUID,
 		Accessor:    cubbyholeAccessor,
 		Local:       true,
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
 	}
 
 	sysUUID, err := uuid.GenerateUUID()
@@ -932,6 +1014,8 @@ func (c *Core) requiredMountTable() *MountTable {
 		Description: "system endpoints used for control, policy and debugging",
 		UUID:        sysUUID,
 		Accessor:    sysAccessor,
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
 	}
 
 	identityUUID, err := uuid.GenerateUUID()
@@ -950,6 +1034,8 @@ func (c *Core) requiredMountTable() *MountTable {
 		Description: "identity store",
 		UUID:        identityUUID,
 		Accessor:    identityAccessor,
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
 	}
 
 	table.Entries = append(table.Entries, cubbyholeMount)
@@ -990,13 +1076,24 @@ func (c *Core) singletonMountTables() (mounts, auth *MountTable) {
 
 func (c *Core) setCoreBackend(entry *MountEntry, backend logical.Backend, view *BarrierView) {
 	switch entry.Type {
-	case "system":
-		c.systemBackend = backend.(*SystemBackend)
-		c.systemBarrierView = view
 	case "cubbyhole":
 		ch := backend.(*CubbyholeBackend)
 		ch.saltUUID = entry.UUID
 		ch.storageView = view
+	case "system":
+		backend.(*SystemBackend).ns = entry.Namespace()
+	}
+
+	// The system backend and identity store of child namespaces are only
+	// reached through the router
+	if entry.Namespace().ID != namespace.RootNamespaceID {
+		return
+	}
+
+	switch entry.Type {
+	case "system":
+		c.systemBackend = backend.(*SystemBackend)
+		c.systemBarrierView = view
 	case "identity":
 		c.identityStore = backend.(*IdentityStore)
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/compressutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -181,7 +182,7 @@ func TestCore_Mount_Local(t *testing.T) {
 
 func TestCore_Unmount(t *testing.T) {
 	c, keys, _ := TestCoreUnsealed(t)
-	err := c.unmount("secret")
+	err := c.unmount(namespace.RootNamespace, "secret")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -272,7 +273,7 @@ func TestCore_Unmount_Cleanup(t *testing.T) {
 	}
 
 	// Unmount, this should cleanup
-	if err := c.unmount("test/"); err != nil {
+	if err := c.unmount(namespace.RootNamespace, "test/"); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -301,7 +302,7 @@ func TestCore_Unmount_Cleanup(t *testing.T) {
 
 func TestCore_Remount(t *testing.T) {
 	c, keys, _ := TestCoreUnsealed(t)
-	err := c.remount("secret", "foo")
+	err := c.remount(namespace.RootNamespace, "secret", "foo")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -392,7 +393,7 @@ func TestCore_Remount_Cleanup(t *testing.T) {
 	}
 
 	// Remount, this should cleanup
-	if err := c.remount("test/", "new/"); err != nil {
+	if err := c.remount(namespace.RootNamespace, "test/", "new/"); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -421,7 +422,7 @@ func TestCore_Remount_Cleanup(t *testing.T) {
 
 func TestCore_Remount_Protected(t *testing.T) {
 	c, _, _ := TestCoreUnsealed(t)
-	err := c.remount("sys", "foo")
+	err := c.remount(namespace.RootNamespace, "sys", "foo")
 	if err.Error() != "cannot remount 'sys/'" {
 		t.Fatalf("err: %v", err)
 	}
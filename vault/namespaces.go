@@ -0,0 +1,346 @@
+package vault
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// coreNamespacesPrefix is the storage prefix of the namespace entries
+	coreNamespacesPrefix = "core/namespaces/"
+
+	// namespaceBarrierPrefix is the prefix of the storage of child
+	// namespaces that isn't tied to a mount, such as their policies and
+	// token roles. The root namespace uses the system view instead.
+	namespaceBarrierPrefix = "namespaces/"
+)
+
+var (
+	// reservedNamespaceNames cannot be used as namespace names, as they
+	// would shadow the required mounts of the parent namespace
+	reservedNamespaceNames = []string{
+		"audit",
+		"auth",
+		"cubbyhole",
+		"identity",
+		"root",
+		"sys",
+	}
+
+	// namespaceNameRegex is the set of valid namespace names
+	namespaceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// namespaceSystemBarrierPrefix returns the storage prefix of the system
+// backend of the given namespace.
+func namespaceSystemBarrierPrefix(ns *namespace.Namespace) string {
+	if ns.ID == namespace.RootNamespaceID {
+		return systemBarrierPrefix
+	}
+	return namespaceBarrierPrefix + ns.ID + "/" + systemBarrierPrefix
+}
+
+// namespaceSystemBarrierView returns the storage of the system backend of
+// the given namespace
+func (c *Core) namespaceSystemBarrierView(ns *namespace.Namespace) *BarrierView {
+	if ns.ID == namespace.RootNamespaceID {
+		return c.systemBarrierView
+	}
+	return NewBarrierView(c.barrier, namespaceSystemBarrierPrefix(ns))
+}
+
+// loadNamespaces is invoked as part of postUnseal to load the namespaces.
+// It must run before the mounts are loaded, since every mount belongs to a
+// namespace.
+func (c *Core) loadNamespaces() error {
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespacesByPath = radix.New()
+	c.namespacesByID = make(map[string]*namespace.Namespace)
+	c.namespacesByPath.Insert(namespace.RootNamespace.Path, namespace.RootNamespace)
+	c.namespacesByID[namespace.RootNamespaceID] = namespace.RootNamespace
+
+	keys, err := c.barrier.List(coreNamespacesPrefix)
+	if err != nil {
+		c.logger.Error("core: failed to list namespaces", "error", err)
+		return errLoadMountsFailed
+	}
+
+	for _, key := range keys {
+		raw, err := c.barrier.Get(coreNamespacesPrefix + key)
+		if err != nil {
+			c.logger.Error("core: failed to read namespace", "id", key, "error", err)
+			return errLoadMountsFailed
+		}
+		if raw == nil {
+			continue
+		}
+
+		ns := new(namespace.Namespace)
+		if err := jsonutil.DecodeJSON(raw.Value, ns); err != nil {
+			c.logger.Error("core: failed to decode namespace", "id", key, "error", err)
+			return errLoadMountsFailed
+		}
+
+		c.namespacesByPath.Insert(ns.Path, ns)
+		c.namespacesByID[ns.ID] = ns
+	}
+
+	return nil
+}
+
+// unloadNamespaces is used before sealing to drop the namespaces
+func (c *Core) unloadNamespaces() {
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	c.namespacesByPath = nil
+	c.namespacesByID = nil
+}
+
+// namespaceByPath returns the namespace that holds the given API path,
+// which is the namespace with the longest matching path. The root
+// namespace holds every path that isn't in a child namespace.
+func (c *Core) namespaceByPath(path string) *namespace.Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	if c.namespacesByPath == nil {
+		return namespace.RootNamespace
+	}
+	_, raw, ok := c.namespacesByPath.LongestPrefix(path)
+	if !ok {
+		return namespace.RootNamespace
+	}
+	return raw.(*namespace.Namespace)
+}
+
+// namespaceByID returns the namespace with the given ID, or nil if there is
+// no such namespace. The empty ID refers to the root namespace.
+func (c *Core) namespaceByID(id string) *namespace.Namespace {
+	if id == "" || id == namespace.RootNamespaceID {
+		return namespace.RootNamespace
+	}
+
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	return c.namespacesByID[id]
+}
+
+// listNamespaces returns the direct children of the given namespace, sorted
+// by path
+func (c *Core) listNamespaces(parent *namespace.Namespace) []*namespace.Namespace {
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+
+	var children []*namespace.Namespace
+	if c.namespacesByPath == nil {
+		return children
+	}
+	c.namespacesByPath.WalkPrefix(parent.Path, func(path string, raw interface{}) bool {
+		rel := parent.TrimmedPath(path)
+		if rel != "" && strings.Index(rel, "/") == len(rel)-1 {
+			children = append(children, raw.(*namespace.Namespace))
+		}
+		return false
+	})
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Path < children[j].Path
+	})
+	return children
+}
+
+// namespaceIdentityStore returns the identity store of the given namespace,
+// or nil if it isn't mounted
+func (c *Core) namespaceIdentityStore(ns *namespace.Namespace) *IdentityStore {
+	if ns.ID == namespace.RootNamespaceID {
+		return c.identityStore
+	}
+	is, _ := c.router.MatchingBackend(ns.Path + "identity/").(*IdentityStore)
+	return is
+}
+
+// createNamespace creates a new namespace with the given name as a child of
+// the given namespace. The new namespace gets its own default policy, the
+// required mounts and the token auth method.
+func (c *Core) createNamespace(parent *namespace.Namespace, name string) (*namespace.Namespace, error) {
+	switch {
+	case name == "":
+		return nil, logical.CodedError(400, "missing namespace name")
+	case strings.Contains(name, "/"):
+		return nil, logical.CodedError(400, "namespace names cannot contain '/'; nested namespaces must be created from within their parent")
+	case !namespaceNameRegex.MatchString(name):
+		return nil, logical.CodedError(400, fmt.Sprintf("invalid namespace name %q", name))
+	case strutil.StrListContains(reservedNamespaceNames, strings.ToLower(name)):
+		return nil, logical.CodedError(400, fmt.Sprintf("namespace name %q is reserved", name))
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	ns := &namespace.Namespace{
+		ID:   id,
+		Path: parent.Path + name + "/",
+	}
+
+	if err := c.registerNamespace(ns); err != nil {
+		return nil, err
+	}
+
+	if err := c.setupNamespace(ns); err != nil {
+		c.logger.Error("core: failed to set up namespace", "path", ns.Path, "error", err)
+		if err := c.deleteNamespace(ns); err != nil {
+			c.logger.Error("core: failed to clean up namespace", "path", ns.Path, "error", err)
+		}
+		return nil, err
+	}
+
+	if c.logger.IsInfo() {
+		c.logger.Info("core: successfully created namespace", "path", ns.Path)
+	}
+	return ns, nil
+}
+
+// registerNamespace verifies that the given namespace doesn't conflict with
+// an existing namespace or mount, then persists it. Both checks happen while
+// holding the locks so that concurrent requests can't create conflicts.
+func (c *Core) registerNamespace(ns *namespace.Namespace) error {
+	c.mountsLock.RLock()
+	defer c.mountsLock.RUnlock()
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	if _, ok := c.namespacesByPath.Get(ns.Path); ok {
+		return logical.CodedError(409, fmt.Sprintf("existing namespace at %s", ns.Path))
+	}
+	for _, entry := range c.mounts.Entries {
+		path := entry.APIPath()
+		if strings.HasPrefix(path, ns.Path) || strings.HasPrefix(ns.Path, path) {
+			return logical.CodedError(409, fmt.Sprintf("existing mount at %s", path))
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(coreNamespacesPrefix+ns.ID, ns)
+	if err != nil {
+		return err
+	}
+	if err := c.barrier.Put(&Entry{Key: entry.Key, Value: entry.Value}); err != nil {
+		c.logger.Error("core: failed to persist namespace", "path", ns.Path, "error", err)
+		return logical.CodedError(500, "failed to persist namespace")
+	}
+	c.namespacesByPath.Insert(ns.Path, ns)
+	c.namespacesByID[ns.ID] = ns
+
+	return nil
+}
+
+// setupNamespace creates the default policy, the required mounts and the
+// token auth method of a new namespace
+func (c *Core) setupNamespace(ns *namespace.Namespace) error {
+	if err := c.policyStore.createDefaultPolicy(ns); err != nil {
+		return err
+	}
+
+	for _, entry := range c.requiredMountTable().Entries {
+		entry.NamespaceID = ns.ID
+		entry.namespace = ns
+		if err := c.mountInternal(entry); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to mount %q: {{err}}", entry.Path), err)
+		}
+	}
+
+	for _, entry := range c.defaultAuthTable().Entries {
+		entry.NamespaceID = ns.ID
+		entry.namespace = ns
+		if err := c.enableCredentialInternal(entry); err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to enable %q auth method: {{err}}", entry.Path), err)
+		}
+	}
+
+	return nil
+}
+
+// deleteNamespace revokes all leases and tokens of the given namespace,
+// removes all of its mounts and data, and finally the namespace itself.
+// Namespaces with children cannot be deleted.
+func (c *Core) deleteNamespace(ns *namespace.Namespace) error {
+	if ns.ID == namespace.RootNamespaceID {
+		return logical.CodedError(400, "cannot delete the root namespace")
+	}
+	if children := c.listNamespaces(ns); len(children) > 0 {
+		return logical.CodedError(400, fmt.Sprintf("namespace %q has child namespaces, which must be deleted first", ns.Path))
+	}
+
+	// Revoke every lease and token issued within the namespace
+	if err := c.expiration.RevokePrefix(ns.Path); err != nil {
+		c.logger.Error("core: failed to revoke namespace leases", "path", ns.Path, "error", err)
+		return logical.CodedError(500, "failed to revoke namespace leases")
+	}
+
+	// Disable the auth methods first so that no new tokens can be issued
+	// while the mounts are removed
+	var paths []string
+	c.authLock.RLock()
+	for _, entry := range c.auth.Entries {
+		if entry.Namespace().ID == ns.ID {
+			paths = append(paths, entry.Path)
+		}
+	}
+	c.authLock.RUnlock()
+	for _, path := range paths {
+		if err := c.disableCredentialInternal(ns, path); err != nil {
+			return err
+		}
+	}
+
+	paths = nil
+	c.mountsLock.RLock()
+	for _, entry := range c.mounts.Entries {
+		if entry.Namespace().ID == ns.ID {
+			paths = append(paths, entry.Path)
+		}
+	}
+	c.mountsLock.RUnlock()
+	for _, path := range paths {
+		if err := c.unmountInternal(ns, path); err != nil {
+			return err
+		}
+	}
+
+	// Clear the remaining storage of the namespace, such as its policies
+	view := NewBarrierView(c.barrier, namespaceBarrierPrefix+ns.ID+"/")
+	if err := logical.ClearView(view); err != nil {
+		c.logger.Error("core: failed to clear namespace storage", "path", ns.Path, "error", err)
+		return logical.CodedError(500, "failed to clear namespace storage")
+	}
+	c.policyStore.invalidateNamespace(ns)
+
+	c.namespacesLock.Lock()
+	defer c.namespacesLock.Unlock()
+
+	if err := c.barrier.Delete(coreNamespacesPrefix + ns.ID); err != nil {
+		c.logger.Error("core: failed to delete namespace", "path", ns.Path, "error", err)
+		return logical.CodedError(500, "failed to delete namespace")
+	}
+	c.namespacesByPath.Delete(ns.Path)
+	delete(c.namespacesByID, ns.ID)
+
+	if c.logger.IsInfo() {
+		c.logger.Info("core: successfully deleted namespace", "path", ns.Path)
+	}
+	return nil
+}
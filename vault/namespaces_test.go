@@ -0,0 +1,292 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testNamespaceRequest performs the request with the given token and fails
+// the test on error
+func testNamespaceRequest(t *testing.T, c *Core, token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	req.Data = data
+	resp, err := c.HandleRequest(req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("%s %s: err: %v, resp: %#v", op, path, err, resp)
+	}
+	return resp
+}
+
+func TestCore_Namespaces_Create(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	resp := testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	if resp.Data["path"] != "ns1/" || resp.Data["id"] == "" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Nested namespaces are created from within their parent
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/namespaces/child", nil)
+	resp = testNamespaceRequest(t, c, root, logical.ReadOperation, "sys/namespaces/ns1/child", nil)
+	if resp.Data["path"] != "ns1/child/" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = testNamespaceRequest(t, c, root, logical.ListOperation, "sys/namespaces", nil)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{"ns1/"}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The namespace gets its own required mounts and token auth method
+	resp = testNamespaceRequest(t, c, root, logical.ReadOperation, "ns1/sys/mounts", nil)
+	var mounts []string
+	for path := range resp.Data {
+		mounts = append(mounts, path)
+	}
+	sort.Strings(mounts)
+	if !reflect.DeepEqual(mounts, []string{"cubbyhole/", "identity/", "sys/"}) {
+		t.Fatalf("bad: %#v", mounts)
+	}
+	resp = testNamespaceRequest(t, c, root, logical.ReadOperation, "ns1/sys/auth", nil)
+	if len(resp.Data) != 1 || resp.Data["token/"] == nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Reserved names and existing namespaces are rejected
+	for _, path := range []string{"sys/namespaces/sys", "sys/namespaces/ns1", "sys/namespaces/secret/foo"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.ClientToken = root
+		if _, err := c.HandleRequest(req); err == nil {
+			t.Fatalf("expected error creating %s", path)
+		}
+	}
+
+	// Namespaces survive a seal and unseal
+	ns := c.namespaceByPath("ns1/child/foo")
+	if ns.Path != "ns1/child/" {
+		t.Fatalf("bad: %#v", ns)
+	}
+	if err := c.preSeal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.postUnseal(); err != nil {
+		t.Fatal(err)
+	}
+	if actual := c.namespaceByPath("ns1/child/foo"); !reflect.DeepEqual(actual, ns) {
+		t.Fatalf("expected %#v, got %#v", ns, actual)
+	}
+	testNamespaceRequest(t, c, root, logical.ReadOperation, "ns1/child/sys/mounts", nil)
+}
+
+func TestCore_Namespaces_Isolation(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/mounts/secret", map[string]interface{}{
+		"type": "kv",
+	})
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/policy/reader", map[string]interface{}{
+		"policy": `path "secret/*" { capabilities = ["read", "create", "update"] }`,
+	})
+
+	// Policies are scoped to their namespace
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/policy/reader")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil || resp != nil {
+		t.Fatalf("expected no root policy, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Tokens created in a namespace get the policies of the namespace
+	resp = testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/auth/token/create", map[string]interface{}{
+		"policies": []string{"reader"},
+	})
+	token := resp.Auth.ClientToken
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te.NamespaceID != c.namespaceByPath("ns1/").ID || te.Parent != "" {
+		t.Fatalf("bad: %#v", te)
+	}
+
+	testNamespaceRequest(t, c, token, logical.UpdateOperation, "ns1/secret/foo", map[string]interface{}{
+		"foo": "bar",
+	})
+	resp = testNamespaceRequest(t, c, token, logical.ReadOperation, "ns1/secret/foo", nil)
+	if resp.Data["foo"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The token has no access outside of its namespace
+	for _, path := range []string{"secret/foo", "sys/mounts", "ns1/sys/mounts"} {
+		req := logical.TestRequest(t, logical.ReadOperation, path)
+		req.ClientToken = token
+		if _, err := c.HandleRequest(req); err == nil || !errwrap.Contains(err, logical.ErrPermissionDenied.Error()) {
+			t.Fatalf("%s: expected permission denied, got %v", path, err)
+		}
+	}
+
+	// Root tokens only exist in the root namespace
+	req = logical.TestRequest(t, logical.UpdateOperation, "ns1/auth/token/create")
+	req.ClientToken = root
+	req.Data["policies"] = []string{"root"}
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatal("expected error creating a root token in a namespace")
+	}
+
+	// Paths concerning all of Vault are not available in namespaces
+	req = logical.TestRequest(t, logical.ReadOperation, "ns1/sys/audit")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatal("expected error reading the audit table in a namespace")
+	}
+}
+
+func TestCore_Namespaces_TuneOptions(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/mounts/secret", map[string]interface{}{
+		"type": "kv",
+	})
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "secret/foo", map[string]interface{}{
+		"foo": "root",
+	})
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/secret/foo", map[string]interface{}{
+		"foo": "ns1",
+	})
+
+	rootBackend := c.router.MatchingBackend("secret/")
+	nsBackend := c.router.MatchingBackend("ns1/secret/")
+	if rootBackend == nil || nsBackend == nil || rootBackend == nsBackend {
+		t.Fatalf("expected distinct backends, got %#v and %#v", rootBackend, nsBackend)
+	}
+
+	// Tuning the options of the mount of the namespace only reloads it
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/mounts/secret/tune", map[string]interface{}{
+		"options": map[string]interface{}{
+			"version": "2",
+		},
+	})
+	if c.router.MatchingBackend("secret/") != rootBackend {
+		t.Fatal("expected the backend of the root namespace to be left alone")
+	}
+	if c.router.MatchingBackend("ns1/secret/") == nsBackend {
+		t.Fatal("expected the backend of the namespace to be reloaded")
+	}
+
+	resp := testNamespaceRequest(t, c, root, logical.ReadOperation, "ns1/secret/data/foo", nil)
+	if resp == nil || resp.Data["data"].(map[string]interface{})["foo"] != "ns1" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	resp = testNamespaceRequest(t, c, root, logical.ReadOperation, "secret/foo", nil)
+	if resp == nil || resp.Data["foo"] != "root" {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestCore_Namespaces_PolicyInvalidation(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/policy/reader", map[string]interface{}{
+		"policy": `path "root/*" { capabilities = ["read"] }`,
+	})
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/policy/reader", map[string]interface{}{
+		"policy": `path "before/*" { capabilities = ["read"] }`,
+	})
+	ns := c.namespaceByPath("ns1/")
+
+	// The second store stands in for the one of a standby, which caches the
+	// policy and is only told about changes through invalidation
+	other := NewPolicyStore(c.systemBarrierView, &dynamicSystemView{core: c})
+	p, err := other.GetPolicy(ns, "reader", PolicyTypeACL)
+	if err != nil || p == nil || p.Raw != `path "before/*" { capabilities = ["read"] }` {
+		t.Fatalf("bad: err: %v, policy: %#v", err, p)
+	}
+
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/policy/reader", map[string]interface{}{
+		"policy": `path "after/*" { capabilities = ["read"] }`,
+	})
+	p, err = other.GetPolicy(ns, "reader", PolicyTypeACL)
+	if err != nil || p == nil || p.Raw != `path "before/*" { capabilities = ["read"] }` {
+		t.Fatalf("expected the cached policy before invalidation, got err: %v, policy: %#v", err, p)
+	}
+
+	// Invalidations of the storage of the namespace reach its system backend
+	sys, ok := c.router.MatchingBackend("ns1/sys/").(*SystemBackend)
+	if !ok {
+		t.Fatal("expected the system backend of the namespace")
+	}
+	c.policyStore, other = other, c.policyStore
+	sys.invalidate(policyACLSubPath + "reader")
+	c.policyStore, other = other, c.policyStore
+
+	p, err = other.GetPolicy(ns, "reader", PolicyTypeACL)
+	if err != nil || p == nil || p.Raw != `path "after/*" { capabilities = ["read"] }` {
+		t.Fatalf("expected the updated policy after invalidation, got err: %v, policy: %#v", err, p)
+	}
+	p, err = other.GetPolicy(namespace.RootNamespace, "reader", PolicyTypeACL)
+	if err != nil || p == nil || p.Raw != `path "root/*" { capabilities = ["read"] }` {
+		t.Fatalf("expected the root policy to be unchanged, got err: %v, policy: %#v", err, p)
+	}
+}
+
+func TestCore_Namespaces_Delete(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/namespaces/child", nil)
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/mounts/secret", map[string]interface{}{
+		"type": "kv",
+	})
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/sys/policy/reader", map[string]interface{}{
+		"policy": `path "secret/*" { capabilities = ["read"] }`,
+	})
+	resp := testNamespaceRequest(t, c, root, logical.UpdateOperation, "ns1/auth/token/create", map[string]interface{}{
+		"policies": []string{"reader"},
+	})
+	token := resp.Auth.ClientToken
+
+	// Namespaces with children cannot be deleted
+	req := logical.TestRequest(t, logical.DeleteOperation, "sys/namespaces/ns1")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatal("expected error deleting a namespace with children")
+	}
+
+	testNamespaceRequest(t, c, root, logical.DeleteOperation, "ns1/sys/namespaces/child", nil)
+	testNamespaceRequest(t, c, root, logical.DeleteOperation, "sys/namespaces/ns1", nil)
+
+	// The mounts, tokens and policies of the namespace are gone
+	if match := c.router.MatchingMount("ns1/secret/foo"); match != "" {
+		t.Fatalf("expected no mount, got %q", match)
+	}
+	te, err := c.tokenStore.Lookup(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te != nil {
+		t.Fatalf("expected token to be revoked, got %#v", te)
+	}
+	keys, err := logical.CollectKeys(NewBarrierView(c.barrier, namespaceBarrierPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("expected no namespace storage, got %v", keys)
+	}
+	if ns := c.namespaceByPath("ns1/"); ns.ID != namespace.RootNamespaceID {
+		t.Fatalf("bad: %#v", ns)
+	}
+
+	// The name can be used again
+	testNamespaceRequest(t, c, root, logical.UpdateOperation, "sys/namespaces/ns1", nil)
+}
@@ -30,7 +30,7 @@ func (c *Core) reloadMatchingPluginMounts(mounts []string) error {
 		}
 
 		if entry.Type == "plugin" {
-			err := c.reloadPluginCommon(entry, isAuth)
+			err := c.reloadBackendCommon(entry, isAuth)
 			if err != nil {
 				errors = multierror.Append(errors, fmt.Errorf("cannot reload plugin on %s: %v", mount, err))
 				continue
@@ -51,7 +51,7 @@ func (c *Core) reloadMatchingPlugin(pluginName string) error {
 	// Filter mount entries that only matches the plugin name
 	for _, entry := range c.mounts.Entries {
 		if entry.Config.PluginName == pluginName && entry.Type == "plugin" {
-			err := c.reloadPluginCommon(entry, false)
+			err := c.reloadBackendCommon(entry, false)
 			if err != nil {
 				return err
 			}
@@ -62,7 +62,7 @@ func (c *Core) reloadMatchingPlugin(pluginName string) error {
 	// Filter auth mount entries that ony matches the plugin name
 	for _, entry := range c.auth.Entries {
 		if entry.Config.PluginName == pluginName && entry.Type == "plugin" {
-			err := c.reloadPluginCommon(entry, true)
+			err := c.reloadBackendCommon(entry, true)
 			if err != nil {
 				return err
 			}
@@ -73,11 +73,16 @@ func (c *Core) reloadMatchingPlugin(pluginName string) error {
 	return nil
 }
 
-// reloadPluginCommon is a generic method to reload a backend provided a
-// MountEntry. entry.Type should be checked by the caller to ensure that
-// it's a "plugin" type.
-func (c *Core) reloadPluginCommon(entry *MountEntry, isAuth bool) error {
-	path := entry.Path
+// reloadBackendCommon is a generic method to reload a backend provided a
+// MountEntry. It is used both to reload plugins and to pick up changes to a
+// mount's options. The caller must hold the relevant mount table lock.
+func (c *Core) reloadBackendCommon(entry *MountEntry, isAuth bool) error {
+	// Mounts are routed under the path of their namespace, so that mounts
+	// at the same path in different namespaces are not confused
+	path := entry.APIPath()
+	if isAuth {
+		path = entry.Namespace().Path + credentialRoutePrefix + entry.Path
+	}
 
 	// Fast-path out if the backend doesn't exist
 	raw, ok := c.router.root.Get(path)
@@ -96,12 +101,15 @@ func (c *Core) reloadPluginCommon(entry *MountEntry, isAuth bool) error {
 	if entry.Config.PluginName != "" {
 		conf["plugin_name"] = entry.Config.PluginName
 	}
+	for k, v := range entry.Options {
+		conf[k] = v
+	}
 
 	var backend logical.Backend
 	var err error
 	if !isAuth {
 		// Dispense a new backend
-		backend, err = c.newLogicalBackend(entry.Type, sysView, view, conf)
+		backend, err = c.newLogicalBackend(entry.Type, entry.Path, sysView, view, conf)
 	} else {
 		backend, err = c.newCredentialBackend(entry.Type, sysView, view, conf)
 	}
@@ -10,6 +10,8 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/mitchellh/copystructure"
 )
@@ -84,6 +86,16 @@ type Policy struct {
 	Paths []*PathRules `hcl:"-"`
 	Raw   string
 	Type  PolicyType
+
+	// Templated is set if any of the paths of the policy contain identity
+	// templates, such as {{identity.entity.name}}. The templates of these
+	// paths are resolved using the identity of the token each time an ACL
+	// is built.
+	Templated bool `hcl:"-"`
+
+	// namespace is the namespace the policy belongs to. The paths of the
+	// policy are relative to it.
+	namespace *namespace.Namespace
 }
 
 // PathRules represents a policy for a path in the namespace.
@@ -94,6 +106,10 @@ type PathRules struct {
 	Glob         bool
 	Capabilities []string
 
+	// Templated is set if the prefix contains identity templates, which are
+	// kept unresolved until an ACL is built for a given identity
+	Templated bool `hcl:"-"`
+
 	// These keys are used at the top level to make the HCL nicer; we store in
 	// the ACLPermissions object though
 	MinWrappingTTLHCL    interface{}              `hcl:"min_wrapping_ttl"`
@@ -146,7 +162,8 @@ func (p *ACLPermissions) Clone() (*ACLPermissions, error) {
 
 // Parse is used to parse the specified ACL rules into an
 // intermediary set of policies, before being compiled into
-// the ACL
+// the ACL. The identity templates in the paths are only checked for
+// validity; they are resolved when building an ACL.
 func ParseACLPolicy(rules string) (*Policy, error) {
 	// Parse the rules
 	root, err := hcl.Parse(rules)
@@ -226,6 +243,21 @@ func parsePaths(result *Policy, list *ast.ObjectList) error {
 			pc.Glob = true
 		}
 
+		// Check the identity templates in the path. This is done after
+		// stripping the glob character so that a templated value can't turn
+		// the path into a glob.
+		hasTemplating, _, err := identity.PopulateString(&identity.PopulateStringInput{
+			ValidityCheckOnly: true,
+			String:            pc.Prefix,
+		})
+		if err != nil {
+			return fmt.Errorf("path %q: invalid templating: %v", key, err)
+		}
+		if hasTemplating {
+			result.Templated = true
+			pc.Templated = true
+		}
+
 		// Map old-style policies into capabilities
 		if len(pc.Policy) > 0 {
 			switch pc.Policy {
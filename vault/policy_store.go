@@ -10,6 +10,8 @@ import (
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/golang-lru"
 	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 )
@@ -126,6 +128,9 @@ var (
 // PolicyStore is used to provide durable storage of policy, and to
 // manage ACLs associated with them.
 type PolicyStore struct {
+	// barrier is used to build the views of the policies of child
+	// namespaces, aclView holds the policies of the root namespace
+	barrier          BarrierStorage
 	aclView          *BarrierView
 	tokenPoliciesLRU *lru.TwoQueueCache
 	// This is used to ensure that writes to the store (acl/rgp) or to the egp
@@ -147,6 +152,7 @@ type PolicyEntry struct {
 // using a given view. It used used to durable store and manage named policy.
 func NewPolicyStore(baseView *BarrierView, system logical.SystemView) *PolicyStore {
 	ps := &PolicyStore{
+		barrier:    baseView.barrier,
 		aclView:    baseView.SubView(policyACLSubPath),
 		modifyLock: new(sync.RWMutex),
 	}
@@ -180,25 +186,37 @@ func (c *Core) setupPolicyStore() error {
 		return nil
 	}
 
+	// Load the types of the policies of the child namespaces
+	c.namespacesLock.RLock()
+	defer c.namespacesLock.RUnlock()
+	for _, ns := range c.namespacesByID {
+		if ns.ID == namespace.RootNamespaceID {
+			continue
+		}
+		if err := c.policyStore.loadNamespacePolicyTypes(ns); err != nil {
+			return err
+		}
+	}
+
 	// Ensure that the default policy exists, and if not, create it
-	policy, err := c.policyStore.GetPolicy("default", PolicyTypeACL)
+	policy, err := c.policyStore.GetPolicy(namespace.RootNamespace, "default", PolicyTypeACL)
 	if err != nil {
 		return errwrap.Wrapf("error fetching default policy from store: {{err}}", err)
 	}
 	if policy == nil {
-		err := c.policyStore.createDefaultPolicy()
+		err := c.policyStore.createDefaultPolicy(namespace.RootNamespace)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Ensure that the cubbyhole response wrapping policy exists
-	policy, err = c.policyStore.GetPolicy(responseWrappingPolicyName, PolicyTypeACL)
+	policy, err = c.policyStore.GetPolicy(namespace.RootNamespace, responseWrappingPolicyName, PolicyTypeACL)
 	if err != nil {
 		return errwrap.Wrapf("error fetching response-wrapping policy from store: {{err}}", err)
 	}
 	if policy == nil || policy.Raw != responseWrappingPolicy {
-		err := c.policyStore.createResponseWrappingPolicy()
+		err := c.policyStore.createResponseWrappingPolicy(namespace.RootNamespace)
 		if err != nil {
 			return err
 		}
@@ -214,7 +232,58 @@ func (c *Core) teardownPolicyStore() error {
 	return nil
 }
 
-func (ps *PolicyStore) invalidate(name string, policyType PolicyType) {
+// loadNamespacePolicyTypes stores the types of the policies of the given
+// namespace, so that they can be found when building ACLs of tokens.
+func (ps *PolicyStore) loadNamespacePolicyTypes(ns *namespace.Namespace) error {
+	keys, err := logical.CollectKeys(ps.aclViewByNamespace(ns))
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error collecting acl policy keys of namespace %q: {{err}}", ns.Path), err)
+	}
+	for _, key := range keys {
+		ps.policyTypeMap.Store(ps.cacheKey(ns, ps.sanitizeName(key)), PolicyTypeACL)
+	}
+	return nil
+}
+
+// invalidateNamespace drops all cached policies of the given namespace. It
+// is used when the namespace is deleted.
+func (ps *PolicyStore) invalidateNamespace(ns *namespace.Namespace) {
+	prefix := ps.cacheKey(ns, "")
+	ps.policyTypeMap.Range(func(key, value interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			ps.policyTypeMap.Delete(key)
+		}
+		return true
+	})
+	if ps.tokenPoliciesLRU != nil {
+		for _, key := range ps.tokenPoliciesLRU.Keys() {
+			if strings.HasPrefix(key.(string), prefix) {
+				ps.tokenPoliciesLRU.Remove(key)
+			}
+		}
+	}
+}
+
+// aclViewByNamespace returns the storage of the ACL policies of the given
+// namespace
+func (ps *PolicyStore) aclViewByNamespace(ns *namespace.Namespace) *BarrierView {
+	if ns.ID == namespace.RootNamespaceID {
+		return ps.aclView
+	}
+	return NewBarrierView(ps.barrier, namespaceSystemBarrierPrefix(ns)+policyACLSubPath)
+}
+
+// cacheKey returns the key of the named policy in the cache and the type
+// map, which are shared by all namespaces. Policies of the root namespace
+// are keyed by their name only.
+func (ps *PolicyStore) cacheKey(ns *namespace.Namespace, name string) string {
+	if ns.ID == namespace.RootNamespaceID {
+		return name
+	}
+	return ns.ID + "/" + name
+}
+
+func (ps *PolicyStore) invalidate(ns *namespace.Namespace, name string, policyType PolicyType) {
 	// This may come with a prefixed "/" due to joining the file path
 	saneName := strings.TrimPrefix(name, "/")
 
@@ -223,7 +292,7 @@ func (ps *PolicyStore) invalidate(name string, policyType PolicyType) {
 	switch policyType {
 	case PolicyTypeACL:
 		if ps.tokenPoliciesLRU != nil {
-			ps.tokenPoliciesLRU.Remove(saneName)
+			ps.tokenPoliciesLRU.Remove(ps.cacheKey(ns, saneName))
 		}
 
 	default:
@@ -232,14 +301,14 @@ func (ps *PolicyStore) invalidate(name string, policyType PolicyType) {
 	}
 
 	// Force a reload
-	p, err := ps.GetPolicy(name, policyType)
+	p, err := ps.GetPolicy(ns, name, policyType)
 	if err != nil {
 		vlogger.Error("policy: error fetching policy after invalidation", "name", saneName)
 	}
 }
 
 // SetPolicy is used to create or update the given policy
-func (ps *PolicyStore) SetPolicy(p *Policy) error {
+func (ps *PolicyStore) SetPolicy(ns *namespace.Namespace, p *Policy) error {
 	defer metrics.MeasureSince([]string{"policy", "set_policy"}, time.Now())
 	if p == nil {
 		return fmt.Errorf("nil policy passed in for storage")
@@ -253,10 +322,10 @@ func (ps *PolicyStore) SetPolicy(p *Policy) error {
 		return fmt.Errorf("cannot update %s policy", p.Name)
 	}
 
-	return ps.setPolicyInternal(p)
+	return ps.setPolicyInternal(ns, p)
 }
 
-func (ps *PolicyStore) setPolicyInternal(p *Policy) error {
+func (ps *PolicyStore) setPolicyInternal(ns *namespace.Namespace, p *Policy) error {
 	ps.modifyLock.Lock()
 	defer ps.modifyLock.Unlock()
 	// Create the entry
@@ -277,14 +346,15 @@ func (ps *PolicyStore) setPolicyInternal(p *Policy) error {
 		if rgp != nil {
 			return fmt.Errorf("cannot reuse policy names between ACLs and RGPs")
 		}
-		if err := ps.aclView.Put(entry); err != nil {
+		if err := ps.aclViewByNamespace(ns).Put(entry); err != nil {
 			return errwrap.Wrapf("failed to persist policy: {{err}}", err)
 		}
-		ps.policyTypeMap.Store(p.Name, PolicyTypeACL)
+		p.namespace = ns
+		ps.policyTypeMap.Store(ps.cacheKey(ns, p.Name), PolicyTypeACL)
 
 		if ps.tokenPoliciesLRU != nil {
 			// Update the LRU cache
-			ps.tokenPoliciesLRU.Add(p.Name, p)
+			ps.tokenPoliciesLRU.Add(ps.cacheKey(ns, p.Name), p)
 		}
 
 	default:
@@ -294,22 +364,23 @@ func (ps *PolicyStore) setPolicyInternal(p *Policy) error {
 	return nil
 }
 
-// GetPolicy is used to fetch the named policy
-func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, error) {
+// GetPolicy is used to fetch the named policy of the given namespace
+func (ps *PolicyStore) GetPolicy(ns *namespace.Namespace, name string, policyType PolicyType) (*Policy, error) {
 	defer metrics.MeasureSince([]string{"policy", "get_policy"}, time.Now())
 
 	// Policies are normalized to lower-case
 	name = ps.sanitizeName(name)
+	key := ps.cacheKey(ns, name)
 
 	var cache *lru.TwoQueueCache
 	var view *BarrierView
 	switch policyType {
 	case PolicyTypeACL:
 		cache = ps.tokenPoliciesLRU
-		view = ps.aclView
+		view = ps.aclViewByNamespace(ns)
 	case PolicyTypeToken:
 		cache = ps.tokenPoliciesLRU
-		val, ok := ps.policyTypeMap.Load(name)
+		val, ok := ps.policyTypeMap.Load(key)
 		if !ok {
 			// Doesn't exist
 			return nil, nil
@@ -317,7 +388,7 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 		policyType = val.(PolicyType)
 		switch policyType {
 		case PolicyTypeACL:
-			view = ps.aclView
+			view = ps.aclViewByNamespace(ns)
 		default:
 			return nil, fmt.Errorf("invalid type of policy in type map: %s", policyType)
 		}
@@ -325,14 +396,17 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 
 	if cache != nil {
 		// Check for cached policy
-		if raw, ok := cache.Get(name); ok {
+		if raw, ok := cache.Get(key); ok {
 			return raw.(*Policy), nil
 		}
 	}
 
-	// Special case the root policy
+	// Special case the root policy, which only exists in the root namespace
 	if policyType == PolicyTypeACL && name == "root" {
-		p := &Policy{Name: "root"}
+		if ns.ID != namespace.RootNamespaceID {
+			return nil, nil
+		}
+		p := &Policy{Name: "root", namespace: ns}
 		if cache != nil {
 			cache.Add(p.Name, p)
 		}
@@ -344,7 +418,7 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 
 	// See if anything has added it since we got the lock
 	if cache != nil {
-		if raw, ok := cache.Get(name); ok {
+		if raw, ok := cache.Get(key); ok {
 			return raw.(*Policy), nil
 		}
 	}
@@ -368,6 +442,7 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 	// Set these up here so that they're available for loading into
 	// Sentinel
 	policy.Name = name
+	policy.namespace = ns
 	policy.Raw = policyEntry.Raw
 	policy.Type = policyEntry.Type
 	switch policyEntry.Type {
@@ -381,7 +456,7 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 		// Reset this in case they set the name in the policy itself
 		policy.Name = name
 
-		ps.policyTypeMap.Store(name, PolicyTypeACL)
+		ps.policyTypeMap.Store(key, PolicyTypeACL)
 
 	default:
 		return nil, fmt.Errorf("unknown policy type %q", policyEntry.Type.String())
@@ -389,14 +464,14 @@ func (ps *PolicyStore) GetPolicy(name string, policyType PolicyType) (*Policy, e
 
 	if cache != nil {
 		// Update the LRU cache
-		cache.Add(name, policy)
+		cache.Add(key, policy)
 	}
 
 	return policy, nil
 }
 
-// ListPolicies is used to list the available policies
-func (ps *PolicyStore) ListPolicies(policyType PolicyType) ([]string, error) {
+// ListPolicies is used to list the available policies of the given namespace
+func (ps *PolicyStore) ListPolicies(ns *namespace.Namespace, policyType PolicyType) ([]string, error) {
 	defer metrics.MeasureSince([]string{"policy", "list_policies"}, time.Now())
 	// Scan the view, since the policy names are the same as the
 	// key names.
@@ -404,7 +479,7 @@ func (ps *PolicyStore) ListPolicies(policyType PolicyType) ([]string, error) {
 	var err error
 	switch policyType {
 	case PolicyTypeACL:
-		keys, err = logical.CollectKeys(ps.aclView)
+		keys, err = logical.CollectKeys(ps.aclViewByNamespace(ns))
 	default:
 		return nil, fmt.Errorf("unknown policy type %s", policyType)
 	}
@@ -429,8 +504,8 @@ func (ps *PolicyStore) ListPolicies(policyType PolicyType) ([]string, error) {
 	return keys, err
 }
 
-// DeletePolicy is used to delete the named policy
-func (ps *PolicyStore) DeletePolicy(name string, policyType PolicyType) error {
+// DeletePolicy is used to delete the named policy of the given namespace
+func (ps *PolicyStore) DeletePolicy(ns *namespace.Namespace, name string, policyType PolicyType) error {
 	defer metrics.MeasureSince([]string{"policy", "delete_policy"}, time.Now())
 
 	ps.modifyLock.Lock()
@@ -448,29 +523,30 @@ func (ps *PolicyStore) DeletePolicy(name string, policyType PolicyType) error {
 			return fmt.Errorf("cannot delete default policy")
 		}
 
-		err := ps.aclView.Delete(name)
+		err := ps.aclViewByNamespace(ns).Delete(name)
 		if err != nil {
 			return errwrap.Wrapf("failed to delete policy: {{err}}", err)
 		}
 
 		if ps.tokenPoliciesLRU != nil {
 			// Clear the cache
-			ps.tokenPoliciesLRU.Remove(name)
+			ps.tokenPoliciesLRU.Remove(ps.cacheKey(ns, name))
 		}
 
-		ps.policyTypeMap.Delete(name)
+		ps.policyTypeMap.Delete(ps.cacheKey(ns, name))
 
 	}
 	return nil
 }
 
 // ACL is used to return an ACL which is built using the
-// named policies.
-func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
+// named policies of the given namespace. The templated paths of the policies
+// are resolved using the given entity and groups, which may be nil.
+func (ps *PolicyStore) ACL(ns *namespace.Namespace, entity *identity.Entity, groups []*identity.Group, names ...string) (*ACL, error) {
 	// Fetch the policies
 	var policies []*Policy
 	for _, name := range names {
-		p, err := ps.GetPolicy(name, PolicyTypeToken)
+		p, err := ps.GetPolicy(ns, name, PolicyTypeToken)
 		if err != nil {
 			return nil, errwrap.Wrapf("failed to get policy: {{err}}", err)
 		}
@@ -478,14 +554,14 @@ func (ps *PolicyStore) ACL(names ...string) (*ACL, error) {
 	}
 
 	// Construct the ACL
-	acl, err := NewACL(policies)
+	acl, err := NewACLWithIdentity(policies, entity, groups)
 	if err != nil {
 		return nil, errwrap.Wrapf("failed to construct ACL: {{err}}", err)
 	}
 	return acl, nil
 }
 
-func (ps *PolicyStore) createDefaultPolicy() error {
+func (ps *PolicyStore) createDefaultPolicy(ns *namespace.Namespace) error {
 	policy, err := ParseACLPolicy(defaultPolicy)
 	if err != nil {
 		return errwrap.Wrapf("error parsing default policy: {{err}}", err)
@@ -497,10 +573,10 @@ func (ps *PolicyStore) createDefaultPolicy() error {
 
 	policy.Name = "default"
 	policy.Type = PolicyTypeACL
-	return ps.setPolicyInternal(policy)
+	return ps.setPolicyInternal(ns, policy)
 }
 
-func (ps *PolicyStore) createResponseWrappingPolicy() error {
+func (ps *PolicyStore) createResponseWrappingPolicy(ns *namespace.Namespace) error {
 	policy, err := ParseACLPolicy(responseWrappingPolicy)
 	if err != nil {
 		return errwrap.Wrapf(fmt.Sprintf("error parsing %s policy: {{err}}", responseWrappingPolicyName), err)
@@ -512,7 +588,7 @@ func (ps *PolicyStore) createResponseWrappingPolicy() error {
 
 	policy.Name = responseWrappingPolicyName
 	policy.Type = PolicyTypeACL
-	return ps.setPolicyInternal(policy)
+	return ps.setPolicyInternal(ns, policy)
 }
 
 func (ps *PolicyStore) sanitizeName(name string) string {
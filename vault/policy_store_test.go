@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -27,7 +28,7 @@ func TestPolicyStore_Root(t *testing.T) {
 	ps := mockPolicyStore(t)
 
 	// Get should return a special policy
-	p, err := ps.GetPolicy("root", PolicyTypeToken)
+	p, err := ps.GetPolicy(namespace.RootNamespace, "root", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -39,13 +40,13 @@ func TestPolicyStore_Root(t *testing.T) {
 	}
 
 	// Set should fail
-	err = ps.SetPolicy(p)
+	err = ps.SetPolicy(namespace.RootNamespace, p)
 	if err.Error() != "cannot update root policy" {
 		t.Fatalf("err: %v", err)
 	}
 
 	// Delete should fail
-	err = ps.DeletePolicy("root", PolicyTypeACL)
+	err = ps.DeletePolicy(namespace.RootNamespace, "root", PolicyTypeACL)
 	if err.Error() != "cannot delete root policy" {
 		t.Fatalf("err: %v", err)
 	}
@@ -61,7 +62,7 @@ func TestPolicyStore_CRUD(t *testing.T) {
 
 func testPolicyStore_CRUD(t *testing.T, ps *PolicyStore) {
 	// Get should return nothing
-	p, err := ps.GetPolicy("Dev", PolicyTypeToken)
+	p, err := ps.GetPolicy(namespace.RootNamespace, "Dev", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -70,13 +71,13 @@ func testPolicyStore_CRUD(t *testing.T, ps *PolicyStore) {
 	}
 
 	// Delete should be no-op
-	err = ps.DeletePolicy("deV", PolicyTypeACL)
+	err = ps.DeletePolicy(namespace.RootNamespace, "deV", PolicyTypeACL)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// List should be blank
-	out, err := ps.ListPolicies(PolicyTypeACL)
+	out, err := ps.ListPolicies(namespace.RootNamespace, PolicyTypeACL)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -86,13 +87,13 @@ func testPolicyStore_CRUD(t *testing.T, ps *PolicyStore) {
 
 	// Set should work
 	policy, _ := ParseACLPolicy(aclPolicy)
-	err = ps.SetPolicy(policy)
+	err = ps.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// Get should work
-	p, err = ps.GetPolicy("dEv", PolicyTypeToken)
+	p, err = ps.GetPolicy(namespace.RootNamespace, "dEv", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -101,7 +102,7 @@ func testPolicyStore_CRUD(t *testing.T, ps *PolicyStore) {
 	}
 
 	// List should be one element
-	out, err = ps.ListPolicies(PolicyTypeACL)
+	out, err = ps.ListPolicies(namespace.RootNamespace, PolicyTypeACL)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -110,13 +111,13 @@ func testPolicyStore_CRUD(t *testing.T, ps *PolicyStore) {
 	}
 
 	// Delete should be clear the entry
-	err = ps.DeletePolicy("Dev", PolicyTypeACL)
+	err = ps.DeletePolicy(namespace.RootNamespace, "Dev", PolicyTypeACL)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
 	// Get should fail
-	p, err = ps.GetPolicy("deV", PolicyTypeToken)
+	p, err = ps.GetPolicy(namespace.RootNamespace, "deV", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -134,7 +135,7 @@ func TestPolicyStore_Predefined(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 	// List should be two elements
-	out, err := core.policyStore.ListPolicies(PolicyTypeACL)
+	out, err := core.policyStore.ListPolicies(namespace.RootNamespace, PolicyTypeACL)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -143,7 +144,7 @@ func TestPolicyStore_Predefined(t *testing.T) {
 		t.Fatalf("bad: %v", out)
 	}
 
-	pCubby, err := core.policyStore.GetPolicy("response-wrapping", PolicyTypeToken)
+	pCubby, err := core.policyStore.GetPolicy(namespace.RootNamespace, "response-wrapping", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -153,7 +154,7 @@ func TestPolicyStore_Predefined(t *testing.T) {
 	if pCubby.Raw != responseWrappingPolicy {
 		t.Fatalf("bad: expected\n%s\ngot\n%s\n", responseWrappingPolicy, pCubby.Raw)
 	}
-	pRoot, err := core.policyStore.GetPolicy("root", PolicyTypeToken)
+	pRoot, err := core.policyStore.GetPolicy(namespace.RootNamespace, "root", PolicyTypeToken)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -161,19 +162,19 @@ func TestPolicyStore_Predefined(t *testing.T) {
 		t.Fatal("nil root policy")
 	}
 
-	err = core.policyStore.SetPolicy(pCubby)
+	err = core.policyStore.SetPolicy(namespace.RootNamespace, pCubby)
 	if err == nil {
 		t.Fatalf("expected err setting %s", pCubby.Name)
 	}
-	err = core.policyStore.SetPolicy(pRoot)
+	err = core.policyStore.SetPolicy(namespace.RootNamespace, pRoot)
 	if err == nil {
 		t.Fatalf("expected err setting %s", pRoot.Name)
 	}
-	err = core.policyStore.DeletePolicy(pCubby.Name, PolicyTypeACL)
+	err = core.policyStore.DeletePolicy(namespace.RootNamespace, pCubby.Name, PolicyTypeACL)
 	if err == nil {
 		t.Fatalf("expected err deleting %s", pCubby.Name)
 	}
-	err = core.policyStore.DeletePolicy(pRoot.Name, PolicyTypeACL)
+	err = core.policyStore.DeletePolicy(namespace.RootNamespace, pRoot.Name, PolicyTypeACL)
 	if err == nil {
 		t.Fatalf("expected err deleting %s", pRoot.Name)
 	}
@@ -183,17 +184,17 @@ func TestPolicyStore_ACL(t *testing.T) {
 	ps := mockPolicyStore(t)
 
 	policy, _ := ParseACLPolicy(aclPolicy)
-	err := ps.SetPolicy(policy)
+	err := ps.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	policy, _ = ParseACLPolicy(aclPolicy2)
-	err = ps.SetPolicy(policy)
+	err = ps.SetPolicy(namespace.RootNamespace, policy)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
-	acl, err := ps.ACL("dev", "ops")
+	acl, err := ps.ACL(namespace.RootNamespace, nil, nil, "dev", "ops")
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
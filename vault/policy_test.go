@@ -312,3 +312,54 @@ path "/" {
 		t.Errorf("bad error: %s", err)
 	}
 }
+
+func TestPolicy_ParseBadTemplating(t *testing.T) {
+	_, err := ParseACLPolicy(strings.TrimSpace(`
+path "secret/{{identity.entity.name}" {
+	capabilities = ["read"]
+}
+`))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "invalid templating") {
+		t.Errorf("bad error: %s", err)
+	}
+
+	_, err = ParseACLPolicy(strings.TrimSpace(`
+path "secret/{{identity.banana}}" {
+	capabilities = ["read"]
+}
+`))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "invalid template directive") {
+		t.Errorf("bad error: %s", err)
+	}
+}
+
+func TestPolicy_ParseTemplated(t *testing.T) {
+	p, err := ParseACLPolicy(templatedPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !p.Templated {
+		t.Fatalf("expected the policy to be templated")
+	}
+
+	// Without templating, the paths are kept as written
+	if p.Paths[0].Prefix != "secret/users/{{identity.entity.name}}/" || !p.Paths[0].Glob || !p.Paths[0].Templated {
+		t.Fatalf("bad: %#v", p.Paths[0])
+	}
+
+	p, err = ParseACLPolicy(aclPolicy)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if p.Templated {
+		t.Fatalf("expected the policy not to be templated")
+	}
+}
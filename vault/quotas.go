@@ -0,0 +1,297 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// quotaSubPath is the sub-path of the system view holding the quotas
+	quotaSubPath = "quotas/"
+
+	quotaTypeRateLimit  = "rate-limit"
+	quotaTypeLeaseCount = "lease-count"
+
+	// rateLimitPurgeInterval is how long the rate limiter of a client is
+	// kept around after its last request
+	rateLimitPurgeInterval = time.Minute
+)
+
+// quotaExemptPaths are never rate limited, so that a misconfigured quota can
+// always be fixed
+var quotaExemptPaths = []string{
+	"sys/quotas/",
+}
+
+// Quota limits either the rate of requests or the number of leases under a
+// path. An empty path applies the quota globally; otherwise the path is a
+// mount or a path prefix within a mount. When several quotas of the same
+// type match a request, the most specific one applies.
+type Quota struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Path string `json:"path"`
+
+	// Rate, Interval and Burst configure rate limit quotas. Every client may
+	// make Rate requests per Interval, with bursts of up to Burst requests.
+	Rate     int           `json:"rate,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"`
+	Burst    int           `json:"burst,omitempty"`
+
+	// MaxLeases configures lease count quotas
+	MaxLeases int `json:"max_leases,omitempty"`
+
+	// limiters holds the rate limiter of each client, by remote address
+	limiters     map[string]*clientRateLimiter
+	limitersLock sync.Mutex
+	lastPurge    time.Time
+}
+
+type clientRateLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// allow reports whether the client may make a request now. If not, it
+// returns how long the client has to wait before retrying.
+func (q *Quota) allow(client string, now time.Time) (bool, time.Duration) {
+	q.limitersLock.Lock()
+	defer q.limitersLock.Unlock()
+
+	if q.limiters == nil {
+		q.limiters = make(map[string]*clientRateLimiter)
+	}
+
+	// Forget the clients that have been idle for a while
+	if now.Sub(q.lastPurge) > rateLimitPurgeInterval {
+		for addr, cl := range q.limiters {
+			if now.Sub(cl.lastSeen) > rateLimitPurgeInterval {
+				delete(q.limiters, addr)
+			}
+		}
+		q.lastPurge = now
+	}
+
+	cl, ok := q.limiters[client]
+	if !ok {
+		cl = &clientRateLimiter{
+			limiter: rate.NewLimiter(rate.Every(q.Interval/time.Duration(q.Rate)), q.Burst),
+		}
+		q.limiters[client] = cl
+	}
+	cl.lastSeen = now
+
+	r := cl.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// QuotaManager holds the rate limit and lease count quotas and applies them
+// to requests
+type QuotaManager struct {
+	core *Core
+	view *BarrierView
+
+	// quotas holds the quotas of each type by name
+	quotas map[string]map[string]*Quota
+	lock   sync.RWMutex
+}
+
+// setupQuotas is invoked after we've loaded the mount table to load the
+// quotas
+func (c *Core) setupQuotas() error {
+	m := &QuotaManager{
+		core: c,
+		view: c.systemBarrierView.SubView(quotaSubPath),
+		quotas: map[string]map[string]*Quota{
+			quotaTypeRateLimit:  make(map[string]*Quota),
+			quotaTypeLeaseCount: make(map[string]*Quota),
+		},
+	}
+
+	for quotaType := range m.quotas {
+		names, err := m.view.List(quotaType + "/")
+		if err != nil {
+			c.logger.Error("core: failed to list quotas", "type", quotaType, "error", err)
+			return err
+		}
+		for _, name := range names {
+			raw, err := m.view.Get(quotaType + "/" + name)
+			if err != nil {
+				c.logger.Error("core: failed to read quota", "type", quotaType, "name", name, "error", err)
+				return err
+			}
+			if raw == nil {
+				continue
+			}
+			q := new(Quota)
+			if err := raw.DecodeJSON(q); err != nil {
+				c.logger.Error("core: failed to decode quota", "type", quotaType, "name", name, "error", err)
+				return err
+			}
+			m.quotas[quotaType][q.Name] = q
+		}
+	}
+
+	for _, q := range m.quotas[quotaTypeLeaseCount] {
+		c.expiration.trackLeaseCount(q.Path)
+	}
+	c.expiration.setQuotas(m)
+
+	c.metricsMutex.Lock()
+	defer c.metricsMutex.Unlock()
+	c.quotas = m
+	return nil
+}
+
+// teardownQuotas is used to drop the quotas before sealing
+func (c *Core) teardownQuotas() {
+	if c.expiration != nil {
+		c.expiration.setQuotas(nil)
+	}
+
+	c.metricsMutex.Lock()
+	defer c.metricsMutex.Unlock()
+	c.quotas = nil
+}
+
+// get returns the quota of the given type with the given name, or nil
+func (m *QuotaManager) get(quotaType, name string) *Quota {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.quotas[quotaType][name]
+}
+
+// list returns the names of the quotas of the given type
+func (m *QuotaManager) list(quotaType string) []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	names := make([]string, 0, len(m.quotas[quotaType]))
+	for name := range m.quotas[quotaType] {
+		names = append(names, name)
+	}
+	return names
+}
+
+// set persists the given quota, replacing any quota of the same type and name
+func (m *QuotaManager) set(q *Quota) error {
+	// Lease count quotas need the leases under their path to be counted
+	// before they apply
+	if q.Type == quotaTypeLeaseCount {
+		m.core.expiration.trackLeaseCount(q.Path)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, existing := range m.quotas[q.Type] {
+		if existing.Name != q.Name && existing.Path == q.Path {
+			return fmt.Errorf("quota %q already applies to path %q", existing.Name, q.Path)
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(q.Type+"/"+q.Name, q)
+	if err != nil {
+		return err
+	}
+	if err := m.view.Put(entry); err != nil {
+		return err
+	}
+	if old, ok := m.quotas[q.Type][q.Name]; ok && q.Type == quotaTypeLeaseCount && old.Path != q.Path {
+		m.core.expiration.untrackLeaseCount(old.Path)
+	}
+	m.quotas[q.Type][q.Name] = q
+	return nil
+}
+
+// delete removes the quota of the given type with the given name
+func (m *QuotaManager) delete(quotaType, name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.view.Delete(quotaType + "/" + name); err != nil {
+		return err
+	}
+	if q, ok := m.quotas[quotaType][name]; ok && quotaType == quotaTypeLeaseCount {
+		m.core.expiration.untrackLeaseCount(q.Path)
+	}
+	delete(m.quotas[quotaType], name)
+	return nil
+}
+
+// match returns the most specific quota of the given type that applies to
+// the given path, or nil if there is none
+func (m *QuotaManager) match(quotaType, path string) *Quota {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var match *Quota
+	for _, q := range m.quotas[quotaType] {
+		if !strings.HasPrefix(path, q.Path) {
+			continue
+		}
+		if match == nil || len(q.Path) > len(match.Path) {
+			match = q
+		}
+	}
+	return match
+}
+
+// applyRateLimit rejects the request if its client is over the rate limit
+// quota that applies to the request path
+func (m *QuotaManager) applyRateLimit(req *logical.Request) error {
+	for _, prefix := range quotaExemptPaths {
+		if strings.HasPrefix(req.Path, prefix) {
+			return nil
+		}
+	}
+
+	q := m.match(quotaTypeRateLimit, req.Path)
+	if q == nil {
+		return nil
+	}
+
+	var client string
+	if req.Connection != nil {
+		client = req.Connection.RemoteAddr
+	}
+	allowed, retryAfter := q.allow(client, time.Now())
+	if allowed {
+		return nil
+	}
+
+	metrics.IncrCounter([]string{"quota", "rate_limit", q.Name, "violation"}, 1)
+	return &logical.QuotaExceededError{
+		Err:        logical.ErrRateLimitQuotaExceeded,
+		RetryAfter: retryAfter,
+	}
+}
+
+// emitMetrics is invoked periodically to emit the state of the lease count
+// quotas
+func (m *QuotaManager) emitMetrics() {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, q := range m.quotas[quotaTypeLeaseCount] {
+		if m.core.expiration == nil {
+			return
+		}
+		count := m.core.expiration.countLeases(q.Path)
+		metrics.SetGauge([]string{"quota", "lease_count", q.Name, "counter"}, float32(count))
+		metrics.SetGauge([]string{"quota", "lease_count", q.Name, "max"}, float32(q.MaxLeases))
+	}
+}
@@ -0,0 +1,264 @@
+package vault
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestQuotas_RateLimit(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":     "secret/",
+		"rate":     1,
+		"interval": "1h",
+	}
+	if resp, err := c.HandleRequest(req); err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":     "secret",
+		"type":     quotaTypeRateLimit,
+		"path":     "secret/",
+		"rate":     1,
+		"interval": int64(3600),
+		"burst":    1,
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, resp.Data)
+	}
+
+	testRead := func(addr string) error {
+		req := logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = root
+		req.Connection = &logical.Connection{RemoteAddr: addr}
+		_, err := c.HandleRequest(req)
+		return err
+	}
+
+	if err := testRead("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	err = testRead("127.0.0.1")
+	if err == nil || !errwrap.Contains(err, logical.ErrRateLimitQuotaExceeded.Error()) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	quotaErr, ok := errwrap.GetType(err, new(logical.QuotaExceededError)).(*logical.QuotaExceededError)
+	if !ok || quotaErr.RetryAfter <= 0 {
+		t.Fatalf("expected retry after, got %#v", err)
+	}
+
+	// Clients are limited independently
+	if err := testRead("127.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Paths outside of the quota are not limited
+	for i := 0; i < 3; i++ {
+		req := logical.TestRequest(t, logical.ReadOperation, "cubbyhole/foo")
+		req.ClientToken = root
+		req.Connection = &logical.Connection{RemoteAddr: "127.0.0.1"}
+		if _, err := c.HandleRequest(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The quota survives a seal and unseal, but its state does not
+	if err := c.preSeal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.postUnseal(); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRead("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRead("127.0.0.1"); err == nil {
+		t.Fatal("expected rate limit error")
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "sys/quotas/rate-limit/secret")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRead("127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotas_RateLimit_Validation(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	for _, data := range []map[string]interface{}{
+		{"rate": 0},
+		{"rate": 1, "burst": -1},
+		{"rate": 1, "path": "nonexistent/"},
+	} {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/bad")
+		req.ClientToken = root
+		req.Data = data
+		if _, err := c.HandleRequest(req); err == nil {
+			t.Fatalf("expected error for %#v", data)
+		}
+	}
+
+	// Two quotas of the same type cannot apply to the same path
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/first")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{"rate": 10, "path": "secret/"}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/second")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{"rate": 10, "path": "secret/"}
+	if _, err := c.HandleRequest(req); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestQuotas_Match(t *testing.T) {
+	m := &QuotaManager{
+		quotas: map[string]map[string]*Quota{
+			quotaTypeRateLimit: {
+				"global": &Quota{Name: "global", Path: ""},
+				"mount":  &Quota{Name: "mount", Path: "secret/"},
+				"prefix": &Quota{Name: "prefix", Path: "secret/team-a/"},
+			},
+		},
+	}
+
+	cases := map[string]string{
+		"secret/team-a/foo": "prefix",
+		"secret/team-b/foo": "mount",
+		"cubbyhole/foo":     "global",
+	}
+	for path, expected := range cases {
+		if q := m.match(quotaTypeRateLimit, path); q == nil || q.Name != expected {
+			t.Fatalf("%s: expected %q, got %#v", path, expected, q)
+		}
+	}
+
+	if q := m.match(quotaTypeLeaseCount, "secret/foo"); q != nil {
+		t.Fatalf("bad: %#v", q)
+	}
+}
+
+func TestQuotas_LeaseCount(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/tokens")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":       "auth/token/",
+		"max_leases": 2,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	testCreate := func() (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+		req.ClientToken = root
+		req.Data = map[string]interface{}{
+			"ttl": "1h",
+		}
+		return c.HandleRequest(req)
+	}
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		resp, err := testCreate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, resp.Auth.ClientToken)
+	}
+	if count := c.expiration.countLeases("auth/token/"); count != 2 {
+		t.Fatalf("expected 2 leases, got %d", count)
+	}
+
+	_, err := testCreate()
+	if err == nil || !errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+		t.Fatalf("expected lease count error, got %v", err)
+	}
+	if count := c.expiration.countLeases("auth/token/"); count != 2 {
+		t.Fatalf("expected 2 leases, got %d", count)
+	}
+
+	// Revoking a token frees up its lease
+	req = logical.TestRequest(t, logical.UpdateOperation, "auth/token/revoke")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"token": tokens[0],
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCreate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotas_LeaseCount_Concurrent(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	// Leases that exist before the quota count against it
+	req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+	req.ClientToken = root
+	req.Data["ttl"] = "1h"
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/tokens")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":       "auth/token/",
+		"max_leases": 5,
+	}
+	if _, err := c.HandleRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if count := c.expiration.countLeases("auth/token/"); count != 1 {
+		t.Fatalf("expected 1 lease, got %d", count)
+	}
+
+	var wg sync.WaitGroup
+	var created int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+			req.ClientToken = root
+			req.Data["ttl"] = "1h"
+			if _, err := c.HandleRequest(req); err == nil {
+				atomic.AddInt32(&created, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created != 4 {
+		t.Fatalf("expected 4 tokens to be created, got %d", created)
+	}
+	if count := c.expiration.countLeases("auth/token/"); count != 5 {
+		t.Fatalf("expected 5 leases, got %d", count)
+	}
+}
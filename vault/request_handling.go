@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/helper/wrapping"
@@ -43,6 +45,10 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 		return logical.ErrorResponse("cannot write to a path ending in '/'"), nil
 	}
 
+	if err := c.quotas.applyRateLimit(req); err != nil {
+		return nil, err
+	}
+
 	var auth *logical.Auth
 	if c.router.LoginPath(req.Path) {
 		resp, auth, err = c.handleLoginRequest(req)
@@ -120,6 +126,9 @@ func (c *Core) HandleRequest(req *logical.Request) (resp *logical.Response, err
 func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, retAuth *logical.Auth, retErr error) {
 	defer metrics.MeasureSince([]string{"core", "handle_request"}, time.Now())
 
+	// The special paths below are relative to the namespace of the request
+	relPath := c.namespaceByPath(req.Path).TrimmedPath(req.Path)
+
 	// Validate the token
 	auth, te, ctErr := c.checkToken(req, false)
 	// We run this logic first because we want to decrement the use count even in the case of an error
@@ -236,8 +245,8 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 
 	// If there is a secret, we must register it with the expiration manager.
 	// We exclude renewal of a lease, since it does not need to be re-registered
-	if resp != nil && resp.Secret != nil && !strings.HasPrefix(req.Path, "sys/renew") &&
-		!strings.HasPrefix(req.Path, "sys/leases/renew") {
+	if resp != nil && resp.Secret != nil && !strings.HasPrefix(relPath, "sys/renew") &&
+		!strings.HasPrefix(relPath, "sys/leases/renew") {
 		// Get the SystemView for the mount
 		sysView := c.router.MatchingSystemView(req.Path)
 		if sysView == nil {
@@ -276,6 +285,12 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 		if registerLease {
 			leaseID, err := c.expiration.Register(req, resp)
 			if err != nil {
+				// A secret over its lease count quota has been revoked by the
+				// expiration manager already
+				if quotaErr := errwrap.GetType(err, new(logical.QuotaExceededError)); quotaErr != nil {
+					retErr = multierror.Append(retErr, quotaErr)
+					return nil, auth, retErr
+				}
 				c.logger.Error("core: failed to register lease", "request_path", req.Path, "error", err)
 				retErr = multierror.Append(retErr, ErrInternalError)
 				return nil, auth, retErr
@@ -287,8 +302,8 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 	// Only the token store is allowed to return an auth block, for any
 	// other request this is an internal error. We exclude renewal of a token,
 	// since it does not need to be re-registered
-	if resp != nil && resp.Auth != nil && !strings.HasPrefix(req.Path, "auth/token/renew") {
-		if !strings.HasPrefix(req.Path, "auth/token/") {
+	if resp != nil && resp.Auth != nil && !strings.HasPrefix(relPath, "auth/token/renew") {
+		if !strings.HasPrefix(relPath, "auth/token/") {
 			c.logger.Error("core: unexpected Auth response for non-token backend", "request_path", req.Path)
 			retErr = multierror.Append(retErr, ErrInternalError)
 			return nil, auth, retErr
@@ -305,6 +320,10 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 
 		if err := c.expiration.RegisterAuth(te.Path, resp.Auth); err != nil {
 			c.tokenStore.Revoke(te.ID)
+			if _, ok := err.(*logical.QuotaExceededError); ok {
+				retErr = multierror.Append(retErr, err)
+				return nil, auth, retErr
+			}
 			c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
 			retErr = multierror.Append(retErr, ErrInternalError)
 			return nil, auth, retErr
@@ -312,7 +331,7 @@ func (c *Core) handleRequest(req *logical.Request) (retResp *logical.Response, r
 	}
 
 	if resp != nil &&
-		req.Path == "cubbyhole/response" &&
+		relPath == "cubbyhole/response" &&
 		len(te.Policies) == 1 &&
 		te.Policies[0] == responseWrappingPolicyName {
 		resp.AddWarning("Reading from 'cubbyhole/response' is deprecated. Please use sys/wrapping/unwrap to unwrap responses, as it provides additional security checks and other benefits.")
@@ -341,9 +360,13 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 		return nil, nil, ErrInternalError
 	}
 
+	// Logins happen within the namespace of the request; the identities and
+	// the token are created in it
+	ns := c.namespaceByPath(req.Path)
+
 	// The token store uses authentication even when creating a new token,
 	// so it's handled in handleRequest. It should not be reached here.
-	if strings.HasPrefix(req.Path, "auth/token/") {
+	if strings.HasPrefix(ns.TrimmedPath(req.Path), "auth/token/") {
 		c.logger.Error("core: unexpected login request for token backend", "request_path", req.Path)
 		return nil, nil, ErrInternalError
 	}
@@ -409,10 +432,16 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 				return nil, nil, fmt.Errorf("missing name in alias")
 			}
 
+			identityStore := c.namespaceIdentityStore(ns)
+			if identityStore == nil {
+				c.logger.Error("core: identity store is unavailable", "namespace", ns.Path)
+				return nil, nil, ErrInternalError
+			}
+
 			var err error
 
 			// Check if an entity already exists for the given alias
-			entity, err = c.identityStore.EntityByAliasFactors(auth.Alias.MountAccessor, auth.Alias.Name, false)
+			entity, err = identityStore.EntityByAliasFactors(auth.Alias.MountAccessor, auth.Alias.Name, false)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -420,7 +449,7 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 			// If not, create one.
 			if entity == nil {
 				c.logger.Debug("core: creating a new entity", "alias", auth.Alias)
-				entity, err = c.identityStore.CreateEntity(auth.Alias)
+				entity, err = identityStore.CreateEntity(auth.Alias)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -430,6 +459,20 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 			}
 
 			auth.EntityID = entity.ID
+
+			// Update the memberships of the external groups that are
+			// managed by this backend
+			if auth.GroupAliases != nil {
+				for _, groupAlias := range auth.GroupAliases {
+					groupAlias.MountType = req.MountType
+					groupAlias.MountAccessor = req.MountAccessor
+				}
+
+				err = identityStore.refreshExternalGroupMembershipsByEntityID(auth.EntityID, req.MountAccessor, auth.GroupAliases)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
 		}
 
 		if strutil.StrListSubset(auth.Policies, []string{"root"}) {
@@ -437,7 +480,7 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 		}
 
 		// Determine the source of the login
-		source := c.router.MatchingMount(req.Path)
+		source := ns.TrimmedPath(c.router.MatchingMount(req.Path))
 		source = strings.TrimPrefix(source, credentialRoutePrefix)
 		source = strings.Replace(source, "/", "-", -1)
 
@@ -471,6 +514,9 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 			NumUses:      auth.NumUses,
 			EntityID:     auth.EntityID,
 		}
+		if ns.ID != namespace.RootNamespaceID {
+			te.NamespaceID = ns.ID
+		}
 
 		te.Policies = policyutil.SanitizePolicies(te.Policies, true)
 
@@ -494,6 +540,9 @@ func (c *Core) handleLoginRequest(req *logical.Request) (retResp *logical.Respon
 		// Register with the expiration manager
 		if err := c.expiration.RegisterAuth(te.Path, auth); err != nil {
 			c.tokenStore.Revoke(te.ID)
+			if _, ok := err.(*logical.QuotaExceededError); ok {
+				return nil, auth, err
+			}
 			c.logger.Error("core: failed to register token lease", "request_path", req.Path, "error", err)
 			return nil, auth, ErrInternalError
 		}
@@ -394,9 +394,10 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 
 	// Allow EntityID to passthrough to the system backend. This is required to
 	// allow clients to generate MFA credentials in respective entity objects
-	// in identity store via the system backend.
-	switch {
-	case strings.HasPrefix(originalPath, "sys/"):
+	// in identity store via the system backend. The backends are matched by
+	// type since every namespace has its own instances of them.
+	switch re.mountEntry.Type {
+	case "system":
 	default:
 		req.EntityID = ""
 	}
@@ -404,10 +405,10 @@ func (r *Router) routeCommon(req *logical.Request, existenceCheck bool) (*logica
 	// Hash the request token unless the request is being routed to the token
 	// or system backend.
 	clientToken := req.ClientToken
-	switch {
-	case strings.HasPrefix(originalPath, "auth/token/"):
-	case strings.HasPrefix(originalPath, "sys/"):
-	case strings.HasPrefix(originalPath, "cubbyhole/"):
+	switch re.mountEntry.Type {
+	case "token":
+	case "system":
+	case "cubbyhole":
 		// In order for the token store to revoke later, we need to have the same
 		// salted ID, so we double-salt what's going to the cubbyhole backend
 		salt, err := r.tokenStoreSaltFunc()
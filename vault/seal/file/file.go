@@ -0,0 +1,216 @@
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/vault/seal"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// defaultKeyLabel is the label of the key used if none is configured
+	defaultKeyLabel = "vault-seal-key"
+
+	// keySize is the size of the AES-256 key kept in the token directory
+	keySize = 32
+)
+
+// Seal is an auto-unseal seal that wraps keys with an AES-GCM key kept in a
+// local directory. It behaves like a software HSM token: the directory plays
+// the part of the token and keys are looked up by their label. It offers no
+// protection beyond the file permissions and is meant for testing.
+type Seal struct {
+	logger log.Logger
+
+	path        string
+	keyLabel    string
+	generateKey bool
+
+	l     sync.RWMutex
+	keyID string
+	aead  cipher.AEAD
+}
+
+// Ensure that we are implementing seal.Access
+var _ seal.Access = (*Seal)(nil)
+
+// NewSeal returns an unconfigured file seal
+func NewSeal(logger log.Logger) *Seal {
+	return &Seal{
+		logger: logger,
+	}
+}
+
+// SetConfig sets the configuration of the seal. The returned map contains
+// information about the seal suitable for display.
+func (s *Seal) SetConfig(config map[string]string) (map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	s.path = config["path"]
+	if s.path == "" {
+		return nil, fmt.Errorf("'path' must be set")
+	}
+
+	s.keyLabel = config["key_label"]
+	if s.keyLabel == "" {
+		s.keyLabel = defaultKeyLabel
+	}
+
+	if raw, ok := config["generate_key"]; ok {
+		generateKey, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing generate_key parameter: {{err}}", err)
+		}
+		s.generateKey = generateKey
+	}
+
+	return map[string]string{
+		"path":      s.path,
+		"key_label": s.keyLabel,
+	}, nil
+}
+
+// SealType returns the type of the seal
+func (s *Seal) SealType() string {
+	return seal.File
+}
+
+// KeyID returns the ID of the loaded key, derived from its contents
+func (s *Seal) KeyID() string {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.keyID
+}
+
+// Init loads the key from the token directory, generating it first if it
+// does not exist and generate_key is set
+func (s *Seal) Init() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.aead != nil {
+		return nil
+	}
+
+	keyPath := filepath.Join(s.path, s.keyLabel)
+	key, err := ioutil.ReadFile(keyPath)
+	switch {
+	case os.IsNotExist(err) && s.generateKey:
+		key, err = s.createKey(keyPath)
+		if err != nil {
+			return err
+		}
+	case os.IsNotExist(err):
+		return fmt.Errorf("key %q not found and generate_key is not set", s.keyLabel)
+	case err != nil:
+		return errwrap.Wrapf("failed to read seal key: {{err}}", err)
+	}
+
+	if len(key) != keySize {
+		return fmt.Errorf("seal key %q has invalid length %d", s.keyLabel, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(key)
+	s.keyID = fmt.Sprintf("%s-%s", s.keyLabel, hex.EncodeToString(sum[:4]))
+	s.aead = aead
+
+	return nil
+}
+
+// createKey generates a new key and writes it to the token directory
+func (s *Seal) createKey(keyPath string) ([]byte, error) {
+	if err := os.MkdirAll(s.path, 0700); err != nil {
+		return nil, errwrap.Wrapf("failed to create seal directory: {{err}}", err)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(keyPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to create seal key: {{err}}", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(key); err != nil {
+		return nil, errwrap.Wrapf("failed to write seal key: {{err}}", err)
+	}
+
+	if s.logger.IsInfo() {
+		s.logger.Info("seal: generated new file seal key", "key_label", s.keyLabel)
+	}
+
+	return key, nil
+}
+
+// Finalize is a no-op for the file seal
+func (s *Seal) Finalize() error {
+	return nil
+}
+
+// Encrypt encrypts the plaintext with the loaded key
+func (s *Seal) Encrypt(plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	if s.aead == nil {
+		return nil, errors.New("seal has not been initialized")
+	}
+
+	iv := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: s.aead.Seal(nil, iv, plaintext, []byte(s.keyID)),
+		IV:         iv,
+		KeyID:      s.keyID,
+	}, nil
+}
+
+// Decrypt decrypts a value encrypted with the loaded key
+func (s *Seal) Decrypt(in *seal.EncryptedBlobInfo) ([]byte, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	if s.aead == nil {
+		return nil, errors.New("seal has not been initialized")
+	}
+	if in == nil {
+		return nil, errors.New("given input for decryption is nil")
+	}
+	if in.KeyID != s.keyID {
+		return nil, fmt.Errorf("value was encrypted with key %q, but the loaded key is %q", in.KeyID, s.keyID)
+	}
+
+	plaintext, err := s.aead.Open(nil, in.IV, in.Ciphertext, []byte(in.KeyID))
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decrypt value: {{err}}", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,129 @@
+package file
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func testFileSeal(t *testing.T, config map[string]string) *Seal {
+	s := NewSeal(logformat.NewVaultLogger(log.LevelTrace))
+	if _, err := s.SetConfig(config); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return s
+}
+
+func TestFileSeal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-file-seal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := map[string]string{
+		"path":         dir,
+		"generate_key": "true",
+	}
+
+	s := testFileSeal(t, config)
+	if _, err := s.Encrypt([]byte("foo")); err == nil {
+		t.Fatal("expected error before init")
+	}
+	if err := s.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultKeyLabel)); err != nil {
+		t.Fatalf("expected key to be generated: %v", err)
+	}
+
+	blob, err := s.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if blob.KeyID != s.KeyID() {
+		t.Fatalf("bad key ID: %q", blob.KeyID)
+	}
+
+	// A second seal using the same directory must load the same key
+	s2 := testFileSeal(t, config)
+	if err := s2.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if s2.KeyID() != s.KeyID() {
+		t.Fatalf("key IDs differ: %q vs %q", s2.KeyID(), s.KeyID())
+	}
+	pt, err := s2.Decrypt(blob)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(pt, []byte("foo")) {
+		t.Fatalf("bad plaintext: %q", pt)
+	}
+
+	// Tampered ciphertext must not decrypt
+	blob.Ciphertext[0] ^= 0xff
+	if _, err := s2.Decrypt(blob); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFileSeal_KeyMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-file-seal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := testFileSeal(t, map[string]string{
+		"path":         dir,
+		"key_label":    "one",
+		"generate_key": "true",
+	})
+	if err := s.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	blob, err := s.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	s2 := testFileSeal(t, map[string]string{
+		"path":         dir,
+		"key_label":    "two",
+		"generate_key": "true",
+	})
+	if err := s2.Init(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := s2.Decrypt(blob); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFileSeal_Config(t *testing.T) {
+	s := NewSeal(logformat.NewVaultLogger(log.LevelTrace))
+	if _, err := s.SetConfig(nil); err == nil {
+		t.Fatal("expected error without path")
+	}
+	if _, err := s.SetConfig(map[string]string{"path": "foo", "generate_key": "maybe"}); err == nil {
+		t.Fatal("expected error with invalid generate_key")
+	}
+
+	dir, err := ioutil.TempDir("", "vault-file-seal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Without generate_key a missing key is an error
+	s = testFileSeal(t, map[string]string{"path": dir})
+	if err := s.Init(); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
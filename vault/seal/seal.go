@@ -0,0 +1,51 @@
+package seal
+
+const (
+	// Transit is the type of the seal backed by the transit secret backend
+	// of another Vault cluster
+	Transit = "transit"
+
+	// File is the type of the seal backed by a key kept in a local file. It
+	// mimics a software HSM token and is meant for testing.
+	File = "file"
+
+	// Test is the type of the in-memory seal used in tests
+	Test = "test-auto"
+)
+
+// Access is the interface a key management system has to implement to be
+// used for auto-unseal. It is used to encrypt and decrypt the key material
+// Vault needs to unseal itself, which is then stored in the physical backend.
+type Access interface {
+	// SealType returns the type of the seal
+	SealType() string
+
+	// KeyID returns the ID of the key currently used for encryption
+	KeyID() string
+
+	// Init is called before the seal is used. It must be safe to call it
+	// more than once.
+	Init() error
+
+	// Finalize is called when Vault shuts down
+	Finalize() error
+
+	// Encrypt wraps the given plaintext
+	Encrypt([]byte) (*EncryptedBlobInfo, error)
+
+	// Decrypt unwraps a value previously returned by Encrypt
+	Decrypt(*EncryptedBlobInfo) ([]byte, error)
+}
+
+// EncryptedBlobInfo contains the value returned by a seal's Encrypt along
+// with the information needed to decrypt it
+type EncryptedBlobInfo struct {
+	// Ciphertext is the encrypted value
+	Ciphertext []byte `json:"ciphertext"`
+
+	// IV is the initialization vector, for seals that generate it locally
+	IV []byte `json:"iv,omitempty"`
+
+	// KeyID is the ID of the key the value was encrypted with
+	KeyID string `json:"key_id,omitempty"`
+}
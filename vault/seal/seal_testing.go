@@ -0,0 +1,73 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/mitchellh/go-testing-interface"
+)
+
+// TestSeal is an in-memory Access implementation for use in tests
+type TestSeal struct {
+	aead cipher.AEAD
+}
+
+var _ Access = (*TestSeal)(nil)
+
+// NewTestSeal returns a TestSeal using a randomly generated key
+func NewTestSeal(t testing.T) *TestSeal {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &TestSeal{
+		aead: aead,
+	}
+}
+
+func (t *TestSeal) SealType() string {
+	return Test
+}
+
+func (t *TestSeal) KeyID() string {
+	return "test-key"
+}
+
+func (t *TestSeal) Init() error {
+	return nil
+}
+
+func (t *TestSeal) Finalize() error {
+	return nil
+}
+
+func (t *TestSeal) Encrypt(plaintext []byte) (*EncryptedBlobInfo, error) {
+	iv := make([]byte, t.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	return &EncryptedBlobInfo{
+		Ciphertext: t.aead.Seal(nil, iv, plaintext, nil),
+		IV:         iv,
+		KeyID:      t.KeyID(),
+	}, nil
+}
+
+func (t *TestSeal) Decrypt(in *EncryptedBlobInfo) ([]byte, error) {
+	if in == nil {
+		return nil, fmt.Errorf("given input for decryption is nil")
+	}
+	return t.aead.Open(nil, in.IV, in.Ciphertext, nil)
+}
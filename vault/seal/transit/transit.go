@@ -0,0 +1,177 @@
+package transit
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/vault/seal"
+	log "github.com/mgutz/logxi/v1"
+)
+
+const (
+	// defaultMountPath is where the transit backend is expected to be
+	// mounted if no mount path is configured
+	defaultMountPath = "transit"
+)
+
+// Seal is an auto-unseal seal that wraps keys using the transit secret
+// backend of another Vault cluster
+type Seal struct {
+	logger log.Logger
+	client *api.Client
+
+	mountPath string
+	keyName   string
+}
+
+// Ensure that we are implementing seal.Access
+var _ seal.Access = (*Seal)(nil)
+
+// NewSeal returns an unconfigured transit seal
+func NewSeal(logger log.Logger) *Seal {
+	return &Seal{
+		logger: logger,
+	}
+}
+
+// SetConfig sets the configuration of the seal. Values not given fall back
+// to the standard VAULT_* environment variables. The returned map contains
+// information about the seal suitable for display.
+func (s *Seal) SetConfig(config map[string]string) (map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	s.keyName = config["key_name"]
+	if s.keyName == "" {
+		return nil, fmt.Errorf("'key_name' must be set")
+	}
+
+	s.mountPath = strings.Trim(config["mount_path"], "/")
+	if s.mountPath == "" {
+		s.mountPath = defaultMountPath
+	}
+
+	clientConfig := api.DefaultConfig()
+	if err := clientConfig.ReadEnvironment(); err != nil {
+		return nil, errwrap.Wrapf("failed to read environment: {{err}}", err)
+	}
+	if address := config["address"]; address != "" {
+		clientConfig.Address = address
+	}
+
+	tlsConfig := &api.TLSConfig{
+		CACert:        config["tls_ca_cert"],
+		ClientCert:    config["tls_client_cert"],
+		ClientKey:     config["tls_client_key"],
+		TLSServerName: config["tls_server_name"],
+	}
+	if raw, ok := config["tls_skip_verify"]; ok {
+		skipVerify, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed parsing tls_skip_verify parameter: {{err}}", err)
+		}
+		tlsConfig.Insecure = skipVerify
+	}
+	if tlsConfig.CACert != "" || tlsConfig.ClientCert != "" || tlsConfig.TLSServerName != "" || tlsConfig.Insecure {
+		if err := clientConfig.ConfigureTLS(tlsConfig); err != nil {
+			return nil, errwrap.Wrapf("failed to configure TLS: {{err}}", err)
+		}
+	}
+
+	client, err := api.NewClient(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	if token := config["token"]; token != "" {
+		client.SetToken(token)
+	}
+	if client.Token() == "" {
+		return nil, fmt.Errorf("'token' must be set")
+	}
+	s.client = client
+
+	return map[string]string{
+		"address":    client.Address(),
+		"mount_path": s.mountPath,
+		"key_name":   s.keyName,
+	}, nil
+}
+
+// SealType returns the type of the seal
+func (s *Seal) SealType() string {
+	return seal.Transit
+}
+
+// KeyID returns the name of the transit key
+func (s *Seal) KeyID() string {
+	return s.keyName
+}
+
+// Init is a no-op; the remote Vault is only contacted when keys are
+// wrapped or unwrapped
+func (s *Seal) Init() error {
+	return nil
+}
+
+// Finalize is a no-op for the transit seal
+func (s *Seal) Finalize() error {
+	return nil
+}
+
+// Encrypt encrypts the plaintext using the transit key
+func (s *Seal) Encrypt(plaintext []byte) (*seal.EncryptedBlobInfo, error) {
+	secret, err := s.client.Logical().Write(path.Join(s.mountPath, "encrypt", s.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to encrypt with transit: {{err}}", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("no data returned from transit encrypt")
+	}
+
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return nil, errors.New("no ciphertext returned from transit encrypt")
+	}
+
+	return &seal.EncryptedBlobInfo{
+		Ciphertext: []byte(ciphertext),
+		KeyID:      s.keyName,
+	}, nil
+}
+
+// Decrypt decrypts a value encrypted with the transit key
+func (s *Seal) Decrypt(in *seal.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil {
+		return nil, errors.New("given input for decryption is nil")
+	}
+
+	secret, err := s.client.Logical().Write(path.Join(s.mountPath, "decrypt", s.keyName), map[string]interface{}{
+		"ciphertext": string(in.Ciphertext),
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decrypt with transit: {{err}}", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("no data returned from transit decrypt")
+	}
+
+	encoded, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("no plaintext returned from transit decrypt")
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to decode plaintext returned from transit: {{err}}", err)
+	}
+	return plaintext, nil
+}
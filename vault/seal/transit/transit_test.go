@@ -0,0 +1,138 @@
+package transit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	transitBackend "github.com/hashicorp/vault/builtin/logical/transit"
+	"github.com/hashicorp/vault/helper/logformat"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	log "github.com/mgutz/logxi/v1"
+)
+
+func testTransitSeal(t *testing.T) (*Seal, func()) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"transit": transitBackend.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().Mount("seal-transit", &api.MountInput{
+		Type: "transit",
+	}); err != nil {
+		cluster.Cleanup()
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("seal-transit/keys/autounseal", nil); err != nil {
+		cluster.Cleanup()
+		t.Fatal(err)
+	}
+
+	s := NewSeal(logformat.NewVaultLogger(log.LevelTrace))
+	if _, err := s.SetConfig(map[string]string{
+		"address":     client.Address(),
+		"token":       cluster.RootToken,
+		"mount_path":  "seal-transit/",
+		"key_name":    "autounseal",
+		"tls_ca_cert": cluster.CACertPEMFile,
+	}); err != nil {
+		cluster.Cleanup()
+		t.Fatal(err)
+	}
+
+	return s, cluster.Cleanup
+}
+
+func TestTransitSeal(t *testing.T) {
+	s, cleanup := testTransitSeal(t)
+	defer cleanup()
+
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := s.Encrypt([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(blob.Ciphertext, []byte("vault:v1:")) {
+		t.Fatalf("bad ciphertext: %q", blob.Ciphertext)
+	}
+	if blob.KeyID != "autounseal" {
+		t.Fatalf("bad key ID: %q", blob.KeyID)
+	}
+
+	pt, err := s.Decrypt(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, []byte("foo")) {
+		t.Fatalf("bad plaintext: %q", pt)
+	}
+}
+
+func TestTransitSeal_AutoUnseal(t *testing.T) {
+	s, cleanup := testTransitSeal(t)
+	defer cleanup()
+
+	core := vault.TestCoreWithSeal(t, vault.NewAutoSeal(s), false)
+	result, err := core.Initialize(&vault.InitParams{
+		BarrierConfig: &vault.SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		},
+		RecoveryConfig: &vault.SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := core.UnsealWithStoredKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+
+	if err := core.Seal(result.RootToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.UnsealWithStoredKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := core.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
+
+func TestTransitSeal_Config(t *testing.T) {
+	s := NewSeal(logformat.NewVaultLogger(log.LevelTrace))
+	if _, err := s.SetConfig(map[string]string{"token": "foo"}); err == nil {
+		t.Fatal("expected error without key_name")
+	}
+	if _, err := s.SetConfig(map[string]string{"key_name": "foo", "token": "foo", "tls_skip_verify": "maybe"}); err == nil {
+		t.Fatal("expected error with invalid tls_skip_verify")
+	}
+
+	info, err := s.SetConfig(map[string]string{"key_name": "foo", "token": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["mount_path"] != "transit" {
+		t.Fatalf("bad mount path: %q", info["mount_path"])
+	}
+}
@@ -0,0 +1,351 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// autoSeal is a Seal that wraps the stored unseal keys and the recovery key
+// with an external key management system, so that Vault can unseal itself
+// without gathering the unseal key holders. Recovery keys take the place of
+// unseal keys for operations such as generate-root and rekey.
+type autoSeal struct {
+	seal.Access
+
+	barrierConfig  *SealConfig
+	recoveryConfig *SealConfig
+	core           *Core
+}
+
+// Ensure we are implementing the Seal interface
+var _ Seal = (*autoSeal)(nil)
+
+// NewAutoSeal returns a Seal that uses the given key management system to
+// protect the stored keys
+func NewAutoSeal(access seal.Access) Seal {
+	return &autoSeal{
+		Access: access,
+	}
+}
+
+func (d *autoSeal) checkCore() error {
+	if d.core == nil {
+		return fmt.Errorf("seal does not have a core set")
+	}
+	return nil
+}
+
+func (d *autoSeal) SetCore(core *Core) {
+	d.core = core
+}
+
+func (d *autoSeal) Init() error {
+	return d.Access.Init()
+}
+
+func (d *autoSeal) Finalize() error {
+	return d.Access.Finalize()
+}
+
+func (d *autoSeal) BarrierType() string {
+	return d.SealType()
+}
+
+func (d *autoSeal) StoredKeysSupported() bool {
+	return true
+}
+
+func (d *autoSeal) RecoveryKeySupported() bool {
+	return true
+}
+
+// SetStoredKeys encrypts the keys with the key management system and
+// stores them in the physical backend
+func (d *autoSeal) SetStoredKeys(keys [][]byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if keys == nil {
+		return fmt.Errorf("keys were nil")
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys provided")
+	}
+
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("failed to encode keys for storage: %v", err)
+	}
+
+	return d.putEncrypted(hsmStoredKeysPath, buf)
+}
+
+// GetStoredKeys fetches the stored keys and decrypts them with the key
+// management system
+func (d *autoSeal) GetStoredKeys() ([][]byte, error) {
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	pt, err := d.getDecrypted(hsmStoredKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	if pt == nil {
+		return nil, nil
+	}
+
+	var keys [][]byte
+	if err := json.Unmarshal(pt, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode stored keys: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (d *autoSeal) BarrierConfig() (*SealConfig, error) {
+	if d.barrierConfig != nil {
+		return d.barrierConfig.Clone(), nil
+	}
+
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	conf, err := d.readConfig(barrierSealConfigPath, d.BarrierType())
+	if err != nil {
+		d.core.logger.Error("core: failed to read seal configuration", "error", err)
+		return nil, err
+	}
+
+	// If the seal configuration is missing, we are not initialized
+	if conf == nil {
+		d.core.logger.Info("core: seal configuration missing, not initialized")
+		return nil, nil
+	}
+
+	d.barrierConfig = conf
+	return d.barrierConfig.Clone(), nil
+}
+
+func (d *autoSeal) SetBarrierConfig(config *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value (also prevents actually
+	// saving a nil config)
+	if config == nil {
+		d.barrierConfig = nil
+		return nil
+	}
+
+	config.Type = d.BarrierType()
+
+	if err := d.writeConfig(barrierSealConfigPath, config); err != nil {
+		d.core.logger.Error("core: failed to write seal configuration", "error", err)
+		return err
+	}
+
+	d.barrierConfig = config.Clone()
+
+	return nil
+}
+
+func (d *autoSeal) RecoveryType() string {
+	return RecoveryTypeShamir
+}
+
+func (d *autoSeal) RecoveryConfig() (*SealConfig, error) {
+	if d.recoveryConfig != nil {
+		return d.recoveryConfig.Clone(), nil
+	}
+
+	if err := d.checkCore(); err != nil {
+		return nil, err
+	}
+
+	conf, err := d.readConfig(recoverySealConfigPlaintextPath, d.RecoveryType())
+	if err != nil {
+		d.core.logger.Error("core: failed to read recovery seal configuration", "error", err)
+		return nil, err
+	}
+
+	if conf == nil {
+		d.core.logger.Info("core: recovery seal configuration missing, not initialized")
+		return nil, nil
+	}
+
+	d.recoveryConfig = conf
+	return d.recoveryConfig.Clone(), nil
+}
+
+func (d *autoSeal) SetRecoveryConfig(config *SealConfig) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+
+	// Provide a way to wipe out the cached value
+	if config == nil {
+		d.recoveryConfig = nil
+		return nil
+	}
+
+	config.Type = d.RecoveryType()
+
+	if err := d.writeConfig(recoverySealConfigPlaintextPath, config); err != nil {
+		d.core.logger.Error("core: failed to write recovery seal configuration", "error", err)
+		return err
+	}
+
+	d.recoveryConfig = config.Clone()
+
+	return nil
+}
+
+// SetRecoveryKey encrypts the recovery key with the key management system
+// and stores it in the physical backend, so it can be verified while sealed
+func (d *autoSeal) SetRecoveryKey(key []byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("recovery key to store is nil")
+	}
+
+	return d.putEncrypted(recoveryKeyPath, key)
+}
+
+// VerifyRecoveryKey checks the given key against the stored recovery key
+func (d *autoSeal) VerifyRecoveryKey(key []byte) error {
+	if err := d.checkCore(); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("recovery key to verify is nil")
+	}
+
+	pt, err := d.getDecrypted(recoveryKeyPath)
+	if err != nil {
+		return err
+	}
+	if pt == nil {
+		return fmt.Errorf("no recovery key found")
+	}
+
+	if subtle.ConstantTimeCompare(key, pt) != 1 {
+		return fmt.Errorf("recovery key verification failed")
+	}
+
+	return nil
+}
+
+// readConfig reads a seal configuration of the given type from the
+// physical backend
+func (d *autoSeal) readConfig(path, sealType string) (*SealConfig, error) {
+	pe, err := d.core.physical.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check seal configuration: %v", err)
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		return nil, fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+
+	if conf.Type != sealType {
+		return nil, fmt.Errorf("seal type of %s does not match loaded type of %s", conf.Type, sealType)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("seal validation failed: %v", err)
+	}
+
+	return &conf, nil
+}
+
+// writeConfig writes a seal configuration to the physical backend
+func (d *autoSeal) writeConfig(path string, config *SealConfig) error {
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode seal configuration: %v", err)
+	}
+
+	pe := &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}
+	if err := d.core.physical.Put(pe); err != nil {
+		return fmt.Errorf("failed to write seal configuration: %v", err)
+	}
+
+	return nil
+}
+
+// putEncrypted encrypts the value with the key management system and stores
+// the result in the physical backend
+func (d *autoSeal) putEncrypted(path string, value []byte) error {
+	pe, err := d.encryptedEntry(path, value)
+	if err != nil {
+		return err
+	}
+
+	if err := d.core.physical.Put(pe); err != nil {
+		d.core.logger.Error("core: failed to write encrypted seal value", "path", path, "error", err)
+		return fmt.Errorf("failed to write encrypted value: %v", err)
+	}
+
+	return nil
+}
+
+// encryptedEntry encrypts the value with the key management system and
+// returns the physical entry to store it at path
+func (d *autoSeal) encryptedEntry(path string, value []byte) (*physical.Entry, error) {
+	blobInfo, err := d.Encrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt value: %v", err)
+	}
+
+	buf, err := json.Marshal(blobInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode encrypted value: %v", err)
+	}
+
+	return &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}, nil
+}
+
+// getDecrypted reads a value from the physical backend and decrypts it with
+// the key management system. It returns nil if there is no value.
+func (d *autoSeal) getDecrypted(path string) ([]byte, error) {
+	pe, err := d.core.physical.Get(path)
+	if err != nil {
+		d.core.logger.Error("core: failed to read encrypted seal value", "path", path, "error", err)
+		return nil, fmt.Errorf("failed to read encrypted value: %v", err)
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	var blobInfo seal.EncryptedBlobInfo
+	if err := jsonutil.DecodeJSON(pe.Value, &blobInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted value: %v", err)
+	}
+
+	pt, err := d.Decrypt(&blobInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %v", err)
+	}
+
+	return pt, nil
+}
@@ -0,0 +1,217 @@
+package vault
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func testCoreAutoSealed(t *testing.T) (*Core, [][]byte, string) {
+	c := TestCoreWithSeal(t, NewTestAutoSeal(t), false)
+	result, err := c.Initialize(&InitParams{
+		BarrierConfig: &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		},
+		RecoveryConfig: &SealConfig{
+			SecretShares:    5,
+			SecretThreshold: 3,
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(result.SecretShares) != 0 {
+		t.Fatalf("expected no unseal keys to be returned, got %d", len(result.SecretShares))
+	}
+	if len(result.RecoveryShares) != 5 {
+		t.Fatalf("expected 5 recovery keys, got %d", len(result.RecoveryShares))
+	}
+
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+
+	return c, result.RecoveryShares, result.RootToken
+}
+
+func TestAutoSeal_Init(t *testing.T) {
+	c, _, _ := testCoreAutoSealed(t)
+
+	conf, err := c.seal.BarrierConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != "test-auto" || conf.StoredShares != 1 {
+		t.Fatalf("bad barrier config: %#v", conf)
+	}
+
+	rconf, err := c.seal.RecoveryConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if rconf.Type != RecoveryTypeShamir || rconf.SecretShares != 5 || rconf.SecretThreshold != 3 {
+		t.Fatalf("bad recovery config: %#v", rconf)
+	}
+
+	// The stored keys must not be kept in the clear
+	keys, err := c.seal.GetStoredKeys()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pe, err := c.physical.Get(hsmStoredKeysPath)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if pe == nil {
+		t.Fatal("expected stored keys")
+	}
+	for _, key := range keys {
+		if reflect.DeepEqual(pe.Value, key) {
+			t.Fatal("stored keys were not encrypted")
+		}
+	}
+}
+
+func TestAutoSeal_Unseal(t *testing.T) {
+	c, _, root := testCoreAutoSealed(t)
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatal("should be sealed")
+	}
+
+	// Forget the cached configuration, as a restarted node would
+	c.seal.SetBarrierConfig(nil)
+	c.seal.SetRecoveryConfig(nil)
+
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
+
+func TestAutoSeal_UnsealWrongKey(t *testing.T) {
+	c, _, root := testCoreAutoSealed(t)
+
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// A different key management key cannot unwrap the stored keys
+	c.seal.(*autoSeal).Access = NewTestAutoSeal(t).(*autoSeal).Access
+
+	err := c.UnsealWithStoredKeys()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := err.(*NonFatalError); !ok {
+		t.Fatalf("expected a non-fatal error, got %#v", err)
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatal("should be sealed")
+	}
+}
+
+func TestAutoSeal_VerifyRecoveryKey(t *testing.T) {
+	c, recoveryKeys, _ := testCoreAutoSealed(t)
+
+	// Individual shares are not the recovery key
+	if err := c.seal.VerifyRecoveryKey(recoveryKeys[0]); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := c.seal.VerifyRecoveryKey(nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestAutoSeal_GenerateRoot(t *testing.T) {
+	c, recoveryKeys, _ := testCoreAutoSealed(t)
+	testCore_GenerateRoot_Update_OTP_Common(t, c, recoveryKeys[0:3])
+}
+
+func TestAutoSeal_GenerateRoot_WrongKeys(t *testing.T) {
+	c, _, _ := testCoreAutoSealed(t)
+	_, otherKeys, _ := testCoreAutoSealed(t)
+
+	otpBytes, err := GenerateRandBytes(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.GenerateRootInit(base64.StdEncoding.EncodeToString(otpBytes), ""); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rkconf, err := c.GenerateRootConfiguration()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i, key := range otherKeys[0:3] {
+		_, err = c.GenerateRootUpdate(key, rkconf.Nonce)
+		if i < 2 && err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if err == nil {
+		t.Fatal("expected recovery key verification to fail")
+	}
+}
+
+func TestAutoSeal_RekeyRecovery(t *testing.T) {
+	c, recoveryKeys, root := testCoreAutoSealed(t)
+	testCore_Rekey_Update_Common(t, c, recoveryKeys, root, true)
+}
+
+func TestAutoSeal_RekeyBarrier(t *testing.T) {
+	c, recoveryKeys, root := testCoreAutoSealed(t)
+
+	newConf := &SealConfig{
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	}
+	if err := c.RekeyInit(newConf, false); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rkconf, err := c.RekeyConfig(false)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The barrier key is rekeyed using the recovery keys
+	var result *RekeyResult
+	for _, key := range recoveryKeys {
+		result, err = c.RekeyUpdate(key, rkconf.Nonce, false)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if result != nil {
+			break
+		}
+	}
+	if result == nil {
+		t.Fatal("rekey did not complete")
+	}
+	if len(result.SecretShares) != 0 {
+		t.Fatalf("expected new keys to be stored, got %d returned", len(result.SecretShares))
+	}
+
+	// The new stored keys must unseal the core
+	if err := c.Seal(root); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
@@ -0,0 +1,269 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/shamir"
+)
+
+var (
+	// ErrSealMigrationInProgress is returned when unsealing without the
+	// migrate flag while a seal migration is in progress
+	ErrSealMigrationInProgress = errors.New("seal migration is in progress, keys must be provided with the migrate flag")
+
+	// ErrNoSealMigration is returned when unsealing with the migrate flag
+	// while no seal migration is in progress
+	ErrNoSealMigration = errors.New("no seal migration is in progress")
+)
+
+// IsInSealMigration returns true if Vault was started with a seal to migrate
+// from and the stored data is still protected by that seal. While migrating,
+// Vault has to be unsealed with UnsealMigrate.
+func (c *Core) IsInSealMigration() bool {
+	migrating, err := c.sealMigrationPending()
+	if err != nil {
+		c.logger.Error("core: failed to check seal migration status", "error", err)
+		return false
+	}
+	return migrating
+}
+
+// SealMigrationConfig returns the configuration of the keys that have to be
+// provided to migrate between seals, or nil if no migration is in progress
+func (c *Core) SealMigrationConfig() (*SealConfig, error) {
+	migrating, err := c.sealMigrationPending()
+	if err != nil || !migrating {
+		return nil, err
+	}
+	return c.sealMigrationKeyConfig()
+}
+
+// sealMigrationPending checks whether the stored seal configuration still
+// belongs to the seal being migrated from. The stored configuration is read
+// directly, as the seals refuse configurations of a type other than their
+// own.
+func (c *Core) sealMigrationPending() (bool, error) {
+	if c.migrationSeal == nil {
+		return false, nil
+	}
+
+	pe, err := c.physical.Get(barrierSealConfigPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read seal configuration: %v", err)
+	}
+	if pe == nil {
+		return false, nil
+	}
+
+	var conf SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &conf); err != nil {
+		return false, fmt.Errorf("failed to decode seal configuration: %v", err)
+	}
+
+	// Configurations written before seal types were recorded belong to the
+	// default seal
+	if conf.Type == "" {
+		conf.Type = SealTypeShamir
+	}
+
+	return conf.Type == c.migrationSeal.BarrierType(), nil
+}
+
+// sealMigrationKeyConfig returns the configuration of the keys provided
+// during a migration: the unseal keys when migrating from the default seal,
+// and the recovery keys when migrating from an auto-unseal seal
+func (c *Core) sealMigrationKeyConfig() (*SealConfig, error) {
+	if c.migrationSeal.RecoveryKeySupported() {
+		return c.migrationSeal.RecoveryConfig()
+	}
+	return c.migrationSeal.BarrierConfig()
+}
+
+// migrateSeal moves the stored data from the migration seal to the seal,
+// given the key assembled from the parts provided to UnsealMigrate. The
+// keyring is re-encrypted and the new seal configuration is written in a
+// single transaction. It returns the master key to unseal with. This must
+// be called with the state write lock held.
+func (c *Core) migrateSeal(key []byte) ([]byte, error) {
+	if _, ok := c.physical.(physical.Transactional); !ok {
+		return nil, fmt.Errorf("seal migration requires a storage backend that supports transactions")
+	}
+
+	// Recover the master key and the key that protects operations such as
+	// generate-root. When migrating from the default seal, the unseal keys
+	// become the recovery keys of the new seal.
+	var masterKey, recoveryKey []byte
+	var recoveryConfig *SealConfig
+	var err error
+	if c.migrationSeal.StoredKeysSupported() {
+		if err := c.migrationSeal.VerifyRecoveryKey(key); err != nil {
+			c.logger.Error("core: seal migration aborted, recovery key verification failed", "error", err)
+			return nil, err
+		}
+
+		storedKeys, err := c.migrationSeal.GetStoredKeys()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch stored keys: %v", err)
+		}
+		switch len(storedKeys) {
+		case 0:
+			return nil, fmt.Errorf("no stored keys found")
+		case 1:
+			masterKey = storedKeys[0]
+		default:
+			masterKey, err = shamir.Combine(storedKeys)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute master key: %v", err)
+			}
+		}
+
+		recoveryKey = key
+		recoveryConfig, err = c.migrationSeal.RecoveryConfig()
+		if err != nil {
+			return nil, err
+		}
+		if recoveryConfig == nil {
+			return nil, fmt.Errorf("recovery configuration not found")
+		}
+	} else {
+		masterKey = key
+		recoveryKey = key
+		recoveryConfig, err = c.migrationSeal.BarrierConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The barrier has to be unsealed to re-encrypt the keyring, which also
+	// verifies the master key
+	if err := c.barrier.Unseal(masterKey); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := c.barrier.Seal(); err != nil {
+			c.logger.Error("core: failed to seal barrier after seal migration", "error", err)
+		}
+	}()
+
+	var newMasterKey []byte
+	var txns []*physical.TxnEntry
+	if c.seal.StoredKeysSupported() {
+		newSeal, ok := c.seal.(*autoSeal)
+		if !ok {
+			return nil, fmt.Errorf("seal migration to seal type %s is not supported", c.seal.BarrierType())
+		}
+
+		// Keep the master key when moving between auto-unseal seals, as it
+		// is never seen by anyone
+		newMasterKey = masterKey
+		if !c.migrationSeal.StoredKeysSupported() {
+			newMasterKey, err = c.barrier.GenerateKey()
+			if err != nil {
+				return nil, fmt.Errorf("master key generation failed: %v", err)
+			}
+		}
+
+		barrierConfig := &SealConfig{
+			Type:            c.seal.BarrierType(),
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		}
+		newRecoveryConfig := recoveryConfig.Clone()
+		newRecoveryConfig.Type = c.seal.RecoveryType()
+		newRecoveryConfig.StoredShares = 0
+
+		storedKeysBuf, err := json.Marshal([][]byte{newMasterKey})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode keys for storage: %v", err)
+		}
+
+		barrierConfigEntry, err := sealConfigEntry(barrierSealConfigPath, barrierConfig)
+		if err != nil {
+			return nil, err
+		}
+		recoveryConfigEntry, err := sealConfigEntry(recoverySealConfigPlaintextPath, newRecoveryConfig)
+		if err != nil {
+			return nil, err
+		}
+		storedKeysEntry, err := newSeal.encryptedEntry(hsmStoredKeysPath, storedKeysBuf)
+		if err != nil {
+			return nil, err
+		}
+		recoveryKeyEntry, err := newSeal.encryptedEntry(recoveryKeyPath, recoveryKey)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pe := range []*physical.Entry{barrierConfigEntry, recoveryConfigEntry, storedKeysEntry, recoveryKeyEntry} {
+			txns = append(txns, &physical.TxnEntry{
+				Operation: physical.PutOperation,
+				Entry:     pe,
+			})
+		}
+	} else {
+		// The recovery key becomes the master key, so that the recovery
+		// key holders become the unseal key holders
+		newMasterKey = recoveryKey
+
+		barrierConfig := recoveryConfig.Clone()
+		barrierConfig.Type = c.seal.BarrierType()
+		barrierConfig.StoredShares = 0
+
+		pe, err := sealConfigEntry(barrierSealConfigPath, barrierConfig)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, &physical.TxnEntry{
+			Operation: physical.PutOperation,
+			Entry:     pe,
+		})
+		for _, path := range []string{recoverySealConfigPlaintextPath, recoveryKeyPath, hsmStoredKeysPath} {
+			txns = append(txns, &physical.TxnEntry{
+				Operation: physical.DeleteOperation,
+				Entry: &physical.Entry{
+					Key: path,
+				},
+			})
+		}
+	}
+
+	if err := c.barrier.RekeyTxn(newMasterKey, txns); err != nil {
+		c.logger.Error("core: seal migration failed", "error", err)
+		return nil, fmt.Errorf("seal migration failed: %v", err)
+	}
+
+	// Drop the cached configurations so they are read from storage again
+	for _, seal := range []Seal{c.seal, c.migrationSeal} {
+		seal.SetBarrierConfig(nil)
+		if seal.RecoveryKeySupported() {
+			seal.SetRecoveryConfig(nil)
+		}
+	}
+
+	if c.logger.IsInfo() {
+		c.logger.Info("core: seal migration complete", "from", c.migrationSeal.BarrierType(), "to", c.seal.BarrierType())
+	}
+
+	ret := make([]byte, len(newMasterKey))
+	copy(ret, newMasterKey)
+	return ret, nil
+}
+
+// sealConfigEntry returns the physical entry to store the seal
+// configuration at path
+func sealConfigEntry(path string, config *SealConfig) (*physical.Entry, error) {
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode seal configuration: %v", err)
+	}
+
+	return &physical.Entry{
+		Key:   path,
+		Value: buf,
+	}, nil
+}
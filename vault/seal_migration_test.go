@@ -0,0 +1,237 @@
+package vault
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/physical"
+	physInmem "github.com/hashicorp/vault/physical/inmem"
+	log "github.com/mgutz/logxi/v1"
+)
+
+var testSealMigrationEntry = &Entry{
+	Key:   "test/seal-migration",
+	Value: []byte("still readable"),
+}
+
+// testCoreSealMigration creates a core on the given physical backend with
+// the given seal and seal to migrate from
+func testCoreSealMigration(t *testing.T, phys physical.Backend, seal, migrationSeal Seal) *Core {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	conf := testCoreConfig(t, phys, logger)
+	conf.Seal = seal
+	conf.MigrationSeal = migrationSeal
+
+	c, err := NewCore(conf)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	return c
+}
+
+func testSealMigrationPhysical(t *testing.T) physical.Backend {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	phys, err := physInmem.NewTransactionalInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return phys
+}
+
+// testSealMigrationInit initializes a core using the given seal, writes a
+// test entry through the barrier and seals it again. It returns the unseal
+// keys, or the recovery keys if the seal stores its keys.
+func testSealMigrationInit(t *testing.T, phys physical.Backend, seal Seal) [][]byte {
+	c := testCoreSealMigration(t, phys, seal, nil)
+
+	params := &InitParams{
+		BarrierConfig: &SealConfig{
+			SecretShares:    5,
+			SecretThreshold: 3,
+		},
+	}
+	if seal.StoredKeysSupported() {
+		params.BarrierConfig = &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+			StoredShares:    1,
+		}
+		params.RecoveryConfig = &SealConfig{
+			SecretShares:    5,
+			SecretThreshold: 3,
+		}
+	}
+	result, err := c.Initialize(params)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	keys := result.SecretShares
+	if seal.StoredKeysSupported() {
+		keys = result.RecoveryShares
+		if err := c.UnsealWithStoredKeys(); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	} else {
+		// The unseal parts are wiped once used, so keep the keys intact
+		for _, key := range keys[0:3] {
+			if _, err := c.Unseal(append([]byte(nil), key...)); err != nil {
+				t.Fatalf("err: %v", err)
+			}
+		}
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+
+	if err := c.barrier.Put(testSealMigrationEntry); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := c.Seal(result.RootToken); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	return keys
+}
+
+// testSealMigrationUnseal performs the migration with the given keys and
+// checks that the data written before the migration can still be read
+func testSealMigrationUnseal(t *testing.T, c *Core, keys [][]byte) {
+	if !c.IsInSealMigration() {
+		t.Fatal("expected seal migration to be in progress")
+	}
+
+	// The keys of the old seal must be given with the migrate flag
+	if _, err := c.Unseal(keys[0]); err != ErrSealMigrationInProgress {
+		t.Fatalf("expected %v, got %v", ErrSealMigrationInProgress, err)
+	}
+
+	conf, err := c.SealMigrationConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf == nil || conf.SecretShares != 5 || conf.SecretThreshold != 3 {
+		t.Fatalf("bad migration config: %#v", conf)
+	}
+
+	for i, key := range keys[0:3] {
+		unsealed, err := c.UnsealMigrate(append([]byte(nil), key...))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if unsealed != (i == 2) {
+			t.Fatalf("bad unseal state after %d keys: %v", i+1, unsealed)
+		}
+	}
+
+	if c.IsInSealMigration() {
+		t.Fatal("expected seal migration to be complete")
+	}
+	if _, err := c.UnsealMigrate(keys[0]); err != ErrNoSealMigration {
+		t.Fatalf("expected %v, got %v", ErrNoSealMigration, err)
+	}
+
+	out, err := c.barrier.Get(testSealMigrationEntry.Key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out == nil || !bytes.Equal(out.Value, testSealMigrationEntry.Value) {
+		t.Fatalf("bad entry after migration: %#v", out)
+	}
+}
+
+func TestCore_SealMigration_ShamirToAuto(t *testing.T) {
+	phys := testSealMigrationPhysical(t)
+	keys := testSealMigrationInit(t, phys, &DefaultSeal{})
+
+	testSeal := NewTestAutoSeal(t)
+	c := testCoreSealMigration(t, phys, testSeal, &DefaultSeal{})
+	testSealMigrationUnseal(t, c, keys)
+
+	conf, err := c.seal.BarrierConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != testSeal.BarrierType() || conf.StoredShares != 1 {
+		t.Fatalf("bad barrier config: %#v", conf)
+	}
+
+	// The old unseal keys are now the recovery keys
+	testCore_GenerateRoot_Update_OTP_Common(t, c, keys[0:3])
+
+	// A restarted node unseals itself with the new seal
+	c = testCoreSealMigration(t, phys, testSeal, &DefaultSeal{})
+	if c.IsInSealMigration() {
+		t.Fatal("expected no seal migration to be in progress")
+	}
+	if err := c.UnsealWithStoredKeys(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
+
+func TestCore_SealMigration_AutoToShamir(t *testing.T) {
+	phys := testSealMigrationPhysical(t)
+	testSeal := NewTestAutoSeal(t)
+	keys := testSealMigrationInit(t, phys, testSeal)
+
+	c := testCoreSealMigration(t, phys, &DefaultSeal{}, testSeal)
+	testSealMigrationUnseal(t, c, keys)
+
+	conf, err := c.seal.BarrierConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if conf.Type != SealTypeShamir || conf.SecretShares != 5 || conf.SecretThreshold != 3 || conf.StoredShares != 0 {
+		t.Fatalf("bad barrier config: %#v", conf)
+	}
+	for _, path := range []string{recoverySealConfigPlaintextPath, recoveryKeyPath, hsmStoredKeysPath} {
+		pe, err := phys.Get(path)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if pe != nil {
+			t.Fatalf("expected %s to be removed", path)
+		}
+	}
+
+	// The old recovery keys are now the unseal keys
+	c = testCoreSealMigration(t, phys, &DefaultSeal{}, testSeal)
+	for _, key := range keys[0:3] {
+		if _, err := c.Unseal(append([]byte(nil), key...)); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+	if sealed, _ := c.Sealed(); sealed {
+		t.Fatal("should not be sealed")
+	}
+}
+
+func TestCore_SealMigration_NotTransactional(t *testing.T) {
+	logger := logformat.NewVaultLogger(log.LevelTrace)
+	phys, err := physInmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := testSealMigrationInit(t, phys, &DefaultSeal{})
+
+	c := testCoreSealMigration(t, phys, NewTestAutoSeal(t), &DefaultSeal{})
+	var unsealErr error
+	for _, key := range keys[0:3] {
+		if _, unsealErr = c.UnsealMigrate(key); unsealErr != nil {
+			break
+		}
+	}
+	if unsealErr == nil {
+		t.Fatal("expected error")
+	}
+	if sealed, _ := c.Sealed(); !sealed {
+		t.Fatal("should be sealed")
+	}
+	if !c.IsInSealMigration() {
+		t.Fatal("expected seal migration to still be in progress")
+	}
+}
@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"github.com/hashicorp/vault/vault/seal"
 	"github.com/mitchellh/go-testing-interface"
 )
 
@@ -18,6 +19,11 @@ func NewTestSeal(t testing.T, opts *TestSealOpts) Seal {
 	return &DefaultSeal{}
 }
 
+// NewTestAutoSeal returns an auto-unseal Seal backed by an in-memory key
+func NewTestAutoSeal(t testing.T) Seal {
+	return NewAutoSeal(seal.NewTestSeal(t))
+}
+
 func testCoreUnsealedWithConfigs(t testing.T, barrierConf, recoveryConf *SealConfig) (*Core, [][]byte, [][]byte, string) {
 	seal := NewTestSeal(t, nil)
 	core := TestCoreWithSeal(t, seal, false)
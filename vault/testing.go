@@ -326,7 +326,7 @@ func testTokenStore(t testing.T, c *Core) *TokenStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = c.router.Mount(ts, "auth/token/", &MountEntry{Table: credentialTableType, UUID: "authtokenuuid", Path: "auth/token", Accessor: "authtokenaccessor"}, ts.view)
+	err = c.router.Mount(ts, "auth/token/", &MountEntry{Table: credentialTableType, Type: "token", UUID: "authtokenuuid", Path: "auth/token", Accessor: "authtokenaccessor"}, ts.view)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/helper/salt"
@@ -69,12 +70,22 @@ var (
 	// pathSuffixSanitize is used to ensure a path suffix in a role is valid.
 	pathSuffixSanitize = regexp.MustCompile("\\w[\\w-.]+\\w")
 
-	destroyCubbyhole = func(ts *TokenStore, saltedID string) error {
-		if ts.cubbyholeBackend == nil {
+	destroyCubbyhole = func(ts *TokenStore, te *TokenEntry, saltedID string) error {
+		// The cubbyhole of the token is in the namespace of the token
+		cubbyholeBackend := ts.cubbyholeBackend
+		if te.NamespaceID != "" && te.NamespaceID != namespace.RootNamespaceID {
+			ns := ts.core.namespaceByID(te.NamespaceID)
+			if ns == nil {
+				// The namespace and all of its storage are gone already
+				return nil
+			}
+			cubbyholeBackend, _ = ts.core.router.MatchingBackend(ns.Path + "cubbyhole/").(*CubbyholeBackend)
+		}
+		if cubbyholeBackend == nil {
 			// Should only ever happen in testing
 			return nil
 		}
-		return ts.cubbyholeBackend.revoke(salt.SaltID(ts.cubbyholeBackend.saltUUID, saltedID, salt.SHA1Hash))
+		return cubbyholeBackend.revoke(salt.SaltID(cubbyholeBackend.saltUUID, saltedID, salt.SHA1Hash))
 	}
 )
 
@@ -84,17 +95,19 @@ var (
 type TokenStore struct {
 	*framework.Backend
 
+	core *Core
+
 	view *BarrierView
 
 	expiration *ExpirationManager
 
 	cubbyholeBackend *CubbyholeBackend
 
-	policyLookupFunc func(string) (*Policy, error)
+	policyLookupFunc func(*namespace.Namespace, string) (*Policy, error)
 
 	tokenLocks []*locksutil.LockEntry
 
-	cubbyholeDestroyer func(*TokenStore, string) error
+	cubbyholeDestroyer func(*TokenStore, *TokenEntry, string) error
 
 	logger log.Logger
 
@@ -113,6 +126,7 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 
 	// Initialize the store
 	t := &TokenStore{
+		core:               c,
 		view:               view,
 		cubbyholeDestroyer: destroyCubbyhole,
 		logger:             c.logger,
@@ -121,8 +135,8 @@ func NewTokenStore(c *Core, config *logical.BackendConfig) (*TokenStore, error)
 	}
 
 	if c.policyStore != nil {
-		t.policyLookupFunc = func(name string) (*Policy, error) {
-			return c.policyStore.GetPolicy(name, PolicyTypeToken)
+		t.policyLookupFunc = func(ns *namespace.Namespace, name string) (*Policy, error) {
+			return c.policyStore.GetPolicy(ns, name, PolicyTypeToken)
 		}
 	}
 
@@ -585,6 +599,11 @@ type TokenEntry struct {
 	ExplicitMaxTTLDeprecated time.Duration `json:"ExplicitMaxTTL" mapstructure:"ExplicitMaxTTL" structs:"ExplicitMaxTTL" sentinel:""`
 
 	EntityID string `json:"entity_id" mapstructure:"entity_id" structs:"entity_id"`
+
+	// NamespaceID is the ID of the namespace the token was created in. The
+	// policies of the token are those of the namespace. An empty ID refers
+	// to the root namespace.
+	NamespaceID string `json:"namespace_id" mapstructure:"namespace_id" structs:"namespace_id"`
 }
 
 func (te *TokenEntry) SentinelGet(key string) (interface{}, error) {
@@ -1105,7 +1124,7 @@ func (ts *TokenStore) revokeSalted(saltedId string) (ret error) {
 
 	// Destroy the token's cubby. This should go first as it's a
 	// security-sensitive item.
-	err = ts.cubbyholeDestroyer(ts, saltedId)
+	err = ts.cubbyholeDestroyer(ts, entry, saltedId)
 	if err != nil {
 		return err
 	}
@@ -1203,7 +1222,7 @@ func (ts *TokenStore) revokeTreeSalted(saltedId string) error {
 func (ts *TokenStore) handleCreateAgainstRole(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("role_name").(string)
-	roleEntry, err := ts.tokenStoreRole(name)
+	roleEntry, err := ts.tokenStoreRole(ts.core.namespaceByPath(req.MountPoint), name)
 	if err != nil {
 		return nil, err
 	}
@@ -1493,6 +1512,10 @@ func (ts *TokenStore) handleUpdateRevokeAccessor(req *logical.Request, data *fra
 		return nil, err
 	}
 
+	if resp, err := ts.checkTokenNamespace(req, aEntry.TokenID); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke the token and its children
 	if err := ts.RevokeTree(aEntry.TokenID); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -1537,6 +1560,20 @@ func (ts *TokenStore) handleCreateCommon(
 			logical.ErrInvalidRequest
 	}
 
+	// Tokens are created in the namespace of the request. A parent token of
+	// an ancestor namespace may create tokens in it, but those tokens can't
+	// inherit the policies or the lineage of the parent, which belong to
+	// another namespace.
+	ns := ts.core.namespaceByPath(req.MountPoint)
+	parentNS := ts.core.namespaceByID(parent.NamespaceID)
+	if parentNS == nil {
+		return logical.ErrorResponse("parent token lookup failed"), logical.ErrInvalidRequest
+	}
+	crossNamespace := parentNS.ID != ns.ID
+	if crossNamespace && !ns.HasParent(parentNS) {
+		return logical.ErrorResponse("parent token must belong to the namespace or one of its ancestors"), logical.ErrInvalidRequest
+	}
+
 	// Check if the client token has sudo/root privileges for the requested path
 	isSudo := ts.System().SudoPrivilege(req.MountPoint+req.Path, req.ClientToken)
 
@@ -1571,9 +1608,9 @@ func (ts *TokenStore) handleCreateCommon(
 		Parent: req.ClientToken,
 
 		// The mount point is always the same since we have only one token
-		// store; using req.MountPoint causes trouble in tests since they don't
-		// have an official mount
-		Path: fmt.Sprintf("auth/token/%s", req.Path),
+		// store per namespace; using req.MountPoint causes trouble in tests
+		// since they don't have an official mount
+		Path: fmt.Sprintf("%sauth/token/%s", ns.Path, req.Path),
 
 		Meta:         data.Metadata,
 		DisplayName:  "token",
@@ -1581,6 +1618,12 @@ func (ts *TokenStore) handleCreateCommon(
 		CreationTime: time.Now().Unix(),
 	}
 
+	// Tokens of the root namespace don't record it, like the tokens that were
+	// created before namespaces existed
+	if ns.ID != namespace.RootNamespaceID {
+		te.NamespaceID = ns.ID
+	}
+
 	renewable := true
 	if data.Renewable != nil {
 		renewable = *data.Renewable
@@ -1692,6 +1735,15 @@ func (ts *TokenStore) handleCreateCommon(
 
 		data.Policies = finalPolicies
 
+	// The policies of a parent of another namespace have no meaning in the
+	// namespace of the token, so they can neither be inherited nor be used
+	// to restrict the requested ones
+	case crossNamespace && len(data.Policies) == 0:
+		return logical.ErrorResponse("policies must be specified when creating a token in a child namespace"), logical.ErrInvalidRequest
+
+	case crossNamespace:
+		addDefault = !data.NoDefaultPolicy
+
 	// No policies specified, inherit parent
 	case len(data.Policies) == 0:
 		// Only inherit "default" if the parent already has it, so don't touch addDefault here
@@ -1743,6 +1795,11 @@ func (ts *TokenStore) handleCreateCommon(
 		return logical.ErrorResponse("root tokens may not be created without parent token being root"), logical.ErrInvalidRequest
 	}
 
+	// The root policy only exists in the root namespace
+	if ns.ID != namespace.RootNamespaceID && strutil.StrListContains(te.Policies, "root") {
+		return logical.ErrorResponse("root tokens may not be created in child namespaces"), logical.ErrInvalidRequest
+	}
+
 	//
 	// NOTE: Do not modify policies below this line. We need the checks above
 	// to be the last checks as they must look at the final policy set.
@@ -1770,6 +1827,12 @@ func (ts *TokenStore) handleCreateCommon(
 		}
 	}
 
+	// Tokens of another namespace than their parent are always orphans, so
+	// that revoking the parent doesn't reach into the child namespace
+	if crossNamespace {
+		te.Parent = ""
+	}
+
 	// At this point, it is clear whether the token is going to be an orphan or
 	// not. If the token is not going to be an orphan, inherit the parent's
 	// entity identifier into the child token.
@@ -1924,7 +1987,7 @@ func (ts *TokenStore) handleCreateCommon(
 
 	if ts.policyLookupFunc != nil {
 		for _, p := range te.Policies {
-			policy, err := ts.policyLookupFunc(p)
+			policy, err := ts.policyLookupFunc(ns, p)
 			if err != nil {
 				return logical.ErrorResponse(fmt.Sprintf("could not look up policy %s", p)), nil
 			}
@@ -1964,6 +2027,10 @@ func (ts *TokenStore) handleRevokeTree(
 		urltoken = true
 	}
 
+	if resp, err := ts.checkTokenNamespace(req, id); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke the token and its children
 	if err := ts.RevokeTree(id); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -2010,6 +2077,10 @@ func (ts *TokenStore) handleRevokeOrphan(
 			logical.ErrInvalidRequest
 	}
 
+	if resp, err := ts.checkTokenNamespace(req, id); resp != nil || err != nil {
+		return resp, err
+	}
+
 	// Revoke and orphan
 	if err := ts.Revoke(id); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
@@ -2063,7 +2134,7 @@ func (ts *TokenStore) handleLookup(
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	if out == nil {
+	if out == nil || (id != req.ClientToken && !ts.entryInNamespace(req, out)) {
 		return logical.ErrorResponse("bad token"), logical.ErrPermissionDenied
 	}
 
@@ -2156,7 +2227,7 @@ func (ts *TokenStore) handleRenew(
 	}
 
 	// Verify the token exists
-	if te == nil {
+	if te == nil || (id != req.ClientToken && !ts.entryInNamespace(req, te)) {
 		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
 	}
 
@@ -2224,7 +2295,11 @@ func (ts *TokenStore) authRenew(
 		return f(req, d)
 	}
 
-	role, err := ts.tokenStoreRole(te.Role)
+	roleNS := ts.core.namespaceByID(te.NamespaceID)
+	if roleNS == nil {
+		return nil, fmt.Errorf("namespace of the token could not be found, not renewing")
+	}
+	role, err := ts.tokenStoreRole(roleNS, te.Role)
 	if err != nil {
 		return nil, fmt.Errorf("error looking up role %s: %s", te.Role, err)
 	}
@@ -2256,8 +2331,42 @@ func (ts *TokenStore) authRenew(
 	return f(req, d)
 }
 
-func (ts *TokenStore) tokenStoreRole(name string) (*tsRoleEntry, error) {
-	entry, err := ts.view.Get(fmt.Sprintf("%s%s", rolesPrefix, name))
+// rolesView returns the storage of the roles of the given namespace. The
+// roles of the root namespace are stored along with the tokens.
+func (ts *TokenStore) rolesView(ns *namespace.Namespace) *BarrierView {
+	if ns.ID == namespace.RootNamespaceID {
+		return ts.view
+	}
+	return ts.core.namespaceSystemBarrierView(ns).SubView(tokenSubPath)
+}
+
+// entryInNamespace returns whether the given token belongs to the namespace
+// of the request or one of its descendants. Tokens can only be managed from
+// within their own namespace or an ancestor of it.
+func (ts *TokenStore) entryInNamespace(req *logical.Request, te *TokenEntry) bool {
+	ns := ts.core.namespaceByPath(req.MountPoint)
+	teNS := ts.core.namespaceByID(te.NamespaceID)
+	if teNS == nil {
+		return false
+	}
+	return teNS.ID == ns.ID || teNS.HasParent(ns)
+}
+
+// checkTokenNamespace returns an error response if the token with the given
+// ID exists but can't be managed from the namespace of the request
+func (ts *TokenStore) checkTokenNamespace(req *logical.Request, id string) (*logical.Response, error) {
+	te, err := ts.Lookup(id)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if te != nil && !ts.entryInNamespace(req, te) {
+		return logical.ErrorResponse("token not found"), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+func (ts *TokenStore) tokenStoreRole(ns *namespace.Namespace, name string) (*tsRoleEntry, error) {
+	entry, err := ts.rolesView(ns).Get(fmt.Sprintf("%s%s", rolesPrefix, name))
 	if err != nil {
 		return nil, err
 	}
@@ -2275,7 +2384,7 @@ func (ts *TokenStore) tokenStoreRole(name string) (*tsRoleEntry, error) {
 
 func (ts *TokenStore) tokenStoreRoleList(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	entries, err := ts.view.List(rolesPrefix)
+	entries, err := ts.rolesView(ts.core.namespaceByPath(req.MountPoint)).List(rolesPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -2290,7 +2399,7 @@ func (ts *TokenStore) tokenStoreRoleList(
 
 func (ts *TokenStore) tokenStoreRoleDelete(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := ts.view.Delete(fmt.Sprintf("%s%s", rolesPrefix, data.Get("role_name").(string)))
+	err := ts.rolesView(ts.core.namespaceByPath(req.MountPoint)).Delete(fmt.Sprintf("%s%s", rolesPrefix, data.Get("role_name").(string)))
 	if err != nil {
 		return nil, err
 	}
@@ -2300,7 +2409,7 @@ func (ts *TokenStore) tokenStoreRoleDelete(
 
 func (ts *TokenStore) tokenStoreRoleRead(
 	req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	role, err := ts.tokenStoreRole(data.Get("role_name").(string))
+	role, err := ts.tokenStoreRole(ts.core.namespaceByPath(req.MountPoint), data.Get("role_name").(string))
 	if err != nil {
 		return nil, err
 	}
@@ -2329,7 +2438,7 @@ func (ts *TokenStore) tokenStoreRoleExistenceCheck(req *logical.Request, data *f
 	if name == "" {
 		return false, fmt.Errorf("role name cannot be empty")
 	}
-	role, err := ts.tokenStoreRole(name)
+	role, err := ts.tokenStoreRole(ts.core.namespaceByPath(req.MountPoint), name)
 	if err != nil {
 		return false, err
 	}
@@ -2343,7 +2452,7 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(
 	if name == "" {
 		return logical.ErrorResponse("role name cannot be empty"), nil
 	}
-	entry, err := ts.tokenStoreRole(name)
+	entry, err := ts.tokenStoreRole(ts.core.namespaceByPath(req.MountPoint), name)
 	if err != nil {
 		return nil, err
 	}
@@ -2441,7 +2550,7 @@ func (ts *TokenStore) tokenStoreRoleCreateUpdate(
 	if err != nil {
 		return nil, err
 	}
-	if err := ts.view.Put(jsonEntry); err != nil {
+	if err := ts.rolesView(ts.core.namespaceByPath(req.MountPoint)).Put(jsonEntry); err != nil {
 		return nil, err
 	}
 
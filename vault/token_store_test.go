@@ -13,6 +13,7 @@ import (
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 )
 
@@ -1114,7 +1115,7 @@ func TestTokenStore_HandleRequest_CreateToken_NonRoot_RootChild(t *testing.T) {
 
 	policy, _ := ParseACLPolicy(tokenCreationPolicy)
 	policy.Name = "test1"
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1970,19 +1971,19 @@ func TestTokenStore_RoleDisallowedPolicies(t *testing.T) {
 	// Create 3 different policies
 	policy, _ := ParseACLPolicy(tokenCreationPolicy)
 	policy.Name = "test1"
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
 	policy, _ = ParseACLPolicy(tokenCreationPolicy)
 	policy.Name = "test2"
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
 	policy, _ = ParseACLPolicy(tokenCreationPolicy)
 	policy.Name = "test3"
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
@@ -2899,7 +2900,7 @@ func TestTokenStore_NoDefaultPolicy(t *testing.T) {
 	ps := core.policyStore
 	policy, _ := ParseACLPolicy(tokenCreationPolicy)
 	policy.Name = "policy1"
-	if err := ps.SetPolicy(policy); err != nil {
+	if err := ps.SetPolicy(namespace.RootNamespace, policy); err != nil {
 		t.Fatal(err)
 	}
 
@@ -3265,7 +3266,7 @@ func TestTokenStore_RevokeUseCountToken(t *testing.T) {
 
 	origDestroyCubbyhole := ts.cubbyholeDestroyer
 
-	ts.cubbyholeDestroyer = func(*TokenStore, string) error {
+	ts.cubbyholeDestroyer = func(*TokenStore, *TokenEntry, string) error {
 		return fmt.Errorf("keep it frosty")
 	}
 
@@ -3287,7 +3288,7 @@ func TestTokenStore_RevokeUseCountToken(t *testing.T) {
 	}
 
 	// Check the race condition situation by making the process sleep
-	ts.cubbyholeDestroyer = func(*TokenStore, string) error {
+	ts.cubbyholeDestroyer = func(*TokenStore, *TokenEntry, string) error {
 		time.Sleep(1 * time.Second)
 		return fmt.Errorf("keep it frosty")
 	}